@@ -0,0 +1,336 @@
+package bitcoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/tokenized/pkg/storage"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AccountExternalChain is the chain index of addresses given out to receive payments.
+	AccountExternalChain = uint32(0)
+
+	// AccountInternalChain is the chain index of change addresses.
+	AccountInternalChain = uint32(1)
+
+	// DefaultAccountGapLimit is the number of unused addresses past the last used address that are
+	//   watched for payments.
+	DefaultAccountGapLimit = 20
+
+	// accountVersion is the version of the serialized account cursor.
+	accountVersion = uint8(0)
+)
+
+var (
+	// ErrUnknownAccountChain means a chain index is not the external or internal chain.
+	ErrUnknownAccountChain = errors.New("Unknown Account Chain")
+)
+
+// Account manages the addresses of a BIP-0032 account key. It issues the next unused address on
+//   the external (receiving) and internal (change) chains, watches the addresses up to a gap limit
+//   past the last one issued or used so payments to them can be recognized, and persists the
+//   position of each chain in storage.
+// The account key can be private or public. Keys for signing are only available from private
+//   account keys.
+type Account struct {
+	key      ExtendedKey
+	store    storage.Storage
+	path     string
+	gapLimit uint32
+
+	chains [2]*accountChain
+
+	sync.Mutex
+}
+
+// AccountAddress is an address of an account along with its position in the account.
+type AccountAddress struct {
+	Chain         uint32
+	Index         uint32
+	Address       RawAddress
+	LockingScript Script
+}
+
+type accountChain struct {
+	chain     uint32
+	key       ExtendedKey
+	next      uint32            // index of next address to issue
+	addresses []*AccountAddress // derived addresses by index
+	scripts   map[string]uint32 // locking script to index of derived addresses
+}
+
+// NewAccount creates an account for the key that stores its cursor in the store at the path. A
+//   gapLimit of zero uses DefaultAccountGapLimit.
+func NewAccount(key ExtendedKey, store storage.Storage, path string,
+	gapLimit uint32) (*Account, error) {
+
+	if gapLimit == 0 {
+		gapLimit = DefaultAccountGapLimit
+	}
+
+	result := &Account{
+		key:      key,
+		store:    store,
+		path:     path,
+		gapLimit: gapLimit,
+	}
+
+	for i := range result.chains {
+		chainKey, err := key.ChildKey(uint32(i))
+		if err != nil {
+			return nil, errors.Wrapf(err, "chain key %d", i)
+		}
+
+		result.chains[i] = &accountChain{
+			chain:   uint32(i),
+			key:     chainKey,
+			scripts: make(map[string]uint32),
+		}
+
+		if err := result.chains[i].fill(gapLimit); err != nil {
+			return nil, errors.Wrapf(err, "chain %d", i)
+		}
+	}
+
+	return result, nil
+}
+
+// LoadAccount creates an account for the key and loads its cursor from the store. If the cursor
+//   was never saved then the account starts at the first address of each chain.
+func LoadAccount(ctx context.Context, key ExtendedKey, store storage.Storage, path string,
+	gapLimit uint32) (*Account, error) {
+
+	result, err := NewAccount(key, store, path, gapLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := store.Read(ctx, path)
+	if err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return result, nil
+		}
+		return nil, errors.Wrap(err, "read")
+	}
+
+	if err := result.deserialize(bytes.NewReader(b)); err != nil {
+		return nil, errors.Wrap(err, "deserialize")
+	}
+
+	return result, nil
+}
+
+// Save writes the account's cursor to the store.
+func (a *Account) Save(ctx context.Context) error {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.save(ctx)
+}
+
+// NextAddress issues the next unused receiving address and saves the cursor.
+func (a *Account) NextAddress(ctx context.Context) (*AccountAddress, error) {
+	return a.nextAddress(ctx, AccountExternalChain)
+}
+
+// NextChangeAddress issues the next unused change address and saves the cursor.
+func (a *Account) NextChangeAddress(ctx context.Context) (*AccountAddress, error) {
+	return a.nextAddress(ctx, AccountInternalChain)
+}
+
+// NextIndex returns the index of the next address that will be issued on the chain.
+func (a *Account) NextIndex(chain uint32) (uint32, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if chain >= uint32(len(a.chains)) {
+		return 0, errors.Wrapf(ErrUnknownAccountChain, "%d", chain)
+	}
+
+	return a.chains[chain].next, nil
+}
+
+// Lookup returns the account address with the locking script, or nil if the locking script isn't
+//   one of the watched addresses.
+func (a *Account) Lookup(lockingScript Script) *AccountAddress {
+	a.Lock()
+	defer a.Unlock()
+
+	for _, chain := range a.chains {
+		if index, exists := chain.scripts[string(lockingScript)]; exists {
+			address := *chain.addresses[index]
+			return &address
+		}
+	}
+
+	return nil
+}
+
+// MarkUsed marks the addresses with the locking scripts as used, for example from the outputs of
+//   an observed tx. The cursor of each chain is moved past its highest used address so that
+//   address isn't issued again and more addresses are watched. It returns the number of locking
+//   scripts that belong to the account and saves the cursor if it changed.
+func (a *Account) MarkUsed(ctx context.Context, lockingScripts ...Script) (int, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	count := 0
+	modified := false
+	for _, lockingScript := range lockingScripts {
+		for _, chain := range a.chains {
+			index, exists := chain.scripts[string(lockingScript)]
+			if !exists {
+				continue
+			}
+
+			count++
+			if index >= chain.next {
+				chain.next = index + 1
+				modified = true
+			}
+			break
+		}
+	}
+
+	if !modified {
+		return count, nil
+	}
+
+	for i, chain := range a.chains {
+		if err := chain.fill(a.gapLimit); err != nil {
+			return count, errors.Wrapf(err, "chain %d", i)
+		}
+	}
+
+	if err := a.save(ctx); err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+// Key returns the key for the address at the index of the chain so it can be spent. The account
+//   key must be private.
+func (a *Account) Key(net Network, chain, index uint32) (Key, error) {
+	if !a.key.IsPrivate() {
+		return Key{}, errors.New("Account key not private")
+	}
+
+	if chain >= uint32(len(a.chains)) {
+		return Key{}, errors.Wrapf(ErrUnknownAccountChain, "%d", chain)
+	}
+
+	childKey, err := a.chains[chain].key.ChildKey(index)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "child key")
+	}
+
+	return childKey.Key(net), nil
+}
+
+func (a *Account) nextAddress(ctx context.Context, chainIndex uint32) (*AccountAddress, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	chain := a.chains[chainIndex]
+	index := chain.next
+	chain.next++
+
+	if err := chain.fill(a.gapLimit); err != nil {
+		chain.next--
+		return nil, err
+	}
+
+	if err := a.save(ctx); err != nil {
+		chain.next--
+		return nil, err
+	}
+
+	address := *chain.addresses[index]
+	return &address, nil
+}
+
+func (a *Account) save(ctx context.Context) error {
+	buf := &bytes.Buffer{}
+	if err := a.serialize(buf); err != nil {
+		return errors.Wrap(err, "serialize")
+	}
+
+	if err := a.store.Write(ctx, a.path, buf.Bytes(), nil); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+
+func (a *Account) serialize(buf *bytes.Buffer) error {
+	if err := buf.WriteByte(accountVersion); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	for i, chain := range a.chains {
+		if err := binary.Write(buf, binary.LittleEndian, chain.next); err != nil {
+			return errors.Wrapf(err, "chain %d", i)
+		}
+	}
+
+	return nil
+}
+
+func (a *Account) deserialize(r *bytes.Reader) error {
+	version, err := r.ReadByte()
+	if err != nil {
+		return errors.Wrap(err, "version")
+	}
+	if version != accountVersion {
+		return fmt.Errorf("Unsupported version : %d", version)
+	}
+
+	for i, chain := range a.chains {
+		if err := binary.Read(r, binary.LittleEndian, &chain.next); err != nil {
+			return errors.Wrapf(err, "chain %d", i)
+		}
+
+		if err := chain.fill(a.gapLimit); err != nil {
+			return errors.Wrapf(err, "fill chain %d", i)
+		}
+	}
+
+	return nil
+}
+
+// fill derives the addresses up to the gap limit past the next address to issue.
+func (c *accountChain) fill(gapLimit uint32) error {
+	for index := uint32(len(c.addresses)); index < c.next+gapLimit; index++ {
+		childKey, err := c.key.ChildKey(index)
+		if err != nil {
+			return errors.Wrapf(err, "child key %d", index)
+		}
+
+		ra, err := childKey.RawAddress()
+		if err != nil {
+			return errors.Wrapf(err, "address %d", index)
+		}
+
+		lockingScript, err := ra.LockingScript()
+		if err != nil {
+			return errors.Wrapf(err, "locking script %d", index)
+		}
+
+		c.addresses = append(c.addresses, &AccountAddress{
+			Chain:         c.chain,
+			Index:         index,
+			Address:       ra,
+			LockingScript: lockingScript,
+		})
+		c.scripts[string(lockingScript)] = index
+	}
+
+	return nil
+}
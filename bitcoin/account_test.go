@@ -0,0 +1,130 @@
+package bitcoin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tokenized/pkg/storage"
+)
+
+func TestAccount(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+
+	masterKey, err := GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	accountKey, err := masterKey.ChildKeyForPath([]uint32{Hardened + 44, Hardened + 236,
+		Hardened})
+	if err != nil {
+		t.Fatalf("Failed to derive account key : %s", err)
+	}
+
+	account, err := NewAccount(accountKey.ExtendedPublicKey(), store, "accounts/0", 5)
+	if err != nil {
+		t.Fatalf("Failed to create account : %s", err)
+	}
+
+	first, err := account.NextAddress(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next address : %s", err)
+	}
+	if first.Chain != AccountExternalChain || first.Index != 0 {
+		t.Fatalf("Wrong first address : chain %d, index %d", first.Chain, first.Index)
+	}
+
+	expectedKey, err := accountKey.ChildKeyForPath([]uint32{0, 0})
+	if err != nil {
+		t.Fatalf("Failed to derive key : %s", err)
+	}
+	expectedAddress, _ := expectedKey.RawAddress()
+	if !first.Address.Equal(expectedAddress) {
+		t.Fatalf("Wrong first address : got %x, want %x", first.Address.Bytes(),
+			expectedAddress.Bytes())
+	}
+
+	change, err := account.NextChangeAddress(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next change address : %s", err)
+	}
+	if change.Chain != AccountInternalChain || change.Index != 0 {
+		t.Fatalf("Wrong change address : chain %d, index %d", change.Chain, change.Index)
+	}
+
+	// Derive an address within the gap limit that hasn't been issued, as if it was issued by
+	// another instance of the wallet, and observe a payment to it.
+	otherKey, err := accountKey.ChildKeyForPath([]uint32{AccountExternalChain, 4})
+	if err != nil {
+		t.Fatalf("Failed to derive key : %s", err)
+	}
+	otherAddress, _ := otherKey.RawAddress()
+	otherScript, _ := otherAddress.LockingScript()
+
+	unknownKey, _ := GenerateKey(MainNet)
+	unknownScript, _ := unknownKey.LockingScript()
+
+	count, err := account.MarkUsed(ctx, otherScript, unknownScript, change.LockingScript)
+	if err != nil {
+		t.Fatalf("Failed to mark used : %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("Wrong used count : got %d, want %d", count, 2)
+	}
+
+	lookup := account.Lookup(otherScript)
+	if lookup == nil || lookup.Index != 4 {
+		t.Fatalf("Wrong lookup result : %+v", lookup)
+	}
+
+	// Addresses past the new gap are watched.
+	farKey, _ := accountKey.ChildKeyForPath([]uint32{AccountExternalChain, 9})
+	farAddress, _ := farKey.RawAddress()
+	farScript, _ := farAddress.LockingScript()
+	if account.Lookup(farScript) == nil {
+		t.Fatalf("Address within new gap should be watched")
+	}
+
+	next, err := account.NextAddress(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next address : %s", err)
+	}
+	if next.Index != 5 {
+		t.Fatalf("Wrong next index : got %d, want %d", next.Index, 5)
+	}
+
+	loaded, err := LoadAccount(ctx, accountKey.ExtendedPublicKey(), store, "accounts/0", 5)
+	if err != nil {
+		t.Fatalf("Failed to load account : %s", err)
+	}
+
+	for _, chain := range []uint32{AccountExternalChain, AccountInternalChain} {
+		want, _ := account.NextIndex(chain)
+		got, err := loaded.NextIndex(chain)
+		if err != nil {
+			t.Fatalf("Failed to get next index : %s", err)
+		}
+		if got != want {
+			t.Fatalf("Wrong loaded next index for chain %d : got %d, want %d", chain, got, want)
+		}
+	}
+
+	if _, err := loaded.Key(MainNet, AccountExternalChain, 4); err == nil {
+		t.Fatalf("Public account should not provide keys")
+	}
+
+	private, err := LoadAccount(ctx, accountKey, store, "accounts/0", 5)
+	if err != nil {
+		t.Fatalf("Failed to load account : %s", err)
+	}
+
+	key, err := private.Key(MainNet, AccountExternalChain, 4)
+	if err != nil {
+		t.Fatalf("Failed to get key : %s", err)
+	}
+	keyAddress, _ := key.RawAddress()
+	if !keyAddress.Equal(otherAddress) {
+		t.Fatalf("Wrong key address : got %x, want %x", keyAddress.Bytes(), otherAddress.Bytes())
+	}
+}
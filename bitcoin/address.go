@@ -137,12 +137,25 @@ func (a *Address) decodeBytes(b []byte) error {
 		return a.SetNonStandard(b[1:], TestNet)
 	}
 
+	// Custom networks
+	if params, exists := networkForAddressType(b[0]); exists {
+		if b[0] == params.PKHAddressType {
+			return a.SetPKH(b[1:], params.Net)
+		}
+		return a.SetSH(b[1:], params.Net)
+	}
+
 	return ErrBadType
 }
 
 // DecodeNetMatches returns true if the decoded network id matches the specified network id.
-// All test network ids decode as TestNet.
+// All test network ids that use the test net address types decode as TestNet. Custom networks
+//   with their own address types only match themselves. InvalidNet never matches.
 func DecodeNetMatches(decoded Network, desired Network) bool {
+	if decoded == InvalidNet || desired == InvalidNet {
+		return false
+	}
+
 	switch decoded {
 	case MainNet:
 		return desired == MainNet
@@ -150,7 +163,7 @@ func DecodeNetMatches(decoded Network, desired Network) bool {
 		return desired != MainNet
 	}
 
-	return false
+	return decoded == desired
 }
 
 // NewAddressFromRawAddress creates an Address from a RawAddress and a network.
@@ -159,11 +172,7 @@ func NewAddressFromRawAddress(ra RawAddress, net Network) Address {
 
 	switch ra.scriptType {
 	case ScriptTypePKH:
-		if net == MainNet {
-			result.addressType = AddressTypeMainPKH
-		} else {
-			result.addressType = AddressTypeTestPKH
-		}
+		result.addressType = pkhAddressType(net)
 	case ScriptTypePK:
 		if net == MainNet {
 			result.addressType = AddressTypeMainPK
@@ -171,11 +180,7 @@ func NewAddressFromRawAddress(ra RawAddress, net Network) Address {
 			result.addressType = AddressTypeTestPK
 		}
 	case ScriptTypeSH:
-		if net == MainNet {
-			result.addressType = AddressTypeMainSH
-		} else {
-			result.addressType = AddressTypeTestSH
-		}
+		result.addressType = shAddressType(net)
 	case ScriptTypeMultiPKH:
 		if net == MainNet {
 			result.addressType = AddressTypeMainMultiPKH
@@ -214,12 +219,7 @@ func (a *Address) SetPKH(pkh []byte, net Network) error {
 		return ErrBadScriptHashLength
 	}
 
-	if net == MainNet {
-		a.addressType = AddressTypeMainPKH
-	} else {
-		a.addressType = AddressTypeTestPKH
-	}
-
+	a.addressType = pkhAddressType(net)
 	a.data = pkh
 	return nil
 }
@@ -291,12 +291,7 @@ func (a *Address) SetSH(sh []byte, net Network) error {
 		return ErrBadScriptHashLength
 	}
 
-	if net == MainNet {
-		a.addressType = AddressTypeMainSH
-	} else {
-		a.addressType = AddressTypeTestSH
-	}
-
+	a.addressType = shAddressType(net)
 	a.data = sh
 	return nil
 }
@@ -403,6 +398,9 @@ func (a Address) Network() Network {
 		AddressTypeMainNonStandard:
 		return MainNet
 	}
+	if params, exists := networkForAddressType(a.addressType); exists {
+		return params.Net
+	}
 	return TestNet
 }
 
@@ -421,6 +419,9 @@ func (a Address) Hash() (*Hash20, error) {
 		AddressTypeMainNonStandard, AddressTypeTestNonStandard:
 		return NewHash20(Hash160(a.data))
 	}
+	if _, exists := networkForAddressType(a.addressType); exists {
+		return NewHash20(a.data)
+	}
 	return nil, ErrUnknownScriptTemplate
 }
 
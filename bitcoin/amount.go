@@ -0,0 +1,250 @@
+package bitcoin
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SatoshisPerBitcoin is the number of satoshis in one bitcoin.
+	SatoshisPerBitcoin = 100000000
+
+	// MaxSatoshis is the largest amount that can exist.
+	MaxSatoshis = Satoshis(21000000 * SatoshisPerBitcoin)
+)
+
+var (
+	// ErrAmountOverflow means an arithmetic operation on amounts overflowed.
+	ErrAmountOverflow = errors.New("Amount Overflow")
+
+	// ErrAmountUnderflow means a subtraction would result in a negative amount.
+	ErrAmountUnderflow = errors.New("Amount Underflow")
+
+	// ErrInvalidAmount means a string is not a valid amount.
+	ErrInvalidAmount = errors.New("Invalid Amount")
+
+	// UnitSatoshis formats amounts as a whole number of satoshis.
+	UnitSatoshis = Unit{Symbol: "sat", Decimals: 0}
+
+	// UnitBitcoin formats amounts as bitcoin with 8 decimal places.
+	UnitBitcoin = Unit{Symbol: "BSV", Decimals: 8}
+
+	// UnitMilliBitcoin formats amounts as thousandths of a bitcoin.
+	UnitMilliBitcoin = Unit{Symbol: "mBSV", Decimals: 5}
+
+	// parseUnits are the unit symbols recognized when parsing amounts. Symbols are compared
+	//   case insensitively.
+	parseUnits = map[string]Unit{
+		"sat":      UnitSatoshis,
+		"sats":     UnitSatoshis,
+		"satoshi":  UnitSatoshis,
+		"satoshis": UnitSatoshis,
+		"bsv":      UnitBitcoin,
+		"btc":      UnitBitcoin,
+		"mbsv":     UnitMilliBitcoin,
+	}
+)
+
+// Satoshis is an amount of bitcoin in satoshis. Use it instead of a plain uint64 or a float so the
+//   unit of an amount is always clear. Arithmetic methods return errors instead of wrapping.
+type Satoshis uint64
+
+// Unit specifies how an amount is formatted. Decimals is the number of satoshi digits to the
+//   right of the decimal point, so bitcoin is 8 and satoshis is 0.
+type Unit struct {
+	Symbol   string
+	Decimals uint8
+}
+
+// Add returns the sum of the amounts or ErrAmountOverflow.
+func (s Satoshis) Add(o Satoshis) (Satoshis, error) {
+	result := s + o
+	if result < s {
+		return 0, errors.Wrapf(ErrAmountOverflow, "%d + %d", s, o)
+	}
+	return result, nil
+}
+
+// Sub returns the difference of the amounts or ErrAmountUnderflow if o is larger than s.
+func (s Satoshis) Sub(o Satoshis) (Satoshis, error) {
+	if o > s {
+		return 0, errors.Wrapf(ErrAmountUnderflow, "%d - %d", s, o)
+	}
+	return s - o, nil
+}
+
+// Mul returns the amount multiplied by n or ErrAmountOverflow.
+func (s Satoshis) Mul(n uint64) (Satoshis, error) {
+	if n != 0 && uint64(s) > math.MaxUint64/n {
+		return 0, errors.Wrapf(ErrAmountOverflow, "%d * %d", s, n)
+	}
+	return s * Satoshis(n), nil
+}
+
+// SumSatoshis returns the sum of the amounts or ErrAmountOverflow.
+func SumSatoshis(amounts ...Satoshis) (Satoshis, error) {
+	var result Satoshis
+	for _, amount := range amounts {
+		sum, err := result.Add(amount)
+		if err != nil {
+			return 0, err
+		}
+		result = sum
+	}
+	return result, nil
+}
+
+// Uint64 returns the amount as a number of satoshis.
+func (s Satoshis) Uint64() uint64 {
+	return uint64(s)
+}
+
+// Bitcoins returns the amount as a float number of bitcoin. It is only for display since floats
+//   can't represent all amounts exactly.
+func (s Satoshis) Bitcoins() float64 {
+	return float64(s) / SatoshisPerBitcoin
+}
+
+// String returns the amount formatted in bitcoin, like "1.23400000 BSV".
+func (s Satoshis) String() string {
+	return s.Format(UnitBitcoin)
+}
+
+// Format returns the amount in the unit followed by the unit's symbol, like "1.23400000 BSV".
+func (s Satoshis) Format(unit Unit) string {
+	if len(unit.Symbol) == 0 {
+		return s.FormatNumber(unit.Decimals)
+	}
+	return s.FormatNumber(unit.Decimals) + " " + unit.Symbol
+}
+
+// FormatNumber returns the amount with the specified number of decimal places and no symbol.
+func (s Satoshis) FormatNumber(decimals uint8) string {
+	digits := strconv.FormatUint(uint64(s), 10)
+	if decimals == 0 {
+		return digits
+	}
+
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+
+	split := len(digits) - int(decimals)
+	return digits[:split] + "." + digits[split:]
+}
+
+// ParseSatoshis parses an amount like "1.234 BSV", "1234 sat", or "1234". A number without a unit
+//   is a number of satoshis. The amount can't have more precision than one satoshi.
+func ParseSatoshis(s string) (Satoshis, error) {
+	s = strings.TrimSpace(s)
+	unit := UnitSatoshis
+
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		// Allow a symbol without a space, like "1.234BSV".
+		number := strings.TrimRight(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+		if symbol := s[len(number):]; len(symbol) > 0 {
+			u, exists := parseUnits[strings.ToLower(symbol)]
+			if !exists {
+				return 0, errors.Wrapf(ErrInvalidAmount, "unknown unit %s", symbol)
+			}
+			unit = u
+			s = number
+		}
+	case 2:
+		u, exists := parseUnits[strings.ToLower(fields[1])]
+		if !exists {
+			return 0, errors.Wrapf(ErrInvalidAmount, "unknown unit %s", fields[1])
+		}
+		unit = u
+		s = fields[0]
+	default:
+		return 0, errors.Wrapf(ErrInvalidAmount, "%q", s)
+	}
+
+	return ParseSatoshisUnit(s, unit)
+}
+
+// ParseSatoshisUnit parses a number, without a symbol, in the unit.
+func ParseSatoshisUnit(s string, unit Unit) (Satoshis, error) {
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+
+	if len(whole) == 0 && len(fraction) == 0 {
+		return 0, errors.Wrapf(ErrInvalidAmount, "%q", s)
+	}
+
+	// Trailing zeros don't add precision.
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > int(unit.Decimals) {
+		return 0, errors.Wrapf(ErrInvalidAmount, "%q: more than %d decimal places", s,
+			unit.Decimals)
+	}
+
+	digits := whole + fraction + strings.Repeat("0", int(unit.Decimals)-len(fraction))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, errors.Wrapf(ErrInvalidAmount, "%q", s)
+		}
+	}
+
+	value, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return 0, errors.Wrapf(ErrAmountOverflow, "%q", s)
+		}
+		return 0, errors.Wrapf(ErrInvalidAmount, "%q", s)
+	}
+
+	return Satoshis(value), nil
+}
+
+// MarshalJSON converts to json as a number of satoshis.
+func (s Satoshis) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(s), 10)), nil
+}
+
+// UnmarshalJSON converts from json. It accepts a number of satoshis or a string with a unit, like
+//   "1.234 BSV".
+func (s *Satoshis) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		return s.UnmarshalText(data[1 : len(data)-1])
+	}
+
+	value, err := ParseSatoshisUnit(string(data), UnitSatoshis)
+	if err != nil {
+		return errors.Wrap(err, "json")
+	}
+
+	*s = value
+	return nil
+}
+
+// MarshalText returns the text encoding of the amount as a number of satoshis.
+// Implements encoding.TextMarshaler interface.
+func (s Satoshis) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(s), 10)), nil
+}
+
+// UnmarshalText parses an amount with ParseSatoshis.
+// Implements encoding.TextUnmarshaler interface.
+func (s *Satoshis) UnmarshalText(text []byte) error {
+	value, err := ParseSatoshis(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = value
+	return nil
+}
+
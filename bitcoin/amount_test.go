@@ -0,0 +1,179 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseSatoshis(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Satoshis
+		err  error
+	}{
+		{"1234", 1234, nil},
+		{"1234 sat", 1234, nil},
+		{"1234 sats", 1234, nil},
+		{"1.234 BSV", 123400000, nil},
+		{"1.234bsv", 123400000, nil},
+		{" 0.00000001 BSV ", 1, nil},
+		{".5 BTC", 50000000, nil},
+		{"21000000 BSV", MaxSatoshis, nil},
+		{"1.50000000000 BSV", 150000000, nil},
+		{"2 mBSV", 200000, nil},
+		{"0.000000001 BSV", 0, ErrInvalidAmount},
+		{"1.5 sat", 0, ErrInvalidAmount},
+		{"1.5 ETH", 0, ErrInvalidAmount},
+		{"-1", 0, ErrInvalidAmount},
+		{"1,000", 0, ErrInvalidAmount},
+		{"", 0, ErrInvalidAmount},
+		{".", 0, ErrInvalidAmount},
+		{"1 2 BSV", 0, ErrInvalidAmount},
+		{"18446744073709551616", 0, ErrAmountOverflow},
+		{"1000000000000 BSV", 0, ErrAmountOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseSatoshis(tt.s)
+			if errors.Cause(err) != tt.err {
+				t.Fatalf("Wrong error : got %v, want %v", err, tt.err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("Wrong amount : got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSatoshisFormat(t *testing.T) {
+	tests := []struct {
+		value Satoshis
+		unit  Unit
+		want  string
+	}{
+		{123400000, UnitBitcoin, "1.23400000 BSV"},
+		{1, UnitBitcoin, "0.00000001 BSV"},
+		{0, UnitBitcoin, "0.00000000 BSV"},
+		{1234, UnitSatoshis, "1234 sat"},
+		{200000, UnitMilliBitcoin, "2.00000 mBSV"},
+		{150000000, Unit{Decimals: 8}, "1.50000000"},
+		{150000000, Unit{Symbol: "BTC", Decimals: 8}, "1.50000000 BTC"},
+	}
+
+	for _, tt := range tests {
+		got := tt.value.Format(tt.unit)
+		if got != tt.want {
+			t.Errorf("Wrong format : got %s, want %s", got, tt.want)
+		}
+
+		// Formatted amounts parse back to the same value.
+		if len(tt.unit.Symbol) > 0 {
+			parsed, err := ParseSatoshis(got)
+			if err != nil {
+				t.Errorf("Failed to parse %s : %s", got, err)
+			} else if parsed != tt.value {
+				t.Errorf("Wrong parsed amount : got %d, want %d", parsed, tt.value)
+			}
+		}
+	}
+
+	if Satoshis(123400000).String() != "1.23400000 BSV" {
+		t.Fatalf("Wrong string : %s", Satoshis(123400000).String())
+	}
+}
+
+func TestSatoshisArithmetic(t *testing.T) {
+	sum, err := Satoshis(5).Add(7)
+	if err != nil {
+		t.Fatalf("Failed to add : %s", err)
+	}
+	if sum != 12 {
+		t.Fatalf("Wrong sum : got %d, want %d", sum, 12)
+	}
+
+	if _, err := Satoshis(math.MaxUint64).Add(1); errors.Cause(err) != ErrAmountOverflow {
+		t.Fatalf("Wrong add error : got %v, want %v", err, ErrAmountOverflow)
+	}
+
+	difference, err := Satoshis(7).Sub(5)
+	if err != nil {
+		t.Fatalf("Failed to subtract : %s", err)
+	}
+	if difference != 2 {
+		t.Fatalf("Wrong difference : got %d, want %d", difference, 2)
+	}
+
+	if _, err := Satoshis(5).Sub(7); errors.Cause(err) != ErrAmountUnderflow {
+		t.Fatalf("Wrong sub error : got %v, want %v", err, ErrAmountUnderflow)
+	}
+
+	product, err := Satoshis(5).Mul(3)
+	if err != nil {
+		t.Fatalf("Failed to multiply : %s", err)
+	}
+	if product != 15 {
+		t.Fatalf("Wrong product : got %d, want %d", product, 15)
+	}
+
+	if _, err := Satoshis(math.MaxUint64 / 2).Mul(3); errors.Cause(err) != ErrAmountOverflow {
+		t.Fatalf("Wrong mul error : got %v, want %v", err, ErrAmountOverflow)
+	}
+
+	total, err := SumSatoshis(1, 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to sum : %s", err)
+	}
+	if total != 6 {
+		t.Fatalf("Wrong total : got %d, want %d", total, 6)
+	}
+
+	if _, err := SumSatoshis(1, math.MaxUint64); errors.Cause(err) != ErrAmountOverflow {
+		t.Fatalf("Wrong sum error : got %v, want %v", err, ErrAmountOverflow)
+	}
+}
+
+func TestSatoshisJSON(t *testing.T) {
+	type payment struct {
+		Amount Satoshis `json:"amount"`
+	}
+
+	b, err := json.Marshal(payment{Amount: 123400000})
+	if err != nil {
+		t.Fatalf("Failed to marshal : %s", err)
+	}
+	if string(b) != `{"amount":123400000}` {
+		t.Fatalf("Wrong json : %s", b)
+	}
+
+	tests := []struct {
+		js   string
+		want Satoshis
+	}{
+		{`{"amount":123400000}`, 123400000},
+		{`{"amount":"1.234 BSV"}`, 123400000},
+		{`{"amount":"5000"}`, 5000},
+		{`{"amount":null}`, 0},
+	}
+
+	for _, tt := range tests {
+		var p payment
+		if err := json.Unmarshal([]byte(tt.js), &p); err != nil {
+			t.Fatalf("Failed to unmarshal %s : %s", tt.js, err)
+		}
+		if p.Amount != tt.want {
+			t.Fatalf("Wrong amount for %s : got %d, want %d", tt.js, p.Amount, tt.want)
+		}
+	}
+
+	for _, js := range []string{`{"amount":1.5}`, `{"amount":-1}`, `{"amount":"1 ETH"}`} {
+		var p payment
+		if err := json.Unmarshal([]byte(js), &p); err == nil {
+			t.Fatalf("Unmarshal should fail for %s", js)
+		}
+	}
+}
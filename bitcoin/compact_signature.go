@@ -0,0 +1,183 @@
+package bitcoin
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CompactSignatureSize is the size of a compact signature, which is the 32 byte R value
+	//   followed by the 32 byte S value.
+	CompactSignatureSize = 64
+
+	// RecoverableSignatureSize is the size of a recoverable compact signature, which is a header
+	//   byte containing the recovery id followed by a compact signature.
+	RecoverableSignatureSize = 65
+
+	// recoverableHeader is added to the recovery id in the header byte. 4 more is added when the
+	//   public key is compressed.
+	recoverableHeader = 27
+)
+
+var (
+	// ErrRecoverPublicKey means a public key could not be recovered from a signature.
+	ErrRecoverPublicKey = errors.New("Public Key Not Recoverable")
+)
+
+// RecoverableSignature is a signature with the recovery id needed to recover the public key that
+//   created it from the signature and the signed hash. This is the format used by Bitcoin Signed
+//   Messages (BSM).
+type RecoverableSignature struct {
+	Signature Signature
+
+	// RecoveryID identifies which of the possible public keys created the signature (0-3).
+	RecoveryID uint8
+
+	// Compressed is true when the public key is compressed. Keys in this package are always
+	//   compressed.
+	Compressed bool
+}
+
+// SignatureFromCompactBytes decodes a 64 byte compact signature.
+func SignatureFromCompactBytes(b []byte) (Signature, error) {
+	if len(b) != CompactSignatureSize {
+		return Signature{}, fmt.Errorf("Wrong compact signature length : got %d, want %d", len(b),
+			CompactSignatureSize)
+	}
+
+	var result Signature
+	result.R.SetBytes(b[:32])
+	result.S.SetBytes(b[32:])
+
+	return result, result.Validate()
+}
+
+// CompactBytes returns the 64 byte compact encoding of the signature.
+func (s Signature) CompactBytes() []byte {
+	result := make([]byte, CompactSignatureSize)
+	rb := s.R.Bytes()
+	copy(result[32-len(rb):32], rb)
+	sb := s.S.Bytes()
+	copy(result[64-len(sb):], sb)
+	return result
+}
+
+// DERToCompact converts a DER encoded signature to a 64 byte compact signature.
+func DERToCompact(der []byte) ([]byte, error) {
+	signature, err := SignatureFromBytes(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "der")
+	}
+
+	return signature.CompactBytes(), nil
+}
+
+// CompactToDER converts a 64 byte compact signature to a DER encoded signature.
+func CompactToDER(compact []byte) ([]byte, error) {
+	signature, err := SignatureFromCompactBytes(compact)
+	if err != nil {
+		return nil, errors.Wrap(err, "compact")
+	}
+
+	return signature.Bytes(), nil
+}
+
+// SignRecoverable returns a signature of the hash with the recovery id of the key.
+func (k Key) SignRecoverable(hash Hash32) (RecoverableSignature, error) {
+	signature, err := k.Sign(hash)
+	if err != nil {
+		return RecoverableSignature{}, err
+	}
+
+	publicKey := k.PublicKey()
+	for id := uint8(0); id < 4; id++ {
+		result := RecoverableSignature{
+			Signature:  signature,
+			RecoveryID: id,
+			Compressed: true,
+		}
+
+		recovered, err := result.RecoverPublicKey(hash)
+		if err != nil {
+			continue
+		}
+
+		if recovered.Equal(publicKey) {
+			return result, nil
+		}
+	}
+
+	return RecoverableSignature{}, errors.Wrap(ErrRecoverPublicKey, "recovery id")
+}
+
+// RecoverableSignatureFromBytes decodes a 65 byte recoverable compact signature.
+func RecoverableSignatureFromBytes(b []byte) (RecoverableSignature, error) {
+	if len(b) != RecoverableSignatureSize {
+		return RecoverableSignature{}, fmt.Errorf(
+			"Wrong recoverable signature length : got %d, want %d", len(b),
+			RecoverableSignatureSize)
+	}
+
+	header := int(b[0]) - recoverableHeader
+	if header < 0 || header > 7 {
+		return RecoverableSignature{}, fmt.Errorf("Invalid recoverable signature header : %d",
+			b[0])
+	}
+
+	signature, err := SignatureFromCompactBytes(b[1:])
+	if err != nil {
+		return RecoverableSignature{}, err
+	}
+
+	return RecoverableSignature{
+		Signature:  signature,
+		RecoveryID: uint8(header & 3),
+		Compressed: header&4 != 0,
+	}, nil
+}
+
+// RecoverableSignatureFromCompact decodes base64 recoverable compact signature text, as used by
+//   Bitcoin Signed Messages.
+func RecoverableSignatureFromCompact(s string) (RecoverableSignature, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return RecoverableSignature{}, errors.Wrap(err, "base64 decode")
+	}
+
+	return RecoverableSignatureFromBytes(b)
+}
+
+// Bytes returns the 65 byte recoverable compact encoding of the signature.
+func (s RecoverableSignature) Bytes() []byte {
+	header := byte(recoverableHeader) + s.RecoveryID
+	if s.Compressed {
+		header += 4
+	}
+
+	return append([]byte{header}, s.Signature.CompactBytes()...)
+}
+
+// ToCompact returns the recoverable signature as base64 text, as used by Bitcoin Signed Messages.
+func (s RecoverableSignature) ToCompact() string {
+	return base64.StdEncoding.EncodeToString(s.Bytes())
+}
+
+// RecoverPublicKey returns the public key that created the signature of the hash.
+func (s RecoverableSignature) RecoverPublicKey(hash Hash32) (PublicKey, error) {
+	if s.RecoveryID > 3 {
+		return PublicKey{}, fmt.Errorf("Invalid recovery id : %d", s.RecoveryID)
+	}
+
+	recovered, _, err := btcec.RecoverCompact(curveS256, s.Bytes(), hash[:])
+	if err != nil {
+		return PublicKey{}, errors.Wrap(ErrRecoverPublicKey, err.Error())
+	}
+
+	var result PublicKey
+	result.X.Set(recovered.X)
+	result.Y.Set(recovered.Y)
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestSignatureFormats(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		hash := Hash32(sha256.Sum256([]byte{byte(i)}))
+
+		recoverable, err := key.SignRecoverable(hash)
+		if err != nil {
+			t.Fatalf("Failed to sign : %s", err)
+		}
+
+		if !recoverable.Signature.Verify(hash, key.PublicKey()) {
+			t.Fatalf("Signature not valid")
+		}
+
+		// DER <-> compact
+		der := recoverable.Signature.Bytes()
+		compact, err := DERToCompact(der)
+		if err != nil {
+			t.Fatalf("Failed to convert DER to compact : %s", err)
+		}
+		if len(compact) != CompactSignatureSize {
+			t.Fatalf("Wrong compact size : got %d, want %d", len(compact), CompactSignatureSize)
+		}
+
+		reDER, err := CompactToDER(compact)
+		if err != nil {
+			t.Fatalf("Failed to convert compact to DER : %s", err)
+		}
+		if !bytes.Equal(reDER, der) {
+			t.Fatalf("Wrong DER : \ngot  %x\nwant %x", reDER, der)
+		}
+
+		// Recoverable encoding
+		decoded, err := RecoverableSignatureFromCompact(recoverable.ToCompact())
+		if err != nil {
+			t.Fatalf("Failed to decode recoverable signature : %s", err)
+		}
+		if decoded.RecoveryID != recoverable.RecoveryID || !decoded.Compressed ||
+			!decoded.Signature.Equal(recoverable.Signature) {
+			t.Fatalf("Wrong decoded signature : got %+v, want %+v", decoded, recoverable)
+		}
+
+		publicKey, err := decoded.RecoverPublicKey(hash)
+		if err != nil {
+			t.Fatalf("Failed to recover public key : %s", err)
+		}
+		if !publicKey.Equal(key.PublicKey()) {
+			t.Fatalf("Wrong recovered public key : got %s, want %s", publicKey, key.PublicKey())
+		}
+
+		// Wrong hash recovers a different key.
+		otherHash := Hash32(sha256.Sum256([]byte{byte(i), 1}))
+		if other, err := decoded.RecoverPublicKey(otherHash); err == nil &&
+			other.Equal(key.PublicKey()) {
+			t.Fatalf("Key should not be recovered from wrong hash")
+		}
+	}
+}
+
+func TestRecoverableSignatureEncoding(t *testing.T) {
+	sigCompact := "IChdjWiBBd85xYoJegm4C0Gg/7HIH+XFsfz1xXIPtX+fDXyuF2lykeAcKmsKtJuPnCMbcCgX2olXRsGHjRZtsoM="
+
+	sig, err := RecoverableSignatureFromCompact(sigCompact)
+	if err != nil {
+		t.Fatalf("Failed to decode recoverable signature : %s", err)
+	}
+
+	if sig.RecoveryID != 1 || !sig.Compressed {
+		t.Fatalf("Wrong header : recovery id %d, compressed %t", sig.RecoveryID, sig.Compressed)
+	}
+
+	if reencode := sig.ToCompact(); reencode != sigCompact {
+		t.Fatalf("Wrong encoding : \ngot  %s\nwant %s", reencode, sigCompact)
+	}
+
+	if _, err := RecoverableSignatureFromBytes(make([]byte, 64)); err == nil {
+		t.Fatalf("Short signature should fail")
+	}
+
+	invalid := sig.Bytes()
+	invalid[0] = 26
+	if _, err := RecoverableSignatureFromBytes(invalid); err == nil {
+		t.Fatalf("Invalid header should fail")
+	}
+}
+
+func TestSignMessageRecover(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	message := "test message"
+	signature, err := key.SignMessage(message)
+	if err != nil {
+		t.Fatalf("Failed to sign message : %s", err)
+	}
+
+	if !key.PublicKey().VerifyMessage(message, signature) {
+		t.Fatalf("Message signature not valid")
+	}
+
+	publicKey, err := RecoverMessagePublicKey(message, signature)
+	if err != nil {
+		t.Fatalf("Failed to recover public key : %s", err)
+	}
+	if !publicKey.Equal(key.PublicKey()) {
+		t.Fatalf("Wrong recovered public key : got %s, want %s", publicKey, key.PublicKey())
+	}
+}
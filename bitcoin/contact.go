@@ -0,0 +1,158 @@
+package bitcoin
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotContactKey means a public key is not one of a contact's known keys.
+	ErrNotContactKey = errors.New("Not Contact Key")
+
+	// ErrMissingBaseKey means a contact doesn't have a base key to derive keys from.
+	ErrMissingBaseKey = errors.New("Missing Base Key")
+
+	// ErrBadOwnershipSignature means a signature proving ownership of a contact's key is not valid.
+	ErrBadOwnershipSignature = errors.New("Bad Ownership Signature")
+)
+
+// ContactKey is a public key known to belong to a contact.
+type ContactKey struct {
+	PublicKey PublicKey `json:"public_key"`
+
+	// Optional path used to derive the key from the contact's base key.
+	DerivationPath string `json:"derivation_path,omitempty"`
+
+	// Optional description of the key's use, like "identity".
+	Label string `json:"label,omitempty"`
+}
+
+// Contact is an entry in an address book. It links a display name and paymail handle to the
+//   public keys known to belong to the contact.
+type Contact struct {
+	Name string `json:"name"`
+
+	// Optional paymail handle in alias@domain form.
+	Handle string `json:"handle,omitempty"`
+
+	// Optional extended public key that the contact's keys are derived from.
+	BaseKey *ExtendedKey `json:"base_key,omitempty"`
+
+	PublicKeys []*ContactKey `json:"public_keys,omitempty"`
+}
+
+// NewContact creates a contact with no keys.
+func NewContact(name, handle string) *Contact {
+	return &Contact{
+		Name:   name,
+		Handle: handle,
+	}
+}
+
+// SetBaseKey sets the extended key that the contact's keys are derived from. Only the public part
+//   of the key is kept.
+func (c *Contact) SetBaseKey(key ExtendedKey) {
+	publicKey := key.ExtendedPublicKey()
+	c.BaseKey = &publicKey
+}
+
+// AddPublicKey adds a known public key to the contact. It returns false if the key was already
+//   known.
+func (c *Contact) AddPublicKey(publicKey PublicKey, label string) bool {
+	if c.Key(publicKey) != nil {
+		return false
+	}
+
+	c.PublicKeys = append(c.PublicKeys, &ContactKey{
+		PublicKey: publicKey,
+		Label:     label,
+	})
+	return true
+}
+
+// AddDerivedKey derives a public key from the contact's base key with the path, like "m/0/1",
+//   and adds it to the contact. Only non-hardened paths can be derived from an extended public
+//   key.
+func (c *Contact) AddDerivedKey(path, label string) (PublicKey, error) {
+	if c.BaseKey == nil || c.BaseKey.IsEmpty() {
+		return PublicKey{}, ErrMissingBaseKey
+	}
+
+	values, err := PathFromString(path)
+	if err != nil {
+		return PublicKey{}, errors.Wrap(err, "path")
+	}
+
+	child, err := c.BaseKey.ChildKeyForPath(values)
+	if err != nil {
+		return PublicKey{}, errors.Wrap(err, "derive")
+	}
+
+	publicKey := child.PublicKey()
+	if key := c.Key(publicKey); key != nil {
+		return publicKey, nil
+	}
+
+	c.PublicKeys = append(c.PublicKeys, &ContactKey{
+		PublicKey:      publicKey,
+		DerivationPath: PathToString(values),
+		Label:          label,
+	})
+	return publicKey, nil
+}
+
+// RemovePublicKey removes a known public key. It returns false if the key wasn't known.
+func (c *Contact) RemovePublicKey(publicKey PublicKey) bool {
+	for i, key := range c.PublicKeys {
+		if key.PublicKey.Equal(publicKey) {
+			c.PublicKeys = append(c.PublicKeys[:i], c.PublicKeys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Key returns the contact's key matching the public key, or nil if it isn't known.
+func (c Contact) Key(publicKey PublicKey) *ContactKey {
+	for _, key := range c.PublicKeys {
+		if key.PublicKey.Equal(publicKey) {
+			return key
+		}
+	}
+	return nil
+}
+
+// HasPublicKey returns true if the public key is known to belong to the contact. The public key of
+//   the base key also belongs to the contact.
+func (c Contact) HasPublicKey(publicKey PublicKey) bool {
+	if c.Key(publicKey) != nil {
+		return true
+	}
+
+	return c.BaseKey != nil && !c.BaseKey.IsEmpty() && c.BaseKey.PublicKey().Equal(publicKey)
+}
+
+// OwnershipMessage returns the message that is signed to prove ownership of a key for the
+//   contact. It includes the handle so that a signature can't be reused for a different contact.
+func (c Contact) OwnershipMessage(challenge string) string {
+	return c.Handle + "\n" + challenge
+}
+
+// SignOwnership signs the challenge with the key to prove that the key belongs to the contact. It
+//   returns the signature in compact form.
+func (c Contact) SignOwnership(key Key, challenge string) (string, error) {
+	return key.SignMessage(c.OwnershipMessage(challenge))
+}
+
+// VerifyOwnership checks that the signature of the challenge was made by the public key and that
+//   the public key belongs to the contact.
+func (c Contact) VerifyOwnership(publicKey PublicKey, challenge, signature string) error {
+	if !c.HasPublicKey(publicKey) {
+		return ErrNotContactKey
+	}
+
+	if !publicKey.VerifyMessage(c.OwnershipMessage(challenge), signature) {
+		return ErrBadOwnershipSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestContactJSON(t *testing.T) {
+	baseKey, err := GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to generate base key : %s", err)
+	}
+
+	identityKey, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	contact := NewContact("Alice", "alice@example.com")
+	contact.SetBaseKey(baseKey)
+	contact.AddPublicKey(identityKey.PublicKey(), "identity")
+
+	derived, err := contact.AddDerivedKey("m/0/1", "payment")
+	if err != nil {
+		t.Fatalf("Failed to add derived key : %s", err)
+	}
+
+	js, err := json.Marshal(contact)
+	if err != nil {
+		t.Fatalf("Failed to marshal contact : %s", err)
+	}
+
+	read := &Contact{}
+	if err := json.Unmarshal(js, read); err != nil {
+		t.Fatalf("Failed to unmarshal contact : %s", err)
+	}
+
+	if read.Name != contact.Name || read.Handle != contact.Handle {
+		t.Fatalf("Wrong name or handle : got %s %s, want %s %s", read.Name, read.Handle,
+			contact.Name, contact.Handle)
+	}
+
+	if read.BaseKey == nil || !read.BaseKey.Equal(*contact.BaseKey) {
+		t.Fatalf("Wrong base key")
+	}
+
+	if read.BaseKey.IsPrivate() {
+		t.Fatalf("Base key should not be private")
+	}
+
+	if len(read.PublicKeys) != 2 {
+		t.Fatalf("Wrong key count : got %d, want %d", len(read.PublicKeys), 2)
+	}
+
+	key := read.Key(derived)
+	if key == nil {
+		t.Fatalf("Missing derived key")
+	}
+
+	if key.DerivationPath != "m/0/1" || key.Label != "payment" {
+		t.Fatalf("Wrong derived key info : %s %s", key.DerivationPath, key.Label)
+	}
+
+	if !read.HasPublicKey(identityKey.PublicKey()) {
+		t.Fatalf("Missing identity key")
+	}
+}
+
+func TestContactOwnership(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	otherKey, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	contact := NewContact("Bob", "bob@example.com")
+	contact.AddPublicKey(key.PublicKey(), "identity")
+
+	challenge := "4b6f1c2e"
+	signature, err := contact.SignOwnership(key, challenge)
+	if err != nil {
+		t.Fatalf("Failed to sign ownership : %s", err)
+	}
+
+	if err := contact.VerifyOwnership(key.PublicKey(), challenge, signature); err != nil {
+		t.Fatalf("Failed to verify ownership : %s", err)
+	}
+
+	if err := contact.VerifyOwnership(key.PublicKey(), "other", signature); errors.Cause(err) !=
+		ErrBadOwnershipSignature {
+		t.Fatalf("Wrong error for wrong challenge : %v", err)
+	}
+
+	if err := contact.VerifyOwnership(otherKey.PublicKey(), challenge,
+		signature); errors.Cause(err) != ErrNotContactKey {
+		t.Fatalf("Wrong error for unknown key : %v", err)
+	}
+
+	other := NewContact("Bob", "bob@other.com")
+	other.AddPublicKey(key.PublicKey(), "identity")
+	if err := other.VerifyOwnership(key.PublicKey(), challenge, signature); errors.Cause(err) !=
+		ErrBadOwnershipSignature {
+		t.Fatalf("Wrong error for different handle : %v", err)
+	}
+
+	if !contact.RemovePublicKey(key.PublicKey()) {
+		t.Fatalf("Failed to remove key")
+	}
+
+	if contact.HasPublicKey(key.PublicKey()) {
+		t.Fatalf("Key not removed")
+	}
+}
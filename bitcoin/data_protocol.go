@@ -0,0 +1,199 @@
+package bitcoin
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// BitcomPipe is the push that separates the protocols in a Bitcom data script, like
+	//   "B | MAP | AIP".
+	BitcomPipe = "|"
+)
+
+var (
+	ErrDataProtocolRegistered = errors.New("Data protocol already registered")
+
+	// ErrNotDataScript means a locking script doesn't start with OP_RETURN or OP_FALSE OP_RETURN.
+	ErrNotDataScript = errors.New("Not Data Script")
+
+	dataProtocolsLock sync.RWMutex
+	dataProtocols     = make(map[string]*DataProtocol) // by prefix
+	dataProtocolNames = make(map[string]*DataProtocol)
+	dataProtocolOrder []*DataProtocol
+)
+
+// DataProtocol is a protocol that is identified by the first push of an OP_RETURN data script.
+type DataProtocol struct {
+	Name   string // Name used by indexers, like "map".
+	Prefix []byte // First push after OP_RETURN.
+}
+
+// DataScript is the parsed content of an OP_RETURN data script.
+type DataScript struct {
+	// Protocol is the protocol identified by the first push, or nil if it isn't registered.
+	Protocol *DataProtocol
+
+	// Pushes are the push datas after OP_RETURN, including the protocol prefix. OP_0 and small
+	//   number op codes are converted to the values they push.
+	Pushes [][]byte
+}
+
+func init() {
+	builtIn := []DataProtocol{
+		{"tokenized_envelope", []byte{0xbd}}, // Envelope v1 used by Tokenized and others.
+		{"tokenized", []byte("tokenized")},
+		{"tokenized_test", []byte("test.tokenized")},
+		{"b", []byte("19HxigV4QyBv3tHpQVcUEQyq1pzZVdoAut")},
+		{"bcat", []byte("15DHFxWZJT58f9nhyGnsRBqrgwK4W6h4Up")},
+		{"bcat_part", []byte("1ChDHzdd1H4wSjgGMHyndZm6qxEDGjqpJL")},
+		{"d", []byte("19iG3WTYSsbyos3uJ733yK4zEioi1FesNU")},
+		{"map", []byte("1PuQa7K62MiKCtssSLKy1kh56WWU7MtUR5")},
+		{"aip", []byte("15PciHG22SNLQJXMoSUaWVi7WSqc7hCfva")},
+		{"metanet", []byte("meta")},
+		{"run", []byte("run")},
+	}
+	for _, protocol := range builtIn {
+		if err := RegisterDataProtocol(protocol); err != nil {
+			fmt.Printf("WARNING failed to register data protocol %s : %s", protocol.Name, err)
+		}
+	}
+}
+
+// RegisterDataProtocol adds a protocol so that it is identified by ParseDataScript. Names and
+//   prefixes can only be registered once.
+func RegisterDataProtocol(protocol DataProtocol) error {
+	if len(protocol.Name) == 0 {
+		return errors.New("Missing data protocol name")
+	}
+	if len(protocol.Prefix) == 0 {
+		return errors.New("Missing data protocol prefix")
+	}
+
+	dataProtocolsLock.Lock()
+	defer dataProtocolsLock.Unlock()
+
+	if _, exists := dataProtocolNames[protocol.Name]; exists {
+		return errors.Wrapf(ErrDataProtocolRegistered, "name %s", protocol.Name)
+	}
+	if other, exists := dataProtocols[string(protocol.Prefix)]; exists {
+		return errors.Wrapf(ErrDataProtocolRegistered, "prefix used by %s", other.Name)
+	}
+
+	p := protocol
+	p.Prefix = append([]byte(nil), protocol.Prefix...)
+	dataProtocols[string(p.Prefix)] = &p
+	dataProtocolNames[p.Name] = &p
+	dataProtocolOrder = append(dataProtocolOrder, &p)
+	return nil
+}
+
+// LookupDataProtocol returns the registered protocol with the prefix.
+func LookupDataProtocol(prefix []byte) (*DataProtocol, bool) {
+	dataProtocolsLock.RLock()
+	defer dataProtocolsLock.RUnlock()
+
+	protocol, exists := dataProtocols[string(prefix)]
+	return protocol, exists
+}
+
+// LookupDataProtocolByName returns the registered protocol with the name.
+func LookupDataProtocolByName(name string) (*DataProtocol, bool) {
+	dataProtocolsLock.RLock()
+	defer dataProtocolsLock.RUnlock()
+
+	protocol, exists := dataProtocolNames[name]
+	return protocol, exists
+}
+
+// DataProtocols returns all registered protocols in the order they were registered.
+func DataProtocols() []DataProtocol {
+	dataProtocolsLock.RLock()
+	defer dataProtocolsLock.RUnlock()
+
+	result := make([]DataProtocol, len(dataProtocolOrder))
+	for i, protocol := range dataProtocolOrder {
+		result[i] = *protocol
+	}
+	return result
+}
+
+// ParseDataScript parses an OP_RETURN or OP_FALSE OP_RETURN locking script, identifies its
+//   protocol by the first push, and returns the pushes. It returns ErrNotDataScript if the script
+//   isn't a data script and ErrInvalidScript if it contains op codes that aren't pushes.
+func ParseDataScript(script []byte) (*DataScript, error) {
+	buf := bytes.NewReader(script)
+
+	opCode, err := buf.ReadByte()
+	if err != nil {
+		return nil, ErrNotDataScript
+	}
+	if opCode == OP_FALSE {
+		if opCode, err = buf.ReadByte(); err != nil {
+			return nil, ErrNotDataScript
+		}
+	}
+	if opCode != OP_RETURN {
+		return nil, ErrNotDataScript
+	}
+
+	result := &DataScript{}
+	for buf.Len() > 0 {
+		item, err := ParseScript(buf)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidScript, "push %d: %s", len(result.Pushes), err)
+		}
+
+		switch {
+		case item.Type == ScriptItemTypePushData:
+			result.Pushes = append(result.Pushes, item.Data)
+		case item.OpCode == OP_FALSE:
+			result.Pushes = append(result.Pushes, nil)
+		case item.OpCode >= OP_1 && item.OpCode <= OP_16:
+			result.Pushes = append(result.Pushes, []byte{item.OpCode - OP_1 + 1})
+		case item.OpCode == OP_1NEGATE:
+			result.Pushes = append(result.Pushes, []byte{0x81})
+		default:
+			return nil, errors.Wrapf(ErrInvalidScript, "push %d: op code %s",
+				len(result.Pushes), item)
+		}
+	}
+
+	if len(result.Pushes) > 0 {
+		result.Protocol, _ = LookupDataProtocol(result.Pushes[0])
+	}
+
+	return result, nil
+}
+
+// Sections splits the pushes of a Bitcom data script at the pipe pushes, so each protocol in a
+//   script like "B | MAP | AIP" can be identified. Scripts without pipes return one section.
+func (d DataScript) Sections() []DataScript {
+	var result []DataScript
+	start := 0
+	for i := 0; i <= len(d.Pushes); i++ {
+		if i < len(d.Pushes) && !bytes.Equal(d.Pushes[i], []byte(BitcomPipe)) {
+			continue
+		}
+
+		section := DataScript{Pushes: d.Pushes[start:i]}
+		if len(section.Pushes) > 0 {
+			section.Protocol, _ = LookupDataProtocol(section.Pushes[0])
+		}
+		result = append(result, section)
+		start = i + 1
+	}
+
+	return result
+}
+
+// ProtocolName returns the name of the protocol, or an empty string if it isn't registered.
+func (d DataScript) ProtocolName() string {
+	if d.Protocol == nil {
+		return ""
+	}
+	return d.Protocol.Name
+}
@@ -0,0 +1,112 @@
+package bitcoin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseDataScript(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		protocols []string
+		pushes    int
+	}{
+		{
+			name:      "run",
+			script:    "OP_FALSE OP_RETURN 0x72756e 0x05 0x0102",
+			protocols: []string{"run"},
+			pushes:    3,
+		},
+		{
+			name: "bitcom",
+			script: "OP_RETURN 0x31394878696756345179427633744870515663554551797131707a5a56646f417574" +
+				" 0x68656c6c6f 0x7c" +
+				" 0x3150755161374b36324d694b43747373534c4b79316b683536575755374d74555235" +
+				" 0x534554 0x617070",
+			protocols: []string{"b", "map"},
+			pushes:    6,
+		},
+		{
+			name:      "envelope",
+			script:    "OP_FALSE OP_RETURN 0xbd OP_0 0x544b4e 0x0102",
+			protocols: []string{"tokenized_envelope"},
+			pushes:    4,
+		},
+		{
+			name:      "unknown",
+			script:    "OP_FALSE OP_RETURN 0x01020304",
+			protocols: []string{""},
+			pushes:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := StringToScript(tt.script)
+			if err != nil {
+				t.Fatalf("Failed to create script : %s", err)
+			}
+
+			data, err := ParseDataScript(script)
+			if err != nil {
+				t.Fatalf("Failed to parse data script : %s", err)
+			}
+
+			if len(data.Pushes) != tt.pushes {
+				t.Fatalf("Wrong push count : got %d, want %d", len(data.Pushes), tt.pushes)
+			}
+
+			if data.ProtocolName() != tt.protocols[0] {
+				t.Fatalf("Wrong protocol : got %s, want %s", data.ProtocolName(), tt.protocols[0])
+			}
+
+			sections := data.Sections()
+			if len(sections) != len(tt.protocols) {
+				t.Fatalf("Wrong section count : got %d, want %d", len(sections),
+					len(tt.protocols))
+			}
+			for i, section := range sections {
+				if section.ProtocolName() != tt.protocols[i] {
+					t.Errorf("Wrong section %d protocol : got %s, want %s", i,
+						section.ProtocolName(), tt.protocols[i])
+				}
+			}
+		})
+	}
+
+	p2pkh, err := StringToScript("OP_DUP OP_HASH160 0x0102030405060708090a0b0c0d0e0f1011121314" +
+		" OP_EQUALVERIFY OP_CHECKSIG")
+	if err != nil {
+		t.Fatalf("Failed to create script : %s", err)
+	}
+	if _, err := ParseDataScript(p2pkh); errors.Cause(err) != ErrNotDataScript {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrNotDataScript)
+	}
+}
+
+func TestRegisterDataProtocol(t *testing.T) {
+	custom := DataProtocol{Name: "custom_test", Prefix: []byte("custom.test")}
+	if err := RegisterDataProtocol(custom); err != nil {
+		t.Fatalf("Failed to register data protocol : %s", err)
+	}
+
+	if err := RegisterDataProtocol(custom); errors.Cause(err) != ErrDataProtocolRegistered {
+		t.Fatalf("Wrong error registering twice : got %v, want %s", err,
+			ErrDataProtocolRegistered)
+	}
+
+	duplicatePrefix := DataProtocol{Name: "other", Prefix: []byte("run")}
+	if err := RegisterDataProtocol(duplicatePrefix); errors.Cause(err) !=
+		ErrDataProtocolRegistered {
+		t.Fatalf("Wrong error registering used prefix : got %v, want %s", err,
+			ErrDataProtocolRegistered)
+	}
+
+	protocol, exists := LookupDataProtocolByName("custom_test")
+	if !exists || !bytes.Equal(protocol.Prefix, custom.Prefix) {
+		t.Fatalf("Registered data protocol not found")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha512"
+	"database/sql/driver"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -455,6 +456,14 @@ func (k ExtendedKey) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON converts from json.
 func (k *ExtendedKey) UnmarshalJSON(data []byte) error {
+	if isJSONEmpty(data) {
+		*k = ExtendedKey{}
+		return nil
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("Too short for ExtendedKey data : %d", len(data))
+	}
+
 	return k.SetString58(string(data[1 : len(data)-1]))
 }
 
@@ -483,8 +492,28 @@ func (k *ExtendedKey) UnmarshalBinary(data []byte) error {
 	return k.SetBytes(data)
 }
 
+// IsEmpty returns true if the key value is not set.
+func (k ExtendedKey) IsEmpty() bool {
+	var zero [33]byte
+	return bytes.Equal(k.KeyValue[:], zero[:])
+}
+
+// Value returns the value to store in a database column.
+// Implements driver.Valuer interface.
+func (k ExtendedKey) Value() (driver.Value, error) {
+	if k.IsEmpty() {
+		return nil, nil
+	}
+	return k.Bytes(), nil
+}
+
 // Scan converts from a database column.
 func (k *ExtendedKey) Scan(data interface{}) error {
+	if data == nil {
+		*k = ExtendedKey{}
+		return nil
+	}
+
 	b, ok := data.([]byte)
 	if !ok {
 		return errors.New("ExtendedKey db column not bytes")
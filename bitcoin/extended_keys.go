@@ -2,6 +2,7 @@ package bitcoin
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/hex"
 	"fmt"
 
@@ -271,6 +272,14 @@ func (k ExtendedKeys) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON converts from json.
 func (k *ExtendedKeys) UnmarshalJSON(data []byte) error {
+	if isJSONEmpty(data) {
+		*k = nil
+		return nil
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("Too short for ExtendedKeys data : %d", len(data))
+	}
+
 	return k.SetString58(string(data[1 : len(data)-1]))
 }
 
@@ -307,8 +316,22 @@ func (k *ExtendedKeys) UnmarshalBinary(data []byte) error {
 	return k.SetBytes(data)
 }
 
+// Value returns the value to store in a database column.
+// Implements driver.Valuer interface.
+func (k ExtendedKeys) Value() (driver.Value, error) {
+	if len(k) == 0 {
+		return nil, nil
+	}
+	return k.Bytes(), nil
+}
+
 // Scan converts from a database column.
 func (k *ExtendedKeys) Scan(data interface{}) error {
+	if data == nil {
+		*k = nil
+		return nil
+	}
+
 	b, ok := data.([]byte)
 	if !ok {
 		return errors.New("ExtendedKeys db column not bytes")
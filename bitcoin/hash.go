@@ -2,6 +2,7 @@ package bitcoin
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
 
 	"golang.org/x/crypto/ripemd160"
 )
@@ -38,3 +39,64 @@ func Hash160(b []byte) []byte {
 func DoubleSha256(b []byte) []byte {
 	return Sha256(Sha256(b))
 }
+
+const hexChars = "0123456789abcdef"
+
+// decodeReverseHex decodes big endian hex text into h in little endian order without allocating.
+// s must be twice the length of h.
+func decodeReverseHex(h []byte, s string) error {
+	last := len(h) - 1
+	for i := range h {
+		high, ok := hexValue(s[i*2])
+		if !ok {
+			return hex.InvalidByteError(s[i*2])
+		}
+
+		low, ok := hexValue(s[i*2+1])
+		if !ok {
+			return hex.InvalidByteError(s[i*2+1])
+		}
+
+		h[last-i] = high<<4 | low
+	}
+
+	return nil
+}
+
+// encodeReverseHex encodes little endian h into big endian hex text. s must be twice the length
+// of h.
+func encodeReverseHex(s, h []byte) {
+	last := len(h) - 1
+	for i := range h {
+		b := h[last-i]
+		s[i*2] = hexChars[b>>4]
+		s[i*2+1] = hexChars[b&0x0f]
+	}
+}
+
+// compareReverse compares little endian numbers of the same length.
+func compareReverse(l, r []byte) int {
+	for i := len(l) - 1; i >= 0; i-- {
+		if l[i] < r[i] {
+			return -1
+		}
+		if l[i] > r[i] {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+
+	return 0, false
+}
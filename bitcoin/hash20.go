@@ -1,7 +1,7 @@
 package bitcoin
 
 import (
-	"bytes"
+	"database/sql/driver"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -30,19 +30,28 @@ func NewHash20(b []byte) (*Hash20, error) {
 
 // NewHash20FromStr creates a little endian hash from a big endian string.
 func NewHash20FromStr(s string) (*Hash20, error) {
+	result := &Hash20{}
+	if err := result.SetString(s); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetString sets the value of the hash from a big endian hex string, as returned by String. It
+//   doesn't allocate, so it can be used to parse many hashes into existing values. The hash is not
+//   modified when an error is returned.
+func (h *Hash20) SetString(s string) error {
 	if len(s) != 2*Hash20Size {
-		return nil, errors.Wrapf(ErrWrongSize, "hex: got %d, want %d", len(s), Hash20Size*2)
+		return errors.Wrapf(ErrWrongSize, "hex: got %d, want %d", len(s), Hash20Size*2)
 	}
 
-	b := make([]byte, Hash20Size)
-	_, err := hex.Decode(b, []byte(s[:]))
-	if err != nil {
-		return nil, err
+	var result Hash20
+	if err := decodeReverseHex(result[:], s); err != nil {
+		return err
 	}
 
-	result := Hash20{}
-	reverse20(result[:], b)
-	return &result, nil
+	*h = result
+	return nil
 }
 
 // NewHash20FromData creates a Hash20 by hashing the data with a Ripemd160(Sha256(b))
@@ -62,6 +71,9 @@ func (h Hash20) ReverseBytes() []byte {
 	return b
 }
 
+// Value returns the hash as a big integer.
+// Hash20 doesn't implement driver.Valuer because of this function, so use SQLValue when writing it
+//   to a database column.
 func (h Hash20) Value() *big.Int {
 	value := &big.Int{}
 	value.SetBytes(h.ReverseBytes())
@@ -79,9 +91,9 @@ func (h *Hash20) SetBytes(b []byte) error {
 
 // String returns the hex for the hash.
 func (h Hash20) String() string {
-	var r [Hash20Size]byte
-	reverse20(r[:], h[:])
-	return fmt.Sprintf("%x", r[:])
+	var r [Hash20Size * 2]byte
+	encodeReverseHex(r[:], h[:])
+	return string(r[:])
 }
 
 // Equal returns true if the parameter has the same value.
@@ -92,7 +104,14 @@ func (h *Hash20) Equal(o *Hash20) bool {
 	if o == nil {
 		return false
 	}
-	return bytes.Equal(h[:], o[:])
+	return *h == *o
+}
+
+// Compare returns -1, 0, or 1 if the value of the hash is less than, equal to, or greater than
+//   the value of o. Hashes are compared as little endian numbers so the order matches the order of
+//   their strings.
+func (h *Hash20) Compare(o *Hash20) int {
+	return compareReverse(h[:], o[:])
 }
 
 func (h Hash20) IsZero() bool {
@@ -133,6 +152,11 @@ func (h Hash20) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON converts from json.
 func (h *Hash20) UnmarshalJSON(data []byte) error {
+	if isJSONEmpty(data) {
+		*h = Hash20{}
+		return nil
+	}
+
 	b, err := ConvertJSONHexToBytes(data)
 	if err != nil {
 		return errors.Wrap(err, "hex")
@@ -186,6 +210,11 @@ func (h *Hash20) UnmarshalBinary(data []byte) error {
 
 // Scan converts from a database column.
 func (h *Hash20) Scan(data interface{}) error {
+	if data == nil {
+		*h = Hash20{}
+		return nil
+	}
+
 	b, ok := data.([]byte)
 	if !ok {
 		return errors.New("Hash20 db column not bytes")
@@ -194,6 +223,26 @@ func (h *Hash20) Scan(data interface{}) error {
 	return h.SetBytes(b)
 }
 
+// Hash20Value is a Hash20 that implements driver.Valuer. Hash20 can't because its Value function
+//   returns a big integer.
+type Hash20Value Hash20
+
+// SQLValue returns the hash as a type that can be written to a database column.
+func (h Hash20) SQLValue() Hash20Value {
+	return Hash20Value(h)
+}
+
+// Value returns the value to store in a database column.
+// Implements driver.Valuer interface.
+func (h Hash20Value) Value() (driver.Value, error) {
+	return Hash20(h).Bytes(), nil
+}
+
+// Scan converts from a database column.
+func (h *Hash20Value) Scan(data interface{}) error {
+	return (*Hash20)(h).Scan(data)
+}
+
 func reverse20(h, rh []byte) {
 	i := Hash20Size - 1
 	for _, b := range rh[:] {
@@ -201,3 +250,18 @@ func reverse20(h, rh []byte) {
 		i--
 	}
 }
+
+// SortHash20s sorts hashes in the order of Compare with sort.Sort.
+type SortHash20s []Hash20
+
+func (s SortHash20s) Len() int {
+	return len(s)
+}
+
+func (s SortHash20s) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s SortHash20s) Less(i, j int) bool {
+	return s[i].Compare(&s[j]) < 0
+}
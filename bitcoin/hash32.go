@@ -1,7 +1,7 @@
 package bitcoin
 
 import (
-	"bytes"
+	"database/sql/driver"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -26,19 +26,28 @@ func NewHash32(b []byte) (*Hash32, error) {
 
 // NewHash32FromStr creates a little endian hash from a big endian string.
 func NewHash32FromStr(s string) (*Hash32, error) {
+	result := &Hash32{}
+	if err := result.SetString(s); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetString sets the value of the hash from a big endian hex string, as returned by String. It
+//   doesn't allocate, so it can be used to parse many hashes into existing values. The hash is not
+//   modified when an error is returned.
+func (h *Hash32) SetString(s string) error {
 	if len(s) != 2*Hash32Size {
-		return nil, errors.Wrapf(ErrWrongSize, "hex: got %d, want %d", len(s), Hash32Size*2)
+		return errors.Wrapf(ErrWrongSize, "hex: got %d, want %d", len(s), Hash32Size*2)
 	}
 
-	b := make([]byte, Hash32Size)
-	_, err := hex.Decode(b, []byte(s[:]))
-	if err != nil {
-		return nil, err
+	var result Hash32
+	if err := decodeReverseHex(result[:], s); err != nil {
+		return err
 	}
 
-	result := Hash32{}
-	reverse32(result[:], b)
-	return &result, nil
+	*h = result
+	return nil
 }
 
 // Sha256 sets the value of this hash to the SHA256 of itself.
@@ -65,6 +74,9 @@ func (h Hash32) ReverseBytes() []byte {
 	return b
 }
 
+// Value returns the hash as a big integer.
+// Hash32 doesn't implement driver.Valuer because of this function, so use SQLValue when writing it
+//   to a database column.
 func (h Hash32) Value() *big.Int {
 	value := &big.Int{}
 	value.SetBytes(h.ReverseBytes())
@@ -82,9 +94,9 @@ func (h *Hash32) SetBytes(b []byte) error {
 
 // String returns the hex for the hash.
 func (h Hash32) String() string {
-	var r [Hash32Size]byte
-	reverse32(r[:], h[:])
-	return fmt.Sprintf("%x", r[:])
+	var r [Hash32Size * 2]byte
+	encodeReverseHex(r[:], h[:])
+	return string(r[:])
 }
 
 // Equal returns true if the parameter has the same value.
@@ -95,7 +107,14 @@ func (h *Hash32) Equal(o *Hash32) bool {
 	if o == nil {
 		return false
 	}
-	return bytes.Equal(h[:], o[:])
+	return *h == *o
+}
+
+// Compare returns -1, 0, or 1 if the value of the hash is less than, equal to, or greater than
+//   the value of o. Hashes are compared as little endian numbers so the order matches the order of
+//   their strings.
+func (h *Hash32) Compare(o *Hash32) int {
+	return compareReverse(h[:], o[:])
 }
 
 func (h Hash32) IsZero() bool {
@@ -136,6 +155,11 @@ func (h Hash32) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON converts from json.
 func (h *Hash32) UnmarshalJSON(data []byte) error {
+	if isJSONEmpty(data) {
+		*h = Hash32{}
+		return nil
+	}
+
 	b, err := ConvertJSONHexToBytes(data)
 	if err != nil {
 		return errors.Wrap(err, "hex")
@@ -189,6 +213,11 @@ func (h *Hash32) UnmarshalBinary(data []byte) error {
 
 // Scan converts from a database column.
 func (h *Hash32) Scan(data interface{}) error {
+	if data == nil {
+		*h = Hash32{}
+		return nil
+	}
+
 	b, ok := data.([]byte)
 	if !ok {
 		return errors.New("Hash32 db column not bytes")
@@ -197,6 +226,26 @@ func (h *Hash32) Scan(data interface{}) error {
 	return h.SetBytes(b)
 }
 
+// Hash32Value is a Hash32 that implements driver.Valuer. Hash32 can't because its Value function
+//   returns a big integer.
+type Hash32Value Hash32
+
+// SQLValue returns the hash as a type that can be written to a database column.
+func (h Hash32) SQLValue() Hash32Value {
+	return Hash32Value(h)
+}
+
+// Value returns the value to store in a database column.
+// Implements driver.Valuer interface.
+func (h Hash32Value) Value() (driver.Value, error) {
+	return Hash32(h).Bytes(), nil
+}
+
+// Scan converts from a database column.
+func (h *Hash32Value) Scan(data interface{}) error {
+	return (*Hash32)(h).Scan(data)
+}
+
 func reverse32(h, rh []byte) {
 	i := Hash32Size - 1
 	for _, b := range rh[:] {
@@ -204,3 +253,18 @@ func reverse32(h, rh []byte) {
 		i--
 	}
 }
+
+// SortHash32s sorts hashes in the order of Compare with sort.Sort.
+type SortHash32s []Hash32
+
+func (s SortHash32s) Len() int {
+	return len(s)
+}
+
+func (s SortHash32s) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s SortHash32s) Less(i, j int) bool {
+	return s[i].Compare(&s[j]) < 0
+}
@@ -0,0 +1,126 @@
+package bitcoin
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestHash32SetString(t *testing.T) {
+	text := "43b83509a310acbbcdb91164285829505ae415ad476e773f1e9ce49023387ac8"
+
+	var hash Hash32
+	if err := hash.SetString(text); err != nil {
+		t.Fatalf("Failed to set string : %s", err)
+	}
+
+	if hash.String() != text {
+		t.Fatalf("Wrong string : got %s, want %s", hash.String(), text)
+	}
+
+	upper := Hash32{}
+	upperText := "43B83509A310ACBBCDB91164285829505AE415AD476E773F1E9CE49023387AC8"
+	if err := upper.SetString(upperText); err != nil {
+		t.Fatalf("Failed to set upper case string : %s", err)
+	}
+
+	if !upper.Equal(&hash) {
+		t.Fatalf("Wrong upper case value : got %s, want %s", upper, hash)
+	}
+
+	if err := hash.SetString(text[:62]); errors.Cause(err) != ErrWrongSize {
+		t.Fatalf("Wrong error for short string : %v", err)
+	}
+
+	if err := hash.SetString(text[:63] + "g"); err == nil {
+		t.Fatalf("Invalid character should fail")
+	}
+
+	if hash.String() != text {
+		t.Fatalf("Hash modified by failed parse : %s", hash)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		hash.SetString(text)
+	})
+	if allocs != 0 {
+		t.Fatalf("SetString allocated : %f", allocs)
+	}
+}
+
+func TestHash20SetString(t *testing.T) {
+	text := "9a1c78a507689f6f54b847ad1cef1e614ee23f1e"
+
+	hash, err := NewHash20FromStr(text)
+	if err != nil {
+		t.Fatalf("Failed to parse hash : %s", err)
+	}
+
+	if hash.String() != text {
+		t.Fatalf("Wrong string : got %s, want %s", hash.String(), text)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		hash.SetString(text)
+	})
+	if allocs != 0 {
+		t.Fatalf("SetString allocated : %f", allocs)
+	}
+}
+
+func TestHashCompare(t *testing.T) {
+	hashes := make([]Hash32, 50)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+	hashes = append(hashes, hashes[0])
+
+	sort.Sort(SortHash32s(hashes))
+
+	for i := 1; i < len(hashes); i++ {
+		if hashes[i-1].String() > hashes[i].String() {
+			t.Fatalf("Hashes not in order at %d : %s > %s", i, hashes[i-1], hashes[i])
+		}
+
+		if hashes[i-1].Value().Cmp(hashes[i].Value()) != hashes[i-1].Compare(&hashes[i]) {
+			t.Fatalf("Compare doesn't match value at %d", i)
+		}
+	}
+
+	hashes20 := make([]Hash20, 50)
+	for i := range hashes20 {
+		rand.Read(hashes20[i][:])
+	}
+
+	sort.Sort(SortHash20s(hashes20))
+
+	for i := 1; i < len(hashes20); i++ {
+		if hashes20[i-1].String() > hashes20[i].String() {
+			t.Fatalf("Hashes not in order at %d : %s > %s", i, hashes20[i-1], hashes20[i])
+		}
+	}
+}
+
+func BenchmarkHash32SetString(b *testing.B) {
+	text := "43b83509a310acbbcdb91164285829505ae415ad476e773f1e9ce49023387ac8"
+	var hash Hash32
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := hash.SetString(text); err != nil {
+			b.Fatalf("Failed to set string : %s", err)
+		}
+	}
+}
+
+func BenchmarkHash32String(b *testing.B) {
+	var hash Hash32
+	rand.Read(hash[:])
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = hash.String()
+	}
+}
@@ -64,6 +64,11 @@ func ConvertBytesToJSONHex(b []byte) ([]byte, error) {
 	return result, nil
 }
 
+// isJSONEmpty returns true if the json value is null or an empty string.
+func isJSONEmpty(js []byte) bool {
+	return string(js) == "null" || string(js) == "\"\""
+}
+
 func ConvertJSONHexToBytes(js []byte) ([]byte, error) {
 	l := len(js)
 	if l < 2 {
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
-	"encoding/hex"
+	"database/sql/driver"
 	"fmt"
 	"io"
 	"math/big"
@@ -45,64 +45,19 @@ func KeyFromValue(value big.Int, net Network) Key {
 	return Key{value: value, net: net}
 }
 
-// KeyFromStr converts WIF (Wallet Import Format) key text to a key.
+// KeyFromStr converts WIF (Wallet Import Format) key text to a key. See DecodeWIF for the
+//   errors returned.
 func KeyFromStr(s string) (Key, error) {
-	b, err := decodeAddress(s)
-	if err != nil {
-		return Key{}, err
-	}
-
-	var network Network
-	switch b[0] {
-	case typeMainPrivKey:
-		network = MainNet
-	case typeTestPrivKey:
-		network = TestNet
-	default:
-		return Key{}, ErrBadKeyType
-	}
-
-	if len(b) == 34 {
-		if b[len(b)-1] != 0x01 {
-			return Key{}, fmt.Errorf("Key not for compressed public : %x", b[len(b)-1:])
-		}
-		return KeyFromNumber(b[1:33], network)
-	} else if len(b) == 33 {
-		return KeyFromNumber(b[1:], network)
-	}
-
-	return Key{}, fmt.Errorf("Key unknown format length %d", len(b))
+	return DecodeWIF(s)
 }
 
 func (k *Key) DecodeString(s string) error {
-	b, err := decodeAddress(s)
+	nk, err := DecodeWIF(s)
 	if err != nil {
 		return err
 	}
 
-	switch b[0] {
-	case typeMainPrivKey:
-		k.net = MainNet
-	case typeTestPrivKey:
-		k.net = TestNet
-	default:
-		return ErrBadKeyType
-	}
-
-	if len(b) == 34 {
-		if b[len(b)-1] != 0x01 {
-			return fmt.Errorf("Key not for compressed public : %x", b[len(b)-1:])
-		}
-		b = b[1:33]
-	} else if len(b) == 33 {
-		b = b[1:]
-	}
-
-	if err := privateKeyIsValid(b); err != nil {
-		return err
-	}
-
-	k.value.SetBytes(b)
+	*k = nk
 	return nil
 }
 
@@ -151,17 +106,8 @@ func (k Key) Equal(other Key) bool {
 
 // String returns the type followed by the key data with a checksum, encoded with Base58.
 func (k Key) String() string {
-	var keyType byte
-
 	// Add key type byte in front
-	switch k.net {
-	case MainNet:
-		keyType = typeMainPrivKey
-	default:
-		keyType = typeTestPrivKey
-	}
-
-	b := append([]byte{keyType}, k.value.Bytes()...)
+	b := append([]byte{privateKeyType(k.net)}, k.value.Bytes()...)
 	//b = append(b, 0x01) // compressed public key // Don't know if we want this or not.
 	return encodeAddress(b)
 }
@@ -177,9 +123,10 @@ func (k *Key) SetString(s string) error {
 	return k.DecodeString(s)
 }
 
-// SetBytes decodes the key from bytes.
+// SetBytes decodes the key from bytes. The binary encoding doesn't contain the network so the
+//   key's current network is retained.
 func (k *Key) SetBytes(b []byte) error {
-	nk, err := KeyFromBytes(b, typeIntPrivKey)
+	nk, err := KeyFromBytes(b, k.net)
 	if err != nil {
 		return err
 	}
@@ -245,8 +192,29 @@ func (k Key) IsEmpty() bool {
 }
 
 // Sign returns the serialized signature of the hash for the private key.
+// The nonce is generated deterministically according to RFC 6979 so the same key and hash always
+//   produce the same signature.
 func (k Key) Sign(hash Hash32) (Signature, error) {
-	return signRFC6979(k.value, hash[:])
+	return signRFC6979(k.value, hash[:], nil)
+}
+
+// SignDeterministic returns a signature of the hash using an RFC 6979 nonce that also includes
+//   the extra entropy as described in RFC 6979 section 3.6. The result is still deterministic for
+//   the same key, hash, and extra entropy. An empty extraEntropy gives the same result as Sign.
+func (k Key) SignDeterministic(hash Hash32, extraEntropy []byte) (Signature, error) {
+	return signRFC6979(k.value, hash[:], extraEntropy)
+}
+
+// SignWithNonce returns a signature of the hash using the specified nonce. The nonce must be in
+//   the range 1 to N-1 and must never be reused with a different hash or the key will be exposed.
+func (k Key) SignWithNonce(hash Hash32, nonce big.Int) (Signature, error) {
+	return signWithNonce(k.value, hash[:], &nonce)
+}
+
+// Nonce returns the RFC 6979 nonce that Sign, or SignDeterministic when extraEntropy is not
+//   empty, would use for the hash.
+func (k Key) Nonce(hash Hash32, extraEntropy []byte) big.Int {
+	return *nonceRFC6979(k.value, hash[:], extraEntropy)
 }
 
 // MarshalJSON converts to json.
@@ -256,16 +224,22 @@ func (k Key) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON converts from json.
 func (k *Key) UnmarshalJSON(data []byte) error {
+	if isJSONEmpty(data) {
+		*k = Key{}
+		return nil
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("Too short for Key data : %d", len(data))
+	}
+
 	return k.DecodeString(string(data[1 : len(data)-1]))
 }
 
-// MarshalText returns the text encoding of the key.
+// MarshalText returns the text encoding of the key. This is the same WIF encoding used by String
+//   and MarshalJSON.
 // Implements encoding.TextMarshaler interface.
 func (k Key) MarshalText() ([]byte, error) {
-	b := k.Bytes()
-	result := make([]byte, hex.EncodedLen(len(b)))
-	hex.Encode(result, b)
-	return result, nil
+	return []byte(k.String()), nil
 }
 
 // UnmarshalText parses a text encoded key and sets the value of this object.
@@ -286,8 +260,22 @@ func (k *Key) UnmarshalBinary(data []byte) error {
 	return k.SetBytes(data)
 }
 
+// Value returns the value to store in a database column.
+// Implements driver.Valuer interface.
+func (k Key) Value() (driver.Value, error) {
+	if k.IsEmpty() {
+		return nil, nil
+	}
+	return k.Bytes(), nil
+}
+
 // Scan converts from a database column.
 func (k *Key) Scan(data interface{}) error {
+	if data == nil {
+		*k = Key{}
+		return nil
+	}
+
 	b, ok := data.([]byte)
 	if !ok {
 		return errors.New("Key db column not bytes")
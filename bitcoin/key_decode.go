@@ -0,0 +1,313 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	bip32 "github.com/tyler-smith/go-bip32"
+)
+
+const (
+	KeyFormatWIF         = KeyFormat(1) // Base58 Wallet Import Format
+	KeyFormatHex         = KeyFormat(2) // 32 byte hex number
+	KeyFormatBIP32       = KeyFormat(3) // Base58 xprv, xpub, tprv, or tpub
+	KeyFormatExtendedKey = KeyFormat(4) // BIP-0276 "bitcoin-xkey:" text
+
+	bip32SerializedLength = 82 // Including the 4 byte checksum
+)
+
+var (
+	// ErrBadKeyCharacter means the key text contains a character that isn't valid in its encoding.
+	ErrBadKeyCharacter = errors.New("Key has invalid character")
+
+	// ErrBadKeyChecksum means the key decoded but the checksum doesn't match, which is usually
+	//   caused by a typo or a truncated copy.
+	ErrBadKeyChecksum = errors.New("Key has bad checksum")
+
+	// ErrWrongKeyPrefix means the key's prefix is not a private key prefix for any known network.
+	//   Addresses and public keys are often pasted where private keys are expected.
+	ErrWrongKeyPrefix = errors.New("Key has wrong network prefix")
+
+	// ErrNonCanonicalKey means the key decoded but isn't in the standard encoding, for example
+	//   with surrounding white space or an invalid compressed public key flag.
+	ErrNonCanonicalKey = errors.New("Key not canonical")
+
+	// ErrUnknownKeyFormat means DecodeAnyKey couldn't determine the format of the key.
+	ErrUnknownKeyFormat = errors.New("Unknown key format")
+
+	bip32Versions = map[string]struct {
+		net       Network
+		isPrivate bool
+	}{
+		"0488ade4": {MainNet, true},  // xprv
+		"0488b21e": {MainNet, false}, // xpub
+		"04358394": {TestNet, true},  // tprv
+		"043587cf": {TestNet, false}, // tpub
+	}
+)
+
+// KeyFormat is the text format of a key.
+type KeyFormat uint8
+
+// DecodedKey is the result of DecodeAnyKey.
+type DecodedKey struct {
+	Format KeyFormat
+
+	// Key is the private key. It is empty for extended public keys.
+	Key Key
+
+	// ExtendedKey is set for BIP-0032 and BIP-0276 keys.
+	ExtendedKey *ExtendedKey
+}
+
+// DecodeWIF decodes a WIF (Wallet Import Format) private key. Errors have causes that identify
+//   the specific problem, like ErrBadKeyChecksum or ErrWrongKeyPrefix, and detail text that
+//   describes it.
+func DecodeWIF(s string) (Key, error) {
+	if strings.TrimSpace(s) != s {
+		return Key{}, errors.Wrap(ErrNonCanonicalKey, "surrounding white space")
+	}
+
+	if err := checkBase58(s); err != nil {
+		return Key{}, err
+	}
+
+	b := Base58Decode(s)
+	if len(b) < 5 {
+		return Key{}, errors.Wrapf(ErrBadKeyLength, "%d bytes", len(b))
+	}
+
+	checksum := DoubleSha256(b[:len(b)-4])
+	if !bytes.Equal(checksum[:4], b[len(b)-4:]) {
+		return Key{}, ErrBadKeyChecksum
+	}
+	b = b[:len(b)-4]
+
+	net, exists := networkForPrivateKeyType(b[0])
+	if !exists {
+		return Key{}, errors.Wrap(ErrWrongKeyPrefix, describeKeyPrefix(b[0]))
+	}
+
+	switch len(b) {
+	case 33: // uncompressed public key
+	case 34:
+		if b[33] != 0x01 {
+			return Key{}, errors.Wrapf(ErrNonCanonicalKey, "compressed flag 0x%02x", b[33])
+		}
+	default:
+		return Key{}, errors.Wrapf(ErrBadKeyLength, "got %d bytes, want 33 or 34", len(b))
+	}
+
+	if err := privateKeyIsValid(b[1:33]); err != nil {
+		return Key{}, err
+	}
+
+	result := Key{net: net}
+	result.value.SetBytes(b[1:33])
+	return result, nil
+}
+
+// DecodeHexKey decodes a private key from the hex text of its 32 byte number.
+func DecodeHexKey(s string, net Network) (Key, error) {
+	if strings.TrimSpace(s) != s {
+		return Key{}, errors.Wrap(ErrNonCanonicalKey, "surrounding white space")
+	}
+
+	for i, c := range s {
+		if !isHexCharacter(c) {
+			return Key{}, errors.Wrapf(ErrBadKeyCharacter, "'%c' at position %d is not hex", c,
+				i)
+		}
+	}
+
+	if len(s) != 64 {
+		return Key{}, errors.Wrapf(ErrBadKeyLength, "got %d hex characters, want 64", len(s))
+	}
+
+	b, _ := hex.DecodeString(s)
+	return KeyFromNumber(b, net)
+}
+
+// DecodeBIP32Key decodes a base58 BIP-0032 extended key (xprv, xpub, tprv, or tpub).
+func DecodeBIP32Key(s string) (ExtendedKey, error) {
+	if strings.TrimSpace(s) != s {
+		return ExtendedKey{}, errors.Wrap(ErrNonCanonicalKey, "surrounding white space")
+	}
+
+	if err := checkBase58(s); err != nil {
+		return ExtendedKey{}, err
+	}
+
+	b := Base58Decode(s)
+	if len(b) != bip32SerializedLength {
+		return ExtendedKey{}, errors.Wrapf(ErrBadKeyLength, "got %d bytes, want %d", len(b),
+			bip32SerializedLength)
+	}
+
+	checksum := DoubleSha256(b[:len(b)-4])
+	if !bytes.Equal(checksum[:4], b[len(b)-4:]) {
+		return ExtendedKey{}, ErrBadKeyChecksum
+	}
+
+	version, exists := bip32Versions[hex.EncodeToString(b[:4])]
+	if !exists {
+		return ExtendedKey{}, errors.Wrapf(ErrWrongKeyPrefix, "version %x", b[:4])
+	}
+
+	if version.isPrivate {
+		if b[45] != 0 {
+			return ExtendedKey{}, errors.Wrapf(ErrNonCanonicalKey, "private key padding 0x%02x",
+				b[45])
+		}
+		if err := privateKeyIsValid(b[46:78]); err != nil {
+			return ExtendedKey{}, err
+		}
+	} else {
+		if _, err := PublicKeyFromBytes(b[45:78]); err != nil {
+			return ExtendedKey{}, errors.Wrap(err, "public key")
+		}
+	}
+
+	bip32Key, err := bip32.Deserialize(b)
+	if err != nil {
+		return ExtendedKey{}, errors.Wrap(err, "deserialize")
+	}
+
+	result, err := fromBIP32(bip32Key)
+	if err != nil {
+		return ExtendedKey{}, err
+	}
+
+	result.Network = version.net
+	return result, nil
+}
+
+// DecodeAnyKey detects the format of the key text and decodes it. Surrounding white space is
+//   ignored. net is only used for hex keys, since the other formats contain the network. When the
+//   format is detected but the key is invalid the error describes the problem with that format.
+func DecodeAnyKey(s string, net Network) (*DecodedKey, error) {
+	s = strings.TrimSpace(s)
+
+	switch {
+	case len(s) == 0:
+		return nil, errors.Wrap(ErrUnknownKeyFormat, "empty")
+
+	case strings.HasPrefix(s, ExtendedKeyURLPrefix+":"):
+		xkey, err := ExtendedKeyFromStr(s)
+		if err != nil {
+			if xkey, err58 := ExtendedKeyFromStr58(s); err58 == nil {
+				return newDecodedExtendedKey(KeyFormatExtendedKey, xkey), nil
+			}
+			return nil, errors.Wrap(err, "extended key")
+		}
+		return newDecodedExtendedKey(KeyFormatExtendedKey, xkey), nil
+
+	case isHexString(s):
+		if len(s) == 66 && (strings.HasPrefix(s, "02") || strings.HasPrefix(s, "03")) {
+			return nil, errors.Wrap(ErrUnknownKeyFormat, "looks like a public key")
+		}
+
+		key, err := DecodeHexKey(s, net)
+		if err != nil {
+			return nil, errors.Wrap(err, "hex")
+		}
+		return &DecodedKey{Format: KeyFormatHex, Key: key}, nil
+
+	case hasBIP32Prefix(s):
+		xkey, err := DecodeBIP32Key(s)
+		if err != nil {
+			return nil, errors.Wrap(err, "bip32")
+		}
+		return newDecodedExtendedKey(KeyFormatBIP32, xkey), nil
+	}
+
+	key, err := DecodeWIF(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "wif")
+	}
+	return &DecodedKey{Format: KeyFormatWIF, Key: key}, nil
+}
+
+func newDecodedExtendedKey(format KeyFormat, xkey ExtendedKey) *DecodedKey {
+	net := xkey.Network
+	if net == InvalidNet {
+		net = MainNet
+	}
+
+	return &DecodedKey{
+		Format:      format,
+		Key:         xkey.Key(net),
+		ExtendedKey: &xkey,
+	}
+}
+
+func (f KeyFormat) String() string {
+	switch f {
+	case KeyFormatWIF:
+		return "wif"
+	case KeyFormatHex:
+		return "hex"
+	case KeyFormatBIP32:
+		return "bip32"
+	case KeyFormatExtendedKey:
+		return "xkey"
+	default:
+		return "unknown"
+	}
+}
+
+// checkBase58 returns an error describing the first character that isn't valid base58.
+func checkBase58(s string) error {
+	for i, c := range s {
+		if strings.ContainsRune(base58Alphabet, c) {
+			continue
+		}
+
+		switch c {
+		case '0', 'O', 'I', 'l':
+			return errors.Wrapf(ErrBadKeyCharacter,
+				"'%c' at position %d is not used in base58 to avoid confusion", c, i)
+		default:
+			return errors.Wrapf(ErrBadKeyCharacter, "'%c' at position %d is not base58", c, i)
+		}
+	}
+
+	return nil
+}
+
+// describeKeyPrefix returns text describing what data with the prefix byte probably is.
+func describeKeyPrefix(prefix byte) string {
+	switch prefix {
+	case AddressTypeMainPKH, AddressTypeTestPKH:
+		return fmt.Sprintf("prefix 0x%02x is a P2PKH address", prefix)
+	case AddressTypeMainSH, AddressTypeTestSH:
+		return fmt.Sprintf("prefix 0x%02x is a P2SH address", prefix)
+	}
+
+	return fmt.Sprintf("prefix 0x%02x", prefix)
+}
+
+func hasBIP32Prefix(s string) bool {
+	for _, prefix := range []string{"xprv", "xpub", "tprv", "tpub"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHexString(s string) bool {
+	for _, c := range s {
+		if !isHexCharacter(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexCharacter(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,151 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecodeWIFErrors(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+	wif := key.String()
+
+	ra, err := key.RawAddress()
+	if err != nil {
+		t.Fatalf("Failed to create raw address : %s", err)
+	}
+	address := NewAddressFromRawAddress(ra, MainNet).String()
+
+	// Change one character to another valid base58 character.
+	typo := []byte(wif)
+	if typo[10] == 'a' {
+		typo[10] = 'b'
+	} else {
+		typo[10] = 'a'
+	}
+
+	badFlag := encodeAddress(append(append([]byte{typeMainPrivKey}, key.Number()...), 0x02))
+	longKey := encodeAddress(append(append([]byte{typeMainPrivKey}, key.Number()...), 0x01,
+		0x01))
+	zeroKey := encodeAddress(append([]byte{typeMainPrivKey}, make([]byte, 32)...))
+
+	tests := []struct {
+		name string
+		text string
+		err  error
+	}{
+		{"valid", wif, nil},
+		{"typo", string(typo), ErrBadKeyChecksum},
+		{"zero", wif[:5] + "0" + wif[6:], ErrBadKeyCharacter},
+		{"space", " " + wif, ErrNonCanonicalKey},
+		{"address", address, ErrWrongKeyPrefix},
+		{"flag", badFlag, ErrNonCanonicalKey},
+		{"length", longKey, ErrBadKeyLength},
+		{"truncated", "1111", ErrBadKeyLength},
+		{"range", zeroKey, ErrOutOfRangeKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeWIF(tt.text)
+			if errors.Cause(err) != tt.err {
+				t.Fatalf("Wrong error : got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				t.Logf("Error : %s", err)
+				return
+			}
+
+			if !decoded.Equal(key) {
+				t.Errorf("Wrong key : got %s, want %s", decoded, key)
+			}
+		})
+	}
+
+	_, err = KeyFromStr(address)
+	if errors.Cause(err) != ErrWrongKeyPrefix {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrWrongKeyPrefix)
+	}
+	if !strings.Contains(err.Error(), "P2PKH address") {
+		t.Errorf("Error should describe the prefix : %s", err)
+	}
+}
+
+func TestDecodeAnyKey(t *testing.T) {
+	key, err := GenerateKey(TestNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	xkey, err := GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to generate extended key : %s", err)
+	}
+	bip32Key := xkey.ToBIP32()
+	xprv := bip32Key.B58Serialize()
+	xpub := bip32Key.PublicKey().B58Serialize()
+
+	tests := []struct {
+		name   string
+		text   string
+		format KeyFormat
+		key    *Key
+		err    error
+	}{
+		{"wif", key.String(), KeyFormatWIF, &key, nil},
+		{"wif space", "  " + key.String() + "\n", KeyFormatWIF, &key, nil},
+		{"hex", hex.EncodeToString(key.Number()), KeyFormatHex, &key, nil},
+		{"hex short", hex.EncodeToString(key.Number()[1:]), 0, nil, ErrBadKeyLength},
+		{"public key", key.PublicKey().String(), 0, nil, ErrUnknownKeyFormat},
+		{"xprv", xprv, KeyFormatBIP32, nil, nil},
+		{"xpub", xpub, KeyFormatBIP32, nil, nil},
+		{"xprv typo", xprv[:20] + swapBase58(xprv[20]) + xprv[21:], 0, nil, ErrBadKeyChecksum},
+		{"xkey", xkey.String(), KeyFormatExtendedKey, nil, nil},
+		{"empty", " ", 0, nil, ErrUnknownKeyFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeAnyKey(tt.text, TestNet)
+			if errors.Cause(err) != tt.err {
+				t.Fatalf("Wrong error : got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				t.Logf("Error : %s", err)
+				return
+			}
+
+			if decoded.Format != tt.format {
+				t.Errorf("Wrong format : got %s, want %s", decoded.Format, tt.format)
+			}
+
+			if tt.key != nil && !decoded.Key.Equal(*tt.key) {
+				t.Errorf("Wrong key : got %s, want %s", decoded.Key, tt.key)
+			}
+
+			if tt.format == KeyFormatBIP32 || tt.format == KeyFormatExtendedKey {
+				if decoded.ExtendedKey == nil {
+					t.Fatalf("Missing extended key")
+				}
+				if decoded.ExtendedKey.ChainCode != xkey.ChainCode {
+					t.Errorf("Wrong chain code")
+				}
+				if decoded.ExtendedKey.IsPrivate() == decoded.Key.IsEmpty() {
+					t.Errorf("Key should only be set for private extended keys")
+				}
+			}
+		})
+	}
+}
+
+func swapBase58(c byte) string {
+	if c == 'a' {
+		return "b"
+	}
+	return "a"
+}
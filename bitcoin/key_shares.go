@@ -0,0 +1,270 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	keyShareVersion = 0x01
+
+	// KeyShareLength is the length of a serialized key share including the checksum.
+	// version (1) + key id (4) + threshold (1) + index (1) + value (32) + checksum (4)
+	KeyShareLength = 43
+)
+
+var (
+	ErrKeyShareChecksum  = errors.New("Key share checksum invalid")
+	ErrKeyShareMismatch  = errors.New("Key shares are not from the same key")
+	ErrKeyShareDuplicate = errors.New("Duplicate key share")
+	ErrNotEnoughShares   = errors.New("Not enough key shares")
+)
+
+// KeyShare is one share of a private key that was split using Shamir's secret sharing scheme. A
+//   threshold number of shares are required to recreate the key.
+type KeyShare struct {
+	KeyID     [4]byte // First 4 bytes of the hash160 of the key's public key.
+	Threshold uint8   // Number of shares required to recreate the key.
+	Index     uint8   // x coordinate of the share. Never zero.
+	Value     big.Int // y coordinate of the share.
+}
+
+// SplitKey splits a key into count shares, any threshold of which can be combined to recreate the
+//   key.
+func SplitKey(k Key, threshold, count int) ([]KeyShare, error) {
+	if threshold < 1 {
+		return nil, errors.New("Threshold must be one or more")
+	}
+	if count < threshold {
+		return nil, fmt.Errorf("Count less than threshold : %d < %d", count, threshold)
+	}
+	if count > 255 {
+		return nil, fmt.Errorf("Count more than 255 : %d", count)
+	}
+	if k.IsEmpty() {
+		return nil, ErrOutOfRangeKey
+	}
+
+	var keyID [4]byte
+	copy(keyID[:], Hash160(k.PublicKey().Bytes()))
+
+	// Polynomial with the key value as the constant coefficient.
+	coefficients := make([]big.Int, threshold)
+	coefficients[0].Set(&k.value)
+	max := new(big.Int).Sub(curveS256.N, one)
+	for i := 1; i < threshold; i++ {
+		r, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return nil, errors.Wrap(err, "random coefficient")
+		}
+		coefficients[i].Add(r, one) // range 1 to N-1
+	}
+
+	result := make([]KeyShare, count)
+	for i := range result {
+		result[i].KeyID = keyID
+		result[i].Threshold = uint8(threshold)
+		result[i].Index = uint8(i + 1)
+
+		// Evaluate polynomial using Horner's method.
+		x := big.NewInt(int64(i + 1))
+		for j := threshold - 1; j >= 0; j-- {
+			result[i].Value.Mul(&result[i].Value, x)
+			result[i].Value.Add(&result[i].Value, &coefficients[j])
+			result[i].Value.Mod(&result[i].Value, curveS256.N)
+		}
+	}
+
+	return result, nil
+}
+
+// CombineKey recreates a key from a threshold number of shares.
+func CombineKey(shares []KeyShare, net Network) (Key, error) {
+	if len(shares) == 0 {
+		return Key{}, ErrNotEnoughShares
+	}
+
+	first := shares[0]
+	if len(shares) < int(first.Threshold) {
+		return Key{}, errors.Wrapf(ErrNotEnoughShares, "have %d, need %d", len(shares),
+			first.Threshold)
+	}
+
+	for i, share := range shares {
+		if share.KeyID != first.KeyID || share.Threshold != first.Threshold {
+			return Key{}, ErrKeyShareMismatch
+		}
+		if share.Index == 0 {
+			return Key{}, errors.New("Key share index zero")
+		}
+		if !validShareValue(&share.Value) {
+			return Key{}, errors.Wrapf(ErrOutOfRangeKey, "key share value %d", share.Index)
+		}
+		for _, other := range shares[:i] {
+			if other.Index == share.Index {
+				return Key{}, errors.Wrapf(ErrKeyShareDuplicate, "index %d", share.Index)
+			}
+		}
+	}
+
+	// Lagrange interpolation at x = 0.
+	used := shares[:first.Threshold]
+	var value big.Int
+	for j, share := range used {
+		numerator := big.NewInt(1)
+		denominator := big.NewInt(1)
+		xj := big.NewInt(int64(share.Index))
+		for m, other := range used {
+			if m == j {
+				continue
+			}
+			xm := big.NewInt(int64(other.Index))
+			numerator.Mul(numerator, xm)
+			numerator.Mod(numerator, curveS256.N)
+
+			diff := new(big.Int).Sub(xm, xj)
+			denominator.Mul(denominator, diff)
+			denominator.Mod(denominator, curveS256.N)
+		}
+
+		term := new(big.Int).ModInverse(denominator, curveS256.N)
+		term.Mul(term, numerator)
+		term.Mul(term, &share.Value)
+		value.Add(&value, term)
+		value.Mod(&value, curveS256.N)
+	}
+
+	result := KeyFromValue(value, net)
+	if result.IsEmpty() {
+		return Key{}, ErrOutOfRangeKey
+	}
+
+	if !bytes.Equal(Hash160(result.PublicKey().Bytes())[:4], first.KeyID[:]) {
+		return Key{}, ErrKeyShareMismatch
+	}
+
+	return result, nil
+}
+
+// validShareValue returns true if the share value is in the range 1 to N-1.
+func validShareValue(value *big.Int) bool {
+	return value.Sign() > 0 && value.Cmp(curveS256.N) < 0
+}
+
+// KeyShareFromStr converts hex text to a key share.
+func KeyShareFromStr(s string) (KeyShare, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return KeyShare{}, err
+	}
+
+	return KeyShareFromBytes(b)
+}
+
+// KeyShareFromBytes decodes a key share and verifies its checksum.
+func KeyShareFromBytes(b []byte) (KeyShare, error) {
+	if len(b) != KeyShareLength {
+		return KeyShare{}, errors.Wrapf(ErrWrongSize, "got %d, want %d", len(b), KeyShareLength)
+	}
+
+	checksum := DoubleSha256(b[:KeyShareLength-4])
+	if !bytes.Equal(checksum[:4], b[KeyShareLength-4:]) {
+		return KeyShare{}, ErrKeyShareChecksum
+	}
+
+	if b[0] != keyShareVersion {
+		return KeyShare{}, fmt.Errorf("Unsupported key share version : %d", b[0])
+	}
+
+	var result KeyShare
+	copy(result.KeyID[:], b[1:5])
+	result.Threshold = b[5]
+	result.Index = b[6]
+	result.Value.SetBytes(b[7:39])
+
+	if result.Threshold == 0 || result.Index == 0 {
+		return KeyShare{}, errors.New("Invalid key share")
+	}
+
+	if !validShareValue(&result.Value) {
+		return KeyShare{}, errors.Wrap(ErrOutOfRangeKey, "key share value")
+	}
+
+	return result, nil
+}
+
+// Bytes returns the serialized key share followed by a 4 byte checksum.
+func (s KeyShare) Bytes() []byte {
+	b := make([]byte, 0, KeyShareLength)
+	b = append(b, keyShareVersion)
+	b = append(b, s.KeyID[:]...)
+	b = append(b, s.Threshold, s.Index)
+
+	value := s.Value.Bytes()
+	if len(value) < 32 {
+		b = append(b, make([]byte, 32-len(value))...)
+	}
+	b = append(b, value...)
+
+	checksum := DoubleSha256(b)
+	return append(b, checksum[:4]...)
+}
+
+// String returns the hex encoding of the key share.
+func (s KeyShare) String() string {
+	return hex.EncodeToString(s.Bytes())
+}
+
+// SetString decodes a key share from hex text.
+func (s *KeyShare) SetString(str string) error {
+	ns, err := KeyShareFromStr(str)
+	if err != nil {
+		return err
+	}
+
+	*s = ns
+	return nil
+}
+
+// SetBytes decodes the key share from bytes.
+func (s *KeyShare) SetBytes(b []byte) error {
+	ns, err := KeyShareFromBytes(b)
+	if err != nil {
+		return err
+	}
+
+	*s = ns
+	return nil
+}
+
+func (s KeyShare) Serialize(w io.Writer) error {
+	_, err := w.Write(s.Bytes())
+	return err
+}
+
+func (s *KeyShare) Deserialize(r io.Reader) error {
+	b := make([]byte, KeyShareLength)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return errors.Wrap(err, "key share")
+	}
+
+	return s.SetBytes(b)
+}
+
+// MarshalText returns the text encoding of the key share.
+// Implements encoding.TextMarshaler interface.
+func (s KeyShare) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a text encoded key share and sets the value of this object.
+// Implements encoding.TextUnmarshaler interface.
+func (s *KeyShare) UnmarshalText(text []byte) error {
+	return s.SetString(string(text))
+}
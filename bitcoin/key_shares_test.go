@@ -0,0 +1,131 @@
+package bitcoin
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestSplitKey(t *testing.T) {
+	tests := []struct {
+		threshold int
+		count     int
+	}{
+		{1, 1},
+		{2, 3},
+		{3, 5},
+		{5, 5},
+	}
+
+	for _, tt := range tests {
+		key, err := GenerateKey(MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+
+		shares, err := SplitKey(key, tt.threshold, tt.count)
+		if err != nil {
+			t.Fatalf("Failed to split key : %s", err)
+		}
+
+		if len(shares) != tt.count {
+			t.Fatalf("Wrong share count : got %d, want %d", len(shares), tt.count)
+		}
+
+		// Use the last shares to ensure the first shares aren't required.
+		combined, err := CombineKey(shares[tt.count-tt.threshold:], MainNet)
+		if err != nil {
+			t.Fatalf("Failed to combine key : %s", err)
+		}
+
+		if !combined.Equal(key) {
+			t.Fatalf("Wrong combined key : \ngot  %s\nwant %s", combined, key)
+		}
+
+		// Serialize shares
+		for _, share := range shares {
+			decoded, err := KeyShareFromStr(share.String())
+			if err != nil {
+				t.Fatalf("Failed to decode share : %s", err)
+			}
+
+			if decoded.Index != share.Index || decoded.Threshold != share.Threshold ||
+				decoded.Value.Cmp(&share.Value) != 0 {
+				t.Fatalf("Wrong decoded share : \ngot  %s\nwant %s", decoded, share)
+			}
+		}
+
+		if tt.threshold > 1 {
+			if _, err := CombineKey(shares[:tt.threshold-1], MainNet); err == nil {
+				t.Fatalf("Combine with too few shares should fail")
+			}
+
+			duplicate := append([]KeyShare{shares[0]}, shares[:tt.threshold-1]...)
+			if _, err := CombineKey(duplicate, MainNet); err == nil {
+				t.Fatalf("Combine with duplicate shares should fail")
+			}
+		}
+	}
+}
+
+func TestKeyShareChecksum(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	shares, err := SplitKey(key, 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split key : %s", err)
+	}
+
+	b := shares[0].Bytes()
+	b[10] ^= 0x01
+
+	if _, err := KeyShareFromBytes(b); err != ErrKeyShareChecksum {
+		t.Fatalf("Wrong error for modified share : got %v, want %v", err, ErrKeyShareChecksum)
+	}
+
+	// Tampered value with a valid checksum is detected by the key id.
+	shares[0].Value.Add(&shares[0].Value, one)
+	if _, err := CombineKey(shares[:2], MainNet); err != ErrKeyShareMismatch {
+		t.Fatalf("Wrong error for tampered share : got %v, want %v", err, ErrKeyShareMismatch)
+	}
+}
+
+func TestKeyShareValueRange(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	shares, err := SplitKey(key, 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split key : %s", err)
+	}
+
+	for _, value := range []*big.Int{
+		big.NewInt(0),
+		new(big.Int).Set(curveS256.N),
+		new(big.Int).Add(curveS256.N, one),
+	} {
+		share := KeyShare{
+			KeyID:     shares[0].KeyID,
+			Threshold: shares[0].Threshold,
+			Index:     shares[0].Index,
+		}
+		share.Value.Set(value)
+
+		if _, err := KeyShareFromBytes(share.Bytes()); errors.Cause(err) != ErrOutOfRangeKey {
+			t.Fatalf("Wrong error for share value %s : got %v, want %v", value.Text(16), err,
+				ErrOutOfRangeKey)
+		}
+
+		combine := []KeyShare{share, shares[1]}
+		if _, err := CombineKey(combine, MainNet); errors.Cause(err) != ErrOutOfRangeKey {
+			t.Fatalf("Wrong combine error for share value %s : got %v, want %v",
+				value.Text(16), err, ErrOutOfRangeKey)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package bitcoin
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type marshalType interface {
+	json.Marshaler
+	encoding.TextMarshaler
+}
+
+type unmarshalType interface {
+	json.Unmarshaler
+	encoding.TextUnmarshaler
+	sql.Scanner
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	hash, err := NewHash32(Sha256([]byte("marshal")))
+	if err != nil {
+		t.Fatalf("Failed to create hash : %s", err)
+	}
+
+	hash20, err := NewHash20(Hash160([]byte("marshal")))
+	if err != nil {
+		t.Fatalf("Failed to create hash : %s", err)
+	}
+
+	signature, err := key.Sign(*hash)
+	if err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	extendedKey, err := GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to generate extended key : %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		value marshalType
+		empty func() unmarshalType
+	}{
+		// The binary encoding of a key doesn't include the network.
+		{"Key", key, func() unmarshalType { return &Key{net: MainNet} }},
+		{"PublicKey", key.PublicKey(), func() unmarshalType { return &PublicKey{} }},
+		{"Hash32", *hash, func() unmarshalType { return &Hash32{} }},
+		{"Hash20", *hash20, func() unmarshalType { return &Hash20{} }},
+		{"Signature", signature, func() unmarshalType { return &Signature{} }},
+		{"ExtendedKey", extendedKey, func() unmarshalType { return &ExtendedKey{} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// JSON
+			js, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Failed to marshal json : %s", err)
+			}
+
+			fromJSON := tt.empty()
+			if err := json.Unmarshal(js, fromJSON); err != nil {
+				t.Fatalf("Failed to unmarshal json : %s", err)
+			}
+
+			if !reflect.DeepEqual(reflect.ValueOf(fromJSON).Elem().Interface(), tt.value) {
+				t.Fatalf("Wrong value from json : \ngot  %v\nwant %v", fromJSON, tt.value)
+			}
+
+			// Text
+			text, err := tt.value.MarshalText()
+			if err != nil {
+				t.Fatalf("Failed to marshal text : %s", err)
+			}
+
+			fromText := tt.empty()
+			if err := fromText.UnmarshalText(text); err != nil {
+				t.Fatalf("Failed to unmarshal text : %s", err)
+			}
+
+			if !reflect.DeepEqual(reflect.ValueOf(fromText).Elem().Interface(), tt.value) {
+				t.Fatalf("Wrong value from text : \ngot  %v\nwant %v", fromText, tt.value)
+			}
+
+			// SQL
+			var dbValue driver.Value
+			if valuer, ok := tt.value.(driver.Valuer); ok {
+				dbValue, err = valuer.Value()
+				if err != nil {
+					t.Fatalf("Failed to get db value : %s", err)
+				}
+			} else if b, ok := tt.value.(encoding.BinaryMarshaler); ok {
+				dbValue, _ = b.MarshalBinary()
+			}
+
+			fromDB := tt.empty()
+			if err := fromDB.Scan(dbValue); err != nil {
+				t.Fatalf("Failed to scan : %s", err)
+			}
+
+			if !reflect.DeepEqual(reflect.ValueOf(fromDB).Elem().Interface(), tt.value) {
+				t.Fatalf("Wrong value from db : \ngot  %v\nwant %v", fromDB, tt.value)
+			}
+
+			// Null
+			fromNull := tt.empty()
+			if err := json.Unmarshal([]byte("null"), fromNull); err != nil {
+				t.Fatalf("Failed to unmarshal null json : %s", err)
+			}
+
+			if err := fromNull.Scan(nil); err != nil {
+				t.Fatalf("Failed to scan null : %s", err)
+			}
+		})
+	}
+}
+
+func TestHashSQLValue(t *testing.T) {
+	hash, err := NewHash32(Sha256([]byte("sql")))
+	if err != nil {
+		t.Fatalf("Failed to create hash : %s", err)
+	}
+
+	hash20, err := NewHash20(Hash160([]byte("sql")))
+	if err != nil {
+		t.Fatalf("Failed to create hash : %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		value driver.Valuer
+		empty sql.Scanner
+		want  []byte
+	}{
+		{"Hash32", hash.SQLValue(), &Hash32Value{}, hash.Bytes()},
+		{"Hash20", hash20.SQLValue(), &Hash20Value{}, hash20.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbValue, err := tt.value.Value()
+			if err != nil {
+				t.Fatalf("Failed to get db value : %s", err)
+			}
+
+			if !reflect.DeepEqual(dbValue, tt.want) {
+				t.Fatalf("Wrong db value : \ngot  %x\nwant %x", dbValue, tt.want)
+			}
+
+			if err := tt.empty.Scan(dbValue); err != nil {
+				t.Fatalf("Failed to scan : %s", err)
+			}
+
+			if !reflect.DeepEqual(reflect.ValueOf(tt.empty).Elem().Interface(), tt.value) {
+				t.Fatalf("Wrong value from db : \ngot  %v\nwant %v", tt.empty, tt.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+const signedMessagePrefix = "Bitcoin Signed Message:\n"
+
+// SignedMessageHash returns the hash that is signed for a text message, using the "Bitcoin Signed
+//   Message" format.
+func SignedMessageHash(message string) Hash32 {
+	var buf bytes.Buffer
+	writeCompactSize(&buf, uint64(len(signedMessagePrefix)))
+	buf.WriteString(signedMessagePrefix)
+	writeCompactSize(&buf, uint64(len(message)))
+	buf.WriteString(message)
+
+	var result Hash32
+	copy(result[:], DoubleSha256(buf.Bytes()))
+	return result
+}
+
+// SignMessage signs a text message and returns the signature in compact form. The signature
+//   contains the recovery id so the public key can be recovered with RecoverMessagePublicKey.
+func (k Key) SignMessage(message string) (string, error) {
+	signature, err := k.SignRecoverable(SignedMessageHash(message))
+	if err != nil {
+		return "", err
+	}
+
+	return signature.ToCompact(), nil
+}
+
+// RecoverMessagePublicKey returns the public key that created the compact signature of the text
+//   message. Bitcoin Signed Messages are verified by comparing the address of the recovered key
+//   to the expected address.
+func RecoverMessagePublicKey(message, compactSignature string) (PublicKey, error) {
+	signature, err := RecoverableSignatureFromCompact(compactSignature)
+	if err != nil {
+		return PublicKey{}, errors.Wrap(err, "signature")
+	}
+
+	return signature.RecoverPublicKey(SignedMessageHash(message))
+}
+
+// VerifyMessage returns true if the compact signature of the text message is valid for the
+//   public key.
+func (k PublicKey) VerifyMessage(message, compactSignature string) bool {
+	signature, err := SignatureFromCompact(compactSignature)
+	if err != nil {
+		return false
+	}
+
+	return signature.Verify(SignedMessageHash(message), k)
+}
+
+// writeCompactSize writes a bitcoin variable length integer.
+func writeCompactSize(buf *bytes.Buffer, value uint64) {
+	var b [9]byte
+	switch {
+	case value < 0xfd:
+		buf.WriteByte(byte(value))
+	case value <= 0xffff:
+		b[0] = 0xfd
+		binary.LittleEndian.PutUint16(b[1:], uint16(value))
+		buf.Write(b[:3])
+	case value <= 0xffffffff:
+		b[0] = 0xfe
+		binary.LittleEndian.PutUint32(b[1:], uint32(value))
+		buf.Write(b[:5])
+	default:
+		b[0] = 0xff
+		binary.LittleEndian.PutUint64(b[1:], value)
+		buf.Write(b[:9])
+	}
+}
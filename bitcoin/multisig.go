@@ -0,0 +1,226 @@
+package bitcoin
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxMultiSigPublicKeys is the maximum number of public keys in a bare multi-sig locking
+	//   script. Counts are encoded with OP_1 through OP_16.
+	MaxMultiSigPublicKeys = 16
+
+	// MaxSignatureWithHashTypeLength is the maximum length of a DER encoded signature with the
+	//   sig hash type byte appended, as it is in unlocking scripts.
+	MaxSignatureWithHashTypeLength = 73
+
+	// multiSigSignaturePushSize is the maximum size of a signature push in an unlocking script.
+	//   1 byte push op code + 72 byte signature + 1 byte sig hash type
+	multiSigSignaturePushSize = 1 + MaxSignatureWithHashTypeLength
+
+	// multiSigPublicKeyPushSize is the size of a compressed public key push.
+	multiSigPublicKeyPushSize = 1 + PublicKeyCompressedLength
+)
+
+var (
+	ErrInvalidMultiSig = errors.New("Invalid multi-sig")
+)
+
+// MultiSig is a bare multi-sig locking script that requires signatures from Required of the
+//   public keys.
+//
+//   OP_{Required} <PublicKey>... OP_{Total} OP_CHECKMULTISIG
+type MultiSig struct {
+	Required   uint32
+	PublicKeys []PublicKey
+}
+
+// NewMultiSig returns a multi-sig requiring signatures from required of the public keys.
+func NewMultiSig(required uint32, publicKeys []PublicKey) (*MultiSig, error) {
+	if err := checkMultiSigCounts(required, uint32(len(publicKeys))); err != nil {
+		return nil, err
+	}
+
+	return &MultiSig{
+		Required:   required,
+		PublicKeys: publicKeys,
+	}, nil
+}
+
+// NewMultiSigTemplate returns a template for a bare multi-sig locking script.
+func NewMultiSigTemplate(required, total uint32) (Template, error) {
+	if err := checkMultiSigCounts(required, total); err != nil {
+		return nil, err
+	}
+
+	result := make(Template, 0, total+3)
+	result = append(result, OP_1+byte(required-1))
+	for i := uint32(0); i < total; i++ {
+		result = append(result, OP_PUBKEY)
+	}
+	result = append(result, OP_1+byte(total-1), OP_CHECKMULTISIG)
+
+	return result, nil
+}
+
+// ParseMultiSig parses a bare multi-sig locking script. It returns ErrWrongScriptTemplate if the
+//   locking script isn't a bare multi-sig.
+func ParseMultiSig(lockingScript Script) (*MultiSig, error) {
+	required, total, err := lockingScript.MultiSigCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MultiSig{
+		Required:   required,
+		PublicKeys: make([]PublicKey, 0, total),
+	}
+
+	r := bytes.NewReader(lockingScript)
+	if _, err := ParseScript(r); err != nil { // required count
+		return nil, errors.Wrap(err, "required")
+	}
+
+	for i := uint32(0); i < total; i++ {
+		item, err := ParseScript(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "public key %d", i)
+		}
+
+		publicKey, err := PublicKeyFromBytes(item.Data)
+		if err != nil {
+			return nil, errors.Wrapf(ErrWrongScriptTemplate, "public key %d: %s", i, err)
+		}
+
+		result.PublicKeys = append(result.PublicKeys, publicKey)
+	}
+
+	return result, nil
+}
+
+// Total returns the number of public keys.
+func (m MultiSig) Total() uint32 {
+	return uint32(len(m.PublicKeys))
+}
+
+// LockingScript returns the bare multi-sig locking script.
+func (m MultiSig) LockingScript() (Script, error) {
+	template, err := NewMultiSigTemplate(m.Required, m.Total())
+	if err != nil {
+		return nil, err
+	}
+
+	return template.LockingScript(m.PublicKeys)
+}
+
+// LockingScriptSize returns the size of the locking script.
+func (m MultiSig) LockingScriptSize() int {
+	return MultiSigLockingScriptSize(m.Total())
+}
+
+// MultiSigLockingScriptSize returns the size of a bare multi-sig locking script with total
+//   compressed public keys.
+func MultiSigLockingScriptSize(total uint32) int {
+	// OP_{Required} + public keys + OP_{Total} + OP_CHECKMULTISIG
+	return 1 + int(total)*multiSigPublicKeyPushSize + 2
+}
+
+// UnlockingScript returns an unlocking script containing the signatures. sigs must contain an
+//   entry for each public key, in the same order, that is empty for keys that didn't sign.
+//   Signatures must have the sig hash type byte appended and exactly Required must be provided.
+//
+//   OP_0 <Signature>...
+//
+//   The OP_0 is consumed by OP_CHECKMULTISIG because of a bug in the original implementation.
+func (m MultiSig) UnlockingScript(sigs [][]byte) (Script, error) {
+	if len(sigs) != len(m.PublicKeys) {
+		return nil, errors.Wrapf(ErrInvalidMultiSig, "%d signatures for %d public keys",
+			len(sigs), len(m.PublicKeys))
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, m.UnlockingScriptSize()))
+	if err := buf.WriteByte(OP_0); err != nil {
+		return nil, errors.Wrap(err, "write byte")
+	}
+
+	// Signatures must be in the same order as the public keys.
+	count := uint32(0)
+	for _, sig := range sigs {
+		if len(sig) == 0 {
+			continue
+		}
+
+		if err := WritePushDataScript(buf, sig); err != nil {
+			return nil, errors.Wrap(err, "write signature")
+		}
+		count++
+	}
+
+	if count != m.Required {
+		return nil, errors.Wrapf(ErrInvalidMultiSig, "%d signatures, %d required", count,
+			m.Required)
+	}
+
+	return Script(buf.Bytes()), nil
+}
+
+// UnlockingScriptTemplate returns an unlocking script containing maximum size zero placeholders
+//   for the required signatures. It can be used in a tx to estimate the size before signing.
+func (m MultiSig) UnlockingScriptTemplate() Script {
+	buf := bytes.NewBuffer(make([]byte, 0, m.UnlockingScriptSize()))
+	buf.WriteByte(OP_0)
+
+	placeholder := make([]byte, MaxSignatureWithHashTypeLength)
+	for i := uint32(0); i < m.Required; i++ {
+		WritePushDataScript(buf, placeholder)
+	}
+
+	return Script(buf.Bytes())
+}
+
+// UnlockingScriptSize returns the maximum size of the unlocking script.
+func (m MultiSig) UnlockingScriptSize() int {
+	return MultiSigUnlockingScriptSize(m.Required)
+}
+
+// MultiSigUnlockingScriptSize returns the maximum size of an unlocking script for a bare multi-sig
+//   locking script requiring required signatures.
+func MultiSigUnlockingScriptSize(required uint32) int {
+	// OP_0 + signatures
+	return 1 + int(required)*multiSigSignaturePushSize
+}
+
+// multiSigTemplateRequired returns the required signature count of a bare multi-sig template.
+func multiSigTemplateRequired(t Template) (uint32, bool) {
+	if len(t) < 4 || t[len(t)-1] != OP_CHECKMULTISIG || t[0] < OP_1 || t[0] > OP_16 {
+		return 0, false
+	}
+
+	r := bytes.NewReader(t[1 : len(t)-2])
+	for {
+		item, err := ParseScript(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil || item.Type != ScriptItemTypeOpCode || item.OpCode != OP_PUBKEY {
+			return 0, false
+		}
+	}
+
+	return uint32(t[0]-OP_1) + 1, true
+}
+
+func checkMultiSigCounts(required, total uint32) error {
+	if total < 1 || total > MaxMultiSigPublicKeys {
+		return errors.Wrapf(ErrInvalidMultiSig, "total %d not 1 to %d", total,
+			MaxMultiSigPublicKeys)
+	}
+
+	if required < 1 || required > total {
+		return errors.Wrapf(ErrInvalidMultiSig, "required %d not 1 to total %d", required, total)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package bitcoin
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func Test_MultiSig(t *testing.T) {
+	var keys []Key
+	var publicKeys []PublicKey
+	for i := 0; i < 3; i++ {
+		key, err := GenerateKey(TestNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key %d : %s", i, err)
+		}
+		keys = append(keys, key)
+		publicKeys = append(publicKeys, key.PublicKey())
+	}
+
+	multiSig, err := NewMultiSig(2, publicKeys)
+	if err != nil {
+		t.Fatalf("Failed to create multi-sig : %s", err)
+	}
+
+	lockingScript, err := multiSig.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	t.Logf("Locking Script : %s", lockingScript)
+
+	if len(lockingScript) != multiSig.LockingScriptSize() {
+		t.Fatalf("Wrong locking script size : got %d, want %d", len(lockingScript),
+			multiSig.LockingScriptSize())
+	}
+
+	required, total, err := lockingScript.MultiSigCounts()
+	if err != nil {
+		t.Fatalf("Failed to get multi-sig counts : %s", err)
+	}
+	if required != 2 || total != 3 {
+		t.Fatalf("Wrong counts : got %d of %d, want 2 of 3", required, total)
+	}
+
+	parsed, err := ParseMultiSig(lockingScript)
+	if err != nil {
+		t.Fatalf("Failed to parse multi-sig : %s", err)
+	}
+
+	if parsed.Required != 2 || parsed.Total() != 3 {
+		t.Fatalf("Wrong parsed counts : got %d of %d, want 2 of 3", parsed.Required,
+			parsed.Total())
+	}
+
+	for i, publicKey := range parsed.PublicKeys {
+		if !publicKey.Equal(publicKeys[i]) {
+			t.Fatalf("Wrong public key %d : got %s, want %s", i, publicKey, publicKeys[i])
+		}
+	}
+
+	template, err := NewMultiSigTemplate(2, 3)
+	if err != nil {
+		t.Fatalf("Failed to create template : %s", err)
+	}
+
+	templateRequired, err := template.RequiredSignatures()
+	if err != nil {
+		t.Fatalf("Failed to get template required signatures : %s", err)
+	}
+	if templateRequired != 2 || template.PubKeyCount() != 3 {
+		t.Fatalf("Wrong template counts : got %d of %d, want 2 of 3", templateRequired,
+			template.PubKeyCount())
+	}
+
+	var hash Hash32
+	hash[0] = 1
+	sigs := make([][]byte, 3)
+	for _, i := range []int{0, 2} {
+		sig, err := keys[i].Sign(hash)
+		if err != nil {
+			t.Fatalf("Failed to sign : %s", err)
+		}
+		sigs[i] = append(sig.Bytes(), 0x41)
+	}
+
+	unlockingScript, err := multiSig.UnlockingScript(sigs)
+	if err != nil {
+		t.Fatalf("Failed to create unlocking script : %s", err)
+	}
+
+	t.Logf("Unlocking Script : %s", unlockingScript)
+
+	if len(unlockingScript) > multiSig.UnlockingScriptSize() {
+		t.Fatalf("Unlocking script larger than estimate : got %d, max %d", len(unlockingScript),
+			multiSig.UnlockingScriptSize())
+	}
+
+	if len(multiSig.UnlockingScriptTemplate()) != multiSig.UnlockingScriptSize() {
+		t.Fatalf("Wrong unlocking script template size : got %d, want %d",
+			len(multiSig.UnlockingScriptTemplate()), multiSig.UnlockingScriptSize())
+	}
+
+	if _, err := multiSig.UnlockingScript(sigs[:2]); errors.Cause(err) != ErrInvalidMultiSig {
+		t.Fatalf("Wrong error for missing signature entries : got %v, want %s", err,
+			ErrInvalidMultiSig)
+	}
+
+	sigs[1] = sigs[0]
+	if _, err := multiSig.UnlockingScript(sigs); errors.Cause(err) != ErrInvalidMultiSig {
+		t.Fatalf("Wrong error for extra signature : got %v, want %s", err, ErrInvalidMultiSig)
+	}
+}
+
+func Test_MultiSigInvalid(t *testing.T) {
+	key, err := GenerateKey(TestNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	tests := []struct {
+		required uint32
+		total    int
+	}{
+		{0, 1},
+		{2, 1},
+		{1, 0},
+		{1, 17},
+	}
+
+	for _, tt := range tests {
+		publicKeys := make([]PublicKey, tt.total)
+		for i := range publicKeys {
+			publicKeys[i] = key.PublicKey()
+		}
+
+		if _, err := NewMultiSig(tt.required, publicKeys); errors.Cause(err) != ErrInvalidMultiSig {
+			t.Fatalf("Wrong error for %d of %d : got %v, want %s", tt.required, tt.total, err,
+				ErrInvalidMultiSig)
+		}
+	}
+
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	if _, err := ParseMultiSig(lockingScript); errors.Cause(err) != ErrWrongScriptTemplate {
+		t.Fatalf("Wrong error parsing P2PKH : got %v, want %s", err, ErrWrongScriptTemplate)
+	}
+}
@@ -43,6 +43,10 @@ func NetworkFromString(name string) Network {
 		return RegTestNet
 	}
 
+	if params, exists := LookupNetworkByName(name); exists {
+		return params.Net
+	}
+
 	return InvalidNet
 }
 
@@ -60,6 +64,10 @@ func NetworkName(net Network) string {
 		return "invalid"
 	}
 
+	if params, exists := LookupNetwork(net); exists {
+		return params.Name
+	}
+
 	return "testnet"
 }
 
@@ -141,4 +149,17 @@ func init() {
 	if err := chaincfg.Register(&RegTestNetParams); err != nil {
 		fmt.Printf("WARNING failed to register RegTestNetParams")
 	}
+
+	// Register the built in networks so they can be looked up with the custom networks.
+	builtIn := []NetworkParams{
+		{"mainnet", MainNet, 8333, AddressTypeMainPKH, AddressTypeMainSH, typeMainPrivKey},
+		{"testnet", TestNet, 18333, AddressTypeTestPKH, AddressTypeTestSH, typeTestPrivKey},
+		{"stn", StressTestNet, 9333, AddressTypeTestPKH, AddressTypeTestSH, typeTestPrivKey},
+		{"regtest", RegTestNet, 18444, AddressTypeTestPKH, AddressTypeTestSH, typeTestPrivKey},
+	}
+	for _, params := range builtIn {
+		if err := RegisterNetwork(params); err != nil {
+			fmt.Printf("WARNING failed to register network %s : %s", params.Name, err)
+		}
+	}
 }
@@ -0,0 +1,246 @@
+package bitcoin
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// NetworkParams contains the values that differ between bitcoin networks.
+type NetworkParams struct {
+	Name        string  // Name used in configuration, like "mainnet".
+	Net         Network // Magic bytes at the start of P2P messages.
+	DefaultPort uint16  // Default P2P port.
+
+	PKHAddressType byte // Prefix for P2PKH addresses.
+	SHAddressType  byte // Prefix for P2SH addresses.
+	PrivateKeyType byte // Prefix for WIF private keys.
+}
+
+var (
+	ErrNetworkRegistered = errors.New("Network already registered")
+
+	networksLock sync.RWMutex
+	networks     = make(map[Network]*NetworkParams)
+	networkNames = make(map[string]*NetworkParams)
+	networkOrder []*NetworkParams
+)
+
+// RegisterNetwork adds the parameters of a network so that it can be used by address and key
+//   encoding and by the wire package. Networks may share the main net or test net prefixes, but a
+//   custom prefix can only be used by one network. The PKH and SH prefixes must both be the main
+//   net prefixes, both be the test net prefixes, or both be custom and different from each other so
+//   that an address decodes to one network and script type.
+func RegisterNetwork(params NetworkParams) error {
+	if len(params.Name) == 0 {
+		return errors.New("Missing network name")
+	}
+	if params.Net == InvalidNet {
+		return errors.New("Invalid network magic")
+	}
+
+	networksLock.Lock()
+	defer networksLock.Unlock()
+
+	if _, exists := networks[params.Net]; exists {
+		return errors.Wrapf(ErrNetworkRegistered, "net %08x", uint32(params.Net))
+	}
+	if _, exists := networkNames[params.Name]; exists {
+		return errors.Wrapf(ErrNetworkRegistered, "name %s", params.Name)
+	}
+
+	if params.PKHAddressType == params.SHAddressType {
+		return fmt.Errorf("PKH and SH address types are both %02x", params.PKHAddressType)
+	}
+	if isStandardPKHType(params.PKHAddressType) || isStandardSHType(params.SHAddressType) {
+		if !isStandardAddressPair(params.PKHAddressType, params.SHAddressType) {
+			return fmt.Errorf("PKH address type %02x doesn't match SH address type %02x",
+				params.PKHAddressType, params.SHAddressType)
+		}
+	}
+
+	if !isStandardPKHType(params.PKHAddressType) && isBuiltinAddressType(params.PKHAddressType) {
+		return fmt.Errorf("PKH address type %02x is reserved", params.PKHAddressType)
+	}
+	if !isStandardSHType(params.SHAddressType) && isBuiltinAddressType(params.SHAddressType) {
+		return fmt.Errorf("SH address type %02x is reserved", params.SHAddressType)
+	}
+
+	for _, other := range networkOrder {
+		if !isStandardPKHType(params.PKHAddressType) &&
+			(other.PKHAddressType == params.PKHAddressType ||
+				other.SHAddressType == params.PKHAddressType) {
+			return fmt.Errorf("PKH address type %02x already used by %s", params.PKHAddressType,
+				other.Name)
+		}
+		if !isStandardSHType(params.SHAddressType) &&
+			(other.SHAddressType == params.SHAddressType ||
+				other.PKHAddressType == params.SHAddressType) {
+			return fmt.Errorf("SH address type %02x already used by %s", params.SHAddressType,
+				other.Name)
+		}
+		if !isStandardPrivateKeyType(params.PrivateKeyType) &&
+			other.PrivateKeyType == params.PrivateKeyType {
+			return fmt.Errorf("Private key type %02x already used by %s", params.PrivateKeyType,
+				other.Name)
+		}
+	}
+
+	p := params
+	networks[p.Net] = &p
+	networkNames[p.Name] = &p
+	networkOrder = append(networkOrder, &p)
+	return nil
+}
+
+// LookupNetwork returns the parameters of a registered network.
+func LookupNetwork(net Network) (NetworkParams, bool) {
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	params, exists := networks[net]
+	if !exists {
+		return NetworkParams{}, false
+	}
+	return *params, true
+}
+
+// LookupNetworkByName returns the parameters of a registered network with the specified name.
+func LookupNetworkByName(name string) (NetworkParams, bool) {
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	params, exists := networkNames[name]
+	if !exists {
+		return NetworkParams{}, false
+	}
+	return *params, true
+}
+
+// Networks returns the parameters of all registered networks in the order they were registered.
+func Networks() []NetworkParams {
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	result := make([]NetworkParams, len(networkOrder))
+	for i, params := range networkOrder {
+		result[i] = *params
+	}
+	return result
+}
+
+// DefaultPort returns the default P2P port of the network, or zero if the network isn't
+//   registered.
+func (n Network) DefaultPort() uint16 {
+	params, exists := LookupNetwork(n)
+	if !exists {
+		return 0
+	}
+	return params.DefaultPort
+}
+
+// networkForAddressType returns the custom network that uses the address type. Only address types
+//   that are not the standard main net or test net types are matched.
+func networkForAddressType(addressType byte) (*NetworkParams, bool) {
+	if isStandardPKHType(addressType) || isStandardSHType(addressType) {
+		return nil, false
+	}
+
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	for _, params := range networkOrder {
+		if params.PKHAddressType == addressType || params.SHAddressType == addressType {
+			return params, true
+		}
+	}
+
+	return nil, false
+}
+
+// networkForPrivateKeyType returns the network that uses the private key type.
+func networkForPrivateKeyType(keyType byte) (Network, bool) {
+	switch keyType {
+	case typeMainPrivKey:
+		return MainNet, true
+	case typeTestPrivKey:
+		return TestNet, true
+	}
+
+	networksLock.RLock()
+	defer networksLock.RUnlock()
+
+	for _, params := range networkOrder {
+		if params.PrivateKeyType == keyType {
+			return params.Net, true
+		}
+	}
+
+	return InvalidNet, false
+}
+
+// pkhAddressType returns the P2PKH address prefix for the network. Unregistered networks use the
+//   test net prefix.
+func pkhAddressType(net Network) byte {
+	if net == MainNet {
+		return AddressTypeMainPKH
+	}
+	if params, exists := LookupNetwork(net); exists {
+		return params.PKHAddressType
+	}
+	return AddressTypeTestPKH
+}
+
+// shAddressType returns the P2SH address prefix for the network. Unregistered networks use the
+//   test net prefix.
+func shAddressType(net Network) byte {
+	if net == MainNet {
+		return AddressTypeMainSH
+	}
+	if params, exists := LookupNetwork(net); exists {
+		return params.SHAddressType
+	}
+	return AddressTypeTestSH
+}
+
+// privateKeyType returns the WIF private key prefix for the network. Unregistered networks use
+//   the test net prefix.
+func privateKeyType(net Network) byte {
+	if net == MainNet {
+		return typeMainPrivKey
+	}
+	if params, exists := LookupNetwork(net); exists {
+		return params.PrivateKeyType
+	}
+	return typeTestPrivKey
+}
+
+func isStandardPKHType(t byte) bool {
+	return t == AddressTypeMainPKH || t == AddressTypeTestPKH
+}
+
+func isStandardSHType(t byte) bool {
+	return t == AddressTypeMainSH || t == AddressTypeTestSH
+}
+
+// isStandardAddressPair returns true if the PKH and SH address types are the main net types or the
+//   test net types.
+func isStandardAddressPair(pkh, sh byte) bool {
+	return (pkh == AddressTypeMainPKH && sh == AddressTypeMainSH) ||
+		(pkh == AddressTypeTestPKH && sh == AddressTypeTestSH)
+}
+
+func isBuiltinAddressType(t byte) bool {
+	switch t {
+	case AddressTypeMainPKH, AddressTypeMainSH, AddressTypeMainMultiPKH, AddressTypeMainRPH,
+		AddressTypeMainPK, AddressTypeMainNonStandard, AddressTypeTestPKH, AddressTypeTestSH,
+		AddressTypeTestMultiPKH, AddressTypeTestRPH, AddressTypeTestPK, AddressTypeTestNonStandard:
+		return true
+	}
+	return false
+}
+
+func isStandardPrivateKeyType(t byte) bool {
+	return t == typeMainPrivKey || t == typeTestPrivKey
+}
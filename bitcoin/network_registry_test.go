@@ -0,0 +1,163 @@
+package bitcoin
+
+import (
+	"testing"
+)
+
+func TestRegisterNetwork(t *testing.T) {
+	custom := NetworkParams{
+		Name:           "customnet",
+		Net:            Network(0x01020304),
+		DefaultPort:    28333,
+		PKHAddressType: 0x1c,
+		SHAddressType:  0x1d,
+		PrivateKeyType: 0x9e,
+	}
+
+	if err := RegisterNetwork(custom); err != nil {
+		t.Fatalf("Failed to register network : %s", err)
+	}
+
+	if err := RegisterNetwork(custom); err == nil {
+		t.Fatalf("Duplicate registration should fail")
+	}
+
+	reserved := custom
+	reserved.Name = "reservednet"
+	reserved.Net = Network(0x01020305)
+	reserved.PKHAddressType = AddressTypeMainSH
+	if err := RegisterNetwork(reserved); err == nil {
+		t.Fatalf("Registration with reserved address type should fail")
+	}
+
+	if NetworkFromString("customnet") != custom.Net {
+		t.Fatalf("Wrong network from name : %s", NetworkFromString("customnet"))
+	}
+
+	if custom.Net.String() != "customnet" {
+		t.Fatalf("Wrong network name : %s", custom.Net.String())
+	}
+
+	if custom.Net.DefaultPort() != 28333 {
+		t.Fatalf("Wrong default port : %d", custom.Net.DefaultPort())
+	}
+
+	if MainNet.DefaultPort() != 8333 {
+		t.Fatalf("Wrong main net default port : %d", MainNet.DefaultPort())
+	}
+
+	key, err := GenerateKey(custom.Net)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	decodedKey, err := KeyFromStr(key.String())
+	if err != nil {
+		t.Fatalf("Failed to decode key : %s", err)
+	}
+
+	if !decodedKey.Equal(key) {
+		t.Fatalf("Wrong decoded key : \ngot  %s\nwant %s", decodedKey, key)
+	}
+
+	ra, err := key.RawAddress()
+	if err != nil {
+		t.Fatalf("Failed to create raw address : %s", err)
+	}
+
+	address := NewAddressFromRawAddress(ra, custom.Net)
+	if address.Type() != custom.PKHAddressType {
+		t.Fatalf("Wrong address type : got %02x, want %02x", address.Type(),
+			custom.PKHAddressType)
+	}
+
+	decoded, err := DecodeAddress(address.String())
+	if err != nil {
+		t.Fatalf("Failed to decode address : %s", err)
+	}
+
+	if decoded.Network() != custom.Net {
+		t.Fatalf("Wrong address network : %s", decoded.Network())
+	}
+
+	if !DecodeNetMatches(decoded.Network(), custom.Net) {
+		t.Fatalf("Decoded network should match")
+	}
+
+	if DecodeNetMatches(decoded.Network(), TestNet) {
+		t.Fatalf("Decoded network should not match test net")
+	}
+
+	if !NewRawAddressFromAddress(decoded).Equal(ra) {
+		t.Fatalf("Wrong raw address from decoded address")
+	}
+}
+
+func TestRegisterNetworkAddressTypeCollisions(t *testing.T) {
+	existing := NetworkParams{
+		Name:           "collisionnet",
+		Net:            Network(0x01020310),
+		PKHAddressType: 0x2c,
+		SHAddressType:  0x2d,
+		PrivateKeyType: 0xae,
+	}
+
+	if err := RegisterNetwork(existing); err != nil {
+		t.Fatalf("Failed to register network : %s", err)
+	}
+
+	tests := []struct {
+		name string
+		pkh  byte
+		sh   byte
+	}{
+		{"same_types", 0x3c, 0x3c},
+		{"pkh_is_other_sh", existing.SHAddressType, 0x3d},
+		{"sh_is_other_pkh", 0x3c, existing.PKHAddressType},
+		{"main_pkh_custom_sh", AddressTypeMainPKH, 0x3d},
+		{"custom_pkh_test_sh", 0x3c, AddressTypeTestSH},
+		{"main_pkh_test_sh", AddressTypeMainPKH, AddressTypeTestSH},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := NetworkParams{
+				Name:           "collision_" + tt.name,
+				Net:            Network(0x01020320 + uint32(i)),
+				PKHAddressType: tt.pkh,
+				SHAddressType:  tt.sh,
+				PrivateKeyType: 0xb0 + byte(i),
+			}
+
+			if err := RegisterNetwork(params); err == nil {
+				t.Fatalf("Registration with address types %02x %02x should fail", tt.pkh, tt.sh)
+			}
+		})
+	}
+
+	shared := NetworkParams{
+		Name:           "sharedtestnet",
+		Net:            Network(0x01020330),
+		PKHAddressType: AddressTypeTestPKH,
+		SHAddressType:  AddressTypeTestSH,
+		PrivateKeyType: typeTestPrivKey,
+	}
+	if err := RegisterNetwork(shared); err != nil {
+		t.Fatalf("Failed to register network with test net address types : %s", err)
+	}
+}
+
+func TestDecodeNetMatchesInvalidNet(t *testing.T) {
+	for _, net := range []Network{InvalidNet, MainNet, TestNet, RegTestNet} {
+		if DecodeNetMatches(InvalidNet, net) {
+			t.Errorf("Decoded invalid net should not match %s", net)
+		}
+		if DecodeNetMatches(net, InvalidNet) {
+			t.Errorf("Decoded %s should not match invalid net", net)
+		}
+	}
+
+	if !DecodeNetMatches(TestNet, RegTestNet) {
+		t.Errorf("Decoded test net should match regtest")
+	}
+}
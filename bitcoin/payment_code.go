@@ -0,0 +1,206 @@
+package bitcoin
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Payment codes allow a sender to derive a new address for every payment to a recipient without
+//   contacting the recipient. This is similar to BIP-0047.
+//
+// The recipient publishes a payment code containing the public key and chain code of an extended
+//   key. For each payment the sender derives the recipient's child public key at the payment
+//   index, calculates an ECDH shared secret with its own key, and adds the hash of the secret to
+//   the child public key. The recipient derives the matching private key from its child key and
+//   the sender's public key.
+//
+//   // Recipient
+//   code, err := NewPaymentCode(recipientExtendedKey)
+//
+//   // Sender
+//   publicKey, err := PaymentPublicKey(senderKey, code, index)
+//
+//   // Recipient
+//   key, err := PaymentKey(recipientExtendedKey, senderKey.PublicKey(), index, MainNet)
+
+const (
+	paymentCodeType    = 0x47
+	paymentCodeVersion = 0x01
+
+	// PaymentCodeLength is the length of a serialized payment code.
+	// type (1) + version (1) + public key (33) + chain code (32)
+	PaymentCodeLength = 67
+)
+
+var (
+	ErrNotPaymentCode = errors.New("Data not a payment code")
+)
+
+// PaymentCode is a reusable code that can be given to senders so they can derive payment keys.
+type PaymentCode struct {
+	PublicKey PublicKey
+	ChainCode [32]byte
+}
+
+// NewPaymentCode creates a payment code from an extended key.
+func NewPaymentCode(key ExtendedKey) (PaymentCode, error) {
+	if key.IsEmpty() {
+		return PaymentCode{}, errors.New("Empty extended key")
+	}
+
+	return PaymentCode{
+		PublicKey: key.PublicKey(),
+		ChainCode: key.ChainCode,
+	}, nil
+}
+
+// PaymentCodeFromStr decodes a base58 encoded payment code.
+func PaymentCodeFromStr(s string) (PaymentCode, error) {
+	b, err := decodeAddress(s)
+	if err != nil {
+		return PaymentCode{}, err
+	}
+
+	return PaymentCodeFromBytes(b)
+}
+
+// PaymentCodeFromBytes decodes a binary payment code.
+func PaymentCodeFromBytes(b []byte) (PaymentCode, error) {
+	if len(b) != PaymentCodeLength {
+		return PaymentCode{}, errors.Wrapf(ErrWrongSize, "got %d, want %d", len(b),
+			PaymentCodeLength)
+	}
+
+	if b[0] != paymentCodeType {
+		return PaymentCode{}, ErrNotPaymentCode
+	}
+
+	if b[1] != paymentCodeVersion {
+		return PaymentCode{}, fmt.Errorf("Unsupported payment code version : %d", b[1])
+	}
+
+	publicKey, err := PublicKeyFromBytes(b[2:35])
+	if err != nil {
+		return PaymentCode{}, errors.Wrap(err, "public key")
+	}
+
+	result := PaymentCode{PublicKey: publicKey}
+	copy(result.ChainCode[:], b[35:])
+	return result, nil
+}
+
+// Bytes returns the binary encoding of the payment code.
+func (pc PaymentCode) Bytes() []byte {
+	b := make([]byte, 0, PaymentCodeLength)
+	b = append(b, paymentCodeType, paymentCodeVersion)
+	b = append(b, pc.PublicKey.Bytes()...)
+	return append(b, pc.ChainCode[:]...)
+}
+
+// String returns the payment code with a checksum, encoded with Base58.
+func (pc PaymentCode) String() string {
+	return encodeAddress(pc.Bytes())
+}
+
+// SetString decodes a base58 encoded payment code.
+func (pc *PaymentCode) SetString(s string) error {
+	npc, err := PaymentCodeFromStr(s)
+	if err != nil {
+		return err
+	}
+
+	*pc = npc
+	return nil
+}
+
+// Equal returns true if the payment codes are the same.
+func (pc PaymentCode) Equal(other PaymentCode) bool {
+	return pc.PublicKey.Equal(other.PublicKey) && pc.ChainCode == other.ChainCode
+}
+
+// ExtendedKey returns the public extended key that the payment code represents.
+func (pc PaymentCode) ExtendedKey() ExtendedKey {
+	result := ExtendedKey{ChainCode: pc.ChainCode}
+	copy(result.KeyValue[:], pc.PublicKey.Bytes())
+	return result
+}
+
+// MarshalText returns the text encoding of the payment code.
+// Implements encoding.TextMarshaler interface.
+func (pc PaymentCode) MarshalText() ([]byte, error) {
+	return []byte(pc.String()), nil
+}
+
+// UnmarshalText parses a text encoded payment code and sets the value of this object.
+// Implements encoding.TextUnmarshaler interface.
+func (pc *PaymentCode) UnmarshalText(text []byte) error {
+	return pc.SetString(string(text))
+}
+
+// PaymentPublicKey returns the public key for the payment at the index that the sender is making
+//   to the recipient's payment code.
+func PaymentPublicKey(sender Key, recipient PaymentCode, index uint32) (PublicKey, error) {
+	if index >= Hardened {
+		return PublicKey{}, errors.New("Payment index can't be hardened")
+	}
+
+	child, err := recipient.ExtendedKey().ChildKey(index)
+	if err != nil {
+		return PublicKey{}, errors.Wrap(err, "child key")
+	}
+	childPublicKey := child.PublicKey()
+
+	secret, err := paymentSecret(sender, childPublicKey)
+	if err != nil {
+		return PublicKey{}, err
+	}
+
+	return NextPublicKey(childPublicKey, secret)
+}
+
+// PaymentKey returns the private key for the payment at the index that was made by the sender to
+//   the payment code of the recipient's extended key.
+func PaymentKey(recipient ExtendedKey, sender PublicKey, index uint32,
+	net Network) (Key, error) {
+
+	if !recipient.IsPrivate() {
+		return Key{}, errors.New("Recipient extended key not private")
+	}
+	if index >= Hardened {
+		return Key{}, errors.New("Payment index can't be hardened")
+	}
+
+	child, err := recipient.ChildKey(index)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "child key")
+	}
+	childKey := child.Key(net)
+
+	secret, err := paymentSecret(childKey, sender)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return NextKey(childKey, secret)
+}
+
+// paymentSecret returns the hash of the ECDH shared secret between the key and public key.
+func paymentSecret(key Key, publicKey PublicKey) (Hash32, error) {
+	secret, err := ECDHSecret(key, publicKey)
+	if err != nil {
+		return Hash32{}, errors.Wrap(err, "ecdh")
+	}
+
+	// Pad to 32 bytes so the hash doesn't depend on leading zeros.
+	if len(secret) < 32 {
+		secret = append(make([]byte, 32-len(secret)), secret...)
+	}
+
+	hash, err := NewHash32(Sha256(secret))
+	if err != nil {
+		return Hash32{}, err
+	}
+
+	return *hash, nil
+}
@@ -0,0 +1,58 @@
+package bitcoin
+
+import (
+	"testing"
+)
+
+func TestPaymentCode(t *testing.T) {
+	recipient, err := GenerateMasterExtendedKey()
+	if err != nil {
+		t.Fatalf("Failed to generate extended key : %s", err)
+	}
+
+	sender, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	code, err := NewPaymentCode(recipient)
+	if err != nil {
+		t.Fatalf("Failed to create payment code : %s", err)
+	}
+
+	decoded, err := PaymentCodeFromStr(code.String())
+	if err != nil {
+		t.Fatalf("Failed to decode payment code : %s", err)
+	}
+
+	if !decoded.Equal(code) {
+		t.Fatalf("Wrong decoded payment code : \ngot  %s\nwant %s", decoded, code)
+	}
+
+	var previous PublicKey
+	for index := uint32(0); index < 5; index++ {
+		publicKey, err := PaymentPublicKey(sender, decoded, index)
+		if err != nil {
+			t.Fatalf("Failed to derive payment public key : %s", err)
+		}
+
+		key, err := PaymentKey(recipient, sender.PublicKey(), index, MainNet)
+		if err != nil {
+			t.Fatalf("Failed to derive payment key : %s", err)
+		}
+
+		if !key.PublicKey().Equal(publicKey) {
+			t.Fatalf("Payment keys don't match : \ngot  %s\nwant %s", key.PublicKey(), publicKey)
+		}
+
+		if publicKey.Equal(previous) {
+			t.Fatalf("Payment key should be different for each index")
+		}
+		previous = publicKey
+	}
+
+	if _, err := PaymentKey(recipient.ExtendedPublicKey(), sender.PublicKey(), 0,
+		MainNet); err == nil {
+		t.Fatalf("Payment key from public extended key should fail")
+	}
+}
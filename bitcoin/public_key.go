@@ -1,6 +1,7 @@
 package bitcoin
 
 import (
+	"database/sql/driver"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -126,6 +127,14 @@ func (k PublicKey) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON converts from json.
 func (k *PublicKey) UnmarshalJSON(data []byte) error {
+	if isJSONEmpty(data) {
+		*k = PublicKey{}
+		return nil
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("Too short for PublicKey data : %d", len(data))
+	}
+
 	return k.SetString(string(data[1 : len(data)-1]))
 }
 
@@ -162,8 +171,22 @@ func (k *PublicKey) UnmarshalBinary(data []byte) error {
 	return k.SetBytes(data)
 }
 
+// Value returns the value to store in a database column.
+// Implements driver.Valuer interface.
+func (k PublicKey) Value() (driver.Value, error) {
+	if k.IsEmpty() {
+		return nil, nil
+	}
+	return k.Bytes(), nil
+}
+
 // Scan converts from a database column.
 func (k *PublicKey) Scan(data interface{}) error {
+	if data == nil {
+		*k = PublicKey{}
+		return nil
+	}
+
 	b, ok := data.([]byte)
 	if !ok {
 		return errors.New("Public Key db column not bytes")
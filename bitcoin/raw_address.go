@@ -244,6 +244,14 @@ func NewRawAddressFromAddress(a Address) RawAddress {
 		fallthrough
 	case AddressTypeTestRPH:
 		result.scriptType = ScriptTypeRPH
+	default:
+		if params, exists := networkForAddressType(a.addressType); exists {
+			if a.addressType == params.PKHAddressType {
+				result.scriptType = ScriptTypePKH
+			} else {
+				result.scriptType = ScriptTypeSH
+			}
+		}
 	}
 
 	return result
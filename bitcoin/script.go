@@ -58,6 +58,7 @@ const (
 	OP_HASH160            = byte(0xa9)
 	OP_CHECKSIG           = byte(0xac)
 	OP_CHECKSIGVERIFY     = byte(0xad)
+	OP_CHECKMULTISIG      = byte(0xae)
 
 	OP_PUSH_DATA_20 = byte(0x14)
 	OP_PUSH_DATA_33 = byte(0x21)
@@ -121,6 +122,7 @@ var (
 		OP_GREATERTHANOREQUAL: "OP_GREATERTHANOREQUAL",
 		OP_CHECKSIG:           "OP_CHECKSIG",
 		OP_CHECKSIGVERIFY:     "OP_CHECKSIGVERIFY",
+		OP_CHECKMULTISIG:      "OP_CHECKMULTISIG",
 		OP_IF:                 "OP_IF",
 		OP_ENDIF:              "OP_ENDIF",
 		OP_TOALTSTACK:         "OP_TOALTSTACK",
@@ -164,6 +166,7 @@ var (
 		"OP_GREATERTHANOREQUAL": OP_GREATERTHANOREQUAL,
 		"OP_CHECKSIG":           OP_CHECKSIG,
 		"OP_CHECKSIGVERIFY":     OP_CHECKSIGVERIFY,
+		"OP_CHECKMULTISIG":      OP_CHECKMULTISIG,
 		"OP_IF":                 OP_IF,
 		"OP_ENDIF":              OP_ENDIF,
 		"OP_TOALTSTACK":         OP_TOALTSTACK,
@@ -425,6 +428,68 @@ func (s Script) MultiPKHCounts() (uint32, uint32, error) {
 	return uint32(requiredSigners), total, nil
 }
 
+// MultiSigCounts returns the number of required signatures and total signers for a bare multi-sig
+// locking script (OP_m <public key>... OP_n OP_CHECKMULTISIG). It returns the error
+// ErrWrongScriptTemplate if the locking script doesn't match the template.
+//
+// Returns:
+// Required Signatures Count
+// Total Signers Count
+func (s Script) MultiSigCounts() (uint32, uint32, error) {
+	r := bytes.NewReader(s)
+
+	item, err := ParseScript(r)
+	if err != nil {
+		return 0, 0, errors.Wrap(ErrWrongScriptTemplate, err.Error())
+	}
+	if item.Type != ScriptItemTypeOpCode || item.OpCode < OP_1 || item.OpCode > OP_16 {
+		return 0, 0, errors.Wrap(ErrWrongScriptTemplate, "required signers not OP_1 - OP_16")
+	}
+	required := uint32(item.OpCode-OP_1) + 1
+
+	total := uint32(0)
+	for {
+		item, err := ParseScript(r)
+		if err != nil {
+			return 0, 0, errors.Wrap(ErrWrongScriptTemplate, err.Error())
+		}
+
+		if item.Type == ScriptItemTypeOpCode {
+			if item.OpCode < OP_1 || item.OpCode > OP_16 {
+				return 0, 0, errors.Wrap(ErrWrongScriptTemplate,
+					"total signers not OP_1 - OP_16")
+			}
+
+			if uint32(item.OpCode-OP_1)+1 != total {
+				return 0, 0, errors.Wrapf(ErrWrongScriptTemplate,
+					"wrong total signers: got %d, want %d", uint32(item.OpCode-OP_1)+1, total)
+			}
+			break
+		}
+
+		if len(item.Data) != PublicKeyCompressedLength {
+			return 0, 0, errors.Wrapf(ErrWrongScriptTemplate, "wrong public key size: %d",
+				len(item.Data))
+		}
+		total++
+	}
+
+	if required > total {
+		return 0, 0, errors.Wrapf(ErrWrongScriptTemplate, "required %d more than total %d",
+			required, total)
+	}
+
+	if err := CheckOpCode(r, OP_CHECKMULTISIG); err != nil {
+		return 0, 0, errors.Wrap(ErrWrongScriptTemplate, err.Error())
+	}
+
+	if _, err := ParseScript(r); errors.Cause(err) != io.EOF {
+		return 0, 0, errors.Wrap(ErrWrongScriptTemplate, "not end of script")
+	}
+
+	return required, total, nil
+}
+
 func (s Script) Equal(r Script) bool {
 	return bytes.Equal(s, r)
 }
@@ -523,9 +588,9 @@ func (s *Script) Scan(data interface{}) error {
 func PushDataScriptSize(size uint64) []byte {
 	if size <= uint64(OP_MAX_SINGLE_BYTE_PUSH_DATA) {
 		return []byte{byte(size)} // Single byte push
-	} else if size < OP_PUSH_DATA_1_MAX {
+	} else if size <= OP_PUSH_DATA_1_MAX {
 		return []byte{OP_PUSH_DATA_1, byte(size)}
-	} else if size < OP_PUSH_DATA_2_MAX {
+	} else if size <= OP_PUSH_DATA_2_MAX {
 		var buf bytes.Buffer
 		binary.Write(&buf, endian, OP_PUSH_DATA_2)
 		binary.Write(&buf, endian, uint16(size))
@@ -544,9 +609,9 @@ func WritePushDataScript(buf *bytes.Buffer, data []byte) error {
 	var err error
 	if size <= int(OP_MAX_SINGLE_BYTE_PUSH_DATA) {
 		_, err = buf.Write([]byte{byte(size)}) // Single byte push
-	} else if size < int(OP_PUSH_DATA_1_MAX) {
+	} else if size <= int(OP_PUSH_DATA_1_MAX) {
 		_, err = buf.Write([]byte{OP_PUSH_DATA_1, byte(size)})
-	} else if size < int(OP_PUSH_DATA_2_MAX) {
+	} else if size <= int(OP_PUSH_DATA_2_MAX) {
 		_, err = buf.Write([]byte{OP_PUSH_DATA_2})
 		if err != nil {
 			return err
@@ -800,38 +865,7 @@ func PushNumberScript(n int64) []byte {
 		return []byte{0x50 + byte(n)}
 	}
 
-	// Take the absolute value and keep track of whether it was originally
-	// negative.
-	isNegative := n < 0
-	if isNegative {
-		n = -n
-	}
-
-	// Encode to little endian.  The maximum number of encoded bytes is 9
-	// (8 bytes for max int64 plus a potential byte for sign extension).
-	result := make([]byte, 0, 10)
-	for n > 0 {
-		result = append(result, byte(n&0xff))
-		n >>= 8
-	}
-
-	// When the most significant byte already has the high bit set, an
-	// additional high byte is required to indicate whether the number is
-	// negative or positive.  The additional byte is removed when converting
-	// back to an integral and its high bit is used to denote the sign.
-	//
-	// Otherwise, when the most significant byte does not already have the
-	// high bit set, use it to indicate the value is negative, if needed.
-	if result[len(result)-1]&0x80 != 0 {
-		extraByte := byte(0x00)
-		if isNegative {
-			extraByte = 0x80
-		}
-		result = append(result, extraByte)
-
-	} else if isNegative {
-		result[len(result)-1] |= 0x80
-	}
+	result := EncodeScriptNumber(n)
 
 	// Push this value onto the stack (single byte push op)
 	return append([]byte{byte(len(result))}, result...)
@@ -881,18 +915,28 @@ func ParsePushNumberScript(b []byte) (int64, int, error) {
 		return int64(b[0] - 0x50), 1, nil
 	}
 
-	if b[0] > 10 {
+	if b[0] > 9 {
 		return 0, 0, errors.New("Invalid push op for number")
 	}
 
 	length := int(b[0])
-	b = b[1:]
+	if len(b) < length+1 {
+		return 0, 0, errors.New("Push data size past end of script")
+	}
 
-	// Decode from little endian.
-	return DecodeScriptLittleEndian(b), length, nil
+	value, err := DecodeScriptNumber(b[1:length+1], false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return value, length + 1, nil
 }
 
 func DecodeScriptLittleEndian(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+
 	var result int64
 	for i, val := range b {
 		result |= int64(val) << uint8(8*i)
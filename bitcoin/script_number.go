@@ -0,0 +1,195 @@
+package bitcoin
+
+import (
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNonMinimalEncoding means a script number or push is not encoded in the shortest form.
+	ErrNonMinimalEncoding = errors.New("Non Minimal Encoding")
+
+	// ErrScriptNumberOverflow means a script number is too large to decode to an int64. Use
+	//   DecodeScriptBigNumber for post-Genesis big numbers.
+	ErrScriptNumberOverflow = errors.New("Script Number Overflow")
+)
+
+// EncodeScriptNumber returns the minimal script number encoding of n. It is little endian with the
+//   sign in the high bit of the last byte. Zero is an empty byte slice.
+func EncodeScriptNumber(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	isNegative := n < 0
+	magnitude := uint64(n)
+	if isNegative {
+		magnitude = uint64(-(n + 1)) + 1 // avoid overflow of -math.MinInt64
+	}
+
+	result := make([]byte, 0, 9)
+	for magnitude > 0 {
+		result = append(result, byte(magnitude&0xff))
+		magnitude >>= 8
+	}
+
+	return setScriptNumberSign(result, isNegative)
+}
+
+// DecodeScriptNumber decodes a script number. It returns ErrScriptNumberOverflow if the number
+//   doesn't fit in an int64 and, when requireMinimal is true, ErrNonMinimalEncoding if the number
+//   isn't minimally encoded.
+func DecodeScriptNumber(b []byte, requireMinimal bool) (int64, error) {
+	n, err := DecodeScriptBigNumber(b, requireMinimal)
+	if err != nil {
+		return 0, err
+	}
+
+	if !n.IsInt64() {
+		return 0, errors.Wrapf(ErrScriptNumberOverflow, "%x", b)
+	}
+
+	return n.Int64(), nil
+}
+
+// EncodeScriptBigNumber returns the minimal script number encoding of a number of any size. Post
+//   Genesis scripts can do arithmetic on numbers larger than 64 bits.
+func EncodeScriptBigNumber(n *big.Int) []byte {
+	if n.Sign() == 0 {
+		return nil
+	}
+
+	bigEndian := new(big.Int).Abs(n).Bytes()
+	result := make([]byte, len(bigEndian), len(bigEndian)+1)
+	for i, b := range bigEndian {
+		result[len(bigEndian)-1-i] = b
+	}
+
+	return setScriptNumberSign(result, n.Sign() < 0)
+}
+
+// DecodeScriptBigNumber decodes a script number of any size. When requireMinimal is true it
+//   returns ErrNonMinimalEncoding if the number isn't minimally encoded.
+func DecodeScriptBigNumber(b []byte, requireMinimal bool) (*big.Int, error) {
+	if requireMinimal && !IsMinimalScriptNumber(b) {
+		return nil, errors.Wrapf(ErrNonMinimalEncoding, "%x", b)
+	}
+
+	if len(b) == 0 {
+		return new(big.Int), nil
+	}
+
+	bigEndian := make([]byte, len(b))
+	for i, v := range b {
+		bigEndian[len(b)-1-i] = v
+	}
+
+	isNegative := bigEndian[0]&0x80 != 0
+	bigEndian[0] &= 0x7f // remove sign bit
+
+	result := new(big.Int).SetBytes(bigEndian)
+	if isNegative {
+		result.Neg(result)
+	}
+	return result, nil
+}
+
+// IsMinimalScriptNumber returns true if the script number is encoded with no unnecessary bytes.
+//   The last byte can only be 0x00 or 0x80 if the previous byte has its high bit set.
+func IsMinimalScriptNumber(b []byte) bool {
+	if len(b) == 0 {
+		return true
+	}
+
+	if b[len(b)-1]&0x7f != 0 {
+		return true
+	}
+
+	return len(b) > 1 && b[len(b)-2]&0x80 != 0
+}
+
+// PushBigNumberScript returns a section of script that will push the number onto the stack with
+//   the smallest encoding.
+func PushBigNumberScript(n *big.Int) []byte {
+	if n.IsInt64() {
+		return PushNumberScript(n.Int64())
+	}
+
+	return MinimalPushDataScript(EncodeScriptBigNumber(n))
+}
+
+// MinimalPushDataScript returns a script that pushes the data with the smallest encoding. Empty
+//   data is OP_0, single bytes 1 through 16 and 0x81 use OP_1 through OP_16 and OP_1NEGATE, and
+//   other data uses the smallest push op that fits its size.
+func MinimalPushDataScript(data []byte) []byte {
+	if len(data) == 0 {
+		return []byte{OP_0}
+	}
+
+	if len(data) == 1 {
+		if data[0] >= 1 && data[0] <= 16 {
+			return []byte{OP_1 + data[0] - 1}
+		}
+		if data[0] == 0x81 {
+			return []byte{OP_1NEGATE}
+		}
+	}
+
+	size := uint64(len(data))
+	result := make([]byte, 0, PushDataScriptHeaderSize(size)+len(data))
+	result = append(result, PushDataScriptSize(size)...)
+	return append(result, data...)
+}
+
+// IsMinimalPush returns true if the op code is the smallest encoding that can push the data. The
+//   op code and data are those of a push data item returned from ParseScript.
+func IsMinimalPush(opCode byte, data []byte) bool {
+	switch {
+	case len(data) == 0:
+		return opCode == OP_0
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		return opCode == OP_1+data[0]-1
+	case len(data) == 1 && data[0] == 0x81:
+		return opCode == OP_1NEGATE
+	case len(data) <= int(OP_MAX_SINGLE_BYTE_PUSH_DATA):
+		return opCode == byte(len(data))
+	case uint64(len(data)) <= OP_PUSH_DATA_1_MAX:
+		return opCode == OP_PUSH_DATA_1
+	case uint64(len(data)) <= OP_PUSH_DATA_2_MAX:
+		return opCode == OP_PUSH_DATA_2
+	default:
+		return opCode == OP_PUSH_DATA_4
+	}
+}
+
+// PushDataScriptHeaderSize returns the number of bytes of push op and size that precede data of
+//   the size in a script.
+func PushDataScriptHeaderSize(size uint64) int {
+	switch {
+	case size <= uint64(OP_MAX_SINGLE_BYTE_PUSH_DATA):
+		return 1
+	case size <= OP_PUSH_DATA_1_MAX:
+		return 2
+	case size <= OP_PUSH_DATA_2_MAX:
+		return 3
+	default:
+		return 5
+	}
+}
+
+// setScriptNumberSign adds the sign to a little endian magnitude. When the most significant byte
+//   already has the high bit set an extra byte is added to hold the sign.
+func setScriptNumberSign(b []byte, isNegative bool) []byte {
+	if b[len(b)-1]&0x80 != 0 {
+		if isNegative {
+			return append(b, 0x80)
+		}
+		return append(b, 0x00)
+	}
+
+	if isNegative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
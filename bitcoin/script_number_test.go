@@ -0,0 +1,195 @@
+package bitcoin
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestScriptNumber(t *testing.T) {
+	tests := []struct {
+		n       int64
+		encoded []byte
+	}{
+		{0, nil},
+		{1, []byte{0x01}},
+		{-1, []byte{0x81}},
+		{127, []byte{0x7f}},
+		{-127, []byte{0xff}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80, 0x80}},
+		{256, []byte{0x00, 0x01}},
+		{-256, []byte{0x00, 0x81}},
+		{32767, []byte{0xff, 0x7f}},
+		{32768, []byte{0x00, 0x80, 0x00}},
+		{-32768, []byte{0x00, 0x80, 0x80}},
+		{math.MaxInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{math.MinInt64 + 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{math.MinInt64, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80, 0x80}},
+	}
+
+	for _, tt := range tests {
+		encoded := EncodeScriptNumber(tt.n)
+		if !bytes.Equal(encoded, tt.encoded) {
+			t.Fatalf("Wrong encoding of %d : got %x, want %x", tt.n, encoded, tt.encoded)
+		}
+
+		if !IsMinimalScriptNumber(encoded) {
+			t.Fatalf("Encoding of %d not minimal : %x", tt.n, encoded)
+		}
+
+		decoded, err := DecodeScriptNumber(encoded, true)
+		if err != nil {
+			t.Fatalf("Failed to decode %x : %s", encoded, err)
+		}
+		if decoded != tt.n {
+			t.Fatalf("Wrong decoded value : got %d, want %d", decoded, tt.n)
+		}
+
+		bigEncoded := EncodeScriptBigNumber(big.NewInt(tt.n))
+		if !bytes.Equal(bigEncoded, tt.encoded) {
+			t.Fatalf("Wrong big encoding of %d : got %x, want %x", tt.n, bigEncoded, tt.encoded)
+		}
+
+		// The push script parses back to the same number.
+		script := PushNumberScript(tt.n)
+		parsed, length, err := ParsePushNumberScript(script)
+		if err != nil {
+			t.Fatalf("Failed to parse push number script %x : %s", script, err)
+		}
+		if parsed != tt.n {
+			t.Fatalf("Wrong parsed number : got %d, want %d", parsed, tt.n)
+		}
+		if length != len(script) {
+			t.Fatalf("Wrong parsed length : got %d, want %d", length, len(script))
+		}
+	}
+}
+
+func TestScriptNumberNonMinimal(t *testing.T) {
+	tests := []struct {
+		encoded []byte
+		n       int64
+	}{
+		{[]byte{0x00}, 0},
+		{[]byte{0x80}, 0},
+		{[]byte{0x01, 0x00}, 1},
+		{[]byte{0x01, 0x80}, -1},
+		{[]byte{0x7f, 0x00, 0x00}, 127},
+	}
+
+	for _, tt := range tests {
+		if IsMinimalScriptNumber(tt.encoded) {
+			t.Fatalf("Encoding should not be minimal : %x", tt.encoded)
+		}
+
+		if _, err := DecodeScriptNumber(tt.encoded, true); errors.Cause(err) != ErrNonMinimalEncoding {
+			t.Fatalf("Wrong error for %x : got %v, want %v", tt.encoded, err,
+				ErrNonMinimalEncoding)
+		}
+
+		n, err := DecodeScriptNumber(tt.encoded, false)
+		if err != nil {
+			t.Fatalf("Failed to decode %x : %s", tt.encoded, err)
+		}
+		if n != tt.n {
+			t.Fatalf("Wrong value for %x : got %d, want %d", tt.encoded, n, tt.n)
+		}
+	}
+}
+
+func TestScriptBigNumber(t *testing.T) {
+	n, ok := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	if !ok {
+		t.Fatalf("Failed to parse big number")
+	}
+
+	encoded := EncodeScriptBigNumber(n)
+	if !IsMinimalScriptNumber(encoded) {
+		t.Fatalf("Encoding not minimal : %x", encoded)
+	}
+
+	decoded, err := DecodeScriptBigNumber(encoded, true)
+	if err != nil {
+		t.Fatalf("Failed to decode : %s", err)
+	}
+	if decoded.Cmp(n) != 0 {
+		t.Fatalf("Wrong decoded value : got %s, want %s", decoded, n)
+	}
+
+	if _, err := DecodeScriptNumber(encoded, true); errors.Cause(err) != ErrScriptNumberOverflow {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrScriptNumberOverflow)
+	}
+
+	script := PushBigNumberScript(n)
+	item, err := ParseScript(bytes.NewReader(script))
+	if err != nil {
+		t.Fatalf("Failed to parse script : %s", err)
+	}
+	if !bytes.Equal(item.Data, encoded) {
+		t.Fatalf("Wrong pushed data : got %x, want %x", item.Data, encoded)
+	}
+	if !IsMinimalPush(item.OpCode, item.Data) {
+		t.Fatalf("Push not minimal : %x", script)
+	}
+
+	if !bytes.Equal(PushBigNumberScript(big.NewInt(5)), []byte{OP_5}) {
+		t.Fatalf("Wrong small big number push : %x", PushBigNumberScript(big.NewInt(5)))
+	}
+}
+
+func TestMinimalPushDataScript(t *testing.T) {
+	tests := []struct {
+		data   []byte
+		script []byte
+	}{
+		{nil, []byte{OP_0}},
+		{[]byte{0x01}, []byte{OP_1}},
+		{[]byte{0x10}, []byte{OP_16}},
+		{[]byte{0x81}, []byte{OP_1NEGATE}},
+		{[]byte{0x11}, []byte{0x01, 0x11}},
+		{[]byte{0x00}, []byte{0x01, 0x00}},
+	}
+
+	for _, tt := range tests {
+		script := MinimalPushDataScript(tt.data)
+		if !bytes.Equal(script, tt.script) {
+			t.Fatalf("Wrong script for %x : got %x, want %x", tt.data, script, tt.script)
+		}
+	}
+
+	for _, size := range []int{0x4b, 0x4c, 0xff, 0x100, 0xffff, 0x10000} {
+		data := bytes.Repeat([]byte{0xaa}, size)
+		script := MinimalPushDataScript(data)
+
+		if len(script) != PushDataScriptHeaderSize(uint64(size))+size {
+			t.Fatalf("Wrong script size for %d : got %d, want %d", size, len(script),
+				PushDataScriptHeaderSize(uint64(size))+size)
+		}
+
+		item, err := ParseScript(bytes.NewReader(script))
+		if err != nil {
+			t.Fatalf("Failed to parse script : %s", err)
+		}
+		if !bytes.Equal(item.Data, data) {
+			t.Fatalf("Wrong data for size %d", size)
+		}
+		if !IsMinimalPush(item.OpCode, item.Data) {
+			t.Fatalf("Push of size %d not minimal : op code 0x%02x", size, item.OpCode)
+		}
+	}
+
+	// Non-minimal pushes.
+	if IsMinimalPush(0x01, []byte{0x05}) {
+		t.Fatalf("Push of 5 should use OP_5")
+	}
+	if IsMinimalPush(OP_PUSH_DATA_1, []byte{0xaa}) {
+		t.Fatalf("Push of 1 byte should use single byte push")
+	}
+	if IsMinimalPush(OP_PUSH_DATA_2, bytes.Repeat([]byte{0xaa}, 0xff)) {
+		t.Fatalf("Push of 255 bytes should use OP_PUSHDATA1")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 var tests = []struct {
@@ -19,14 +21,18 @@ var tests = []struct {
 	// OP_PUSHDATA1 (push code 0x4c followed by 1 byte for size)
 	{0x4c, []byte{0x4c, 0x4c}},
 	{0x50, []byte{0x4c, 0x50}},
+	{0xff, []byte{0x4c, 0xff}},
 
 	// OP_PUSHDATA2 (push code 0x4d followed by 2 bytes for size)
 	{0x1050, []byte{0x4d, 0x50, 0x10}},
 	{0xff50, []byte{0x4d, 0x50, 0xff}},
+	{0x0100, []byte{0x4d, 0x00, 0x01}},
+	{0xffff, []byte{0x4d, 0xff, 0xff}},
 
 	// OP_PUSHDATA4 (push code 0x4e followed by 4 bytes for size)
 	{0x0010ff50, []byte{0x4e, 0x50, 0xff, 0x10, 0x00}},
 	{0x00ffff50, []byte{0x4e, 0x50, 0xff, 0xff, 0x00}},
+	{0x00010000, []byte{0x4e, 0x00, 0x00, 0x01, 0x00}},
 }
 
 func TestPushDataScriptSize(t *testing.T) {
@@ -281,3 +287,73 @@ func Test_PubKeyCount_RequiredSignatures(t *testing.T) {
 		})
 	}
 }
+
+func Test_MultiSigCounts(t *testing.T) {
+	pk1 := "0x029ac355257736dfa1ad9652fcb51c7136fc27f9ad9652fcb51c7136fc27f95257"
+	pk2 := "0x039ac355257736dfa1ad9652fcb51c7136fc27f9ad9652fcb51c7136fc27f95257"
+	pk3 := "0x029ac355257736dfa1ad9652fcb51c7136fc27f9ad9652fcb51c7136fc27f95258"
+
+	tests := []struct {
+		name     string
+		text     string
+		valid    bool
+		required uint32
+		total    uint32
+	}{
+		{
+			name:     "1 of 1",
+			text:     "OP_1 " + pk1 + " OP_1 OP_CHECKMULTISIG",
+			valid:    true,
+			required: 1,
+			total:    1,
+		},
+		{
+			name:     "2 of 3",
+			text:     "OP_2 " + pk1 + " " + pk2 + " " + pk3 + " OP_3 OP_CHECKMULTISIG",
+			valid:    true,
+			required: 2,
+			total:    3,
+		},
+		{
+			name:  "wrong total",
+			text:  "OP_2 " + pk1 + " " + pk2 + " OP_3 OP_CHECKMULTISIG",
+			valid: false,
+		},
+		{
+			name:  "required more than total",
+			text:  "OP_3 " + pk1 + " " + pk2 + " OP_2 OP_CHECKMULTISIG",
+			valid: false,
+		},
+		{
+			name:  "PKH",
+			text:  "OP_DUP OP_HASH160 0x999ac355257736dfa1ad9652fcb51c7136fc27f9 OP_EQUALVERIFY OP_CHECKSIG",
+			valid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := StringToScript(tt.text)
+			if err != nil {
+				t.Fatalf("Failed to decode script : %s", err)
+			}
+
+			required, total, err := script.MultiSigCounts()
+			if !tt.valid {
+				if errors.Cause(err) != ErrWrongScriptTemplate {
+					t.Fatalf("Wrong error : got %v, want %s", err, ErrWrongScriptTemplate)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to get multi-sig counts : %s", err)
+			}
+
+			if required != tt.required || total != tt.total {
+				t.Fatalf("Wrong counts : got %d of %d, want %d of %d", required, total,
+					tt.required, tt.total)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/sha256"
+	"database/sql/driver"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -17,6 +18,9 @@ import (
 )
 
 // Signature is an elliptic curve signature using the secp256k1 elliptic curve.
+// Signatures created by this package always have a low S value (S <= N/2) per BIP 62, and Bytes
+//   and Serialize always encode the low S form, so the encoded signature is never malleable even
+//   if S was set directly to a high value.
 type Signature struct {
 	R big.Int
 	S big.Int
@@ -388,6 +392,14 @@ func (s Signature) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON converts from json.
 func (s *Signature) UnmarshalJSON(data []byte) error {
+	if isJSONEmpty(data) {
+		*s = Signature{}
+		return nil
+	}
+	if len(data) < 2 {
+		return fmt.Errorf("Too short for Signature data : %d", len(data))
+	}
+
 	return s.SetString(string(data[1 : len(data)-1]))
 }
 
@@ -424,11 +436,25 @@ func (s *Signature) UnmarshalBinary(data []byte) error {
 	return s.SetBytes(data)
 }
 
+// Value returns the value to store in a database column.
+// Implements driver.Valuer interface.
+func (s Signature) Value() (driver.Value, error) {
+	if s.IsEmpty() {
+		return nil, nil
+	}
+	return s.Bytes(), nil
+}
+
 // Scan converts from a database column.
 func (s *Signature) Scan(data interface{}) error {
+	if data == nil {
+		*s = Signature{}
+		return nil
+	}
+
 	b, ok := data.([]byte)
 	if !ok {
-		return errors.New("Public Key db column not bytes")
+		return errors.New("Signature db column not bytes")
 	}
 
 	c := make([]byte, len(b))
@@ -436,10 +462,33 @@ func (s *Signature) Scan(data interface{}) error {
 	return s.SetBytes(c)
 }
 
+// IsEmpty returns true if the value is zero.
+func (s Signature) IsEmpty() bool {
+	return s.R.Sign() == 0 && s.S.Sign() == 0
+}
+
 func (s Signature) Equal(o Signature) bool {
 	return s.S.Cmp(&o.S) == 0 && s.R.Cmp(&o.R) == 0
 }
 
+// IsLowS returns true if the S value is not greater than half the curve order as required by
+//   BIP 62.
+func (s Signature) IsLowS() bool {
+	return s.S.Cmp(curveHalfOrder) <= 0
+}
+
+// LowS returns a copy of the signature with the S value normalized to the low S form.
+func (s Signature) LowS() Signature {
+	var result Signature
+	result.R.Set(&s.R)
+	if s.S.Cmp(curveHalfOrder) == 1 {
+		result.S.Sub(curveS256.N, &s.S)
+	} else {
+		result.S.Set(&s.S)
+	}
+	return result
+}
+
 /********************************************* RFC6979 ********************************************/
 var (
 	// Used in RFC6979 implementation when testing the nonce for correctness
@@ -455,9 +504,19 @@ var (
 )
 
 // signRFC6979 generates a deterministic ECDSA signature according to RFC 6979 and BIP 62.
-func signRFC6979(pk big.Int, hash []byte) (Signature, error) {
+// extraEntropy is optional additional data as described in RFC 6979 section 3.6.
+func signRFC6979(pk big.Int, hash, extraEntropy []byte) (Signature, error) {
+	return signWithNonce(pk, hash, nonceRFC6979(pk, hash, extraEntropy))
+}
+
+// signWithNonce generates an ECDSA signature using the specified nonce. The S value is always
+//   normalized to the low S form defined in BIP 62.
+func signWithNonce(pk big.Int, hash []byte, k *big.Int) (Signature, error) {
 	N := curveS256.N
-	k := nonceRFC6979(pk, hash)
+	if k.Sign() <= 0 || k.Cmp(N) >= 0 {
+		return Signature{}, errors.New("nonce out of range")
+	}
+
 	inv := new(big.Int).ModInverse(k, N)
 	r, _ := curveS256.ScalarBaseMult(k.Bytes())
 	r.Mod(r, N)
@@ -483,7 +542,8 @@ func signRFC6979(pk big.Int, hash []byte) (Signature, error) {
 
 // nonceRFC6979 generates an ECDSA nonce (`k`) deterministically according to RFC 6979.
 // It takes a 32-byte hash as an input and returns 32-byte nonce to be used in ECDSA algorithm.
-func nonceRFC6979(pk big.Int, hash []byte) *big.Int {
+// When extraEntropy is not empty it is appended to the HMAC input as described in section 3.6.
+func nonceRFC6979(pk big.Int, hash, extraEntropy []byte) *big.Int {
 
 	q := curveS256Params.N
 	alg := sha256.New
@@ -492,6 +552,7 @@ func nonceRFC6979(pk big.Int, hash []byte) *big.Int {
 	holen := alg().Size()
 	rolen := (qlen + 7) >> 3
 	bx := append(int2octets(pk, rolen), bits2octets(hash, curveS256, rolen)...)
+	bx = append(bx, extraEntropy...)
 
 	// Step B
 	v := bytes.Repeat(oneInitializer, holen)
@@ -2,6 +2,8 @@ package bitcoin
 
 import (
 	"bytes"
+	"fmt"
+	"math/big"
 	"testing"
 )
 
@@ -101,3 +103,111 @@ func TestSig(t *testing.T) {
 // 		fmt.Printf("\"%s\",\n", signatures[i])
 // 	}
 // }
+
+func TestSignDeterministic(t *testing.T) {
+	key := KeyFromValue(*big.NewInt(1), MainNet)
+
+	hash, err := NewHash32(Sha256([]byte("Satoshi Nakamoto")))
+	if err != nil {
+		t.Fatalf("Failed to create hash : %s", err)
+	}
+
+	nonce := key.Nonce(*hash, nil)
+	wantNonce := "8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15"
+	if fmt.Sprintf("%064x", &nonce) != wantNonce {
+		t.Fatalf("Wrong nonce : \ngot  %064x\nwant %s", &nonce, wantNonce)
+	}
+
+	sig, err := key.Sign(*hash)
+	if err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	wantR := "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8"
+	wantS := "2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"
+	if fmt.Sprintf("%064x", &sig.R) != wantR || fmt.Sprintf("%064x", &sig.S) != wantS {
+		t.Fatalf("Wrong signature : \ngot  %064x %064x\nwant %s %s", &sig.R, &sig.S, wantR, wantS)
+	}
+
+	if !sig.IsLowS() {
+		t.Fatalf("Signature is not low S")
+	}
+
+	nonceSig, err := key.SignWithNonce(*hash, nonce)
+	if err != nil {
+		t.Fatalf("Failed to sign with nonce : %s", err)
+	}
+
+	if !nonceSig.Equal(sig) {
+		t.Fatalf("Signature with nonce doesn't match")
+	}
+
+	sameSig, err := key.SignDeterministic(*hash, nil)
+	if err != nil {
+		t.Fatalf("Failed to sign deterministic : %s", err)
+	}
+
+	if !sameSig.Equal(sig) {
+		t.Fatalf("Signature without extra entropy doesn't match")
+	}
+
+	entropy := Sha256([]byte("extra entropy"))
+	entropySig, err := key.SignDeterministic(*hash, entropy)
+	if err != nil {
+		t.Fatalf("Failed to sign deterministic : %s", err)
+	}
+
+	if entropySig.Equal(sig) {
+		t.Fatalf("Signature with extra entropy should not match")
+	}
+
+	if !entropySig.Verify(*hash, key.PublicKey()) {
+		t.Fatalf("Signature with extra entropy is invalid")
+	}
+
+	repeatSig, err := key.SignDeterministic(*hash, entropy)
+	if err != nil {
+		t.Fatalf("Failed to sign deterministic : %s", err)
+	}
+
+	if !repeatSig.Equal(entropySig) {
+		t.Fatalf("Signature with extra entropy is not deterministic")
+	}
+
+	if _, err := key.SignWithNonce(*hash, big.Int{}); err == nil {
+		t.Fatalf("Zero nonce should fail")
+	}
+}
+
+func TestSignatureLowS(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	hash, err := NewHash32(Sha256([]byte("low s")))
+	if err != nil {
+		t.Fatalf("Failed to create hash : %s", err)
+	}
+
+	sig, err := key.Sign(*hash)
+	if err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	var highS Signature
+	highS.R.Set(&sig.R)
+	highS.S.Sub(curveS256.N, &sig.S)
+	if highS.IsLowS() {
+		t.Fatalf("Signature should be high S")
+	}
+
+	lowS := highS.LowS()
+	if !lowS.Equal(sig) {
+		t.Fatalf("Normalized signature doesn't match")
+	}
+
+	if !bytes.Equal(highS.Bytes(), sig.Bytes()) {
+		t.Fatalf("High S signature should encode as low S")
+	}
+}
@@ -0,0 +1,93 @@
+package bitcoin
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrKeyNotFound means that a signer doesn't have the key needed to sign.
+	ErrKeyNotFound = errors.New("Key not found")
+)
+
+// Signer signs hashes with private keys that it holds, so that signing can be delegated to
+//   something other than the process building the tx, like an HSM service.
+type Signer interface {
+	// PublicKey returns the public key of the key that can unlock the locking script. keyID is an
+	//   optional identifier, like a derivation path, for the key. It returns ErrKeyNotFound if the
+	//   signer doesn't have the key.
+	PublicKey(keyID string, lockingScript Script) (PublicKey, error)
+
+	// Sign signs the hash with the key for the public key.
+	Sign(keyID string, publicKey PublicKey, hash Hash32) (Signature, error)
+}
+
+// KeysSigner is a Signer that holds a list of keys in memory.
+type KeysSigner struct {
+	keys []Key
+}
+
+// NewKeysSigner creates a signer that signs with the keys. Key IDs are ignored.
+func NewKeysSigner(keys []Key) *KeysSigner {
+	return &KeysSigner{
+		keys: keys,
+	}
+}
+
+// PublicKey returns the public key of the key that can unlock a P2PKH or P2PK locking script.
+func (s *KeysSigner) PublicKey(keyID string, lockingScript Script) (PublicKey, error) {
+	key, err := s.key(lockingScript)
+	if err != nil {
+		return PublicKey{}, err
+	}
+
+	return key.PublicKey(), nil
+}
+
+// Sign signs the hash with the key for the public key.
+func (s *KeysSigner) Sign(keyID string, publicKey PublicKey, hash Hash32) (Signature, error) {
+	for _, key := range s.keys {
+		if key.PublicKey().Equal(publicKey) {
+			return key.Sign(hash)
+		}
+	}
+
+	return Signature{}, ErrKeyNotFound
+}
+
+func (s *KeysSigner) key(lockingScript Script) (*Key, error) {
+	address, err := RawAddressFromLockingScript(lockingScript)
+	if err != nil {
+		return nil, errors.Wrap(err, "locking script")
+	}
+
+	switch address.Type() {
+	case ScriptTypePKH:
+		hash, err := address.Hash()
+		if err != nil {
+			return nil, errors.Wrap(err, "address hash")
+		}
+		for i, key := range s.keys {
+			if bytes.Equal(Hash160(key.PublicKey().Bytes()), hash.Bytes()) {
+				return &s.keys[i], nil
+			}
+		}
+
+	case ScriptTypePK:
+		publicKey, err := address.GetPublicKey()
+		if err != nil {
+			return nil, errors.Wrap(err, "address public key")
+		}
+		for i, key := range s.keys {
+			if key.PublicKey().Equal(publicKey) {
+				return &s.keys[i], nil
+			}
+		}
+
+	default:
+		return nil, errors.Wrap(ErrWrongScriptTemplate, "not P2PKH or P2PK")
+	}
+
+	return nil, ErrKeyNotFound
+}
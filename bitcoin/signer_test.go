@@ -0,0 +1,66 @@
+package bitcoin
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestKeysSigner(t *testing.T) {
+	var keys []Key
+	for i := 0; i < 2; i++ {
+		key, err := GenerateKey(MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		keys = append(keys, key)
+	}
+
+	signer := NewKeysSigner(keys)
+
+	pkhScript, err := keys[1].LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	pkAddress, err := NewRawAddressPublicKey(keys[0].PublicKey())
+	if err != nil {
+		t.Fatalf("Failed to create address : %s", err)
+	}
+	pkLockingScript, err := pkAddress.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	for i, lockingScript := range []Script{pkLockingScript, pkhScript} {
+		publicKey, err := signer.PublicKey("", lockingScript)
+		if err != nil {
+			t.Fatalf("Failed to get public key %d : %s", i, err)
+		}
+		if !publicKey.Equal(keys[i].PublicKey()) {
+			t.Fatalf("Wrong public key %d", i)
+		}
+
+		var hash Hash32
+		rand.Read(hash[:])
+		signature, err := signer.Sign("", publicKey, hash)
+		if err != nil {
+			t.Fatalf("Failed to sign %d : %s", i, err)
+		}
+		if !signature.Verify(hash, publicKey) {
+			t.Fatalf("Signature %d not valid", i)
+		}
+	}
+
+	otherKey, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+	otherScript, err := otherKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	if _, err := signer.PublicKey("", otherScript); errors.Cause(err) != ErrKeyNotFound {
+		t.Fatalf("Wrong error for missing key : %v", err)
+	}
+}
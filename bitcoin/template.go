@@ -166,12 +166,16 @@ func (t Template) PubKeyCount() uint32 {
 }
 
 // RequiredSignatures is the number of signatures required to unlock the template.
-// Note: Only supports PKH, PK, and MultiPKH.
+// Note: Only supports PKH, PK, MultiSig, and MultiPKH.
 func (t Template) RequiredSignatures() (uint32, error) {
 	if bytes.Equal(t, PKHTemplate) || bytes.Equal(t, PKTemplate) {
 		return 1, nil
 	}
 
+	if required, ok := multiSigTemplateRequired(t); ok {
+		return required, nil
+	}
+
 	// Assume this is a multi-pkh accumulator script.
 	buf := bytes.NewReader(t)
 	var previousItems []*ScriptItem
@@ -0,0 +1,95 @@
+package bitcoin
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// Pay to contract key tweaking commits data into a key so that the holder of the base key can
+//   later prove that the tweaked key was derived from a specific commitment.
+//
+//   tweak = TaggedHash("PayToContract", BasePublicKey || Commitment)
+//   TweakedPublicKey = BasePublicKey + (tweak * G)
+//   TweakedKey = BaseKey + tweak
+//
+//   // Commit a script into a key
+//   commitment := ScriptCommitment(script)
+//   tweakedPublicKey, err := TweakPublicKey(basePublicKey, commitment)
+//   tweakedKey, err := TweakKey(baseKey, commitment)
+
+const (
+	contractTweakTag    = "PayToContract"
+	scriptCommitmentTag = "ScriptCommitment"
+)
+
+var (
+	ErrTweakOutOfRange = errors.New("Tweak out of range")
+)
+
+// TaggedHash returns the SHA256 of the data prefixed with the SHA256 of the tag twice as defined
+//   in BIP 340. Using a tag prevents a hash from one protocol from being valid in another.
+func TaggedHash(tag string, data []byte) Hash32 {
+	tagHash := sha256.Sum256([]byte(tag))
+
+	hasher := sha256.New()
+	hasher.Write(tagHash[:])
+	hasher.Write(tagHash[:])
+	hasher.Write(data)
+
+	var result Hash32
+	copy(result[:], hasher.Sum(nil))
+	return result
+}
+
+// ContractTweak returns the value that is added to the base key to commit to the commitment hash.
+func ContractTweak(publicKey PublicKey, commitment Hash32) (Hash32, error) {
+	data := append(publicKey.Bytes(), commitment[:]...)
+	result := TaggedHash(contractTweakTag, data)
+
+	var value big.Int
+	value.SetBytes(result[:])
+	if value.Sign() == 0 || value.Cmp(curveS256.N) >= 0 {
+		return Hash32{}, ErrTweakOutOfRange
+	}
+
+	return result, nil
+}
+
+// ScriptCommitment returns a commitment hash for a script that can be used to tweak a key.
+func ScriptCommitment(script Script) Hash32 {
+	return TaggedHash(scriptCommitmentTag, script)
+}
+
+// TweakPublicKey returns the public key with the commitment added to it.
+func TweakPublicKey(publicKey PublicKey, commitment Hash32) (PublicKey, error) {
+	tweak, err := ContractTweak(publicKey, commitment)
+	if err != nil {
+		return PublicKey{}, err
+	}
+
+	return NextPublicKey(publicKey, tweak)
+}
+
+// TweakKey returns the private key that corresponds to the public key returned by TweakPublicKey
+//   for the public key of this key and the commitment.
+func TweakKey(key Key, commitment Hash32) (Key, error) {
+	tweak, err := ContractTweak(key.PublicKey(), commitment)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return NextKey(key, tweak)
+}
+
+// VerifyTweakedPublicKey returns true if the tweaked public key is the base public key with the
+//   commitment added to it.
+func VerifyTweakedPublicKey(basePublicKey, tweakedPublicKey PublicKey, commitment Hash32) bool {
+	expected, err := TweakPublicKey(basePublicKey, commitment)
+	if err != nil {
+		return false
+	}
+
+	return expected.Equal(tweakedPublicKey)
+}
@@ -0,0 +1,47 @@
+package bitcoin
+
+import (
+	"testing"
+)
+
+func TestTweakKey(t *testing.T) {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+	publicKey := key.PublicKey()
+
+	script, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	commitment := ScriptCommitment(script)
+
+	tweakedKey, err := TweakKey(key, commitment)
+	if err != nil {
+		t.Fatalf("Failed to tweak key : %s", err)
+	}
+
+	tweakedPublicKey, err := TweakPublicKey(publicKey, commitment)
+	if err != nil {
+		t.Fatalf("Failed to tweak public key : %s", err)
+	}
+
+	if !tweakedKey.PublicKey().Equal(tweakedPublicKey) {
+		t.Fatalf("Tweaked keys don't match : \ngot  %s\nwant %s", tweakedKey.PublicKey(),
+			tweakedPublicKey)
+	}
+
+	if tweakedPublicKey.Equal(publicKey) {
+		t.Fatalf("Tweaked public key should not match base")
+	}
+
+	if !VerifyTweakedPublicKey(publicKey, tweakedPublicKey, commitment) {
+		t.Fatalf("Tweaked public key should verify")
+	}
+
+	otherCommitment := ScriptCommitment(append(script, OP_0))
+	if VerifyTweakedPublicKey(publicKey, tweakedPublicKey, otherCommitment) {
+		t.Fatalf("Tweaked public key should not verify with other commitment")
+	}
+}
@@ -0,0 +1,317 @@
+package bitcoin
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultSelectionInputSize is the estimated size of a P2PKH input used when no input size
+	//   function is provided for UTXO selection.
+	DefaultSelectionInputSize = 148
+
+	// DefaultSelectionChangeSize is the size of a P2PKH output used for change when no change
+	//   output size is provided for UTXO selection.
+	DefaultSelectionChangeSize = 34
+
+	// branchAndBoundMaxTries is the maximum number of branches searched by branch and bound
+	//   before giving up.
+	branchAndBoundMaxTries = 100000
+)
+
+var (
+	ErrInsufficientValue = errors.New("Insufficient value")
+	ErrNoExactMatch      = errors.New("No exact match")
+)
+
+// UTXOSelectionOptions specifies the fee and dust parameters used when selecting UTXOs.
+type UTXOSelectionOptions struct {
+	// FeeRate is the fee in satoshis per byte.
+	FeeRate float32
+
+	// BaseSize is the size of the tx not including the selected inputs or a change output. It
+	//   should include the version, lock time, and all other inputs and outputs.
+	BaseSize int
+
+	// ChangeSize is the size of the change output. Zero means DefaultSelectionChangeSize.
+	ChangeSize int
+
+	// DustLimit is the minimum value of a change output. Change below this is added to the fee.
+	DustLimit uint64
+
+	// InputSize returns the size of an input spending the locking script. When nil
+	//   DefaultSelectionInputSize is used for all inputs. txbuilder.InputSize can be used here.
+	InputSize func(Script) (int, error)
+}
+
+// UTXOSelection is the result of selecting UTXOs to fund a target value.
+type UTXOSelection struct {
+	UTXOs  []UTXO
+	Value  uint64 // Total value of the selected UTXOs
+	Fee    uint64 // Fee paid by the tx, including any dust change
+	Change uint64 // Zero when no change output is needed
+}
+
+// selectionCandidate is a UTXO with its fee already calculated.
+type selectionCandidate struct {
+	utxo UTXO
+	fee  uint64
+}
+
+func (c selectionCandidate) effectiveValue() uint64 {
+	if c.utxo.Value <= c.fee {
+		return 0
+	}
+	return c.utxo.Value - c.fee
+}
+
+func (o UTXOSelectionOptions) fee(size int) uint64 {
+	return uint64(float32(size) * o.FeeRate)
+}
+
+func (o UTXOSelectionOptions) changeSize() int {
+	if o.ChangeSize == 0 {
+		return DefaultSelectionChangeSize
+	}
+	return o.ChangeSize
+}
+
+// candidates calculates the fee for each UTXO and removes those that cost more to spend than
+//   they are worth.
+func (o UTXOSelectionOptions) candidates(utxos []UTXO) ([]selectionCandidate, error) {
+	result := make([]selectionCandidate, 0, len(utxos))
+	for _, utxo := range utxos {
+		size := DefaultSelectionInputSize
+		if o.InputSize != nil {
+			s, err := o.InputSize(utxo.LockingScript)
+			if err != nil {
+				return nil, errors.Wrapf(err, "input size %s", utxo.ID())
+			}
+			size = s
+		}
+
+		candidate := selectionCandidate{utxo: utxo, fee: o.fee(size)}
+		if candidate.effectiveValue() == 0 {
+			continue // uneconomical
+		}
+		result = append(result, candidate)
+	}
+
+	return result, nil
+}
+
+// finish calculates the fee and change for the selected candidates.
+func (o UTXOSelectionOptions) finish(target uint64,
+	selected []selectionCandidate) (*UTXOSelection, error) {
+
+	result := &UTXOSelection{
+		UTXOs: make([]UTXO, 0, len(selected)),
+		Fee:   o.fee(o.BaseSize),
+	}
+	for _, c := range selected {
+		result.UTXOs = append(result.UTXOs, c.utxo)
+		result.Value += c.utxo.Value
+		result.Fee += c.fee
+	}
+
+	if result.Value < target+result.Fee {
+		return nil, errors.Wrapf(ErrInsufficientValue, "have %d, need %d", result.Value,
+			target+result.Fee)
+	}
+
+	remaining := result.Value - target - result.Fee
+	changeFee := o.fee(o.changeSize())
+	if remaining > changeFee && remaining-changeFee >= o.DustLimit {
+		result.Change = remaining - changeFee
+		result.Fee += changeFee
+	} else {
+		result.Fee += remaining // dust goes to the miner
+	}
+
+	return result, nil
+}
+
+// SelectUTXOsLargestFirst selects the largest UTXOs until the target value and fees are covered.
+func SelectUTXOsLargestFirst(utxos []UTXO, target uint64,
+	options UTXOSelectionOptions) (*UTXOSelection, error) {
+
+	candidates, err := options.candidates(utxos)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].effectiveValue() > candidates[j].effectiveValue()
+	})
+
+	needed := target + options.fee(options.BaseSize)
+	var value uint64
+	for i, c := range candidates {
+		value += c.effectiveValue()
+		if value >= needed {
+			return options.finish(target, candidates[:i+1])
+		}
+	}
+
+	return nil, errors.Wrapf(ErrInsufficientValue, "have %d, need %d", value, needed)
+}
+
+// SelectUTXOsBranchAndBound searches for a set of UTXOs that covers the target value and fees
+//   without needing a change output. The excess is limited to the cost of adding a change output
+//   plus the dust limit, and is added to the fee. ErrNoExactMatch is returned when no such set is
+//   found so the caller can fall back to another algorithm.
+func SelectUTXOsBranchAndBound(utxos []UTXO, target uint64,
+	options UTXOSelectionOptions) (*UTXOSelection, error) {
+
+	candidates, err := options.candidates(utxos)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].effectiveValue() > candidates[j].effectiveValue()
+	})
+
+	var available uint64
+	for _, c := range candidates {
+		available += c.effectiveValue()
+	}
+
+	low := target + options.fee(options.BaseSize)
+	high := low + options.fee(options.changeSize()) + options.DustLimit
+	if available < low {
+		return nil, errors.Wrapf(ErrInsufficientValue, "have %d, need %d", available, low)
+	}
+
+	// Depth first search where each level decides whether to include a candidate.
+	included := make([]bool, len(candidates))
+	var best []bool
+	var bestExcess uint64
+	var value uint64
+	tries := 0
+	depth := 0
+
+	for tries < branchAndBoundMaxTries {
+		tries++
+		backtrack := false
+
+		if value+available < low || value > high {
+			backtrack = true // can't reach target, or overshot
+		} else if value >= low {
+			excess := value - low
+			if best == nil || excess < bestExcess {
+				best = make([]bool, len(included))
+				copy(best, included[:depth])
+				bestExcess = excess
+				if excess == 0 {
+					break
+				}
+			}
+			backtrack = true
+		} else if depth == len(candidates) {
+			backtrack = true
+		}
+
+		if backtrack {
+			// Walk back to the last included candidate and exclude it instead.
+			for depth > 0 && !included[depth-1] {
+				depth--
+				available += candidates[depth].effectiveValue()
+			}
+			if depth == 0 {
+				break // search space exhausted
+			}
+			included[depth-1] = false
+			value -= candidates[depth-1].effectiveValue()
+			continue
+		}
+
+		// Include the next candidate.
+		available -= candidates[depth].effectiveValue()
+		included[depth] = true
+		value += candidates[depth].effectiveValue()
+		depth++
+	}
+
+	if best == nil {
+		return nil, ErrNoExactMatch
+	}
+
+	var selected []selectionCandidate
+	for i, include := range best {
+		if include {
+			selected = append(selected, candidates[i])
+		}
+	}
+
+	return options.finish(target, selected)
+}
+
+// SelectUTXOsRandomImprove randomly selects UTXOs until the target value and fees are covered,
+//   then randomly adds more UTXOs while they move the selected value closer to twice the target,
+//   without exceeding three times the target. This tends to create change outputs of similar size
+//   to the payments, which keeps the UTXO set healthy.
+func SelectUTXOsRandomImprove(utxos []UTXO, target uint64,
+	options UTXOSelectionOptions) (*UTXOSelection, error) {
+
+	candidates, err := options.candidates(utxos)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := shuffleCandidates(candidates); err != nil {
+		return nil, errors.Wrap(err, "shuffle")
+	}
+
+	needed := target + options.fee(options.BaseSize)
+	var value uint64
+	count := 0
+	for count < len(candidates) && value < needed {
+		value += candidates[count].effectiveValue()
+		count++
+	}
+
+	if value < needed {
+		return nil, errors.Wrapf(ErrInsufficientValue, "have %d, need %d", value, needed)
+	}
+
+	// Improve
+	ideal := 2 * needed
+	upper := 3 * needed
+	selected := make([]selectionCandidate, count, len(candidates))
+	copy(selected, candidates[:count])
+	for _, c := range candidates[count:] {
+		newValue := value + c.effectiveValue()
+		if newValue > upper || distance(newValue, ideal) >= distance(value, ideal) {
+			continue
+		}
+
+		selected = append(selected, c)
+		value = newValue
+	}
+
+	return options.finish(target, selected)
+}
+
+func distance(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func shuffleCandidates(candidates []selectionCandidate) error {
+	for i := len(candidates) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		k := int(j.Int64())
+		candidates[i], candidates[k] = candidates[k], candidates[i]
+	}
+
+	return nil
+}
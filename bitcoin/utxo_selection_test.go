@@ -0,0 +1,153 @@
+package bitcoin
+
+import (
+	"errors"
+	"testing"
+)
+
+func selectionTestUTXOs(t *testing.T, values []uint64) []UTXO {
+	key, err := GenerateKey(MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	result := make([]UTXO, len(values))
+	for i, value := range values {
+		result[i] = UTXO{
+			Index:         uint32(i),
+			Value:         value,
+			LockingScript: lockingScript,
+		}
+	}
+
+	return result
+}
+
+func checkSelection(t *testing.T, selection *UTXOSelection, target uint64) {
+	var value uint64
+	for _, utxo := range selection.UTXOs {
+		value += utxo.Value
+	}
+
+	if value != selection.Value {
+		t.Fatalf("Wrong selection value : got %d, want %d", selection.Value, value)
+	}
+
+	if selection.Value != target+selection.Fee+selection.Change {
+		t.Fatalf("Selection doesn't balance : value %d, target %d, fee %d, change %d",
+			selection.Value, target, selection.Fee, selection.Change)
+	}
+}
+
+func TestSelectUTXOsLargestFirst(t *testing.T) {
+	utxos := selectionTestUTXOs(t, []uint64{1000, 50000, 20000, 10, 5000})
+	options := UTXOSelectionOptions{
+		FeeRate:   0.5,
+		BaseSize:  44,
+		DustLimit: 546,
+	}
+
+	selection, err := SelectUTXOsLargestFirst(utxos, 60000, options)
+	if err != nil {
+		t.Fatalf("Failed to select : %s", err)
+	}
+	checkSelection(t, selection, 60000)
+
+	if len(selection.UTXOs) != 2 {
+		t.Fatalf("Wrong selected count : got %d, want %d", len(selection.UTXOs), 2)
+	}
+
+	if selection.UTXOs[0].Value != 50000 || selection.UTXOs[1].Value != 20000 {
+		t.Fatalf("Wrong UTXOs selected : %d, %d", selection.UTXOs[0].Value,
+			selection.UTXOs[1].Value)
+	}
+
+	if _, err := SelectUTXOsLargestFirst(utxos, 100000, options); !errors.Is(err,
+		ErrInsufficientValue) {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrInsufficientValue)
+	}
+}
+
+func TestSelectUTXOsBranchAndBound(t *testing.T) {
+	utxos := selectionTestUTXOs(t, []uint64{30074, 50074, 20074, 10074, 7074})
+	options := UTXOSelectionOptions{
+		FeeRate:   0.5,
+		BaseSize:  44,
+		DustLimit: 546,
+	}
+
+	// Input fee is 74 and base fee is 22 so 30074 exactly covers the target.
+	selection, err := SelectUTXOsBranchAndBound(utxos, 29978, options)
+	if err != nil {
+		t.Fatalf("Failed to select : %s", err)
+	}
+	checkSelection(t, selection, 29978)
+
+	if selection.Change != 0 {
+		t.Fatalf("Branch and bound should not have change : %d", selection.Change)
+	}
+
+	if selection.Fee != 22+74 {
+		t.Fatalf("Wrong fee : %d", selection.Fee)
+	}
+
+	if _, err := SelectUTXOsBranchAndBound(utxos, 5000, options); !errors.Is(err,
+		ErrNoExactMatch) {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrNoExactMatch)
+	}
+}
+
+func TestSelectUTXOsRandomImprove(t *testing.T) {
+	values := make([]uint64, 50)
+	for i := range values {
+		values[i] = uint64(1000 * (i + 1))
+	}
+	utxos := selectionTestUTXOs(t, values)
+	options := UTXOSelectionOptions{
+		FeeRate:   0.5,
+		BaseSize:  44,
+		DustLimit: 546,
+	}
+
+	for i := 0; i < 20; i++ {
+		selection, err := SelectUTXOsRandomImprove(utxos, 40000, options)
+		if err != nil {
+			t.Fatalf("Failed to select : %s", err)
+		}
+		checkSelection(t, selection, 40000)
+
+		if selection.Value > 3*(40000+22) {
+			t.Fatalf("Selected too much value : %d", selection.Value)
+		}
+	}
+}
+
+func TestSelectUTXOsDust(t *testing.T) {
+	// UTXOs worth less than their input fee are never selected.
+	utxos := selectionTestUTXOs(t, []uint64{50, 60, 10000})
+	options := UTXOSelectionOptions{
+		FeeRate:   1.0,
+		BaseSize:  44,
+		DustLimit: 546,
+	}
+
+	selection, err := SelectUTXOsLargestFirst(utxos, 9500, options)
+	if err != nil {
+		t.Fatalf("Failed to select : %s", err)
+	}
+	checkSelection(t, selection, 9500)
+
+	if len(selection.UTXOs) != 1 {
+		t.Fatalf("Wrong selected count : got %d, want %d", len(selection.UTXOs), 1)
+	}
+
+	// Remaining 308 is less than change fee plus dust limit so it goes to the fee.
+	if selection.Change != 0 {
+		t.Fatalf("Dust change should be added to fee : %d", selection.Change)
+	}
+}
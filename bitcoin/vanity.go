@@ -0,0 +1,247 @@
+package bitcoin
+
+import (
+	"context"
+	"math/big"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	// vanityBatchSize is the number of keys each worker tries between updates of the shared
+	//   attempt count.
+	vanityBatchSize = 256
+)
+
+var (
+	ErrInvalidVanityPrefix = errors.New("Invalid vanity prefix")
+)
+
+// VanityConfig specifies the address to search for with FindVanityKey.
+type VanityConfig struct {
+	// Prefix is the start of the address, including the network character. For example "1Tok" for
+	//   main net or "mTok" for test net.
+	Prefix string
+
+	// CaseInsensitive matches the prefix without regard to case, which is much faster for longer
+	//   prefixes.
+	CaseInsensitive bool
+
+	// Workers is the number of go routines used to search. Zero uses all CPU cores.
+	Workers int
+
+	// Progress is called every ProgressInterval with the current progress. It is optional.
+	Progress         func(VanityProgress)
+	ProgressInterval time.Duration
+}
+
+// VanityProgress describes the progress of a vanity key search.
+type VanityProgress struct {
+	Attempts uint64        // Number of keys tried
+	Elapsed  time.Duration // Time since the search started
+	Expected float64       // Expected number of attempts to find a match
+}
+
+// Rate returns the number of keys tried per second.
+func (p VanityProgress) Rate() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Attempts) / p.Elapsed.Seconds()
+}
+
+// FindVanityKey searches for a key whose P2PKH address starts with the prefix. The search uses
+//   multiple CPU cores and stops when the context is done. Each additional prefix character makes
+//   the search about 58 times longer, so check VanityDifficulty before starting long searches.
+//
+//   Each worker starts at a random key and tries consecutive keys so that only a point addition,
+//   instead of a full scalar multiplication, is needed per key.
+func FindVanityKey(ctx context.Context, net Network, config VanityConfig) (Key, error) {
+	if err := ValidateVanityPrefix(config.Prefix, net); err != nil {
+		return Key{}, err
+	}
+
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	prefix := config.Prefix
+	if config.CaseInsensitive {
+		prefix = strings.ToLower(prefix)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var attempts uint64
+	found := make(chan Key, workers)
+	errs := make(chan error, workers)
+	var wait sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+
+			key, err := searchVanityKey(ctx, net, prefix, config.CaseInsensitive, &attempts)
+			if err != nil {
+				errs <- err
+			} else if key != nil {
+				found <- *key
+			}
+		}()
+	}
+
+	var ticker <-chan time.Time
+	if config.Progress != nil {
+		interval := config.ProgressInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		ticker = t.C
+	}
+
+	start := time.Now()
+	expected := VanityDifficulty(config.Prefix, config.CaseInsensitive)
+	defer func() {
+		cancel()
+		wait.Wait()
+	}()
+
+	for {
+		select {
+		case key := <-found:
+			return key, nil
+
+		case err := <-errs:
+			return Key{}, err
+
+		case <-ctx.Done():
+			return Key{}, ctx.Err()
+
+		case <-ticker:
+			config.Progress(VanityProgress{
+				Attempts: atomic.LoadUint64(&attempts),
+				Elapsed:  time.Since(start),
+				Expected: expected,
+			})
+		}
+	}
+}
+
+// searchVanityKey tries consecutive keys from a random starting key until one matches or the
+//   context is done, in which case it returns nil.
+func searchVanityKey(ctx context.Context, net Network, prefix string, caseInsensitive bool,
+	attempts *uint64) (*Key, error) {
+
+	start, err := GenerateKey(net)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate key")
+	}
+
+	value := new(big.Int).Set(&start.value)
+	x, y := curveS256.ScalarBaseMult(value.Bytes())
+	gx, gy := curveS256Params.Gx, curveS256Params.Gy
+	one := big.NewInt(1)
+
+	for {
+		for i := 0; i < vanityBatchSize; i++ {
+			address := NewAddressFromRawAddress(vanityRawAddress(x, y), net).String()
+			if caseInsensitive {
+				address = strings.ToLower(address)
+			}
+
+			if strings.HasPrefix(address, prefix) {
+				atomic.AddUint64(attempts, uint64(i+1))
+				return &Key{net: net, value: *value}, nil
+			}
+
+			// Next key
+			value.Add(value, one)
+			if value.Cmp(curveS256.N) >= 0 {
+				value.SetInt64(1)
+				x, y = curveS256.ScalarBaseMult(value.Bytes())
+			} else {
+				x, y = curveS256.Add(x, y, gx, gy)
+			}
+		}
+
+		atomic.AddUint64(attempts, vanityBatchSize)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		default:
+		}
+	}
+}
+
+func vanityRawAddress(x, y *big.Int) RawAddress {
+	ra, _ := NewRawAddressPKH(Hash160(compressPublicKey(*x, *y)))
+	return ra
+}
+
+// ValidateVanityPrefix returns an error if no P2PKH address on the network can start with the
+//   prefix.
+func ValidateVanityPrefix(prefix string, net Network) error {
+	if len(prefix) == 0 {
+		return errors.Wrap(ErrInvalidVanityPrefix, "empty")
+	}
+
+	for _, c := range prefix {
+		if !strings.ContainsRune(base58Alphabet, c) {
+			return errors.Wrapf(ErrInvalidVanityPrefix, "character not in base58 : %c", c)
+		}
+	}
+
+	var first string
+	if net == MainNet {
+		first = "1"
+	} else {
+		first = "mn"
+	}
+
+	if !strings.ContainsRune(first, rune(prefix[0])) {
+		return errors.Wrapf(ErrInvalidVanityPrefix, "must start with one of \"%s\"", first)
+	}
+
+	return nil
+}
+
+// VanityDifficulty returns the approximate expected number of keys that must be tried to find an
+//   address with the prefix. The network character is not counted. Characters are not uniformly
+//   distributed in the second position, because of the range of the encoded value, so prefixes
+//   with a second character late in the base58 alphabet, like "1z", take much longer.
+func VanityDifficulty(prefix string, caseInsensitive bool) float64 {
+	if len(prefix) <= 1 {
+		return 1
+	}
+
+	result := 1.0
+	for _, c := range prefix[1:] {
+		if caseInsensitive {
+			lower := strings.ToLower(string(c))
+			upper := strings.ToUpper(string(c))
+
+			// Letters that exist in both cases in base58 match two characters.
+			if lower != upper && strings.Contains(base58Alphabet, lower) &&
+				strings.Contains(base58Alphabet, upper) {
+				result *= 29
+				continue
+			}
+		}
+
+		result *= 58
+	}
+
+	return result
+}
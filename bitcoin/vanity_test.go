@@ -0,0 +1,116 @@
+package bitcoin
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestFindVanityKey(t *testing.T) {
+	tests := []struct {
+		net             Network
+		prefix          string
+		caseInsensitive bool
+	}{
+		{MainNet, "1A", false},
+		{MainNet, "1ab", true},
+		{TestNet, "n2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			key, err := FindVanityKey(context.Background(), tt.net, VanityConfig{
+				Prefix:          tt.prefix,
+				CaseInsensitive: tt.caseInsensitive,
+				Workers:         2,
+			})
+			if err != nil {
+				t.Fatalf("Failed to find vanity key : %s", err)
+			}
+
+			ra, err := key.RawAddress()
+			if err != nil {
+				t.Fatalf("Failed to create raw address : %s", err)
+			}
+
+			address := NewAddressFromRawAddress(ra, tt.net).String()
+			t.Logf("Address : %s", address)
+
+			match := address
+			prefix := tt.prefix
+			if tt.caseInsensitive {
+				match = strings.ToLower(match)
+				prefix = strings.ToLower(prefix)
+			}
+			if !strings.HasPrefix(match, prefix) {
+				t.Errorf("Wrong address prefix : got %s, want %s", address, tt.prefix)
+			}
+
+			if key.Network() != tt.net {
+				t.Errorf("Wrong key network : got %v, want %v", key.Network(), tt.net)
+			}
+		})
+	}
+}
+
+func TestFindVanityKeyCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var progress []VanityProgress
+	var lock sync.Mutex
+	_, err := FindVanityKey(ctx, MainNet, VanityConfig{
+		Prefix:           "1zzzzzzzzz",
+		ProgressInterval: 50 * time.Millisecond,
+		Progress: func(p VanityProgress) {
+			lock.Lock()
+			progress = append(progress, p)
+			lock.Unlock()
+		},
+	})
+	if errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("Wrong error : got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(progress) == 0 {
+		t.Fatalf("No progress reported")
+	}
+
+	last := progress[len(progress)-1]
+	t.Logf("Attempts %d, rate %.0f/s, expected %.0f", last.Attempts, last.Rate(), last.Expected)
+	if last.Attempts == 0 || last.Expected != VanityDifficulty("1zzzzzzzzz", false) {
+		t.Errorf("Wrong progress : %+v", last)
+	}
+}
+
+func TestValidateVanityPrefix(t *testing.T) {
+	tests := []struct {
+		net    Network
+		prefix string
+		valid  bool
+	}{
+		{MainNet, "1Tok", true},
+		{MainNet, "mTok", false},
+		{TestNet, "nTok", true},
+		{MainNet, "1Tol", false}, // l isn't in base58
+		{MainNet, "10", false},   // 0 isn't in base58
+		{MainNet, "", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateVanityPrefix(tt.prefix, tt.net)
+		if tt.valid && err != nil {
+			t.Errorf("Prefix %s should be valid : %s", tt.prefix, err)
+		}
+		if !tt.valid && errors.Cause(err) != ErrInvalidVanityPrefix {
+			t.Errorf("Prefix %s should be invalid : %v", tt.prefix, err)
+		}
+	}
+}
@@ -56,6 +56,9 @@ var (
 	// ErrWrongOutputCount means that the outputs supplied with a payment request do not match the
 	// number of inputs.
 	ErrWrongOutputCount = errors.New("Wrong Output Count")
+
+	// ErrRejected means a P2P transaction was not accepted by the receiver.
+	ErrRejected = errors.New("Rejected")
 )
 
 // Factory is the interface for creating new bsvalias clients.
@@ -0,0 +1,53 @@
+package bsvalias
+
+import (
+	"context"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ContactIdentityKeyLabel is the label of the contact key retrieved from the handle's PKI
+	// endpoint.
+	ContactIdentityKeyLabel = "identity"
+)
+
+// LookupContact creates a contact for the handle with the identity public key from the handle's
+// PKI endpoint. The handle is normalized before it is used.
+func LookupContact(ctx context.Context, factory Factory, name,
+	handle string) (*bitcoin.Contact, error) {
+
+	normalized, err := NormalizeHandle(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "handle")
+	}
+
+	contact := bitcoin.NewContact(name, normalized)
+	if _, err := RefreshContact(ctx, factory, contact); err != nil {
+		return nil, err
+	}
+
+	return contact, nil
+}
+
+// RefreshContact retrieves the identity public key for the contact's handle and adds it to the
+// contact if it isn't already known. It returns true if a new key was added.
+func RefreshContact(ctx context.Context, factory Factory, contact *bitcoin.Contact) (bool, error) {
+	if len(contact.Handle) == 0 {
+		return false, errors.Wrap(ErrInvalidHandle, "missing handle")
+	}
+
+	client, err := factory.NewClient(ctx, contact.Handle)
+	if err != nil {
+		return false, errors.Wrap(err, "client")
+	}
+
+	publicKey, err := client.GetPublicKey(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "get public key")
+	}
+
+	return contact.AddPublicKey(*publicKey, ContactIdentityKeyLabel), nil
+}
@@ -0,0 +1,57 @@
+package bsvalias
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+func TestLookupContact(t *testing.T) {
+	ctx := context.Background()
+	factory := NewMockFactory()
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	factory.AddMockUser("alice@example.com", key, key)
+
+	contact, err := LookupContact(ctx, factory, "Alice", " alice@Example.COM")
+	if err != nil {
+		t.Fatalf("Failed to lookup contact : %s", err)
+	}
+
+	if contact.Handle != "alice@example.com" {
+		t.Fatalf("Wrong handle : got %s, want %s", contact.Handle, "alice@example.com")
+	}
+
+	contactKey := contact.Key(key.PublicKey())
+	if contactKey == nil {
+		t.Fatalf("Missing identity key")
+	}
+
+	if contactKey.Label != ContactIdentityKeyLabel {
+		t.Fatalf("Wrong label : got %s, want %s", contactKey.Label, ContactIdentityKeyLabel)
+	}
+
+	added, err := RefreshContact(ctx, factory, contact)
+	if err != nil {
+		t.Fatalf("Failed to refresh contact : %s", err)
+	}
+
+	if added {
+		t.Fatalf("Identity key should already be known")
+	}
+
+	challenge := "challenge"
+	signature, err := contact.SignOwnership(key, challenge)
+	if err != nil {
+		t.Fatalf("Failed to sign ownership : %s", err)
+	}
+
+	if err := contact.VerifyOwnership(key.PublicKey(), challenge, signature); err != nil {
+		t.Fatalf("Failed to verify ownership : %s", err)
+	}
+}
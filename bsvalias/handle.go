@@ -0,0 +1,220 @@
+package bsvalias
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxAliasLength is the maximum length of the alias part of a handle.
+	MaxAliasLength = 64
+
+	// MaxDomainLength is the maximum length of the domain part of a handle.
+	MaxDomainLength = 253
+
+	maxDomainLabelLength = 63
+)
+
+// Handle is a parsed and normalized bsvalias (paymail) handle in the form alias@domain.
+//
+// Signatures over handles are only valid if both parties use the same text, so handles should be
+// parsed with ParseHandle and String used wherever a handle is sent or signed.
+type Handle struct {
+	Alias  string
+	Domain string
+}
+
+// ParseHandle parses and normalizes a handle. Surrounding white space is removed, full width
+// forms of ASCII characters, which some input methods produce, are converted to ASCII, and the
+// domain is converted to lower case. The alias keeps its case since some hosts treat it as case
+// sensitive.
+//
+// Aliases may contain ASCII letters, digits, and the characters ".", "_", "-", and "+". They
+// can't start or end with "." or contain "..". Other unicode characters are rejected rather than
+// normalized, since there isn't an agreed normalization form for them. Internationalized domains
+// must be in their ASCII "xn--" form. The domain can include a port.
+func ParseHandle(s string) (Handle, error) {
+	s = normalizeWidth(strings.TrimSpace(s))
+
+	if !utf8.ValidString(s) {
+		return Handle{}, errors.Wrap(ErrInvalidHandle, "invalid utf-8")
+	}
+
+	parts := strings.Split(s, "@")
+	if len(parts) != 2 {
+		return Handle{}, errors.Wrapf(ErrInvalidHandle, "%d @ characters", len(parts)-1)
+	}
+
+	alias := parts[0]
+	if err := validateAlias(alias); err != nil {
+		return Handle{}, errors.Wrap(err, "alias")
+	}
+
+	domain := strings.TrimSuffix(strings.ToLower(parts[1]), ".")
+	if err := validateDomain(domain); err != nil {
+		return Handle{}, errors.Wrap(err, "domain")
+	}
+
+	return Handle{
+		Alias:  alias,
+		Domain: domain,
+	}, nil
+}
+
+// NormalizeHandle returns the canonical text of the handle.
+func NormalizeHandle(s string) (string, error) {
+	handle, err := ParseHandle(s)
+	if err != nil {
+		return "", err
+	}
+
+	return handle.String(), nil
+}
+
+// String returns the canonical text of the handle.
+func (h Handle) String() string {
+	return h.Alias + "@" + h.Domain
+}
+
+// Equal returns true if the handles are the same.
+func (h Handle) Equal(other Handle) bool {
+	return h.Alias == other.Alias && h.Domain == other.Domain
+}
+
+// IsEmpty returns true if the handle has no value.
+func (h Handle) IsEmpty() bool {
+	return len(h.Alias) == 0 && len(h.Domain) == 0
+}
+
+// MarshalText returns the text encoding of the handle.
+// Implements encoding.TextMarshaler interface.
+func (h Handle) MarshalText() ([]byte, error) {
+	if h.IsEmpty() {
+		return []byte{}, nil
+	}
+
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText parses and normalizes the text encoding of a handle.
+// Implements encoding.TextUnmarshaler interface.
+func (h *Handle) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*h = Handle{}
+		return nil
+	}
+
+	handle, err := ParseHandle(string(text))
+	if err != nil {
+		return err
+	}
+
+	*h = handle
+	return nil
+}
+
+// normalizeSenderHandle normalizes an optional sender handle so the text signed is the same text
+// the receiver checks the signature against.
+func normalizeSenderHandle(handle string) (string, error) {
+	if len(handle) == 0 {
+		return "", nil
+	}
+
+	return NormalizeHandle(handle)
+}
+
+func validateAlias(alias string) error {
+	if len(alias) == 0 {
+		return errors.Wrap(ErrInvalidHandle, "empty")
+	}
+
+	if len(alias) > MaxAliasLength {
+		return errors.Wrapf(ErrInvalidHandle, "longer than %d characters", MaxAliasLength)
+	}
+
+	for i, c := range alias {
+		if !isAliasCharacter(c) {
+			return errors.Wrapf(ErrInvalidHandle, "invalid character %q at position %d", c, i)
+		}
+	}
+
+	if alias[0] == '.' || alias[len(alias)-1] == '.' {
+		return errors.Wrap(ErrInvalidHandle, "starts or ends with .")
+	}
+
+	if strings.Contains(alias, "..") {
+		return errors.Wrap(ErrInvalidHandle, "contains ..")
+	}
+
+	return nil
+}
+
+func isAliasCharacter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '.' || c == '_' || c == '-' || c == '+'
+}
+
+// validateDomain checks that the lower case domain is a valid host name with an optional port,
+// which is used for local development hosts like "localhost:8080".
+func validateDomain(domain string) error {
+	if i := strings.LastIndex(domain, ":"); i != -1 {
+		port := domain[i+1:]
+		if len(port) == 0 || len(port) > 5 || strings.Trim(port, "0123456789") != "" {
+			return errors.Wrapf(ErrInvalidHandle, "invalid port : %s", port)
+		}
+		domain = domain[:i]
+	}
+
+	if len(domain) == 0 {
+		return errors.Wrap(ErrInvalidHandle, "empty")
+	}
+
+	if len(domain) > MaxDomainLength {
+		return errors.Wrapf(ErrInvalidHandle, "longer than %d characters", MaxDomainLength)
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) == 0 {
+			return errors.Wrap(ErrInvalidHandle, "empty label")
+		}
+
+		if len(label) > maxDomainLabelLength {
+			return errors.Wrapf(ErrInvalidHandle, "label longer than %d characters",
+				maxDomainLabelLength)
+		}
+
+		for i, c := range label {
+			if c >= utf8.RuneSelf {
+				return errors.Wrapf(ErrInvalidHandle,
+					"non-ASCII character %q at position %d (use xn-- form)", c, i)
+			}
+
+			if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-') {
+				return errors.Wrapf(ErrInvalidHandle, "invalid character %q at position %d", c,
+					i)
+			}
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return errors.Wrapf(ErrInvalidHandle, "label starts or ends with - : %s", label)
+		}
+	}
+
+	return nil
+}
+
+// normalizeWidth converts full width forms of ASCII characters (U+FF01 to U+FF5E) and the small
+// commercial at (U+FE6B) to ASCII, as unicode compatibility normalization does.
+func normalizeWidth(s string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 0xff01 && c <= 0xff5e:
+			return c - 0xff01 + '!'
+		case c == 0xfe6b:
+			return '@'
+		}
+		return c
+	}, s)
+}
@@ -0,0 +1,116 @@
+package bsvalias
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseHandle(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  string
+		valid bool
+	}{
+		{"alice@example.com", "alice@example.com", true},
+		{"  alice@Example.COM \n", "alice@example.com", true},
+		{"Alice.Smith+pay@example.com.", "Alice.Smith+pay@example.com", true},
+		{"first_last-1@sub.example.co.uk", "first_last-1@sub.example.co.uk", true},
+		{"ａｌｉｃｅ＠ｅｘａｍｐｌｅ．ｃｏｍ", "alice@example.com", true}, // full width
+		{"alice﹫example.com", "alice@example.com", true},                        // small at
+		{"bob@xn--bcher-kva.example", "bob@xn--bcher-kva.example", true},
+		{"test@localhost:8080", "test@localhost:8080", true},
+		{"", "", false},
+		{"alice", "", false},
+		{"alice@", "", false},
+		{"@example.com", "", false},
+		{"alice@bob@example.com", "", false},
+		{"al ice@example.com", "", false},
+		{"alicé@example.com", "", false},
+		{".alice@example.com", "", false},
+		{"alice.@example.com", "", false},
+		{"al..ice@example.com", "", false},
+		{"alice@bücher.example", "", false},
+		{"alice@example..com", "", false},
+		{"alice@-example.com", "", false},
+		{"alice@exam_ple.com", "", false},
+		{"alice@example.com:", "", false},
+		{"alice@example.com:80a", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			handle, err := ParseHandle(tt.text)
+			if !tt.valid {
+				if errors.Cause(err) != ErrInvalidHandle {
+					t.Fatalf("Wrong error : got %v, want %s", err, ErrInvalidHandle)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to parse handle : %s", err)
+			}
+
+			if handle.String() != tt.want {
+				t.Fatalf("Wrong handle : got %s, want %s", handle.String(), tt.want)
+			}
+
+			normalized, err := NormalizeHandle(handle.String())
+			if err != nil {
+				t.Fatalf("Failed to normalize handle : %s", err)
+			}
+
+			if normalized != tt.want {
+				t.Fatalf("Normalization not stable : got %s, want %s", normalized, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleJSON(t *testing.T) {
+	var value struct {
+		Handle Handle `json:"handle"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"handle":"bob@EXAMPLE.com"}`), &value); err != nil {
+		t.Fatalf("Failed to unmarshal : %s", err)
+	}
+
+	if !value.Handle.Equal(Handle{Alias: "bob", Domain: "example.com"}) {
+		t.Fatalf("Wrong handle : %s", value.Handle)
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Failed to marshal : %s", err)
+	}
+
+	if string(b) != `{"handle":"bob@example.com"}` {
+		t.Fatalf("Wrong JSON : %s", b)
+	}
+
+	if err := json.Unmarshal([]byte(`{"handle":"bob"}`), &value); err == nil {
+		t.Fatalf("Invalid handle unmarshalled")
+	}
+}
+
+func TestMockHandleNormalization(t *testing.T) {
+	ctx := context.Background()
+	factory := NewMockFactory()
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	factory.AddMockUser("alice@Example.com", key, key)
+
+	if _, err := factory.NewClient(ctx, " alice@EXAMPLE.COM"); err != nil {
+		t.Fatalf("Failed to create client with different form of handle : %s", err)
+	}
+}
@@ -0,0 +1,294 @@
+package bsvalias
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CircuitClosed   = CircuitState(0) // requests are allowed
+	CircuitOpen     = CircuitState(1) // requests are rejected
+	CircuitHalfOpen = CircuitState(2) // probe requests are allowed to check if the host recovered
+)
+
+var (
+	// ErrRateLimited means a request to a host wasn't sent because the host's request rate limit
+	// was reached.
+	ErrRateLimited = errors.New("Rate Limited")
+
+	// ErrCircuitOpen means a request to a host wasn't sent because recent requests to it failed.
+	ErrCircuitOpen = errors.New("Circuit Open")
+)
+
+// CircuitState is the state of the circuit breaker of a host.
+type CircuitState uint8
+
+// HostGuardConfig configures the rate limiting and circuit breaking of requests to each host.
+type HostGuardConfig struct {
+	// RequestsPerSecond is the rate of requests allowed to each host. Zero means no limit.
+	RequestsPerSecond float64
+
+	// Burst is the number of requests that can be sent to a host at once before the rate limit
+	// applies.
+	Burst int
+
+	// MaxWait is the longest a request will wait for the rate limit before failing with
+	// ErrRateLimited. Zero means requests fail immediately when the limit is reached.
+	MaxWait time.Duration
+
+	// FailureThreshold is the number of consecutive failures that opens the circuit of a host.
+	// Zero disables the circuit breaker.
+	FailureThreshold int
+
+	// OpenDuration is how long the circuit stays open before probe requests are allowed.
+	OpenDuration time.Duration
+
+	// HalfOpenProbes is the number of probe requests allowed at the same time while the circuit is
+	// half open.
+	HalfOpenProbes int
+}
+
+// HostGuard limits the rate of requests to each host and stops sending requests to hosts that
+// are failing, so one misbehaving host can't stall or flood requests to others. After
+// FailureThreshold consecutive failures the host's circuit opens and requests fail immediately
+// with ErrCircuitOpen. After OpenDuration the circuit is half open and a limited number of probe
+// requests are sent. A successful probe closes the circuit and a failed probe opens it again.
+type HostGuard struct {
+	config HostGuardConfig
+	hosts  map[string]*hostState
+
+	sync.Mutex
+}
+
+type hostState struct {
+	tokens     float64
+	lastRefill time.Time
+
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int // probe requests in progress while half open
+}
+
+// DefaultHostGuardConfig returns a host guard config with reasonable values.
+func DefaultHostGuardConfig() HostGuardConfig {
+	return HostGuardConfig{
+		RequestsPerSecond: 10.0,
+		Burst:             20,
+		MaxWait:           5 * time.Second,
+		FailureThreshold:  5,
+		OpenDuration:      time.Minute,
+		HalfOpenProbes:    1,
+	}
+}
+
+// NewHostGuard creates a host guard.
+func NewHostGuard(config HostGuardConfig) *HostGuard {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	if config.HalfOpenProbes < 1 {
+		config.HalfOpenProbes = 1
+	}
+
+	return &HostGuard{
+		config: config,
+		hosts:  make(map[string]*hostState),
+	}
+}
+
+// Do calls the function to send a request to the host if the host's circuit allows it and after
+// waiting for the rate limit. The result of the function is recorded for the circuit breaker.
+// Not found responses and canceled contexts are not counted as host failures.
+func (g *HostGuard) Do(ctx context.Context, host string, f func() error) error {
+	isProbe, err := g.allow(host)
+	if err != nil {
+		return err
+	}
+
+	if err := g.wait(ctx, host); err != nil {
+		g.release(host, isProbe)
+		return err
+	}
+
+	err = f()
+	g.record(host, isProbe, err)
+	return err
+}
+
+// State returns the circuit state of the host.
+func (g *HostGuard) State(host string) CircuitState {
+	g.Lock()
+	defer g.Unlock()
+
+	state, exists := g.hosts[host]
+	if !exists {
+		return CircuitClosed
+	}
+
+	if state.state == CircuitOpen && time.Since(state.openedAt) >= g.config.OpenDuration {
+		return CircuitHalfOpen
+	}
+
+	return state.state
+}
+
+// allow returns an error if the host's circuit doesn't allow a request. It returns true if the
+// request is a probe of a half open circuit.
+func (g *HostGuard) allow(host string) (bool, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	state := g.hostState(host)
+
+	if state.state == CircuitOpen {
+		if time.Since(state.openedAt) < g.config.OpenDuration {
+			return false, errors.Wrap(ErrCircuitOpen, host)
+		}
+		state.state = CircuitHalfOpen
+	}
+
+	if state.state == CircuitHalfOpen {
+		if state.probes >= g.config.HalfOpenProbes {
+			return false, errors.Wrap(ErrCircuitOpen, host)
+		}
+		state.probes++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// wait waits until the host's rate limit allows a request. It returns ErrRateLimited if the wait
+// would be longer than MaxWait.
+func (g *HostGuard) wait(ctx context.Context, host string) error {
+	if g.config.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	g.Lock()
+	state := g.hostState(host)
+	now := time.Now()
+	state.tokens += now.Sub(state.lastRefill).Seconds() * g.config.RequestsPerSecond
+	if state.tokens > float64(g.config.Burst) {
+		state.tokens = float64(g.config.Burst)
+	}
+	state.lastRefill = now
+
+	var delay time.Duration
+	if state.tokens < 1.0 {
+		delay = time.Duration((1.0 - state.tokens) / g.config.RequestsPerSecond *
+			float64(time.Second))
+		if delay > g.config.MaxWait {
+			g.Unlock()
+			return errors.Wrap(ErrRateLimited, host)
+		}
+	}
+	state.tokens -= 1.0 // reserve the token
+	g.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		g.Lock()
+		state.tokens += 1.0 // return the reserved token
+		g.Unlock()
+		return ctx.Err()
+	}
+}
+
+// record updates the host's circuit with the result of a request.
+func (g *HostGuard) record(host string, isProbe bool, err error) {
+	g.Lock()
+	defer g.Unlock()
+
+	state := g.hostState(host)
+	if isProbe && state.probes > 0 {
+		state.probes--
+	}
+
+	if !isHostFailure(err) {
+		state.failures = 0
+		if isProbe {
+			state.state = CircuitClosed
+		}
+		return
+	}
+
+	state.failures++
+	if isProbe || (g.config.FailureThreshold > 0 && state.failures >= g.config.FailureThreshold) {
+		state.state = CircuitOpen
+		state.openedAt = time.Now()
+	}
+}
+
+// release frees the probe slot of a request that wasn't sent.
+func (g *HostGuard) release(host string, isProbe bool) {
+	if !isProbe {
+		return
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	if state := g.hostState(host); state.probes > 0 {
+		state.probes--
+	}
+}
+
+func (g *HostGuard) hostState(host string) *hostState {
+	state, exists := g.hosts[host]
+	if !exists {
+		state = &hostState{
+			tokens:     float64(g.config.Burst),
+			lastRefill: time.Now(),
+		}
+		g.hosts[host] = state
+	}
+	return state
+}
+
+// isHostFailure returns true if the error shows the host is misbehaving. Not found and client
+// error responses mean the host is working.
+func isHostFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	cause := errors.Cause(err)
+	if cause == ErrNotFound || cause == context.Canceled {
+		return false
+	}
+
+	if httpErr, ok := cause.(HTTPError); ok {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	return true
+}
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
@@ -0,0 +1,166 @@
+package bsvalias
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestHostGuardCircuit(t *testing.T) {
+	ctx := context.Background()
+	guard := NewHostGuard(HostGuardConfig{
+		FailureThreshold: 3,
+		OpenDuration:     50 * time.Millisecond,
+	})
+
+	failure := errors.New("connection refused")
+	calls := 0
+	fail := func() error {
+		calls++
+		return failure
+	}
+	succeed := func() error {
+		calls++
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := guard.Do(ctx, "bad.com", fail); err != failure {
+			t.Fatalf("Wrong error : got %v, want %v", err, failure)
+		}
+	}
+
+	if state := guard.State("bad.com"); state != CircuitOpen {
+		t.Fatalf("Wrong state : got %s, want %s", state, CircuitOpen)
+	}
+
+	if err := guard.Do(ctx, "bad.com", succeed); errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("Wrong error for open circuit : got %v, want %v", err, ErrCircuitOpen)
+	}
+	if calls != 3 {
+		t.Fatalf("Request should not be sent while circuit is open : %d calls", calls)
+	}
+
+	// Other hosts are not affected.
+	if err := guard.Do(ctx, "good.com", succeed); err != nil {
+		t.Fatalf("Failed request to other host : %s", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if state := guard.State("bad.com"); state != CircuitHalfOpen {
+		t.Fatalf("Wrong state : got %s, want %s", state, CircuitHalfOpen)
+	}
+
+	// Failed probe opens the circuit again.
+	if err := guard.Do(ctx, "bad.com", fail); err != failure {
+		t.Fatalf("Wrong error for probe : got %v, want %v", err, failure)
+	}
+	if state := guard.State("bad.com"); state != CircuitOpen {
+		t.Fatalf("Wrong state after failed probe : got %s, want %s", state, CircuitOpen)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// Successful probe closes the circuit.
+	if err := guard.Do(ctx, "bad.com", succeed); err != nil {
+		t.Fatalf("Failed probe : %s", err)
+	}
+	if state := guard.State("bad.com"); state != CircuitClosed {
+		t.Fatalf("Wrong state after probe : got %s, want %s", state, CircuitClosed)
+	}
+
+	// Not found responses don't count as failures.
+	for i := 0; i < 5; i++ {
+		guard.Do(ctx, "bad.com", func() error {
+			return errors.Wrap(ErrNotFound, "404")
+		})
+	}
+	if state := guard.State("bad.com"); state != CircuitClosed {
+		t.Fatalf("Wrong state after not found : got %s, want %s", state, CircuitClosed)
+	}
+}
+
+func TestHostGuardRateLimit(t *testing.T) {
+	ctx := context.Background()
+	guard := NewHostGuard(HostGuardConfig{
+		RequestsPerSecond: 20.0,
+		Burst:             3,
+	})
+
+	succeed := func() error { return nil }
+
+	for i := 0; i < 3; i++ {
+		if err := guard.Do(ctx, "host.com", succeed); err != nil {
+			t.Fatalf("Failed request %d : %s", i, err)
+		}
+	}
+
+	if err := guard.Do(ctx, "host.com", succeed); errors.Cause(err) != ErrRateLimited {
+		t.Fatalf("Wrong error after burst : got %v, want %v", err, ErrRateLimited)
+	}
+
+	if err := guard.Do(ctx, "other.com", succeed); err != nil {
+		t.Fatalf("Failed request to other host : %s", err)
+	}
+
+	// Waiting up to MaxWait allows the request.
+	waitGuard := NewHostGuard(HostGuardConfig{
+		RequestsPerSecond: 20.0,
+		Burst:             1,
+		MaxWait:           time.Second,
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := waitGuard.Do(ctx, "host.com", succeed); err != nil {
+			t.Fatalf("Failed request %d : %s", i, err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Fatalf("Requests should be delayed by rate limit : %s", elapsed)
+	}
+}
+
+func TestHostGuardHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	site := Site{
+		URL: server.URL,
+		Capabilities: Capabilities{
+			Capabilities: map[string]interface{}{
+				URLNamePKI: server.URL + "/pki/{alias}@{domain.tld}",
+			},
+		},
+	}
+
+	client, err := NewHTTPClientForSite("alias@example.com", site)
+	if err != nil {
+		t.Fatalf("Failed to create client : %s", err)
+	}
+
+	client.Guard = NewHostGuard(HostGuardConfig{
+		FailureThreshold: 2,
+		OpenDuration:     time.Minute,
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := client.GetPublicKey(ctx)
+		if _, ok := errors.Cause(err).(HTTPError); !ok {
+			t.Fatalf("Wrong error : got %v, want HTTPError", err)
+		}
+	}
+
+	if _, err := client.GetPublicKey(ctx); errors.Cause(err) != ErrCircuitOpen {
+		t.Fatalf("Wrong error for open circuit : got %v, want %v", err, ErrCircuitOpen)
+	}
+}
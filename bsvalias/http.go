@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -24,36 +25,71 @@ type HTTPClient struct {
 	Site     Site
 	Alias    string
 	Hostname string
+
+	// Optional guard that rate limits requests to the site's host and stops sending them while
+	// the host is failing.
+	Guard *HostGuard
+}
+
+// HTTPError is an HTTP response with a status code that isn't successful.
+type HTTPError struct {
+	StatusCode int
+	Status     string
 }
 
 // HTTPFactory is a factory for creating HTTP clients.
-type HTTPFactory struct{}
+type HTTPFactory struct {
+	guard *HostGuard
+}
 
 // NewHTTPFactory creates a new HTTP factory.
 func NewHTTPFactory() *HTTPFactory {
 	return &HTTPFactory{}
 }
 
+// SetHostGuard sets a guard that is shared by the clients created by the factory, so requests to
+// each host are rate limited and stopped while the host is failing. Site lookups are guarded by
+// the handle's domain.
+func (f *HTTPFactory) SetHostGuard(guard *HostGuard) {
+	f.guard = guard
+}
+
 // NewClient creates a new client.
 func (f *HTTPFactory) NewClient(ctx context.Context, handle string) (Client, error) {
-	return NewHTTPClient(ctx, handle)
+	if f.guard == nil {
+		return NewHTTPClient(ctx, handle)
+	}
+
+	parsed, err := ParseHandle(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse handle")
+	}
+
+	var client *HTTPClient
+	if err := f.guard.Do(ctx, parsed.Domain, func() error {
+		client, err = NewHTTPClient(ctx, handle)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	client.Guard = f.guard
+	return client, nil
 }
 
 // NewHTTPClient creates a new HTTPClient.
 func NewHTTPClient(ctx context.Context, handle string) (*HTTPClient, error) {
-	result := HTTPClient{
-		Handle: handle,
+	parsed, err := ParseHandle(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse handle")
 	}
 
-	fields := strings.Split(handle, "@")
-	if len(fields) != 2 {
-		return nil, errors.Wrap(ErrInvalidHandle, "split @ not 2")
+	result := HTTPClient{
+		Handle:   parsed.String(),
+		Alias:    parsed.Alias,
+		Hostname: parsed.Domain,
 	}
 
-	result.Alias = fields[0]
-	result.Hostname = fields[1]
-
-	var err error
 	result.Site, err = GetSite(ctx, result.Hostname)
 	if err != nil {
 		return nil, errors.Wrap(err, "get site")
@@ -62,6 +98,22 @@ func NewHTTPClient(ctx context.Context, handle string) (*HTTPClient, error) {
 	return &result, nil
 }
 
+// NewHTTPClientForSite creates a new HTTPClient for a handle hosted by a site that is already
+// known, like a Server run by another agent, so the site doesn't have to be looked up.
+func NewHTTPClientForSite(handle string, site Site) (*HTTPClient, error) {
+	parsed, err := ParseHandle(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse handle")
+	}
+
+	return &HTTPClient{
+		Handle:   parsed.String(),
+		Site:     site,
+		Alias:    parsed.Alias,
+		Hostname: parsed.Domain,
+	}, nil
+}
+
 // GetPublicKey gets the identity public key for the handle.
 func (c *HTTPClient) GetPublicKey(ctx context.Context) (*bitcoin.PublicKey, error) {
 
@@ -74,7 +126,7 @@ func (c *HTTPClient) GetPublicKey(ctx context.Context) (*bitcoin.PublicKey, erro
 	url = strings.ReplaceAll(url, "{domain.tld}", c.Hostname)
 
 	var response PublicKeyResponse
-	if err := get(ctx, url, &response); err != nil {
+	if err := c.get(ctx, url, &response); err != nil {
 		return nil, errors.Wrap(err, "http get")
 	}
 
@@ -97,6 +149,11 @@ func (c *HTTPClient) GetPaymentDestination(ctx context.Context, senderName, send
 		return nil, errors.Wrap(err, "capability url")
 	}
 
+	senderHandle, err = normalizeSenderHandle(senderHandle)
+	if err != nil {
+		return nil, errors.Wrap(err, "sender handle")
+	}
+
 	request := PaymentDestinationRequest{
 		SenderName:   senderName,
 		SenderHandle: senderHandle,
@@ -124,7 +181,7 @@ func (c *HTTPClient) GetPaymentDestination(ctx context.Context, senderName, send
 	url = strings.ReplaceAll(url, "{domain.tld}", c.Hostname)
 
 	var response PaymentDestinationResponse
-	if err := post(ctx, url, request, &response); err != nil {
+	if err := c.post(ctx, url, request, &response); err != nil {
 		return nil, errors.Wrap(err, "http post")
 	}
 
@@ -148,6 +205,11 @@ func (c *HTTPClient) GetPaymentRequest(ctx context.Context, senderName, senderHa
 		return nil, errors.Wrap(err, "capability url")
 	}
 
+	senderHandle, err = normalizeSenderHandle(senderHandle)
+	if err != nil {
+		return nil, errors.Wrap(err, "sender handle")
+	}
+
 	request := PaymentRequestRequest{
 		SenderName:   senderName,
 		SenderHandle: senderHandle,
@@ -176,7 +238,7 @@ func (c *HTTPClient) GetPaymentRequest(ctx context.Context, senderName, senderHa
 	url = strings.ReplaceAll(url, "{domain.tld}", c.Hostname)
 
 	var response PaymentRequestResponse
-	if err := post(ctx, url, request, &response); err != nil {
+	if err := c.post(ctx, url, request, &response); err != nil {
 		return nil, errors.Wrap(err, "http post")
 	}
 
@@ -230,7 +292,7 @@ func (c *HTTPClient) GetP2PPaymentDestination(ctx context.Context,
 	url = strings.ReplaceAll(url, "{domain.tld}", c.Hostname)
 
 	var response P2PPaymentDestinationResponse
-	if err := post(ctx, url, request, &response); err != nil {
+	if err := c.post(ctx, url, request, &response); err != nil {
 		return nil, errors.Wrap(err, "http post")
 	}
 
@@ -265,6 +327,11 @@ func (c *HTTPClient) PostP2PTransaction(ctx context.Context, senderHandle, note,
 		return "", errors.Wrap(err, "capability url")
 	}
 
+	senderHandle, err = normalizeSenderHandle(senderHandle)
+	if err != nil {
+		return "", errors.Wrap(err, "sender handle")
+	}
+
 	txid := *tx.TxHash()
 
 	request := P2PTransactionRequest{
@@ -286,7 +353,7 @@ func (c *HTTPClient) PostP2PTransaction(ctx context.Context, senderHandle, note,
 	url = strings.ReplaceAll(url, "{domain.tld}", c.Hostname)
 
 	var response P2PTransactionResponse
-	if err := post(ctx, url, request, &response); err != nil {
+	if err := c.post(ctx, url, request, &response); err != nil {
 		return "", errors.Wrap(err, "http post")
 	}
 
@@ -308,13 +375,35 @@ func (c *HTTPClient) ListTokenizedInstruments(ctx context.Context) ([]Instrument
 	url = strings.ReplaceAll(url, "{domain.tld}", c.Hostname)
 
 	var response InstrumentAliasListResponse
-	if err := get(ctx, url, &response); err != nil {
+	if err := c.get(ctx, url, &response); err != nil {
 		return nil, errors.Wrap(err, "http get")
 	}
 
 	return response.InstrumentAliases, nil
 }
 
+// get sends a GET request through the client's guard.
+func (c *HTTPClient) get(ctx context.Context, url string, response interface{}) error {
+	if c.Guard == nil {
+		return get(ctx, url, response)
+	}
+
+	return c.Guard.Do(ctx, urlHost(url), func() error {
+		return get(ctx, url, response)
+	})
+}
+
+// post sends a POST request through the client's guard.
+func (c *HTTPClient) post(ctx context.Context, url string, request, response interface{}) error {
+	if c.Guard == nil {
+		return post(ctx, url, request, response)
+	}
+
+	return c.Guard.Do(ctx, urlHost(url), func() error {
+		return post(ctx, url, request, response)
+	})
+}
+
 // post sends a request to the HTTP server using the POST method.
 func post(ctx context.Context, url string, request, response interface{}) error {
 	var transport = &http.Transport{
@@ -343,7 +432,10 @@ func post(ctx context.Context, url string, request, response interface{}) error
 		if httpResponse.StatusCode == 404 {
 			return errors.Wrap(ErrNotFound, httpResponse.Status)
 		}
-		return fmt.Errorf("%v %s", httpResponse.StatusCode, httpResponse.Status)
+		return HTTPError{
+			StatusCode: httpResponse.StatusCode,
+			Status:     httpResponse.Status,
+		}
 	}
 
 	defer httpResponse.Body.Close()
@@ -377,7 +469,10 @@ func get(ctx context.Context, url string, response interface{}) error {
 	}
 
 	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
-		return fmt.Errorf("%v %s", httpResponse.StatusCode, httpResponse.Status)
+		return HTTPError{
+			StatusCode: httpResponse.StatusCode,
+			Status:     httpResponse.Status,
+		}
 	}
 
 	defer httpResponse.Body.Close()
@@ -390,3 +485,16 @@ func get(ctx context.Context, url string, response interface{}) error {
 
 	return nil
 }
+
+func (err HTTPError) Error() string {
+	return fmt.Sprintf("%v %s", err.StatusCode, err.Status)
+}
+
+// urlHost returns the host of the URL, or the URL if it can't be parsed.
+func urlHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || len(parsed.Host) == 0 {
+		return rawURL
+	}
+	return parsed.Host
+}
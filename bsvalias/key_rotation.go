@@ -0,0 +1,277 @@
+package bsvalias
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// URLNameKeyRotation is the name used to identify the key rotation history URL and capability.
+	URLNameKeyRotation = "keyRotation"
+)
+
+var (
+	// ErrBrokenKeyChain means a key rotation history is not a valid chain of signatures.
+	ErrBrokenKeyChain = errors.New("Broken Key Chain")
+
+	// ErrNoValidKey means no key in a key history was valid at the requested time.
+	ErrNoValidKey = errors.New("No Valid Key")
+)
+
+// KeyRotationClient is implemented by clients of hosts that publish key rotation records. It is
+// separate from Client since most hosts don't support it.
+type KeyRotationClient interface {
+	// GetKeyRotations returns the key rotation records of the handle in order.
+	GetKeyRotations(ctx context.Context) ([]KeyRotationRecord, error)
+}
+
+// KeyRotationRecord publishes an identity key for a handle and the time it becomes valid. The
+// first record is signed by its own key and each following record is signed by the key of the
+// previous record, so the chain can be verified back to the first key.
+type KeyRotationRecord struct {
+	PublicKey bitcoin.PublicKey `json:"pubkey"`
+	ValidFrom uint64            `json:"validFrom"` // unix seconds
+	Signature string            `json:"signature"`
+}
+
+// KeyRotationResponse is the raw response from a key rotation endpoint.
+type KeyRotationResponse struct {
+	Version   string              `json:"bsvalias"`
+	Handle    string              `json:"handle"`
+	Rotations []KeyRotationRecord `json:"rotations"`
+}
+
+// KeyValidity is a key from a verified key history with the window in which it was valid.
+type KeyValidity struct {
+	PublicKey bitcoin.PublicKey
+	ValidFrom time.Time
+
+	// ValidUntil is when the key was replaced by the next key. It is zero for the current key.
+	ValidUntil time.Time
+}
+
+// KeyHistory is a verified history of the identity keys of a handle.
+type KeyHistory struct {
+	Handle string
+	Keys   []KeyValidity // oldest first
+}
+
+// SignKeyRotation creates a key rotation record for the public key of the handle signed by the
+// previous key, or by the new key itself if it is the first record. Hosts use it to publish
+// rotations.
+func SignKeyRotation(handle string, publicKey bitcoin.PublicKey, validFrom time.Time,
+	signingKey bitcoin.Key) (*KeyRotationRecord, error) {
+
+	normalized, err := NormalizeHandle(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "handle")
+	}
+
+	result := &KeyRotationRecord{
+		PublicKey: publicKey,
+		ValidFrom: uint64(validFrom.Unix()),
+	}
+
+	sigHash, err := result.sigHash(normalized)
+	if err != nil {
+		return nil, errors.Wrap(err, "signature hash")
+	}
+
+	sig, err := signingKey.Sign(sigHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "sign")
+	}
+
+	result.Signature = sig.ToCompact()
+	return result, nil
+}
+
+// CheckSignature verifies that the record was signed by the public key.
+func (r KeyRotationRecord) CheckSignature(handle string, publicKey bitcoin.PublicKey) error {
+	sigHash, err := r.sigHash(handle)
+	if err != nil {
+		return errors.Wrap(err, "signature hash")
+	}
+
+	sig, err := bitcoin.SignatureFromCompact(r.Signature)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("parse signature: %s", r.Signature))
+	}
+
+	if !sig.Verify(sigHash, publicKey) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (r KeyRotationRecord) sigHash(handle string) (bitcoin.Hash32, error) {
+	return SignatureHashForMessage(handle + r.PublicKey.String() +
+		strconv.FormatUint(r.ValidFrom, 10))
+}
+
+// VerifyKeyRotations verifies the chain of signatures of the key rotation records and returns the
+// key history. If anchor is not nil then it must be one of the keys in the chain. An anchor, like
+// a key previously retrieved from the handle's PKI endpoint, prevents a host from replacing the
+// whole chain.
+func VerifyKeyRotations(handle string, records []KeyRotationRecord,
+	anchor *bitcoin.PublicKey) (*KeyHistory, error) {
+
+	if len(records) == 0 {
+		return nil, errors.Wrap(ErrBrokenKeyChain, "empty")
+	}
+
+	normalized, err := NormalizeHandle(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "handle")
+	}
+
+	result := &KeyHistory{
+		Handle: normalized,
+		Keys:   make([]KeyValidity, 0, len(records)),
+	}
+
+	anchored := anchor == nil
+	for i, record := range records {
+		signer := record.PublicKey // the first record is self signed
+		if i > 0 {
+			previous := records[i-1]
+			if record.ValidFrom <= previous.ValidFrom {
+				return nil, errors.Wrapf(ErrBrokenKeyChain, "record %d: not after previous", i)
+			}
+			signer = previous.PublicKey
+		}
+
+		if err := record.CheckSignature(normalized, signer); err != nil {
+			return nil, errors.Wrapf(ErrBrokenKeyChain, "record %d: %s", i, err)
+		}
+
+		if i > 0 {
+			result.Keys[i-1].ValidUntil = unixTime(record.ValidFrom)
+		}
+
+		result.Keys = append(result.Keys, KeyValidity{
+			PublicKey: record.PublicKey,
+			ValidFrom: unixTime(record.ValidFrom),
+		})
+
+		if !anchored && record.PublicKey.Equal(*anchor) {
+			anchored = true
+		}
+	}
+
+	if !anchored {
+		return nil, errors.Wrap(ErrBrokenKeyChain, "anchor key not in chain")
+	}
+
+	return result, nil
+}
+
+// GetKeyHistory retrieves and verifies the key rotation history of the handle. The current key
+// in the history must be the key returned by the handle's PKI endpoint. It returns ErrNotCapable
+// if the handle's host doesn't publish key rotations.
+func GetKeyHistory(ctx context.Context, factory Factory, handle string) (*KeyHistory, error) {
+	client, err := factory.NewClient(ctx, handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "client")
+	}
+
+	rotationClient, ok := client.(KeyRotationClient)
+	if !ok {
+		return nil, ErrNotCapable
+	}
+
+	records, err := rotationClient.GetKeyRotations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get key rotations")
+	}
+
+	publicKey, err := client.GetPublicKey(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "get public key")
+	}
+
+	history, err := VerifyKeyRotations(handle, records, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if current := history.Current(); !current.PublicKey.Equal(*publicKey) {
+		return nil, errors.Wrap(ErrBrokenKeyChain, "current key doesn't match PKI")
+	}
+
+	return history, nil
+}
+
+// Current returns the most recent key.
+func (h KeyHistory) Current() KeyValidity {
+	return h.Keys[len(h.Keys)-1]
+}
+
+// KeyAt returns the key that was valid at the time.
+func (h KeyHistory) KeyAt(t time.Time) (*KeyValidity, error) {
+	for i := len(h.Keys) - 1; i >= 0; i-- {
+		if !t.Before(h.Keys[i].ValidFrom) {
+			return &h.Keys[i], nil
+		}
+	}
+
+	return nil, errors.Wrapf(ErrNoValidKey, "before first key %s", h.Keys[0].ValidFrom)
+}
+
+// Validity returns the validity window of the public key, or nil if it isn't in the history.
+func (h KeyHistory) Validity(publicKey bitcoin.PublicKey) *KeyValidity {
+	for i, key := range h.Keys {
+		if key.PublicKey.Equal(publicKey) {
+			return &h.Keys[i]
+		}
+	}
+
+	return nil
+}
+
+// VerifySignatureAt verifies a signature of the hash made at the time, so payloads signed by keys
+// that have since been rotated can still be verified.
+func (h KeyHistory) VerifySignatureAt(sigHash bitcoin.Hash32, sig bitcoin.Signature,
+	t time.Time) error {
+
+	key, err := h.KeyAt(t)
+	if err != nil {
+		return err
+	}
+
+	if !sig.Verify(sigHash, key.PublicKey) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// GetKeyRotations returns the key rotation records of the handle in order.
+func (c *HTTPClient) GetKeyRotations(ctx context.Context) ([]KeyRotationRecord, error) {
+	url, err := c.Site.Capabilities.GetURL(URLNameKeyRotation)
+	if err != nil {
+		return nil, errors.Wrap(err, "capability url")
+	}
+
+	url = strings.ReplaceAll(url, "{alias}", c.Alias)
+	url = strings.ReplaceAll(url, "{domain.tld}", c.Hostname)
+
+	var response KeyRotationResponse
+	if err := c.get(ctx, url, &response); err != nil {
+		return nil, errors.Wrap(err, "http get")
+	}
+
+	return response.Rotations, nil
+}
+
+func unixTime(seconds uint64) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
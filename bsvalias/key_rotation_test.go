@@ -0,0 +1,161 @@
+package bsvalias
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestKeyHistory(t *testing.T) {
+	ctx := context.Background()
+	factory := NewMockFactory()
+	handle := "alice@example.com"
+
+	var keys []bitcoin.Key
+	for i := 0; i < 3; i++ {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		keys = append(keys, key)
+	}
+
+	factory.AddMockUser(handle, keys[0], keys[0])
+
+	if _, err := GetKeyHistory(ctx, factory, handle); errors.Cause(err) != ErrNotFound {
+		t.Fatalf("Wrong error without rotations : got %v, want %v", err, ErrNotFound)
+	}
+
+	first := time.Unix(1600000000, 0)
+	second := time.Unix(1700000000, 0)
+	if err := factory.RotateMockUserKey(handle, keys[1], first); err != nil {
+		t.Fatalf("Failed to rotate key : %s", err)
+	}
+	if err := factory.RotateMockUserKey(handle, keys[2], second); err != nil {
+		t.Fatalf("Failed to rotate key : %s", err)
+	}
+
+	history, err := GetKeyHistory(ctx, factory, handle)
+	if err != nil {
+		t.Fatalf("Failed to get key history : %s", err)
+	}
+
+	if len(history.Keys) != 3 {
+		t.Fatalf("Wrong key count : got %d, want %d", len(history.Keys), 3)
+	}
+
+	current := history.Current()
+	if !current.PublicKey.Equal(keys[2].PublicKey()) {
+		t.Fatalf("Wrong current key")
+	}
+	if !current.ValidUntil.IsZero() {
+		t.Fatalf("Current key should not expire : %s", current.ValidUntil)
+	}
+
+	validity := history.Validity(keys[1].PublicKey())
+	if validity == nil {
+		t.Fatalf("Missing validity")
+	}
+	if !validity.ValidFrom.Equal(first) || !validity.ValidUntil.Equal(second) {
+		t.Fatalf("Wrong validity : %s - %s", validity.ValidFrom, validity.ValidUntil)
+	}
+
+	// A payload signed by a rotated key still verifies at the time it was signed.
+	sigHash, err := SignatureHashForMessage("old payload")
+	if err != nil {
+		t.Fatalf("Failed to create signature hash : %s", err)
+	}
+	sig, err := keys[1].Sign(sigHash)
+	if err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	signedAt := first.Add(time.Hour)
+	if err := history.VerifySignatureAt(sigHash, sig, signedAt); err != nil {
+		t.Fatalf("Failed to verify old signature : %s", err)
+	}
+
+	err = history.VerifySignatureAt(sigHash, sig, time.Now())
+	if errors.Cause(err) != ErrInvalidSignature {
+		t.Fatalf("Wrong error for rotated key : got %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestVerifyKeyRotationsInvalid(t *testing.T) {
+	handle := "bob@example.com"
+
+	var keys []bitcoin.Key
+	for i := 0; i < 3; i++ {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		keys = append(keys, key)
+	}
+
+	genesis, err := SignKeyRotation(handle, keys[0].PublicKey(), time.Unix(100, 0), keys[0])
+	if err != nil {
+		t.Fatalf("Failed to sign rotation : %s", err)
+	}
+
+	valid, err := SignKeyRotation(handle, keys[1].PublicKey(), time.Unix(200, 0), keys[0])
+	if err != nil {
+		t.Fatalf("Failed to sign rotation : %s", err)
+	}
+
+	// Signed by a key that isn't the previous key.
+	wrongSigner, err := SignKeyRotation(handle, keys[1].PublicKey(), time.Unix(200, 0), keys[2])
+	if err != nil {
+		t.Fatalf("Failed to sign rotation : %s", err)
+	}
+
+	// Not after the previous record.
+	outOfOrder, err := SignKeyRotation(handle, keys[1].PublicKey(), time.Unix(50, 0), keys[0])
+	if err != nil {
+		t.Fatalf("Failed to sign rotation : %s", err)
+	}
+
+	// Signed for another handle.
+	otherHandle, err := SignKeyRotation("carol@example.com", keys[1].PublicKey(),
+		time.Unix(200, 0), keys[0])
+	if err != nil {
+		t.Fatalf("Failed to sign rotation : %s", err)
+	}
+
+	if _, err := VerifyKeyRotations(handle, []KeyRotationRecord{*genesis, *valid},
+		nil); err != nil {
+		t.Fatalf("Failed to verify valid chain : %s", err)
+	}
+
+	anchor := keys[0].PublicKey()
+	if _, err := VerifyKeyRotations(handle, []KeyRotationRecord{*genesis, *valid},
+		&anchor); err != nil {
+		t.Fatalf("Failed to verify anchored chain : %s", err)
+	}
+
+	unknown := keys[2].PublicKey()
+	tests := []struct {
+		name    string
+		records []KeyRotationRecord
+		anchor  *bitcoin.PublicKey
+	}{
+		{"empty", nil, nil},
+		{"wrong signer", []KeyRotationRecord{*genesis, *wrongSigner}, nil},
+		{"out of order", []KeyRotationRecord{*genesis, *outOfOrder}, nil},
+		{"other handle", []KeyRotationRecord{*genesis, *otherHandle}, nil},
+		{"unknown anchor", []KeyRotationRecord{*genesis, *valid}, &unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyKeyRotations(handle, tt.records, tt.anchor)
+			if errors.Cause(err) != ErrBrokenKeyChain {
+				t.Fatalf("Wrong error : got %v, want %v", err, ErrBrokenKeyChain)
+			}
+		})
+	}
+}
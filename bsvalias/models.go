@@ -39,10 +39,15 @@ type PaymentDestinationRequest struct {
 	Signature    string `json:"signature"`
 }
 
+// SignatureHash returns the hash of the request's signed message.
+func (r PaymentDestinationRequest) SignatureHash() (bitcoin.Hash32, error) {
+	return SignatureHashForMessage(r.SenderHandle + strconv.FormatUint(r.Amount, 10) +
+		r.DateTime + r.Purpose)
+}
+
 // Sign adds a signature to the request. The key should correspond to the sender handle's PKI.
 func (r *PaymentDestinationRequest) Sign(key bitcoin.Key) error {
-	sigHash, err := SignatureHashForMessage(r.SenderHandle + strconv.FormatUint(r.Amount, 10) +
-		r.DateTime + r.Purpose)
+	sigHash, err := r.SignatureHash()
 	if err != nil {
 		return errors.Wrap(err, "signature hash")
 	}
@@ -57,8 +62,7 @@ func (r *PaymentDestinationRequest) Sign(key bitcoin.Key) error {
 }
 
 func (r PaymentDestinationRequest) CheckSignature(publicKey bitcoin.PublicKey) error {
-	sigHash, err := SignatureHashForMessage(r.SenderHandle + strconv.FormatUint(r.Amount, 10) +
-		r.DateTime + r.Purpose)
+	sigHash, err := r.SignatureHash()
 	if err != nil {
 		return errors.Wrap(err, "signature hash")
 	}
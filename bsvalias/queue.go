@@ -0,0 +1,437 @@
+package bsvalias
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/threads"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	RequestTypePublicKey                = RequestType(0)
+	RequestTypePaymentDestination       = RequestType(1)
+	RequestTypePaymentRequest           = RequestType(2)
+	RequestTypeP2PPaymentDestination    = RequestType(3)
+	RequestTypeP2PTransaction           = RequestType(4)
+	RequestTypeListTokenizedInstruments = RequestType(5)
+
+	// DefaultQueuePath is the storage path under which queued requests are saved.
+	DefaultQueuePath = "bsvalias/queue"
+)
+
+var (
+	// ErrQueued means a request couldn't be completed now and was queued to be retried. The
+	// result will be passed to the queue's callback.
+	ErrQueued = errors.New("Queued")
+
+	// ErrMaxAttempts means a queued request failed too many times and was removed from the queue.
+	ErrMaxAttempts = errors.New("Max Attempts")
+
+	// ErrInvalidRequest means a queued request is missing data needed for its type.
+	ErrInvalidRequest = errors.New("Invalid Request")
+)
+
+// RequestType specifies which bsvalias request is queued.
+type RequestType uint8
+
+// QueuedRequest contains the parameters of a bsvalias request so that it can be saved and retried.
+// Only the fields used by the request type need to be set.
+type QueuedRequest struct {
+	ID     uuid.UUID   `json:"id"`
+	Handle string      `json:"handle"`
+	Type   RequestType `json:"type"`
+
+	SenderName   string      `json:"sender_name,omitempty"`
+	SenderHandle string      `json:"sender_handle,omitempty"`
+	Purpose      string      `json:"purpose,omitempty"`
+	InstrumentID string      `json:"instrument_id,omitempty"`
+	Amount       uint64      `json:"amount,omitempty"`
+	Note         string      `json:"note,omitempty"`
+	Reference    string      `json:"reference,omitempty"`
+	Tx           *wire.MsgTx `json:"tx,omitempty"`
+
+	Created     time.Time `json:"created"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// QueuedResult is the response to a bsvalias request. Only the field for the request type is set.
+type QueuedResult struct {
+	PublicKey             *bitcoin.PublicKey
+	LockingScript         bitcoin.Script
+	PaymentRequest        *PaymentRequest
+	P2PPaymentDestination *P2PPaymentDestinationOutputs
+	Note                  string
+	InstrumentAliases     []InstrumentAlias
+}
+
+// QueueCallback is called when a queued request succeeds, or fails permanently. err is nil on
+// success.
+type QueueCallback func(ctx context.Context, request *QueuedRequest, result *QueuedResult,
+	err error)
+
+// QueueConfig configures the retries of a request queue.
+type QueueConfig struct {
+	// MaxAttempts is the number of attempts before a request fails. Zero means no limit.
+	MaxAttempts int
+
+	// RetryDelay is the delay before the first retry. It is doubled after each attempt until it
+	// reaches MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// SenderKey is used to sign requests that include a sender handle. It isn't saved with the
+	// requests so it must be the key for every sender handle used with the queue.
+	SenderKey *bitcoin.Key
+}
+
+// Queue makes bsvalias requests that are retried when they fail because the host, or the local
+// network, is unavailable. Pending requests are saved in storage so they survive restarts, and
+// the results of queued requests are passed to the callback. This supports agents that are often
+// offline.
+type Queue struct {
+	store    storage.Storage
+	path     string
+	factory  Factory
+	config   QueueConfig
+	callback QueueCallback
+
+	requests map[uuid.UUID]*QueuedRequest
+	trigger  chan interface{}
+
+	sync.Mutex
+}
+
+// NewQueue creates a request queue. Load must be called to restore requests from storage.
+func NewQueue(store storage.Storage, factory Factory, config QueueConfig,
+	callback QueueCallback) *Queue {
+
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = time.Second
+	}
+	if config.MaxRetryDelay < config.RetryDelay {
+		config.MaxRetryDelay = config.RetryDelay
+	}
+
+	return &Queue{
+		store:    store,
+		path:     DefaultQueuePath,
+		factory:  factory,
+		config:   config,
+		callback: callback,
+		requests: make(map[uuid.UUID]*QueuedRequest),
+		trigger:  make(chan interface{}, 1),
+	}
+}
+
+// Load reads the pending requests from storage.
+func (q *Queue) Load(ctx context.Context) error {
+	keys, err := q.store.List(ctx, q.path)
+	if err != nil {
+		return errors.Wrap(err, "list")
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	for _, key := range keys {
+		b, err := q.store.Read(ctx, key)
+		if err != nil {
+			return errors.Wrapf(err, "read %s", key)
+		}
+
+		request := &QueuedRequest{}
+		if err := json.Unmarshal(b, request); err != nil {
+			return errors.Wrapf(err, "unmarshal %s", key)
+		}
+
+		q.requests[request.ID] = request
+	}
+
+	return nil
+}
+
+// Do attempts the request immediately. If it fails because of a temporary problem, like the host
+// being unreachable, the request is saved and retried later and ErrQueued is returned. The result
+// is then passed to the queue's callback.
+func (q *Queue) Do(ctx context.Context, request *QueuedRequest) (*QueuedResult, error) {
+	q.prepare(request)
+
+	result, err := q.attempt(ctx, request)
+	if err == nil {
+		return result, nil
+	}
+
+	if !isRetryable(err) {
+		return nil, err
+	}
+
+	if err := q.retryLater(ctx, request, err); err != nil {
+		return nil, errors.Wrap(err, "queue")
+	}
+
+	return nil, errors.Wrap(ErrQueued, err.Error())
+}
+
+// Enqueue saves the request to be attempted by Run, without trying it now. Use it when the agent
+// is known to be offline.
+func (q *Queue) Enqueue(ctx context.Context, request *QueuedRequest) error {
+	q.prepare(request)
+	request.NextAttempt = time.Now()
+
+	if err := q.save(ctx, request); err != nil {
+		return errors.Wrap(err, "save")
+	}
+
+	q.Lock()
+	q.requests[request.ID] = request
+	q.Unlock()
+
+	q.Retry()
+	return nil
+}
+
+// Retry makes all pending requests due immediately. Call it when connectivity returns.
+func (q *Queue) Retry() {
+	q.Lock()
+	now := time.Now()
+	for _, request := range q.requests {
+		request.NextAttempt = now
+	}
+	q.Unlock()
+
+	select {
+	case q.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns the requests waiting to be retried, oldest first.
+func (q *Queue) Pending() []QueuedRequest {
+	q.Lock()
+	defer q.Unlock()
+
+	result := make([]QueuedRequest, 0, len(q.requests))
+	for _, request := range q.requests {
+		result = append(result, *request)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+
+	return result
+}
+
+// Run attempts pending requests when they are due until the interrupt is closed.
+func (q *Queue) Run(ctx context.Context, interrupt <-chan interface{}) error {
+	for {
+		q.processDue(ctx)
+
+		var timer *time.Timer
+		var wait <-chan time.Time
+		if next := q.nextAttempt(); !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			wait = timer.C
+		}
+
+		select {
+		case <-interrupt:
+			if timer != nil {
+				timer.Stop()
+			}
+			return threads.Interrupted
+		case <-q.trigger:
+		case <-wait:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (q *Queue) processDue(ctx context.Context) {
+	now := time.Now()
+	var due []*QueuedRequest
+	q.Lock()
+	for _, request := range q.requests {
+		if !request.NextAttempt.After(now) {
+			due = append(due, request)
+		}
+	}
+	q.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Created.Before(due[j].Created)
+	})
+
+	for _, request := range due {
+		result, err := q.attempt(ctx, request)
+		if err != nil && isRetryable(err) {
+			if q.config.MaxAttempts == 0 || request.Attempts < q.config.MaxAttempts {
+				if err := q.retryLater(ctx, request, err); err != nil {
+					q.finish(ctx, request, nil, errors.Wrap(err, "queue"))
+				}
+				continue
+			}
+
+			err = errors.Wrap(ErrMaxAttempts, err.Error())
+		}
+
+		q.finish(ctx, request, result, err)
+	}
+}
+
+func (q *Queue) nextAttempt() time.Time {
+	q.Lock()
+	defer q.Unlock()
+
+	var result time.Time
+	for _, request := range q.requests {
+		if result.IsZero() || request.NextAttempt.Before(result) {
+			result = request.NextAttempt
+		}
+	}
+
+	return result
+}
+
+func (q *Queue) prepare(request *QueuedRequest) {
+	if request.ID == uuid.Nil {
+		request.ID = uuid.New()
+	}
+	if request.Created.IsZero() {
+		request.Created = time.Now()
+	}
+}
+
+// retryLater schedules the next attempt of a request that failed and saves it.
+func (q *Queue) retryLater(ctx context.Context, request *QueuedRequest, err error) error {
+	delay := q.config.RetryDelay
+	for i := 1; i < request.Attempts && delay < q.config.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > q.config.MaxRetryDelay {
+		delay = q.config.MaxRetryDelay
+	}
+
+	q.Lock()
+	request.NextAttempt = time.Now().Add(delay)
+	request.LastError = err.Error()
+	q.requests[request.ID] = request
+	b, merr := json.Marshal(request)
+	q.Unlock()
+
+	if merr != nil {
+		return errors.Wrap(merr, "marshal")
+	}
+
+	return q.store.Write(ctx, q.requestPath(request.ID), b, nil)
+}
+
+// finish removes a request from the queue and passes its result to the callback.
+func (q *Queue) finish(ctx context.Context, request *QueuedRequest, result *QueuedResult,
+	err error) {
+
+	q.Lock()
+	delete(q.requests, request.ID)
+	q.Unlock()
+
+	if rerr := q.store.Remove(ctx, q.requestPath(request.ID)); rerr != nil &&
+		errors.Cause(rerr) != storage.ErrNotFound {
+		err = errors.Wrap(rerr, "remove")
+	}
+
+	if q.callback != nil {
+		q.callback(ctx, request, result, err)
+	}
+}
+
+func (q *Queue) save(ctx context.Context, request *QueuedRequest) error {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	return q.store.Write(ctx, q.requestPath(request.ID), b, nil)
+}
+
+func (q *Queue) requestPath(id uuid.UUID) string {
+	return fmt.Sprintf("%s/%s", q.path, id)
+}
+
+// attempt makes the request.
+func (q *Queue) attempt(ctx context.Context, request *QueuedRequest) (*QueuedResult, error) {
+	q.Lock()
+	request.Attempts++
+	q.Unlock()
+
+	client, err := q.factory.NewClient(ctx, request.Handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "client")
+	}
+
+	var senderKey *bitcoin.Key
+	if len(request.SenderHandle) > 0 {
+		senderKey = q.config.SenderKey
+	}
+
+	result := &QueuedResult{}
+	switch request.Type {
+	case RequestTypePublicKey:
+		result.PublicKey, err = client.GetPublicKey(ctx)
+
+	case RequestTypePaymentDestination:
+		result.LockingScript, err = client.GetPaymentDestination(ctx, request.SenderName,
+			request.SenderHandle, request.Purpose, request.Amount, senderKey)
+
+	case RequestTypePaymentRequest:
+		result.PaymentRequest, err = client.GetPaymentRequest(ctx, request.SenderName,
+			request.SenderHandle, request.Purpose, request.InstrumentID, request.Amount, senderKey)
+
+	case RequestTypeP2PPaymentDestination:
+		result.P2PPaymentDestination, err = client.GetP2PPaymentDestination(ctx, request.Amount)
+
+	case RequestTypeP2PTransaction:
+		if request.Tx == nil {
+			return nil, errors.Wrap(ErrInvalidRequest, "missing tx")
+		}
+		result.Note, err = client.PostP2PTransaction(ctx, request.SenderHandle, request.Note,
+			request.Reference, senderKey, request.Tx)
+
+	case RequestTypeListTokenizedInstruments:
+		result.InstrumentAliases, err = client.ListTokenizedInstruments(ctx)
+
+	default:
+		return nil, errors.Wrapf(ErrInvalidRequest, "unknown type %d", request.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// isRetryable returns false for errors that will not be fixed by retrying the request.
+func isRetryable(err error) bool {
+	switch errors.Cause(err) {
+	case ErrInvalidHandle, ErrNotCapable, ErrInvalidSignature, ErrNotFound,
+		ErrWrongOutputCount, ErrInvalidRequest:
+		return false
+	}
+
+	return true
+}
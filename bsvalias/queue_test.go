@@ -0,0 +1,201 @@
+package bsvalias
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+var errOffline = errors.New("Network unreachable")
+
+// offlineFactory wraps a mock factory and fails to create clients while offline.
+type offlineFactory struct {
+	factory *MockFactory
+	offline bool
+
+	sync.Mutex
+}
+
+func (f *offlineFactory) NewClient(ctx context.Context, handle string) (Client, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.offline {
+		return nil, errOffline
+	}
+	return f.factory.NewClient(ctx, handle)
+}
+
+func (f *offlineFactory) setOffline(offline bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.offline = offline
+}
+
+type queueResult struct {
+	request *QueuedRequest
+	result  *QueuedResult
+	err     error
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+	factory := &offlineFactory{factory: NewMockFactory()}
+
+	handle, _, _, err := factory.factory.GenerateMockUser("test.com", bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate user : %s", err)
+	}
+
+	config := QueueConfig{
+		RetryDelay:    time.Hour,
+		MaxRetryDelay: time.Hour,
+	}
+	queue := NewQueue(store, factory, config, nil)
+
+	result, err := queue.Do(ctx, &QueuedRequest{
+		Handle: *handle,
+		Type:   RequestTypeP2PPaymentDestination,
+		Amount: 1000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to get payment destination : %s", err)
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxOut(result.P2PPaymentDestination.Outputs[0])
+
+	factory.setOffline(true)
+
+	_, err = queue.Do(ctx, &QueuedRequest{
+		Handle:       *handle,
+		Type:         RequestTypeP2PTransaction,
+		SenderHandle: "sender@test.com",
+		Reference:    result.P2PPaymentDestination.Reference,
+		Tx:           tx,
+	})
+	if errors.Cause(err) != ErrQueued {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrQueued)
+	}
+
+	// Permanent errors aren't queued.
+	factory.setOffline(false)
+	_, err = queue.Do(ctx, &QueuedRequest{
+		Handle: "unknown@test.com",
+		Type:   RequestTypePublicKey,
+	})
+	if errors.Cause(err) != ErrInvalidHandle {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrInvalidHandle)
+	}
+
+	// Restore the queue from storage as if the agent restarted.
+	results := make(chan queueResult, 1)
+	restored := NewQueue(store, factory, config, func(ctx context.Context,
+		request *QueuedRequest, result *QueuedResult, err error) {
+		results <- queueResult{request: request, result: result, err: err}
+	})
+	if err := restored.Load(ctx); err != nil {
+		t.Fatalf("Failed to load queue : %s", err)
+	}
+
+	pending := restored.Pending()
+	if len(pending) != 1 || pending[0].Attempts != 1 || !pending[0].Tx.TxHash().Equal(tx.TxHash()) {
+		t.Fatalf("Wrong pending requests : %+v", pending)
+	}
+
+	interrupt := make(chan interface{})
+	var wait sync.WaitGroup
+	wait.Add(1)
+	go func() {
+		restored.Run(ctx, interrupt)
+		wait.Done()
+	}()
+
+	// Connectivity returns.
+	restored.Retry()
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatalf("Queued request failed : %s", r.err)
+		}
+		if r.result.Note != "Accepted" {
+			t.Errorf("Wrong note : got %s, want %s", r.result.Note, "Accepted")
+		}
+		if r.request.Attempts != 2 {
+			t.Errorf("Wrong attempts : got %d, want %d", r.request.Attempts, 2)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for queued request")
+	}
+
+	close(interrupt)
+	wait.Wait()
+
+	client, _ := factory.factory.NewClient(ctx, *handle)
+	if err := client.(*MockClient).CheckP2PTx(*tx.TxHash()); err != nil {
+		t.Errorf("Tx not posted : %s", err)
+	}
+
+	if len(restored.Pending()) != 0 {
+		t.Errorf("Queue should be empty")
+	}
+
+	keys, _ := store.List(ctx, DefaultQueuePath)
+	if len(keys) != 0 {
+		t.Errorf("Storage should be empty : %v", keys)
+	}
+}
+
+func TestQueueMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	factory := &offlineFactory{factory: NewMockFactory(), offline: true}
+
+	results := make(chan queueResult, 1)
+	queue := NewQueue(storage.NewMockStorage(), factory, QueueConfig{
+		MaxAttempts:   3,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 5 * time.Millisecond,
+	}, func(ctx context.Context, request *QueuedRequest, result *QueuedResult, err error) {
+		results <- queueResult{request: request, result: result, err: err}
+	})
+
+	interrupt := make(chan interface{})
+	var wait sync.WaitGroup
+	wait.Add(1)
+	go func() {
+		queue.Run(ctx, interrupt)
+		wait.Done()
+	}()
+
+	if err := queue.Enqueue(ctx, &QueuedRequest{
+		Handle: "user@test.com",
+		Type:   RequestTypePublicKey,
+	}); err != nil {
+		t.Fatalf("Failed to enqueue request : %s", err)
+	}
+
+	select {
+	case r := <-results:
+		if errors.Cause(r.err) != ErrMaxAttempts {
+			t.Errorf("Wrong error : got %v, want %v", r.err, ErrMaxAttempts)
+		}
+		if r.request.Attempts != 3 {
+			t.Errorf("Wrong attempts : got %d, want %d", r.request.Attempts, 3)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for failure")
+	}
+
+	close(interrupt)
+	wait.Wait()
+}
@@ -0,0 +1,136 @@
+package bsvalias
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrRequestExpired means a request's date time is outside of the accepted window.
+	ErrRequestExpired = errors.New("Request Expired")
+
+	// ErrReplayed means a signed request was already received.
+	ErrReplayed = errors.New("Replayed Request")
+)
+
+// ReplayGuardConfig configures the replay protection of signed requests.
+type ReplayGuardConfig struct {
+	// Window is how far a request's date time can be from the current time, in either direction.
+	// Signed messages are remembered for the window after their request's date time, so requests
+	// can't be replayed while they are still accepted.
+	Window time.Duration
+}
+
+// ReplayGuard rejects requests with old date times and signed requests that were already received,
+// so a captured request can't be replayed, for example to drain pre-generated payment
+// destinations.
+//
+// Requests are identified by the recipient's handle and the hash of the signed message rather than
+// the signature itself because the same message can have many valid signature encodings. The
+// signed message doesn't contain the recipient, so the same message sent to different recipients
+// are different requests. Unsigned requests can't be identified, so they only have their date time
+// checked and can be replayed within the window.
+type ReplayGuard struct {
+	config ReplayGuardConfig
+
+	seen      map[replayKey]time.Time // to expiry
+	nextPrune time.Time
+
+	sync.Mutex
+}
+
+// replayKey identifies a signed request.
+type replayKey struct {
+	recipient string // normalized handle
+	sigHash   bitcoin.Hash32
+}
+
+// DefaultReplayGuardConfig returns a replay guard config with reasonable values.
+func DefaultReplayGuardConfig() ReplayGuardConfig {
+	return ReplayGuardConfig{
+		Window: 5 * time.Minute,
+	}
+}
+
+// NewReplayGuard creates a replay guard.
+func NewReplayGuard(config ReplayGuardConfig) *ReplayGuard {
+	if config.Window <= 0 {
+		config.Window = DefaultReplayGuardConfig().Window
+	}
+
+	return &ReplayGuard{
+		config: config,
+		seen:   make(map[replayKey]time.Time),
+	}
+}
+
+// Check returns ErrRequestExpired if the request's date time isn't within the window of the
+// current time and ErrReplayed if the signed message hash was already checked for the recipient,
+// which should be a normalized handle. Requests without a signature should pass a nil hash and
+// only have their date time checked. The hash should be checked with Check after the signature is
+// verified so invalid requests don't block valid ones.
+func (g *ReplayGuard) Check(recipient, dateTime string, sigHash *bitcoin.Hash32) error {
+	requestTime, err := ParseDateTime(dateTime)
+	if err != nil {
+		return errors.Wrap(ErrRequestExpired, err.Error())
+	}
+
+	now := time.Now()
+	if requestTime.Before(now.Add(-g.config.Window)) || requestTime.After(now.Add(g.config.Window)) {
+		return errors.Wrapf(ErrRequestExpired, "%s", dateTime)
+	}
+
+	if sigHash == nil {
+		return nil
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	g.prune(now)
+
+	key := replayKey{
+		recipient: recipient,
+		sigHash:   *sigHash,
+	}
+
+	if _, exists := g.seen[key]; exists {
+		return ErrReplayed
+	}
+
+	g.seen[key] = requestTime.Add(g.config.Window)
+	return nil
+}
+
+// ParseDateTime parses a bsvalias request date time, which is in ISO 8601 format.
+func ParseDateTime(dateTime string) (time.Time, error) {
+	if len(dateTime) == 0 {
+		return time.Time{}, errors.New("Missing date time")
+	}
+
+	result, err := time.Parse(time.RFC3339Nano, dateTime)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "parse")
+	}
+
+	return result, nil
+}
+
+// prune removes the signed requests that are no longer within the window. It is done at most once
+// a minute.
+func (g *ReplayGuard) prune(now time.Time) {
+	if now.Before(g.nextPrune) {
+		return
+	}
+	g.nextPrune = now.Add(time.Minute)
+
+	for key, expiry := range g.seen {
+		if now.After(expiry) {
+			delete(g.seen, key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package bsvalias
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestReplayGuard(t *testing.T) {
+	guard := NewReplayGuard(ReplayGuardConfig{Window: time.Minute})
+
+	hash1 := bitcoin.Hash32{1}
+	hash2 := bitcoin.Hash32{2}
+	hash3 := bitcoin.Hash32{3}
+
+	now := time.Now().UTC().Format("2006-01-02T15:04:05.999Z")
+	if err := guard.Check("alice@example.com", now, &hash1); err != nil {
+		t.Fatalf("Failed to check request : %s", err)
+	}
+	if err := guard.Check("alice@example.com", now, &hash1); errors.Cause(err) != ErrReplayed {
+		t.Fatalf("Wrong error for replay : got %v, want %v", err, ErrReplayed)
+	}
+	if err := guard.Check("alice@example.com", now, &hash2); err != nil {
+		t.Fatalf("Failed to check request : %s", err)
+	}
+
+	// The same signed message sent to another recipient isn't a replay.
+	if err := guard.Check("bob@example.com", now, &hash1); err != nil {
+		t.Fatalf("Failed to check request for other recipient : %s", err)
+	}
+	if err := guard.Check("bob@example.com", now, &hash1); errors.Cause(err) != ErrReplayed {
+		t.Fatalf("Wrong error for replay : got %v, want %v", err, ErrReplayed)
+	}
+
+	// Unsigned requests only have their date time checked.
+	for i := 0; i < 2; i++ {
+		if err := guard.Check("alice@example.com", now, nil); err != nil {
+			t.Fatalf("Failed to check unsigned request : %s", err)
+		}
+	}
+
+	for _, dateTime := range []string{
+		time.Now().Add(-2 * time.Minute).UTC().Format(time.RFC3339),
+		time.Now().Add(2 * time.Minute).UTC().Format(time.RFC3339),
+		"",
+		"yesterday",
+	} {
+		if err := guard.Check("alice@example.com", dateTime,
+			&hash3); errors.Cause(err) != ErrRequestExpired {
+			t.Fatalf("Wrong error for date time %q : got %v, want %v", dateTime, err,
+				ErrRequestExpired)
+		}
+	}
+}
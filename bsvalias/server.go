@@ -0,0 +1,562 @@
+package bsvalias
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	serverStoragePath = "bsvalias"
+
+	// ServerVersion is the bsvalias version returned in the server's capabilities.
+	ServerVersion = "1.0"
+
+	// receiveTransactionPath is the path of the P2P receive transaction endpoint. The handle
+	// follows it.
+	receiveTransactionPath = "/api/v1/bsvalias/receive-transaction/"
+
+	// paymentDestinationPath is the path of the payment destination endpoint. The handle follows
+	// it.
+	paymentDestinationPath = "/api/v1/bsvalias/address/"
+
+	// maxPaymentDestinationRequestSize is the largest payment destination request body that is
+	// accepted.
+	maxPaymentDestinationRequestSize = 64 * 1024
+
+	// maxP2PTransactionRequestSize is the largest P2P transaction request body that is accepted.
+	maxP2PTransactionRequestSize = 10 * 1024 * 1024
+)
+
+// P2PTransactionRecord is a P2P transaction received by a Server.
+type P2PTransactionRecord struct {
+	Handle    string                 `json:"handle"`
+	Tx        *wire.MsgTx            `json:"tx"`
+	MetaData  P2PTransactionMetaData `json:"metadata"`
+	Reference string                 `json:"reference"`
+
+	// SenderVerified is true when the tx was signed by the PKI key of the sender's handle.
+	SenderVerified bool `json:"sender_verified"`
+
+	// Note is returned to the sender when the tx is accepted.
+	Note string `json:"note,omitempty"`
+
+	Received time.Time `json:"received"`
+}
+
+// P2PTransactionHandler decides whether a Server accepts a P2P transaction.
+type P2PTransactionHandler interface {
+	// HandleP2PTransaction is called with each new transaction after the sender's signature has
+	// been verified and before the transaction is recorded. It returns the note that is sent back
+	// to the sender, or an error to reject the transaction. Return ErrNotFound when the handle or
+	// reference is not known and ErrRejected when the transaction isn't acceptable.
+	HandleP2PTransaction(ctx context.Context, record *P2PTransactionRecord) (string, error)
+}
+
+// PaymentDestinationHandler provides the locking scripts returned by a Server's payment
+// destination endpoint.
+type PaymentDestinationHandler interface {
+	// HandlePaymentDestination is called with each payment destination request after its date
+	// time and signature have been checked. senderVerified is true when the request was signed by
+	// the sender's PKI key. It returns the locking script the sender should pay. Return
+	// ErrNotFound when the handle is not known.
+	HandlePaymentDestination(ctx context.Context, handle string,
+		request *PaymentDestinationRequest, senderVerified bool) (bitcoin.Script, error)
+}
+
+// Server is the receiving side of the P2P transactions capability. It verifies transactions posted
+// by HTTPClient.PostP2PTransaction, records them in a storage.Storage, and acknowledges them with
+// the txid. It implements http.Handler for the receive transaction and capabilities endpoints.
+type Server struct {
+	baseURL string
+	store   storage.Storage
+
+	handler          P2PTransactionHandler
+	factory          Factory
+	requireSignature bool
+
+	paymentDestinationHandler PaymentDestinationHandler
+	replayGuard               *ReplayGuard
+
+	lock sync.Mutex
+}
+
+// NewServer creates a server that is reachable at baseURL, like "https://example.com", and
+// records transactions in the store.
+func NewServer(baseURL string, store storage.Storage) *Server {
+	return &Server{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		store:       store,
+		replayGuard: NewReplayGuard(DefaultReplayGuardConfig()),
+	}
+}
+
+// SetHandler sets the handler that accepts or rejects transactions. Without a handler all valid
+// transactions are accepted.
+func (s *Server) SetHandler(handler P2PTransactionHandler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.handler = handler
+}
+
+// SetFactory sets the factory used to retrieve the PKI public key of sender handles. Without a
+// factory signatures are only checked against the public key in the request's metadata, which
+// doesn't verify the sender.
+func (s *Server) SetFactory(factory Factory) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.factory = factory
+}
+
+// SetPaymentDestinationHandler sets the handler that provides payment destinations and enables the
+// payment destination endpoint.
+func (s *Server) SetPaymentDestinationHandler(handler PaymentDestinationHandler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.paymentDestinationHandler = handler
+}
+
+// SetReplayGuard sets the replay guard that checks the date time and signature of payment
+// destination requests. The server uses a guard with DefaultReplayGuardConfig by default.
+func (s *Server) SetReplayGuard(guard *ReplayGuard) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.replayGuard = guard
+}
+
+// SetRequireSignature sets whether transactions and payment destination requests must be signed by
+// the sender. Signed requests must include the sender's handle, and a factory must be set to
+// retrieve the sender's PKI public key.
+func (s *Server) SetRequireSignature(require bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requireSignature = require
+}
+
+// Capabilities returns the capabilities that are served at "/.well-known/bsvalias".
+func (s *Server) Capabilities() Capabilities {
+	s.lock.Lock()
+	hasPaymentDestination := s.paymentDestinationHandler != nil
+	s.lock.Unlock()
+
+	result := Capabilities{
+		Version: ServerVersion,
+		Capabilities: map[string]interface{}{
+			URLNameP2PTransactions: s.baseURL + receiveTransactionPath + "{alias}@{domain.tld}",
+		},
+	}
+
+	if hasPaymentDestination {
+		result.Capabilities[URLNamePaymentDestination] = s.baseURL + paymentDestinationPath +
+			"{alias}@{domain.tld}"
+	}
+
+	return result
+}
+
+// Site returns the site that clients use to reach the server.
+func (s *Server) Site() Site {
+	return Site{
+		Capabilities: s.Capabilities(),
+		URL:          s.baseURL,
+	}
+}
+
+// ServeHTTP routes capabilities, payment destination, and receive transaction requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.URL.Path == "/.well-known/bsvalias" && r.Method == http.MethodGet {
+		if err := writeJSON(w, s.Capabilities()); err != nil {
+			writeError(w, err)
+		}
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, paymentDestinationPath) {
+		s.servePaymentDestination(w, r)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, receiveTransactionPath) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	handle := strings.TrimPrefix(r.URL.Path, receiveTransactionPath)
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxP2PTransactionRequestSize))
+	if err != nil {
+		writeError(w, badRequest(errors.Wrap(err, "read body")))
+		return
+	}
+
+	request := &P2PTransactionRequest{}
+	if err := json.Unmarshal(b, request); err != nil {
+		writeError(w, badRequest(errors.Wrap(err, "unmarshal request")))
+		return
+	}
+
+	response, err := s.ReceiveP2PTransaction(ctx, handle, request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := writeJSON(w, response); err != nil {
+		writeError(w, err)
+	}
+}
+
+// ReceiveP2PTransaction verifies a P2P transaction sent to the handle, records it, and returns
+// the acknowledgement for the sender. A transaction that was already recorded is acknowledged
+// again without calling the handler.
+func (s *Server) ReceiveP2PTransaction(ctx context.Context, handle string,
+	request *P2PTransactionRequest) (*P2PTransactionResponse, error) {
+
+	normalized, err := NormalizeHandle(handle)
+	if err != nil {
+		return nil, badRequest(errors.Wrap(err, "handle"))
+	}
+
+	if request.Tx == nil {
+		return nil, badRequest(errors.New("Missing tx"))
+	}
+	if len(request.Tx.TxIn) == 0 || len(request.Tx.TxOut) == 0 {
+		return nil, badRequest(errors.New("Tx missing inputs or outputs"))
+	}
+	if len(request.Reference) == 0 {
+		return nil, badRequest(errors.New("Missing reference"))
+	}
+
+	s.lock.Lock()
+	handler := s.handler
+	factory := s.factory
+	requireSignature := s.requireSignature
+	s.lock.Unlock()
+
+	txid := *request.Tx.TxHash()
+
+	verified, err := s.verifySender(ctx, factory, requireSignature, request)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path := p2pTransactionPath(normalized, txid)
+	existing := &P2PTransactionRecord{}
+	if err := s.load(ctx, path, existing); err == nil {
+		if existing.Reference != request.Reference {
+			return nil, badRequest(fmt.Errorf("Tx already received with reference %s",
+				existing.Reference))
+		}
+
+		return &P2PTransactionResponse{
+			TxID: txid,
+			Note: existing.Note,
+		}, nil
+	} else if errors.Cause(err) != storage.ErrNotFound {
+		return nil, errors.Wrap(err, "load")
+	}
+
+	record := &P2PTransactionRecord{
+		Handle:         normalized,
+		Tx:             request.Tx,
+		MetaData:       request.MetaData,
+		Reference:      request.Reference,
+		SenderVerified: verified,
+		Received:       time.Now(),
+	}
+
+	if handler != nil {
+		note, err := handler.HandleP2PTransaction(ctx, record)
+		if err != nil {
+			return nil, errors.Wrap(err, "handle")
+		}
+		record.Note = note
+	}
+
+	if err := s.save(ctx, path, record); err != nil {
+		return nil, errors.Wrap(err, "save")
+	}
+
+	return &P2PTransactionResponse{
+		TxID: txid,
+		Note: record.Note,
+	}, nil
+}
+
+func (s *Server) servePaymentDestination(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	handle := strings.TrimPrefix(r.URL.Path, paymentDestinationPath)
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPaymentDestinationRequestSize))
+	if err != nil {
+		writeError(w, badRequest(errors.Wrap(err, "read body")))
+		return
+	}
+
+	request := &PaymentDestinationRequest{}
+	if err := json.Unmarshal(b, request); err != nil {
+		writeError(w, badRequest(errors.Wrap(err, "unmarshal request")))
+		return
+	}
+
+	response, err := s.ReceivePaymentDestination(r.Context(), handle, request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := writeJSON(w, response); err != nil {
+		writeError(w, err)
+	}
+}
+
+// ReceivePaymentDestination checks a payment destination request for the handle and returns the
+// locking script from the payment destination handler. Requests with a date time outside of the
+// replay guard's window are rejected, as are signed requests that were already received, so a
+// captured request can't be replayed to use up the handle's payment destinations. Unsigned requests
+// can be replayed within the window, so SetRequireSignature should be used when that matters.
+func (s *Server) ReceivePaymentDestination(ctx context.Context, handle string,
+	request *PaymentDestinationRequest) (*PaymentDestinationResponse, error) {
+
+	normalized, err := NormalizeHandle(handle)
+	if err != nil {
+		return nil, badRequest(errors.Wrap(err, "handle"))
+	}
+
+	s.lock.Lock()
+	handler := s.paymentDestinationHandler
+	factory := s.factory
+	requireSignature := s.requireSignature
+	guard := s.replayGuard
+	s.lock.Unlock()
+
+	if handler == nil {
+		return nil, ErrNotFound
+	}
+
+	if len(request.SenderHandle) == 0 {
+		return nil, badRequest(errors.New("Missing sender handle"))
+	}
+
+	if guard != nil {
+		// Check the date time before the signature is verified so expired requests don't cause
+		// sender key lookups.
+		if err := guard.Check(normalized, request.DateTime, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	verified := false
+	if len(request.Signature) > 0 {
+		if factory == nil {
+			return nil, errors.New("Sender signatures can't be verified without a factory")
+		}
+
+		client, err := factory.NewClient(ctx, request.SenderHandle)
+		if err != nil {
+			return nil, badRequest(errors.Wrap(err, "sender client"))
+		}
+
+		senderKey, err := client.GetPublicKey(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "sender public key")
+		}
+
+		if err := request.CheckSignature(*senderKey); err != nil {
+			return nil, badRequest(err)
+		}
+		verified = true
+	} else if requireSignature {
+		return nil, badRequest(errors.New("Missing signature"))
+	}
+
+	if guard != nil && verified {
+		sigHash, err := request.SignatureHash()
+		if err != nil {
+			return nil, errors.Wrap(err, "signature hash")
+		}
+
+		if err := guard.Check(normalized, request.DateTime, &sigHash); err != nil {
+			return nil, err
+		}
+	}
+
+	lockingScript, err := handler.HandlePaymentDestination(ctx, normalized, request, verified)
+	if err != nil {
+		return nil, errors.Wrap(err, "handle")
+	}
+
+	return &PaymentDestinationResponse{
+		Output: lockingScript,
+	}, nil
+}
+
+// GetP2PTransaction returns a transaction received for the handle.
+func (s *Server) GetP2PTransaction(ctx context.Context, handle string,
+	txid bitcoin.Hash32) (*P2PTransactionRecord, error) {
+
+	normalized, err := NormalizeHandle(handle)
+	if err != nil {
+		return nil, errors.Wrap(err, "handle")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := &P2PTransactionRecord{}
+	if err := s.load(ctx, p2pTransactionPath(normalized, txid), result); err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// verifySender checks the sender's signature of the txid. It returns true only if the signature
+// was made by the PKI key of the sender's handle, which is retrieved with the factory. When a
+// signature isn't required, a signature that can't be verified that way is checked against the
+// key in the metadata and the sender is not verified.
+func (s *Server) verifySender(ctx context.Context, factory Factory, requireSignature bool,
+	request *P2PTransactionRequest) (bool, error) {
+
+	if len(request.MetaData.Signature) == 0 {
+		if requireSignature {
+			return false, badRequest(errors.New("Missing signature"))
+		}
+		return false, nil
+	}
+
+	if factory == nil || len(request.MetaData.Sender) == 0 {
+		if requireSignature {
+			if factory == nil {
+				return false, errors.New("Sender signatures can't be verified without a factory")
+			}
+			return false, badRequest(errors.New("Missing sender"))
+		}
+
+		// The key is provided by the sender so it doesn't verify who the sender is.
+		if request.MetaData.Key == nil {
+			return false, badRequest(errors.New("Missing public key"))
+		}
+
+		if err := request.CheckSignature(*request.MetaData.Key); err != nil {
+			return false, badRequest(err)
+		}
+
+		return false, nil
+	}
+
+	client, err := factory.NewClient(ctx, request.MetaData.Sender)
+	if err != nil {
+		return false, badRequest(errors.Wrap(err, "sender client"))
+	}
+
+	senderKey, err := client.GetPublicKey(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "sender public key")
+	}
+
+	if request.MetaData.Key != nil && !request.MetaData.Key.Equal(*senderKey) {
+		return false, badRequest(errors.Wrap(ErrInvalidSignature, "not sender's key"))
+	}
+
+	if err := request.CheckSignature(*senderKey); err != nil {
+		return false, badRequest(err)
+	}
+
+	return true, nil
+}
+
+func (s *Server) load(ctx context.Context, path string, value interface{}) error {
+	b, err := s.store.Read(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, value); err != nil {
+		return errors.Wrap(err, "unmarshal")
+	}
+
+	return nil
+}
+
+func (s *Server) save(ctx context.Context, path string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	return s.store.Write(ctx, path, b, nil)
+}
+
+type badRequestError struct {
+	err error
+}
+
+func (err badRequestError) Error() string {
+	return err.err.Error()
+}
+
+func badRequest(err error) error {
+	return badRequestError{err: err}
+}
+
+// writeError responds with the HTTP status that corresponds to the error.
+func writeError(w http.ResponseWriter, err error) {
+	switch cause := errors.Cause(err); cause {
+	case ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrRejected, ErrRequestExpired, ErrReplayed:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		if _, ok := cause.(badRequestError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		return errors.Wrap(err, "encode response")
+	}
+	return nil
+}
+
+func p2pTransactionPath(handle string, txid bitcoin.Hash32) string {
+	return fmt.Sprintf("%s/p2p_transactions/%s/%s", serverStoragePath, handle, txid)
+}
@@ -0,0 +1,379 @@
+package bsvalias
+
+import (
+	"context"
+	"encoding/base64"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+type mockP2PTransactionHandler struct {
+	reference string
+	records   []*P2PTransactionRecord
+}
+
+func (h *mockP2PTransactionHandler) HandleP2PTransaction(ctx context.Context,
+	record *P2PTransactionRecord) (string, error) {
+
+	if record.Reference != h.reference {
+		return "", errors.Wrap(ErrRejected, "unknown reference")
+	}
+
+	h.records = append(h.records, record)
+	return "Thanks", nil
+}
+
+func TestServerReceiveP2PTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+
+	senderKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	factory := NewMockFactory()
+	factory.AddMockUser("sender@example.com", senderKey, senderKey)
+
+	handler := &mockP2PTransactionHandler{reference: "ref1"}
+
+	httpServer := httptest.NewServer(nil)
+	defer httpServer.Close()
+
+	server := NewServer(httpServer.URL, store)
+	server.SetHandler(handler)
+	server.SetFactory(factory)
+	server.SetRequireSignature(true)
+	httpServer.Config.Handler = server
+
+	client, err := NewHTTPClientForSite("receiver@example.com", server.Site())
+	if err != nil {
+		t.Fatalf("Failed to create client : %s", err)
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{1}, 0), nil))
+	tx.AddTxOut(wire.NewTxOut(1000, bitcoin.Script{bitcoin.OP_TRUE}))
+	txid := *tx.TxHash()
+
+	note, err := client.PostP2PTransaction(ctx, "sender@example.com", "hello", "ref1",
+		&senderKey, tx)
+	if err != nil {
+		t.Fatalf("Failed to post transaction : %s", err)
+	}
+
+	if note != "Thanks" {
+		t.Fatalf("Wrong note : got %s, want %s", note, "Thanks")
+	}
+
+	record, err := server.GetP2PTransaction(ctx, "receiver@example.com", txid)
+	if err != nil {
+		t.Fatalf("Failed to get transaction : %s", err)
+	}
+
+	if !record.SenderVerified {
+		t.Fatalf("Sender not verified")
+	}
+
+	if record.MetaData.Note != "hello" || record.Reference != "ref1" {
+		t.Fatalf("Wrong record : note %s, reference %s", record.MetaData.Note, record.Reference)
+	}
+
+	if !record.Tx.TxHash().Equal(&txid) {
+		t.Fatalf("Wrong txid : got %s, want %s", record.Tx.TxHash(), txid)
+	}
+
+	// Posting again is acknowledged without handling again.
+	if _, err := client.PostP2PTransaction(ctx, "sender@example.com", "hello", "ref1",
+		&senderKey, tx); err != nil {
+		t.Fatalf("Failed to post transaction again : %s", err)
+	}
+
+	if len(handler.records) != 1 {
+		t.Fatalf("Wrong handled count : got %d, want %d", len(handler.records), 1)
+	}
+
+	// Unsigned transactions are rejected.
+	if _, err := client.PostP2PTransaction(ctx, "sender@example.com", "hello", "ref1", nil,
+		tx); err == nil {
+		t.Fatalf("Unsigned transaction should be rejected")
+	}
+
+	// Transactions signed by a key that isn't the sender's are rejected.
+	otherKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	request := &P2PTransactionRequest{
+		Tx: tx,
+		MetaData: P2PTransactionMetaData{
+			Sender: "sender@example.com",
+		},
+		Reference: "ref1",
+	}
+	if err := request.Sign(otherKey); err != nil {
+		t.Fatalf("Failed to sign request : %s", err)
+	}
+
+	if _, err := server.ReceiveP2PTransaction(ctx, "receiver@example.com",
+		request); err == nil {
+		t.Fatalf("Transaction signed by wrong key should be rejected")
+	}
+
+	// Unknown references are rejected by the handler.
+	tx.AddTxOut(wire.NewTxOut(500, bitcoin.Script{bitcoin.OP_TRUE}))
+	request = &P2PTransactionRequest{
+		Tx: tx,
+		MetaData: P2PTransactionMetaData{
+			Sender: "sender@example.com",
+		},
+		Reference: "ref2",
+	}
+	if err := request.Sign(senderKey); err != nil {
+		t.Fatalf("Failed to sign request : %s", err)
+	}
+
+	if _, err := server.ReceiveP2PTransaction(ctx, "receiver@example.com",
+		request); errors.Cause(err) != ErrRejected {
+		t.Fatalf("Wrong error for unknown reference : %v", err)
+	}
+
+	if _, err := server.GetP2PTransaction(ctx, "receiver@example.com",
+		*tx.TxHash()); errors.Cause(err) != ErrNotFound {
+		t.Fatalf("Wrong error for rejected transaction : %v", err)
+	}
+}
+
+func TestServerP2PTransactionSenderKey(t *testing.T) {
+	ctx := context.Background()
+
+	senderKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	selfKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+	selfPublicKey := selfKey.PublicKey()
+
+	factory := NewMockFactory()
+	factory.AddMockUser("sender@example.com", senderKey, senderKey)
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{2}, 0), nil))
+	tx.AddTxOut(wire.NewTxOut(1000, bitcoin.Script{bitcoin.OP_TRUE}))
+
+	// newRequest returns a request signed by the self generated key provided in the metadata.
+	newRequest := func(sender string) *P2PTransactionRequest {
+		request := &P2PTransactionRequest{
+			Tx: tx,
+			MetaData: P2PTransactionMetaData{
+				Sender: sender,
+				Key:    &selfPublicKey,
+			},
+			Reference: "ref1",
+		}
+		if err := request.Sign(selfKey); err != nil {
+			t.Fatalf("Failed to sign request : %s", err)
+		}
+		return request
+	}
+
+	// Required signatures need a sender whose key can be retrieved from the factory.
+	server := NewServer("https://example.com", storage.NewMockStorage())
+	server.SetHandler(&mockP2PTransactionHandler{reference: "ref1"})
+	server.SetFactory(factory)
+	server.SetRequireSignature(true)
+
+	if _, err := server.ReceiveP2PTransaction(ctx, "receiver@example.com",
+		newRequest("")); err == nil {
+		t.Fatalf("Transaction without sender should be rejected")
+	}
+
+	server = NewServer("https://example.com", storage.NewMockStorage())
+	server.SetHandler(&mockP2PTransactionHandler{reference: "ref1"})
+	server.SetRequireSignature(true)
+
+	if _, err := server.ReceiveP2PTransaction(ctx, "receiver@example.com",
+		newRequest("sender@example.com")); err == nil {
+		t.Fatalf("Transaction should be rejected without a factory")
+	}
+
+	// Signatures by the metadata key are accepted when not required, but don't verify the sender.
+	handler := &mockP2PTransactionHandler{reference: "ref1"}
+	server = NewServer("https://example.com", storage.NewMockStorage())
+	server.SetHandler(handler)
+
+	if _, err := server.ReceiveP2PTransaction(ctx, "receiver@example.com",
+		newRequest("sender@example.com")); err != nil {
+		t.Fatalf("Failed to receive transaction : %s", err)
+	}
+
+	if len(handler.records) != 1 {
+		t.Fatalf("Wrong handled count : got %d, want %d", len(handler.records), 1)
+	}
+
+	if handler.records[0].SenderVerified {
+		t.Fatalf("Sender should not be verified by the metadata key")
+	}
+}
+
+type mockPaymentDestinationHandler struct {
+	lockingScript bitcoin.Script
+	requests      []*PaymentDestinationRequest
+}
+
+func (h *mockPaymentDestinationHandler) HandlePaymentDestination(ctx context.Context,
+	handle string, request *PaymentDestinationRequest,
+	senderVerified bool) (bitcoin.Script, error) {
+
+	if !senderVerified {
+		return nil, errors.Wrap(ErrRejected, "sender not verified")
+	}
+
+	h.requests = append(h.requests, request)
+	return h.lockingScript, nil
+}
+
+func TestServerPaymentDestinationReplay(t *testing.T) {
+	ctx := context.Background()
+
+	senderKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	factory := NewMockFactory()
+	factory.AddMockUser("sender@example.com", senderKey, senderKey)
+
+	handler := &mockPaymentDestinationHandler{
+		lockingScript: bitcoin.Script{bitcoin.OP_TRUE},
+	}
+
+	httpServer := httptest.NewServer(nil)
+	defer httpServer.Close()
+
+	server := NewServer(httpServer.URL, storage.NewMockStorage())
+	server.SetPaymentDestinationHandler(handler)
+	server.SetFactory(factory)
+	server.SetRequireSignature(true)
+	httpServer.Config.Handler = server
+
+	client, err := NewHTTPClientForSite("receiver@example.com", server.Site())
+	if err != nil {
+		t.Fatalf("Failed to create client : %s", err)
+	}
+
+	lockingScript, err := client.GetPaymentDestination(ctx, "Sender", "sender@example.com",
+		"test", 1000, &senderKey)
+	if err != nil {
+		t.Fatalf("Failed to get payment destination : %s", err)
+	}
+	if !lockingScript.Equal(handler.lockingScript) {
+		t.Fatalf("Wrong locking script : got %s, want %s", lockingScript, handler.lockingScript)
+	}
+
+	if _, err := client.GetPaymentDestination(ctx, "Sender", "sender@example.com", "test",
+		1000, nil); err == nil {
+		t.Fatalf("Unsigned request should be rejected")
+	}
+
+	request := &PaymentDestinationRequest{
+		SenderHandle: "sender@example.com",
+		DateTime:     time.Now().UTC().Format("2006-01-02T15:04:05.999Z"),
+		Amount:       1000,
+	}
+	if err := request.Sign(senderKey); err != nil {
+		t.Fatalf("Failed to sign request : %s", err)
+	}
+
+	if _, err := server.ReceivePaymentDestination(ctx, "receiver@example.com",
+		request); err != nil {
+		t.Fatalf("Failed to receive payment destination : %s", err)
+	}
+
+	if _, err := server.ReceivePaymentDestination(ctx, "receiver@example.com",
+		request); errors.Cause(err) != ErrReplayed {
+		t.Fatalf("Wrong error for replay : got %v, want %v", err, ErrReplayed)
+	}
+
+	// Different encodings of the same signature are still replays.
+	for i, signature := range malleateCompactSignature(t, request.Signature) {
+		malleated := *request
+		malleated.Signature = signature
+
+		if _, err := server.ReceivePaymentDestination(ctx, "receiver@example.com",
+			&malleated); errors.Cause(err) != ErrReplayed {
+			t.Fatalf("Wrong error for malleated replay %d : got %v, want %v", i, err, ErrReplayed)
+		}
+	}
+
+	// The recipient handle is normalized so a differently written handle is still a replay.
+	if _, err := server.ReceivePaymentDestination(ctx, " receiver@Example.COM",
+		request); errors.Cause(err) != ErrReplayed {
+		t.Fatalf("Wrong error for replay to unnormalized handle : got %v, want %v", err,
+			ErrReplayed)
+	}
+
+	// The same request to another recipient isn't a replay.
+	if _, err := server.ReceivePaymentDestination(ctx, "other@example.com",
+		request); err != nil {
+		t.Fatalf("Failed to receive payment destination for other recipient : %s", err)
+	}
+
+	old := &PaymentDestinationRequest{
+		SenderHandle: "sender@example.com",
+		DateTime:     time.Now().Add(-time.Hour).UTC().Format("2006-01-02T15:04:05.999Z"),
+		Amount:       1000,
+	}
+	if err := old.Sign(senderKey); err != nil {
+		t.Fatalf("Failed to sign request : %s", err)
+	}
+
+	if _, err := server.ReceivePaymentDestination(ctx, "receiver@example.com",
+		old); errors.Cause(err) != ErrRequestExpired {
+		t.Fatalf("Wrong error for old request : got %v, want %v", err, ErrRequestExpired)
+	}
+
+	if len(handler.requests) != 3 {
+		t.Fatalf("Wrong handled request count : got %d, want %d", len(handler.requests), 3)
+	}
+}
+
+// malleateCompactSignature returns other valid encodings of a compact signature. They have a
+// different recovery header byte and a high S value.
+func malleateCompactSignature(t *testing.T, signature string) []string {
+	b, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		t.Fatalf("Failed to decode signature : %s", err)
+	}
+
+	header := make([]byte, len(b))
+	copy(header, b)
+	header[0] = 27
+
+	n, _ := new(big.Int).SetString(
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+	s := new(big.Int).SetBytes(b[33:])
+	highS := new(big.Int).Sub(n, s).Bytes()
+
+	high := make([]byte, len(b))
+	copy(high, b[:33])
+	copy(high[65-len(highS):], highS)
+
+	return []string{
+		base64.StdEncoding.EncodeToString(header),
+		base64.StdEncoding.EncodeToString(high),
+	}
+}
@@ -2,6 +2,7 @@ package bsvalias
 
 import (
 	"context"
+	"time"
 
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/wire"
@@ -26,6 +27,7 @@ func NewMockFactory() *MockFactory {
 
 // NewClient creates a new client.
 func (f *MockFactory) NewClient(ctx context.Context, handle string) (Client, error) {
+	handle = mockHandle(handle)
 	for _, user := range f.users {
 		if user.handle == handle {
 			return &MockClient{user: user}, nil
@@ -42,12 +44,13 @@ type mockUser struct {
 	addressKey        bitcoin.Key
 	p2pTxs            map[string][]*wire.MsgTx
 	instrumentAliases []InstrumentAlias
+	keyRotations      []KeyRotationRecord
 }
 
 // AddMockUser adds a new mock user.
 func (f *MockFactory) AddMockUser(handle string, identityKey, addressKey bitcoin.Key) {
 	f.users = append(f.users, &mockUser{
-		handle:      handle,
+		handle:      mockHandle(handle),
 		identityKey: identityKey,
 		addressKey:  addressKey,
 		p2pTxs:      make(map[string][]*wire.MsgTx),
@@ -56,6 +59,7 @@ func (f *MockFactory) AddMockUser(handle string, identityKey, addressKey bitcoin
 
 // AddMockUser adds a new mock user.
 func (f *MockFactory) AddMockInstrument(handle string, instrumentAlias, instrumentID string) {
+	handle = mockHandle(handle)
 	for _, user := range f.users {
 		if user.handle != handle {
 			continue
@@ -69,12 +73,55 @@ func (f *MockFactory) AddMockInstrument(handle string, instrumentAlias, instrume
 	}
 }
 
+// RotateMockUserKey replaces the identity key of a mock user and publishes a key rotation record
+// signed by the previous key.
+func (f *MockFactory) RotateMockUserKey(handle string, newKey bitcoin.Key,
+	validFrom time.Time) error {
+
+	handle = mockHandle(handle)
+	for _, user := range f.users {
+		if user.handle != handle {
+			continue
+		}
+
+		if len(user.keyRotations) == 0 {
+			// Publish the original key first.
+			record, err := SignKeyRotation(handle, user.identityKey.PublicKey(), time.Unix(0, 0),
+				user.identityKey)
+			if err != nil {
+				return errors.Wrap(err, "sign original")
+			}
+			user.keyRotations = append(user.keyRotations, *record)
+		}
+
+		record, err := SignKeyRotation(handle, newKey.PublicKey(), validFrom, user.identityKey)
+		if err != nil {
+			return errors.Wrap(err, "sign")
+		}
+		user.keyRotations = append(user.keyRotations, *record)
+		user.identityKey = newKey
+		return nil
+	}
+
+	return errors.Wrap(ErrInvalidHandle, "not found")
+}
+
+// mockHandle returns the normalized handle so mock users can be found with any form of their
+// handle. Invalid handles are returned unchanged.
+func mockHandle(handle string) string {
+	normalized, err := NormalizeHandle(handle)
+	if err != nil {
+		return handle
+	}
+	return normalized
+}
+
 // GenerateMockUser generates a mock user and returns the user's handle, public key, and address.
 func (f *MockFactory) GenerateMockUser(host string,
 	net bitcoin.Network) (*string, *bitcoin.PublicKey, *bitcoin.RawAddress, error) {
 
 	result := &mockUser{
-		handle: uuid.New().String() + "@" + host,
+		handle: mockHandle(uuid.New().String() + "@" + host),
 		p2pTxs: make(map[string][]*wire.MsgTx),
 	}
 
@@ -218,3 +265,11 @@ func (c *MockClient) CheckP2PTx(txid bitcoin.Hash32) error {
 func (c *MockClient) ListTokenizedInstruments(ctx context.Context) ([]InstrumentAlias, error) {
 	return c.user.instrumentAliases, nil
 }
+
+// GetKeyRotations returns the key rotation records of the handle in order.
+func (c *MockClient) GetKeyRotations(ctx context.Context) ([]KeyRotationRecord, error) {
+	if len(c.user.keyRotations) == 0 {
+		return nil, ErrNotFound
+	}
+	return c.user.keyRotations, nil
+}
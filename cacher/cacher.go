@@ -0,0 +1,383 @@
+package cacher
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// PolicyLRU evicts the least recently used item.
+	PolicyLRU = Policy(0)
+
+	// PolicyARC uses Adaptive Replacement Cache, which balances recently and frequently used items
+	// so that a scan of many items used once doesn't evict frequently used items.
+	PolicyARC = Policy(1)
+)
+
+// Policy specifies which items are evicted when the cache is full.
+type Policy uint8
+
+// Sizer is implemented by values that know their size in bytes. Values that don't implement it
+// are counted as zero bytes toward the byte limit.
+type Sizer interface {
+	Size() uint64
+}
+
+// Config configures a cache.
+type Config struct {
+	Policy Policy
+
+	// MaxItems is the maximum number of items. Zero means no limit. PolicyARC requires it and
+	// PolicyLRU is used when it is zero.
+	MaxItems int
+
+	// MaxBytes is the maximum total size of the items that implement Sizer. Zero means no limit.
+	MaxBytes uint64
+
+	// DefaultTTL is how long items added with Set are kept. Zero means they don't expire.
+	DefaultTTL time.Duration
+}
+
+// Stats contains counters of cache activity.
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64 // Items removed to make space
+	Expirations uint64 // Items removed because their TTL passed
+	Coalesced   uint64 // GetOrFetch calls that shared another call's fetch
+}
+
+// Cache is a thread safe cache of values by key.
+type Cache struct {
+	config Config
+
+	// For LRU only t1 is used. For ARC t1 and t2 hold items used once and more than once, and b1
+	// and b2 hold the keys of items recently evicted from them.
+	t1, t2, b1, b2 *list.List
+	target         int // ARC target size of t1
+
+	items map[string]*list.Element
+	bytes uint64
+	stats Stats
+
+	fetches Group // GetOrFetch calls in progress
+
+	sync.Mutex
+}
+
+type entry struct {
+	key    string
+	value  interface{}
+	size   uint64
+	expiry time.Time
+	list   *list.List
+}
+
+// NewCache creates an empty cache.
+func NewCache(config Config) *Cache {
+	if config.MaxItems <= 0 {
+		config.Policy = PolicyLRU
+	}
+
+	return &Cache{
+		config: config,
+		t1:     list.New(),
+		t2:     list.New(),
+		b1:     list.New(),
+		b2:     list.New(),
+		items:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value for the key and true, or false if it isn't in the cache or has expired.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, exists := c.items[key]
+	if !exists || c.isGhost(e) {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	ent := e.Value.(*entry)
+	if !ent.expiry.IsZero() && time.Now().After(ent.expiry) {
+		c.remove(e)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.touch(e)
+	return ent.value, true
+}
+
+// Set adds or replaces the value for the key with the default TTL.
+func (c *Cache) Set(key string, value interface{}) {
+	c.SetWithTTL(key, value, c.config.DefaultTTL)
+}
+
+// SetWithTTL adds or replaces the value for the key. It expires after the TTL unless the TTL is
+// zero.
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	ent := &entry{
+		key:   key,
+		value: value,
+	}
+	if sizer, ok := value.(Sizer); ok {
+		ent.size = sizer.Size()
+	}
+	if ttl > 0 {
+		ent.expiry = time.Now().Add(ttl)
+	}
+
+	if e, exists := c.items[key]; exists && !c.isGhost(e) {
+		existing := e.Value.(*entry)
+		c.bytes = c.bytes - existing.size + ent.size
+		existing.value = ent.value
+		existing.size = ent.size
+		existing.expiry = ent.expiry
+		c.touch(e)
+		c.enforceBytes()
+		return
+	}
+
+	if c.config.Policy == PolicyARC {
+		c.addARC(ent)
+	} else {
+		c.insert(c.t1, ent)
+		for c.config.MaxItems > 0 && c.t1.Len() > c.config.MaxItems {
+			c.evict(c.t1.Back())
+		}
+	}
+
+	c.enforceBytes()
+}
+
+// Remove removes the key from the cache and returns true if it was in the cache.
+func (c *Cache) Remove(key string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	e, exists := c.items[key]
+	if !exists {
+		return false
+	}
+
+	wasResident := !c.isGhost(e)
+	c.remove(e)
+	return wasResident
+}
+
+// RemoveExpired removes all expired items and returns the number removed. Expired items are also
+// removed when they are requested, so this is only needed to release their memory sooner.
+func (c *Cache) RemoveExpired() int {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for e := l.Front(); e != nil; {
+			next := e.Next()
+			ent := e.Value.(*entry)
+			if !ent.expiry.IsZero() && now.After(ent.expiry) {
+				c.remove(e)
+				c.stats.Expirations++
+				count++
+			}
+			e = next
+		}
+	}
+
+	return count
+}
+
+// Clear removes all items. The stats are not reset.
+func (c *Cache) Clear() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.t1.Init()
+	c.t2.Init()
+	c.b1.Init()
+	c.b2.Init()
+	c.target = 0
+	c.items = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// Len returns the number of items in the cache, including expired items that haven't been removed.
+func (c *Cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.t1.Len() + c.t2.Len()
+}
+
+// Bytes returns the total size of the items in the cache that implement Sizer.
+func (c *Cache) Bytes() uint64 {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.bytes
+}
+
+// Stats returns the cache's counters.
+func (c *Cache) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.stats
+}
+
+// addARC adds a new item using the ARC algorithm.
+func (c *Cache) addARC(ent *entry) {
+	size := c.config.MaxItems
+
+	if e, exists := c.items[ent.key]; exists {
+		// Ghost hit. Adapt the target toward the list that would have kept the item.
+		inB2 := e.Value.(*entry).list == c.b2
+		if inB2 {
+			c.target -= maxInt(1, c.b1.Len()/maxInt(1, c.b2.Len()))
+			if c.target < 0 {
+				c.target = 0
+			}
+		} else {
+			c.target += maxInt(1, c.b2.Len()/maxInt(1, c.b1.Len()))
+			if c.target > size {
+				c.target = size
+			}
+		}
+
+		c.remove(e)
+		c.replace(inB2)
+		c.insert(c.t2, ent)
+		return
+	}
+
+	l1 := c.t1.Len() + c.b1.Len()
+	if l1 >= size {
+		if c.t1.Len() < size {
+			c.remove(c.b1.Back())
+			c.replace(false)
+		} else {
+			c.evict(c.t1.Back())
+		}
+	} else if total := l1 + c.t2.Len() + c.b2.Len(); total >= size {
+		if total >= 2*size && c.b2.Len() > 0 {
+			c.remove(c.b2.Back())
+		}
+		c.replace(false)
+	}
+
+	c.insert(c.t1, ent)
+}
+
+// replace makes space for an ARC item by moving the least recently used item of t1 or t2 to its
+// ghost list, depending on the target. It does nothing if the cache isn't full.
+func (c *Cache) replace(inB2 bool) {
+	if c.t1.Len()+c.t2.Len() < c.config.MaxItems {
+		return
+	}
+
+	c.replaceOne(inB2)
+}
+
+func (c *Cache) replaceOne(inB2 bool) {
+	t1Len := c.t1.Len()
+	if t1Len > 0 && (t1Len > c.target || (inB2 && t1Len == c.target) || c.t2.Len() == 0) {
+		c.toGhost(c.t1.Back(), c.b1)
+	} else if c.t2.Len() > 0 {
+		c.toGhost(c.t2.Back(), c.b2)
+	}
+}
+
+// enforceBytes evicts items until the byte limit is met.
+func (c *Cache) enforceBytes() {
+	if c.config.MaxBytes == 0 {
+		return
+	}
+
+	for c.bytes > c.config.MaxBytes && c.t1.Len()+c.t2.Len() > 0 {
+		if c.config.Policy == PolicyARC {
+			c.replaceOne(false)
+		} else {
+			c.evict(c.t1.Back())
+		}
+	}
+
+	// Keep the ghost lists within the ARC limit.
+	for c.config.Policy == PolicyARC && c.b1.Len()+c.b2.Len() > c.config.MaxItems {
+		if c.b1.Len() > c.b2.Len() {
+			c.remove(c.b1.Back())
+		} else {
+			c.remove(c.b2.Back())
+		}
+	}
+}
+
+// touch records a use of a resident item.
+func (c *Cache) touch(e *list.Element) {
+	ent := e.Value.(*entry)
+	if c.config.Policy == PolicyARC && ent.list == c.t1 {
+		c.t1.Remove(e)
+		ent.list = c.t2
+		c.items[ent.key] = c.t2.PushFront(ent)
+		return
+	}
+
+	ent.list.MoveToFront(e)
+}
+
+func (c *Cache) insert(l *list.List, ent *entry) {
+	ent.list = l
+	c.items[ent.key] = l.PushFront(ent)
+	c.bytes += ent.size
+}
+
+// remove removes an item, or ghost, completely.
+func (c *Cache) remove(e *list.Element) {
+	ent := e.Value.(*entry)
+	ent.list.Remove(e)
+	delete(c.items, ent.key)
+	if ent.list == c.t1 || ent.list == c.t2 {
+		c.bytes -= ent.size
+	}
+}
+
+// evict removes an item to make space.
+func (c *Cache) evict(e *list.Element) {
+	c.remove(e)
+	c.stats.Evictions++
+}
+
+// toGhost evicts an item's value but keeps its key in the ghost list.
+func (c *Cache) toGhost(e *list.Element, ghosts *list.List) {
+	ent := e.Value.(*entry)
+	ent.list.Remove(e)
+	c.bytes -= ent.size
+	c.stats.Evictions++
+
+	ent.value = nil
+	ent.size = 0
+	ent.list = ghosts
+	c.items[ent.key] = ghosts.PushFront(ent)
+}
+
+func (c *Cache) isGhost(e *list.Element) bool {
+	l := e.Value.(*entry).list
+	return l == c.b1 || l == c.b2
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
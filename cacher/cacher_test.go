@@ -0,0 +1,240 @@
+package cacher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type sizedValue []byte
+
+func (v sizedValue) Size() uint64 {
+	return uint64(len(v))
+}
+
+func TestLRU(t *testing.T) {
+	cache := NewCache(Config{MaxItems: 3})
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+
+	if _, ok := cache.Get("a"); !ok { // a is now most recently used
+		t.Fatalf("Missing a")
+	}
+
+	cache.Set("d", 4) // evicts b
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("b should be evicted")
+	}
+
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Missing %s", key)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 4 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("Wrong stats : %+v", stats)
+	}
+
+	if !cache.Remove("a") || cache.Remove("a") {
+		t.Errorf("Wrong remove result")
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("Wrong length : got %d, want %d", cache.Len(), 2)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	for _, policy := range []Policy{PolicyLRU, PolicyARC} {
+		cache := NewCache(Config{Policy: policy, MaxItems: 10, MaxBytes: 100})
+
+		for i := 0; i < 5; i++ {
+			cache.Set(fmt.Sprintf("%d", i), make(sizedValue, 30))
+		}
+
+		if cache.Bytes() > 100 || cache.Len() != 3 {
+			t.Errorf("Policy %d : wrong size : %d items, %d bytes", policy, cache.Len(),
+				cache.Bytes())
+		}
+
+		cache.Set("4", make(sizedValue, 10))
+		if cache.Bytes() != 70 {
+			t.Errorf("Policy %d : wrong bytes after replace : got %d, want %d", policy,
+				cache.Bytes(), 70)
+		}
+
+		cache.Clear()
+		if cache.Bytes() != 0 || cache.Len() != 0 {
+			t.Errorf("Policy %d : cache not cleared", policy)
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	cache := NewCache(Config{DefaultTTL: 20 * time.Millisecond})
+
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, 0)
+	cache.SetWithTTL("c", 3, 0)
+	cache.SetWithTTL("d", 4, 10*time.Millisecond)
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("a should be expired")
+	}
+
+	if _, ok := cache.Get("b"); !ok {
+		t.Errorf("b should not expire")
+	}
+
+	if count := cache.RemoveExpired(); count != 1 {
+		t.Errorf("Wrong expired count : got %d, want %d", count, 1)
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("Wrong length : got %d, want %d", cache.Len(), 2)
+	}
+
+	if stats := cache.Stats(); stats.Expirations != 2 {
+		t.Errorf("Wrong expirations : got %d, want %d", stats.Expirations, 2)
+	}
+}
+
+func TestARCScanResistance(t *testing.T) {
+	for _, policy := range []Policy{PolicyLRU, PolicyARC} {
+		cache := NewCache(Config{Policy: policy, MaxItems: 10})
+
+		// Frequently used items.
+		for i := 0; i < 5; i++ {
+			key := fmt.Sprintf("hot%d", i)
+			cache.Set(key, i)
+			cache.Get(key)
+		}
+
+		// Scan of items used only once.
+		for i := 0; i < 100; i++ {
+			cache.Set(fmt.Sprintf("scan%d", i), i)
+		}
+
+		hot := 0
+		for i := 0; i < 5; i++ {
+			if _, ok := cache.Get(fmt.Sprintf("hot%d", i)); ok {
+				hot++
+			}
+		}
+
+		if policy == PolicyARC && hot != 5 {
+			t.Errorf("ARC lost frequently used items : got %d, want %d", hot, 5)
+		}
+		if policy == PolicyLRU && hot != 0 {
+			t.Errorf("LRU kept frequently used items : got %d, want %d", hot, 0)
+		}
+
+		if cache.Len() > 10 {
+			t.Errorf("Policy %d : too many items : %d", policy, cache.Len())
+		}
+	}
+}
+
+func TestARCAdapts(t *testing.T) {
+	cache := NewCache(Config{Policy: PolicyARC, MaxItems: 4})
+
+	// Repeatedly cycle through more keys than fit so ghost hits adapt the target.
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 6; i++ {
+			key := fmt.Sprintf("%d", i)
+			if _, ok := cache.Get(key); !ok {
+				cache.Set(key, i)
+			}
+		}
+
+		if cache.Len() > 4 {
+			t.Fatalf("Too many items : %d", cache.Len())
+		}
+
+		cache.Lock()
+		ghosts := cache.b1.Len() + cache.b2.Len()
+		total := len(cache.items)
+		cache.Unlock()
+		if ghosts > 4 || total > 8 {
+			t.Fatalf("Too many ghosts : %d ghosts, %d total", ghosts, total)
+		}
+	}
+}
+
+func TestGetOrFetch(t *testing.T) {
+	cache := NewCache(Config{MaxItems: 10})
+
+	var fetchCount int32
+	release := make(chan interface{})
+	fetch := func() (interface{}, error) {
+		atomic.AddInt32(&fetchCount, 1)
+		<-release
+		return "header", nil
+	}
+
+	var wait sync.WaitGroup
+	results := make(chan interface{}, 100)
+	for i := 0; i < 100; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			value, err := cache.GetOrFetch("hash", fetch)
+			if err != nil {
+				t.Errorf("Failed to fetch : %s", err)
+				return
+			}
+			results <- value
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond) // let the goroutines wait for the fetch
+	close(release)
+	wait.Wait()
+	close(results)
+
+	if count := atomic.LoadInt32(&fetchCount); count != 1 {
+		t.Fatalf("Wrong fetch count : got %d, want 1", count)
+	}
+
+	resultCount := 0
+	for value := range results {
+		if value != "header" {
+			t.Fatalf("Wrong value : got %v, want %s", value, "header")
+		}
+		resultCount++
+	}
+	if resultCount != 100 {
+		t.Fatalf("Wrong result count : got %d, want 100", resultCount)
+	}
+
+	// Cached now
+	if _, err := cache.GetOrFetch("hash", func() (interface{}, error) {
+		t.Fatalf("Cached value should not be fetched")
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("Failed to get : %s", err)
+	}
+
+	t.Logf("Stats : %+v", cache.Stats())
+
+	// Errors are not cached
+	fetchErr := errors.New("Backend Unavailable")
+	if _, err := cache.GetOrFetch("missing", func() (interface{}, error) {
+		return nil, fetchErr
+	}); err != fetchErr {
+		t.Fatalf("Wrong error : got %v, want %s", err, fetchErr)
+	}
+	if _, exists := cache.Get("missing"); exists {
+		t.Fatalf("Failed fetch should not be cached")
+	}
+}
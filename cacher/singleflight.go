@@ -0,0 +1,108 @@
+package cacher
+
+import (
+	"sync"
+	"time"
+)
+
+// FetchFunction returns the value for a key from the backend when it isn't in the cache.
+type FetchFunction func() (interface{}, error)
+
+// Group coalesces concurrent calls for the same key, so that only one of them calls the fetch
+// function and the others wait for and share its result. The zero value is ready to use.
+type Group struct {
+	calls map[string]*call
+
+	sync.Mutex
+}
+
+// call is a fetch in progress or completed.
+type call struct {
+	wait  sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Do calls the fetch function for the key and returns its result, unless a call for the key is
+// already in progress, in which case it waits for that call and returns its result. shared is
+// true when the result came from another call.
+func (g *Group) Do(key string, fetch FetchFunction) (value interface{}, err error, shared bool) {
+	g.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+
+	if c, exists := g.calls[key]; exists {
+		g.Unlock()
+		c.wait.Wait()
+		return c.value, c.err, true
+	}
+
+	c := &call{}
+	c.wait.Add(1)
+	g.calls[key] = c
+	g.Unlock()
+
+	// The call is removed even if the fetch panics so later calls don't wait forever.
+	defer func() {
+		g.Lock()
+		delete(g.calls, key)
+		g.Unlock()
+		c.wait.Done()
+	}()
+
+	c.value, c.err = fetch()
+	return c.value, c.err, false
+}
+
+// GetOrFetch returns the value for the key from the cache. If it isn't in the cache then it is
+// fetched with the fetch function and added to the cache with the default TTL. Concurrent misses
+// for the same key result in one fetch, so many goroutines requesting the same item, like a block
+// header, don't all go to the backend. Errors are returned to all of the waiting callers and are
+// not cached.
+func (c *Cache) GetOrFetch(key string, fetch FetchFunction) (interface{}, error) {
+	if value, exists := c.Get(key); exists {
+		return value, nil
+	}
+
+	value, err, shared := c.fetches.Do(key, func() (interface{}, error) {
+		// Another fetch could have completed between the miss and the start of this one.
+		if value, exists := c.peek(key); exists {
+			return value, nil
+		}
+
+		value, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		c.Set(key, value)
+		return value, nil
+	})
+
+	if shared {
+		c.Lock()
+		c.stats.Coalesced++
+		c.Unlock()
+	}
+
+	return value, err
+}
+
+// peek returns the value for the key without updating its use or the stats.
+func (c *Cache) peek(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, exists := c.items[key]
+	if !exists || c.isGhost(e) {
+		return nil, false
+	}
+
+	ent := e.Value.(*entry)
+	if !ent.expiry.IsZero() && time.Now().After(ent.expiry) {
+		return nil, false
+	}
+
+	return ent.value, true
+}
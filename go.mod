@@ -11,6 +11,7 @@ require (
 	github.com/gomodule/redigo v1.8.2
 	github.com/google/uuid v1.1.2
 	github.com/gorilla/websocket v1.4.2
+	github.com/klauspost/compress v1.11.13
 	github.com/pkg/errors v0.9.1
 	github.com/scottjbarr/redis v0.0.1
 	github.com/tokenized/config v0.0.4-0.20220304163631-6373c9a80410
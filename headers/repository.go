@@ -0,0 +1,599 @@
+package headers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/cacher"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultPath is the storage path under which headers are saved.
+	DefaultPath = "headers"
+
+	// DefaultCacheSize is the number of header records kept in memory.
+	DefaultCacheSize = 10000
+
+	// hashesPerFile is the number of hashes saved in each storage item of the height index.
+	hashesPerFile = 1000
+)
+
+var (
+	ErrHeaderNotFound     = errors.New("Header Not Found")
+	ErrPreviousNotFound   = errors.New("Previous Header Not Found")
+	ErrInvalidProofOfWork = errors.New("Invalid Proof Of Work")
+	ErrWrongGenesis       = errors.New("Wrong Genesis Header")
+
+	Endian = binary.LittleEndian
+)
+
+// Repository is a persistent chain of block headers. It keeps every valid header it is given,
+// including headers on branches, and follows the branch with the most cumulative work as the best
+// chain. Headers are indexed by hash for all branches and by height for the best chain.
+//
+// Each header is saved under {path}/hashes/{hash} with its height and cumulative work. The hashes
+// of the best chain are saved in groups under {path}/heights/ and the tip under {path}/tip.
+//
+// Headers are validated against the proof of work limit, checkpoints, and difficulty adjustment
+// rules of the config before they are saved, so headers with less than the required work are not
+// stored.
+type Repository struct {
+	config wire.HeaderSyncConfig
+	store  storage.Storage
+	path   string
+
+	genesis wire.BlockHeader
+	hashes  []bitcoin.Hash32 // best chain, indexed by height
+	heights map[bitcoin.Hash32]int
+	tip     *headerData
+
+	// cache holds recently used header records from all branches.
+	cache *cacher.Cache
+
+	subscriptions  map[uint64]chan<- *wire.ChainTipUpdate
+	nextSubscriber uint64
+
+	sync.Mutex
+}
+
+// headerData is the record saved for each header.
+type headerData struct {
+	Header wire.BlockHeader
+	Height int
+	Work   *big.Int // Cumulative work of the chain ending at this header
+}
+
+// NewRepository creates a header repository with a chain containing only the genesis header. Load
+// must be called to read any headers already in storage. The config contains the consensus rules
+// used to validate headers, like wire.DefaultHeaderSyncConfig of the network.
+func NewRepository(config wire.HeaderSyncConfig, store storage.Storage,
+	genesis *wire.BlockHeader) *Repository {
+
+	result := &Repository{
+		config:        config,
+		store:         store,
+		path:          DefaultPath,
+		genesis:       *genesis,
+		cache:         cacher.NewCache(cacher.Config{MaxItems: DefaultCacheSize}),
+		subscriptions: make(map[uint64]chan<- *wire.ChainTipUpdate),
+	}
+	result.reset()
+	return result
+}
+
+func (r *Repository) reset() {
+	data := &headerData{
+		Header: r.genesis,
+		Height: 0,
+		Work:   headerWork(&r.genesis),
+	}
+	hash := *r.genesis.BlockHash()
+
+	r.hashes = []bitcoin.Hash32{hash}
+	r.heights = map[bitcoin.Hash32]int{hash: 0}
+	r.tip = data
+	r.cache.Clear()
+	r.cache.Set(hash.String(), data)
+}
+
+// Subscribe adds a channel that receives an update each time the best chain changes. Updates are
+// sent after the repository is unlocked, but the send blocks, so the channel should be buffered or
+// quickly drained. It returns an id to pass to Unsubscribe.
+func (r *Repository) Subscribe(updates chan<- *wire.ChainTipUpdate) uint64 {
+	r.Lock()
+	defer r.Unlock()
+
+	id := r.nextSubscriber
+	r.nextSubscriber++
+	r.subscriptions[id] = updates
+	return id
+}
+
+// Unsubscribe removes a channel added with Subscribe. The channel isn't closed.
+func (r *Repository) Unsubscribe(id uint64) {
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.subscriptions, id)
+}
+
+// Height returns the height of the best chain tip.
+func (r *Repository) Height() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return len(r.hashes) - 1
+}
+
+// Tip returns the height and hash of the best chain tip.
+func (r *Repository) Tip() (int, bitcoin.Hash32) {
+	r.Lock()
+	defer r.Unlock()
+
+	height := len(r.hashes) - 1
+	return height, r.hashes[height]
+}
+
+// Work returns the cumulative work of the best chain.
+func (r *Repository) Work() *big.Int {
+	r.Lock()
+	defer r.Unlock()
+
+	return new(big.Int).Set(r.tip.Work)
+}
+
+// Hash returns the hash of the header at the height in the best chain.
+func (r *Repository) Hash(height int) (*bitcoin.Hash32, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if height < 0 || height >= len(r.hashes) {
+		return nil, errors.Wrapf(ErrHeaderNotFound, "height %d", height)
+	}
+
+	hash := r.hashes[height]
+	return &hash, nil
+}
+
+// HashHeight returns the height of the header with the hash if it is in the best chain.
+func (r *Repository) HashHeight(hash bitcoin.Hash32) (int, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	height, exists := r.heights[hash]
+	return height, exists
+}
+
+// Header returns the header with the hash from any branch, and its height.
+func (r *Repository) Header(ctx context.Context, hash bitcoin.Hash32) (*wire.BlockHeader, int,
+	error) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	data, err := r.getData(ctx, hash)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	header := data.Header
+	return &header, data.Height, nil
+}
+
+// BlockHeader returns the header with the hash if it is in the best chain. Headers on other
+// branches are not returned so that merkle proofs for orphaned blocks don't verify. It implements
+// merkle_proof.HeaderGetter.
+func (r *Repository) BlockHeader(ctx context.Context,
+	blockHash bitcoin.Hash32) (*wire.BlockHeader, error) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	if _, exists := r.heights[blockHash]; !exists {
+		return nil, errors.Wrapf(ErrHeaderNotFound, "%s", blockHash)
+	}
+
+	data, err := r.getData(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	header := data.Header
+	return &header, nil
+}
+
+// BlockHeaderAtHeight returns the header at the height in the best chain. It implements
+// merkle_proof.HeaderGetter.
+func (r *Repository) BlockHeaderAtHeight(ctx context.Context,
+	height int) (*wire.BlockHeader, error) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	if height < 0 || height >= len(r.hashes) {
+		return nil, errors.Wrapf(ErrHeaderNotFound, "height %d", height)
+	}
+
+	data, err := r.getData(ctx, r.hashes[height])
+	if err != nil {
+		return nil, err
+	}
+
+	header := data.Header
+	return &header, nil
+}
+
+// AddHeaders adds the headers in order. See AddHeader.
+func (r *Repository) AddHeaders(ctx context.Context, headers []*wire.BlockHeader) error {
+	for i, header := range headers {
+		if _, err := r.AddHeader(ctx, header); err != nil {
+			return errors.Wrapf(err, "header %d", i)
+		}
+	}
+
+	return nil
+}
+
+// AddHeader validates the header's proof of work, difficulty, and checkpoints and saves it. The
+// previous header must already be in the repository. When the header's branch has more work than the best chain it becomes
+// the best chain and the update is returned and sent to subscribers. It returns nil when the best
+// chain didn't change, including when the header was already added.
+func (r *Repository) AddHeader(ctx context.Context,
+	header *wire.BlockHeader) (*wire.ChainTipUpdate, error) {
+
+	hash := *header.BlockHash()
+	if !header.WorkIsValid() {
+		return nil, errors.Wrapf(ErrInvalidProofOfWork, "%s", hash)
+	}
+
+	r.Lock()
+	update, err := r.addHeader(ctx, header, hash)
+	var subscriptions []chan<- *wire.ChainTipUpdate
+	if update != nil {
+		for _, updates := range r.subscriptions {
+			subscriptions = append(subscriptions, updates)
+		}
+	}
+	r.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, updates := range subscriptions {
+		select {
+		case updates <- update:
+		case <-ctx.Done():
+			return update, ctx.Err()
+		}
+	}
+
+	return update, nil
+}
+
+func (r *Repository) addHeader(ctx context.Context, header *wire.BlockHeader,
+	hash bitcoin.Hash32) (*wire.ChainTipUpdate, error) {
+
+	if _, err := r.getData(ctx, hash); err == nil {
+		return nil, nil // already added
+	} else if errors.Cause(err) != ErrHeaderNotFound {
+		return nil, errors.Wrap(err, "get header")
+	}
+
+	previous, err := r.getData(ctx, header.PrevBlock)
+	if err != nil {
+		if errors.Cause(err) == ErrHeaderNotFound {
+			return nil, errors.Wrapf(ErrPreviousNotFound, "%s", header.PrevBlock)
+		}
+		return nil, errors.Wrap(err, "get previous")
+	}
+
+	if err := r.validateHeader(ctx, header, previous); err != nil {
+		return nil, errors.Wrapf(err, "%s", hash)
+	}
+
+	data := &headerData{
+		Header: *header,
+		Height: previous.Height + 1,
+		Work:   new(big.Int).Add(previous.Work, headerWork(header)),
+	}
+
+	if err := storage.Save(ctx, r.store, r.headerPath(hash), data); err != nil {
+		return nil, errors.Wrap(err, "save header")
+	}
+	r.cache.Set(hash.String(), data)
+
+	if data.Work.Cmp(r.tip.Work) <= 0 {
+		return nil, nil // not more work than the best chain
+	}
+
+	// Find where the branch connects to the best chain.
+	connected := []bitcoin.Hash32{hash}
+	forkData := previous
+	forkHash := header.PrevBlock
+	for {
+		if height, exists := r.heights[forkHash]; exists && height == forkData.Height {
+			break
+		}
+
+		connected = append(connected, forkHash)
+		forkHash = forkData.Header.PrevBlock
+		forkData, err = r.getData(ctx, forkHash)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get branch %s", forkHash)
+		}
+	}
+
+	update := &wire.ChainTipUpdate{
+		Height:     data.Height,
+		Hash:       hash,
+		ForkHeight: forkData.Height,
+	}
+
+	for height := len(r.hashes) - 1; height > forkData.Height; height-- {
+		update.Disconnected = append(update.Disconnected, r.hashes[height])
+		delete(r.heights, r.hashes[height])
+	}
+
+	previousCount := len(r.hashes)
+	r.hashes = r.hashes[:forkData.Height+1]
+	for i := len(connected) - 1; i >= 0; i-- {
+		r.hashes = append(r.hashes, connected[i])
+		r.heights[connected[i]] = len(r.hashes) - 1
+	}
+	r.tip = data
+
+	if err := r.saveHeights(ctx, forkData.Height+1, previousCount); err != nil {
+		return nil, errors.Wrap(err, "save heights")
+	}
+
+	return update, nil
+}
+
+// validateHeader checks the header against the consensus rules of the config. previous is the
+// record of the header's previous header.
+func (r *Repository) validateHeader(ctx context.Context, header *wire.BlockHeader,
+	previous *headerData) error {
+
+	// Collect the headers of the branch down to where it connects to the best chain.
+	branch := make(map[int]*wire.BlockHeader)
+	forkData := previous
+	for {
+		if height, exists := r.heights[*forkData.Header.BlockHash()]; exists &&
+			height == forkData.Height {
+			break
+		}
+
+		branch[forkData.Height] = &forkData.Header
+
+		prevHash := forkData.Header.PrevBlock
+		var err error
+		forkData, err = r.getData(ctx, prevHash)
+		if err != nil {
+			return errors.Wrapf(err, "get branch %s", prevHash)
+		}
+	}
+
+	if err := r.config.CheckFork(forkData.Height, len(r.hashes)-1); err != nil {
+		return err
+	}
+
+	var ancestorErr error
+	ancestor := func(height int) *wire.BlockHeader {
+		if header, exists := branch[height]; exists {
+			return header
+		}
+
+		data, err := r.getData(ctx, r.hashes[height])
+		if err != nil {
+			if ancestorErr == nil {
+				ancestorErr = errors.Wrapf(err, "get height %d", height)
+			}
+			return &wire.BlockHeader{}
+		}
+		return &data.Header
+	}
+
+	err := r.config.CheckHeader(header, previous.Height+1, ancestor)
+	if ancestorErr != nil {
+		return ancestorErr
+	}
+	return err
+}
+
+// getData returns the record for the header from the cache or storage.
+func (r *Repository) getData(ctx context.Context, hash bitcoin.Hash32) (*headerData, error) {
+	if value, exists := r.cache.Get(hash.String()); exists {
+		return value.(*headerData), nil
+	}
+
+	data := &headerData{}
+	if err := storage.Load(ctx, r.store, r.headerPath(hash), data); err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return nil, errors.Wrapf(ErrHeaderNotFound, "%s", hash)
+		}
+		return nil, errors.Wrapf(err, "load %s", hash)
+	}
+
+	r.cache.Set(hash.String(), data)
+	return data, nil
+}
+
+// Load reads the best chain from storage. If storage is empty the genesis header is saved. The
+// first header in storage must match the genesis header.
+func (r *Repository) Load(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.reset()
+	genesisHash := r.hashes[0]
+
+	b, err := r.store.Read(ctx, r.tipPath())
+	if err != nil {
+		if errors.Cause(err) != storage.ErrNotFound {
+			return errors.Wrap(err, "read tip")
+		}
+
+		if err := storage.Save(ctx, r.store, r.headerPath(genesisHash), r.tip); err != nil {
+			return errors.Wrap(err, "save genesis")
+		}
+		return r.saveHeights(ctx, 0, 0)
+	}
+
+	if len(b) != 4 {
+		return fmt.Errorf("Wrong tip size : %d", len(b))
+	}
+	tipHeight := int(Endian.Uint32(b))
+
+	r.hashes = nil
+	r.heights = make(map[bitcoin.Hash32]int)
+	for file := 0; file <= tipHeight/hashesPerFile; file++ {
+		b, err := r.store.Read(ctx, r.heightsPath(file))
+		if err != nil {
+			return errors.Wrapf(err, "read heights %d", file)
+		}
+
+		reader := bytes.NewReader(b)
+		for reader.Len() > 0 && len(r.hashes) <= tipHeight {
+			var hash bitcoin.Hash32
+			if err := hash.Deserialize(reader); err != nil {
+				return errors.Wrapf(err, "hash %d", len(r.hashes))
+			}
+
+			r.heights[hash] = len(r.hashes)
+			r.hashes = append(r.hashes, hash)
+		}
+	}
+
+	if len(r.hashes) != tipHeight+1 {
+		return fmt.Errorf("Missing heights : got %d, want %d", len(r.hashes), tipHeight+1)
+	}
+
+	if !r.hashes[0].Equal(&genesisHash) {
+		return errors.Wrapf(ErrWrongGenesis, "%s", r.hashes[0])
+	}
+
+	tip, err := r.getData(ctx, r.hashes[tipHeight])
+	if err != nil {
+		return errors.Wrap(err, "tip")
+	}
+	r.tip = tip
+
+	return nil
+}
+
+// saveHeights saves the height index from the modified height to the end of the best chain, and
+// removes index items beyond the end of the chain, which previously contained previousCount
+// hashes. The tip is saved last so that a partial save doesn't extend the chain.
+func (r *Repository) saveHeights(ctx context.Context, modifiedHeight, previousCount int) error {
+	count := len(r.hashes)
+	lastFile := (count - 1) / hashesPerFile
+	for file := modifiedHeight / hashesPerFile; file <= lastFile; file++ {
+		end := (file + 1) * hashesPerFile
+		if end > count {
+			end = count
+		}
+
+		buf := bytes.NewBuffer(make([]byte, 0, (end-file*hashesPerFile)*bitcoin.Hash32Size))
+		for _, hash := range r.hashes[file*hashesPerFile : end] {
+			if err := hash.Serialize(buf); err != nil {
+				return errors.Wrapf(err, "serialize %d", file)
+			}
+		}
+
+		if err := r.store.Write(ctx, r.heightsPath(file), buf.Bytes(), nil); err != nil {
+			return errors.Wrapf(err, "write %d", file)
+		}
+	}
+
+	previousFiles := (previousCount + hashesPerFile - 1) / hashesPerFile
+	for file := lastFile + 1; file < previousFiles; file++ {
+		if err := r.store.Remove(ctx, r.heightsPath(file)); err != nil &&
+			errors.Cause(err) != storage.ErrNotFound {
+			return errors.Wrapf(err, "remove %d", file)
+		}
+	}
+
+	tip := make([]byte, 4)
+	Endian.PutUint32(tip, uint32(count-1))
+	if err := r.store.Write(ctx, r.tipPath(), tip, nil); err != nil {
+		return errors.Wrap(err, "write tip")
+	}
+
+	return nil
+}
+
+func (r *Repository) headerPath(hash bitcoin.Hash32) string {
+	return fmt.Sprintf("%s/hashes/%s", r.path, hash)
+}
+
+func (r *Repository) heightsPath(file int) string {
+	return fmt.Sprintf("%s/heights/%08d", r.path, file)
+}
+
+func (r *Repository) tipPath() string {
+	return fmt.Sprintf("%s/tip", r.path)
+}
+
+// headerWork returns the work required to produce the header.
+func headerWork(header *wire.BlockHeader) *big.Int {
+	return bitcoin.ConvertToWork(bitcoin.ConvertToDifficulty(header.Bits))
+}
+
+func (d headerData) Serialize(w io.Writer) error {
+	if err := d.Header.Serialize(w); err != nil {
+		return errors.Wrap(err, "header")
+	}
+
+	if err := binary.Write(w, Endian, uint32(d.Height)); err != nil {
+		return errors.Wrap(err, "height")
+	}
+
+	work := d.Work.Bytes()
+	if err := wire.WriteVarInt(w, 0, uint64(len(work))); err != nil {
+		return errors.Wrap(err, "work size")
+	}
+	if _, err := w.Write(work); err != nil {
+		return errors.Wrap(err, "work")
+	}
+
+	return nil
+}
+
+func (d *headerData) Deserialize(r io.Reader) error {
+	if err := d.Header.Deserialize(r); err != nil {
+		return errors.Wrap(err, "header")
+	}
+
+	var height uint32
+	if err := binary.Read(r, Endian, &height); err != nil {
+		return errors.Wrap(err, "height")
+	}
+	d.Height = int(height)
+
+	size, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "work size")
+	}
+	if size > 64 {
+		return fmt.Errorf("Work too large : %d bytes", size)
+	}
+
+	work := make([]byte, size)
+	if _, err := io.ReadFull(r, work); err != nil {
+		return errors.Wrap(err, "work")
+	}
+	d.Work = new(big.Int).SetBytes(work)
+
+	return nil
+}
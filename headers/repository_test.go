@@ -0,0 +1,283 @@
+package headers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	testEasyBits = 0x207fffff // about half of hashes are valid
+	testHardBits = 0x2000ffff // about 1 in 256 hashes are valid
+)
+
+var testConfig = wire.HeaderSyncConfig{
+	PowLimitBits:               testEasyBits,
+	DifficultyAdjustmentHeight: -1,
+}
+
+// mineHeaders creates a chain of valid headers following the previous header.
+func mineHeaders(previous *wire.BlockHeader, count int, bits uint32,
+	seed uint32) []*wire.BlockHeader {
+
+	var result []*wire.BlockHeader
+	prevHash := *previous.BlockHash()
+	timestamp := previous.Timestamp
+	for i := 0; i < count; i++ {
+		timestamp++
+		header := &wire.BlockHeader{
+			Version:   1,
+			PrevBlock: prevHash,
+			Timestamp: timestamp,
+			Bits:      bits,
+			Nonce:     seed << 24,
+		}
+		for !header.WorkIsValid() {
+			header.Nonce++
+		}
+
+		result = append(result, header)
+		prevHash = *header.BlockHash()
+	}
+
+	return result
+}
+
+func TestRepositoryReorg(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+	genesis := &wire.BlockHeader{Version: 1, Bits: testEasyBits}
+	repo := NewRepository(testConfig, store, genesis)
+	if err := repo.Load(ctx); err != nil {
+		t.Fatalf("Failed to load repository : %s", err)
+	}
+
+	updates := make(chan *wire.ChainTipUpdate, 10)
+	repo.Subscribe(updates)
+
+	main := mineHeaders(genesis, 5, testEasyBits, 1)
+	if err := repo.AddHeaders(ctx, main); err != nil {
+		t.Fatalf("Failed to add headers : %s", err)
+	}
+
+	if len(updates) != 5 {
+		t.Fatalf("Wrong update count : got %d, want %d", len(updates), 5)
+	}
+	for len(updates) > 0 {
+		if update := <-updates; update.IsReorg() {
+			t.Errorf("Update should not be a reorg : %+v", update)
+		}
+	}
+
+	height, tipHash := repo.Tip()
+	if height != 5 || !tipHash.Equal(main[4].BlockHash()) {
+		t.Fatalf("Wrong tip : %d %s", height, tipHash)
+	}
+
+	// Adding a header again doesn't change anything.
+	if update, err := repo.AddHeader(ctx, main[2]); err != nil || update != nil {
+		t.Errorf("Duplicate header should be ignored : %v %v", update, err)
+	}
+
+	// Branch with less work than the best chain.
+	branch := mineHeaders(main[1], 2, testEasyBits, 2)
+	if err := repo.AddHeaders(ctx, branch); err != nil {
+		t.Fatalf("Failed to add branch : %s", err)
+	}
+
+	if len(updates) != 0 || repo.Height() != 5 {
+		t.Fatalf("Branch with less work should not change the best chain")
+	}
+
+	header, branchHeight, err := repo.Header(ctx, *branch[1].BlockHash())
+	if err != nil {
+		t.Fatalf("Failed to get branch header : %s", err)
+	}
+	if branchHeight != 4 || !header.BlockHash().Equal(branch[1].BlockHash()) {
+		t.Errorf("Wrong branch header height : got %d, want %d", branchHeight, 4)
+	}
+
+	_, err = repo.BlockHeader(ctx, *branch[1].BlockHash())
+	if errors.Cause(err) != ErrHeaderNotFound {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrHeaderNotFound)
+	}
+
+	// Extend the branch with a hard header so it has more work.
+	hard := mineHeaders(branch[1], 1, testHardBits, 3)
+	update, err := repo.AddHeader(ctx, hard[0])
+	if err != nil {
+		t.Fatalf("Failed to add hard header : %s", err)
+	}
+
+	if update == nil || !update.IsReorg() || update.ForkHeight != 2 || update.Height != 5 ||
+		len(update.Disconnected) != 3 {
+		t.Fatalf("Wrong reorg update : %+v", update)
+	}
+	if !update.Disconnected[0].Equal(main[4].BlockHash()) {
+		t.Errorf("Wrong first disconnected : got %s, want %s", update.Disconnected[0],
+			main[4].BlockHash())
+	}
+
+	if len(updates) != 1 || <-updates != update {
+		t.Errorf("Subscriber should receive the reorg update")
+	}
+
+	for i, header := range append(branch, hard...) {
+		h, err := repo.BlockHeaderAtHeight(ctx, 3+i)
+		if err != nil {
+			t.Fatalf("Failed to get header at height %d : %s", 3+i, err)
+		}
+		if !h.BlockHash().Equal(header.BlockHash()) {
+			t.Errorf("Wrong header at height %d", 3+i)
+		}
+	}
+
+	if _, exists := repo.HashHeight(*main[4].BlockHash()); exists {
+		t.Errorf("Disconnected header should not be in the best chain")
+	}
+
+	// Reload from storage.
+	loaded := NewRepository(testConfig, store, genesis)
+	if err := loaded.Load(ctx); err != nil {
+		t.Fatalf("Failed to load repository : %s", err)
+	}
+
+	loadedHeight, loadedHash := loaded.Tip()
+	if loadedHeight != 5 || !loadedHash.Equal(hard[0].BlockHash()) {
+		t.Fatalf("Wrong loaded tip : %d %s", loadedHeight, loadedHash)
+	}
+
+	if loaded.Work().Cmp(repo.Work()) != 0 {
+		t.Errorf("Wrong loaded work : got %s, want %s", loaded.Work(), repo.Work())
+	}
+
+	// The old main chain can still be extended from storage.
+	more := mineHeaders(main[4], 2, testHardBits, 4)
+	update, err = loaded.AddHeader(ctx, more[0])
+	if err != nil {
+		t.Fatalf("Failed to add header to old chain : %s", err)
+	}
+	if update == nil || update.ForkHeight != 2 || update.Height != 6 {
+		t.Fatalf("Wrong reorg update : %+v", update)
+	}
+
+	// Wrong genesis
+	other := NewRepository(testConfig, store, &wire.BlockHeader{Version: 2, Bits: testEasyBits})
+	if err := other.Load(ctx); errors.Cause(err) != ErrWrongGenesis {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrWrongGenesis)
+	}
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	ctx := context.Background()
+	genesis := &wire.BlockHeader{Version: 1, Bits: testEasyBits}
+	repo := NewRepository(testConfig, storage.NewMockStorage(), genesis)
+	if err := repo.Load(ctx); err != nil {
+		t.Fatalf("Failed to load repository : %s", err)
+	}
+
+	headers := mineHeaders(genesis, 2, testEasyBits, 1)
+	if _, err := repo.AddHeader(ctx, headers[1]); errors.Cause(err) != ErrPreviousNotFound {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrPreviousNotFound)
+	}
+
+	invalid := *headers[0]
+	for invalid.WorkIsValid() {
+		invalid.Nonce++
+	}
+	if _, err := repo.AddHeader(ctx, &invalid); errors.Cause(err) != ErrInvalidProofOfWork {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrInvalidProofOfWork)
+	}
+}
+
+func TestRepositoryMerkleProof(t *testing.T) {
+	ctx := context.Background()
+	genesis := &wire.BlockHeader{Version: 1, Bits: testEasyBits}
+	repo := NewRepository(testConfig, storage.NewMockStorage(), genesis)
+
+	tree := merkle_proof.NewMerkleTree(false)
+	txid := bitcoin.Hash32{1}
+	tree.AddMerkleProof(txid)
+	tree.AddHash(txid)
+	tree.AddHash(bitcoin.Hash32{2})
+	root, proofs := tree.FinalizeMerkleProofs()
+
+	header := &wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  *genesis.BlockHash(),
+		MerkleRoot: root,
+		Bits:       testEasyBits,
+	}
+	for !header.WorkIsValid() {
+		header.Nonce++
+	}
+
+	if _, err := repo.AddHeader(ctx, header); err != nil {
+		t.Fatalf("Failed to add header : %s", err)
+	}
+
+	proofs[0].BlockHash = header.BlockHash()
+	results := merkle_proof.NewVerifier(repo, 1).VerifyAtHeights(ctx, proofs, []int{1})
+	if results[0].Err != nil {
+		t.Fatalf("Failed to verify merkle proof : %s", results[0].Err)
+	}
+}
+
+func TestRepositoryConsensusRules(t *testing.T) {
+	ctx := context.Background()
+	genesis := &wire.BlockHeader{Version: 1, Bits: testHardBits}
+	main := mineHeaders(genesis, 3, testHardBits, 1)
+
+	config := wire.HeaderSyncConfig{
+		PowLimitBits:               testHardBits,
+		DifficultyAdjustmentHeight: -1,
+		Checkpoints: []wire.Checkpoint{
+			{Height: 2, Hash: *main[1].BlockHash()},
+		},
+	}
+
+	store := storage.NewMockStorage()
+	repo := NewRepository(config, store, genesis)
+	if err := repo.Load(ctx); err != nil {
+		t.Fatalf("Failed to load repository : %s", err)
+	}
+
+	// Headers below the proof of work limit are not saved.
+	easy := mineHeaders(genesis, 1, testEasyBits, 2)[0]
+	if _, err := repo.AddHeader(ctx, easy); errors.Cause(err) != wire.ErrInvalidDifficulty {
+		t.Fatalf("Wrong error : got %v, want %v", err, wire.ErrInvalidDifficulty)
+	}
+
+	if _, _, err := repo.Header(ctx, *easy.BlockHash()); errors.Cause(err) != ErrHeaderNotFound {
+		t.Fatalf("Invalid header should not be saved : %v", err)
+	}
+
+	// A header that doesn't match a checkpoint is rejected.
+	wrong := mineHeaders(main[0], 1, testHardBits, 3)[0]
+	if err := repo.AddHeaders(ctx, main[:1]); err != nil {
+		t.Fatalf("Failed to add header : %s", err)
+	}
+	if _, err := repo.AddHeader(ctx, wrong); errors.Cause(err) != wire.ErrCheckpointMismatch {
+		t.Fatalf("Wrong error : got %v, want %v", err, wire.ErrCheckpointMismatch)
+	}
+
+	// Branches that fork below the checkpoint are rejected once it is in the best chain.
+	if err := repo.AddHeaders(ctx, main[1:]); err != nil {
+		t.Fatalf("Failed to add headers : %s", err)
+	}
+
+	branch := mineHeaders(genesis, 1, testHardBits, 4)[0]
+	if _, err := repo.AddHeader(ctx, branch); errors.Cause(err) != wire.ErrCheckpointMismatch {
+		t.Fatalf("Wrong error : got %v, want %v", err, wire.ErrCheckpointMismatch)
+	}
+
+	if repo.Height() != 3 {
+		t.Fatalf("Wrong height : got %d, want %d", repo.Height(), 3)
+	}
+}
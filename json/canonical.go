@@ -0,0 +1,259 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// MarshalCanonical returns the canonical JSON encoding of v. See Canonicalize.
+func MarshalCanonical(v interface{}) ([]byte, error) {
+	b, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(b)
+}
+
+// Canonicalize returns the canonical form of the JSON-encoded data as specified by the JSON
+// Canonicalization Scheme (RFC 8785), so that signatures over JSON are reproducible.
+//
+// Object members are sorted by key, compared as UTF-16 code units, and there is no insignificant
+// whitespace. Strings are written as UTF-8 with only the required characters escaped. Numbers are
+// written in the ECMAScript number format, so like any JSON number that is converted to a double,
+// integers with a magnitude larger than 2^53 can lose precision. Objects with duplicate member
+// names are rejected.
+func Canonicalize(data []byte) ([]byte, error) {
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	v, err := readCanonical(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("json: invalid data after top-level value")
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readCanonical reads the next value from the decoder's tokens. Objects are read member by member,
+// instead of being decoded into a map, so duplicate member names can be detected.
+func readCanonical(dec *Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case Delim('['):
+		items := []interface{}{}
+		for dec.More() {
+			item, err := readCanonical(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if _, err := dec.Token(); err != nil { // ]
+			return nil, err
+		}
+		return items, nil
+
+	case Delim('{'):
+		members := make(map[string]interface{})
+		for dec.More() {
+			keyToken, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyToken.(string)
+			if !ok {
+				return nil, fmt.Errorf("json: invalid object member name %v", keyToken)
+			}
+			if _, exists := members[key]; exists {
+				return nil, fmt.Errorf("json: duplicate object member name %q", key)
+			}
+
+			value, err := readCanonical(dec)
+			if err != nil {
+				return nil, err
+			}
+			members[key] = value
+		}
+		if _, err := dec.Token(); err != nil { // }
+			return nil, err
+		}
+		return members, nil
+	}
+
+	return token, nil
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return err
+		}
+		s, err := canonicalNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case string:
+		writeCanonicalString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("json: unsupported canonical type %T", v)
+	}
+	return nil
+}
+
+// canonicalNumber formats f like the ECMAScript Number.prototype.toString method.
+func canonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", &UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	if f == 0 {
+		return "0", nil // includes negative zero
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	// Shortest digits that round trip, and the decimal exponent n such that the value is
+	// 0.digits * 10^n.
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp := e, 0
+	if i := strings.IndexByte(e, 'e'); i >= 0 {
+		mantissa = e[:i]
+		var err error
+		if exp, err = strconv.Atoi(e[i+1:]); err != nil {
+			return "", err
+		}
+	}
+	digits := strings.Replace(mantissa, ".", "", 1)
+	k := len(digits)
+	n := exp + 1
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+
+	result := digits[:1]
+	if k > 1 {
+		result += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		result += "e+" + strconv.Itoa(n-1)
+	} else {
+		result += "e" + strconv.Itoa(n-1)
+	}
+	return sign + result, nil
+}
+
+// writeCanonicalString writes s as a JSON string, escaping only quotes, backslashes, and control
+// characters.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexChars[c>>4])
+				buf.WriteByte(hexChars[c&0xF])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 compares strings by their UTF-16 code units.
+func lessUTF16(a, b string) bool {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
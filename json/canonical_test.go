@@ -0,0 +1,81 @@
+package json
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{ "b" : 2, "a" : [ 1, true, null ] }`, `{"a":[1,true,null],"b":2}`},
+		{`{"€":1,"\r":2,"1":3,"é":4}`, `{"\r":2,"1":3,"é":4,"€":1}`},
+		{`{"\ufb2c":2,"\ud83d\ude00":1}`, "{\"\U0001F600\":1,\"\uFB2C\":2}"},
+		{`"\u0001\u001f</script> "`, `"\u0001\u001f</script>` + " " + `"`},
+		{`[4.50, 2e-3, 0.000001, 1e-7, 1E21, 1e20, -0, 1e30]`,
+			`[4.5,0.002,0.000001,1e-7,1e+21,100000000000000000000,0,1e+30]`},
+		{`[333333333.33333329, -1.5e-10, 9007199254740991]`,
+			`[333333333.3333333,-1.5e-10,9007199254740991]`},
+	}
+
+	for _, tt := range tests {
+		got, err := Canonicalize([]byte(tt.in))
+		if err != nil {
+			t.Errorf("Canonicalize(%s) : %s", tt.in, err)
+			continue
+		}
+
+		if string(got) != tt.want {
+			t.Errorf("Canonicalize(%s) :\n got  %s\n want %s", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := Canonicalize([]byte(`{} {}`)); err == nil {
+		t.Errorf("Canonicalize should fail with trailing data")
+	}
+
+	for _, in := range []string{
+		`{"a":1,"a":2}`,
+		`{"a":1,"\u0061":1}`,
+		`[{"b":{"c":1,"c":1}}]`,
+		``,
+		`{"a" 1}`,
+		`[1,]`,
+		`{"a":1`,
+	} {
+		if _, err := Canonicalize([]byte(in)); err == nil {
+			t.Errorf("Canonicalize(%s) should fail", in)
+		}
+	}
+
+	// Empty containers and the same name in different objects are valid.
+	in := `{"a":{"a":[]},"b":[{"a":1},{"a":{}}]}`
+	if got, err := Canonicalize([]byte(in)); err != nil {
+		t.Errorf("Canonicalize(%s) : %s", in, err)
+	} else if string(got) != in {
+		t.Errorf("Canonicalize(%s) :\n got  %s\n want %s", in, got, in)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	v := struct {
+		Zebra string            `json:"zebra"`
+		Apple int               `json:"apple"`
+		Map   map[string]string `json:"map"`
+	}{
+		Zebra: "z",
+		Apple: 1,
+		Map:   map[string]string{"y": "1", "x": "2"},
+	}
+
+	got, err := MarshalCanonical(v)
+	if err != nil {
+		t.Fatalf("MarshalCanonical : %s", err)
+	}
+
+	want := `{"apple":1,"map":{"x":"2","y":"1"},"zebra":"z"}`
+	if string(got) != want {
+		t.Errorf("MarshalCanonical :\n got  %s\n want %s", got, want)
+	}
+}
@@ -2,17 +2,35 @@ package json_envelope
 
 import (
 	"crypto/sha256"
-	"errors"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 
 	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MimeTypeJSON = "application/json"
+
+	EncodingUTF8   = "UTF-8"
+	EncodingBase64 = "base64"
+	EncodingHex    = "hex"
 )
 
 var (
 	ErrInvalidJSONSignature = errors.New("Invalid JSON Signature")
 
 	ErrJSONNotSigned = errors.New("JSON Not Signed")
+
+	ErrUnsupportedEncoding = errors.New("Unsupported Encoding")
 )
 
+// JSONEnvelope wraps a payload with its MIME type and encoding and an optional signature of the
+// payload by a public key, as specified in BRFC 298e080a4598. The signature is of the SHA256 hash of
+// the encoded payload string.
 type JSONEnvelope struct {
 	Payload   string             `json:"payload"`
 	Signature *bitcoin.Signature `json:"signature"`
@@ -35,3 +53,74 @@ func (je *JSONEnvelope) Verify() error {
 
 	return nil
 }
+
+// NewJSONEnvelope creates an unsigned envelope containing the JSON of the value.
+func NewJSONEnvelope(value interface{}) (*JSONEnvelope, error) {
+	js, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal")
+	}
+
+	return &JSONEnvelope{
+		Payload:  string(js),
+		Encoding: EncodingUTF8,
+		MimeType: MimeTypeJSON,
+	}, nil
+}
+
+// NewEnvelope creates an unsigned envelope containing the payload with the MIME type. The payload
+// is base64 encoded.
+func NewEnvelope(mimeType string, payload []byte) *JSONEnvelope {
+	return &JSONEnvelope{
+		Payload:  base64.StdEncoding.EncodeToString(payload),
+		Encoding: EncodingBase64,
+		MimeType: mimeType,
+	}
+}
+
+// Sign signs the payload with the key and sets the signature and public key.
+func (je *JSONEnvelope) Sign(key bitcoin.Key) error {
+	hash := bitcoin.Hash32(sha256.Sum256([]byte(je.Payload)))
+
+	signature, err := key.Sign(hash)
+	if err != nil {
+		return errors.Wrap(err, "sign")
+	}
+
+	publicKey := key.PublicKey()
+	je.Signature = &signature
+	je.PublicKey = &publicKey
+	return nil
+}
+
+// PayloadBytes returns the payload decoded according to the envelope's encoding.
+func (je *JSONEnvelope) PayloadBytes() ([]byte, error) {
+	switch je.Encoding {
+	case EncodingUTF8, "utf-8", "utf8", "":
+		return []byte(je.Payload), nil
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(je.Payload)
+	case EncodingHex:
+		return hex.DecodeString(je.Payload)
+	default:
+		return nil, errors.Wrap(ErrUnsupportedEncoding, je.Encoding)
+	}
+}
+
+// Unmarshal unmarshals the JSON payload into value. It does not verify the signature.
+func (je *JSONEnvelope) Unmarshal(value interface{}) error {
+	if je.MimeType != MimeTypeJSON {
+		return fmt.Errorf("MIME Type not JSON : %s", je.MimeType)
+	}
+
+	b, err := je.PayloadBytes()
+	if err != nil {
+		return errors.Wrap(err, "payload")
+	}
+
+	if err := json.Unmarshal(b, value); err != nil {
+		return errors.Wrap(err, "json unmarshal")
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package json_envelope
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+
+	"github.com/pkg/errors"
+)
+
+func TestSignVerify(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	value := map[string]string{"name": "value"}
+	envelope, err := NewJSONEnvelope(value)
+	if err != nil {
+		t.Fatalf("Failed to create envelope : %s", err)
+	}
+
+	if err := envelope.Verify(); errors.Cause(err) != ErrJSONNotSigned {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrJSONNotSigned)
+	}
+
+	if err := envelope.Sign(key); err != nil {
+		t.Fatalf("Failed to sign envelope : %s", err)
+	}
+
+	// Encode and decode as it would be sent.
+	js, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope : %s", err)
+	}
+
+	decoded := &JSONEnvelope{}
+	if err := json.Unmarshal(js, decoded); err != nil {
+		t.Fatalf("Failed to unmarshal envelope : %s", err)
+	}
+
+	if err := decoded.Verify(); err != nil {
+		t.Fatalf("Failed to verify envelope : %s", err)
+	}
+
+	result := make(map[string]string)
+	if err := decoded.Unmarshal(&result); err != nil {
+		t.Fatalf("Failed to unmarshal payload : %s", err)
+	}
+
+	if result["name"] != "value" {
+		t.Errorf("Wrong payload value : got %s, want %s", result["name"], "value")
+	}
+
+	decoded.Payload = `{"name":"other"}`
+	if err := decoded.Verify(); errors.Cause(err) != ErrInvalidJSONSignature {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrInvalidJSONSignature)
+	}
+}
+
+func TestPayloadBytes(t *testing.T) {
+	payload := []byte{0x00, 0x01, 0xfe, 0xff}
+
+	envelope := NewEnvelope("application/octet-stream", payload)
+	b, err := envelope.PayloadBytes()
+	if err != nil {
+		t.Fatalf("Failed to decode payload : %s", err)
+	}
+
+	if !bytes.Equal(b, payload) {
+		t.Errorf("Wrong payload : got %x, want %x", b, payload)
+	}
+
+	envelope.Encoding = "unknown"
+	if _, err := envelope.PayloadBytes(); errors.Cause(err) != ErrUnsupportedEncoding {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrUnsupportedEncoding)
+	}
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+)
+
+// BoundLogger logs entries with the configuration of a context and a set of fields that are bound
+//   to it. It is retrieved with From so a logger can be passed to code that doesn't take a context.
+//   It also implements the Logger interface.
+type BoundLogger struct {
+	ctx    context.Context
+	fields []Field
+}
+
+// From returns a logger that uses the logging configuration attached to the context.
+func From(ctx context.Context) *BoundLogger {
+	return &BoundLogger{ctx: ctx}
+}
+
+// With returns a new logger with the fields bound to it in addition to the fields already bound.
+func (l *BoundLogger) With(fields ...Field) *BoundLogger {
+	result := &BoundLogger{
+		ctx:    l.ctx,
+		fields: make([]Field, 0, len(l.fields)+len(fields)),
+	}
+	result.fields = append(result.fields, l.fields...)
+	result.fields = append(result.fields, fields...)
+	return result
+}
+
+// Fields returns the fields bound to the logger.
+func (l *BoundLogger) Fields() []Field {
+	return l.fields
+}
+
+// Context returns a context that logs with the logger's configuration and bound fields.
+func (l *BoundLogger) Context() context.Context {
+	if len(l.fields) == 0 {
+		return l.ctx
+	}
+	return ContextWithLogFields(l.ctx, l.fields...)
+}
+
+// Debug adds a debug level entry to the log.
+func (l *BoundLogger) Debug(format string, values ...interface{}) error {
+	return l.LogDepth(LevelDebug, GetCaller(1), format, values...)
+}
+
+// Verbose adds a verbose level entry to the log.
+func (l *BoundLogger) Verbose(format string, values ...interface{}) error {
+	return l.LogDepth(LevelVerbose, GetCaller(1), format, values...)
+}
+
+// Info adds a info level entry to the log.
+func (l *BoundLogger) Info(format string, values ...interface{}) error {
+	return l.LogDepth(LevelInfo, GetCaller(1), format, values...)
+}
+
+// Warn adds a warn level entry to the log.
+func (l *BoundLogger) Warn(format string, values ...interface{}) error {
+	return l.LogDepth(LevelWarn, GetCaller(1), format, values...)
+}
+
+// Error adds a error level entry to the log.
+func (l *BoundLogger) Error(format string, values ...interface{}) error {
+	return l.LogDepth(LevelError, GetCaller(1), format, values...)
+}
+
+// Log adds an entry with the level to the log.
+func (l *BoundLogger) Log(level Level, format string, values ...interface{}) error {
+	return l.LogDepth(level, GetCaller(1), format, values...)
+}
+
+// LogDepth is the same as Log, but the file name/line of code is specified as caller.
+func (l *BoundLogger) LogDepth(level Level, caller string, format string,
+	values ...interface{}) error {
+
+	return LogDepthWithFields(l.ctx, level, caller, l.fields, format, values...)
+}
+
+// Print adds an info level entry to the log. It is part of the Logger interface.
+func (l *BoundLogger) Print(v ...interface{}) {
+	l.LogDepth(LevelInfo, GetCaller(1), "%s", fmt.Sprint(v...))
+}
+
+// Printf adds an info level entry to the log. It is part of the Logger interface.
+func (l *BoundLogger) Printf(format string, v ...interface{}) {
+	l.LogDepth(LevelInfo, GetCaller(1), format, v...)
+}
+
+// Println adds an info level entry to the log. It is part of the Logger interface.
+func (l *BoundLogger) Println(v ...interface{}) {
+	l.LogDepth(LevelInfo, GetCaller(1), "%s", fmt.Sprint(v...))
+}
+
+// Fatal adds a fatal level entry to the log and then calls os.Exit(1).
+func (l *BoundLogger) Fatal(v ...interface{}) {
+	l.LogDepth(LevelFatal, GetCaller(1), "%s", fmt.Sprint(v...))
+}
+
+// Fatalf adds a fatal level entry to the log and then calls os.Exit(1).
+func (l *BoundLogger) Fatalf(format string, v ...interface{}) {
+	l.LogDepth(LevelFatal, GetCaller(1), format, v...)
+}
+
+// Fatalln adds a fatal level entry to the log and then calls os.Exit(1).
+func (l *BoundLogger) Fatalln(v ...interface{}) {
+	l.LogDepth(LevelFatal, GetCaller(1), "%s", fmt.Sprint(v...))
+}
+
+// Panic adds a panic level entry to the log and then calls panic().
+func (l *BoundLogger) Panic(v ...interface{}) {
+	l.LogDepth(LevelPanic, GetCaller(1), "%s", fmt.Sprint(v...))
+}
+
+// Panicf adds a panic level entry to the log and then calls panic().
+func (l *BoundLogger) Panicf(format string, v ...interface{}) {
+	l.LogDepth(LevelPanic, GetCaller(1), format, v...)
+}
+
+// Panicln adds a panic level entry to the log and then calls panic().
+func (l *BoundLogger) Panicln(v ...interface{}) {
+	l.LogDepth(LevelPanic, GetCaller(1), "%s", fmt.Sprint(v...))
+}
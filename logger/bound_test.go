@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+var _ Logger = (*BoundLogger)(nil)
+
+func TestBoundLogger(t *testing.T) {
+	output := &testOutput{}
+	logConfig := NewConfig(false, true, "")
+	logConfig.Active.output = output
+	ctx := ContextWithLogConfig(context.Background(), logConfig)
+	ctx = ContextWithLogFields(ctx, String("context_field", "context_value"))
+
+	log := From(ctx).With(String("request", "abc"))
+	child := log.With(Int("attempt", 2))
+
+	log.Info("Bound entry %d", 1)
+	lines := output.lines()
+	if len(lines) != 1 {
+		t.Fatalf("Wrong line count : got %d, want %d", len(lines), 1)
+	}
+	for _, want := range []string{"Bound entry 1", "abc", "context_value", "bound_test.go"} {
+		if !strings.Contains(lines[0], want) {
+			t.Fatalf("Missing %s : %s", want, lines[0])
+		}
+	}
+	if strings.Contains(lines[0], "attempt") {
+		t.Fatalf("Parent logger contains child field : %s", lines[0])
+	}
+
+	child.Warn("Child entry")
+	lines = output.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "abc") ||
+		!strings.Contains(lines[0], "attempt") {
+		t.Fatalf("Wrong child lines : %v", lines)
+	}
+
+	child.Debug("Hidden debug")
+	if lines = output.lines(); len(lines) != 0 {
+		t.Fatalf("Debug should not be logged : %v", lines)
+	}
+
+	// Fields are kept when passing a context from the logger to a package function.
+	Error(child.Context(), "Context entry")
+	lines = output.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "attempt") {
+		t.Fatalf("Wrong context lines : %v", lines)
+	}
+
+	// Used through the Logger interface.
+	var l Logger = child
+	l.Printf("Printf %s", "entry")
+	lines = output.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "Printf entry") ||
+		!strings.Contains(lines[0], "attempt") {
+		t.Fatalf("Wrong interface lines : %v", lines)
+	}
+}
@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLogBufferSize is the maximum number of entries held by a log buffer when a size isn't
+	// specified. The oldest entries are dropped when it is full.
+	DefaultLogBufferSize = 1000
+)
+
+// logBuffer holds entries below the minimum log level until an error is logged.
+type logBuffer struct {
+	maxSize int
+	entries []*bufferedEntry
+
+	lock sync.Mutex
+}
+
+// bufferedEntry is a formatted entry and what is needed to pass it to sinks when it is written.
+type bufferedEntry struct {
+	data    []byte
+	config  systemConfig // config when the entry was logged, for its fields and sinks
+	time    time.Time
+	level   Level
+	caller  string
+	fields  []Field
+	message string
+}
+
+// ContextWithLogBuffer returns a context where entries below the minimum log level are held in
+// memory instead of being dropped. If an error, or higher, level entry is logged with the context
+// or a context derived from it, the held entries are written first so the detail leading up to
+// the error is available. If no error is logged the held entries are dropped with the context.
+//
+// maxSize is the maximum number of entries held. When it is exceeded the oldest entries are
+// dropped. Zero uses DefaultLogBufferSize.
+func ContextWithLogBuffer(ctx context.Context, maxSize int) context.Context {
+	var config *Config
+
+	configValue := ctx.Value(key)
+	if configValue != nil {
+		contextConfig, ok := configValue.(Config)
+		if ok {
+			config = &contextConfig
+		}
+	}
+
+	if config == nil {
+		newConfig := NewConfig(false, false, "")
+		config = &newConfig
+	}
+
+	if maxSize <= 0 {
+		maxSize = DefaultLogBufferSize
+	}
+
+	config.buffer = &logBuffer{maxSize: maxSize}
+	return context.WithValue(ctx, key, *config)
+}
+
+// writeEntry holds entries below the config's minimum level and writes the held entries before
+// error level entries.
+func (b *logBuffer) writeEntry(config *systemConfig, level Level, caller string, fields []Field,
+	format string, values ...interface{}) error {
+
+	if config.output == nil {
+		return nil
+	}
+
+	if level < config.minLevel {
+		return b.add(config, level, caller, fields, format, values...)
+	}
+
+	if level >= LevelError {
+		b.flush(config.output)
+	}
+
+	return config.writeEntry(level, caller, fields, format, values...)
+}
+
+// add formats the entry with the config and holds it.
+func (b *logBuffer) add(config *systemConfig, level Level, caller string, fields []Field,
+	format string, values ...interface{}) error {
+
+	now := time.Now()
+	output := &bufferOutput{}
+	entryConfig := config.Copy()
+	entryConfig.minLevel = level
+	entryConfig.output = output
+	entryConfig.sinks = nil // the entry isn't passed to sinks unless it is written
+
+	if err := entryConfig.writeEntry(level, caller, fields, format, values...); err != nil {
+		return err
+	}
+	entryConfig.sinks = config.sinks
+
+	entry := &bufferedEntry{
+		data:    output.buf.Bytes(),
+		config:  entryConfig,
+		time:    now,
+		level:   level,
+		caller:  caller,
+		fields:  fields,
+		message: fmt.Sprintf(format, values...),
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.entries = append(b.entries, entry)
+	if len(b.entries) > b.maxSize {
+		b.entries = b.entries[len(b.entries)-b.maxSize:]
+	}
+
+	return nil
+}
+
+// flush writes the held entries to the output and their sinks and removes them.
+func (b *logBuffer) flush(output Output) {
+	b.lock.Lock()
+	entries := b.entries
+	b.entries = nil
+	b.lock.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+
+	output.Lock()
+	for _, entry := range entries {
+		output.Write(entry.data)
+	}
+	output.Unlock()
+
+	for _, entry := range entries {
+		if len(entry.config.sinks) > 0 {
+			entry.config.writeSinks(entry.time, entry.level, entry.caller, entry.fields, "%s",
+				entry.message)
+		}
+	}
+}
+
+// bufferOutput is an Output that collects one formatted entry.
+type bufferOutput struct {
+	buf bytes.Buffer
+}
+
+func (o *bufferOutput) Write(b []byte) (int, error) {
+	return o.buf.Write(b)
+}
+
+func (o *bufferOutput) Lock() {}
+
+func (o *bufferOutput) Unlock() {}
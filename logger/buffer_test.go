@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testOutput struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (o *testOutput) Write(b []byte) (int, error) {
+	return o.buf.Write(b)
+}
+
+func (o *testOutput) Lock() {
+	o.lock.Lock()
+}
+
+func (o *testOutput) Unlock() {
+	o.lock.Unlock()
+}
+
+func (o *testOutput) lines() []string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	s := strings.TrimSpace(o.buf.String())
+	o.buf.Reset()
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func TestLogBuffer(t *testing.T) {
+	output := &testOutput{}
+	logConfig := NewConfig(false, true, "")
+	logConfig.Active.output = output
+	ctx := ContextWithLogConfig(context.Background(), logConfig)
+
+	// Success doesn't write debug entries.
+	successCtx := ContextWithLogBuffer(ctx, 0)
+	Debug(successCtx, "Success debug")
+	Info(successCtx, "Success info")
+
+	lines := output.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "Success info") {
+		t.Fatalf("Wrong success lines : %v", lines)
+	}
+
+	// Failure writes debug entries before the error, including those from derived contexts.
+	failCtx := ContextWithLogBuffer(ctx, 2)
+	Debug(failCtx, "Fail debug 1")
+	Verbose(failCtx, "Fail debug 2")
+	traceCtx := ContextWithLogTrace(failCtx, "trace 1")
+	Debug(traceCtx, "Fail debug 3")
+	Info(failCtx, "Fail info")
+
+	lines = output.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "Fail info") {
+		t.Fatalf("Wrong lines before error : %v", lines)
+	}
+
+	Error(failCtx, "Fail error")
+
+	// The oldest entry was dropped because the buffer size is 2.
+	lines = output.lines()
+	if len(lines) != 3 {
+		t.Fatalf("Wrong line count after error : got %d, want %d : %v", len(lines), 3, lines)
+	}
+
+	if !strings.HasPrefix(lines[0], "verbose") || !strings.Contains(lines[0], "Fail debug 2") {
+		t.Fatalf("Wrong first line : %s", lines[0])
+	}
+
+	if !strings.HasPrefix(lines[1], "debug") || !strings.Contains(lines[1], "Fail debug 3") ||
+		!strings.Contains(lines[1], "trace 1") {
+		t.Fatalf("Wrong second line : %s", lines[1])
+	}
+
+	if !strings.HasPrefix(lines[2], "error") || !strings.Contains(lines[2], "Fail error") {
+		t.Fatalf("Wrong third line : %s", lines[2])
+	}
+
+	// Buffered entries are only written once.
+	Error(failCtx, "Second error")
+	lines = output.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "Second error") {
+		t.Fatalf("Wrong second error lines : %v", lines)
+	}
+
+	// Without a buffer debug entries are dropped.
+	Debug(ctx, "Unbuffered debug")
+	Error(ctx, "Unbuffered error")
+	lines = output.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "Unbuffered error") {
+		t.Fatalf("Wrong unbuffered lines : %v", lines)
+	}
+}
+
+func TestLogBufferSchema(t *testing.T) {
+	output := &testOutput{}
+	logConfig := NewConfig(false, false, "")
+	logConfig.Active.output = output
+	logConfig.SetSchema(Schema{
+		LevelKey:   "severity",
+		MessageKey: "message",
+	})
+	ctx := ContextWithLogBuffer(ContextWithLogConfig(context.Background(), logConfig), 0)
+
+	Debug(ctx, "Buffered debug")
+	Error(ctx, "Buffered error")
+
+	lines := output.lines()
+	if len(lines) != 2 {
+		t.Fatalf("Wrong line count : got %d, want %d : %v", len(lines), 2, lines)
+	}
+
+	for i, want := range []string{"Buffered debug", "Buffered error"} {
+		entry := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatalf("Failed to unmarshal entry : %s : %s", err, lines[i])
+		}
+
+		if entry["message"] != want {
+			t.Fatalf("Wrong message : got %v, want %s : %s", entry["message"], want, lines[i])
+		}
+
+		if _, exists := entry["severity"]; !exists {
+			t.Fatalf("Missing schema level key : %s", lines[i])
+		}
+	}
+}
+
+func TestLogBufferSinks(t *testing.T) {
+	sink := &testSinkCloser{}
+	logConfig := NewConfig(false, true, "")
+	logConfig.Active.output = &testOutput{}
+	logConfig.AddSink(sink)
+	ctx := ContextWithLogBuffer(ContextWithLogConfig(context.Background(), logConfig), 0)
+
+	traceCtx := ContextWithLogTrace(ctx, "trace 1")
+	Debug(traceCtx, "Buffered debug %d", 1)
+
+	sink.lock.Lock()
+	queued := len(sink.queued)
+	sink.lock.Unlock()
+	if queued != 0 {
+		t.Fatalf("Held entries should not be passed to sinks : got %d", queued)
+	}
+
+	Error(ctx, "Buffered error")
+
+	sink.lock.Lock()
+	entries := sink.queued
+	sink.lock.Unlock()
+	if len(entries) != 2 {
+		t.Fatalf("Wrong sink entry count : got %d, want %d", len(entries), 2)
+	}
+
+	if entries[0].Level != LevelDebug || entries[0].Message != "Buffered debug 1" ||
+		entries[0].Trace != "trace 1" {
+		t.Fatalf("Wrong held sink entry : %+v", entries[0])
+	}
+
+	if entries[1].Level != LevelError || entries[1].Message != "Buffered error" ||
+		len(entries[1].Trace) != 0 {
+		t.Fatalf("Wrong error sink entry : %+v", entries[1])
+	}
+}
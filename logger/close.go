@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// SinkCloser is a Sink that holds entries or resources that must be flushed or released when
+// logging stops.
+type SinkCloser interface {
+	Sink
+	Close(ctx context.Context) error
+}
+
+// Close closes the log config attached to the context. See Config.Close. It can be added as a
+// shutdown function of a threads.Supervisor so it is called after everything else has stopped.
+func Close(ctx context.Context) error {
+	configValue := ctx.Value(key)
+	if configValue == nil {
+		return nil
+	}
+
+	config, ok := configValue.(Config)
+	if !ok {
+		return nil
+	}
+
+	return config.Close(ctx)
+}
+
+// Close flushes and closes the sinks that implement SinkCloser and closes the log files of the
+// main log and subsystem logs, so entries written during shutdown aren't lost. It should be called
+// after everything that logs with the config has stopped. Entries written to closed files after
+// it returns are lost.
+func (config *Config) Close(ctx context.Context) error {
+	var allSinks []Sink
+	allSinks = append(allSinks, config.Main.sinks...)
+	allSinks = append(allSinks, config.Active.sinks...)
+	allOutputs := []Output{config.Main.output, config.Active.output}
+	for name := range config.SubSystems {
+		allSinks = append(allSinks, config.SubSystems[name].sinks...)
+		allOutputs = append(allOutputs, config.SubSystems[name].output)
+	}
+
+	var errs []error // only the first is returned
+	closedSinks := make(map[Sink]bool)
+	for _, sink := range allSinks {
+		if closedSinks[sink] {
+			continue
+		}
+		closedSinks[sink] = true
+
+		if closer, ok := sink.(SinkCloser); ok {
+			if err := closer.Close(ctx); err != nil {
+				errs = append(errs, errors.Wrap(err, "sink"))
+			}
+		}
+	}
+
+	closedOutputs := make(map[Output]bool)
+	for _, output := range allOutputs {
+		if output == nil || closedOutputs[output] {
+			continue
+		}
+		closedOutputs[output] = true
+
+		if closer, ok := output.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, errors.Wrap(err, "output"))
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[0]
+}
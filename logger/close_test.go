@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testSinkCloser struct {
+	entries []*Entry
+	queued  []*Entry
+	closed  int
+
+	lock sync.Mutex
+}
+
+func (s *testSinkCloser) WriteEntry(entry *Entry) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.queued = append(s.queued, entry)
+}
+
+func (s *testSinkCloser) Close(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entries = append(s.entries, s.queued...)
+	s.queued = nil
+	s.closed++
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath := filepath.Join(dir, "main.log")
+	config := NewConfig(false, false, filePath)
+	sink := &testSinkCloser{}
+	config.AddSink(sink)
+	config.SubSystems["sub"] = config.Main.Copy()
+
+	ctx := ContextWithLogConfig(context.Background(), config)
+	Info(ctx, "Shutting down")
+
+	if err := Close(ctx); err != nil {
+		t.Fatalf("Failed to close : %s", err)
+	}
+
+	if sink.closed != 1 {
+		t.Fatalf("Wrong sink close count : got %d, want %d", sink.closed, 1)
+	}
+	if len(sink.entries) != 1 || sink.entries[0].Message != "Shutting down" {
+		t.Fatalf("Queued entries not flushed : %+v", sink.entries)
+	}
+
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Failed to read log file : %s", err)
+	}
+	if !strings.Contains(string(b), "Shutting down") {
+		t.Fatalf("Missing entry in log file : %s", string(b))
+	}
+}
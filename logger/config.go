@@ -6,6 +6,8 @@ type Config struct {
 	Active             systemConfig
 	IncludedSubSystems map[string]bool         // If true, log in main log
 	SubSystems         map[string]systemConfig // SubSystem specific loggers
+
+	buffer *logBuffer // Holds entries below the minimum level until an error
 }
 
 func (c Config) Copy() Config {
@@ -50,3 +52,15 @@ func NewEmptyConfig() Config {
 func (config *Config) EnableSubSystem(subsystem string) {
 	config.IncludedSubSystems[subsystem] = true
 }
+
+// SetMinLevel sets the minimum level of entries written by the main log and subsystem logs of the
+// config. It must be called before the config is attached to a context.
+func (config *Config) SetMinLevel(level Level) {
+	config.Main.minLevel = level
+	config.Active.minLevel = level
+	for name := range config.SubSystems {
+		subConfig := config.SubSystems[name].Copy()
+		subConfig.minLevel = level
+		config.SubSystems[name] = subConfig
+	}
+}
@@ -29,6 +29,9 @@ import (
 // // Attach the log config to the context.
 // ctx := logger.ContextWithLogConfig(context.Background(), logConfig)
 //
+// // Flush sinks and close log files after everything else has stopped.
+// defer logger.Close(ctx)
+//
 
 // Keys for context key/pairs
 type loggerkey int
@@ -241,12 +244,14 @@ func LogDepth(ctx context.Context, level Level, caller string, format string,
 	values ...interface{}) error {
 
 	var config *systemConfig
+	var buffer *logBuffer
 
 	configValue := ctx.Value(key)
 	if configValue != nil {
 		contextConfig, ok := configValue.(Config)
 		if ok {
 			config = &contextConfig.Active
+			buffer = contextConfig.buffer
 		}
 	}
 
@@ -258,6 +263,10 @@ func LogDepth(ctx context.Context, level Level, caller string, format string,
 		config = &newConfig
 	}
 
+	if buffer != nil {
+		return buffer.writeEntry(config, level, caller, nil, format, values...)
+	}
+
 	return config.writeEntry(level, caller, nil, format, values...)
 }
 
@@ -316,12 +325,14 @@ func LogDepthWithFields(ctx context.Context, level Level, caller string, fields
 	format string, values ...interface{}) error {
 
 	var config *systemConfig
+	var buffer *logBuffer
 
 	configValue := ctx.Value(key)
 	if configValue != nil {
 		contextConfig, ok := configValue.(Config)
 		if ok {
 			config = &contextConfig.Active
+			buffer = contextConfig.buffer
 		}
 	}
 
@@ -333,5 +344,9 @@ func LogDepthWithFields(ctx context.Context, level Level, caller string, fields
 		config = &newConfig
 	}
 
+	if buffer != nil {
+		return buffer.writeEntry(config, level, caller, fields, format, values...)
+	}
+
 	return config.writeEntry(level, caller, fields, format, values...)
 }
@@ -194,3 +194,30 @@ func BenchmarkDummyWithFields(b *testing.B) {
 		}, "Simple log entry with fields")
 	}
 }
+
+func TestContextFieldsNotShared(t *testing.T) {
+	ctx := ContextWithLogConfig(context.Background(), NewConfig(false, false, "dummy"))
+	ctx = ContextWithLogFields(ctx, String("a", "parent"), String("b", "parent"))
+
+	first := ContextWithLogFields(ctx, String("b", "first"))
+	second := ContextWithLogFields(ctx, String("b", "second"))
+	ContextWithLogFields(first, String("c", "third"))
+
+	for _, tt := range []struct {
+		ctx  context.Context
+		want string
+	}{
+		{ctx, "parent"},
+		{first, "first"},
+		{second, "second"},
+	} {
+		fields := tt.ctx.Value(key).(Config).Active.fields
+		if len(fields) != 2 {
+			t.Fatalf("Wrong field count : got %d, want %d", len(fields), 2)
+		}
+
+		if fields[1].ValueJSON() != fmt.Sprintf("%q", tt.want) {
+			t.Fatalf("Wrong field value : got %s, want %q", fields[1].ValueJSON(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package loggertest provides helpers for tests that check what was logged.
+package loggertest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/logger"
+)
+
+// Capture records log entries in memory so tests can check that specific entries were logged.
+type Capture struct {
+	entries []logger.Entry
+
+	sync.Mutex
+}
+
+// Matcher returns true if an entry matches a condition.
+type Matcher func(entry logger.Entry) bool
+
+// NewCapture creates an empty capture.
+func NewCapture() *Capture {
+	return &Capture{}
+}
+
+// CaptureConfig returns a logger config that records entries of all levels in the capture and
+// doesn't write any other output. Entries from subsystems are only logged when the subsystem is
+// enabled, so the subsystems to capture must be specified.
+func CaptureConfig(capture *Capture, subsystems ...string) logger.Config {
+	config := logger.NewEmptyConfig()
+	config.SetMinLevel(logger.LevelDebug)
+	config.AddSink(capture)
+	for _, subsystem := range subsystems {
+		config.EnableSubSystem(subsystem)
+	}
+	return config
+}
+
+// ContextWithCapture returns a context with a capture config attached and the capture that
+// records its entries.
+func ContextWithCapture(ctx context.Context, subsystems ...string) (context.Context, *Capture) {
+	capture := NewCapture()
+	return logger.ContextWithLogConfig(ctx, CaptureConfig(capture, subsystems...)), capture
+}
+
+// WriteEntry implements logger.Sink.
+func (c *Capture) WriteEntry(entry *logger.Entry) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries = append(c.entries, *entry)
+}
+
+// Entries returns a copy of all entries in the order they were logged.
+func (c *Capture) Entries() []logger.Entry {
+	c.Lock()
+	defer c.Unlock()
+
+	result := make([]logger.Entry, len(c.entries))
+	copy(result, c.entries)
+	return result
+}
+
+// Find returns the entries that match all of the matchers.
+func (c *Capture) Find(matchers ...Matcher) []logger.Entry {
+	c.Lock()
+	defer c.Unlock()
+
+	var result []logger.Entry
+	for _, entry := range c.entries {
+		if matchesAll(entry, matchers) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// Contains returns true if an entry matches all of the matchers.
+func (c *Capture) Contains(matchers ...Matcher) bool {
+	return c.Count(matchers...) > 0
+}
+
+// Count returns the number of entries that match all of the matchers.
+func (c *Capture) Count(matchers ...Matcher) int {
+	return len(c.Find(matchers...))
+}
+
+// Reset removes all entries.
+func (c *Capture) Reset() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries = nil
+}
+
+// AtLevel matches entries with the level.
+func AtLevel(level logger.Level) Matcher {
+	return func(entry logger.Entry) bool {
+		return entry.Level == level
+	}
+}
+
+// AtOrAboveLevel matches entries with the level or a more severe level.
+func AtOrAboveLevel(level logger.Level) Matcher {
+	return func(entry logger.Entry) bool {
+		return entry.Level >= level
+	}
+}
+
+// InSubSystem matches entries from the subsystem. An empty name matches entries from the main log.
+func InSubSystem(subsystem string) Matcher {
+	return func(entry logger.Entry) bool {
+		return entry.SubSystem == subsystem
+	}
+}
+
+// MessageContains matches entries with messages that contain the text.
+func MessageContains(text string) Matcher {
+	return func(entry logger.Entry) bool {
+		return strings.Contains(entry.Message, text)
+	}
+}
+
+// HasField matches entries with a field with the name.
+func HasField(name string) Matcher {
+	return func(entry logger.Entry) bool {
+		return findField(entry, name) != nil
+	}
+}
+
+// FieldEquals matches entries with a field with the name that has the same JSON value as value.
+// For example FieldEquals("count", 3) matches logger.Int("count", 3).
+func FieldEquals(name string, value interface{}) Matcher {
+	want, err := json.Marshal(value)
+	return func(entry logger.Entry) bool {
+		field := findField(entry, name)
+		if field == nil || err != nil {
+			return false
+		}
+
+		return jsonEqual(field.ValueJSON(), string(want))
+	}
+}
+
+func findField(entry logger.Entry, name string) logger.Field {
+	for _, field := range entry.Fields {
+		if field.Name() == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// jsonEqual returns true if the JSON values are equal after decoding, so differences in escaping
+// and spacing are ignored.
+func jsonEqual(l, r string) bool {
+	if l == r {
+		return true
+	}
+
+	var lValue, rValue interface{}
+	if err := json.Unmarshal([]byte(l), &lValue); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(r), &rValue); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(lValue, rValue)
+}
+
+func matchesAll(entry logger.Entry, matchers []Matcher) bool {
+	for _, matcher := range matchers {
+		if !matcher(entry) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package loggertest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tokenized/pkg/logger"
+)
+
+func TestCapture(t *testing.T) {
+	ctx, capture := ContextWithCapture(context.Background(), "spynode")
+
+	logger.Debug(ctx, "Debug entry")
+	logger.Info(ctx, "Started")
+	logger.WarnWithFields(ctx, []logger.Field{
+		logger.String("txid", "abc123"),
+		logger.Int("count", 3),
+	}, "Failed to send : %s", "timeout")
+
+	subCtx := logger.ContextWithLogSubSystem(ctx, "spynode")
+	logger.Error(subCtx, "Subsystem failure")
+
+	otherCtx := logger.ContextWithLogSubSystem(ctx, "other")
+	logger.Error(otherCtx, "Not captured")
+
+	if count := len(capture.Entries()); count != 4 {
+		t.Fatalf("Wrong entry count : got %d, want %d", count, 4)
+	}
+
+	if !capture.Contains(AtLevel(logger.LevelDebug), MessageContains("Debug")) {
+		t.Errorf("Missing debug entry")
+	}
+
+	warnings := capture.Find(AtLevel(logger.LevelWarn))
+	if len(warnings) != 1 {
+		t.Fatalf("Wrong warning count : got %d, want %d", len(warnings), 1)
+	}
+	if warnings[0].Message != "Failed to send : timeout" {
+		t.Errorf("Wrong warning message : %s", warnings[0].Message)
+	}
+
+	if !capture.Contains(FieldEquals("txid", "abc123"), FieldEquals("count", 3)) {
+		t.Errorf("Missing entry with fields")
+	}
+	if capture.Contains(FieldEquals("count", 4)) {
+		t.Errorf("Entry should not match wrong field value")
+	}
+	if !capture.Contains(HasField("count")) {
+		t.Errorf("Missing entry with field")
+	}
+
+	if count := capture.Count(AtOrAboveLevel(logger.LevelWarn)); count != 2 {
+		t.Errorf("Wrong count at or above warning : got %d, want %d", count, 2)
+	}
+
+	subEntries := capture.Find(InSubSystem("spynode"))
+	if len(subEntries) != 1 || subEntries[0].Message != "Subsystem failure" {
+		t.Errorf("Wrong subsystem entries : %+v", subEntries)
+	}
+
+	if count := capture.Count(InSubSystem("")); count != 3 {
+		t.Errorf("Wrong main entry count : got %d, want %d", count, 3)
+	}
+
+	capture.Reset()
+	if count := len(capture.Entries()); count != 0 {
+		t.Fatalf("Wrong entry count after reset : got %d, want %d", count, 0)
+	}
+}
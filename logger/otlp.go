@@ -0,0 +1,396 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultOTLPLogsURL is the default logs endpoint of an OpenTelemetry collector using OTLP over
+	// HTTP.
+	DefaultOTLPLogsURL = "http://localhost:4318/v1/logs"
+)
+
+var (
+	ErrOTLPExport = errors.New("OTLP export failed")
+)
+
+// OTLPConfig configures an OTLPSink.
+type OTLPConfig struct {
+	// URL is the OTLP/HTTP logs endpoint. Defaults to DefaultOTLPLogsURL.
+	URL string
+
+	// ServiceName is exported as the "service.name" resource attribute.
+	ServiceName string
+
+	// Headers are added to each export request, for example for authentication.
+	Headers map[string]string
+
+	// BatchSize is the number of entries that triggers an export before the flush interval.
+	BatchSize int
+
+	// FlushInterval is the maximum time entries are held before being exported.
+	FlushInterval time.Duration
+
+	// MaxQueueSize is the maximum number of entries held. Entries are dropped when it is full,
+	// for example when the collector is unavailable.
+	MaxQueueSize int
+
+	Timeout time.Duration
+}
+
+// DefaultOTLPConfig returns an OTLP config with reasonable defaults.
+func DefaultOTLPConfig(serviceName string) OTLPConfig {
+	return OTLPConfig{
+		URL:           DefaultOTLPLogsURL,
+		ServiceName:   serviceName,
+		BatchSize:     500,
+		FlushInterval: 5 * time.Second,
+		MaxQueueSize:  10000,
+		Timeout:       10 * time.Second,
+	}
+}
+
+// OTLPSink is a Sink that exports log entries to an OpenTelemetry collector with the OTLP/HTTP
+// JSON encoding. Subsystems are exported as the instrumentation scope, fields as attributes, and
+// trace fields that are 32 hex characters as the trace id.
+//
+// Entries are queued and exported in batches by Run.
+//   sink := logger.NewOTLPSink(logger.DefaultOTLPConfig("my-service"))
+//   logConfig.AddSink(sink)
+//   go sink.Run(ctx, interrupt)
+type OTLPSink struct {
+	config OTLPConfig
+	client *http.Client
+
+	entries []*Entry
+	dropped uint64
+	flush   chan interface{}
+
+	sync.Mutex
+}
+
+// NewOTLPSink creates an OTLP sink. Run must be called to export entries.
+func NewOTLPSink(config OTLPConfig) *OTLPSink {
+	defaults := DefaultOTLPConfig(config.ServiceName)
+	if len(config.URL) == 0 {
+		config.URL = defaults.URL
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.MaxQueueSize <= 0 {
+		config.MaxQueueSize = defaults.MaxQueueSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	return &OTLPSink{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		flush:  make(chan interface{}, 1),
+	}
+}
+
+// WriteEntry queues the entry to be exported. It implements the Sink interface.
+func (s *OTLPSink) WriteEntry(entry *Entry) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.entries) >= s.config.MaxQueueSize {
+		s.dropped++
+		return
+	}
+
+	s.entries = append(s.entries, entry)
+	if len(s.entries) >= s.config.BatchSize {
+		select {
+		case s.flush <- nil:
+		default: // flush already requested
+		}
+	}
+}
+
+// Dropped returns the number of entries dropped because the queue was full.
+func (s *OTLPSink) Dropped() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.dropped
+}
+
+// Run exports queued entries every flush interval, or when a batch is full, until interrupt is
+// closed. Remaining entries are exported before it returns. Export failures don't stop it since
+// the collector might become available again, but the last one is returned.
+func (s *OTLPSink) Run(ctx context.Context, interrupt <-chan interface{}) error {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-interrupt:
+			if err := s.Flush(ctx); err != nil {
+				return err
+			}
+			return lastErr
+
+		case <-ticker.C:
+		case <-s.flush:
+		}
+
+		if err := s.Flush(ctx); err != nil {
+			lastErr = err
+		}
+	}
+}
+
+// Close exports all queued entries. It implements the SinkCloser interface so the entries queued
+// during shutdown are exported when the log config is closed.
+func (s *OTLPSink) Close(ctx context.Context) error {
+	return s.Flush(ctx)
+}
+
+// Flush exports all queued entries. Entries that fail to export are not retried.
+func (s *OTLPSink) Flush(ctx context.Context) error {
+	for {
+		s.Lock()
+		count := len(s.entries)
+		if count > s.config.BatchSize {
+			count = s.config.BatchSize
+		}
+		entries := s.entries[:count]
+		s.entries = s.entries[count:]
+		if len(s.entries) == 0 {
+			s.entries = nil
+		}
+		s.Unlock()
+
+		if len(entries) == 0 {
+			return nil
+		}
+
+		if err := s.export(ctx, entries); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *OTLPSink) export(ctx context.Context, entries []*Entry) error {
+	b, err := json.Marshal(newOTLPLogsRequest(s.config.ServiceName, entries))
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range s.config.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "post")
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.Wrapf(ErrOTLPExport, "HTTP %d %s", response.StatusCode,
+			http.StatusText(response.StatusCode))
+	}
+
+	return nil
+}
+
+// OTLP JSON encoding. See opentelemetry-proto/opentelemetry/proto/logs/v1/logs.proto.
+
+type otlpLogsRequest struct {
+	ResourceLogs []*otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource     `json:"resource"`
+	ScopeLogs []*otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []*otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope        `json:"scope"`
+	LogRecords []*otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string          `json:"timeUnixNano"`
+	SeverityNumber int             `json:"severityNumber"`
+	SeverityText   string          `json:"severityText"`
+	Body           otlpAnyValue    `json:"body"`
+	Attributes     []*otlpKeyValue `json:"attributes,omitempty"`
+	TraceID        string          `json:"traceId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func newOTLPLogsRequest(serviceName string, entries []*Entry) *otlpLogsRequest {
+	resourceLogs := &otlpResourceLogs{}
+	if len(serviceName) > 0 {
+		resourceLogs.Resource.Attributes = []*otlpKeyValue{
+			{Key: "service.name", Value: otlpString(serviceName)},
+		}
+	}
+
+	// Entries are grouped by subsystem, keeping the order of the first entry of each.
+	scopes := make(map[string]*otlpScopeLogs)
+	for _, entry := range entries {
+		scopeLogs, exists := scopes[entry.SubSystem]
+		if !exists {
+			scopeLogs = &otlpScopeLogs{Scope: otlpScope{Name: entry.SubSystem}}
+			scopes[entry.SubSystem] = scopeLogs
+			resourceLogs.ScopeLogs = append(resourceLogs.ScopeLogs, scopeLogs)
+		}
+
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, newOTLPLogRecord(entry))
+	}
+
+	return &otlpLogsRequest{ResourceLogs: []*otlpResourceLogs{resourceLogs}}
+}
+
+func newOTLPLogRecord(entry *Entry) *otlpLogRecord {
+	result := &otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(entry.Time.UnixNano(), 10),
+		SeverityNumber: otlpSeverity(entry.Level),
+		SeverityText:   otlpSeverityText(entry.Level),
+		Body:           otlpString(entry.Message),
+	}
+
+	if len(entry.Caller) > 0 {
+		result.Attributes = append(result.Attributes, &otlpKeyValue{
+			Key:   "code.filepath",
+			Value: otlpString(entry.Caller),
+		})
+	}
+
+	if len(entry.Trace) > 0 {
+		if b, err := hex.DecodeString(entry.Trace); err == nil && len(b) == 16 {
+			result.TraceID = entry.Trace
+		} else {
+			result.Attributes = append(result.Attributes, &otlpKeyValue{
+				Key:   "trace",
+				Value: otlpString(entry.Trace),
+			})
+		}
+	}
+
+	for _, field := range entry.Fields {
+		result.Attributes = append(result.Attributes, &otlpKeyValue{
+			Key:   field.Name(),
+			Value: otlpFieldValue(field),
+		})
+	}
+
+	return result
+}
+
+// otlpFieldValue converts the field's JSON value to an OTLP value. Objects, arrays, and null are
+// exported as their JSON text.
+func otlpFieldValue(field Field) otlpAnyValue {
+	text := field.ValueJSON()
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return otlpString(text)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			s := v.String()
+			return otlpAnyValue{IntValue: &s}
+		}
+		if f, err := v.Float64(); err == nil {
+			return otlpAnyValue{DoubleValue: &f}
+		}
+	}
+
+	return otlpString(text)
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpSeverity returns the OTLP severity number for the level.
+func otlpSeverity(level Level) int {
+	switch level {
+	case LevelDebug:
+		return 5 // DEBUG
+	case LevelVerbose:
+		return 6 // DEBUG2
+	case LevelInfo:
+		return 9 // INFO
+	case LevelWarn:
+		return 13 // WARN
+	case LevelError:
+		return 17 // ERROR
+	case LevelFatal:
+		return 21 // FATAL
+	case LevelPanic:
+		return 22 // FATAL2
+	default:
+		return 0 // UNSPECIFIED
+	}
+}
+
+func otlpSeverityText(level Level) string {
+	switch level {
+	case LevelVerbose:
+		return "DEBUG2"
+	case LevelPanic:
+		return "FATAL2"
+	}
+
+	return strings.ToUpper(level.String())
+}
@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/json"
+)
+
+func TestOTLPSink(t *testing.T) {
+	requests := make(chan *otlpLogsRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		request := &otlpLogsRequest{}
+		if err := json.Unmarshal(b, request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		requests <- request
+	}))
+	defer server.Close()
+
+	config := DefaultOTLPConfig("test-service")
+	config.URL = server.URL + "/v1/logs"
+	config.Headers = map[string]string{"Authorization": "Bearer token"}
+	config.BatchSize = 3
+	config.FlushInterval = time.Hour
+	sink := NewOTLPSink(config)
+
+	logConfig := NewConfig(false, false, "dummy")
+	logConfig.EnableSubSystem("spynode")
+	logConfig.AddSink(sink)
+	ctx := ContextWithLogConfig(context.Background(), logConfig)
+
+	interrupt := make(chan interface{})
+	complete := make(chan error, 1)
+	go func() {
+		complete <- sink.Run(ctx, interrupt)
+	}()
+
+	traceID := "0102030405060708090a0b0c0d0e0f10"
+	traceCtx := ContextWithLogTrace(ctx, traceID)
+	InfoWithFields(traceCtx, []Field{Int("count", 5), Bool("ok", true)}, "First entry")
+	Debug(ctx, "Below minimum level")
+	Warn(ContextWithLogSubSystem(ctx, "spynode"), "Sub system entry")
+	ErrorWithFields(ContextWithLogTrace(ctx, "not hex"), []Field{String("name", "value")},
+		"Third entry")
+
+	var request *otlpLogsRequest
+	select {
+	case request = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Batch not exported")
+	}
+
+	if len(request.ResourceLogs) != 1 {
+		t.Fatalf("Wrong resource logs count : got %d, want %d", len(request.ResourceLogs), 1)
+	}
+	resourceLogs := request.ResourceLogs[0]
+
+	attributes := resourceLogs.Resource.Attributes
+	if len(attributes) != 1 || attributes[0].Key != "service.name" ||
+		*attributes[0].Value.StringValue != "test-service" {
+		t.Fatalf("Wrong resource attributes : %+v", attributes)
+	}
+
+	if len(resourceLogs.ScopeLogs) != 2 {
+		t.Fatalf("Wrong scope count : got %d, want %d", len(resourceLogs.ScopeLogs), 2)
+	}
+
+	main := resourceLogs.ScopeLogs[0]
+	if main.Scope.Name != "" || len(main.LogRecords) != 2 {
+		t.Fatalf("Wrong main scope : %s, %d records", main.Scope.Name, len(main.LogRecords))
+	}
+
+	sub := resourceLogs.ScopeLogs[1]
+	if sub.Scope.Name != "spynode" || len(sub.LogRecords) != 1 {
+		t.Fatalf("Wrong sub system scope : %s, %d records", sub.Scope.Name, len(sub.LogRecords))
+	}
+
+	first := main.LogRecords[0]
+	if *first.Body.StringValue != "First entry" || first.SeverityNumber != 9 ||
+		first.SeverityText != "INFO" {
+		t.Fatalf("Wrong first record : %+v", first)
+	}
+
+	if first.TraceID != traceID {
+		t.Fatalf("Wrong trace id : got %s, want %s", first.TraceID, traceID)
+	}
+
+	values := make(map[string]otlpAnyValue)
+	for _, attribute := range first.Attributes {
+		values[attribute.Key] = attribute.Value
+	}
+
+	if v, exists := values["count"]; !exists || v.IntValue == nil || *v.IntValue != "5" {
+		t.Fatalf("Wrong count attribute : %+v", v)
+	}
+
+	if v, exists := values["ok"]; !exists || v.BoolValue == nil || !*v.BoolValue {
+		t.Fatalf("Wrong ok attribute : %+v", v)
+	}
+
+	if _, exists := values["code.filepath"]; !exists {
+		t.Fatalf("Missing caller attribute")
+	}
+
+	third := main.LogRecords[1]
+	if third.TraceID != "" || third.SeverityText != "ERROR" {
+		t.Fatalf("Wrong third record : %+v", third)
+	}
+
+	if sub.LogRecords[0].SeverityText != "WARN" {
+		t.Fatalf("Wrong sub system severity : %s", sub.LogRecords[0].SeverityText)
+	}
+
+	// Remaining entries are exported when stopped.
+	Info(ctx, "Last entry")
+	close(interrupt)
+
+	if err := <-complete; err != nil {
+		t.Fatalf("Failed to run sink : %s", err)
+	}
+
+	select {
+	case request = <-requests:
+		records := request.ResourceLogs[0].ScopeLogs[0].LogRecords
+		if len(records) != 1 || *records[0].Body.StringValue != "Last entry" {
+			t.Fatalf("Wrong final records : %+v", records)
+		}
+	default:
+		t.Fatalf("Final entries not exported")
+	}
+}
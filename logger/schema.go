@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"strconv"
+	"time"
+)
+
+// TimeFormat specifies how the timestamp of a log entry is written.
+type TimeFormat int
+
+const (
+	// TimeFormatUnixMicro is unix seconds with microseconds: 1600000000.123456
+	TimeFormatUnixMicro TimeFormat = iota
+
+	// TimeFormatUnixMilli is unix seconds with milliseconds: 1600000000.123
+	TimeFormatUnixMilli
+
+	// TimeFormatUnixNano is unix seconds with nanoseconds: 1600000000.123456789
+	TimeFormatUnixNano
+
+	// TimeFormatUnix is whole unix seconds: 1600000000
+	TimeFormatUnix
+
+	// TimeFormatEpochMillis is a whole number of milliseconds since the unix epoch: 1600000000123
+	TimeFormatEpochMillis
+
+	// TimeFormatLayout is a string formatted with the schema's TimeLayout, like time.RFC3339Nano.
+	TimeFormatLayout
+)
+
+// Schema defines the key names and timestamp format of JSON log entries. Empty key names use the
+// default names so the zero value is the default schema.
+type Schema struct {
+	TimeStampKey string // default "ts"
+	LevelKey     string // default "level"
+	MessageKey   string // default "msg"
+	CallerKey    string // default "caller"
+	SubSystemKey string // default "subsystem"
+	TraceKey     string // default "trace"
+
+	TimeFormat TimeFormat
+	TimeLayout string // used with TimeFormatLayout, default time.RFC3339Nano
+	UTC        bool   // convert timestamps to UTC before formatting with a layout
+}
+
+// SetSchema sets the schema of the main log and subsystem logs of the config. It must be called
+// before the config is attached to a context.
+func (config *Config) SetSchema(schema Schema) {
+	config.Main.schema = schema
+	config.Active.schema = schema
+	for name := range config.SubSystems {
+		subConfig := config.SubSystems[name].Copy()
+		subConfig.schema = schema
+		config.SubSystems[name] = subConfig
+	}
+}
+
+func (s Schema) timeStampKey() string {
+	return keyOrDefault(s.TimeStampKey, "ts")
+}
+
+func (s Schema) levelKey() string {
+	return keyOrDefault(s.LevelKey, "level")
+}
+
+func (s Schema) messageKey() string {
+	return keyOrDefault(s.MessageKey, "msg")
+}
+
+func (s Schema) callerKey() string {
+	return keyOrDefault(s.CallerKey, "caller")
+}
+
+// fieldKey returns the key that a field is written with. The subsystem and trace fields are
+// renamed by the schema.
+func (s Schema) fieldKey(name string) string {
+	switch name {
+	case "subsystem":
+		return keyOrDefault(s.SubSystemKey, name)
+	case "trace":
+		return keyOrDefault(s.TraceKey, name)
+	}
+	return name
+}
+
+// timeStampJSON returns the timestamp formatted as a JSON value.
+func (s Schema) timeStampJSON(t time.Time) string {
+	switch s.TimeFormat {
+	case TimeFormatUnixMilli:
+		return strconv.FormatInt(t.Unix(), 10) + "." + zeroPad(t.Nanosecond()/1e6, 3)
+	case TimeFormatUnixNano:
+		return strconv.FormatInt(t.Unix(), 10) + "." + zeroPad(t.Nanosecond(), 9)
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeFormatEpochMillis:
+		return strconv.FormatInt(t.UnixNano()/1e6, 10)
+	case TimeFormatLayout:
+		return strconv.Quote(s.timeStampText(t))
+	default:
+		return strconv.FormatInt(t.Unix(), 10) + "." + zeroPad(t.Nanosecond()/1e3, 6)
+	}
+}
+
+// timeStampText returns the timestamp formatted for text log entries.
+func (s Schema) timeStampText(t time.Time) string {
+	if s.TimeFormat != TimeFormatLayout {
+		return s.timeStampJSON(t)
+	}
+
+	if s.UTC {
+		t = t.UTC()
+	}
+
+	layout := s.TimeLayout
+	if len(layout) == 0 {
+		layout = time.RFC3339Nano
+	}
+	return t.Format(layout)
+}
+
+func keyOrDefault(key, defaultKey string) string {
+	if len(key) == 0 {
+		return defaultKey
+	}
+	return key
+}
+
+func zeroPad(value, width int) string {
+	s := strconv.Itoa(value)
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSchema(t *testing.T) {
+	output := &testOutput{}
+	logConfig := NewConfig(false, false, "")
+	logConfig.Active.output = output
+	logConfig.SetSchema(Schema{
+		TimeStampKey: "@timestamp",
+		LevelKey:     "severity",
+		MessageKey:   "message",
+		CallerKey:    "source",
+		SubSystemKey: "component",
+		TraceKey:     "trace_id",
+		TimeFormat:   TimeFormatLayout,
+		TimeLayout:   time.RFC3339Nano,
+		UTC:          true,
+	})
+
+	ctx := ContextWithLogConfig(context.Background(), logConfig)
+	ctx = ContextWithLogTrace(ctx, "abc")
+	Info(ctx, "Schema entry")
+
+	lines := output.lines()
+	if len(lines) != 1 {
+		t.Fatalf("Wrong line count : got %d, want %d", len(lines), 1)
+	}
+
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Failed to unmarshal entry : %s : %s", err, lines[0])
+	}
+
+	if entry["severity"] != "info" {
+		t.Fatalf("Wrong severity : %v", entry["severity"])
+	}
+	if entry["message"] != "Schema entry" {
+		t.Fatalf("Wrong message : %v", entry["message"])
+	}
+	if entry["trace_id"] != "abc" {
+		t.Fatalf("Wrong trace : %v", entry["trace_id"])
+	}
+	if _, exists := entry["source"]; !exists {
+		t.Fatalf("Missing source")
+	}
+
+	ts, ok := entry["@timestamp"].(string)
+	if !ok {
+		t.Fatalf("Missing timestamp : %v", entry["@timestamp"])
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		t.Fatalf("Failed to parse timestamp : %s", err)
+	}
+	if time.Since(parsed) > time.Minute {
+		t.Fatalf("Wrong timestamp : %s", ts)
+	}
+
+	for _, key := range []string{"ts", "level", "msg", "caller", "trace"} {
+		if _, exists := entry[key]; exists {
+			t.Fatalf("Default key %s should be renamed", key)
+		}
+	}
+}
+
+func TestSchemaTimeFormats(t *testing.T) {
+	now := time.Unix(1600000000, 123456789)
+
+	tests := []struct {
+		format TimeFormat
+		want   string
+	}{
+		{TimeFormatUnixMicro, "1600000000.123456"},
+		{TimeFormatUnixMilli, "1600000000.123"},
+		{TimeFormatUnixNano, "1600000000.123456789"},
+		{TimeFormatUnix, "1600000000"},
+		{TimeFormatEpochMillis, "1600000000123"},
+	}
+
+	for _, tt := range tests {
+		got := Schema{TimeFormat: tt.format}.timeStampJSON(now)
+		if got != tt.want {
+			t.Errorf("Wrong timestamp for format %d : got %s, want %s", tt.format, got, tt.want)
+		}
+	}
+
+	got := Schema{
+		TimeFormat: TimeFormatLayout,
+		TimeLayout: "2006-01-02T15:04:05.000Z07:00",
+		UTC:        true,
+	}.timeStampJSON(now)
+	if want := `"2020-09-13T12:26:40.123Z"`; got != want {
+		t.Errorf("Wrong layout timestamp : got %s, want %s", got, want)
+	}
+}
+
+func TestSchemaSubSystem(t *testing.T) {
+	for _, schema := range []Schema{{}, {SubSystemKey: "component"}} {
+		output := &testOutput{}
+		logConfig := NewConfig(false, false, "")
+		logConfig.Main.output = output
+		logConfig.SetSchema(schema)
+		logConfig.EnableSubSystem("sub")
+
+		ctx := ContextWithLogConfig(context.Background(), logConfig)
+		ctx = ContextWithLogSubSystem(ctx, "sub")
+		Info(ctx, "Sub system entry")
+
+		lines := output.lines()
+		if len(lines) != 1 {
+			t.Fatalf("Wrong line count : got %d, want %d", len(lines), 1)
+		}
+
+		entry := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+			t.Fatalf("Failed to unmarshal entry : %s : %s", err, lines[0])
+		}
+
+		subSystemKey := "subsystem"
+		if len(schema.SubSystemKey) > 0 {
+			subSystemKey = schema.SubSystemKey
+		}
+		if entry[subSystemKey] != "sub" {
+			t.Fatalf("Wrong sub system : %s", lines[0])
+		}
+
+		for _, key := range []string{"ts", "level", "msg", "caller"} {
+			if _, exists := entry[key]; !exists {
+				t.Fatalf("Missing default key %s : %s", key, lines[0])
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// Entry is a log entry passed to sinks.
+type Entry struct {
+	Time      time.Time
+	Level     Level
+	Caller    string
+	Message   string
+	SubSystem string
+	Trace     string
+
+	// Fields contains the fields attached to the context followed by the fields of the entry,
+	// without duplicate names and without the subsystem and trace fields.
+	Fields []Field
+}
+
+// Sink receives structured log entries in addition to the formatted output. Sinks are called
+// synchronously for each entry at or above the minimum level, so they should only queue the
+// entry and do any slow processing separately.
+type Sink interface {
+	WriteEntry(entry *Entry)
+}
+
+// AddSink adds a sink to the main log and subsystem logs of the config. It must be called before
+// the config is attached to a context.
+func (config *Config) AddSink(sink Sink) {
+	config.Main.sinks = append(config.Main.sinks, sink)
+	config.Active.sinks = append(config.Active.sinks, sink)
+	for name, subConfig := range config.SubSystems {
+		subConfig.sinks = append(subConfig.sinks, sink)
+		config.SubSystems[name] = subConfig
+	}
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	index := int(l) + levelOffset
+	if index < 0 || index >= len(levelName) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelName[index]
+}
+
+// writeSinks passes the entry, logged at the time now, to the config's sinks.
+func (config *systemConfig) writeSinks(now time.Time, level Level, caller string, fields []Field,
+	format string, values ...interface{}) {
+
+	entry := &Entry{
+		Time:    now,
+		Level:   level,
+		Caller:  caller,
+		Message: fmt.Sprintf(format, values...),
+	}
+
+	allFields := make([]Field, 0, len(config.fields)+len(fields))
+	allFields = append(allFields, config.fields...)
+	allFields = append(allFields, fields...)
+
+	for i, field := range allFields {
+		if fieldExists(field.Name(), allFields[:i]) {
+			continue // skip duplicate field name
+		}
+
+		switch field.Name() {
+		case "subsystem":
+			entry.SubSystem = fieldString(field)
+		case "trace":
+			entry.Trace = fieldString(field)
+		default:
+			entry.Fields = append(entry.Fields, field)
+		}
+	}
+
+	for _, sink := range config.sinks {
+		sink.WriteEntry(entry)
+	}
+}
+
+// fieldString returns the value of a string field, or the JSON of other fields.
+func fieldString(field Field) string {
+	if s, ok := field.(*StringField); ok {
+		return s.value
+	}
+	return field.ValueJSON()
+}
@@ -43,21 +43,17 @@ type systemConfig struct {
 	output     Output
 	fields     []Field
 	format     int
+	sinks      []Sink
+	schema     Schema
 
 	first bool
-
-	lock sync.Mutex
 }
 
 // Copy makes a separate copy so if the fields are modified in one copy they will not be in another.
 func (config systemConfig) Copy() systemConfig {
 	result := config
-
-	config.lock.Lock()
 	result.fields = make([]Field, len(config.fields))
 	copy(result.fields, config.fields)
-	config.lock.Unlock()
-
 	return result
 }
 
@@ -105,56 +101,36 @@ func newEmptySystemConfig() (systemConfig, error) {
 	return systemConfig{}, nil
 }
 
-// addField adds a field to the log outputs
+// addField adds a field to the log outputs. The fields are replaced instead of modified because
+// copies of the config, like those attached to other contexts, share them.
 func (config *systemConfig) addField(newField Field) {
-	config.lock.Lock()
-	defer config.lock.Unlock()
+	fields := make([]Field, len(config.fields), len(config.fields)+1)
+	copy(fields, config.fields)
 
-	for i, field := range config.fields {
+	for i, field := range fields {
 		if field.Name() == newField.Name() {
 			// Insert new field in same location as previous field with same name.
-			var prev []Field
-			if i > 0 {
-				prev = config.fields[:i]
-			}
-
-			var after []Field
-			if i+1 < len(config.fields) {
-				after = config.fields[i+1:]
-			}
-
-			config.fields = append(prev, newField)
-			config.fields = append(config.fields, after...)
+			fields[i] = newField
+			config.fields = fields
 			return
 		}
 	}
 
-	config.fields = append(config.fields, newField)
+	config.fields = append(fields, newField)
 }
 
 // addSubSystem adds a subsystem to the log outputs
 func (config *systemConfig) addSubSystem(name string) {
-	config.lock.Lock()
-	defer config.lock.Unlock()
-
-	for i, field := range config.fields {
-		if field.Name() == "subsystem" {
-			config.fields[i] = String("subsystem", name)
-			return
-		}
-	}
-
-	config.fields = append(config.fields, String("subsystem", name))
+	config.addField(String("subsystem", name))
 }
 
 // removeSubSystem removes the subsystem from the log outputs
 func (config *systemConfig) removeSubSystem() {
-	config.lock.Lock()
-	defer config.lock.Unlock()
-
 	for i, field := range config.fields {
 		if field.Name() == "subsystem" {
-			config.fields = append(config.fields[:i], config.fields[i+1:]...)
+			fields := make([]Field, 0, len(config.fields)-1)
+			fields = append(fields, config.fields[:i]...)
+			config.fields = append(fields, config.fields[i+1:]...)
 			return
 		}
 	}
@@ -175,6 +151,10 @@ func (config *systemConfig) writeField(format string, values ...interface{}) {
 func (config *systemConfig) writeEntry(level Level, caller string, fields []Field,
 	format string, values ...interface{}) error {
 
+	if len(config.sinks) > 0 && config.minLevel <= level {
+		config.writeSinks(time.Now(), level, caller, fields, format, values...)
+	}
+
 	if config.isText {
 		return config.writeTextEntry(level, caller, fields, format, values...)
 	}
@@ -201,7 +181,8 @@ func (config *systemConfig) writeJSONEntry(level Level, caller string, fields []
 
 	// Write Level
 	if config.format&IncludeLevel != 0 {
-		config.writeField("\"level\":\"%s\"", levelName[level+levelOffset])
+		config.writeField("%s:\"%s\"", strconv.Quote(config.schema.levelKey()),
+			levelName[level+levelOffset])
 	}
 
 	// Create log entry
@@ -209,7 +190,8 @@ func (config *systemConfig) writeJSONEntry(level Level, caller string, fields []
 
 	// Append timestamp
 	if config.format&IncludeTimeStamp != 0 {
-		config.writeField("\"ts\":%d.%06d", now.Unix(), now.Nanosecond()/1e3)
+		config.writeField("%s:%s", strconv.Quote(config.schema.timeStampKey()),
+			config.schema.timeStampJSON(now))
 	}
 
 	// Append Date
@@ -245,26 +227,25 @@ func (config *systemConfig) writeJSONEntry(level Level, caller string, fields []
 
 	// Append Caller
 	if config.format&IncludeCaller != 0 {
-		config.writeField("\"caller\":%s", strconv.Quote(caller))
+		config.writeField("%s:%s", strconv.Quote(config.schema.callerKey()), strconv.Quote(caller))
 	}
 
 	// Append actual log entry
-	config.writeField("\"msg\":%s", strconv.Quote(fmt.Sprintf(format, values...)))
+	config.writeField("%s:%s", strconv.Quote(config.schema.messageKey()),
+		strconv.Quote(fmt.Sprintf(format, values...)))
 
-	config.lock.Lock()
 	for i, field := range config.fields {
 		if fieldExists(field.Name(), config.fields[:i]) {
 			continue // skip duplicate field name
 		}
-		config.writeField("\"%s\":%s", field.Name(), field.ValueJSON())
+		config.writeField("\"%s\":%s", config.schema.fieldKey(field.Name()), field.ValueJSON())
 	}
-	config.lock.Unlock()
 
 	for i, field := range fields {
 		if fieldExists(field.Name(), config.fields) || fieldExists(field.Name(), fields[:i]) {
 			continue // skip duplicate field name
 		}
-		config.writeField("\"%s\":%s", field.Name(), field.ValueJSON())
+		config.writeField("\"%s\":%s", config.schema.fieldKey(field.Name()), field.ValueJSON())
 	}
 
 	config.output.Write(closeCurlyNewLine)
@@ -306,7 +287,7 @@ func (config *systemConfig) writeTextEntry(level Level, caller string, fields []
 
 	// Append timestamp
 	if config.format&IncludeTimeStamp != 0 {
-		config.writeField("ts %d.%06d", now.Unix(), now.Nanosecond()/1e3)
+		config.writeField("%s %s", config.schema.timeStampKey(), config.schema.timeStampText(now))
 	}
 
 	// Append Date
@@ -340,20 +321,20 @@ func (config *systemConfig) writeTextEntry(level Level, caller string, fields []
 	// Append actual log entry
 	config.writeField("%s", fmt.Sprintf(format, values...))
 
-	config.lock.Lock()
 	for i, field := range config.fields {
 		if fieldExists(field.Name(), config.fields[:i]) {
 			continue // skip duplicate field name
 		}
-		fmt.Fprintf(config.output, ", %s: %s", field.Name(), field.ValueJSON())
+		fmt.Fprintf(config.output, ", %s: %s", config.schema.fieldKey(field.Name()),
+			field.ValueJSON())
 	}
-	config.lock.Unlock()
 
 	for i, field := range fields {
 		if fieldExists(field.Name(), config.fields) || fieldExists(field.Name(), fields[:i]) {
 			continue // skip duplicate field name
 		}
-		fmt.Fprintf(config.output, ", %s: %s", field.Name(), field.ValueJSON())
+		fmt.Fprintf(config.output, ", %s: %s", config.schema.fieldKey(field.Name()),
+			field.ValueJSON())
 	}
 
 	config.output.Write(newLine)
@@ -402,6 +383,14 @@ func (w *fileWriter) Unlock() {
 	w.lock.Unlock()
 }
 
+// Close closes the file after pending writes are complete.
+func (w *fileWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.file.Close()
+}
+
 type printer struct {
 	lock sync.Mutex
 }
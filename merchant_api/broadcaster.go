@@ -0,0 +1,281 @@
+package merchant_api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/json_envelope"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// BroadcastFirstSuccess returns as soon as one miner accepts the tx. Submits to the other
+	// miners are not cancelled.
+	BroadcastFirstSuccess = BroadcastPolicy(0)
+
+	// BroadcastQuorum returns as soon as the quorum of miners accept the tx.
+	BroadcastQuorum = BroadcastPolicy(1)
+
+	// BroadcastAll waits for every miner to respond.
+	BroadcastAll = BroadcastPolicy(2)
+
+	// DefaultFeeQuoteDuration is how long a fee quote is cached when it doesn't have an expiry.
+	DefaultFeeQuoteDuration = 10 * time.Minute
+)
+
+var (
+	ErrNoMiners           = errors.New("No Miners")
+	ErrNotAccepted        = errors.New("Not Accepted")
+	ErrBroadcastCancelled = errors.New("Broadcast Cancelled")
+	ErrBroadcastPending   = errors.New("Broadcast Pending")
+)
+
+// BroadcastPolicy specifies when a broadcast is complete.
+type BroadcastPolicy uint8
+
+// BroadcasterConfig configures a broadcaster.
+type BroadcasterConfig struct {
+	Policy BroadcastPolicy
+
+	// Quorum is the number of miners that must accept a tx for the BroadcastQuorum policy.
+	Quorum int
+
+	// RetryCount is the number of times a submit is retried after a transient failure.
+	RetryCount int
+
+	// RetryDelay is the delay before a submit is retried.
+	RetryDelay time.Duration
+}
+
+// Broadcaster submits txs to multiple miners concurrently.
+type Broadcaster struct {
+	clients []*Client
+	config  BroadcasterConfig
+
+	feeQuotes map[string]*cachedFeeQuote
+	feeLock   sync.Mutex
+}
+
+type cachedFeeQuote struct {
+	response *FeeQuoteResponse
+	expiry   time.Time
+}
+
+// MinerResult is the result of submitting a tx to one miner. FeeQuote is the miner's cached fee
+// quote, or nil if the miner didn't provide a valid fee quote.
+type MinerResult struct {
+	BaseURL  string
+	FeeQuote *FeeQuoteResponse
+	Response *SubmitTxResponse
+	Err      error // nil when the tx was accepted
+}
+
+// BroadcastResult contains the result of submitting a tx to each miner, in the same order as the
+// broadcaster's clients. Miners that hadn't responded when the broadcast completed have an error
+// of ErrBroadcastPending.
+type BroadcastResult struct {
+	Results []MinerResult
+}
+
+// NewBroadcaster creates a broadcaster that submits to the miners of the clients.
+func NewBroadcaster(config BroadcasterConfig, clients ...*Client) *Broadcaster {
+	return &Broadcaster{
+		clients:   clients,
+		config:    config,
+		feeQuotes: make(map[string]*cachedFeeQuote),
+	}
+}
+
+// Accepted returns the base URLs of the miners that accepted the tx.
+func (r BroadcastResult) Accepted() []string {
+	var result []string
+	for _, minerResult := range r.Results {
+		if minerResult.Err == nil {
+			result = append(result, minerResult.BaseURL)
+		}
+	}
+	return result
+}
+
+// FeeQuotes returns the fee quote of each miner, in the same order as the broadcaster's clients.
+// Fee quotes are cached until they expire. The fee quote is nil for miners that didn't provide a
+// valid fee quote.
+func (b *Broadcaster) FeeQuotes(ctx context.Context) []*FeeQuoteResponse {
+	result := make([]*FeeQuoteResponse, len(b.clients))
+
+	var wait sync.WaitGroup
+	for i, client := range b.clients {
+		wait.Add(1)
+		go func(i int, client *Client) {
+			quote, err := b.feeQuote(ctx, client)
+			if err == nil {
+				result[i] = quote
+			}
+			wait.Done()
+		}(i, client)
+	}
+	wait.Wait()
+
+	return result
+}
+
+func (b *Broadcaster) feeQuote(ctx context.Context, client *Client) (*FeeQuoteResponse, error) {
+	now := time.Now()
+
+	b.feeLock.Lock()
+	cached, exists := b.feeQuotes[client.BaseURL]
+	b.feeLock.Unlock()
+	if exists && now.Before(cached.expiry) {
+		return cached.response, nil
+	}
+
+	quote, err := client.GetFeeQuote(ctx)
+	if err := acceptUnsigned(client, err); err != nil {
+		return nil, err
+	}
+
+	b.feeLock.Lock()
+	b.feeQuotes[client.BaseURL] = newCachedFeeQuote(quote, now)
+	b.feeLock.Unlock()
+
+	return quote, nil
+}
+
+// Broadcast submits the tx to all miners concurrently and returns when the broadcaster's policy is
+// met. ErrNotAccepted is returned with the result when the policy isn't met.
+//
+// Submits to miners that haven't responded when the policy is met continue in the background
+// until they complete or ctx is cancelled, so the tx still reaches every miner. Their results are
+// not reported.
+func (b *Broadcaster) Broadcast(ctx context.Context,
+	request SubmitTxRequest) (*BroadcastResult, error) {
+
+	if len(b.clients) == 0 {
+		return nil, ErrNoMiners
+	}
+
+	required := 1
+	switch b.config.Policy {
+	case BroadcastQuorum:
+		required = b.config.Quorum
+		if required < 1 {
+			required = 1
+		}
+		if required > len(b.clients) {
+			required = len(b.clients)
+		}
+	case BroadcastAll:
+		required = len(b.clients)
+	}
+
+	type indexedResult struct {
+		index  int
+		result MinerResult
+	}
+
+	results := make(chan indexedResult, len(b.clients))
+	for i, client := range b.clients {
+		go func(i int, client *Client) {
+			results <- indexedResult{
+				index:  i,
+				result: b.submit(ctx, client, request),
+			}
+		}(i, client)
+	}
+
+	result := &BroadcastResult{
+		Results: make([]MinerResult, len(b.clients)),
+	}
+	for i, client := range b.clients {
+		result.Results[i] = MinerResult{
+			BaseURL: client.BaseURL,
+			Err:     ErrBroadcastPending,
+		}
+	}
+
+	accepted := 0
+	for range b.clients {
+		received := <-results
+		result.Results[received.index] = received.result
+		if received.result.Err == nil {
+			accepted++
+		}
+
+		if b.config.Policy != BroadcastAll && accepted >= required {
+			return result, nil
+		}
+	}
+
+	if accepted >= required {
+		return result, nil
+	}
+
+	return result, errors.Wrapf(ErrNotAccepted, "%d of %d required", accepted, required)
+}
+
+// submit submits the tx to one miner, retrying after transient failures. The miner's fee quote is
+// read from the cache, and only requested when it isn't cached or has expired.
+func (b *Broadcaster) submit(ctx context.Context, client *Client,
+	request SubmitTxRequest) MinerResult {
+
+	result := MinerResult{
+		BaseURL: client.BaseURL,
+	}
+
+	if quote, err := b.feeQuote(ctx, client); err == nil {
+		result.FeeQuote = quote
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := client.SubmitTx(ctx, request)
+		err = acceptUnsigned(client, err)
+		if err == nil {
+			err = response.Success()
+			if errors.Cause(err) == AlreadyInMempool {
+				err = nil
+			}
+		}
+
+		result.Response = response
+		result.Err = err
+
+		if err == nil || attempt >= b.config.RetryCount || !IsTransient(err) {
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Err = ErrBroadcastCancelled
+			return result
+		case <-time.After(b.config.RetryDelay):
+		}
+	}
+}
+
+// acceptUnsigned returns nil for the error of an unsigned response when the client doesn't require
+// responses to be signed by a miner ID. Miners aren't required to sign responses.
+func acceptUnsigned(client *Client, err error) error {
+	if errors.Cause(err) == json_envelope.ErrJSONNotSigned && client.MinerID == nil &&
+		client.MinerIDClient == nil {
+		return nil
+	}
+
+	return err
+}
+
+// IsTransient returns true if the error is from a failure that might not happen if the request is
+// retried, like a network error or a server error.
+func IsTransient(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case HTTPError:
+		return cause.Status >= 500 || cause.Status == http.StatusTooManyRequests
+	case net.Error:
+		return true
+	}
+
+	return false
+}
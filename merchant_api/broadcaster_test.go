@@ -0,0 +1,353 @@
+package merchant_api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/json_envelope"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// mockMiner is a merchant API server that fails the first failCount submits with a server error
+// and then responds with result after delay. Fee quotes contain fees and expire after expiry, or
+// after an hour when expiry is zero. Responses are not signed when unsigned is true.
+type mockMiner struct {
+	t         *testing.T
+	key       bitcoin.Key
+	result    string
+	failCount int
+	delay     time.Duration
+	fees      []*FeeQuote
+	expiry    time.Duration
+	unsigned  bool
+
+	feeQuotes int
+	submits   int
+	completed int // submits that weren't cancelled by the client
+
+	sync.Mutex
+}
+
+func (m *mockMiner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+
+	var payload interface{}
+	switch r.URL.Path {
+	case "/mapi/feeQuote":
+		m.feeQuotes++
+		expiry := m.expiry
+		if expiry == 0 {
+			expiry = time.Hour
+		}
+		payload = FeeQuoteResponse{
+			MinerID: m.key.PublicKey(),
+			Expiry:  time.Now().Add(expiry),
+			Fees:    m.fees,
+		}
+
+	case "/mapi/tx":
+		m.submits++
+		if m.submits <= m.failCount {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var request SubmitTxRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		time.Sleep(m.delay)
+		if r.Context().Err() != nil {
+			return
+		}
+		m.completed++
+
+		payload = SubmitTxResponse{
+			MinerID: m.key.PublicKey(),
+			TxID:    *request.Tx.TxHash(),
+			Result:  m.result,
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if m.unsigned {
+		envelope, err := json_envelope.NewJSONEnvelope(payload)
+		if err != nil {
+			m.t.Errorf("Failed to create envelope : %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(envelope)
+		return
+	}
+
+	json.NewEncoder(w).Encode(signedEnvelope(m.t, m.key, payload))
+}
+
+func TestBroadcaster(t *testing.T) {
+	ctx := context.Background()
+
+	var miners []*mockMiner
+	var clients []*Client
+	for _, miner := range []*mockMiner{
+		{result: "success"},
+		{result: "success", failCount: 1},
+		{result: "failure"},
+	} {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		miner.t = t
+		miner.key = key
+
+		server := httptest.NewServer(miner)
+		defer server.Close()
+
+		miners = append(miners, miner)
+		clients = append(clients, NewClient(server.URL, ""))
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{1}, 0), []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	request := NewSubmitTxRequest(tx, "", "")
+
+	broadcaster := NewBroadcaster(BroadcasterConfig{
+		Policy:     BroadcastAll,
+		RetryCount: 2,
+		RetryDelay: time.Millisecond,
+	}, clients...)
+
+	result, err := broadcaster.Broadcast(ctx, request)
+	if errors.Cause(err) != ErrNotAccepted {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrNotAccepted)
+	}
+
+	accepted := result.Accepted()
+	if len(accepted) != 2 {
+		t.Fatalf("Wrong accepted count : got %d, want %d", len(accepted), 2)
+	}
+
+	if result.Results[0].Err != nil || result.Results[1].Err != nil {
+		t.Errorf("Miners should have accepted : %v, %v", result.Results[0].Err,
+			result.Results[1].Err)
+	}
+
+	if errors.Cause(result.Results[2].Err) != ErrFailure {
+		t.Errorf("Wrong miner error : got %v, want %s", result.Results[2].Err, ErrFailure)
+	}
+
+	miners[1].Lock()
+	submits := miners[1].submits
+	miners[1].Unlock()
+	if submits != 2 {
+		t.Errorf("Wrong submit count : got %d, want %d", submits, 2)
+	}
+
+	broadcaster = NewBroadcaster(BroadcasterConfig{
+		Policy: BroadcastQuorum,
+		Quorum: 3,
+	}, clients...)
+
+	if _, err := broadcaster.Broadcast(ctx, request); errors.Cause(err) != ErrNotAccepted {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrNotAccepted)
+	}
+
+	var previousFeeQuotes []int
+	for _, miner := range miners {
+		miner.Lock()
+		previousFeeQuotes = append(previousFeeQuotes, miner.feeQuotes)
+		miner.Unlock()
+	}
+
+	broadcaster = NewBroadcaster(BroadcasterConfig{
+		Policy: BroadcastFirstSuccess,
+	}, clients...)
+
+	result, err = broadcaster.Broadcast(ctx, request)
+	if err != nil {
+		t.Fatalf("Failed to broadcast : %s", err)
+	}
+
+	if len(result.Accepted()) == 0 {
+		t.Errorf("No miners accepted")
+	}
+
+	for i, minerResult := range result.Results {
+		if minerResult.Err == nil && minerResult.FeeQuote == nil {
+			t.Errorf("Missing fee quote in result %d", i)
+		}
+	}
+
+	// Fee quotes are cached, including the ones read by the broadcast.
+	for i := 0; i < 2; i++ {
+		quotes := broadcaster.FeeQuotes(ctx)
+		for j, quote := range quotes {
+			if quote == nil {
+				t.Fatalf("Missing fee quote %d", j)
+			}
+		}
+	}
+
+	for i, miner := range miners {
+		miner.Lock()
+		feeQuotes := miner.feeQuotes
+		miner.Unlock()
+		if feeQuotes-previousFeeQuotes[i] != 1 {
+			t.Errorf("Wrong fee quote count for miner %d : got %d, want %d", i,
+				feeQuotes-previousFeeQuotes[i], 1)
+		}
+	}
+}
+
+func TestBroadcasterFirstSuccessCompletesSubmits(t *testing.T) {
+	ctx := context.Background()
+
+	var miners []*mockMiner
+	var clients []*Client
+	for _, miner := range []*mockMiner{
+		{result: "success"},
+		{result: "success", delay: 200 * time.Millisecond},
+	} {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		miner.t = t
+		miner.key = key
+
+		server := httptest.NewServer(miner)
+		defer server.Close()
+
+		miners = append(miners, miner)
+		clients = append(clients, NewClient(server.URL, ""))
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{2}, 0), []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+
+	broadcaster := NewBroadcaster(BroadcasterConfig{
+		Policy: BroadcastFirstSuccess,
+	}, clients...)
+
+	result, err := broadcaster.Broadcast(ctx, NewSubmitTxRequest(tx, "", ""))
+	if err != nil {
+		t.Fatalf("Failed to broadcast : %s", err)
+	}
+
+	if result.Results[0].Err != nil {
+		t.Fatalf("First miner should have accepted : %s", result.Results[0].Err)
+	}
+
+	if result.Results[1].Err != ErrBroadcastPending {
+		t.Fatalf("Wrong slow miner error : got %v, want %s", result.Results[1].Err,
+			ErrBroadcastPending)
+	}
+
+	// The submit to the slow miner must complete after the broadcast returns.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		miners[1].Lock()
+		completed := miners[1].completed
+		miners[1].Unlock()
+
+		if completed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Slow miner didn't receive the tx")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBroadcasterUnsignedResponses(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	miner := &mockMiner{
+		t:        t,
+		key:      key,
+		result:   "success",
+		unsigned: true,
+	}
+
+	server := httptest.NewServer(miner)
+	defer server.Close()
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{3}, 0), []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	request := NewSubmitTxRequest(tx, "", "")
+
+	// Unsigned responses are accepted when the client doesn't require a miner ID.
+	broadcaster := NewBroadcaster(BroadcasterConfig{
+		Policy: BroadcastAll,
+	}, NewClient(server.URL, ""))
+
+	result, err := broadcaster.Broadcast(ctx, request)
+	if err != nil {
+		t.Fatalf("Failed to broadcast : %s", err)
+	}
+
+	if result.Results[0].Err != nil {
+		t.Fatalf("Miner should have accepted : %s", result.Results[0].Err)
+	}
+
+	if result.Results[0].FeeQuote == nil {
+		t.Fatalf("Missing unsigned fee quote")
+	}
+
+	// Unsigned fee quotes are cached.
+	if quotes := broadcaster.FeeQuotes(ctx); quotes[0] == nil {
+		t.Fatalf("Missing unsigned fee quote")
+	}
+
+	miner.Lock()
+	feeQuotes := miner.feeQuotes
+	miner.Unlock()
+	if feeQuotes != 1 {
+		t.Errorf("Wrong fee quote count : got %d, want %d", feeQuotes, 1)
+	}
+
+	// Unsigned responses are rejected when the client requires a miner ID.
+	client := NewClient(server.URL, "")
+	client.SetMinerID(key.PublicKey())
+	broadcaster = NewBroadcaster(BroadcasterConfig{
+		Policy: BroadcastAll,
+	}, client)
+
+	result, err = broadcaster.Broadcast(ctx, request)
+	if errors.Cause(err) != ErrNotAccepted {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrNotAccepted)
+	}
+
+	if errors.Cause(result.Results[0].Err) != json_envelope.ErrJSONNotSigned {
+		t.Fatalf("Wrong miner error : got %v, want %s", result.Results[0].Err,
+			json_envelope.ErrJSONNotSigned)
+	}
+
+	if result.Results[0].FeeQuote != nil {
+		t.Fatalf("Unsigned fee quote should not be used")
+	}
+}
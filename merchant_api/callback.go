@@ -0,0 +1,205 @@
+package merchant_api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/json_envelope"
+	"github.com/tokenized/pkg/merkle_proof"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnknownMinerID  = errors.New("Unknown Miner ID")
+	ErrUnknownCallBack = errors.New("Unknown Call Back Reason")
+)
+
+// CallBackHandler handles verified call backs from miners.
+type CallBackHandler interface {
+	// HandleMerkleProof is called when a tx has been mined.
+	HandleMerkleProof(ctx context.Context, callBack *SubmitTxCallbackResponse,
+		proof *merkle_proof.MerkleProof) error
+
+	// HandleDoubleSpend is called when a double spend of a tx was attempted or mined.
+	HandleDoubleSpend(ctx context.Context, callBack *SubmitTxCallbackResponse,
+		doubleSpend *CallBackDoubleSpend) error
+}
+
+// CallBackReceiver is an HTTP handler that receives mAPI call backs, verifies that they were signed
+// by the miner, and dispatches them to a CallBackHandler. When a token is set it must match the
+// bearer token in the request, which is the call back token provided when the tx was submitted.
+// When miner IDs or miner ID clients are added, call backs must be signed by one of the miner IDs
+// or by the current miner ID of one of the miner ID clients.
+type CallBackReceiver struct {
+	handler        CallBackHandler
+	token          string
+	minerIDs       []bitcoin.PublicKey
+	minerIDClients []*MinerIDClient
+
+	sync.Mutex
+}
+
+// NewCallBackReceiver creates a call back receiver. The token is optional.
+func NewCallBackReceiver(handler CallBackHandler, token string) *CallBackReceiver {
+	return &CallBackReceiver{
+		handler: handler,
+		token:   token,
+	}
+}
+
+// AddMinerID adds a miner ID that call backs can be signed by.
+func (r *CallBackReceiver) AddMinerID(minerID bitcoin.PublicKey) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.minerIDs = append(r.minerIDs, minerID)
+}
+
+// AddMinerIDClient adds a miner ID client whose current miner ID call backs can be signed by, so
+// call backs are still accepted after the miner rotates its miner ID.
+func (r *CallBackReceiver) AddMinerIDClient(minerIDClient *MinerIDClient) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.minerIDClients = append(r.minerIDClients, minerIDClient)
+}
+
+// ServeHTTP receives a call back. It responds with status OK when the call back was handled.
+func (r *CallBackReceiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if len(r.token) > 0 && request.Header.Get("Authorization") != "Bearer "+r.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	envelope := &json_envelope.JSONEnvelope{}
+	if err := json.NewDecoder(request.Body).Decode(envelope); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON envelope : %s", err), http.StatusBadRequest)
+		return
+	}
+
+	callBack, err := r.Open(request.Context(), envelope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payload, err := callBackPayload(callBack)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := r.handle(request.Context(), callBack, payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Open verifies the envelope's signature and miner ID and returns the call back it contains. It can
+// be used directly for call backs that are delivered by other means, like SPV channels.
+func (r *CallBackReceiver) Open(ctx context.Context,
+	envelope *json_envelope.JSONEnvelope) (*SubmitTxCallbackResponse, error) {
+
+	if envelope.MimeType != "application/json" {
+		return nil, fmt.Errorf("MIME Type not JSON : %s", envelope.MimeType)
+	}
+
+	if err := envelope.Verify(); err != nil {
+		return nil, errors.Wrap(err, "verify")
+	}
+
+	result := &SubmitTxCallbackResponse{}
+	if err := json.Unmarshal([]byte(envelope.Payload), result); err != nil {
+		return nil, errors.Wrap(err, "json unmarshal")
+	}
+
+	if !result.MinerID.Equal(*envelope.PublicKey) {
+		return nil, ErrWrongPublicKey
+	}
+
+	r.Lock()
+	minerIDs := r.minerIDs
+	minerIDClients := r.minerIDClients
+	r.Unlock()
+
+	if len(minerIDs) == 0 && len(minerIDClients) == 0 {
+		return result, nil
+	}
+
+	for _, minerID := range minerIDs {
+		if minerID.Equal(result.MinerID) {
+			return result, nil
+		}
+	}
+
+	// Miner ID clients are checked last because they can fetch miner ID documents.
+	for _, minerIDClient := range minerIDClients {
+		err := minerIDClient.Verify(ctx, result.MinerID)
+		if err == nil {
+			return result, nil
+		}
+		if errors.Cause(err) == ErrRevokedMinerID {
+			return nil, err
+		}
+	}
+
+	return nil, errors.Wrap(ErrUnknownMinerID, result.MinerID.String())
+}
+
+// Dispatch passes the call back to the handler based on its reason.
+func (r *CallBackReceiver) Dispatch(ctx context.Context, callBack *SubmitTxCallbackResponse) error {
+	payload, err := callBackPayload(callBack)
+	if err != nil {
+		return err
+	}
+
+	return r.handle(ctx, callBack, payload)
+}
+
+// callBackPayload returns the parsed payload of the call back based on its reason.
+func callBackPayload(callBack *SubmitTxCallbackResponse) (interface{}, error) {
+	switch callBack.Reason {
+	case CallBackReasonMerkleProof:
+		proof, err := callBack.MerkleProof()
+		if err != nil {
+			return nil, errors.Wrap(err, "merkle proof")
+		}
+		return proof, nil
+
+	case CallBackReasonDoubleSpend, CallBackReasonDoubleSpendAttempt:
+		doubleSpend, err := callBack.DoubleSpend()
+		if err != nil {
+			return nil, errors.Wrap(err, "double spend")
+		}
+		return doubleSpend, nil
+
+	default:
+		return nil, errors.Wrap(ErrUnknownCallBack, callBack.Reason)
+	}
+}
+
+// handle passes the parsed payload of the call back to the handler.
+func (r *CallBackReceiver) handle(ctx context.Context, callBack *SubmitTxCallbackResponse,
+	payload interface{}) error {
+
+	switch p := payload.(type) {
+	case *merkle_proof.MerkleProof:
+		return r.handler.HandleMerkleProof(ctx, callBack, p)
+	case *CallBackDoubleSpend:
+		return r.handler.HandleDoubleSpend(ctx, callBack, p)
+	default:
+		return errors.Wrap(ErrUnknownCallBack, callBack.Reason)
+	}
+}
@@ -0,0 +1,166 @@
+package merchant_api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/wire"
+)
+
+type mockCallBackHandler struct {
+	proofs       []*merkle_proof.MerkleProof
+	doubleSpends []*CallBackDoubleSpend
+}
+
+func (h *mockCallBackHandler) HandleMerkleProof(ctx context.Context,
+	callBack *SubmitTxCallbackResponse, proof *merkle_proof.MerkleProof) error {
+
+	h.proofs = append(h.proofs, proof)
+	return nil
+}
+
+func (h *mockCallBackHandler) HandleDoubleSpend(ctx context.Context,
+	callBack *SubmitTxCallbackResponse, doubleSpend *CallBackDoubleSpend) error {
+
+	h.doubleSpends = append(h.doubleSpends, doubleSpend)
+	return nil
+}
+
+func TestCallBackReceiver(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	otherKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	txid := bitcoin.Hash32{1}
+	proof := merkle_proof.NewMerkleProof(txid)
+	proof.Index = 0
+	proof.AddHash(bitcoin.Hash32{3}, bitcoin.Hash32{2})
+	proof.BlockHash = &bitcoin.Hash32{4}
+
+	proofPayload, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("Failed to marshal merkle proof : %s", err)
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{1}, 0), []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+
+	doubleSpendPayload, err := json.Marshal(CallBackDoubleSpend{
+		TxID: *tx.TxHash(),
+		Tx:   tx,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal double spend : %s", err)
+	}
+
+	handler := &mockCallBackHandler{}
+	receiver := NewCallBackReceiver(handler, "token")
+	receiver.AddMinerID(key.PublicKey())
+
+	tests := []struct {
+		name    string
+		key     bitcoin.Key
+		token   string
+		reason  string
+		payload json.RawMessage
+		status  int
+	}{
+		{
+			name:    "merkle proof",
+			key:     key,
+			token:   "token",
+			reason:  CallBackReasonMerkleProof,
+			payload: proofPayload,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "double spend",
+			key:     key,
+			token:   "token",
+			reason:  CallBackReasonDoubleSpendAttempt,
+			payload: doubleSpendPayload,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "wrong token",
+			key:     key,
+			token:   "other",
+			reason:  CallBackReasonMerkleProof,
+			payload: proofPayload,
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "unknown miner",
+			key:     otherKey,
+			token:   "token",
+			reason:  CallBackReasonMerkleProof,
+			payload: proofPayload,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "unknown reason",
+			key:     key,
+			token:   "token",
+			reason:  "unknown",
+			payload: proofPayload,
+			status:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope := signedEnvelope(t, tt.key, SubmitTxCallbackResponse{
+				Reason:  tt.reason,
+				TxID:    &txid,
+				MinerID: tt.key.PublicKey(),
+				Payload: tt.payload,
+			})
+
+			body, err := json.Marshal(envelope)
+			if err != nil {
+				t.Fatalf("Failed to marshal envelope : %s", err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/callback", bytes.NewReader(body))
+			request.Header.Set("Authorization", "Bearer "+tt.token)
+			recorder := httptest.NewRecorder()
+
+			receiver.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.status {
+				t.Errorf("Wrong status : got %d, want %d : %s", recorder.Code, tt.status,
+					recorder.Body.String())
+			}
+		})
+	}
+
+	if len(handler.proofs) != 1 {
+		t.Fatalf("Wrong merkle proof count : got %d, want %d", len(handler.proofs), 1)
+	}
+
+	if !handler.proofs[0].TxID.Equal(&txid) {
+		t.Errorf("Wrong merkle proof txid : got %s, want %s", handler.proofs[0].TxID, txid)
+	}
+
+	if len(handler.doubleSpends) != 1 {
+		t.Fatalf("Wrong double spend count : got %d, want %d", len(handler.doubleSpends), 1)
+	}
+
+	if !handler.doubleSpends[0].TxID.Equal(tx.TxHash()) {
+		t.Errorf("Wrong double spend txid : got %s, want %s", handler.doubleSpends[0].TxID,
+			tx.TxHash())
+	}
+}
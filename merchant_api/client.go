@@ -0,0 +1,252 @@
+package merchant_api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/json_envelope"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrWrongMinerID        = errors.New("Wrong Miner ID")
+	ErrWrongCallBackReason = errors.New("Wrong Call Back Reason")
+)
+
+// Client is a merchant API client for one miner. When MinerID is set, responses must be signed by
+// that miner ID. When MinerIDClient is set, responses must be signed by the miner's current miner
+// ID from its miner ID document.
+type Client struct {
+	BaseURL       string
+	Token         string // Bearer token sent with requests when not empty
+	MinerID       *bitcoin.PublicKey
+	MinerIDClient *MinerIDClient
+}
+
+// NewClient creates a merchant API client. The token is optional.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+	}
+}
+
+// SetMinerID sets the miner ID that responses must be signed by.
+func (c *Client) SetMinerID(minerID bitcoin.PublicKey) {
+	c.MinerID = &minerID
+}
+
+// SetMinerIDClient sets the miner ID client used to verify that responses are signed by the miner's
+// current miner ID.
+func (c *Client) SetMinerIDClient(minerIDClient *MinerIDClient) {
+	c.MinerIDClient = minerIDClient
+}
+
+// SubmitTxsResponse is the response to a multi-submit of txs.
+type SubmitTxsResponse struct {
+	Version                string            `json:"apiVersion"`
+	Timestamp              time.Time         `json:"timestamp"`
+	MinerID                bitcoin.PublicKey `json:"minerId"`
+	CurrentBlockHash       bitcoin.Hash32    `json:"currentHighestBlockHash"`
+	CurrentBlockHeight     uint32            `json:"currentHighestBlockHeight"`
+	SecondaryMempoolExpiry uint32            `json:"txSecondMempoolExpiry"`
+	Txs                    []SubmitTxResult  `json:"txs"`
+	FailureCount           uint32            `json:"failureCount"`
+}
+
+// SubmitTxResult is the result for one tx of a multi-submit.
+type SubmitTxResult struct {
+	TxID              bitcoin.Hash32 `json:"txid"`
+	Result            string         `json:"returnResult"`
+	ResultDescription string         `json:"resultDescription"`
+	Conflicts         []Conflict     `json:"conflictedWith"`
+}
+
+// Success returns nil if the tx was accepted.
+func (r SubmitTxResult) Success() error {
+	return SubmitTxResponse{
+		TxID:              r.TxID,
+		Result:            r.Result,
+		ResultDescription: r.ResultDescription,
+		Conflicts:         r.Conflicts,
+	}.Success()
+}
+
+// GetFeeQuote returns the miner's current fee quote.
+func (c *Client) GetFeeQuote(ctx context.Context) (*FeeQuoteResponse, error) {
+	if len(c.BaseURL) == 0 {
+		return nil, fmt.Errorf("Invalid Base URL : %s", c.BaseURL)
+	}
+
+	envelope := &json_envelope.JSONEnvelope{}
+	if err := get(ctx, c.BaseURL+"/mapi/feeQuote", c.Token, envelope); err != nil {
+		return nil, errors.Wrap(err, "http get")
+	}
+
+	result := &FeeQuoteResponse{}
+	if err := c.openEnvelope(envelope, result); err != nil {
+		return nil, err
+	}
+
+	return result, c.verifyEnvelope(ctx, envelope, result.MinerID)
+}
+
+// SubmitTx submits a tx to the miner.
+func (c *Client) SubmitTx(ctx context.Context,
+	request SubmitTxRequest) (*SubmitTxResponse, error) {
+
+	if len(c.BaseURL) == 0 {
+		return nil, fmt.Errorf("Invalid Base URL : %s", c.BaseURL)
+	}
+
+	envelope := &json_envelope.JSONEnvelope{}
+	if err := post(ctx, c.BaseURL+"/mapi/tx", c.Token, request, envelope); err != nil {
+		return nil, errors.Wrap(err, "http post")
+	}
+
+	result := &SubmitTxResponse{}
+	if err := c.openEnvelope(envelope, result); err != nil {
+		return nil, err
+	}
+
+	return result, c.verifyEnvelope(ctx, envelope, result.MinerID)
+}
+
+// SubmitTxs submits multiple txs to the miner in one request. The response contains a result for
+// each tx.
+func (c *Client) SubmitTxs(ctx context.Context,
+	requests []SubmitTxRequest) (*SubmitTxsResponse, error) {
+
+	if len(c.BaseURL) == 0 {
+		return nil, fmt.Errorf("Invalid Base URL : %s", c.BaseURL)
+	}
+
+	envelope := &json_envelope.JSONEnvelope{}
+	if err := post(ctx, c.BaseURL+"/mapi/txs", c.Token, requests, envelope); err != nil {
+		return nil, errors.Wrap(err, "http post")
+	}
+
+	result := &SubmitTxsResponse{}
+	if err := c.openEnvelope(envelope, result); err != nil {
+		return nil, err
+	}
+
+	return result, c.verifyEnvelope(ctx, envelope, result.MinerID)
+}
+
+// GetTxStatus returns the status of a tx. If it is confirmed it will return valid. A merkle proof
+// is requested, which is included by miners that support it when the tx is confirmed.
+func (c *Client) GetTxStatus(ctx context.Context,
+	txid bitcoin.Hash32) (*GetTxStatusResponse, error) {
+
+	if len(c.BaseURL) == 0 {
+		return nil, fmt.Errorf("Invalid Base URL : %s", c.BaseURL)
+	}
+
+	envelope := &json_envelope.JSONEnvelope{}
+	url := c.BaseURL + "/mapi/tx/" + txid.String() + "?merkleProof=true&merkleFormat=" +
+		CallBackMerkleProofFormat
+	if err := get(ctx, url, c.Token, envelope); err != nil {
+		return nil, errors.Wrap(err, "http get")
+	}
+
+	result := &GetTxStatusResponse{}
+	if err := c.openEnvelope(envelope, result); err != nil {
+		return nil, err
+	}
+
+	return result, c.verifyEnvelope(ctx, envelope, result.MinerID)
+}
+
+// openEnvelope unmarshals the envelope's JSON payload into result.
+func (c *Client) openEnvelope(envelope *json_envelope.JSONEnvelope, result interface{}) error {
+	return envelope.Unmarshal(result)
+}
+
+// verifyEnvelope verifies the envelope's signature and that it was signed by the miner ID in the
+// payload. When the client has a miner ID, the envelope must be signed by it. When the client has a
+// miner ID client, the envelope must be signed by the miner's current miner ID.
+func (c *Client) verifyEnvelope(ctx context.Context, envelope *json_envelope.JSONEnvelope,
+	minerID bitcoin.PublicKey) error {
+
+	if envelope.PublicKey != nil && !minerID.Equal(*envelope.PublicKey) {
+		return ErrWrongPublicKey
+	}
+
+	if c.MinerID != nil {
+		if envelope.PublicKey == nil {
+			return json_envelope.ErrJSONNotSigned
+		}
+
+		if !c.MinerID.Equal(*envelope.PublicKey) {
+			return errors.Wrapf(ErrWrongMinerID, "got %s, want %s", envelope.PublicKey,
+				c.MinerID)
+		}
+	}
+
+	if c.MinerIDClient != nil {
+		return c.MinerIDClient.VerifyEnvelope(ctx, envelope)
+	}
+
+	return envelope.Verify()
+}
+
+// MerkleProof returns the merkle proof in a merkle proof call back.
+func (r SubmitTxCallbackResponse) MerkleProof() (*merkle_proof.MerkleProof, error) {
+	if r.Reason != CallBackReasonMerkleProof {
+		return nil, errors.Wrap(ErrWrongCallBackReason, r.Reason)
+	}
+
+	result, err := merkle_proof.ParseMerkleProof(r.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "merkle proof")
+	}
+
+	if r.TxID != nil && result.TxID != nil && !r.TxID.Equal(result.TxID) {
+		return nil, fmt.Errorf("Wrong merkle proof txid : got %s, want %s", result.TxID, r.TxID)
+	}
+
+	return result, nil
+}
+
+// DoubleSpend returns the double spend in a double spend or double spend attempt call back.
+func (r SubmitTxCallbackResponse) DoubleSpend() (*CallBackDoubleSpend, error) {
+	if r.Reason != CallBackReasonDoubleSpend && r.Reason != CallBackReasonDoubleSpendAttempt {
+		return nil, errors.Wrap(ErrWrongCallBackReason, r.Reason)
+	}
+
+	result := &CallBackDoubleSpend{}
+	if err := json.Unmarshal(r.Payload, result); err != nil {
+		return nil, errors.Wrap(err, "double spend")
+	}
+
+	return result, nil
+}
+
+// NewSubmitTxRequest creates a request to submit a tx that requests merkle proof and double spend
+// call backs to the call back URL when it isn't empty.
+func NewSubmitTxRequest(tx *wire.MsgTx, callBackURL, callBackToken string) SubmitTxRequest {
+	result := SubmitTxRequest{
+		Tx: tx,
+	}
+
+	if len(callBackURL) > 0 {
+		format := CallBackMerkleProofFormat
+		result.CallBackURL = &callBackURL
+		result.SendMerkleProof = true
+		result.MerkleProofFormat = &format
+		result.DoubleSpendCheck = true
+		if len(callBackToken) > 0 {
+			result.CallBackToken = &callBackToken
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,181 @@
+package merchant_api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/json_envelope"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// signedEnvelope returns a JSON envelope containing the payload signed by the key.
+func signedEnvelope(t *testing.T, key bitcoin.Key,
+	payload interface{}) *json_envelope.JSONEnvelope {
+
+	envelope, err := json_envelope.NewJSONEnvelope(payload)
+	if err != nil {
+		t.Fatalf("Failed to create envelope : %s", err)
+	}
+
+	if err := envelope.Sign(key); err != nil {
+		t.Fatalf("Failed to sign envelope : %s", err)
+	}
+
+	return envelope
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{1}, 0), []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var payload interface{}
+		switch r.URL.Path {
+		case "/mapi/feeQuote":
+			payload = FeeQuoteResponse{
+				MinerID: key.PublicKey(),
+				Fees: []*FeeQuote{
+					{
+						FeeType:   FeeQuoteTypeStandard,
+						MiningFee: Fee{Satoshis: 500, Bytes: 1000},
+						RelayFee:  Fee{Satoshis: 250, Bytes: 1000},
+					},
+				},
+			}
+
+		case "/mapi/txs":
+			var requests []SubmitTxRequest
+			if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			response := SubmitTxsResponse{
+				MinerID: key.PublicKey(),
+			}
+			for _, request := range requests {
+				response.Txs = append(response.Txs, SubmitTxResult{
+					TxID:   *request.Tx.TxHash(),
+					Result: "success",
+				})
+			}
+			payload = response
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(signedEnvelope(t, key, payload))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "token")
+	client.SetMinerID(key.PublicKey())
+
+	quote, err := client.GetFeeQuote(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get fee quote : %s", err)
+	}
+
+	txQuote, err := quote.TxFeeQuote()
+	if err != nil {
+		t.Fatalf("Failed to convert fee quote : %s", err)
+	}
+
+	if txQuote.Fee(1000, 0) != 500 {
+		t.Errorf("Wrong fee : got %d, want %d", txQuote.Fee(1000, 0), 500)
+	}
+
+	response, err := client.SubmitTxs(ctx, []SubmitTxRequest{
+		NewSubmitTxRequest(tx, "https://callback.com", "callback token"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to submit txs : %s", err)
+	}
+
+	if len(response.Txs) != 1 {
+		t.Fatalf("Wrong tx result count : got %d, want %d", len(response.Txs), 1)
+	}
+
+	if !response.Txs[0].TxID.Equal(tx.TxHash()) {
+		t.Errorf("Wrong txid : got %s, want %s", response.Txs[0].TxID, tx.TxHash())
+	}
+
+	if err := response.Txs[0].Success(); err != nil {
+		t.Errorf("Submit failed : %s", err)
+	}
+
+	otherKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	client.SetMinerID(otherKey.PublicKey())
+	if _, err := client.GetFeeQuote(ctx); errors.Cause(err) != ErrWrongMinerID {
+		t.Errorf("Wrong error : got %v, want %s", err, ErrWrongMinerID)
+	}
+
+	client.Token = ""
+	if _, err := client.GetFeeQuote(ctx); err == nil {
+		t.Errorf("Fee quote should fail without token")
+	}
+}
+
+func TestCallBack(t *testing.T) {
+	txid := bitcoin.Hash32{1}
+	root := bitcoin.Hash32{2}
+	proof := merkle_proof.NewMerkleProof(txid)
+	proof.Index = 0
+	proof.AddHash(bitcoin.Hash32{3}, root)
+	proof.BlockHash = &bitcoin.Hash32{4}
+
+	payload, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("Failed to marshal merkle proof : %s", err)
+	}
+
+	callBack := SubmitTxCallbackResponse{
+		Reason:  CallBackReasonMerkleProof,
+		TxID:    &txid,
+		Payload: payload,
+	}
+
+	readProof, err := callBack.MerkleProof()
+	if err != nil {
+		t.Fatalf("Failed to get merkle proof : %s", err)
+	}
+
+	if !readProof.TxID.Equal(&txid) {
+		t.Errorf("Wrong merkle proof txid : got %s, want %s", readProof.TxID, txid)
+	}
+
+	if _, err := callBack.DoubleSpend(); errors.Cause(err) != ErrWrongCallBackReason {
+		t.Errorf("Wrong error : got %v, want %s", err, ErrWrongCallBackReason)
+	}
+
+	callBack.TxID = &bitcoin.Hash32{5}
+	if _, err := callBack.MerkleProof(); err == nil {
+		t.Errorf("Merkle proof with wrong txid should fail")
+	}
+}
@@ -0,0 +1,160 @@
+package merchant_api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/txbuilder"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNoValidFeeQuote = errors.New("No Valid Fee Quote")
+)
+
+// FeeQuoter keeps current fee quotes from multiple miners and selects the cheapest.
+type FeeQuoter struct {
+	clients         []*Client
+	refreshInterval time.Duration
+
+	quotes []*cachedFeeQuote // Same order as clients
+	lock   sync.Mutex
+}
+
+// NewFeeQuoter creates a fee quoter that refreshes fee quotes from the miners of the clients at
+// the refresh interval when run.
+func NewFeeQuoter(refreshInterval time.Duration, clients ...*Client) *FeeQuoter {
+	return &FeeQuoter{
+		clients:         clients,
+		refreshInterval: refreshInterval,
+		quotes:          make([]*cachedFeeQuote, len(clients)),
+	}
+}
+
+// Run refreshes the fee quotes at the refresh interval until the interrupt is closed.
+func (q *FeeQuoter) Run(ctx context.Context, interrupt <-chan interface{}) error {
+	ticker := time.NewTicker(q.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		q.Refresh(ctx)
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh requests new fee quotes from all miners concurrently. A miner's previous fee quote is
+// kept when the request fails, until it expires. It returns the number of fee quotes received.
+func (q *FeeQuoter) Refresh(ctx context.Context) int {
+	received := make([]*cachedFeeQuote, len(q.clients))
+
+	var wait sync.WaitGroup
+	for i, client := range q.clients {
+		wait.Add(1)
+		go func(i int, client *Client) {
+			quote, err := client.GetFeeQuote(ctx)
+			if err == nil {
+				received[i] = newCachedFeeQuote(quote, time.Now())
+			}
+			wait.Done()
+		}(i, client)
+	}
+	wait.Wait()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	count := 0
+	for i, quote := range received {
+		if quote != nil {
+			q.quotes[i] = quote
+			count++
+		}
+	}
+
+	return count
+}
+
+// FeeQuotes returns the unexpired fee quotes, in the same order as the clients. The fee quote is
+// nil for miners without a valid fee quote.
+func (q *FeeQuoter) FeeQuotes() []*FeeQuoteResponse {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := time.Now()
+	result := make([]*FeeQuoteResponse, len(q.quotes))
+	for i, quote := range q.quotes {
+		if quote != nil && now.Before(quote.expiry) {
+			result[i] = quote.response
+		}
+	}
+
+	return result
+}
+
+// BestFeeQuote returns the unexpired fee quote that has the lowest fee for a tx with the specified
+// numbers of standard and data bytes, and the base URL of the miner that provided it.
+func (q *FeeQuoter) BestFeeQuote(standardSize, dataSize int) (*txbuilder.FeeQuote, string,
+	error) {
+
+	var best *txbuilder.FeeQuote
+	var bestURL string
+	var bestFee uint64
+	for i, response := range q.FeeQuotes() {
+		if response == nil {
+			continue
+		}
+
+		quote, err := response.TxFeeQuote()
+		if err != nil {
+			continue
+		}
+
+		fee := quote.Fee(standardSize, dataSize)
+		if best == nil || fee < bestFee {
+			best = quote
+			bestURL = q.clients[i].BaseURL
+			bestFee = fee
+		}
+	}
+
+	if best == nil {
+		return nil, "", ErrNoValidFeeQuote
+	}
+
+	return best, bestURL, nil
+}
+
+// SetTxFeeQuote sets the fee quote of the tx to the cheapest fee quote for the tx's current
+// numbers of standard and data bytes. It returns the base URL of the miner that provided the fee
+// quote so the tx can be submitted to that miner.
+func (q *FeeQuoter) SetTxFeeQuote(tx *txbuilder.TxBuilder) (string, error) {
+	dataSize := txbuilder.DataSize(tx.MsgTx)
+	quote, url, err := q.BestFeeQuote(tx.MsgTx.SerializeSize()-dataSize, dataSize)
+	if err != nil {
+		return "", err
+	}
+
+	tx.SetFeeQuote(quote)
+	return url, nil
+}
+
+// newCachedFeeQuote returns a fee quote that expires at the quote's expiry, or after the default
+// fee quote duration when it doesn't have an expiry.
+func newCachedFeeQuote(response *FeeQuoteResponse, now time.Time) *cachedFeeQuote {
+	expiry := response.Expiry
+	if expiry.IsZero() {
+		expiry = now.Add(DefaultFeeQuoteDuration)
+	}
+
+	return &cachedFeeQuote{
+		response: response,
+		expiry:   expiry,
+	}
+}
@@ -0,0 +1,119 @@
+package merchant_api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/txbuilder"
+
+	"github.com/pkg/errors"
+)
+
+func TestFeeQuoter(t *testing.T) {
+	ctx := context.Background()
+
+	miners := []*mockMiner{
+		{ // cheap standard fees, expensive data fees
+			fees: []*FeeQuote{
+				{
+					FeeType:   FeeQuoteTypeStandard,
+					MiningFee: Fee{Satoshis: 250, Bytes: 1000},
+					RelayFee:  Fee{Satoshis: 250, Bytes: 1000},
+				},
+			},
+		},
+		{ // expensive standard fees, cheap data fees
+			fees: []*FeeQuote{
+				{
+					FeeType:   FeeQuoteTypeStandard,
+					MiningFee: Fee{Satoshis: 500, Bytes: 1000},
+					RelayFee:  Fee{Satoshis: 500, Bytes: 1000},
+				},
+				{
+					FeeType:   FeeQuoteTypeData,
+					MiningFee: Fee{Satoshis: 10, Bytes: 1000},
+					RelayFee:  Fee{Satoshis: 10, Bytes: 1000},
+				},
+			},
+		},
+		{ // cheapest, but expired
+			expiry: -time.Minute,
+			fees: []*FeeQuote{
+				{
+					FeeType:   FeeQuoteTypeStandard,
+					MiningFee: Fee{Satoshis: 1, Bytes: 1000},
+					RelayFee:  Fee{Satoshis: 1, Bytes: 1000},
+				},
+			},
+		},
+	}
+
+	var clients []*Client
+	for _, miner := range miners {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		miner.t = t
+		miner.key = key
+
+		server := httptest.NewServer(miner)
+		defer server.Close()
+
+		clients = append(clients, NewClient(server.URL, ""))
+	}
+
+	quoter := NewFeeQuoter(time.Minute, clients...)
+
+	if _, _, err := quoter.BestFeeQuote(1000, 0); errors.Cause(err) != ErrNoValidFeeQuote {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrNoValidFeeQuote)
+	}
+
+	if count := quoter.Refresh(ctx); count != 3 {
+		t.Fatalf("Wrong fee quote count : got %d, want %d", count, 3)
+	}
+
+	quotes := quoter.FeeQuotes()
+	if quotes[0] == nil || quotes[1] == nil || quotes[2] != nil {
+		t.Fatalf("Wrong valid fee quotes : %v", quotes)
+	}
+
+	quote, url, err := quoter.BestFeeQuote(1000, 0)
+	if err != nil {
+		t.Fatalf("Failed to get best fee quote : %s", err)
+	}
+
+	if url != clients[0].BaseURL {
+		t.Errorf("Wrong standard miner : got %s, want %s", url, clients[0].BaseURL)
+	}
+
+	if quote.Fee(1000, 0) != 250 {
+		t.Errorf("Wrong fee : got %d, want %d", quote.Fee(1000, 0), 250)
+	}
+
+	_, url, err = quoter.BestFeeQuote(100, 10000)
+	if err != nil {
+		t.Fatalf("Failed to get best fee quote : %s", err)
+	}
+
+	if url != clients[1].BaseURL {
+		t.Errorf("Wrong data miner : got %s, want %s", url, clients[1].BaseURL)
+	}
+
+	tx := txbuilder.NewTxBuilder(0.5, 0.25)
+	url, err = quoter.SetTxFeeQuote(tx)
+	if err != nil {
+		t.Fatalf("Failed to set tx fee quote : %s", err)
+	}
+
+	if url != clients[0].BaseURL {
+		t.Errorf("Wrong tx miner : got %s, want %s", url, clients[0].BaseURL)
+	}
+
+	if tx.FeeRate != 0.25 {
+		t.Errorf("Wrong tx fee rate : got %f, want %f", tx.FeeRate, 0.25)
+	}
+}
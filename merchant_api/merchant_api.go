@@ -11,7 +11,8 @@ import (
 
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/json"
-	"github.com/tokenized/pkg/json_envelope"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/txbuilder"
 	"github.com/tokenized/pkg/wire"
 
 	"github.com/pkg/errors"
@@ -23,6 +24,7 @@ var (
 	AlreadyInMempool  = errors.New("Already In Mempool")
 	ErrHTTPNotFound   = errors.New("HTTP Not Found")
 	ErrWrongPublicKey = errors.New("Wrong Public Key")
+	ErrMissingFee     = errors.New("Missing Fee")
 )
 
 const (
@@ -93,30 +95,46 @@ type FeePolicies struct {
 	DustLimitFactor               int      `json:"dustlimitfactor"`
 }
 
+// GetFeeQuote returns the fee quote of the merchant API at the base URL. The response is returned
+// with json_envelope.ErrJSONNotSigned when the miner didn't sign it.
 func GetFeeQuote(ctx context.Context, baseURL string) (*FeeQuoteResponse, error) {
-	if len(baseURL) == 0 {
-		return nil, fmt.Errorf("Invalid Base URL : %s", baseURL)
-	}
+	return NewClient(baseURL, "").GetFeeQuote(ctx)
+}
 
-	if baseURL[len(baseURL)-1:] == "/" {
-		baseURL = baseURL[:len(baseURL)-1]
+// TxFeeQuote converts the response to a fee quote that can be used by a tx builder. The standard
+// fee is required. The data fee defaults to the standard fee when it isn't provided.
+func (r FeeQuoteResponse) TxFeeQuote() (*txbuilder.FeeQuote, error) {
+	var standard, data *FeeQuote
+	for _, fee := range r.Fees {
+		switch fee.FeeType {
+		case FeeQuoteTypeStandard:
+			standard = fee
+		case FeeQuoteTypeData:
+			data = fee
+		}
 	}
 
-	envelope := &json_envelope.JSONEnvelope{}
-	if err := get(ctx, baseURL+"/mapi/feeQuote", envelope); err != nil {
-		return nil, errors.Wrap(err, "http get")
+	if standard == nil {
+		return nil, errors.Wrap(ErrMissingFee, FeeQuoteTypeStandard)
 	}
-
-	if envelope.MimeType != "application/json" {
-		return nil, fmt.Errorf("MIME Type not JSON : %s", envelope.MimeType)
+	if data == nil {
+		data = standard
 	}
 
-	result := &FeeQuoteResponse{}
-	if err := json.Unmarshal([]byte(envelope.Payload), result); err != nil {
-		return nil, errors.Wrap(err, "json unmarshal")
-	}
+	return &txbuilder.FeeQuote{
+		MiningFee:     standard.MiningFee.FeeRate(),
+		RelayFee:      standard.RelayFee.FeeRate(),
+		DataMiningFee: data.MiningFee.FeeRate(),
+		DataRelayFee:  data.RelayFee.FeeRate(),
+	}, nil
+}
 
-	return result, envelope.Verify()
+// FeeRate converts the fee to a tx builder fee rate.
+func (f Fee) FeeRate() txbuilder.FeeRate {
+	return txbuilder.FeeRate{
+		Satoshis: f.Satoshis,
+		Bytes:    f.Bytes,
+	}
 }
 
 type SubmitTxRequest struct {
@@ -193,36 +211,12 @@ type CallBackDoubleSpend struct {
 	Tx   *wire.MsgTx    `json:"payload"`
 }
 
+// SubmitTx submits a tx to the merchant API at the base URL. The response is returned with
+// json_envelope.ErrJSONNotSigned when the miner didn't sign it.
 func SubmitTx(ctx context.Context, baseURL string,
 	request SubmitTxRequest) (*SubmitTxResponse, error) {
 
-	if len(baseURL) == 0 {
-		return nil, fmt.Errorf("Invalid Base URL : %s", baseURL)
-	}
-
-	if baseURL[len(baseURL)-1:] == "/" {
-		baseURL = baseURL[:len(baseURL)-1]
-	}
-
-	envelope := &json_envelope.JSONEnvelope{}
-	if err := post(ctx, baseURL+"/mapi/tx", request, envelope); err != nil {
-		return nil, errors.Wrap(err, "http post")
-	}
-
-	if envelope.MimeType != "application/json" {
-		return nil, fmt.Errorf("MIME Type not JSON : %s", envelope.MimeType)
-	}
-
-	result := &SubmitTxResponse{}
-	if err := json.Unmarshal([]byte(envelope.Payload), result); err != nil {
-		return nil, errors.Wrap(err, "json unmarshal")
-	}
-
-	if envelope.PublicKey != nil && !result.MinerID.Equal(*envelope.PublicKey) {
-		return nil, ErrWrongPublicKey
-	}
-
-	return result, envelope.Verify()
+	return NewClient(baseURL, "").SubmitTx(ctx, request)
 }
 
 type GetTxStatusResponse struct {
@@ -236,6 +230,9 @@ type GetTxStatusResponse struct {
 	BlockHeight            *uint32           `json:"blockHeight"`
 	Confirmations          *uint32           `json:"confirmations"`
 	SecondaryMempoolExpiry uint32            `json:"txSecondMempoolExpiry"`
+
+	// MerkleProof is included by miners that support it when the tx is confirmed.
+	MerkleProof json.RawMessage `json:"merkleProof,omitempty"`
 }
 
 func (str GetTxStatusResponse) Success() error {
@@ -246,90 +243,66 @@ func (str GetTxStatusResponse) Success() error {
 	return nil
 }
 
-// GetTxStatus returns the status of a tx. If it is confirmed it will return valid.
-func GetTxStatus(ctx context.Context, baseURL string,
-	txid bitcoin.Hash32) (*GetTxStatusResponse, error) {
-
-	if len(baseURL) == 0 {
-		return nil, fmt.Errorf("Invalid Base URL : %s", baseURL)
-	}
-
-	if baseURL[len(baseURL)-1:] == "/" {
-		baseURL = baseURL[:len(baseURL)-1]
-	}
-
-	envelope := &json_envelope.JSONEnvelope{}
-	if err := get(ctx, baseURL+"/mapi/tx/"+txid.String(), envelope); err != nil {
-		return nil, errors.Wrap(err, "http get")
-	}
+// IsConfirmed returns true if the tx is in a block.
+func (str GetTxStatusResponse) IsConfirmed() bool {
+	return str.BlockHash != nil && !str.BlockHash.IsZero()
+}
 
-	if envelope.MimeType != "application/json" {
-		return nil, fmt.Errorf("MIME Type not JSON : %s", envelope.MimeType)
+// ParseMerkleProof returns the merkle proof included in the status, or nil if there isn't one.
+func (str GetTxStatusResponse) ParseMerkleProof() (*merkle_proof.MerkleProof, error) {
+	if len(str.MerkleProof) == 0 || string(str.MerkleProof) == "null" {
+		return nil, nil
 	}
 
-	result := &GetTxStatusResponse{}
-	if err := json.Unmarshal([]byte(envelope.Payload), result); err != nil {
-		return nil, errors.Wrap(err, "json unmarshal")
+	result, err := merkle_proof.ParseMerkleProof(str.MerkleProof)
+	if err != nil {
+		return nil, errors.Wrap(err, "merkle proof")
 	}
 
-	if envelope.PublicKey != nil && !result.MinerID.Equal(*envelope.PublicKey) {
-		return nil, ErrWrongPublicKey
+	if str.TxID != nil && result.TxID != nil && !str.TxID.Equal(result.TxID) {
+		return nil, fmt.Errorf("Wrong merkle proof txid : got %s, want %s", result.TxID, str.TxID)
 	}
 
-	return result, envelope.Verify()
+	return result, nil
 }
 
-// post sends a request to the HTTP server using the POST method.
-func post(ctx context.Context, url string, request, response interface{}) error {
-	var transport = &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: 5 * time.Second,
-		}).Dial,
-		TLSHandshakeTimeout: 5 * time.Second,
-	}
+// GetTxStatus returns the status of a tx. If it is confirmed it will return valid.
+func GetTxStatus(ctx context.Context, baseURL string,
+	txid bitcoin.Hash32) (*GetTxStatusResponse, error) {
 
-	var client = &http.Client{
-		Timeout:   time.Second * 10,
-		Transport: transport,
-	}
+	return NewClient(baseURL, "").GetTxStatus(ctx, txid)
+}
 
+// post sends a request to the HTTP server using the POST method. The token is sent as an
+// authentication bearer token header when it isn't empty.
+func post(ctx context.Context, url, token string, request, response interface{}) error {
 	b, err := json.Marshal(request)
 	if err != nil {
 		return errors.Wrap(err, "marshal request")
 	}
 
-	httpResponse, err := client.Post(url, "application/json", bytes.NewReader(b))
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
 	if err != nil {
-		return err
+		return errors.Wrap(err, "create request")
 	}
+	httpRequest.Header.Add("Content-Type", "application/json")
 
-	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
-		if httpResponse.Body != nil {
-			b, rerr := ioutil.ReadAll(httpResponse.Body)
-			if rerr == nil {
-				return HTTPError{
-					Status:  httpResponse.StatusCode,
-					Message: string(b),
-				}
-			}
-		}
-
-		return HTTPError{Status: httpResponse.StatusCode}
-	}
-
-	defer httpResponse.Body.Close()
+	return send(httpRequest, token, response)
+}
 
-	if response != nil {
-		if err := json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
-			return errors.Wrap(err, "decode response")
-		}
+// get sends a request to the HTTP server using the GET method. The token is sent as an
+// authentication bearer token header when it isn't empty.
+func get(ctx context.Context, url, token string, response interface{}) error {
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
 	}
 
-	return nil
+	return send(httpRequest, token, response)
 }
 
-// get sends a request to the HTTP server using the GET method.
-func get(ctx context.Context, url string, response interface{}) error {
+// send sends a request to the HTTP server and decodes the JSON response.
+func send(httpRequest *http.Request, token string, response interface{}) error {
 	var transport = &http.Transport{
 		Dial: (&net.Dialer{
 			Timeout: 5 * time.Second,
@@ -342,7 +315,12 @@ func get(ctx context.Context, url string, response interface{}) error {
 		Transport: transport,
 	}
 
-	httpResponse, err := client.Get(url)
+	if len(token) > 0 {
+		// Authorization: Bearer <token>
+		httpRequest.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	httpResponse, err := client.Do(httpRequest)
 	if err != nil {
 		return err
 	}
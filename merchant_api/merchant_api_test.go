@@ -92,3 +92,48 @@ func TestGetTxStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestTxFeeQuote(t *testing.T) {
+	response := FeeQuoteResponse{
+		Fees: []*FeeQuote{
+			{
+				FeeType:   FeeQuoteTypeStandard,
+				MiningFee: Fee{Satoshis: 500, Bytes: 1000},
+				RelayFee:  Fee{Satoshis: 250, Bytes: 1000},
+			},
+		},
+	}
+
+	quote, err := response.TxFeeQuote()
+	if err != nil {
+		t.Fatalf("Failed to convert fee quote : %s", err)
+	}
+
+	if quote.DataMiningFee != quote.MiningFee || quote.DataRelayFee != quote.RelayFee {
+		t.Fatalf("Data fees don't default to standard fees : %+v", quote)
+	}
+
+	if quote.Fee(1000, 0) != 500 {
+		t.Fatalf("Wrong fee : got %d, want %d", quote.Fee(1000, 0), 500)
+	}
+
+	response.Fees = append(response.Fees, &FeeQuote{
+		FeeType:   FeeQuoteTypeData,
+		MiningFee: Fee{Satoshis: 50, Bytes: 1000},
+		RelayFee:  Fee{Satoshis: 25, Bytes: 1000},
+	})
+
+	quote, err = response.TxFeeQuote()
+	if err != nil {
+		t.Fatalf("Failed to convert fee quote : %s", err)
+	}
+
+	if quote.Fee(1000, 1000) != 550 {
+		t.Fatalf("Wrong fee : got %d, want %d", quote.Fee(1000, 1000), 550)
+	}
+
+	response.Fees = response.Fees[1:]
+	if _, err := response.TxFeeQuote(); err == nil {
+		t.Fatalf("Converted fee quote without standard fee")
+	}
+}
@@ -0,0 +1,243 @@
+package merchant_api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json_envelope"
+	"github.com/tokenized/pkg/miner_id"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// minMinerIDRefresh is the minimum time between fetches caused by messages signed by unknown
+	// miner IDs, so invalid messages can't cause a fetch every time.
+	minMinerIDRefresh = time.Minute
+)
+
+var (
+	// ErrMinerIDNotLinked means a miner ID document contains a miner ID that isn't linked to the
+	// current miner ID by a valid rotation signature.
+	ErrMinerIDNotLinked = errors.New("Miner ID Not Linked")
+
+	// ErrRevokedMinerID means a miner ID was replaced by a rotation and is no longer valid.
+	ErrRevokedMinerID = errors.New("Revoked Miner ID")
+)
+
+// MinerIDClient fetches and caches a miner's miner ID document, so that messages like mAPI
+// responses and call backs can be verified as signed by the miner's current miner ID, even when
+// the miner rotates its key. It starts with a trusted miner ID and only accepts a new miner ID when
+// the document links it to the current one with a valid previous miner ID signature. Miner IDs that
+// are rotated away from are revoked.
+//
+// The document is a JSON envelope containing a miner ID document that is signed by the miner ID in
+// it.
+type MinerIDClient struct {
+	url           string
+	cacheDuration time.Duration
+
+	current  bitcoin.PublicKey
+	document *miner_id.MinerID
+	fetched  time.Time
+	revoked  []bitcoin.PublicKey
+
+	fetchLock sync.Mutex // only one fetch at a time
+
+	sync.Mutex
+}
+
+// NewMinerIDClient creates a miner ID client that fetches the miner ID document from the URL. The
+// miner ID is the trusted miner ID of the miner. The document is fetched again when it is older
+// than the cache duration.
+func NewMinerIDClient(url string, minerID bitcoin.PublicKey,
+	cacheDuration time.Duration) *MinerIDClient {
+
+	return &MinerIDClient{
+		url:           url,
+		cacheDuration: cacheDuration,
+		current:       minerID,
+	}
+}
+
+// MinerID returns the current miner ID without fetching the document.
+func (c *MinerIDClient) MinerID() bitcoin.PublicKey {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.current
+}
+
+// Revoked returns the miner IDs that were replaced by rotations.
+func (c *MinerIDClient) Revoked() []bitcoin.PublicKey {
+	c.Lock()
+	defer c.Unlock()
+
+	return append([]bitcoin.PublicKey(nil), c.revoked...)
+}
+
+// Document returns the miner ID document, fetching it if it hasn't been fetched or is older than
+// the cache duration.
+func (c *MinerIDClient) Document(ctx context.Context) (*miner_id.MinerID, error) {
+	c.Lock()
+	document := c.document
+	fetched := c.fetched
+	c.Unlock()
+
+	if document != nil && time.Since(fetched) < c.cacheDuration {
+		return document, nil
+	}
+
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	return c.document, nil
+}
+
+// Refresh fetches and validates the miner ID document. When the document contains a new miner ID
+// that is linked to the current miner ID, the new miner ID becomes current and the previous one is
+// revoked.
+func (c *MinerIDClient) Refresh(ctx context.Context) error {
+	c.fetchLock.Lock()
+	defer c.fetchLock.Unlock()
+
+	envelope := &json_envelope.JSONEnvelope{}
+	if err := get(ctx, c.url, "", envelope); err != nil {
+		return errors.Wrap(err, "get")
+	}
+
+	document := &miner_id.MinerID{}
+	if err := envelope.Unmarshal(document); err != nil {
+		return errors.Wrap(err, "unmarshal")
+	}
+
+	// The document must be signed by the miner ID in it to show that the miner has its key.
+	if envelope.PublicKey == nil {
+		return json_envelope.ErrJSONNotSigned
+	}
+	if !document.MinerID.Equal(*envelope.PublicKey) {
+		return ErrWrongPublicKey
+	}
+	if err := envelope.Verify(); err != nil {
+		return errors.Wrap(err, "verify")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.validateDocument(document); err != nil {
+		return err
+	}
+
+	if !document.MinerID.Equal(c.current) {
+		c.revoked = append(c.revoked, c.current)
+		c.current = document.MinerID
+	}
+
+	c.document = document
+	c.fetched = time.Now()
+	return nil
+}
+
+// Verify returns nil if the miner ID is the miner's current miner ID. When the miner ID is unknown
+// the document is fetched again in case the miner rotated its key. It returns ErrRevokedMinerID if
+// the miner ID was rotated away from and ErrWrongMinerID if it isn't the miner's.
+func (c *MinerIDClient) Verify(ctx context.Context, minerID bitcoin.PublicKey) error {
+	c.Lock()
+	known, err := c.check(minerID)
+	fetched := c.fetched
+	c.Unlock()
+
+	if known || err != nil {
+		return err
+	}
+
+	if time.Since(fetched) < minMinerIDRefresh {
+		return errors.Wrapf(ErrWrongMinerID, "got %s, want %s", minerID, c.MinerID())
+	}
+
+	if err := c.Refresh(ctx); err != nil {
+		return errors.Wrap(err, "refresh")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	known, err = c.check(minerID)
+	if err != nil {
+		return err
+	}
+	if !known {
+		return errors.Wrapf(ErrWrongMinerID, "got %s, want %s", minerID, c.current)
+	}
+	return nil
+}
+
+// VerifyEnvelope verifies the envelope's signature and that it was signed by the miner's current
+// miner ID.
+func (c *MinerIDClient) VerifyEnvelope(ctx context.Context,
+	envelope *json_envelope.JSONEnvelope) error {
+
+	if envelope.PublicKey == nil {
+		return json_envelope.ErrJSONNotSigned
+	}
+
+	if err := c.Verify(ctx, *envelope.PublicKey); err != nil {
+		return err
+	}
+
+	return envelope.Verify()
+}
+
+// check returns true if the miner ID is current and an error if it is revoked. The lock must be
+// held.
+func (c *MinerIDClient) check(minerID bitcoin.PublicKey) (bool, error) {
+	if minerID.Equal(c.current) {
+		return true, nil
+	}
+
+	for _, revoked := range c.revoked {
+		if minerID.Equal(revoked) {
+			return false, errors.Wrap(ErrRevokedMinerID, minerID.String())
+		}
+	}
+
+	return false, nil
+}
+
+// validateDocument checks that the document's miner ID is current or linked to the current miner
+// ID. The lock must be held.
+func (c *MinerIDClient) validateDocument(document *miner_id.MinerID) error {
+	if _, err := c.check(document.MinerID); err != nil {
+		return err
+	}
+
+	if document.MinerID.Equal(c.current) {
+		if document.PreviousMinerID != nil && document.ValidityCheckTx != nil {
+			if err := document.VerifyPrevious(); err != nil {
+				return errors.Wrap(err, "previous")
+			}
+		}
+		return nil
+	}
+
+	// Rotated to a new miner ID.
+	if document.PreviousMinerID == nil || !document.PreviousMinerID.Equal(c.current) {
+		return errors.Wrapf(ErrMinerIDNotLinked, "%s", document.MinerID)
+	}
+
+	if document.ValidityCheckTx == nil {
+		return errors.Wrap(ErrMinerIDNotLinked, "missing validity check tx")
+	}
+
+	if err := document.VerifyPrevious(); err != nil {
+		return errors.Wrap(ErrMinerIDNotLinked, err.Error())
+	}
+
+	return nil
+}
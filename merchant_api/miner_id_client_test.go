@@ -0,0 +1,143 @@
+package merchant_api
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/miner_id"
+
+	"github.com/pkg/errors"
+)
+
+// minerIDDocument returns a miner ID document for the key that is linked to the previous key.
+func minerIDDocument(t *testing.T, key, previous bitcoin.Key) *miner_id.MinerID {
+	previousMinerID := previous.PublicKey()
+	result := &miner_id.MinerID{
+		Version:         "0.1",
+		PreviousMinerID: &previousMinerID,
+		MinerID:         key.PublicKey(),
+		ValidityCheckTx: &miner_id.MinerIDValidityCheckTx{TxID: bitcoin.Hash32{1}},
+	}
+
+	prevCheck := result.PreviousMinerID.String() + result.MinerID.String() +
+		result.ValidityCheckTx.TxID.String()
+	signature, err := previous.Sign(bitcoin.Hash32(sha256.Sum256([]byte(prevCheck))))
+	if err != nil {
+		t.Fatalf("Failed to sign previous miner id : %s", err)
+	}
+	result.PreviousMinerIDSig = &signature
+
+	return result
+}
+
+func TestMinerIDClient(t *testing.T) {
+	ctx := context.Background()
+
+	var keys []bitcoin.Key
+	for i := 0; i < 3; i++ {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		keys = append(keys, key)
+	}
+
+	var lock sync.Mutex
+	documentKey := keys[0]
+	document := minerIDDocument(t, keys[0], keys[0])
+	fetchCount := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch r.URL.Path {
+		case "/minerid":
+			fetchCount++
+			json.NewEncoder(w).Encode(signedEnvelope(t, documentKey, document))
+
+		case "/mapi/feeQuote":
+			json.NewEncoder(w).Encode(signedEnvelope(t, documentKey, FeeQuoteResponse{
+				MinerID: documentKey.PublicKey(),
+			}))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	minerIDClient := NewMinerIDClient(server.URL+"/minerid", keys[0].PublicKey(), time.Hour)
+
+	if _, err := minerIDClient.Document(ctx); err != nil {
+		t.Fatalf("Failed to get document : %s", err)
+	}
+	if _, err := minerIDClient.Document(ctx); err != nil {
+		t.Fatalf("Failed to get document : %s", err)
+	}
+	if fetchCount != 1 {
+		t.Fatalf("Document should be cached : fetched %d times", fetchCount)
+	}
+
+	if err := minerIDClient.Verify(ctx, keys[0].PublicKey()); err != nil {
+		t.Fatalf("Failed to verify miner id : %s", err)
+	}
+
+	// Rotate to the second key.
+	lock.Lock()
+	documentKey = keys[1]
+	document = minerIDDocument(t, keys[1], keys[0])
+	lock.Unlock()
+
+	if err := minerIDClient.Refresh(ctx); err != nil {
+		t.Fatalf("Failed to refresh : %s", err)
+	}
+
+	if !minerIDClient.MinerID().Equal(keys[1].PublicKey()) {
+		t.Fatalf("Wrong miner id : got %s, want %s", minerIDClient.MinerID(),
+			keys[1].PublicKey())
+	}
+
+	if err := minerIDClient.Verify(ctx, keys[0].PublicKey()); errors.Cause(err) !=
+		ErrRevokedMinerID {
+		t.Fatalf("Wrong error for rotated miner id : got %v, want %s", err, ErrRevokedMinerID)
+	}
+
+	client := NewClient(server.URL, "")
+	client.SetMinerIDClient(minerIDClient)
+	if _, err := client.GetFeeQuote(ctx); err != nil {
+		t.Fatalf("Failed to get fee quote : %s", err)
+	}
+
+	// A new miner ID that isn't linked to the current miner ID.
+	lock.Lock()
+	documentKey = keys[2]
+	document = minerIDDocument(t, keys[2], keys[2])
+	lock.Unlock()
+
+	if err := minerIDClient.Refresh(ctx); errors.Cause(err) != ErrMinerIDNotLinked {
+		t.Fatalf("Wrong refresh error : got %v, want %s", err, ErrMinerIDNotLinked)
+	}
+
+	if _, err := client.GetFeeQuote(ctx); errors.Cause(err) != ErrWrongMinerID {
+		t.Fatalf("Wrong fee quote error : got %v, want %s", err, ErrWrongMinerID)
+	}
+
+	// A new client learns of the rotation when it receives a message signed by the new miner ID.
+	lock.Lock()
+	documentKey = keys[1]
+	document = minerIDDocument(t, keys[1], keys[0])
+	lock.Unlock()
+
+	newClient := NewMinerIDClient(server.URL+"/minerid", keys[0].PublicKey(), time.Hour)
+	if err := newClient.Verify(ctx, keys[1].PublicKey()); err != nil {
+		t.Fatalf("Failed to verify rotated miner id : %s", err)
+	}
+}
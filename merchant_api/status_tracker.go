@@ -0,0 +1,355 @@
+package merchant_api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/storage"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultStatusTrackerPath is the storage path that the status tracker's pending txs are saved
+	// to.
+	DefaultStatusTrackerPath = "merchant_api/status_tracker"
+)
+
+// TxStatusSource provides the status of txs. Client implements it to poll mAPI. An adapter for a
+// node's RPC interface can be used instead.
+type TxStatusSource interface {
+	GetTxStatus(ctx context.Context, txid bitcoin.Hash32) (*GetTxStatusResponse, error)
+}
+
+// TxStatusHandler is notified when txs tracked by a StatusTracker reach a final state.
+type TxStatusHandler interface {
+	// HandleTxConfirmed is called when a tx is in a block with the required confirmations.
+	HandleTxConfirmed(ctx context.Context, txid bitcoin.Hash32, status *GetTxStatusResponse) error
+
+	// HandleTxMerkleProof is called when a merkle proof is available for a confirmed tx.
+	HandleTxMerkleProof(ctx context.Context, txid bitcoin.Hash32,
+		proof *merkle_proof.MerkleProof) error
+
+	// HandleTxRejected is called when a tx is still not known by the miner after the rejection
+	// timeout. status is the last failure response, or nil if the last request failed.
+	HandleTxRejected(ctx context.Context, txid bitcoin.Hash32, status *GetTxStatusResponse) error
+}
+
+// StatusTrackerConfig configures a status tracker.
+type StatusTrackerConfig struct {
+	// RequiredConfirmations is the number of confirmations before a tx is considered confirmed.
+	RequiredConfirmations uint32
+
+	// MinDelay is the delay before the first status request and the initial backoff delay.
+	MinDelay time.Duration
+
+	// MaxDelay is the longest delay between status requests for a tx.
+	MaxDelay time.Duration
+
+	// RejectTimeout is how long after a tx is added that failure responses mean the tx was
+	// rejected. Failures before then are retried since the miner might not have seen the tx yet.
+	RejectTimeout time.Duration
+
+	// WaitForMerkleProof keeps polling confirmed txs until a merkle proof is available.
+	WaitForMerkleProof bool
+}
+
+// DefaultStatusTrackerConfig returns a status tracker config with reasonable values.
+func DefaultStatusTrackerConfig() StatusTrackerConfig {
+	return StatusTrackerConfig{
+		RequiredConfirmations: 1,
+		MinDelay:              10 * time.Second,
+		MaxDelay:              10 * time.Minute,
+		RejectTimeout:         time.Hour,
+		WaitForMerkleProof:    false,
+	}
+}
+
+// StatusTracker polls the status of txs with exponential backoff until they are confirmed, or
+// rejected, and notifies a handler. The set of pending txs can be saved to storage so tracking
+// continues after a restart.
+type StatusTracker struct {
+	source  TxStatusSource
+	handler TxStatusHandler
+	config  StatusTrackerConfig
+
+	store storage.Storage
+	path  string
+
+	txs map[bitcoin.Hash32]*trackedTx
+
+	lock sync.Mutex
+}
+
+// trackedTx is the state of a tx being tracked.
+type trackedTx struct {
+	TxID      bitcoin.Hash32 `json:"txid"`
+	Added     time.Time      `json:"added"`
+	Attempts  uint           `json:"attempts"`
+	NextCheck time.Time      `json:"next_check"`
+	Confirmed bool           `json:"confirmed"` // HandleTxConfirmed has been called
+}
+
+// NewStatusTracker creates a status tracker. The store is optional and, when provided, the
+// pending txs are saved to it at the path.
+func NewStatusTracker(config StatusTrackerConfig, source TxStatusSource,
+	handler TxStatusHandler, store storage.Storage, path string) *StatusTracker {
+
+	return &StatusTracker{
+		source:  source,
+		handler: handler,
+		config:  config,
+		store:   store,
+		path:    path,
+		txs:     make(map[bitcoin.Hash32]*trackedTx),
+	}
+}
+
+// Add starts tracking a tx.
+func (t *StatusTracker) Add(ctx context.Context, txid bitcoin.Hash32) error {
+	now := time.Now()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, exists := t.txs[txid]; exists {
+		return nil
+	}
+
+	t.txs[txid] = &trackedTx{
+		TxID:      txid,
+		Added:     now,
+		NextCheck: now.Add(t.config.MinDelay),
+	}
+
+	return t.save(ctx)
+}
+
+// Remove stops tracking a tx.
+func (t *StatusTracker) Remove(ctx context.Context, txid bitcoin.Hash32) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, exists := t.txs[txid]; !exists {
+		return nil
+	}
+
+	delete(t.txs, txid)
+	return t.save(ctx)
+}
+
+// Pending returns the txids of the txs being tracked.
+func (t *StatusTracker) Pending() []bitcoin.Hash32 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	result := make([]bitcoin.Hash32, 0, len(t.txs))
+	for txid := range t.txs {
+		result = append(result, txid)
+	}
+	return result
+}
+
+// Run checks the status of txs as they become due until the interrupt is closed. The pending txs
+// are saved when it returns.
+func (t *StatusTracker) Run(ctx context.Context, interrupt <-chan interface{}) error {
+	interval := t.config.MinDelay
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Check(ctx); err != nil {
+			return errors.Wrap(err, "check")
+		}
+
+		select {
+		case <-interrupt:
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			return t.save(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check requests the status of the txs that are due to be checked and notifies the handler of the
+// txs that reached a final state. Txs that aren't final are checked again after a delay that
+// doubles after every check, up to the maximum delay.
+func (t *StatusTracker) Check(ctx context.Context) error {
+	now := time.Now()
+
+	t.lock.Lock()
+	var due []trackedTx
+	for _, tx := range t.txs {
+		if !now.Before(tx.NextCheck) {
+			due = append(due, *tx)
+		}
+	}
+	t.lock.Unlock()
+
+	if len(due) == 0 {
+		return nil
+	}
+
+	for i := range due {
+		tx := &due[i]
+		done := t.check(ctx, tx)
+
+		t.lock.Lock()
+		if _, exists := t.txs[tx.TxID]; exists { // not removed during the check
+			if done {
+				delete(t.txs, tx.TxID)
+			} else {
+				tx.Attempts++
+				tx.NextCheck = time.Now().Add(t.delay(tx.Attempts))
+				t.txs[tx.TxID] = tx
+			}
+		}
+		t.lock.Unlock()
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.save(ctx)
+}
+
+// check requests the status of a tx and notifies the handler. It returns true when the tx is
+// final and no longer needs to be tracked.
+func (t *StatusTracker) check(ctx context.Context, tx *trackedTx) bool {
+	status, err := t.source.GetTxStatus(ctx, tx.TxID)
+	if err == nil {
+		err = status.Success()
+	} else {
+		status = nil
+	}
+
+	if err != nil {
+		if time.Since(tx.Added) < t.config.RejectTimeout || IsTransient(err) {
+			logger.VerboseWithFields(ctx, []logger.Field{
+				logger.Stringer("txid", tx.TxID),
+			}, "Tx status not available : %s", err)
+			return false
+		}
+
+		if err := t.handler.HandleTxRejected(ctx, tx.TxID, status); err != nil {
+			logger.ErrorWithFields(ctx, []logger.Field{
+				logger.Stringer("txid", tx.TxID),
+			}, "Failed to handle rejected tx : %s", err)
+			return false
+		}
+		return true
+	}
+
+	if !status.IsConfirmed() || status.Confirmations == nil ||
+		*status.Confirmations < t.config.RequiredConfirmations {
+		return false
+	}
+
+	if !tx.Confirmed {
+		if err := t.handler.HandleTxConfirmed(ctx, tx.TxID, status); err != nil {
+			logger.ErrorWithFields(ctx, []logger.Field{
+				logger.Stringer("txid", tx.TxID),
+			}, "Failed to handle confirmed tx : %s", err)
+			return false
+		}
+		tx.Confirmed = true
+	}
+
+	proof, err := status.ParseMerkleProof()
+	if err != nil {
+		logger.WarnWithFields(ctx, []logger.Field{
+			logger.Stringer("txid", tx.TxID),
+		}, "Invalid tx status merkle proof : %s", err)
+	} else if proof != nil {
+		if err := t.handler.HandleTxMerkleProof(ctx, tx.TxID, proof); err != nil {
+			logger.ErrorWithFields(ctx, []logger.Field{
+				logger.Stringer("txid", tx.TxID),
+			}, "Failed to handle merkle proof : %s", err)
+			return false
+		}
+		return true
+	}
+
+	return !t.config.WaitForMerkleProof
+}
+
+// delay returns the delay before the next check after the number of attempts.
+func (t *StatusTracker) delay(attempts uint) time.Duration {
+	result := t.config.MinDelay
+	for i := uint(0); i < attempts; i++ {
+		result *= 2
+		if result >= t.config.MaxDelay {
+			return t.config.MaxDelay
+		}
+	}
+	return result
+}
+
+// Load reads the pending txs from storage.
+func (t *StatusTracker) Load(ctx context.Context) error {
+	if t.store == nil {
+		return nil
+	}
+
+	b, err := t.store.Read(ctx, t.path)
+	if err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return nil
+		}
+		return errors.Wrap(err, "read")
+	}
+
+	var txs []*trackedTx
+	if err := json.Unmarshal(b, &txs); err != nil {
+		return errors.Wrap(err, "unmarshal")
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, tx := range txs {
+		t.txs[tx.TxID] = tx
+	}
+
+	return nil
+}
+
+// Save writes the pending txs to storage.
+func (t *StatusTracker) Save(ctx context.Context) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.save(ctx)
+}
+
+// save writes the pending txs to storage. The lock must be held.
+func (t *StatusTracker) save(ctx context.Context) error {
+	if t.store == nil {
+		return nil
+	}
+
+	txs := make([]*trackedTx, 0, len(t.txs))
+	for _, tx := range t.txs {
+		txs = append(txs, tx)
+	}
+
+	b, err := json.Marshal(txs)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	if err := t.store.Write(ctx, t.path, b, nil); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
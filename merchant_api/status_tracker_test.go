@@ -0,0 +1,189 @@
+package merchant_api
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/storage"
+)
+
+type mockStatusSource struct {
+	statuses map[bitcoin.Hash32]*GetTxStatusResponse
+	requests map[bitcoin.Hash32]int
+	lock     sync.Mutex
+}
+
+func (s *mockStatusSource) GetTxStatus(ctx context.Context,
+	txid bitcoin.Hash32) (*GetTxStatusResponse, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests[txid]++
+	if status, exists := s.statuses[txid]; exists {
+		return status, nil
+	}
+
+	return &GetTxStatusResponse{
+		TxID:   &txid,
+		Result: "failure",
+	}, nil
+}
+
+func (s *mockStatusSource) set(txid bitcoin.Hash32, status *GetTxStatusResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.statuses[txid] = status
+}
+
+type mockStatusHandler struct {
+	confirmed []bitcoin.Hash32
+	proofs    []bitcoin.Hash32
+	rejected  []bitcoin.Hash32
+}
+
+func (h *mockStatusHandler) HandleTxConfirmed(ctx context.Context, txid bitcoin.Hash32,
+	status *GetTxStatusResponse) error {
+
+	h.confirmed = append(h.confirmed, txid)
+	return nil
+}
+
+func (h *mockStatusHandler) HandleTxMerkleProof(ctx context.Context, txid bitcoin.Hash32,
+	proof *merkle_proof.MerkleProof) error {
+
+	h.proofs = append(h.proofs, txid)
+	return nil
+}
+
+func (h *mockStatusHandler) HandleTxRejected(ctx context.Context, txid bitcoin.Hash32,
+	status *GetTxStatusResponse) error {
+
+	h.rejected = append(h.rejected, txid)
+	return nil
+}
+
+func TestStatusTracker(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+
+	source := &mockStatusSource{
+		statuses: make(map[bitcoin.Hash32]*GetTxStatusResponse),
+		requests: make(map[bitcoin.Hash32]int),
+	}
+	handler := &mockStatusHandler{}
+
+	config := StatusTrackerConfig{
+		RequiredConfirmations: 1,
+		MinDelay:              time.Millisecond,
+		MaxDelay:              4 * time.Millisecond,
+		RejectTimeout:         50 * time.Millisecond,
+		WaitForMerkleProof:    true,
+	}
+
+	tracker := NewStatusTracker(config, source, handler, store, DefaultStatusTrackerPath)
+
+	confirmedTxID := bitcoin.Hash32{1}
+	rejectedTxID := bitcoin.Hash32{2}
+
+	if err := tracker.Add(ctx, confirmedTxID); err != nil {
+		t.Fatalf("Failed to add tx : %s", err)
+	}
+	if err := tracker.Add(ctx, rejectedTxID); err != nil {
+		t.Fatalf("Failed to add tx : %s", err)
+	}
+
+	// Pending txs are restored by a new tracker.
+	restored := NewStatusTracker(config, source, handler, store, DefaultStatusTrackerPath)
+	if err := restored.Load(ctx); err != nil {
+		t.Fatalf("Failed to load tracker : %s", err)
+	}
+	if len(restored.Pending()) != 2 {
+		t.Fatalf("Wrong restored pending count : got %d, want %d", len(restored.Pending()), 2)
+	}
+
+	checkUntil := func(done func() bool) {
+		for i := 0; i < 200 && !done(); i++ {
+			time.Sleep(time.Millisecond)
+			if err := tracker.Check(ctx); err != nil {
+				t.Fatalf("Failed to check : %s", err)
+			}
+		}
+	}
+
+	// Confirmed without a merkle proof.
+	blockHash := bitcoin.Hash32{3}
+	confirmations := uint32(1)
+	source.set(confirmedTxID, &GetTxStatusResponse{
+		TxID:          &confirmedTxID,
+		Result:        "success",
+		BlockHash:     &blockHash,
+		Confirmations: &confirmations,
+	})
+
+	checkUntil(func() bool { return len(handler.confirmed) > 0 })
+	if len(handler.confirmed) != 1 || !handler.confirmed[0].Equal(&confirmedTxID) {
+		t.Fatalf("Wrong confirmed txs : %v", handler.confirmed)
+	}
+
+	// Still tracked while waiting for a merkle proof.
+	if len(tracker.Pending()) != 2 {
+		t.Fatalf("Wrong pending count : got %d, want %d", len(tracker.Pending()), 2)
+	}
+
+	// Merkle proof becomes available.
+	proof := &merkle_proof.MerkleProof{
+		TxID:       &confirmedTxID,
+		Path:       []bitcoin.Hash32{{4}},
+		BlockHash:  &blockHash,
+		MerkleRoot: &bitcoin.Hash32{5},
+	}
+	proofJSON, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("Failed to marshal proof : %s", err)
+	}
+	source.set(confirmedTxID, &GetTxStatusResponse{
+		TxID:          &confirmedTxID,
+		Result:        "success",
+		BlockHash:     &blockHash,
+		Confirmations: &confirmations,
+		MerkleProof:   proofJSON,
+	})
+
+	checkUntil(func() bool { return len(handler.proofs) > 0 })
+	if len(handler.proofs) != 1 || !handler.proofs[0].Equal(&confirmedTxID) {
+		t.Fatalf("Wrong merkle proof txs : %v", handler.proofs)
+	}
+	if len(handler.confirmed) != 1 {
+		t.Fatalf("Confirmed handled more than once : %d", len(handler.confirmed))
+	}
+
+	// The unknown tx is rejected after the timeout.
+	checkUntil(func() bool { return len(handler.rejected) > 0 })
+	if len(handler.rejected) != 1 || !handler.rejected[0].Equal(&rejectedTxID) {
+		t.Fatalf("Wrong rejected txs : %v", handler.rejected)
+	}
+
+	if len(tracker.Pending()) != 0 {
+		t.Fatalf("Wrong pending count : got %d, want %d", len(tracker.Pending()), 0)
+	}
+
+	// Backoff means far fewer requests than checks.
+	if source.requests[rejectedTxID] > 30 {
+		t.Fatalf("Too many status requests : %d", source.requests[rejectedTxID])
+	}
+
+	restored = NewStatusTracker(config, source, handler, store, DefaultStatusTrackerPath)
+	if err := restored.Load(ctx); err != nil {
+		t.Fatalf("Failed to load tracker : %s", err)
+	}
+	if len(restored.Pending()) != 0 {
+		t.Fatalf("Wrong restored pending count : got %d, want %d", len(restored.Pending()), 0)
+	}
+}
@@ -0,0 +1,554 @@
+package merkle_proof
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// BUMP (BSV Unified Merkle Path) is the compound merkle path format specified by BRC-74. One BUMP
+// contains the merkle paths of any number of txs in the same block. Hashes that are shared between
+// paths are only included once.
+//
+// https://brc.dev/74
+
+const (
+	BUMPFlagHash      = uint8(0x00) // Hash follows
+	BUMPFlagDuplicate = uint8(0x01) // Duplicate of the working hash, no hash follows
+	BUMPFlagTxID      = uint8(0x02) // Hash of a tx the path is for follows
+)
+
+var (
+	ErrMissingNode    = errors.New("Missing Node")
+	ErrDifferentBlock = errors.New("Different Block")
+)
+
+// BUMP is a compound merkle path for txs in one block. Path contains one level for each level of
+// the merkle tree, starting at the txids. Each level contains the leaves needed to calculate the
+// merkle root from the txids.
+type BUMP struct {
+	BlockHeight uint64
+	Path        [][]*BUMPLeaf
+}
+
+// BUMPLeaf is a node in one level of a BUMP. Offset is the position of the node in its level of the
+// merkle tree. Hash is nil when the node is a duplicate.
+type BUMPLeaf struct {
+	Offset    uint64
+	Hash      *bitcoin.Hash32
+	TxID      bool
+	Duplicate bool
+}
+
+// NewBUMPFromMerkleProofs creates a BUMP containing the paths of merkle proofs for txs in the
+// block at the specified height.
+func NewBUMPFromMerkleProofs(blockHeight uint64, proofs []*MerkleProof) (*BUMP, error) {
+	result := &BUMP{
+		BlockHeight: blockHeight,
+	}
+
+	for i, proof := range proofs {
+		if err := result.AddMerkleProof(proof); err != nil {
+			return nil, errors.Wrapf(err, "proof %d", i)
+		}
+	}
+
+	return result, nil
+}
+
+// AddMerkleProof adds the path of a merkle proof to the BUMP. The proof must be for a tx in the
+// same block as the other paths in the BUMP.
+func (b *BUMP) AddMerkleProof(proof *MerkleProof) error {
+	if proof.TxID == nil {
+		return ErrMissingTxID
+	}
+	if proof.Index < 0 {
+		return ErrBadIndex
+	}
+
+	offset := uint64(proof.Index)
+	b.addLeaf(0, &BUMPLeaf{
+		Offset: offset,
+		Hash:   proof.TxID,
+		TxID:   true,
+	})
+
+	layer := 1
+	path := proof.Path
+	duplicateIndexes := proof.DuplicatedIndexes
+	for {
+		sibling := &BUMPLeaf{
+			Offset: offset ^ 1,
+		}
+
+		if len(duplicateIndexes) > 0 && layer == duplicateIndexes[0] {
+			duplicateIndexes = duplicateIndexes[1:]
+			sibling.Duplicate = true
+		} else {
+			if len(path) == 0 {
+				break
+			}
+			hash := path[0]
+			path = path[1:]
+			sibling.Hash = &hash
+		}
+
+		b.addLeaf(layer-1, sibling)
+
+		offset = offset >> 1
+		layer++
+	}
+
+	return nil
+}
+
+// addLeaf adds a leaf to a level if there isn't already a leaf at its offset. A tx leaf replaces
+// a hash leaf for the same node.
+func (b *BUMP) addLeaf(level int, leaf *BUMPLeaf) {
+	for len(b.Path) <= level {
+		b.Path = append(b.Path, nil)
+	}
+
+	for i, existing := range b.Path[level] {
+		if existing.Offset == leaf.Offset {
+			if leaf.TxID && !existing.TxID {
+				b.Path[level][i] = leaf
+			}
+			return
+		}
+
+		if existing.Offset > leaf.Offset {
+			b.Path[level] = append(b.Path[level], nil)
+			copy(b.Path[level][i+1:], b.Path[level][i:])
+			b.Path[level][i] = leaf
+			return
+		}
+	}
+
+	b.Path[level] = append(b.Path[level], leaf)
+}
+
+// TxIDs returns the txids that the BUMP contains paths for.
+func (b BUMP) TxIDs() []bitcoin.Hash32 {
+	if len(b.Path) == 0 {
+		return nil
+	}
+
+	var result []bitcoin.Hash32
+	for _, leaf := range b.Path[0] {
+		if leaf.TxID && leaf.Hash != nil {
+			result = append(result, *leaf.Hash)
+		}
+	}
+	return result
+}
+
+// CalculateRoot calculates the merkle root from the path of the specified txid.
+func (b BUMP) CalculateRoot(txid bitcoin.Hash32) (bitcoin.Hash32, error) {
+	if len(b.Path) == 0 {
+		return bitcoin.Hash32{}, ErrMissingTxID
+	}
+
+	var offset uint64
+	found := false
+	for _, leaf := range b.Path[0] {
+		if leaf.Hash != nil && leaf.Hash.Equal(&txid) {
+			offset = leaf.Offset
+			found = true
+			break
+		}
+	}
+	if !found {
+		return bitcoin.Hash32{}, ErrMissingTxID
+	}
+
+	// A block with only one tx has a merkle root equal to the txid.
+	if len(b.Path) == 1 && len(b.Path[0]) == 1 && offset == 0 {
+		return txid, nil
+	}
+
+	levels := b.levels()
+	hash := txid
+	for level := range b.Path {
+		siblingOffset := offset ^ 1
+		sibling, err := nodeHash(levels, level, siblingOffset, hash)
+		if err != nil {
+			return bitcoin.Hash32{}, errors.Wrapf(err, "level %d", level)
+		}
+
+		if offset%2 == 0 {
+			hash = hashPair(hash, sibling)
+		} else {
+			if sibling.Equal(&hash) {
+				return bitcoin.Hash32{}, ErrBadIndex // right hash can't be duplicate
+			}
+			hash = hashPair(sibling, hash)
+		}
+
+		offset = offset >> 1
+	}
+
+	return hash, nil
+}
+
+// Verify returns an error if the paths of any of the txids in the BUMP don't calculate to the
+// merkle root.
+func (b BUMP) Verify(merkleRoot bitcoin.Hash32) error {
+	txids := b.TxIDs()
+	if len(txids) == 0 {
+		return ErrMissingTxID
+	}
+
+	for _, txid := range txids {
+		root, err := b.CalculateRoot(txid)
+		if err != nil {
+			return errors.Wrapf(err, "calculate root %s", txid)
+		}
+
+		if !root.Equal(&merkleRoot) {
+			return errors.Wrapf(ErrWrongMerkleRoot, "txid %s", txid)
+		}
+	}
+
+	return nil
+}
+
+// Merge adds the paths from another BUMP for the same block.
+func (b *BUMP) Merge(other *BUMP) error {
+	if b.BlockHeight != other.BlockHeight {
+		return errors.Wrapf(ErrDifferentBlock, "height %d != %d", b.BlockHeight,
+			other.BlockHeight)
+	}
+
+	if len(b.TxIDs()) > 0 && len(other.TxIDs()) > 0 {
+		root, err := b.CalculateRoot(b.TxIDs()[0])
+		if err != nil {
+			return errors.Wrap(err, "calculate root")
+		}
+
+		if err := other.Verify(root); err != nil {
+			return errors.Wrapf(ErrDifferentBlock, "verify : %s", err)
+		}
+	}
+
+	for level, leaves := range other.Path {
+		for _, leaf := range leaves {
+			b.addLeaf(level, leaf)
+		}
+	}
+
+	return nil
+}
+
+// MerkleProof returns a merkle proof for the specified txid from the paths in the BUMP. The proof
+// has no target because the BUMP only specifies the block height.
+func (b BUMP) MerkleProof(txid bitcoin.Hash32) (*MerkleProof, error) {
+	if _, err := b.CalculateRoot(txid); err != nil {
+		return nil, errors.Wrap(err, "calculate root")
+	}
+
+	var offset uint64
+	for _, leaf := range b.Path[0] {
+		if leaf.Hash != nil && leaf.Hash.Equal(&txid) {
+			offset = leaf.Offset
+			break
+		}
+	}
+
+	result := NewMerkleProof(txid)
+	result.Index = int(offset)
+
+	if len(b.Path) == 1 && len(b.Path[0]) == 1 && offset == 0 {
+		return result, nil
+	}
+
+	levels := b.levels()
+	hash := txid
+	for level := range b.Path {
+		leaf, exists := levels[level][offset^1]
+		if exists && leaf.Duplicate {
+			newRoot := hashPair(hash, hash)
+			result.AddDuplicate(newRoot)
+			hash = newRoot
+		} else {
+			sibling, err := nodeHash(levels, level, offset^1, hash)
+			if err != nil {
+				return nil, errors.Wrapf(err, "level %d", level)
+			}
+
+			var newRoot bitcoin.Hash32
+			if offset%2 == 0 {
+				newRoot = hashPair(hash, sibling)
+			} else {
+				newRoot = hashPair(sibling, hash)
+			}
+			result.AddHash(sibling, newRoot)
+			hash = newRoot
+		}
+
+		offset = offset >> 1
+	}
+
+	return result, nil
+}
+
+// levels returns the leaves of each level mapped by offset.
+func (b BUMP) levels() []map[uint64]*BUMPLeaf {
+	result := make([]map[uint64]*BUMPLeaf, len(b.Path))
+	for level, leaves := range b.Path {
+		result[level] = make(map[uint64]*BUMPLeaf, len(leaves))
+		for _, leaf := range leaves {
+			result[level][leaf.Offset] = leaf
+		}
+	}
+	return result
+}
+
+// nodeHash returns the hash of the node at the offset in the level. working is the hash of the
+// node's left sibling and is used when the node is a duplicate. Nodes that aren't in the path are
+// calculated from the level below.
+func nodeHash(levels []map[uint64]*BUMPLeaf, level int, offset uint64,
+	working bitcoin.Hash32) (bitcoin.Hash32, error) {
+
+	if leaf, exists := levels[level][offset]; exists {
+		if leaf.Duplicate {
+			if offset%2 == 0 {
+				return bitcoin.Hash32{}, ErrBadIndex // left hash can't be duplicate
+			}
+			return working, nil
+		}
+
+		if leaf.Hash == nil {
+			return bitcoin.Hash32{}, errors.Wrapf(ErrMissingNode, "offset %d", offset)
+		}
+		return *leaf.Hash, nil
+	}
+
+	if level == 0 {
+		return bitcoin.Hash32{}, errors.Wrapf(ErrMissingNode, "offset %d", offset)
+	}
+
+	left, err := nodeHash(levels, level-1, offset*2, bitcoin.Hash32{})
+	if err != nil {
+		return bitcoin.Hash32{}, err
+	}
+
+	right, err := nodeHash(levels, level-1, offset*2+1, left)
+	if err != nil {
+		return bitcoin.Hash32{}, err
+	}
+
+	return hashPair(left, right), nil
+}
+
+// hashPair returns the double SHA256 of the left and right hashes.
+func hashPair(left, right bitcoin.Hash32) bitcoin.Hash32 {
+	s := sha256.New()
+	s.Write(left[:])
+	s.Write(right[:])
+	return bitcoin.Hash32(sha256.Sum256(s.Sum(nil)))
+}
+
+func (b BUMP) Serialize(w io.Writer) error {
+	if err := wire.WriteVarInt(w, 0, b.BlockHeight); err != nil {
+		return errors.Wrap(err, "block height")
+	}
+
+	if len(b.Path) > 255 {
+		return fmt.Errorf("Tree height too large : %d", len(b.Path))
+	}
+	if err := binary.Write(w, Endian, uint8(len(b.Path))); err != nil {
+		return errors.Wrap(err, "tree height")
+	}
+
+	for level, leaves := range b.Path {
+		if err := wire.WriteVarInt(w, 0, uint64(len(leaves))); err != nil {
+			return errors.Wrapf(err, "level %d leaf count", level)
+		}
+
+		for i, leaf := range leaves {
+			if err := leaf.Serialize(w); err != nil {
+				return errors.Wrapf(err, "level %d leaf %d", level, i)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *BUMP) Deserialize(r io.Reader) error {
+	blockHeight, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "block height")
+	}
+	b.BlockHeight = blockHeight
+
+	var treeHeight uint8
+	if err := binary.Read(r, Endian, &treeHeight); err != nil {
+		return errors.Wrap(err, "tree height")
+	}
+
+	b.Path = make([][]*BUMPLeaf, treeHeight)
+	for level := range b.Path {
+		count, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return errors.Wrapf(err, "level %d leaf count", level)
+		}
+
+		for i := uint64(0); i < count; i++ {
+			leaf := &BUMPLeaf{}
+			if err := leaf.Deserialize(r); err != nil {
+				return errors.Wrapf(err, "level %d leaf %d", level, i)
+			}
+
+			b.Path[level] = append(b.Path[level], leaf)
+		}
+	}
+
+	return nil
+}
+
+func (l BUMPLeaf) Serialize(w io.Writer) error {
+	if err := wire.WriteVarInt(w, 0, l.Offset); err != nil {
+		return errors.Wrap(err, "offset")
+	}
+
+	if l.Duplicate {
+		if err := binary.Write(w, Endian, BUMPFlagDuplicate); err != nil {
+			return errors.Wrap(err, "flag")
+		}
+		return nil
+	}
+
+	if l.Hash == nil {
+		return ErrMissingNode
+	}
+
+	flag := BUMPFlagHash
+	if l.TxID {
+		flag = BUMPFlagTxID
+	}
+	if err := binary.Write(w, Endian, flag); err != nil {
+		return errors.Wrap(err, "flag")
+	}
+
+	if err := l.Hash.Serialize(w); err != nil {
+		return errors.Wrap(err, "hash")
+	}
+
+	return nil
+}
+
+func (l *BUMPLeaf) Deserialize(r io.Reader) error {
+	offset, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "offset")
+	}
+	l.Offset = offset
+
+	var flag uint8
+	if err := binary.Read(r, Endian, &flag); err != nil {
+		return errors.Wrap(err, "flag")
+	}
+
+	l.Hash = nil
+	l.TxID = false
+	l.Duplicate = false
+	switch flag {
+	case BUMPFlagDuplicate:
+		l.Duplicate = true
+		return nil
+	case BUMPFlagTxID:
+		l.TxID = true
+	case BUMPFlagHash:
+	default:
+		return fmt.Errorf("Unsupported flag : %d", flag)
+	}
+
+	hash := &bitcoin.Hash32{}
+	if err := hash.Deserialize(r); err != nil {
+		return errors.Wrap(err, "hash")
+	}
+	l.Hash = hash
+
+	return nil
+}
+
+type jsonBUMP struct {
+	BlockHeight uint64           `json:"blockHeight"`
+	Path        [][]jsonBUMPLeaf `json:"path"`
+}
+
+type jsonBUMPLeaf struct {
+	Offset    uint64          `json:"offset"`
+	Hash      *bitcoin.Hash32 `json:"hash,omitempty"`
+	TxID      bool            `json:"txid,omitempty"`
+	Duplicate bool            `json:"duplicate,omitempty"`
+}
+
+func (b BUMP) MarshalJSON() ([]byte, error) {
+	convert := jsonBUMP{
+		BlockHeight: b.BlockHeight,
+		Path:        make([][]jsonBUMPLeaf, len(b.Path)),
+	}
+
+	for level, leaves := range b.Path {
+		convert.Path[level] = make([]jsonBUMPLeaf, len(leaves))
+		for i, leaf := range leaves {
+			convert.Path[level][i] = jsonBUMPLeaf{
+				Offset:    leaf.Offset,
+				Hash:      leaf.Hash,
+				TxID:      leaf.TxID,
+				Duplicate: leaf.Duplicate,
+			}
+		}
+	}
+
+	return json.Marshal(convert)
+}
+
+func (b *BUMP) UnmarshalJSON(data []byte) error {
+	var convert jsonBUMP
+	if err := json.Unmarshal(data, &convert); err != nil {
+		return err
+	}
+
+	b.BlockHeight = convert.BlockHeight
+	b.Path = make([][]*BUMPLeaf, len(convert.Path))
+	for level, leaves := range convert.Path {
+		for i, leaf := range leaves {
+			if !leaf.Duplicate && leaf.Hash == nil {
+				return errors.Wrapf(ErrMissingNode, "level %d leaf %d", level, i)
+			}
+
+			b.Path[level] = append(b.Path[level], &BUMPLeaf{
+				Offset:    leaf.Offset,
+				Hash:      leaf.Hash,
+				TxID:      leaf.TxID,
+				Duplicate: leaf.Duplicate,
+			})
+		}
+	}
+
+	return nil
+}
+
+func (b BUMP) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := b.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (b *BUMP) UnmarshalBinary(data []byte) error {
+	return b.Deserialize(bytes.NewReader(data))
+}
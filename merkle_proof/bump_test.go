@@ -0,0 +1,172 @@
+package merkle_proof
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+
+	"github.com/pkg/errors"
+)
+
+func TestBUMP(t *testing.T) {
+	for _, count := range []int{1, 2, 5, 6, 13} {
+		hashes := make([]bitcoin.Hash32, count)
+		for i := range hashes {
+			rand.Read(hashes[i][:])
+		}
+
+		mt := NewMerkleTree(true)
+		for i := range hashes {
+			mt.AddMerkleProofIndex(i)
+		}
+		for _, hash := range hashes {
+			mt.AddHash(hash)
+		}
+		root, proofs := mt.FinalizeMerkleProofs()
+
+		bump, err := NewBUMPFromMerkleProofs(1000, proofs)
+		if err != nil {
+			t.Fatalf("Failed to create BUMP : %s", err)
+		}
+
+		if err := bump.Verify(root); err != nil {
+			t.Fatalf("Failed to verify BUMP with %d txs : %s", count, err)
+		}
+
+		if len(bump.TxIDs()) != count {
+			t.Errorf("Wrong txid count : got %d, want %d", len(bump.TxIDs()), count)
+		}
+
+		// Compound paths of single txs.
+		compound, err := NewBUMPFromMerkleProofs(1000, proofs[:1])
+		if err != nil {
+			t.Fatalf("Failed to create BUMP : %s", err)
+		}
+
+		for _, proof := range proofs[1:] {
+			single, err := NewBUMPFromMerkleProofs(1000, []*MerkleProof{proof})
+			if err != nil {
+				t.Fatalf("Failed to create BUMP : %s", err)
+			}
+
+			if err := compound.Merge(single); err != nil {
+				t.Fatalf("Failed to merge BUMP : %s", err)
+			}
+		}
+
+		if err := compound.Verify(root); err != nil {
+			t.Fatalf("Failed to verify compound BUMP : %s", err)
+		}
+
+		// Binary
+		b, err := bump.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to serialize BUMP : %s", err)
+		}
+
+		cb, err := compound.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to serialize compound BUMP : %s", err)
+		}
+
+		if !bytes.Equal(b, cb) {
+			t.Errorf("Compound BUMP doesn't match : \n got  %x\n want %x", cb, b)
+		}
+
+		var readBump BUMP
+		if err := readBump.UnmarshalBinary(b); err != nil {
+			t.Fatalf("Failed to deserialize BUMP : %s", err)
+		}
+
+		if err := readBump.Verify(root); err != nil {
+			t.Fatalf("Failed to verify deserialized BUMP : %s", err)
+		}
+
+		// JSON
+		js, err := json.Marshal(bump)
+		if err != nil {
+			t.Fatalf("Failed to marshal BUMP : %s", err)
+		}
+
+		var jsonBump BUMP
+		if err := json.Unmarshal(js, &jsonBump); err != nil {
+			t.Fatalf("Failed to unmarshal BUMP : %s", err)
+		}
+
+		jb, err := jsonBump.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to serialize json BUMP : %s", err)
+		}
+
+		if !bytes.Equal(b, jb) {
+			t.Errorf("JSON BUMP doesn't match : \n got  %x\n want %x", jb, b)
+		}
+
+		// Merkle proofs
+		for i, hash := range hashes {
+			proof, err := bump.MerkleProof(hash)
+			if err != nil {
+				t.Fatalf("Failed to get merkle proof %d : %s", i, err)
+			}
+
+			if proof.Index != i {
+				t.Errorf("Wrong proof index : got %d, want %d", proof.Index, i)
+			}
+
+			proofRoot, err := proof.CalculateRoot()
+			if err != nil {
+				t.Fatalf("Failed to calculate merkle proof root : %s", err)
+			}
+
+			if !proofRoot.Equal(&root) {
+				t.Errorf("Wrong proof %d root : \n got  %s\n want %s", i, proofRoot, root)
+			}
+		}
+	}
+}
+
+func TestBUMPCompressed(t *testing.T) {
+	hashes := make([]bitcoin.Hash32, 8)
+	for i := range hashes {
+		rand.Read(hashes[i][:])
+	}
+
+	mt := NewMerkleTree(true)
+	mt.AddMerkleProofIndex(0)
+	mt.AddMerkleProofIndex(1)
+	mt.AddMerkleProofIndex(3)
+	for _, hash := range hashes {
+		mt.AddHash(hash)
+	}
+	root, proofs := mt.FinalizeMerkleProofs()
+
+	bump, err := NewBUMPFromMerkleProofs(1, proofs)
+	if err != nil {
+		t.Fatalf("Failed to create BUMP : %s", err)
+	}
+
+	// Remove the level 1 node that can be calculated from the txids at level 0.
+	var level1 []*BUMPLeaf
+	for _, leaf := range bump.Path[1] {
+		if leaf.Offset != 1 {
+			level1 = append(level1, leaf)
+		}
+	}
+	if len(level1) == len(bump.Path[1]) {
+		t.Fatalf("Level 1 node not found")
+	}
+	bump.Path[1] = level1
+
+	if err := bump.Verify(root); err != nil {
+		t.Fatalf("Failed to verify BUMP with calculated nodes : %s", err)
+	}
+
+	// Remove a level 0 hash that is needed.
+	bump.Path[0] = bump.Path[0][1:]
+	if _, err := bump.CalculateRoot(hashes[1]); errors.Cause(err) != ErrMissingNode {
+		t.Errorf("Wrong error : got %v, want %s", err, ErrMissingNode)
+	}
+}
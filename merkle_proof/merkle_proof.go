@@ -22,6 +22,10 @@ var (
 	ErrMissingTxID     = errors.New("Missing Transaction ID")
 	ErrMissingTarget   = errors.New("Missing Target (block hash, header, merkle root)")
 
+	// ErrUnsupportedProofType means the proof is a TSC tree or composite proof or contains index
+	// nodes. The TSC standard reserves these without defining their layout.
+	ErrUnsupportedProofType = errors.New("Unsupported Proof Type")
+
 	Endian = binary.LittleEndian
 )
 
@@ -160,16 +164,20 @@ func (mp MerkleProof) Verify() error {
 func (mp MerkleProof) Serialize(w io.Writer) error {
 	var flag uint8
 
+	txid := mp.TxID
 	if mp.Tx != nil {
-		flag = flag | 0x01
+		flag = flag | FlagTx
+		if txid == nil {
+			txid = mp.Tx.TxHash()
+		}
 	} else if mp.TxID == nil {
 		return ErrMissingTxID
 	}
 
 	if mp.BlockHeader != nil {
-		flag = flag | 0x02
+		flag = flag | FlagTargetHeader
 	} else if mp.MerkleRoot != nil {
-		flag = flag | 0x04
+		flag = flag | FlagTargetMerkleRoot
 	} else if mp.BlockHash == nil {
 		return ErrMissingTarget
 	}
@@ -222,7 +230,7 @@ func (mp MerkleProof) Serialize(w io.Writer) error {
 
 	// Calculate nodes
 	layer := 1
-	hash := *mp.TxID
+	hash := *txid
 	index := mp.Index
 	path := mp.Path
 	duplicateIndexes := mp.DuplicatedIndexes
@@ -236,7 +244,7 @@ func (mp MerkleProof) Serialize(w io.Writer) error {
 			otherHash = hash
 			duplicateIndexes = duplicateIndexes[1:]
 
-			if err := binary.Write(w, Endian, uint8(1)); err != nil {
+			if err := binary.Write(w, Endian, NodeTypeDuplicate); err != nil {
 				return errors.Wrap(err, "node type duplicate")
 			}
 		} else {
@@ -246,7 +254,7 @@ func (mp MerkleProof) Serialize(w io.Writer) error {
 			otherHash = path[0]
 			path = path[1:]
 
-			if err := binary.Write(w, Endian, uint8(0)); err != nil {
+			if err := binary.Write(w, Endian, NodeTypeHash); err != nil {
 				return errors.Wrap(err, "node type hash")
 			}
 			if err := otherHash.Serialize(w); err != nil {
@@ -282,15 +290,32 @@ func (mp *MerkleProof) Deserialize(r io.Reader) error {
 		return errors.Wrap(err, "flag")
 	}
 
+	if flag&FlagProofTypeTree != 0 {
+		return errors.Wrap(ErrUnsupportedProofType, "tree")
+	}
+	if flag&FlagComposite != 0 {
+		return errors.Wrap(ErrUnsupportedProofType, "composite")
+	}
+
 	index, err := wire.ReadVarInt(r, 0)
 	if err != nil {
 		return errors.Wrap(err, "index")
 	}
 	mp.Index = int(index)
 
-	if flag&0x01 == 0x01 {
+	if flag&FlagTx != 0 {
+		txSize, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return errors.Wrap(err, "tx length")
+		}
+
+		b := make([]byte, txSize)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return errors.Wrap(err, "tx bytes")
+		}
+
 		tx := &wire.MsgTx{}
-		if err := tx.Deserialize(r); err != nil {
+		if err := tx.Deserialize(bytes.NewReader(b)); err != nil {
 			return errors.Wrap(err, "tx")
 		}
 
@@ -306,27 +331,36 @@ func (mp *MerkleProof) Deserialize(r io.Reader) error {
 		mp.TxID = txid
 	}
 
-	if flag&0x02 == 0x02 {
+	mp.BlockHeader = nil
+	mp.MerkleRoot = nil
+	mp.BlockHash = nil
+	switch flag & FlagTargetTypeMask {
+	case FlagTargetHeader:
 		header := &wire.BlockHeader{}
 		if err := header.Deserialize(r); err != nil {
 			return errors.Wrap(err, "block header")
 		}
 
 		mp.BlockHeader = header
-	} else if flag&0x04 == 0x04 {
+
+	case FlagTargetMerkleRoot:
 		merkleRoot := &bitcoin.Hash32{}
 		if err := merkleRoot.Deserialize(r); err != nil {
 			return errors.Wrap(err, "merkle root")
 		}
 
 		mp.MerkleRoot = merkleRoot
-	} else {
+
+	case FlagTargetHash:
 		blockHash := &bitcoin.Hash32{}
 		if err := blockHash.Deserialize(r); err != nil {
 			return errors.Wrap(err, "block hash")
 		}
 
 		mp.BlockHash = blockHash
+
+	default:
+		return fmt.Errorf("Unsupported target type : %d", flag&FlagTargetTypeMask)
 	}
 
 	nodeCount, err := wire.ReadVarInt(r, 0)
@@ -334,21 +368,25 @@ func (mp *MerkleProof) Deserialize(r io.Reader) error {
 		return errors.Wrap(err, "node count")
 	}
 
+	mp.Path = nil
+	mp.DuplicatedIndexes = nil
 	for i := uint64(0); i < nodeCount; i++ {
 		var t uint8
 		if err := binary.Read(r, Endian, &t); err != nil {
 			return errors.Wrap(err, "node type")
 		}
 
-		if t == 0 {
+		if t == NodeTypeHash {
 			hash := &bitcoin.Hash32{}
 			if err := hash.Deserialize(r); err != nil {
 				return errors.Wrapf(err, "node hash %d", i)
 			}
 
 			mp.Path = append(mp.Path, *hash)
-		} else if t == 1 {
+		} else if t == NodeTypeDuplicate {
 			mp.DuplicatedIndexes = append(mp.DuplicatedIndexes, int(i+1))
+		} else if t == NodeTypeIndex {
+			return errors.Wrapf(ErrUnsupportedProofType, "index node %d", i)
 		} else {
 			return fmt.Errorf("Unsupported node type at index %d : type %d", i, t)
 		}
@@ -458,7 +496,22 @@ func (mp *MerkleProof) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	switch convert.ProofType {
+	case "branch", "":
+	case "tree":
+		return errors.Wrap(ErrUnsupportedProofType, "tree")
+	default:
+		return fmt.Errorf("Unsupported proof type : %s", convert.ProofType)
+	}
+	if convert.Composite {
+		return errors.Wrap(ErrUnsupportedProofType, "composite")
+	}
+
 	mp.Index = convert.Index
+	mp.Tx = nil
+	mp.BlockHash = nil
+	mp.BlockHeader = nil
+	mp.MerkleRoot = nil
 
 	if len(convert.TxOrID) == bitcoin.Hash32Size*2 {
 		txid, err := bitcoin.NewHash32FromStr(convert.TxOrID)
@@ -511,6 +564,9 @@ func (mp *MerkleProof) UnmarshalJSON(data []byte) error {
 		}
 
 		mp.MerkleRoot = hash
+
+	default:
+		return fmt.Errorf("Unsupported target type : %s", convert.TargetType)
 	}
 
 	mp.depth = 1
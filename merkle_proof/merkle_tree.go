@@ -8,7 +8,10 @@ import (
 	"github.com/tokenized/pkg/bitcoin"
 )
 
-// MerkleTree is an efficient structure for calculating a merkle root hash.
+// MerkleTree is an efficient structure for calculating a merkle root hash. Hashes are streamed
+// into the tree in order with AddHash and merkle proofs are calculated as the hashes are added.
+// When pruned, only the hashes needed to calculate the root and the merkle proofs are kept, so the
+// memory used is logarithmic in the number of hashes.
 type MerkleTree struct {
 	layers []*merkleNodeLayer // First layer, index zero, is the lowest level of the tree.
 	prune  bool
@@ -30,6 +33,21 @@ func (t *MerkleTree) AddMerkleProof(txid bitcoin.Hash32) {
 	t.merkleProofs = append(t.merkleProofs, NewMerkleProof(txid))
 }
 
+// AddMerkleProofIndex adds a merkle proof to be calculated for the hash at the specified index in
+// the bottom level of the tree. It must be called before the hash at that index is added. The
+// proof's TxID is set when the hash is added.
+func (t *MerkleTree) AddMerkleProofIndex(index int) {
+	t.merkleProofs = append(t.merkleProofs, &MerkleProof{
+		Index: index,
+		depth: 1,
+	})
+}
+
+// Count returns the number of hashes added to the bottom level of the tree.
+func (t MerkleTree) Count() int {
+	return t.count
+}
+
 func (t MerkleTree) Print() {
 	indent := ""
 	for i, layer := range t.layers {
@@ -54,6 +72,15 @@ func (t *MerkleTree) AddHash(hash bitcoin.Hash32) {
 		}
 	}
 
+	// Check merkle proofs for this index.
+	for _, mp := range t.merkleProofs {
+		if mp.Index == t.count && mp.TxID == nil {
+			txid := hash
+			mp.TxID = &txid
+			mp.root = hash
+		}
+	}
+
 	if len(t.layers) == 0 {
 		// First hash in tree
 		t.layers = []*merkleNodeLayer{newMerkleNodeLayer(hash)}
@@ -204,8 +231,8 @@ func (t MerkleTree) processProofsLayer(hasher hash.Hash, l, r bitcoin.Hash32, is
 
 	// Check active merkle proofs for next element in path
 	for _, mp := range t.merkleProofs {
-		if mp.Index == -1 {
-			continue // txid not found yet
+		if mp.Index == -1 || mp.TxID == nil {
+			continue // hash not added yet
 		}
 
 		if isDuplicate {
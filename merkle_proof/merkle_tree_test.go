@@ -2,6 +2,7 @@ package merkle_proof
 
 import (
 	"crypto/rand"
+	"fmt"
 	"testing"
 
 	"github.com/tokenized/pkg/bitcoin"
@@ -339,3 +340,81 @@ func BenchmarkMerkleTreePruned(b *testing.B) {
 	mt.RootHash()
 	mt.FinalizeMerkleProofs()
 }
+
+func TestMerkleProofIndex(t *testing.T) {
+	for _, count := range []int{1, 2, 3, 7, 8, 1000, 1025} {
+		t.Run(fmt.Sprintf("%d", count), func(t *testing.T) {
+			hashes := make([]bitcoin.Hash32, count)
+			for i := range hashes {
+				rand.Read(hashes[i][:])
+			}
+
+			indexes := []int{0}
+			if count > 2 {
+				indexes = append(indexes, count/2)
+			}
+			if count > 1 {
+				indexes = append(indexes, count-1)
+			}
+
+			full := NewMerkleTree(false)
+			pruned := NewMerkleTree(true)
+			for _, index := range indexes {
+				full.AddMerkleProof(hashes[index])
+				pruned.AddMerkleProofIndex(index)
+			}
+
+			for _, hash := range hashes {
+				full.AddHash(hash)
+				pruned.AddHash(hash)
+
+				for i, layer := range pruned.layers {
+					if len(layer.hashes) > 2 {
+						t.Fatalf("Pruned layer %d has %d hashes", i, len(layer.hashes))
+					}
+				}
+			}
+
+			if pruned.Count() != count {
+				t.Errorf("Wrong count : got %d, want %d", pruned.Count(), count)
+			}
+
+			fullRoot, fullProofs := full.FinalizeMerkleProofs()
+			root, proofs := pruned.FinalizeMerkleProofs()
+
+			if !root.Equal(&fullRoot) {
+				t.Fatalf("Wrong root hash : \n  got  %s\n  want %s", root, fullRoot)
+			}
+
+			if len(proofs) != len(indexes) {
+				t.Fatalf("Wrong merkle proof count : got %d, want %d", len(proofs), len(indexes))
+			}
+
+			for i, proof := range proofs {
+				if proof.Index != indexes[i] {
+					t.Errorf("Wrong proof %d index : got %d, want %d", i, proof.Index, indexes[i])
+				}
+
+				if proof.TxID == nil || !proof.TxID.Equal(&hashes[indexes[i]]) {
+					t.Fatalf("Wrong proof %d txid : got %s, want %s", i, proof.TxID,
+						hashes[indexes[i]])
+				}
+
+				proofRoot, err := proof.CalculateRoot()
+				if err != nil {
+					t.Fatalf("Failed to calculate merkle proof root : %s", err)
+				}
+
+				if !proofRoot.Equal(&root) {
+					t.Errorf("Wrong proof %d root hash : \n  got  %s\n  want %s", i, proofRoot,
+						root)
+				}
+
+				if len(proof.Path) != len(fullProofs[i].Path) {
+					t.Errorf("Wrong proof %d path length : got %d, want %d", i, len(proof.Path),
+						len(fullProofs[i].Path))
+				}
+			}
+		})
+	}
+}
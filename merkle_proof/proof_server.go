@@ -0,0 +1,240 @@
+package merkle_proof
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// proofServerPath is the storage path that block tx lists are indexed under.
+	proofServerPath = "merkle_proofs/blocks"
+
+	// proofServerVersion is the version of the serialized block tx lists.
+	proofServerVersion = uint8(0)
+)
+
+var (
+	ErrBlockNotIndexed = errors.New("Block Not Indexed")
+	ErrTxNotInBlock    = errors.New("Tx Not In Block")
+)
+
+// ProofServer indexes the txid lists of blocks in storage and generates merkle proofs from them,
+// for services that host their own merkle proof endpoint. It is an http.Handler that serves proofs
+// for GET requests with txid and blockhash query parameters.
+type ProofServer struct {
+	store storage.Storage
+}
+
+// NewProofServer creates a proof server that indexes blocks in the store.
+func NewProofServer(store storage.Storage) *ProofServer {
+	return &ProofServer{
+		store: store,
+	}
+}
+
+// IndexBlock saves the txids of a block so proofs can be generated for them. The txids must be in
+// block order and are verified against the header's merkle root.
+func (s *ProofServer) IndexBlock(ctx context.Context, header *wire.BlockHeader,
+	txids []bitcoin.Hash32) error {
+
+	if len(txids) == 0 {
+		return errors.Wrap(ErrMissingTxID, "empty block")
+	}
+
+	tree := NewMerkleTree(true)
+	for _, txid := range txids {
+		tree.AddHash(txid)
+	}
+
+	root := tree.RootHash()
+	if !root.Equal(&header.MerkleRoot) {
+		return errors.Wrapf(ErrWrongMerkleRoot, "got %s, want %s", root, header.MerkleRoot)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := buf.WriteByte(proofServerVersion); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if err := header.Serialize(buf); err != nil {
+		return errors.Wrap(err, "header")
+	}
+
+	if err := wire.WriteVarInt(buf, 0, uint64(len(txids))); err != nil {
+		return errors.Wrap(err, "txid count")
+	}
+
+	for i, txid := range txids {
+		if err := txid.Serialize(buf); err != nil {
+			return errors.Wrapf(err, "txid %d", i)
+		}
+	}
+
+	blockHash := header.BlockHash()
+	if err := s.store.Write(ctx, proofServerBlockPath(*blockHash), buf.Bytes(), nil); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+
+// IndexMsgBlock indexes the txids of a block message.
+func (s *ProofServer) IndexMsgBlock(ctx context.Context, block *wire.MsgBlock) error {
+	txids := make([]bitcoin.Hash32, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txids[i] = *tx.TxHash()
+	}
+
+	return s.IndexBlock(ctx, &block.Header, txids)
+}
+
+// RemoveBlock removes the index of a block, for example when it is no longer in the longest chain.
+func (s *ProofServer) RemoveBlock(ctx context.Context, blockHash bitcoin.Hash32) error {
+	if err := s.store.Remove(ctx, proofServerBlockPath(blockHash)); err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return ErrBlockNotIndexed
+		}
+		return errors.Wrap(err, "remove")
+	}
+
+	return nil
+}
+
+// GetMerkleProof returns a merkle proof of the tx in the block, targeting the block header. It
+// returns ErrBlockNotIndexed if the block hasn't been indexed and ErrTxNotInBlock if the tx isn't
+// in the block.
+func (s *ProofServer) GetMerkleProof(ctx context.Context, txid,
+	blockHash bitcoin.Hash32) (*MerkleProof, error) {
+
+	header, txids, err := s.readBlock(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+	for i, blockTxID := range txids {
+		if blockTxID.Equal(&txid) {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		return nil, errors.Wrapf(ErrTxNotInBlock, "%s", txid)
+	}
+
+	tree := NewMerkleTree(true)
+	tree.AddMerkleProofIndex(index)
+	for _, blockTxID := range txids {
+		tree.AddHash(blockTxID)
+	}
+
+	_, proofs := tree.FinalizeMerkleProofs()
+	if len(proofs) != 1 {
+		return nil, fmt.Errorf("Wrong proof count : %d", len(proofs))
+	}
+
+	proof := proofs[0]
+	proof.BlockHeader = header
+	return proof, nil
+}
+
+// ServeHTTP serves the merkle proof of the tx and block specified by the txid and blockhash query
+// parameters as JSON.
+func (s *ProofServer) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := request.URL.Query()
+	txid, err := bitcoin.NewHash32FromStr(query.Get("txid"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid txid : %s", err), http.StatusBadRequest)
+		return
+	}
+
+	blockHash, err := bitcoin.NewHash32FromStr(query.Get("blockhash"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid block hash : %s", err), http.StatusBadRequest)
+		return
+	}
+
+	proof, err := s.GetMerkleProof(request.Context(), *txid, *blockHash)
+	if err != nil {
+		cause := errors.Cause(err)
+		if cause == ErrBlockNotIndexed || cause == ErrTxNotInBlock {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(proof)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func (s *ProofServer) readBlock(ctx context.Context,
+	blockHash bitcoin.Hash32) (*wire.BlockHeader, []bitcoin.Hash32, error) {
+
+	b, err := s.store.Read(ctx, proofServerBlockPath(blockHash))
+	if err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return nil, nil, errors.Wrapf(ErrBlockNotIndexed, "%s", blockHash)
+		}
+		return nil, nil, errors.Wrap(err, "read")
+	}
+
+	r := bytes.NewReader(b)
+	version, err := r.ReadByte()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "version")
+	}
+	if version != proofServerVersion {
+		return nil, nil, fmt.Errorf("Unsupported version : %d", version)
+	}
+
+	header := &wire.BlockHeader{}
+	if err := header.Deserialize(r); err != nil {
+		return nil, nil, errors.Wrap(err, "header")
+	}
+
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "txid count")
+	}
+
+	if count > uint64(r.Len()/bitcoin.Hash32Size) {
+		return nil, nil, fmt.Errorf("Txid count too high : %d", count)
+	}
+
+	txids := make([]bitcoin.Hash32, count)
+	for i := range txids {
+		if err := txids[i].Deserialize(r); err != nil {
+			return nil, nil, errors.Wrapf(err, "txid %d", i)
+		}
+	}
+
+	return header, txids, nil
+}
+
+func proofServerBlockPath(blockHash bitcoin.Hash32) string {
+	return fmt.Sprintf("%s/%s", proofServerPath, blockHash)
+}
@@ -0,0 +1,152 @@
+package merkle_proof
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestProofServer(t *testing.T) {
+	ctx := context.Background()
+	server := NewProofServer(storage.NewMockStorage())
+
+	for _, count := range []int{1, 2, 5, 6, 13} {
+		txids := make([]bitcoin.Hash32, count)
+		tree := NewMerkleTree(true)
+		for i := range txids {
+			rand.Read(txids[i][:])
+			tree.AddHash(txids[i])
+		}
+
+		header := &wire.BlockHeader{
+			Version:    1,
+			MerkleRoot: tree.RootHash(),
+		}
+		blockHash := *header.BlockHash()
+
+		_, err := server.GetMerkleProof(ctx, txids[0], blockHash)
+		if errors.Cause(err) != ErrBlockNotIndexed {
+			t.Fatalf("Wrong error for block not indexed : %v", err)
+		}
+
+		if err := server.IndexBlock(ctx, header, txids); err != nil {
+			t.Fatalf("Failed to index block : %s", err)
+		}
+
+		for i, txid := range txids {
+			proof, err := server.GetMerkleProof(ctx, txid, blockHash)
+			if err != nil {
+				t.Fatalf("Failed to get merkle proof %d : %s", i, err)
+			}
+
+			if proof.Index != i {
+				t.Errorf("Wrong proof index : got %d, want %d", proof.Index, i)
+			}
+
+			if err := proof.Verify(); err != nil {
+				t.Fatalf("Failed to verify proof %d of %d : %s", i, count, err)
+			}
+		}
+
+		var unknown bitcoin.Hash32
+		rand.Read(unknown[:])
+		_, err = server.GetMerkleProof(ctx, unknown, blockHash)
+		if errors.Cause(err) != ErrTxNotInBlock {
+			t.Fatalf("Wrong error for tx not in block : %v", err)
+		}
+
+		if err := server.RemoveBlock(ctx, blockHash); err != nil {
+			t.Fatalf("Failed to remove block : %s", err)
+		}
+
+		_, err = server.GetMerkleProof(ctx, txids[0], blockHash)
+		if errors.Cause(err) != ErrBlockNotIndexed {
+			t.Fatalf("Wrong error for removed block : %v", err)
+		}
+	}
+}
+
+func TestProofServerWrongRoot(t *testing.T) {
+	server := NewProofServer(storage.NewMockStorage())
+
+	txids := make([]bitcoin.Hash32, 3)
+	for i := range txids {
+		rand.Read(txids[i][:])
+	}
+
+	header := &wire.BlockHeader{Version: 1}
+	rand.Read(header.MerkleRoot[:])
+
+	err := server.IndexBlock(context.Background(), header, txids)
+	if errors.Cause(err) != ErrWrongMerkleRoot {
+		t.Fatalf("Wrong error for wrong merkle root : %v", err)
+	}
+}
+
+func TestProofServerHTTP(t *testing.T) {
+	server := NewProofServer(storage.NewMockStorage())
+
+	txids := make([]bitcoin.Hash32, 7)
+	tree := NewMerkleTree(true)
+	for i := range txids {
+		rand.Read(txids[i][:])
+		tree.AddHash(txids[i])
+	}
+
+	header := &wire.BlockHeader{
+		Version:    1,
+		MerkleRoot: tree.RootHash(),
+	}
+	blockHash := header.BlockHash()
+
+	if err := server.IndexBlock(context.Background(), header, txids); err != nil {
+		t.Fatalf("Failed to index block : %s", err)
+	}
+
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "?txid=" + txids[4].String() + "&blockhash=" +
+		blockHash.String())
+	if err != nil {
+		t.Fatalf("Failed to get proof : %s", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status : got %d, want %d", response.StatusCode, http.StatusOK)
+	}
+
+	proof := &MerkleProof{}
+	if err := json.NewDecoder(response.Body).Decode(proof); err != nil {
+		t.Fatalf("Failed to decode proof : %s", err)
+	}
+
+	if !proof.TxID.Equal(&txids[4]) {
+		t.Fatalf("Wrong txid : got %s, want %s", proof.TxID, txids[4])
+	}
+
+	if err := proof.Verify(); err != nil {
+		t.Fatalf("Failed to verify proof : %s", err)
+	}
+
+	missing, err := http.Get(httpServer.URL + "?txid=" + txids[4].String() + "&blockhash=" +
+		txids[0].String())
+	if err != nil {
+		t.Fatalf("Failed to get proof : %s", err)
+	}
+	missing.Body.Close()
+
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("Wrong status : got %d, want %d", missing.StatusCode, http.StatusNotFound)
+	}
+}
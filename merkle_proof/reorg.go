@@ -0,0 +1,252 @@
+package merkle_proof
+
+import (
+	"context"
+	"sort"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrHeadersNotLinked means a header chain given for a reorg isn't linked by previous block
+	// hashes.
+	ErrHeadersNotLinked = errors.New("Headers Not Linked")
+)
+
+// Reorg describes a change of the longest chain. Disconnected contains the headers of the old
+// chain after the fork point and Connected contains the headers of the new chain after the fork
+// point. Both start at height ForkHeight + 1.
+type Reorg struct {
+	ForkHeight   int
+	Disconnected []*wire.BlockHeader
+	Connected    []*wire.BlockHeader
+
+	disconnected map[bitcoin.Hash32]int // block hash to height
+}
+
+// NewReorg finds the fork point between the old and new header chains, which both start at the
+// height specified, and returns the reorg between them. The new chain must be linked by previous
+// block hashes.
+func NewReorg(height int, oldChain, newChain []*wire.BlockHeader) (*Reorg, error) {
+	for i := 1; i < len(newChain); i++ {
+		if !newChain[i].PrevBlock.Equal(newChain[i-1].BlockHash()) {
+			return nil, errors.Wrapf(ErrHeadersNotLinked, "height %d", height+i)
+		}
+	}
+
+	fork := 0
+	for fork < len(oldChain) && fork < len(newChain) &&
+		oldChain[fork].BlockHash().Equal(newChain[fork].BlockHash()) {
+		fork++
+	}
+
+	result := &Reorg{
+		ForkHeight:   height + fork - 1,
+		Disconnected: oldChain[fork:],
+		Connected:    newChain[fork:],
+		disconnected: make(map[bitcoin.Hash32]int),
+	}
+
+	for i, header := range result.Disconnected {
+		result.disconnected[*header.BlockHash()] = result.ForkHeight + 1 + i
+	}
+
+	return result, nil
+}
+
+// IsEmpty returns true if no blocks were disconnected.
+func (r Reorg) IsEmpty() bool {
+	return len(r.Disconnected) == 0
+}
+
+// IsDisconnected returns true if the block was removed from the longest chain.
+func (r Reorg) IsDisconnected(blockHash bitcoin.Hash32) bool {
+	_, exists := r.disconnected[blockHash]
+	return exists
+}
+
+// ConnectedHeader returns the header of the new chain at the height, or nil if the height isn't
+// in the new chain after the fork point.
+func (r Reorg) ConnectedHeader(height int) *wire.BlockHeader {
+	index := height - r.ForkHeight - 1
+	if index < 0 || index >= len(r.Connected) {
+		return nil
+	}
+	return r.Connected[index]
+}
+
+// Reroot moves a proof for a disconnected block to the block in the new chain with the same merkle
+// root, which happens when the same txs are mined in a different block. It returns true if the
+// proof was moved.
+func (r Reorg) Reroot(proof *MerkleProof) bool {
+	header := r.rerootHeader(proof)
+	if header == nil {
+		return false
+	}
+
+	if proof.BlockHash != nil {
+		proof.BlockHash = header.BlockHash()
+	}
+	if proof.BlockHeader != nil {
+		proof.BlockHeader = header
+	}
+	return true
+}
+
+// rerootHeader returns the header in the new chain at the same height as the proof's disconnected
+// block if it has the same merkle root.
+func (r Reorg) rerootHeader(proof *MerkleProof) *wire.BlockHeader {
+	var blockHash *bitcoin.Hash32
+	if proof.BlockHash != nil {
+		blockHash = proof.BlockHash
+	} else if proof.BlockHeader != nil {
+		blockHash = proof.BlockHeader.BlockHash()
+	} else {
+		return nil
+	}
+
+	height, exists := r.disconnected[*blockHash]
+	if !exists {
+		return nil
+	}
+
+	oldHeader := r.Disconnected[height-r.ForkHeight-1]
+	newHeader := r.ConnectedHeader(height)
+	if newHeader == nil || !newHeader.MerkleRoot.Equal(&oldHeader.MerkleRoot) {
+		return nil
+	}
+
+	return newHeader
+}
+
+// Affects returns true if the proof might not be valid after the reorg. These are proofs for
+// disconnected blocks and proofs that only contain a merkle root for a height above the fork
+// point. height is the proof's block height, or UnknownHeight.
+func (r Reorg) Affects(proof *MerkleProof, height int) bool {
+	if r.IsEmpty() {
+		return false
+	}
+
+	if proof.BlockHash != nil {
+		return r.IsDisconnected(*proof.BlockHash)
+	}
+
+	if proof.BlockHeader != nil {
+		return r.IsDisconnected(*proof.BlockHeader.BlockHash())
+	}
+
+	if proof.MerkleRoot == nil {
+		return false
+	}
+
+	if height != UnknownHeight {
+		return height > r.ForkHeight
+	}
+
+	for _, header := range r.Disconnected {
+		if header.MerkleRoot.Equal(proof.MerkleRoot) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InvalidProofs returns the indexes of the proofs that are known to be invalid after the reorg
+// without looking up any headers. These are proofs for disconnected blocks that can't be moved to
+// the new chain with Reroot and proofs that only contain a merkle root that doesn't match the new
+// chain's header at the same height. heights
+// contains the block height of each proof, or UnknownHeight, and can be nil.
+func (r Reorg) InvalidProofs(proofs []*MerkleProof, heights []int) []int {
+	var result []int
+	for index, proof := range proofs {
+		if proof == nil {
+			continue
+		}
+
+		height := UnknownHeight
+		if index < len(heights) {
+			height = heights[index]
+		}
+
+		if !r.Affects(proof, height) {
+			continue
+		}
+
+		if proof.BlockHash != nil || proof.BlockHeader != nil {
+			if r.rerootHeader(proof) != nil {
+				continue
+			}
+		} else if height != UnknownHeight {
+			header := r.ConnectedHeader(height)
+			if header != nil && header.MerkleRoot.Equal(proof.MerkleRoot) {
+				continue // the same merkle root is in the new chain
+			}
+		}
+
+		result = append(result, index)
+	}
+
+	return result
+}
+
+// VerifyReorg re-validates the proofs affected by the reorg against the header source, which must
+// already contain the new chain. It returns the indexes of the proofs that are no longer valid
+// and need to be re-fetched. Proofs that aren't affected by the reorg aren't checked, and proofs
+// for disconnected blocks whose txs were mined in a new block with the same merkle root are moved
+// to the new block with Reroot. An error is returned if a header can't be retrieved for a reason
+// other than it not being found.
+func (v *Verifier) VerifyReorg(ctx context.Context, reorg *Reorg, proofs []*MerkleProof,
+	heights []int) ([]int, error) {
+
+	var affected []*MerkleProof
+	var affectedHeights []int
+	var affectedIndexes []int
+	var result []int
+	for index, proof := range proofs {
+		if proof == nil {
+			continue
+		}
+
+		height := UnknownHeight
+		if index < len(heights) {
+			height = heights[index]
+		}
+
+		if !reorg.Affects(proof, height) {
+			continue
+		}
+
+		if proof.BlockHash != nil || proof.BlockHeader != nil {
+			// The header source can still return disconnected headers by hash, so these are
+			// invalid unless they can be moved to the new chain.
+			if !reorg.Reroot(proof) {
+				result = append(result, index)
+			}
+			continue
+		}
+
+		affected = append(affected, proof)
+		affectedHeights = append(affectedHeights, height)
+		affectedIndexes = append(affectedIndexes, index)
+	}
+
+	for i, verifyResult := range v.VerifyAtHeights(ctx, affected, affectedHeights) {
+		if verifyResult.Err == nil {
+			continue
+		}
+
+		switch errors.Cause(verifyResult.Err) {
+		case ErrWrongMerkleRoot, ErrHeaderNotFound, ErrNotVerifiable:
+			result = append(result, affectedIndexes[i])
+		default:
+			return nil, errors.Wrapf(verifyResult.Err, "proof %d", affectedIndexes[i])
+		}
+	}
+
+	sort.Ints(result)
+	return result, nil
+}
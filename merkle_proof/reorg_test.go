@@ -0,0 +1,126 @@
+package merkle_proof
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestReorg(t *testing.T) {
+	mt := NewMerkleTree(false)
+	for _, s := range blocks[3].hashes {
+		hash, err := bitcoin.NewHash32FromStr(s)
+		if err != nil {
+			t.Fatalf("Failed to parse hash string : %s", err)
+		}
+
+		mt.AddMerkleProof(*hash)
+		mt.AddHash(*hash)
+	}
+
+	root, proofs := mt.FinalizeMerkleProofs()
+	if len(proofs) < 5 {
+		t.Fatalf("Not enough proofs : %d", len(proofs))
+	}
+
+	otherRoot := bitcoin.Hash32{1}
+
+	// Old chain is 0, 1, 2, 3. New chain is 0, 1, 2', 3' where 2' contains the same txs as 2.
+	genesis := wire.NewBlockHeader(1, &bitcoin.Hash32{}, &otherRoot, 1, 0)
+	header1 := wire.NewBlockHeader(1, genesis.BlockHash(), &root, 1, 0)
+	oldHeader2 := wire.NewBlockHeader(1, header1.BlockHash(), &root, 1, 0)
+	oldHeader3 := wire.NewBlockHeader(1, oldHeader2.BlockHash(), &root, 1, 0)
+	newHeader2 := wire.NewBlockHeader(1, header1.BlockHash(), &root, 1, 1)
+	newHeader3 := wire.NewBlockHeader(1, newHeader2.BlockHash(), &otherRoot, 1, 1)
+
+	oldChain := []*wire.BlockHeader{genesis, header1, oldHeader2, oldHeader3}
+	newChain := []*wire.BlockHeader{genesis, header1, newHeader2, newHeader3}
+
+	reorg, err := NewReorg(0, oldChain, newChain)
+	if err != nil {
+		t.Fatalf("Failed to create reorg : %s", err)
+	}
+
+	if reorg.ForkHeight != 1 {
+		t.Fatalf("Wrong fork height : got %d, want %d", reorg.ForkHeight, 1)
+	}
+
+	if !reorg.IsDisconnected(*oldHeader3.BlockHash()) || reorg.IsDisconnected(*header1.BlockHash()) {
+		t.Fatalf("Wrong disconnected blocks")
+	}
+
+	unaffected := *proofs[0]
+	unaffected.BlockHash = header1.BlockHash()
+
+	moved := *proofs[1]
+	moved.BlockHash = oldHeader2.BlockHash()
+
+	orphaned := *proofs[2]
+	orphaned.BlockHeader = oldHeader3
+
+	rootValid := *proofs[3]
+	rootValid.MerkleRoot = &root
+
+	rootInvalid := *proofs[4]
+	rootInvalid.MerkleRoot = &root
+
+	batch := []*MerkleProof{&unaffected, &moved, &orphaned, &rootValid, &rootInvalid}
+	heights := []int{1, 2, 3, 2, 3}
+
+	invalid := reorg.InvalidProofs(batch, heights)
+	if !reflect.DeepEqual(invalid, []int{2, 4}) {
+		t.Fatalf("Wrong invalid proofs : got %v, want %v", invalid, []int{2, 4})
+	}
+
+	headers := &mockHeaders{headers: newChain}
+	verifier := NewVerifier(headers, 2)
+	invalid, err = verifier.VerifyReorg(context.Background(), reorg, batch, heights)
+	if err != nil {
+		t.Fatalf("Failed to verify reorg : %s", err)
+	}
+
+	if !reflect.DeepEqual(invalid, []int{2, 4}) {
+		t.Fatalf("Wrong invalid proofs : got %v, want %v", invalid, []int{2, 4})
+	}
+
+	// Only the two proofs for the height were checked against the header source.
+	if headers.calls != 2 {
+		t.Fatalf("Wrong header call count : got %d, want %d", headers.calls, 2)
+	}
+
+	if !moved.BlockHash.Equal(newHeader2.BlockHash()) {
+		t.Fatalf("Proof not moved to new block : got %s, want %s", moved.BlockHash,
+			newHeader2.BlockHash())
+	}
+
+	// The moved proof is valid in the new chain.
+	results := verifier.Verify(context.Background(), []*MerkleProof{&moved})
+	if results[0].Err != nil {
+		t.Fatalf("Moved proof not valid : %s", results[0].Err)
+	}
+}
+
+func TestReorgNotLinked(t *testing.T) {
+	root := bitcoin.Hash32{1}
+	genesis := wire.NewBlockHeader(1, &bitcoin.Hash32{}, &root, 1, 0)
+	header := wire.NewBlockHeader(1, &bitcoin.Hash32{2}, &root, 1, 0)
+
+	_, err := NewReorg(0, nil, []*wire.BlockHeader{genesis, header})
+	if errors.Cause(err) != ErrHeadersNotLinked {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrHeadersNotLinked)
+	}
+
+	reorg, err := NewReorg(0, []*wire.BlockHeader{genesis}, []*wire.BlockHeader{genesis})
+	if err != nil {
+		t.Fatalf("Failed to create reorg : %s", err)
+	}
+
+	if !reorg.IsEmpty() {
+		t.Fatalf("Reorg should be empty")
+	}
+}
@@ -0,0 +1,113 @@
+package merkle_proof
+
+import (
+	"bytes"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// Merkle proofs are serialized in the TSC (Technical Standards Committee) merkle proof format,
+// which is the format delivered by merchant APIs and SPV channels. Only branch proofs for a single
+// tx are supported, in both the binary and JSON forms.
+//
+// The standard reserves the tree proof type, the composite flag, and the index node type for
+// future use but doesn't define their layout, and merchant APIs and SPV channels only deliver
+// single branch proofs. Proofs that use them are rejected with ErrUnsupportedProofType rather than
+// guessing at a layout that may not match the standard when it is defined.
+//
+// https://tsc.bitcoinassociation.net/standards/merkle-proof-standardised-format/
+
+const (
+	// FlagTx is set when the full tx is included instead of the txid.
+	FlagTx = uint8(0x01)
+
+	// FlagTargetTypeMask masks the bits that specify the target type.
+	FlagTargetTypeMask   = uint8(0x06)
+	FlagTargetHash       = uint8(0x00)
+	FlagTargetHeader     = uint8(0x02)
+	FlagTargetMerkleRoot = uint8(0x04)
+
+	// FlagProofTypeTree is set when the proof is a tree instead of a branch.
+	FlagProofTypeTree = uint8(0x08)
+
+	// FlagComposite is set when the proof contains proofs for more than one tx.
+	FlagComposite = uint8(0x10)
+
+	NodeTypeHash      = uint8(0x00) // Hash follows
+	NodeTypeDuplicate = uint8(0x01) // Duplicate of the working hash
+	NodeTypeIndex     = uint8(0x02) // Index of a hash in a composite proof
+)
+
+// ParseMerkleProof parses a TSC merkle proof in either the JSON or binary format.
+func ParseMerkleProof(b []byte) (*MerkleProof, error) {
+	result := &MerkleProof{}
+
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := result.UnmarshalJSON(trimmed); err != nil {
+			return nil, errors.Wrap(err, "json")
+		}
+		return result, nil
+	}
+
+	if err := result.Deserialize(bytes.NewReader(b)); err != nil {
+		return nil, errors.Wrap(err, "binary")
+	}
+	return result, nil
+}
+
+// FromWire creates a merkle proof from the merkle proof format used by the wire package.
+func FromWire(p *wire.MerkleProof) *MerkleProof {
+	result := &MerkleProof{
+		Index:       p.Index,
+		BlockHeader: p.BlockHeader,
+		BlockHash:   p.BlockHash,
+		MerkleRoot:  p.MerkleRoot,
+	}
+
+	if p.TxID != nil {
+		txid := *p.TxID
+		result.TxID = &txid
+	}
+	if len(p.Path) > 0 {
+		result.Path = make([]bitcoin.Hash32, len(p.Path))
+		copy(result.Path, p.Path)
+	}
+	if len(p.DuplicatedIndexes) > 0 {
+		result.DuplicatedIndexes = make([]int, len(p.DuplicatedIndexes))
+		copy(result.DuplicatedIndexes, p.DuplicatedIndexes)
+	}
+
+	return result
+}
+
+// ToWire converts the merkle proof to the merkle proof format used by the wire package. The tx is
+// not included in the wire format so only its txid is kept.
+func (mp MerkleProof) ToWire() *wire.MerkleProof {
+	result := &wire.MerkleProof{
+		Index:       mp.Index,
+		BlockHeader: mp.BlockHeader,
+		BlockHash:   mp.BlockHash,
+		MerkleRoot:  mp.MerkleRoot,
+	}
+
+	if mp.TxID != nil {
+		txid := *mp.TxID
+		result.TxID = &txid
+	} else if mp.Tx != nil {
+		result.TxID = mp.Tx.TxHash()
+	}
+	if len(mp.Path) > 0 {
+		result.Path = make([]bitcoin.Hash32, len(mp.Path))
+		copy(result.Path, mp.Path)
+	}
+	if len(mp.DuplicatedIndexes) > 0 {
+		result.DuplicatedIndexes = make([]int, len(mp.DuplicatedIndexes))
+		copy(result.DuplicatedIndexes, mp.DuplicatedIndexes)
+	}
+
+	return result
+}
@@ -0,0 +1,149 @@
+package merkle_proof
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestTSCWithTx(t *testing.T) {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{1}, 0), []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+
+	mt := NewMerkleTree(false)
+	mt.AddMerkleProof(*tx.TxHash())
+	for _, s := range blocks[3].hashes[:4] {
+		hash, err := bitcoin.NewHash32FromStr(s)
+		if err != nil {
+			t.Fatalf("Failed to parse hash string : %s", err)
+		}
+
+		mt.AddHash(*hash)
+	}
+	mt.AddHash(*tx.TxHash()) // odd index 4 requires a duplicate
+
+	root, proofs := mt.FinalizeMerkleProofs()
+	if len(proofs) != 1 {
+		t.Fatalf("Wrong merkle proof count : got %d, want %d", len(proofs), 1)
+	}
+
+	proof := proofs[0]
+	proof.Tx = tx
+	proof.MerkleRoot = &root
+
+	if err := proof.Verify(); err != nil {
+		t.Fatalf("Failed to verify proof : %s", err)
+	}
+
+	b, err := proof.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize : %s", err)
+	}
+
+	if b[0] != FlagTx|FlagTargetMerkleRoot {
+		t.Errorf("Wrong flags : got 0x%02x, want 0x%02x", b[0], FlagTx|FlagTargetMerkleRoot)
+	}
+
+	binaryProof, err := ParseMerkleProof(b)
+	if err != nil {
+		t.Fatalf("Failed to parse binary : %s", err)
+	}
+
+	if binaryProof.Tx == nil {
+		t.Fatalf("Missing binary tx")
+	}
+	if !binaryProof.TxID.Equal(tx.TxHash()) {
+		t.Errorf("Wrong binary txid : got %s, want %s", binaryProof.TxID, tx.TxHash())
+	}
+	if err := binaryProof.Verify(); err != nil {
+		t.Errorf("Failed to verify binary proof : %s", err)
+	}
+
+	js, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("Failed to marshal json : %s", err)
+	}
+	t.Logf("JSON : %s", js)
+
+	jsonProof, err := ParseMerkleProof(js)
+	if err != nil {
+		t.Fatalf("Failed to parse json : %s", err)
+	}
+
+	if jsonProof.Tx == nil {
+		t.Fatalf("Missing json tx")
+	}
+	if err := jsonProof.Verify(); err != nil {
+		t.Errorf("Failed to verify json proof : %s", err)
+	}
+
+	rb, err := jsonProof.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize json proof : %s", err)
+	}
+
+	if !bytes.Equal(rb, b) {
+		t.Errorf("Wrong serialized bytes : \n got  %x\n want %x", rb, b)
+	}
+
+	wireProof := proof.ToWire()
+	wireRoot, err := wireProof.CalculateRoot()
+	if err != nil {
+		t.Fatalf("Failed to calculate wire root : %s", err)
+	}
+	if !wireRoot.Equal(&root) {
+		t.Errorf("Wrong wire root : got %s, want %s", wireRoot, root)
+	}
+
+	fromWire := FromWire(wireProof)
+	if fromWire.Tx != nil {
+		t.Errorf("Tx should not be included from wire")
+	}
+	if err := fromWire.Verify(); err != nil {
+		t.Errorf("Failed to verify from wire proof : %s", err)
+	}
+}
+
+func TestTSCUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "binary tree",
+			data: []byte{FlagProofTypeTree, 0x00},
+		},
+		{
+			name: "binary composite",
+			data: []byte{FlagComposite, 0x00},
+		},
+		{
+			name: "binary index node",
+			data: append(append([]byte{FlagTargetHash, 0x00}, make([]byte, 64)...), 0x01,
+				NodeTypeIndex),
+		},
+		{
+			name: "json tree",
+			data: []byte(`{"index":0,"txOrId":"","target":"","proofType":"tree","nodes":[]}`),
+		},
+		{
+			name: "json composite",
+			data: []byte(`{"index":0,"txOrId":"","target":"","composite":true,"nodes":[]}`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMerkleProof(tt.data)
+			if errors.Cause(err) != ErrUnsupportedProofType {
+				t.Errorf("Wrong error : got %v, want %s", err, ErrUnsupportedProofType)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package merkle_proof
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultVerifierConcurrency is the number of proofs verified at the same time when a
+	// concurrency isn't specified.
+	DefaultVerifierConcurrency = 8
+
+	// UnknownHeight is used as a height when the block height of a proof isn't known.
+	UnknownHeight = -1
+)
+
+var (
+	ErrHeaderNotFound = errors.New("Header Not Found")
+)
+
+// HeaderGetter provides block headers from a trusted source, like a header chain that has been
+// validated by proof of work.
+type HeaderGetter interface {
+	// BlockHeader returns the header of the block with the specified hash.
+	BlockHeader(ctx context.Context, blockHash bitcoin.Hash32) (*wire.BlockHeader, error)
+
+	// BlockHeaderAtHeight returns the header of the block at the specified height in the longest
+	// chain.
+	BlockHeaderAtHeight(ctx context.Context, height int) (*wire.BlockHeader, error)
+}
+
+// Verifier verifies batches of merkle proofs against headers from a header source.
+type Verifier struct {
+	headers     HeaderGetter
+	concurrency int
+}
+
+// VerifyResult is the result of verifying one merkle proof. BlockHeader is the trusted header that
+// the proof was verified against. Err is nil when the proof is valid.
+type VerifyResult struct {
+	BlockHeader *wire.BlockHeader
+	Err         error
+}
+
+// NewVerifier creates a verifier that verifies up to concurrency proofs at the same time. A
+// concurrency less than one uses DefaultVerifierConcurrency.
+func NewVerifier(headers HeaderGetter, concurrency int) *Verifier {
+	if concurrency < 1 {
+		concurrency = DefaultVerifierConcurrency
+	}
+
+	return &Verifier{
+		headers:     headers,
+		concurrency: concurrency,
+	}
+}
+
+// Verify verifies the proofs against the header source and returns a result for each proof in the
+// same order. Proofs must target a block hash or block header since there is no height to look up
+// a header for a merkle root.
+func (v *Verifier) Verify(ctx context.Context, proofs []*MerkleProof) []VerifyResult {
+	return v.VerifyAtHeights(ctx, proofs, nil)
+}
+
+// VerifyAtHeights verifies the proofs against the header source and returns a result for each
+// proof in the same order. heights contains the block height of each proof, or UnknownHeight, and
+// is used to look up the header of proofs that only target a merkle root. heights can be nil.
+func (v *Verifier) VerifyAtHeights(ctx context.Context, proofs []*MerkleProof,
+	heights []int) []VerifyResult {
+
+	results := make([]VerifyResult, len(proofs))
+	cache := newHeaderCache(v.headers)
+
+	indexes := make(chan int)
+	var wait sync.WaitGroup
+	for i := 0; i < v.concurrency && i < len(proofs); i++ {
+		wait.Add(1)
+		go func() {
+			for index := range indexes {
+				height := UnknownHeight
+				if index < len(heights) {
+					height = heights[index]
+				}
+
+				results[index] = cache.verify(ctx, proofs[index], height)
+			}
+			wait.Done()
+		}()
+	}
+
+	for index := range proofs {
+		indexes <- index
+	}
+	close(indexes)
+	wait.Wait()
+
+	return results
+}
+
+// headerCache keeps the headers retrieved during a batch so that the many proofs for the same
+// block only retrieve its header once. The lock only protects the maps. Concurrent proofs for a
+// header that is being retrieved wait for that retrieval instead of starting another one, while
+// retrievals of other headers continue in parallel.
+type headerCache struct {
+	headers  HeaderGetter
+	byHash   map[bitcoin.Hash32]*headerFetch
+	byHeight map[int]*headerFetch
+
+	sync.Mutex
+}
+
+// headerFetch is the retrieval of one header. done is closed when header and err are set.
+type headerFetch struct {
+	header *wire.BlockHeader
+	err    error
+	done   chan struct{}
+}
+
+func newHeaderCache(headers HeaderGetter) *headerCache {
+	return &headerCache{
+		headers:  headers,
+		byHash:   make(map[bitcoin.Hash32]*headerFetch),
+		byHeight: make(map[int]*headerFetch),
+	}
+}
+
+func (c *headerCache) verify(ctx context.Context, proof *MerkleProof, height int) VerifyResult {
+	if err := ctx.Err(); err != nil {
+		return VerifyResult{Err: err}
+	}
+
+	if proof == nil {
+		return VerifyResult{Err: ErrMissingTarget}
+	}
+
+	root, err := proof.CalculateRoot()
+	if err != nil {
+		return VerifyResult{Err: errors.Wrap(err, "calculate root")}
+	}
+
+	header, err := c.header(ctx, proof, height)
+	if err != nil {
+		return VerifyResult{Err: err}
+	}
+
+	if !header.MerkleRoot.Equal(&root) {
+		return VerifyResult{BlockHeader: header, Err: errors.Wrap(ErrWrongMerkleRoot, "header")}
+	}
+
+	if proof.MerkleRoot != nil && !proof.MerkleRoot.Equal(&root) {
+		return VerifyResult{
+			BlockHeader: header,
+			Err:         errors.Wrap(ErrWrongMerkleRoot, "merkle root"),
+		}
+	}
+
+	return VerifyResult{BlockHeader: header}
+}
+
+// header returns the trusted header for the proof's target.
+func (c *headerCache) header(ctx context.Context, proof *MerkleProof,
+	height int) (*wire.BlockHeader, error) {
+
+	if proof.BlockHash != nil {
+		return c.headerByHash(ctx, *proof.BlockHash)
+	}
+
+	if proof.BlockHeader != nil {
+		// The header in the proof isn't trusted so the header with the same hash is used.
+		return c.headerByHash(ctx, *proof.BlockHeader.BlockHash())
+	}
+
+	if height != UnknownHeight {
+		return c.headerByHeight(ctx, height)
+	}
+
+	if proof.MerkleRoot == nil {
+		return nil, ErrMissingTarget
+	}
+	return nil, errors.Wrap(ErrNotVerifiable, "unknown height")
+}
+
+func (c *headerCache) headerByHash(ctx context.Context,
+	hash bitcoin.Hash32) (*wire.BlockHeader, error) {
+
+	c.Lock()
+	fetch, exists := c.byHash[hash]
+	if !exists {
+		fetch = &headerFetch{done: make(chan struct{})}
+		c.byHash[hash] = fetch
+	}
+	c.Unlock()
+
+	if exists {
+		return fetch.wait(ctx)
+	}
+
+	header, err := c.headers.BlockHeader(ctx, hash)
+	if err != nil {
+		err = errors.Wrapf(err, "header %s", hash)
+	} else if header == nil {
+		err = errors.Wrapf(ErrHeaderNotFound, "%s", hash)
+	}
+
+	return fetch.complete(header, err)
+}
+
+func (c *headerCache) headerByHeight(ctx context.Context, height int) (*wire.BlockHeader, error) {
+	c.Lock()
+	fetch, exists := c.byHeight[height]
+	if !exists {
+		fetch = &headerFetch{done: make(chan struct{})}
+		c.byHeight[height] = fetch
+	}
+	c.Unlock()
+
+	if exists {
+		return fetch.wait(ctx)
+	}
+
+	header, err := c.headers.BlockHeaderAtHeight(ctx, height)
+	if err != nil {
+		err = errors.Wrapf(err, "header at height %d", height)
+	} else if header == nil {
+		err = errors.Wrapf(ErrHeaderNotFound, "height %d", height)
+	}
+
+	return fetch.complete(header, err)
+}
+
+// wait returns the result of the retrieval when it completes.
+func (f *headerFetch) wait(ctx context.Context) (*wire.BlockHeader, error) {
+	select {
+	case <-f.done:
+		return f.header, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// complete sets the result of the retrieval and releases the proofs waiting for it.
+func (f *headerFetch) complete(header *wire.BlockHeader, err error) (*wire.BlockHeader, error) {
+	if err != nil {
+		header = nil
+	}
+
+	f.header = header
+	f.err = err
+	close(f.done)
+	return header, err
+}
+
+// VerifyBUMP verifies the paths in a BUMP against the header at the BUMP's block height and returns
+// the header.
+func (v *Verifier) VerifyBUMP(ctx context.Context, bump *BUMP) (*wire.BlockHeader, error) {
+	cache := newHeaderCache(v.headers)
+	header, err := cache.headerByHeight(ctx, int(bump.BlockHeight))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bump.Verify(header.MerkleRoot); err != nil {
+		return header, err
+	}
+
+	return header, nil
+}
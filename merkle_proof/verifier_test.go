@@ -0,0 +1,249 @@
+package merkle_proof
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+type mockHeaders struct {
+	headers []*wire.BlockHeader
+	calls   int
+
+	sync.Mutex
+}
+
+func (m *mockHeaders) BlockHeader(ctx context.Context,
+	blockHash bitcoin.Hash32) (*wire.BlockHeader, error) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.calls++
+	for _, header := range m.headers {
+		if header.BlockHash().Equal(&blockHash) {
+			return header, nil
+		}
+	}
+
+	return nil, ErrHeaderNotFound
+}
+
+func (m *mockHeaders) BlockHeaderAtHeight(ctx context.Context,
+	height int) (*wire.BlockHeader, error) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.calls++
+	if height < 0 || height >= len(m.headers) {
+		return nil, ErrHeaderNotFound
+	}
+
+	return m.headers[height], nil
+}
+
+func TestVerifier(t *testing.T) {
+	mt := NewMerkleTree(false)
+	for _, s := range blocks[3].hashes {
+		hash, err := bitcoin.NewHash32FromStr(s)
+		if err != nil {
+			t.Fatalf("Failed to parse hash string : %s", err)
+		}
+
+		mt.AddMerkleProof(*hash)
+		mt.AddHash(*hash)
+	}
+
+	root, proofs := mt.FinalizeMerkleProofs()
+	if len(proofs) != len(blocks[3].hashes) {
+		t.Fatalf("Wrong merkle proof count : got %d, want %d", len(proofs),
+			len(blocks[3].hashes))
+	}
+
+	header := wire.NewBlockHeader(1, &bitcoin.Hash32{}, &root, 1, 0)
+	otherRoot := bitcoin.Hash32{1}
+	otherHeader := wire.NewBlockHeader(1, header.BlockHash(), &otherRoot, 1, 0)
+	headers := &mockHeaders{
+		headers: []*wire.BlockHeader{header, otherHeader},
+	}
+
+	var batch []*MerkleProof
+	var heights []int
+	for _, proof := range proofs {
+		proof.BlockHash = header.BlockHash()
+		batch = append(batch, proof)
+		heights = append(heights, UnknownHeight)
+	}
+
+	byHeader := *proofs[0]
+	byHeader.BlockHash = nil
+	byHeader.BlockHeader = header
+	batch = append(batch, &byHeader)
+	heights = append(heights, UnknownHeight)
+
+	byHeight := *proofs[1]
+	byHeight.BlockHash = nil
+	byHeight.MerkleRoot = &root
+	batch = append(batch, &byHeight)
+	heights = append(heights, 0)
+
+	wrongBlock := *proofs[2]
+	wrongBlock.BlockHash = otherHeader.BlockHash()
+	batch = append(batch, &wrongBlock)
+	heights = append(heights, UnknownHeight)
+
+	unknownBlock := *proofs[3]
+	unknownBlock.BlockHash = &bitcoin.Hash32{2}
+	batch = append(batch, &unknownBlock)
+	heights = append(heights, UnknownHeight)
+
+	unknownHeight := *proofs[4]
+	unknownHeight.BlockHash = nil
+	unknownHeight.MerkleRoot = &root
+	batch = append(batch, &unknownHeight)
+	heights = append(heights, UnknownHeight)
+
+	wantErrs := make([]error, len(batch))
+	wantErrs[len(batch)-3] = ErrWrongMerkleRoot
+	wantErrs[len(batch)-2] = ErrHeaderNotFound
+	wantErrs[len(batch)-1] = ErrNotVerifiable
+
+	verifier := NewVerifier(headers, 3)
+	results := verifier.VerifyAtHeights(context.Background(), batch, heights)
+
+	if len(results) != len(batch) {
+		t.Fatalf("Wrong result count : got %d, want %d", len(results), len(batch))
+	}
+
+	for i, result := range results {
+		if errors.Cause(result.Err) != wantErrs[i] {
+			t.Errorf("Wrong result %d error : got %v, want %v", i, result.Err, wantErrs[i])
+		}
+	}
+
+	// The header for the valid block, the wrong block, the unknown block, and the height.
+	if headers.calls != 4 {
+		t.Errorf("Wrong header call count : got %d, want %d", headers.calls, 4)
+	}
+}
+
+func TestVerifierCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	proof := NewMerkleProof(bitcoin.Hash32{1})
+	proof.Index = 0
+	proof.BlockHash = &bitcoin.Hash32{2}
+
+	verifier := NewVerifier(&mockHeaders{}, 0)
+	results := verifier.Verify(ctx, []*MerkleProof{proof})
+
+	if len(results) != 1 {
+		t.Fatalf("Wrong result count : got %d, want %d", len(results), 1)
+	}
+
+	if results[0].Err != context.Canceled {
+		t.Errorf("Wrong error : got %v, want %v", results[0].Err, context.Canceled)
+	}
+}
+
+// blockingHeaders returns headers by hash and blocks retrievals of the slow hash until release is
+// closed.
+type blockingHeaders struct {
+	slow    bitcoin.Hash32
+	started chan struct{}
+	release chan struct{}
+	calls   map[bitcoin.Hash32]int
+
+	sync.Mutex
+}
+
+func (m *blockingHeaders) BlockHeader(ctx context.Context,
+	blockHash bitcoin.Hash32) (*wire.BlockHeader, error) {
+
+	m.Lock()
+	m.calls[blockHash]++
+	m.Unlock()
+
+	if blockHash.Equal(&m.slow) {
+		close(m.started)
+		<-m.release
+	}
+
+	return &wire.BlockHeader{MerkleRoot: blockHash}, nil
+}
+
+func (m *blockingHeaders) BlockHeaderAtHeight(ctx context.Context,
+	height int) (*wire.BlockHeader, error) {
+	return nil, ErrHeaderNotFound
+}
+
+func TestHeaderCacheConcurrentFetch(t *testing.T) {
+	ctx := context.Background()
+	slow := bitcoin.Hash32{1}
+	fast := bitcoin.Hash32{2}
+
+	headers := &blockingHeaders{
+		slow:    slow,
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		calls:   make(map[bitcoin.Hash32]int),
+	}
+	cache := newHeaderCache(headers)
+
+	var wait sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			header, err := cache.headerByHash(ctx, slow)
+			if err == nil && !header.MerkleRoot.Equal(&slow) {
+				err = errors.New("wrong header")
+			}
+			errs <- err
+		}()
+	}
+
+	<-headers.started
+
+	// The slow retrieval must not block retrievals of other headers.
+	fastDone := make(chan error, 1)
+	go func() {
+		_, err := cache.headerByHash(ctx, fast)
+		fastDone <- err
+	}()
+
+	select {
+	case err := <-fastDone:
+		if err != nil {
+			t.Fatalf("Failed to get fast header : %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Fast header blocked by slow header retrieval")
+	}
+
+	close(headers.release)
+	wait.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Failed to get slow header : %s", err)
+		}
+	}
+
+	if headers.calls[slow] != 1 {
+		t.Errorf("Wrong slow header call count : got %d, want %d", headers.calls[slow], 1)
+	}
+	if headers.calls[fast] != 1 {
+		t.Errorf("Wrong fast header call count : got %d, want %d", headers.calls[fast], 1)
+	}
+}
@@ -0,0 +1,350 @@
+package peer_channels
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/threads"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// Client is a client for a peer channels service. Account requests are authorized with the account
+// token and channel requests are authorized with a channel access token.
+type Client struct {
+	BaseURL string
+}
+
+// NewClient creates a client for the peer channels service at the base URL.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// CreateChannel creates a new channel owned by the account.
+func (c *Client) CreateChannel(ctx context.Context, accountID, token string,
+	request CreateChannelRequest) (*Channel, error) {
+
+	url := fmt.Sprintf("%s/api/v1/account/%s/channel", c.BaseURL, accountID)
+	result := &Channel{}
+	if err := postJSON(ctx, url, token, request, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListChannels returns the channels owned by the account.
+func (c *Client) ListChannels(ctx context.Context, accountID, token string) ([]*Channel, error) {
+	url := fmt.Sprintf("%s/api/v1/account/%s/channel/list", c.BaseURL, accountID)
+	var result ChannelList
+	if _, err := send(ctx, http.MethodGet, url, token, "", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Channels, nil
+}
+
+// GetChannel returns a channel owned by the account.
+func (c *Client) GetChannel(ctx context.Context, accountID, channelID,
+	token string) (*Channel, error) {
+
+	url := fmt.Sprintf("%s/api/v1/account/%s/channel/%s", c.BaseURL, accountID, channelID)
+	result := &Channel{}
+	if _, err := send(ctx, http.MethodGet, url, token, "", nil, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// DeleteChannel deletes a channel owned by the account and all of its messages.
+func (c *Client) DeleteChannel(ctx context.Context, accountID, channelID, token string) error {
+	url := fmt.Sprintf("%s/api/v1/account/%s/channel/%s", c.BaseURL, accountID, channelID)
+	_, err := send(ctx, http.MethodDelete, url, token, "", nil, nil)
+	return err
+}
+
+// CreateToken creates a new access token for a channel owned by the account.
+func (c *Client) CreateToken(ctx context.Context, accountID, channelID, token string,
+	request CreateTokenRequest) (*AccessToken, error) {
+
+	url := fmt.Sprintf("%s/api/v1/account/%s/channel/%s/api-token", c.BaseURL, accountID,
+		channelID)
+	result := &AccessToken{}
+	if err := postJSON(ctx, url, token, request, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// WriteMessage writes a message to the channel and returns the message with its sequence.
+func (c *Client) WriteMessage(ctx context.Context, channelID, token, contentType string,
+	payload []byte) (*Message, error) {
+
+	url := fmt.Sprintf("%s/api/v1/channel/%s", c.BaseURL, channelID)
+	result := &Message{}
+	if _, err := send(ctx, http.MethodPost, url, token, contentType, payload,
+		result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// WriteJSONMessage writes the JSON of the value to the channel.
+func (c *Client) WriteJSONMessage(ctx context.Context, channelID, token string,
+	value interface{}) (*Message, error) {
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal")
+	}
+
+	return c.WriteMessage(ctx, channelID, token, ContentTypeJSON, b)
+}
+
+// GetMessages returns the messages in the channel, or only the unread messages, in sequence order.
+func (c *Client) GetMessages(ctx context.Context, channelID, token string,
+	unread bool) (Messages, error) {
+
+	url := fmt.Sprintf("%s/api/v1/channel/%s?unread=%t", c.BaseURL, channelID, unread)
+	var result Messages
+	if _, err := send(ctx, http.MethodGet, url, token, "", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetMaxMessageSequence returns the sequence of the latest message in the channel.
+func (c *Client) GetMaxMessageSequence(ctx context.Context, channelID,
+	token string) (uint32, error) {
+
+	url := fmt.Sprintf("%s/api/v1/channel/%s", c.BaseURL, channelID)
+	headers, err := send(ctx, http.MethodHead, url, token, "", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	tag := headers.Get("ETag")
+	if len(tag) == 0 {
+		return 0, errors.New("Missing tag")
+	}
+
+	max, err := strconv.ParseUint(tag, 10, 32)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse tag")
+	}
+
+	return uint32(max), nil
+}
+
+// MarkMessages marks the message with the sequence as read or unread. When older is true all older
+// messages are also marked.
+func (c *Client) MarkMessages(ctx context.Context, channelID, token string, sequence uint32,
+	read, older bool) error {
+
+	url := fmt.Sprintf("%s/api/v1/channel/%s/%d?older=%t", c.BaseURL, channelID, sequence,
+		older)
+	return postJSON(ctx, url, token, MarkMessagesRequest{Read: read}, nil)
+}
+
+// DeleteMessage deletes the message with the sequence from the channel.
+func (c *Client) DeleteMessage(ctx context.Context, channelID, token string,
+	sequence uint32) error {
+
+	url := fmt.Sprintf("%s/api/v1/channel/%s/%d", c.BaseURL, channelID, sequence)
+	_, err := send(ctx, http.MethodDelete, url, token, "", nil, nil)
+	return err
+}
+
+// WaitForMessages polls the channel at the poll interval until there are unread messages and then
+// returns them. It returns threads.Interrupted if the interrupt is closed first.
+func (c *Client) WaitForMessages(ctx context.Context, channelID, token string,
+	pollInterval time.Duration, interrupt <-chan interface{}) (Messages, error) {
+
+	for {
+		messages, err := c.GetMessages(ctx, channelID, token, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(messages) > 0 {
+			return messages, nil
+		}
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-interrupt:
+			return nil, threads.Interrupted
+		}
+	}
+}
+
+// Listen opens a websocket to the channel and feeds new messages into incoming until the interrupt
+// is closed, in which case it returns threads.Interrupted, or the connection closes.
+func (c *Client) Listen(ctx context.Context, channelID, token string, incoming chan<- *Message,
+	interrupt <-chan interface{}) error {
+
+	url := fmt.Sprintf("%s/api/v1/channel/%s/notify", c.BaseURL, channelID)
+	if strings.HasPrefix(url, "http") {
+		url = "ws" + strings.TrimPrefix(url, "http")
+	}
+
+	header := make(http.Header)
+	header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return errors.Wrap(err, "dial")
+	}
+	defer conn.Close()
+
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			messageType, b, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure,
+					websocket.CloseGoingAway) {
+					readErr <- nil
+				} else {
+					readErr <- errors.Wrap(err, "read")
+				}
+				return
+			}
+
+			if messageType != websocket.TextMessage {
+				readErr <- fmt.Errorf("Wrong message type : got %d, want %d", messageType,
+					websocket.TextMessage)
+				return
+			}
+
+			message := &Message{}
+			if err := json.Unmarshal(b, message); err != nil {
+				readErr <- errors.Wrap(err, "unmarshal")
+				return
+			}
+
+			select {
+			case incoming <- message:
+			case <-interrupt:
+				readErr <- threads.Interrupted
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case err := <-readErr:
+			return err
+
+		case <-time.After(30 * time.Second): // send ping every 30 seconds to keep alive
+			if err := conn.WriteControl(websocket.PingMessage, []byte("ping"),
+				time.Now().Add(time.Second)); err != nil {
+				return errors.Wrap(err, "send ping")
+			}
+
+		case <-interrupt:
+			// Cleanly close the connection by sending a close message and then waiting, with a
+			// timeout, for the server to close the connection.
+			if err := conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
+				return errors.Wrap(err, "send close")
+			}
+
+			select {
+			case <-readErr:
+			case <-time.After(time.Second):
+			}
+			return threads.Interrupted
+		}
+	}
+}
+
+// postJSON sends the JSON of the request to the HTTP server using the POST method.
+func postJSON(ctx context.Context, url, token string, request, response interface{}) error {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "marshal request")
+	}
+
+	_, err = send(ctx, http.MethodPost, url, token, ContentTypeJSON, b, response)
+	return err
+}
+
+// send sends a request to the HTTP server with an authentication bearer token header and decodes
+// the JSON response into response when it isn't nil. It returns the response headers.
+func send(ctx context.Context, method, url, token, contentType string, body []byte,
+	response interface{}) (http.Header, error) {
+
+	var transport = &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout: 5 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 5 * time.Second,
+	}
+
+	var client = &http.Client{
+		Timeout:   time.Second * 10,
+		Transport: transport,
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+
+	// Authorization: Bearer <token>
+	httpRequest.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	if len(contentType) > 0 {
+		httpRequest.Header.Add("Content-Type", contentType)
+	}
+
+	httpResponse, err := client.Do(httpRequest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "http %s", strings.ToLower(method))
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		b, rerr := ioutil.ReadAll(httpResponse.Body)
+		if rerr == nil {
+			return nil, HTTPError{
+				Status:  httpResponse.StatusCode,
+				Message: string(b),
+			}
+		}
+
+		return nil, HTTPError{Status: httpResponse.StatusCode}
+	}
+
+	if response != nil {
+		if err := json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
+			return nil, errors.Wrap(err, "decode response")
+		}
+	}
+
+	return httpResponse.Header, nil
+}
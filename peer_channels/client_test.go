@@ -0,0 +1,152 @@
+package peer_channels
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/json"
+)
+
+// mockChannel is an HTTP handler for the message endpoints of a single channel.
+type mockChannel struct {
+	id       string
+	token    string
+	messages Messages
+	read     map[uint32]bool
+
+	sync.Mutex
+}
+
+func (m *mockChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+m.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := "/api/v1/channel/" + m.id
+	switch {
+	case r.URL.Path == path && r.Method == http.MethodPost:
+		b, _ := ioutil.ReadAll(r.Body)
+		message := &Message{
+			Sequence:    uint32(len(m.messages) + 1),
+			Received:    time.Now(),
+			ContentType: r.Header.Get("Content-Type"),
+			Payload:     b,
+		}
+		m.messages = append(m.messages, message)
+		json.NewEncoder(w).Encode(message)
+
+	case r.URL.Path == path && r.Method == http.MethodHead:
+		w.Header().Set("ETag", fmt.Sprintf("%d", len(m.messages)))
+
+	case r.URL.Path == path && r.Method == http.MethodGet:
+		unread := r.URL.Query().Get("unread") == "true"
+		result := Messages{}
+		for _, message := range m.messages {
+			if !unread || !m.read[message.Sequence] {
+				result = append(result, message)
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+
+	case r.Method == http.MethodPost:
+		var sequence uint32
+		if _, err := fmt.Sscanf(r.URL.Path, path+"/%d", &sequence); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var request MarkMessagesRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		older := r.URL.Query().Get("older") == "true"
+		for _, message := range m.messages {
+			if message.Sequence == sequence || (older && message.Sequence < sequence) {
+				m.read[message.Sequence] = request.Read
+			}
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClientMessages(t *testing.T) {
+	ctx := context.Background()
+
+	channel := &mockChannel{
+		id:    "channel",
+		token: "token",
+		read:  make(map[uint32]bool),
+	}
+	server := httptest.NewServer(channel)
+	defer server.Close()
+
+	client := NewClient(server.URL + "/")
+
+	if _, err := client.GetMessages(ctx, "channel", "wrong", false); err == nil {
+		t.Fatalf("Get messages should fail with wrong token")
+	}
+
+	if _, err := client.GetMessages(ctx, "other", "token", false); !IsNotFound(err) {
+		t.Fatalf("Wrong error : got %v, want not found", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		message, err := client.WriteMessage(ctx, "channel", "token", ContentTypeBinary,
+			[]byte{byte(i)})
+		if err != nil {
+			t.Fatalf("Failed to write message : %s", err)
+		}
+
+		if message.Sequence != uint32(i+1) {
+			t.Errorf("Wrong sequence : got %d, want %d", message.Sequence, i+1)
+		}
+	}
+
+	max, err := client.GetMaxMessageSequence(ctx, "channel", "token")
+	if err != nil {
+		t.Fatalf("Failed to get max sequence : %s", err)
+	}
+
+	if max != 3 {
+		t.Errorf("Wrong max sequence : got %d, want %d", max, 3)
+	}
+
+	if err := client.MarkMessages(ctx, "channel", "token", 2, true, true); err != nil {
+		t.Fatalf("Failed to mark messages : %s", err)
+	}
+
+	messages, err := client.WaitForMessages(ctx, "channel", "token", time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Failed to wait for messages : %s", err)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("Wrong unread message count : got %d, want %d", len(messages), 1)
+	}
+
+	if messages[0].Sequence != 3 || !bytes.Equal(messages[0].Payload, []byte{2}) {
+		t.Errorf("Wrong unread message : %+v", messages[0])
+	}
+
+	if err := client.MarkMessages(ctx, "channel", "token", 3, true, false); err != nil {
+		t.Fatalf("Failed to mark messages : %s", err)
+	}
+
+	interrupt := make(chan interface{})
+	close(interrupt)
+	if _, err := client.WaitForMessages(ctx, "channel", "token", time.Millisecond,
+		interrupt); err == nil {
+		t.Fatalf("Wait for messages should be interrupted")
+	}
+}
@@ -0,0 +1,195 @@
+package peer_channels
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ContentTypeEncrypted is the content type of messages containing an EncryptedMessage.
+	ContentTypeEncrypted = "application/vnd.tokenized.encrypted+json"
+
+	contentKeySize = 64 // 32 byte AES key followed by 32 byte HMAC key
+)
+
+var (
+	ErrNotEncrypted = errors.New("Not Encrypted")
+	ErrNotRecipient = errors.New("Not Recipient")
+	ErrInvalidMAC   = errors.New("Invalid MAC")
+)
+
+// EncryptedMessage is a message payload encrypted so that only the recipients can read it. The
+// payload is encrypted with a random content key and the content key is encrypted for each
+// recipient with a secret from ECDH between an ephemeral key and the recipient's public key.
+type EncryptedMessage struct {
+	// EphemeralKey is the public key of the random key used for key agreement with the recipients.
+	EphemeralKey bitcoin.PublicKey `json:"ephemeral_key"`
+
+	// SenderKey is an optional public key identifying the sender. It is not authenticated.
+	SenderKey *bitcoin.PublicKey `json:"sender_key,omitempty"`
+
+	Recipients  []EncryptedKey `json:"recipients"`
+	ContentType string         `json:"content_type"`
+	Payload     []byte         `json:"payload"`
+	MAC         []byte         `json:"mac"` // HMAC-SHA256 of the content type and encrypted payload
+}
+
+// EncryptedKey is the content key encrypted for one recipient.
+type EncryptedKey struct {
+	PublicKey bitcoin.PublicKey `json:"public_key"`
+	Key       []byte            `json:"key"`
+}
+
+// EncryptMessage encrypts the payload so that it can only be decrypted by the keys of the
+// recipients' public keys. The sender is optional and is only included to identify the sender.
+func EncryptMessage(contentType string, payload []byte, sender *bitcoin.PublicKey,
+	recipients ...bitcoin.PublicKey) (*EncryptedMessage, error) {
+
+	if len(recipients) == 0 {
+		return nil, errors.New("Missing recipients")
+	}
+
+	ephemeralKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate key")
+	}
+
+	contentKey := make([]byte, contentKeySize)
+	if _, err := rand.Read(contentKey); err != nil {
+		return nil, errors.Wrap(err, "rand content key")
+	}
+
+	encryptedPayload, err := bitcoin.Encrypt(payload, contentKey[:32])
+	if err != nil {
+		return nil, errors.Wrap(err, "encrypt payload")
+	}
+
+	result := &EncryptedMessage{
+		EphemeralKey: ephemeralKey.PublicKey(),
+		SenderKey:    sender,
+		ContentType:  contentType,
+		Payload:      encryptedPayload,
+		MAC:          messageMAC(contentKey[32:], contentType, encryptedPayload),
+	}
+
+	for _, recipient := range recipients {
+		secret, err := sharedSecret(ephemeralKey, recipient)
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret %s", recipient)
+		}
+
+		encryptedKey, err := bitcoin.Encrypt(contentKey, secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "encrypt key %s", recipient)
+		}
+
+		result.Recipients = append(result.Recipients, EncryptedKey{
+			PublicKey: recipient,
+			Key:       encryptedKey,
+		})
+	}
+
+	return result, nil
+}
+
+// Decrypt returns the content type and payload of the message when the key is one of the
+// recipients. It returns ErrNotRecipient when the key isn't a recipient and ErrInvalidMAC when the
+// message was modified.
+func (m EncryptedMessage) Decrypt(key bitcoin.Key) (string, []byte, error) {
+	publicKey := key.PublicKey()
+	for _, recipient := range m.Recipients {
+		if !recipient.PublicKey.Equal(publicKey) {
+			continue
+		}
+
+		secret, err := sharedSecret(key, m.EphemeralKey)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "secret")
+		}
+
+		contentKey, err := bitcoin.Decrypt(recipient.Key, secret)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "decrypt key")
+		}
+
+		if len(contentKey) != contentKeySize {
+			return "", nil, ErrInvalidMAC
+		}
+
+		if !hmac.Equal(m.MAC, messageMAC(contentKey[32:], m.ContentType, m.Payload)) {
+			return "", nil, ErrInvalidMAC
+		}
+
+		payload, err := bitcoin.Decrypt(m.Payload, contentKey[:32])
+		if err != nil {
+			return "", nil, errors.Wrap(err, "decrypt payload")
+		}
+
+		return m.ContentType, payload, nil
+	}
+
+	return "", nil, ErrNotRecipient
+}
+
+// EncryptedMessage decodes the message's payload as an encrypted message.
+func (m Message) EncryptedMessage() (*EncryptedMessage, error) {
+	if m.ContentType != ContentTypeEncrypted {
+		return nil, errors.Wrapf(ErrNotEncrypted, "content type %s", m.ContentType)
+	}
+
+	result := &EncryptedMessage{}
+	if err := json.Unmarshal(m.Payload, result); err != nil {
+		return nil, errors.Wrap(err, "unmarshal")
+	}
+
+	return result, nil
+}
+
+// WriteEncryptedMessage encrypts the payload for the recipients and writes it to the channel.
+func (c *Client) WriteEncryptedMessage(ctx context.Context, channelID, token, contentType string,
+	payload []byte, sender *bitcoin.PublicKey,
+	recipients ...bitcoin.PublicKey) (*Message, error) {
+
+	encrypted, err := EncryptMessage(contentType, payload, sender, recipients...)
+	if err != nil {
+		return nil, errors.Wrap(err, "encrypt")
+	}
+
+	b, err := json.Marshal(encrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal")
+	}
+
+	return c.WriteMessage(ctx, channelID, token, ContentTypeEncrypted, b)
+}
+
+// sharedSecret returns the hash of the ECDH shared secret between the key and public key.
+func sharedSecret(key bitcoin.Key, publicKey bitcoin.PublicKey) ([]byte, error) {
+	secret, err := bitcoin.ECDHSecret(key, publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ecdh")
+	}
+
+	// Pad to 32 bytes so the hash doesn't depend on leading zeros.
+	if len(secret) < 32 {
+		secret = append(make([]byte, 32-len(secret)), secret...)
+	}
+
+	return bitcoin.Sha256(secret), nil
+}
+
+// messageMAC returns the HMAC of the content type and encrypted payload.
+func messageMAC(key []byte, contentType string, encryptedPayload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(contentType))
+	mac.Write([]byte{0})
+	mac.Write(encryptedPayload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,80 @@
+package peer_channels
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/json"
+
+	"github.com/pkg/errors"
+)
+
+func TestEncryptMessage(t *testing.T) {
+	sender, _ := bitcoin.GenerateKey(bitcoin.MainNet)
+	recipient1, _ := bitcoin.GenerateKey(bitcoin.MainNet)
+	recipient2, _ := bitcoin.GenerateKey(bitcoin.MainNet)
+	other, _ := bitcoin.GenerateKey(bitcoin.MainNet)
+
+	senderKey := sender.PublicKey()
+	payload := []byte("secret message payload")
+
+	encrypted, err := EncryptMessage(ContentTypeBinary, payload, &senderKey,
+		recipient1.PublicKey(), recipient2.PublicKey())
+	if err != nil {
+		t.Fatalf("Failed to encrypt message : %s", err)
+	}
+
+	if bytes.Contains(encrypted.Payload, payload) {
+		t.Fatalf("Encrypted payload contains plain text")
+	}
+
+	// Encode and decode as it would be written to a channel.
+	b, err := json.Marshal(encrypted)
+	if err != nil {
+		t.Fatalf("Failed to marshal message : %s", err)
+	}
+
+	message := Message{
+		ContentType: ContentTypeEncrypted,
+		Payload:     b,
+	}
+
+	decoded, err := message.EncryptedMessage()
+	if err != nil {
+		t.Fatalf("Failed to decode encrypted message : %s", err)
+	}
+
+	if decoded.SenderKey == nil || !decoded.SenderKey.Equal(senderKey) {
+		t.Errorf("Wrong sender key : got %v, want %s", decoded.SenderKey, senderKey)
+	}
+
+	for _, key := range []bitcoin.Key{recipient1, recipient2} {
+		contentType, decrypted, err := decoded.Decrypt(key)
+		if err != nil {
+			t.Fatalf("Failed to decrypt message : %s", err)
+		}
+
+		if contentType != ContentTypeBinary {
+			t.Errorf("Wrong content type : got %s, want %s", contentType, ContentTypeBinary)
+		}
+
+		if !bytes.Equal(decrypted, payload) {
+			t.Errorf("Wrong payload : got %x, want %x", decrypted, payload)
+		}
+	}
+
+	if _, _, err := decoded.Decrypt(other); errors.Cause(err) != ErrNotRecipient {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrNotRecipient)
+	}
+
+	decoded.Payload[len(decoded.Payload)-1] ^= 0x01
+	if _, _, err := decoded.Decrypt(recipient1); errors.Cause(err) != ErrInvalidMAC {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrInvalidMAC)
+	}
+
+	message.ContentType = ContentTypeJSON
+	if _, err := message.EncryptedMessage(); errors.Cause(err) != ErrNotEncrypted {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrNotEncrypted)
+	}
+}
@@ -0,0 +1,96 @@
+package peer_channels
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ContentTypeJSON   = "application/json"
+	ContentTypeBinary = "application/octet-stream"
+)
+
+// Message is a message written to a peer channel. Sequence is assigned by the service when the
+// message is written and increases with each message in the channel.
+type Message struct {
+	Sequence    uint32    `json:"sequence"`
+	Received    time.Time `json:"received"`
+	ContentType string    `json:"content_type"`
+	Payload     []byte    `json:"payload"`
+}
+
+type Messages []*Message
+
+// Channel is a peer channel owned by an account.
+type Channel struct {
+	ID           string        `json:"id"`
+	Path         string        `json:"href"`
+	PublicRead   bool          `json:"public_read"`
+	PublicWrite  bool          `json:"public_write"`
+	Sequenced    bool          `json:"sequenced"`
+	Locked       bool          `json:"locked"`
+	Head         uint32        `json:"head"`
+	Retention    Retention     `json:"retention"`
+	AccessTokens []AccessToken `json:"access_tokens"`
+}
+
+type Retention struct {
+	MinAgeDays int  `json:"min_age_days"`
+	MaxAgeDays int  `json:"max_age_days"`
+	AutoPrune  bool `json:"auto_prune"`
+}
+
+// AccessToken is a bearer token that grants access to read and/or write a channel's messages.
+type AccessToken struct {
+	ID          string `json:"id"`
+	Token       string `json:"token"`
+	Description string `json:"description"`
+	CanRead     bool   `json:"can_read"`
+	CanWrite    bool   `json:"can_write"`
+}
+
+type ChannelList struct {
+	Channels []*Channel `json:"channels"`
+}
+
+// CreateChannelRequest specifies the properties of a new channel.
+type CreateChannelRequest struct {
+	PublicRead  bool      `json:"public_read"`
+	PublicWrite bool      `json:"public_write"`
+	Sequenced   bool      `json:"sequenced"`
+	Retention   Retention `json:"retention"`
+}
+
+// CreateTokenRequest specifies the permissions of a new channel access token.
+type CreateTokenRequest struct {
+	Description string `json:"description"`
+	CanRead     bool   `json:"can_read"`
+	CanWrite    bool   `json:"can_write"`
+}
+
+// MarkMessagesRequest specifies whether messages are marked as read or unread.
+type MarkMessagesRequest struct {
+	Read bool `json:"read"`
+}
+
+type HTTPError struct {
+	Status  int
+	Message string
+}
+
+func (err HTTPError) Error() string {
+	if len(err.Message) > 0 {
+		return fmt.Sprintf("HTTP Status %d : %s", err.Status, err.Message)
+	}
+
+	return fmt.Sprintf("HTTP Status %d", err.Status)
+}
+
+// IsNotFound returns true if the error is from an HTTP not found response.
+func IsNotFound(err error) bool {
+	httpErr, ok := errors.Cause(err).(HTTPError)
+	return ok && httpErr.Status == http.StatusNotFound
+}
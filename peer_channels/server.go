@@ -0,0 +1,673 @@
+package peer_channels
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/json"
+	"github.com/tokenized/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	serverStoragePath = "peer_channels"
+)
+
+var (
+	ErrUnauthorized = errors.New("Unauthorized")
+	ErrForbidden    = errors.New("Forbidden")
+	ErrNotFound     = errors.New("Not Found")
+)
+
+// Server is a peer channels service that keeps accounts, channels, access tokens, and messages in a
+// storage.Storage. It implements http.Handler with the same endpoints used by Client so it can be
+// used in tests and small deployments without an external service.
+type Server struct {
+	store storage.Storage
+
+	listeners map[string][]*listener // Websocket connections by channel id
+	upgrader  websocket.Upgrader
+
+	lock sync.Mutex
+}
+
+// serverAccount is the stored data for an account.
+type serverAccount struct {
+	ID         string   `json:"id"`
+	Token      string   `json:"token"`
+	ChannelIDs []string `json:"channel_ids"`
+}
+
+// serverChannel is the stored data for a channel.
+type serverChannel struct {
+	AccountID string  `json:"account_id"`
+	Channel   Channel `json:"channel"`
+}
+
+// serverMessage is a stored message and whether it has been marked as read.
+type serverMessage struct {
+	Message Message `json:"message"`
+	Read    bool    `json:"read"`
+}
+
+// listener is a websocket connection that is notified of new messages.
+type listener struct {
+	conn *websocket.Conn
+	lock sync.Mutex
+}
+
+// NewServer creates a peer channels server that stores its data in the store.
+func NewServer(store storage.Storage) *Server {
+	return &Server{
+		store:     store,
+		listeners: make(map[string][]*listener),
+	}
+}
+
+// CreateAccount creates a new account and returns its id and the token that authorizes account
+// requests.
+func (s *Server) CreateAccount(ctx context.Context) (string, string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", "", errors.Wrap(err, "token")
+	}
+
+	account := &serverAccount{
+		ID:    uuid.New().String(),
+		Token: token,
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.save(ctx, accountPath(account.ID), account); err != nil {
+		return "", "", errors.Wrap(err, "save account")
+	}
+
+	return account.ID, account.Token, nil
+}
+
+// ServeHTTP routes account and channel requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "api" || parts[1] != "v1" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var err error
+	switch parts[2] {
+	case "account":
+		err = s.serveAccount(ctx, w, r, token, parts[3], parts[4:])
+	case "channel":
+		if len(parts) == 5 && parts[4] == "notify" && r.Method == http.MethodGet {
+			err = s.serveNotify(ctx, w, r, token, parts[3])
+		} else {
+			err = s.serveChannel(ctx, w, r, token, parts[3], parts[4:])
+		}
+	default:
+		err = ErrNotFound
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// serveAccount handles requests authorized by an account token.
+func (s *Server) serveAccount(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	token, accountID string, parts []string) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	account := &serverAccount{}
+	if err := s.load(ctx, accountPath(accountID), account); err != nil {
+		return err
+	}
+
+	if token != account.Token {
+		return ErrUnauthorized
+	}
+
+	if len(parts) == 0 || parts[0] != "channel" {
+		return ErrNotFound
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		request := &CreateChannelRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			return badRequest(errors.Wrap(err, "decode request"))
+		}
+
+		channel, err := s.createChannel(ctx, account, request)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, channel)
+
+	case len(parts) == 2 && parts[1] == "list" && r.Method == http.MethodGet:
+		result := ChannelList{
+			Channels: make([]*Channel, 0, len(account.ChannelIDs)),
+		}
+		for _, channelID := range account.ChannelIDs {
+			channel := &serverChannel{}
+			if err := s.load(ctx, channelPath(channelID), channel); err != nil {
+				return errors.Wrapf(err, "load channel %s", channelID)
+			}
+			result.Channels = append(result.Channels, &channel.Channel)
+		}
+		return writeJSON(w, result)
+	}
+
+	if len(parts) < 2 {
+		return ErrNotFound
+	}
+
+	channel := &serverChannel{}
+	if err := s.load(ctx, channelPath(parts[1]), channel); err != nil {
+		return err
+	}
+
+	if channel.AccountID != account.ID {
+		return ErrNotFound
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		return writeJSON(w, channel.Channel)
+
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		return s.deleteChannel(ctx, account, channel)
+
+	case len(parts) == 3 && parts[2] == "api-token" && r.Method == http.MethodPost:
+		request := &CreateTokenRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			return badRequest(errors.Wrap(err, "decode request"))
+		}
+
+		accessToken, err := s.createToken(ctx, channel, request)
+		if err != nil {
+			return err
+		}
+		return writeJSON(w, accessToken)
+	}
+
+	return ErrNotFound
+}
+
+// serveChannel handles message requests authorized by a channel access token.
+func (s *Server) serveChannel(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	token, channelID string, parts []string) error {
+
+	if len(parts) == 0 && r.Method == http.MethodPost {
+		payload, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return badRequest(errors.Wrap(err, "read body"))
+		}
+
+		message, listeners, err := s.writeMessage(ctx, token, channelID,
+			r.Header.Get("Content-Type"), payload)
+		if err != nil {
+			return err
+		}
+
+		notify(listeners, message)
+		return writeJSON(w, message)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		if _, err := s.authorizeChannel(ctx, channelID, token, false); err != nil {
+			return err
+		}
+
+		messages, err := s.loadMessages(ctx, channelID)
+		if err != nil {
+			return err
+		}
+
+		unread := r.URL.Query().Get("unread") == "true"
+		result := make(Messages, 0, len(messages))
+		for _, message := range messages {
+			if !unread || !message.Read {
+				result = append(result, &message.Message)
+			}
+		}
+		return writeJSON(w, result)
+
+	case len(parts) == 0 && r.Method == http.MethodHead:
+		channel, err := s.authorizeChannel(ctx, channelID, token, false)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("ETag", fmt.Sprintf("%d", channel.Channel.Head))
+		w.WriteHeader(http.StatusOK)
+		return nil
+
+	case len(parts) == 1 && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+		sequence, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return ErrNotFound
+		}
+
+		if r.Method == http.MethodDelete {
+			if _, err := s.authorizeChannel(ctx, channelID, token, true); err != nil {
+				return err
+			}
+			return s.deleteMessage(ctx, channelID, uint32(sequence))
+		}
+
+		if _, err := s.authorizeChannel(ctx, channelID, token, false); err != nil {
+			return err
+		}
+
+		request := &MarkMessagesRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			return badRequest(errors.Wrap(err, "decode request"))
+		}
+
+		older := r.URL.Query().Get("older") == "true"
+		return s.markMessages(ctx, channelID, uint32(sequence), request.Read, older)
+	}
+
+	return ErrNotFound
+}
+
+// serveNotify upgrades the request to a websocket that is sent each new message written to the
+// channel until it is closed.
+func (s *Server) serveNotify(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	token, channelID string) error {
+
+	s.lock.Lock()
+	_, err := s.authorizeChannel(ctx, channelID, token, false)
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil // upgrade already responded with an error
+	}
+	defer conn.Close()
+
+	l := &listener{conn: conn}
+
+	s.lock.Lock()
+	s.listeners[channelID] = append(s.listeners[channelID], l)
+	s.lock.Unlock()
+
+	// Read until the connection closes. Control messages are handled while reading.
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	s.lock.Lock()
+	listeners := s.listeners[channelID]
+	for i, other := range listeners {
+		if other == l {
+			s.listeners[channelID] = append(listeners[:i], listeners[i+1:]...)
+			break
+		}
+	}
+	if len(s.listeners[channelID]) == 0 {
+		delete(s.listeners, channelID)
+	}
+	s.lock.Unlock()
+
+	return nil
+}
+
+func (s *Server) createChannel(ctx context.Context, account *serverAccount,
+	request *CreateChannelRequest) (*Channel, error) {
+
+	token, err := newToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "token")
+	}
+
+	id := uuid.New().String()
+	channel := &serverChannel{
+		AccountID: account.ID,
+		Channel: Channel{
+			ID:          id,
+			Path:        fmt.Sprintf("/api/v1/channel/%s", id),
+			PublicRead:  request.PublicRead,
+			PublicWrite: request.PublicWrite,
+			Sequenced:   request.Sequenced,
+			Retention:   request.Retention,
+			AccessTokens: []AccessToken{
+				{
+					ID:          uuid.New().String(),
+					Token:       token,
+					Description: "Owner",
+					CanRead:     true,
+					CanWrite:    true,
+				},
+			},
+		},
+	}
+
+	if err := s.save(ctx, channelPath(id), channel); err != nil {
+		return nil, errors.Wrap(err, "save channel")
+	}
+
+	account.ChannelIDs = append(account.ChannelIDs, id)
+	if err := s.save(ctx, accountPath(account.ID), account); err != nil {
+		return nil, errors.Wrap(err, "save account")
+	}
+
+	return &channel.Channel, nil
+}
+
+func (s *Server) deleteChannel(ctx context.Context, account *serverAccount,
+	channel *serverChannel) error {
+
+	for i, channelID := range account.ChannelIDs {
+		if channelID == channel.Channel.ID {
+			account.ChannelIDs = append(account.ChannelIDs[:i], account.ChannelIDs[i+1:]...)
+			break
+		}
+	}
+
+	if err := s.save(ctx, accountPath(account.ID), account); err != nil {
+		return errors.Wrap(err, "save account")
+	}
+
+	if err := s.store.Remove(ctx, messagesPath(channel.Channel.ID)); err != nil &&
+		errors.Cause(err) != storage.ErrNotFound {
+		return errors.Wrap(err, "remove messages")
+	}
+
+	if err := s.store.Remove(ctx, channelPath(channel.Channel.ID)); err != nil {
+		return errors.Wrap(err, "remove channel")
+	}
+
+	return nil
+}
+
+func (s *Server) createToken(ctx context.Context, channel *serverChannel,
+	request *CreateTokenRequest) (*AccessToken, error) {
+
+	token, err := newToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "token")
+	}
+
+	accessToken := AccessToken{
+		ID:          uuid.New().String(),
+		Token:       token,
+		Description: request.Description,
+		CanRead:     request.CanRead,
+		CanWrite:    request.CanWrite,
+	}
+
+	channel.Channel.AccessTokens = append(channel.Channel.AccessTokens, accessToken)
+	if err := s.save(ctx, channelPath(channel.Channel.ID), channel); err != nil {
+		return nil, errors.Wrap(err, "save channel")
+	}
+
+	return &accessToken, nil
+}
+
+// writeMessage adds a message to the channel and returns it with the listeners that should be
+// notified of it.
+func (s *Server) writeMessage(ctx context.Context, token, channelID, contentType string,
+	payload []byte) (*Message, []*listener, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	channel, err := s.authorizeChannel(ctx, channelID, token, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if channel.Channel.Locked {
+		return nil, nil, ErrForbidden
+	}
+
+	messages, err := s.loadMessages(ctx, channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channel.Channel.Head++
+	message := Message{
+		Sequence:    channel.Channel.Head,
+		Received:    time.Now(),
+		ContentType: contentType,
+		Payload:     payload,
+	}
+
+	messages = append(messages, &serverMessage{Message: message})
+	if err := s.save(ctx, messagesPath(channelID), messages); err != nil {
+		return nil, nil, errors.Wrap(err, "save messages")
+	}
+
+	if err := s.save(ctx, channelPath(channelID), channel); err != nil {
+		return nil, nil, errors.Wrap(err, "save channel")
+	}
+
+	listeners := make([]*listener, len(s.listeners[channelID]))
+	copy(listeners, s.listeners[channelID])
+
+	return &message, listeners, nil
+}
+
+func (s *Server) markMessages(ctx context.Context, channelID string, sequence uint32,
+	read, older bool) error {
+
+	messages, err := s.loadMessages(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, message := range messages {
+		if message.Message.Sequence == sequence {
+			found = true
+			message.Read = read
+		} else if older && message.Message.Sequence < sequence {
+			message.Read = read
+		}
+	}
+
+	if !found {
+		return ErrNotFound
+	}
+
+	if err := s.save(ctx, messagesPath(channelID), messages); err != nil {
+		return errors.Wrap(err, "save messages")
+	}
+
+	return nil
+}
+
+func (s *Server) deleteMessage(ctx context.Context, channelID string, sequence uint32) error {
+	messages, err := s.loadMessages(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	for i, message := range messages {
+		if message.Message.Sequence == sequence {
+			messages = append(messages[:i], messages[i+1:]...)
+			if err := s.save(ctx, messagesPath(channelID), messages); err != nil {
+				return errors.Wrap(err, "save messages")
+			}
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+// authorizeChannel returns the channel if the token is allowed to read it, or write it when write
+// is true. Public channels don't require a token.
+func (s *Server) authorizeChannel(ctx context.Context, channelID, token string,
+	write bool) (*serverChannel, error) {
+
+	channel := &serverChannel{}
+	if err := s.load(ctx, channelPath(channelID), channel); err != nil {
+		return nil, err
+	}
+
+	if (write && channel.Channel.PublicWrite) || (!write && channel.Channel.PublicRead) {
+		return channel, nil
+	}
+
+	for _, accessToken := range channel.Channel.AccessTokens {
+		if accessToken.Token != token {
+			continue
+		}
+
+		if (write && accessToken.CanWrite) || (!write && accessToken.CanRead) {
+			return channel, nil
+		}
+		return nil, ErrForbidden
+	}
+
+	return nil, ErrUnauthorized
+}
+
+func (s *Server) loadMessages(ctx context.Context, channelID string) ([]*serverMessage, error) {
+	var messages []*serverMessage
+	if err := s.load(ctx, messagesPath(channelID), &messages); err != nil {
+		if errors.Cause(err) == ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "load messages")
+	}
+
+	return messages, nil
+}
+
+// load reads the JSON value at the key. It returns ErrNotFound when the key doesn't exist.
+func (s *Server) load(ctx context.Context, key string, value interface{}) error {
+	b, err := s.store.Read(ctx, key)
+	if err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "read")
+	}
+
+	if err := json.Unmarshal(b, value); err != nil {
+		return errors.Wrap(err, "unmarshal")
+	}
+
+	return nil
+}
+
+// save writes the value as JSON to the key.
+func (s *Server) save(ctx context.Context, key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+
+	if err := s.store.Write(ctx, key, b, nil); err != nil {
+		return errors.Wrap(err, "write")
+	}
+
+	return nil
+}
+
+// notify sends the message to the listeners. Listeners that fail are closed so that their read
+// loops end and they are removed.
+func notify(listeners []*listener, message *Message) {
+	b, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	for _, l := range listeners {
+		l.lock.Lock()
+		if err := l.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+			l.conn.Close()
+		}
+		l.lock.Unlock()
+	}
+}
+
+type badRequestError struct {
+	err error
+}
+
+func (err badRequestError) Error() string {
+	return err.err.Error()
+}
+
+func badRequest(err error) error {
+	return badRequestError{err: err}
+}
+
+// writeError responds with the HTTP status that corresponds to the error.
+func writeError(w http.ResponseWriter, err error) {
+	switch cause := errors.Cause(err); cause {
+	case ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrUnauthorized:
+		w.WriteHeader(http.StatusUnauthorized)
+	case ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		if _, ok := cause.(badRequestError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) error {
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		return errors.Wrap(err, "encode response")
+	}
+	return nil
+}
+
+// newToken returns a random hex token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func accountPath(accountID string) string {
+	return fmt.Sprintf("%s/accounts/%s", serverStoragePath, accountID)
+}
+
+func channelPath(channelID string) string {
+	return fmt.Sprintf("%s/channels/%s", serverStoragePath, channelID)
+}
+
+func messagesPath(channelID string) string {
+	return fmt.Sprintf("%s/messages/%s", serverStoragePath, channelID)
+}
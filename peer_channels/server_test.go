@@ -0,0 +1,159 @@
+package peer_channels
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/threads"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+
+	server := NewServer(storage.NewMockStorage())
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	accountID, accountToken, err := server.CreateAccount(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create account : %s", err)
+	}
+
+	client := NewClient(httpServer.URL)
+
+	if _, err := client.CreateChannel(ctx, accountID, "wrong",
+		CreateChannelRequest{}); err == nil {
+		t.Fatalf("Create channel should fail with wrong token")
+	}
+
+	channel, err := client.CreateChannel(ctx, accountID, accountToken, CreateChannelRequest{})
+	if err != nil {
+		t.Fatalf("Failed to create channel : %s", err)
+	}
+
+	if len(channel.AccessTokens) != 1 {
+		t.Fatalf("Wrong access token count : got %d, want %d", len(channel.AccessTokens), 1)
+	}
+	ownerToken := channel.AccessTokens[0].Token
+
+	readToken, err := client.CreateToken(ctx, accountID, channel.ID, accountToken,
+		CreateTokenRequest{Description: "read", CanRead: true})
+	if err != nil {
+		t.Fatalf("Failed to create token : %s", err)
+	}
+
+	channels, err := client.ListChannels(ctx, accountID, accountToken)
+	if err != nil {
+		t.Fatalf("Failed to list channels : %s", err)
+	}
+
+	if len(channels) != 1 || channels[0].ID != channel.ID {
+		t.Fatalf("Wrong channel list : %+v", channels)
+	}
+
+	if _, err := client.WriteMessage(ctx, channel.ID, readToken.Token, ContentTypeBinary,
+		[]byte{0}); err == nil {
+		t.Fatalf("Write message should fail with read token")
+	}
+
+	incoming := make(chan *Message, 10)
+	interrupt := make(chan interface{})
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- client.Listen(ctx, channel.ID, readToken.Token, incoming, interrupt)
+	}()
+
+	// Wait for the listener to be registered so it is notified of the messages.
+	for i := 0; i < 100; i++ {
+		server.lock.Lock()
+		count := len(server.listeners[channel.ID])
+		server.lock.Unlock()
+		if count > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		message, err := client.WriteMessage(ctx, channel.ID, ownerToken, ContentTypeBinary,
+			[]byte{byte(i)})
+		if err != nil {
+			t.Fatalf("Failed to write message : %s", err)
+		}
+
+		if message.Sequence != uint32(i+1) {
+			t.Errorf("Wrong sequence : got %d, want %d", message.Sequence, i+1)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case message := <-incoming:
+			if !bytes.Equal(message.Payload, []byte{byte(i)}) {
+				t.Errorf("Wrong notified message : %+v", message)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Message %d not notified", i)
+		}
+	}
+
+	close(interrupt)
+	if err := <-listenErr; err != threads.Interrupted {
+		t.Errorf("Wrong listen error : got %v, want %v", err, threads.Interrupted)
+	}
+
+	max, err := client.GetMaxMessageSequence(ctx, channel.ID, readToken.Token)
+	if err != nil {
+		t.Fatalf("Failed to get max sequence : %s", err)
+	}
+
+	if max != 3 {
+		t.Errorf("Wrong max sequence : got %d, want %d", max, 3)
+	}
+
+	if err := client.MarkMessages(ctx, channel.ID, readToken.Token, 2, true, true); err != nil {
+		t.Fatalf("Failed to mark messages : %s", err)
+	}
+
+	messages, err := client.GetMessages(ctx, channel.ID, readToken.Token, true)
+	if err != nil {
+		t.Fatalf("Failed to get messages : %s", err)
+	}
+
+	if len(messages) != 1 || messages[0].Sequence != 3 {
+		t.Fatalf("Wrong unread messages : %+v", messages)
+	}
+
+	if err := client.DeleteMessage(ctx, channel.ID, readToken.Token, 3); err == nil {
+		t.Fatalf("Delete message should fail with read token")
+	}
+
+	if err := client.DeleteMessage(ctx, channel.ID, ownerToken, 3); err != nil {
+		t.Fatalf("Failed to delete message : %s", err)
+	}
+
+	messages, err = client.GetMessages(ctx, channel.ID, ownerToken, false)
+	if err != nil {
+		t.Fatalf("Failed to get messages : %s", err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("Wrong message count : got %d, want %d", len(messages), 2)
+	}
+
+	if err := client.DeleteChannel(ctx, accountID, channel.ID, accountToken); err != nil {
+		t.Fatalf("Failed to delete channel : %s", err)
+	}
+
+	if _, err := client.GetChannel(ctx, accountID, channel.ID, accountToken); !IsNotFound(err) {
+		t.Fatalf("Wrong error : got %v, want not found", err)
+	}
+
+	if _, err := client.GetMessages(ctx, channel.ID, ownerToken, false); !IsNotFound(err) {
+		t.Fatalf("Wrong error : got %v, want not found", err)
+	}
+}
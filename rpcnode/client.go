@@ -0,0 +1,564 @@
+package rpcnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Verbosity levels of getblock.
+	BlockVerbosityRaw    = 0 // Serialized block
+	BlockVerbosityTxIDs  = 1 // Block with txids
+	BlockVerbosityTxData = 2 // Block with tx data
+
+	// rpcInWarmup is the error code returned while the node is starting.
+	rpcInWarmup = -28
+)
+
+// RPCError is an error returned by the node in a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err *RPCError) Error() string {
+	return fmt.Sprintf("%d: %s", err.Code, err.Message)
+}
+
+// HTTPError is an HTTP failure response that didn't contain a JSON-RPC error.
+type HTTPError struct {
+	Status  int
+	Message string
+}
+
+func (err HTTPError) Error() string {
+	if len(err.Message) > 0 {
+		return fmt.Sprintf("HTTP Status %d : %s", err.Status, err.Message)
+	}
+
+	return fmt.Sprintf("HTTP Status %d", err.Status)
+}
+
+// Client is a client for the bitcoind JSON-RPC interface that doesn't depend on a node specific
+// library. Calls are retried after connection failures and while the node is warming up.
+type Client struct {
+	url      string
+	username string
+	password string
+
+	maxRetries int
+	retryDelay time.Duration
+
+	httpClient *http.Client
+
+	nextID uint64
+	lock   sync.Mutex
+}
+
+// Request is a JSON-RPC request that can be sent in a batch. Result is the value the result is
+// unmarshalled into and Err is set to any error returned for the request.
+type Request struct {
+	Method string
+	Params []interface{}
+	Result interface{}
+	Err    error
+}
+
+type jsonRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type jsonResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// BlockHeaderResult is the verbose result of getblockheader.
+type BlockHeaderResult struct {
+	Hash              bitcoin.Hash32  `json:"hash"`
+	Confirmations     int64           `json:"confirmations"`
+	Height            int             `json:"height"`
+	Version           int32           `json:"version"`
+	MerkleRoot        bitcoin.Hash32  `json:"merkleroot"`
+	Time              uint32          `json:"time"`
+	MedianTime        uint32          `json:"mediantime"`
+	Nonce             uint32          `json:"nonce"`
+	Bits              string          `json:"bits"`
+	Difficulty        float64         `json:"difficulty"`
+	ChainWork         string          `json:"chainwork"`
+	TxCount           int             `json:"num_tx"`
+	PreviousBlockHash *bitcoin.Hash32 `json:"previousblockhash,omitempty"`
+	NextBlockHash     *bitcoin.Hash32 `json:"nextblockhash,omitempty"`
+}
+
+// BlockResult is the verbose result of getblock. TxIDs is set for BlockVerbosityTxIDs and Txs is
+// set for BlockVerbosityTxData.
+type BlockResult struct {
+	BlockHeaderResult
+	Size int `json:"size"`
+
+	TxIDs []bitcoin.Hash32 `json:"-"`
+	Txs   []*TxResult      `json:"-"`
+}
+
+// TxResult is the verbose result of getrawtransaction and the tx data of getblock.
+type TxResult struct {
+	TxID          bitcoin.Hash32 `json:"txid"`
+	Hash          bitcoin.Hash32 `json:"hash"`
+	Hex           string         `json:"hex"`
+	Size          int            `json:"size"`
+	Version       int32          `json:"version"`
+	LockTime      uint32         `json:"locktime"`
+	BlockHash     string         `json:"blockhash,omitempty"`
+	Confirmations int64          `json:"confirmations,omitempty"`
+	Time          int64          `json:"time,omitempty"`
+	BlockTime     int64          `json:"blocktime,omitempty"`
+}
+
+// MempoolInfo is the result of getmempoolinfo.
+type MempoolInfo struct {
+	Size          int     `json:"size"`
+	Bytes         uint64  `json:"bytes"`
+	Usage         uint64  `json:"usage"`
+	MaxMempool    uint64  `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+}
+
+// NewClient creates a JSON-RPC client for the node specified by the config.
+func NewClient(config *Config) *Client {
+	url := config.Host
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + url
+	}
+
+	retryDelay := time.Duration(config.RetryDelay) * time.Millisecond
+	if retryDelay == 0 {
+		retryDelay = 500 * time.Millisecond
+	}
+
+	return &Client{
+		url:        url,
+		username:   config.Username,
+		password:   config.Password,
+		maxRetries: config.MaxRetries,
+		retryDelay: retryDelay,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Dial: (&net.Dialer{
+					Timeout: 5 * time.Second,
+				}).Dial,
+			},
+		},
+	}
+}
+
+// Call calls the method with the params and unmarshals the result into result, if it isn't nil.
+// Errors returned by the node are converted with ConvertError.
+func (c *Client) Call(ctx context.Context, method string, params []interface{},
+	result interface{}) error {
+
+	request := &Request{
+		Method: method,
+		Params: params,
+		Result: result,
+	}
+
+	if err := c.Batch(ctx, []*Request{request}); err != nil {
+		return err
+	}
+
+	return request.Err
+}
+
+// Batch sends the requests in one HTTP request. Each request's Result and Err are set from its
+// response. The returned error is only for failures of the whole batch.
+func (c *Client) Batch(ctx context.Context, requests []*Request) error {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	c.lock.Lock()
+	jsonRequests := make([]jsonRequest, len(requests))
+	for i, request := range requests {
+		c.nextID++
+		params := request.Params
+		if params == nil {
+			params = []interface{}{}
+		}
+		jsonRequests[i] = jsonRequest{
+			JSONRPC: "1.0",
+			ID:      c.nextID,
+			Method:  request.Method,
+			Params:  params,
+		}
+	}
+	c.lock.Unlock()
+
+	var responses []jsonResponse
+	var err error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryDelay):
+			}
+		}
+
+		responses, err = c.post(ctx, jsonRequests)
+		if err == nil && !inWarmup(responses) {
+			break
+		}
+
+		if err != nil && !isTransient(err) {
+			return err
+		}
+
+		logger.Warn(ctx, "RPC %s failed (attempt %d) : %v", requests[0].Method, attempt+1, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[uint64]jsonResponse, len(responses))
+	for _, response := range responses {
+		byID[response.ID] = response
+	}
+
+	for i, request := range requests {
+		response, exists := byID[jsonRequests[i].ID]
+		if !exists {
+			request.Err = fmt.Errorf("Missing response for %s", request.Method)
+			continue
+		}
+
+		if response.Error != nil {
+			request.Err = ConvertError(response.Error)
+			continue
+		}
+
+		if request.Result != nil {
+			if err := json.Unmarshal(response.Result, request.Result); err != nil {
+				request.Err = errors.Wrapf(err, "unmarshal %s result", request.Method)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SendRawTransaction sends the tx to the node and returns its txid.
+func (c *Client) SendRawTransaction(ctx context.Context, tx *wire.MsgTx) (*bitcoin.Hash32,
+	error) {
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, errors.Wrap(err, "serialize tx")
+	}
+
+	var txid bitcoin.Hash32
+	if err := c.Call(ctx, "sendrawtransaction", []interface{}{hex.EncodeToString(buf.Bytes())},
+		&txid); err != nil {
+		return nil, errors.Wrap(err, tx.TxHash().String())
+	}
+
+	return &txid, nil
+}
+
+// GetRawTransaction returns the tx with the txid.
+func (c *Client) GetRawTransaction(ctx context.Context, txid bitcoin.Hash32) (*wire.MsgTx,
+	error) {
+
+	txs, err := c.GetRawTransactions(ctx, []bitcoin.Hash32{txid})
+	if err != nil {
+		return nil, err
+	}
+
+	return txs[0], nil
+}
+
+// GetRawTransactions returns the txs with the txids using one batch request. It fails if any of
+// the txs can't be retrieved.
+func (c *Client) GetRawTransactions(ctx context.Context,
+	txids []bitcoin.Hash32) ([]*wire.MsgTx, error) {
+
+	results := make([]string, len(txids))
+	requests := make([]*Request, len(txids))
+	for i, txid := range txids {
+		requests[i] = &Request{
+			Method: "getrawtransaction",
+			Params: []interface{}{txid.String(), 0},
+			Result: &results[i],
+		}
+	}
+
+	if err := c.Batch(ctx, requests); err != nil {
+		return nil, err
+	}
+
+	txs := make([]*wire.MsgTx, len(txids))
+	for i, request := range requests {
+		if request.Err != nil {
+			return nil, errors.Wrap(request.Err, txids[i].String())
+		}
+
+		tx := &wire.MsgTx{}
+		if err := deserializeHex(results[i], tx); err != nil {
+			return nil, errors.Wrapf(err, "tx %s", txids[i])
+		}
+
+		txs[i] = tx
+	}
+
+	return txs, nil
+}
+
+// GetRawTransactionVerbose returns the verbose information of the tx with the txid.
+func (c *Client) GetRawTransactionVerbose(ctx context.Context,
+	txid bitcoin.Hash32) (*TxResult, error) {
+
+	result := &TxResult{}
+	if err := c.Call(ctx, "getrawtransaction", []interface{}{txid.String(), 1},
+		result); err != nil {
+		return nil, errors.Wrap(err, txid.String())
+	}
+
+	return result, nil
+}
+
+// GetBlockHeader returns the header of the block with the hash.
+func (c *Client) GetBlockHeader(ctx context.Context,
+	hash bitcoin.Hash32) (*wire.BlockHeader, error) {
+
+	var result string
+	if err := c.Call(ctx, "getblockheader", []interface{}{hash.String(), false},
+		&result); err != nil {
+		return nil, errors.Wrap(err, hash.String())
+	}
+
+	header := &wire.BlockHeader{}
+	if err := deserializeHex(result, header); err != nil {
+		return nil, errors.Wrap(err, "header")
+	}
+
+	return header, nil
+}
+
+// GetBlockHeaderVerbose returns the verbose information of the header of the block with the hash.
+func (c *Client) GetBlockHeaderVerbose(ctx context.Context,
+	hash bitcoin.Hash32) (*BlockHeaderResult, error) {
+
+	result := &BlockHeaderResult{}
+	if err := c.Call(ctx, "getblockheader", []interface{}{hash.String(), true},
+		result); err != nil {
+		return nil, errors.Wrap(err, hash.String())
+	}
+
+	return result, nil
+}
+
+// GetBlock returns the block with the hash.
+func (c *Client) GetBlock(ctx context.Context, hash bitcoin.Hash32) (*wire.MsgBlock, error) {
+	var result string
+	if err := c.Call(ctx, "getblock", []interface{}{hash.String(), BlockVerbosityRaw},
+		&result); err != nil {
+		return nil, errors.Wrap(err, hash.String())
+	}
+
+	block := &wire.MsgBlock{}
+	if err := deserializeHex(result, block); err != nil {
+		return nil, errors.Wrap(err, "block")
+	}
+
+	return block, nil
+}
+
+// GetBlockVerbose returns the verbose information of the block with the hash at the verbosity,
+// which must be BlockVerbosityTxIDs or BlockVerbosityTxData.
+func (c *Client) GetBlockVerbose(ctx context.Context, hash bitcoin.Hash32,
+	verbosity int) (*BlockResult, error) {
+
+	if verbosity != BlockVerbosityTxIDs && verbosity != BlockVerbosityTxData {
+		return nil, fmt.Errorf("Unsupported verbosity : %d", verbosity)
+	}
+
+	result := &BlockResult{}
+	if err := c.Call(ctx, "getblock", []interface{}{hash.String(), verbosity},
+		result); err != nil {
+		return nil, errors.Wrap(err, hash.String())
+	}
+
+	return result, nil
+}
+
+// GetMempoolInfo returns information about the node's mempool.
+func (c *Client) GetMempoolInfo(ctx context.Context) (*MempoolInfo, error) {
+	result := &MempoolInfo{}
+	if err := c.Call(ctx, "getmempoolinfo", nil, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetBestBlockHash returns the hash of the tip of the node's longest chain.
+func (c *Client) GetBestBlockHash(ctx context.Context) (*bitcoin.Hash32, error) {
+	var result bitcoin.Hash32
+	if err := c.Call(ctx, "getbestblockhash", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetBlockCount returns the height of the node's longest chain.
+func (c *Client) GetBlockCount(ctx context.Context) (int, error) {
+	var result int
+	if err := c.Call(ctx, "getblockcount", nil, &result); err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+// UnmarshalJSON reads the tx field as txids or tx data depending on the verbosity.
+func (r *BlockResult) UnmarshalJSON(data []byte) error {
+	type blockResult BlockResult // prevent recursion
+	var fields struct {
+		blockResult
+		Tx json.RawMessage `json:"tx"`
+	}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*r = BlockResult(fields.blockResult)
+	r.TxIDs = nil
+	r.Txs = nil
+
+	if len(fields.Tx) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(fields.Tx, &r.TxIDs); err == nil {
+		return nil
+	}
+	r.TxIDs = nil
+
+	if err := json.Unmarshal(fields.Tx, &r.Txs); err != nil {
+		return errors.Wrap(err, "tx")
+	}
+
+	return nil
+}
+
+// post sends the requests as a JSON-RPC batch.
+func (c *Client) post(ctx context.Context, requests []jsonRequest) ([]jsonResponse, error) {
+	b, err := json.Marshal(requests)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal requests")
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url,
+		bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if len(c.username) > 0 || len(c.password) > 0 {
+		httpRequest.SetBasicAuth(c.username, c.password)
+	}
+
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "http post")
+	}
+	defer httpResponse.Body.Close()
+
+	body, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read response")
+	}
+
+	var responses []jsonResponse
+	if jsonErr := json.Unmarshal(body, &responses); jsonErr != nil {
+		// A single response is returned for some failures of the whole batch.
+		response := jsonResponse{}
+		if err := json.Unmarshal(body, &response); err == nil && response.Error != nil {
+			return nil, ConvertError(response.Error)
+		}
+
+		if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+			return nil, HTTPError{
+				Status:  httpResponse.StatusCode,
+				Message: strings.TrimSpace(string(body)),
+			}
+		}
+
+		return nil, errors.Wrap(jsonErr, "unmarshal response")
+	}
+
+	return responses, nil
+}
+
+// isTransient returns true if the error is from a failure that might not happen if the request is
+// retried.
+func isTransient(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case HTTPError:
+		return cause.Status >= 500 || cause.Status == http.StatusTooManyRequests
+	case *RPCError:
+		return cause.Code == rpcInWarmup
+	case net.Error:
+		return true
+	}
+
+	return false
+}
+
+// inWarmup returns true if any of the responses failed because the node is starting.
+func inWarmup(responses []jsonResponse) bool {
+	for _, response := range responses {
+		if response.Error != nil && response.Error.Code == rpcInWarmup {
+			return true
+		}
+	}
+	return false
+}
+
+type deserializer interface {
+	Deserialize(r io.Reader) error
+}
+
+func deserializeHex(s string, value deserializer) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return errors.Wrap(err, "hex")
+	}
+
+	return value.Deserialize(bytes.NewReader(b))
+}
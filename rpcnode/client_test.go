@@ -0,0 +1,269 @@
+package rpcnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// mockNode is an HTTP handler that responds to JSON-RPC batches.
+type mockNode struct {
+	t       *testing.T
+	txs     map[bitcoin.Hash32]*wire.MsgTx
+	block   *wire.MsgBlock
+	warmups int // Number of requests to fail with the warmup error
+	posts   int
+
+	sync.Mutex
+}
+
+func (n *mockNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.posts++
+
+	if username, password, ok := r.BasicAuth(); !ok || username != "user" ||
+		password != "pass" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var requests []jsonRequest
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		n.t.Errorf("Failed to decode requests : %s", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var responses []interface{}
+	for _, request := range requests {
+		response := map[string]interface{}{"id": request.ID}
+		if n.warmups > 0 {
+			response["error"] = RPCError{Code: rpcInWarmup, Message: "Loading block index..."}
+			responses = append(responses, response)
+			continue
+		}
+
+		result, rpcErr := n.handle(request)
+		if rpcErr != nil {
+			response["error"] = rpcErr
+		} else {
+			response["result"] = result
+		}
+		responses = append(responses, response)
+	}
+
+	if n.warmups > 0 {
+		n.warmups--
+	}
+
+	json.NewEncoder(w).Encode(responses)
+}
+
+func (n *mockNode) handle(request jsonRequest) (interface{}, *RPCError) {
+	blockHash := n.block.BlockHash()
+
+	switch request.Method {
+	case "sendrawtransaction":
+		b, _ := hex.DecodeString(request.Params[0].(string))
+		tx := &wire.MsgTx{}
+		if err := tx.Deserialize(bytes.NewReader(b)); err != nil {
+			return nil, &RPCError{Code: -22, Message: "TX decode failed"}
+		}
+		if _, exists := n.txs[*tx.TxHash()]; exists {
+			return nil, &RPCError{Code: -27, Message: "Transaction already in the mempool"}
+		}
+		n.txs[*tx.TxHash()] = tx
+		return tx.TxHash().String(), nil
+
+	case "getrawtransaction":
+		txid, _ := bitcoin.NewHash32FromStr(request.Params[0].(string))
+		tx, exists := n.txs[*txid]
+		if !exists {
+			return nil, &RPCError{Code: -5, Message: "No such mempool or blockchain transaction"}
+		}
+		var buf bytes.Buffer
+		tx.Serialize(&buf)
+		if request.Params[1].(float64) == 0 {
+			return hex.EncodeToString(buf.Bytes()), nil
+		}
+		return TxResult{TxID: *txid, Hash: *txid, Hex: hex.EncodeToString(buf.Bytes())}, nil
+
+	case "getblockheader":
+		if request.Params[1].(bool) {
+			return BlockHeaderResult{Hash: *blockHash, Height: 100, TxCount: 1}, nil
+		}
+		var buf bytes.Buffer
+		n.block.Header.Serialize(&buf)
+		return hex.EncodeToString(buf.Bytes()), nil
+
+	case "getblock":
+		switch request.Params[1].(float64) {
+		case BlockVerbosityRaw:
+			var buf bytes.Buffer
+			n.block.Serialize(&buf)
+			return hex.EncodeToString(buf.Bytes()), nil
+		case BlockVerbosityTxIDs:
+			return map[string]interface{}{
+				"hash": blockHash,
+				"size": 100,
+				"tx":   []string{n.block.Transactions[0].TxHash().String()},
+			}, nil
+		default:
+			return map[string]interface{}{
+				"hash": blockHash,
+				"tx":   []TxResult{{TxID: *n.block.Transactions[0].TxHash()}},
+			}, nil
+		}
+
+	case "getmempoolinfo":
+		return MempoolInfo{Size: len(n.txs), Bytes: 1000}, nil
+	}
+
+	return nil, &RPCError{Code: -32601, Message: "Method not found"}
+}
+
+func newTestTx(value uint64) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&bitcoin.Hash32{}, 0), nil))
+	tx.AddTxOut(wire.NewTxOut(value, bitcoin.Script{0x6a}))
+	return tx
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	coinbase := newTestTx(5000000000)
+	block := wire.NewMsgBlock(&wire.BlockHeader{Version: 1, Bits: 0x1d00ffff})
+	block.AddTransaction(coinbase)
+
+	node := &mockNode{
+		t:       t,
+		txs:     map[bitcoin.Hash32]*wire.MsgTx{*coinbase.TxHash(): coinbase},
+		block:   block,
+		warmups: 2,
+	}
+	server := httptest.NewServer(node)
+	defer server.Close()
+
+	client := NewClient(&Config{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		Username:   "user",
+		Password:   "pass",
+		MaxRetries: 3,
+		RetryDelay: 1,
+	})
+
+	info, err := client.GetMempoolInfo(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get mempool info : %s", err)
+	}
+
+	if info.Size != 1 {
+		t.Errorf("Wrong mempool size : got %d, want %d", info.Size, 1)
+	}
+
+	if node.posts != 3 {
+		t.Errorf("Wrong post count : got %d, want %d (warmup retries)", node.posts, 3)
+	}
+
+	tx := newTestTx(1000)
+	txid, err := client.SendRawTransaction(ctx, tx)
+	if err != nil {
+		t.Fatalf("Failed to send tx : %s", err)
+	}
+
+	if !txid.Equal(tx.TxHash()) {
+		t.Errorf("Wrong txid : got %s, want %s", txid, tx.TxHash())
+	}
+
+	if _, err := client.SendRawTransaction(ctx, tx); errors.Cause(err) != ErrTransactionInMempool {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrTransactionInMempool)
+	}
+
+	txs, err := client.GetRawTransactions(ctx, []bitcoin.Hash32{*coinbase.TxHash(), *txid})
+	if err != nil {
+		t.Fatalf("Failed to get txs : %s", err)
+	}
+
+	if !txs[0].TxHash().Equal(coinbase.TxHash()) || !txs[1].TxHash().Equal(txid) {
+		t.Errorf("Wrong txs : %s, %s", txs[0].TxHash(), txs[1].TxHash())
+	}
+
+	if _, err := client.GetRawTransaction(ctx, bitcoin.Hash32{1}); errors.Cause(err) != ErrNotSeen {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrNotSeen)
+	}
+
+	verboseTx, err := client.GetRawTransactionVerbose(ctx, *txid)
+	if err != nil {
+		t.Fatalf("Failed to get verbose tx : %s", err)
+	}
+
+	if !verboseTx.TxID.Equal(txid) {
+		t.Errorf("Wrong verbose txid : got %s, want %s", verboseTx.TxID, txid)
+	}
+
+	header, err := client.GetBlockHeader(ctx, *block.BlockHash())
+	if err != nil {
+		t.Fatalf("Failed to get header : %s", err)
+	}
+
+	if !header.BlockHash().Equal(block.BlockHash()) {
+		t.Errorf("Wrong header hash : got %s, want %s", header.BlockHash(), block.BlockHash())
+	}
+
+	headerResult, err := client.GetBlockHeaderVerbose(ctx, *block.BlockHash())
+	if err != nil {
+		t.Fatalf("Failed to get verbose header : %s", err)
+	}
+
+	if headerResult.Height != 100 {
+		t.Errorf("Wrong height : got %d, want %d", headerResult.Height, 100)
+	}
+
+	gotBlock, err := client.GetBlock(ctx, *block.BlockHash())
+	if err != nil {
+		t.Fatalf("Failed to get block : %s", err)
+	}
+
+	if len(gotBlock.Transactions) != 1 ||
+		!gotBlock.Transactions[0].TxHash().Equal(coinbase.TxHash()) {
+		t.Errorf("Wrong block txs")
+	}
+
+	blockResult, err := client.GetBlockVerbose(ctx, *block.BlockHash(), BlockVerbosityTxIDs)
+	if err != nil {
+		t.Fatalf("Failed to get verbose block : %s", err)
+	}
+
+	if blockResult.Size != 100 || len(blockResult.TxIDs) != 1 ||
+		!blockResult.TxIDs[0].Equal(coinbase.TxHash()) || len(blockResult.Txs) != 0 {
+		t.Errorf("Wrong verbose block : %+v", blockResult)
+	}
+
+	blockResult, err = client.GetBlockVerbose(ctx, *block.BlockHash(), BlockVerbosityTxData)
+	if err != nil {
+		t.Fatalf("Failed to get verbose block : %s", err)
+	}
+
+	if len(blockResult.Txs) != 1 || !blockResult.Txs[0].TxID.Equal(coinbase.TxHash()) ||
+		!blockResult.Hash.Equal(block.BlockHash()) {
+		t.Errorf("Wrong verbose block txs : %+v", blockResult)
+	}
+
+	if err := client.Call(ctx, "unknown", nil, nil); err == nil {
+		t.Errorf("Unknown method should fail")
+	}
+}
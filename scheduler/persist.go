@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tokenized/pkg/storage"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CatchUpNone skips runs that were missed while the process wasn't running.
+	CatchUpNone = CatchUpPolicy(0)
+
+	// CatchUpOnce runs a job once at startup when one or more runs were missed.
+	CatchUpOnce = CatchUpPolicy(1)
+
+	jobStateVersion = uint8(0)
+)
+
+// CatchUpPolicy specifies what a persistent job does at startup when runs were missed.
+type CatchUpPolicy uint8
+
+// PersistentTask is a Task whose last run time is saved to storage so that missed runs can be
+//   detected when the scheduler restarts.
+type PersistentTask interface {
+	Task
+
+	// Name returns the unique name of the task which is used as its storage key.
+	Name() string
+
+	// LastRun returns the time the task last ran, or zero if it hasn't run.
+	LastRun() time.Time
+
+	// Restore is called when the task is scheduled with the last run time loaded from storage.
+	Restore(lastRun time.Time)
+}
+
+// jobState is the saved state of a persistent task.
+type jobState struct {
+	LastRun time.Time
+}
+
+// SetStorage enables persistence of the last run times of PersistentTasks in the store under the
+//   path. It must be called before jobs are scheduled.
+func (sch *Scheduler) SetStorage(store storage.Storage, path string) {
+	sch.lock.Lock()
+	defer sch.lock.Unlock()
+
+	sch.store = store
+	sch.storagePath = path
+}
+
+// restoreJob loads the saved state of a persistent job. The lock must be held.
+func (sch *Scheduler) restoreJob(ctx context.Context, job Task) error {
+	persistent, ok := job.(PersistentTask)
+	if !ok || sch.store == nil {
+		return nil
+	}
+
+	state := &jobState{}
+	if err := storage.Load(ctx, sch.store, sch.jobPath(persistent), state); err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return nil // never ran
+		}
+		return errors.Wrapf(err, "load job %s", persistent.Name())
+	}
+
+	persistent.Restore(state.LastRun)
+	return nil
+}
+
+// saveJob saves the state of a persistent job. The lock must be held.
+func (sch *Scheduler) saveJob(ctx context.Context, job Task) error {
+	persistent, ok := job.(PersistentTask)
+	if !ok || sch.store == nil {
+		return nil
+	}
+
+	state := &jobState{
+		LastRun: persistent.LastRun(),
+	}
+
+	if err := storage.Save(ctx, sch.store, sch.jobPath(persistent), state); err != nil {
+		return errors.Wrapf(err, "save job %s", persistent.Name())
+	}
+
+	return nil
+}
+
+func (sch *Scheduler) jobPath(task PersistentTask) string {
+	return fmt.Sprintf("%s/%s", sch.storagePath, task.Name())
+}
+
+func (s *jobState) Serialize(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, jobStateVersion); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	var lastRun int64
+	if !s.LastRun.IsZero() {
+		lastRun = s.LastRun.UnixNano()
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, lastRun); err != nil {
+		return errors.Wrap(err, "last run")
+	}
+
+	return nil
+}
+
+func (s *jobState) Deserialize(r io.Reader) error {
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if version != jobStateVersion {
+		return fmt.Errorf("Unsupported job state version : %d", version)
+	}
+
+	var lastRun int64
+	if err := binary.Read(r, binary.LittleEndian, &lastRun); err != nil {
+		return errors.Wrap(err, "last run")
+	}
+
+	if lastRun == 0 {
+		s.LastRun = time.Time{}
+	} else {
+		s.LastRun = time.Unix(0, lastRun)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/storage"
+)
+
+type countProcess struct {
+	count int
+	lock  sync.Mutex
+}
+
+func (p *countProcess) Run(ctx context.Context) {
+	p.lock.Lock()
+	p.count++
+	p.lock.Unlock()
+}
+
+func (p *countProcess) Count() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.count
+}
+
+func TestPersistentCatchUp(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+
+	lastRun := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"catch_up", "skip"} {
+		if err := storage.Save(ctx, store, "jobs/"+name, &jobState{LastRun: lastRun}); err != nil {
+			t.Fatalf("Failed to save job state : %s", err)
+		}
+	}
+
+	sch := &Scheduler{}
+	sch.SetStorage(store, "jobs")
+
+	catchUpProcess := &countProcess{}
+	catchUp, err := NewScheduledTaskFromSpec("catch_up", catchUpProcess, "@daily", 0)
+	if err != nil {
+		t.Fatalf("Failed to create task : %s", err)
+	}
+	catchUp.SetCatchUp(CatchUpOnce)
+
+	skipProcess := &countProcess{}
+	skip, err := NewScheduledTaskFromSpec("skip", skipProcess, "@daily", 0)
+	if err != nil {
+		t.Fatalf("Failed to create task : %s", err)
+	}
+
+	neverProcess := &countProcess{}
+	never, err := NewScheduledTaskFromSpec("never", neverProcess, "@daily", 0)
+	if err != nil {
+		t.Fatalf("Failed to create task : %s", err)
+	}
+	never.SetCatchUp(CatchUpOnce)
+
+	for _, task := range []*ScheduledTask{catchUp, skip, never} {
+		if err := sch.ScheduleJob(ctx, task); err != nil {
+			t.Fatalf("Failed to schedule job : %s", err)
+		}
+	}
+
+	if !catchUp.Missed() || !skip.Missed() {
+		t.Fatalf("Missed runs not detected")
+	}
+
+	if never.Missed() {
+		t.Fatalf("Job without saved state should not have missed runs")
+	}
+
+	go sch.Run(ctx)
+	time.Sleep(100 * time.Millisecond)
+	sch.Stop(ctx)
+
+	if catchUpProcess.Count() != 1 {
+		t.Errorf("Wrong catch up run count : got %d, want %d", catchUpProcess.Count(), 1)
+	}
+
+	if skipProcess.Count() != 0 || neverProcess.Count() != 0 {
+		t.Errorf("Jobs should not have run")
+	}
+
+	state := &jobState{}
+	if err := storage.Load(ctx, store, "jobs/catch_up", state); err != nil {
+		t.Fatalf("Failed to load job state : %s", err)
+	}
+
+	if !state.LastRun.After(lastRun) {
+		t.Errorf("Last run not saved : got %s, want after %s", state.LastRun, lastRun)
+	}
+
+	if !catchUp.Next().After(time.Now()) {
+		t.Errorf("Next run not scheduled : %s", catchUp.Next())
+	}
+}
@@ -0,0 +1,325 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxScheduleSearch is how far ahead Next searches for a matching time before giving up on a
+	//   schedule that can never match, like February 30th.
+	maxScheduleSearch = 5 // years
+)
+
+var (
+	ErrInvalidSchedule = errors.New("Invalid Schedule")
+)
+
+// Schedule determines when a task runs.
+type Schedule interface {
+	// Next returns the first time after t that the task should run, or a zero time if it should
+	//   not run again.
+	Next(t time.Time) time.Time
+}
+
+// IntervalSchedule runs at a fixed interval.
+type IntervalSchedule time.Duration
+
+// Next returns t plus the interval.
+func (s IntervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// CalendarSchedule runs at the start of each minute that matches all of its fields in its
+//   location. An empty field matches any value. When both Days and Weekdays are specified a day
+//   matches if it matches either of them, like cron. Local times that don't exist because of a
+//   daylight saving change are skipped.
+type CalendarSchedule struct {
+	Location *time.Location // Defaults to UTC
+
+	Months   []time.Month
+	Days     []int // Days of the month, starting at 1
+	Weekdays []time.Weekday
+	Hours    []int
+	Minutes  []int
+}
+
+// Next returns the first matching minute after t, or a zero time if there isn't one within five
+//   years.
+func (s *CalendarSchedule) Next(t time.Time) time.Time {
+	location := s.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	t = t.In(location).Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(maxScheduleSearch, 0, 0)
+
+	for t.Before(end) {
+		if !s.matchesMonth(t.Month()) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !s.matchesDay(t.Day(), t.Weekday()) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+			continue
+		}
+
+		if !containsInt(s.Hours, t.Hour()) {
+			t = t.Add(time.Hour).Truncate(time.Hour)
+			continue
+		}
+
+		if !containsInt(s.Minutes, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *CalendarSchedule) matchesMonth(month time.Month) bool {
+	if len(s.Months) == 0 {
+		return true
+	}
+
+	for _, m := range s.Months {
+		if m == month {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *CalendarSchedule) matchesDay(day int, weekday time.Weekday) bool {
+	if len(s.Days) == 0 && len(s.Weekdays) == 0 {
+		return true
+	}
+
+	if len(s.Days) > 0 && containsInt(s.Days, day) {
+		return true
+	}
+
+	for _, w := range s.Weekdays {
+		if w == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseSchedule parses a schedule specification. It can be a five field cron expression
+//   ("minute hour day-of-month month day-of-week"), a macro (@yearly, @monthly, @weekly, @daily,
+//   @hourly), or "@every <duration>". Cron expressions and macros can be preceded by
+//   "CRON_TZ=<location>" or "TZ=<location>" to use a time zone other than UTC.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidSchedule, err.Error())
+		}
+		if d <= 0 {
+			return nil, errors.Wrap(ErrInvalidSchedule, "interval must be positive")
+		}
+		return IntervalSchedule(d), nil
+	}
+
+	return ParseCron(spec)
+}
+
+// ParseCron parses a five field cron expression or macro into a calendar schedule. Fields support
+//   "*", values, ranges "a-b", lists "a,b", and steps "*/n" or "a-b/n". Months and weekdays can be
+//   specified by their three letter names.
+func ParseCron(expr string) (*CalendarSchedule, error) {
+	result := &CalendarSchedule{
+		Location: time.UTC,
+	}
+
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		i := strings.IndexAny(expr, " \t")
+		if i == -1 {
+			return nil, errors.Wrap(ErrInvalidSchedule, "missing expression after time zone")
+		}
+
+		name := expr[strings.Index(expr, "=")+1 : i]
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidSchedule, "time zone %s : %s", name, err)
+		}
+
+		result.Location = location
+		expr = strings.TrimSpace(expr[i:])
+	}
+
+	switch expr {
+	case "@yearly", "@annually":
+		expr = "0 0 1 1 *"
+	case "@monthly":
+		expr = "0 0 1 * *"
+	case "@weekly":
+		expr = "0 0 * * 0"
+	case "@daily", "@midnight":
+		expr = "0 0 * * *"
+	case "@hourly":
+		expr = "0 * * * *"
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Wrapf(ErrInvalidSchedule, "%d fields, want 5", len(fields))
+	}
+
+	var err error
+	if result.Minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, errors.Wrap(err, "minute")
+	}
+
+	if result.Hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, errors.Wrap(err, "hour")
+	}
+
+	if result.Days, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, errors.Wrap(err, "day of month")
+	}
+
+	months, err := parseCronField(fields[3], 1, 12, monthNames)
+	if err != nil {
+		return nil, errors.Wrap(err, "month")
+	}
+	for _, month := range months {
+		result.Months = append(result.Months, time.Month(month))
+	}
+
+	// Sunday can be 0 or 7.
+	weekdays, err := parseCronField(fields[4], 0, 7, weekdayNames)
+	if err != nil {
+		return nil, errors.Wrap(err, "day of week")
+	}
+	for _, weekday := range weekdays {
+		weekday = weekday % 7
+		found := false
+		for _, w := range result.Weekdays {
+			if int(w) == weekday {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.Weekdays = append(result.Weekdays, time.Weekday(weekday))
+		}
+	}
+
+	return result, nil
+}
+
+var (
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+
+	weekdayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// parseCronField returns the values specified by a cron field, or nil if it matches any value.
+func parseCronField(field string, min, max int, names map[string]int) ([]int, error) {
+	if field == "*" || field == "?" {
+		return nil, nil
+	}
+
+	included := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return nil, errors.Wrapf(ErrInvalidSchedule, "step %s", part)
+			}
+			step = s
+			part = part[:i]
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			if low, err = parseCronValue(bounds[0], min, max, names); err != nil {
+				return nil, err
+			}
+
+			high = low
+			if len(bounds) == 2 {
+				if high, err = parseCronValue(bounds[1], min, max, names); err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				high = max // "a/n" means from a to the maximum
+			}
+
+			if high < low {
+				return nil, errors.Wrapf(ErrInvalidSchedule, "range %s", part)
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			included[value] = true
+		}
+	}
+
+	var result []int
+	for value, ok := range included {
+		if ok {
+			result = append(result, value)
+		}
+	}
+
+	return result, nil
+}
+
+func parseCronValue(s string, min, max int, names map[string]int) (int, error) {
+	if value, exists := names[strings.ToLower(s)]; exists {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidSchedule, "value %s", s)
+	}
+
+	if value < min || value > max {
+		return 0, errors.Wrap(ErrInvalidSchedule, fmt.Sprintf("value %d out of range %d-%d",
+			value, min, max))
+	}
+
+	return value, nil
+}
+
+func containsInt(values []int, value int) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseCron(t *testing.T) {
+	start := time.Date(2021, time.March, 10, 12, 30, 15, 0, time.UTC) // Wednesday
+
+	tests := []struct {
+		spec string
+		want []time.Time
+	}{
+		{
+			spec: "*/15 * * * *",
+			want: []time.Time{
+				time.Date(2021, time.March, 10, 12, 45, 0, 0, time.UTC),
+				time.Date(2021, time.March, 10, 13, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 3 * * mon-fri",
+			want: []time.Time{
+				time.Date(2021, time.March, 11, 3, 0, 0, 0, time.UTC),
+				time.Date(2021, time.March, 12, 3, 0, 0, 0, time.UTC),
+				time.Date(2021, time.March, 15, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "30 6 1,15 * *",
+			want: []time.Time{
+				time.Date(2021, time.March, 15, 6, 30, 0, 0, time.UTC),
+				time.Date(2021, time.April, 1, 6, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "0 0 29 2 *",
+			want: []time.Time{
+				time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			spec: "@monthly",
+			want: []time.Time{
+				time.Date(2021, time.April, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// Day of month or Sunday, like cron.
+			spec: "0 12 20 * 7",
+			want: []time.Time{
+				time.Date(2021, time.March, 14, 12, 0, 0, 0, time.UTC),
+				time.Date(2021, time.March, 20, 12, 0, 0, 0, time.UTC),
+				time.Date(2021, time.March, 21, 12, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Fatalf("Failed to parse schedule %s : %s", tt.spec, err)
+		}
+
+		next := start
+		for _, want := range tt.want {
+			next = schedule.Next(next)
+			if !next.Equal(want) {
+				t.Errorf("Wrong next time for %s : got %s, want %s", tt.spec, next, want)
+			}
+		}
+	}
+}
+
+func TestParseCronTimeZone(t *testing.T) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone data not available : %s", err)
+	}
+
+	schedule, err := ParseSchedule("CRON_TZ=America/New_York 0 2 * * *")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule : %s", err)
+	}
+
+	// 2am doesn't exist on the day daylight saving starts, so that day is skipped.
+	next := schedule.Next(time.Date(2021, time.March, 13, 12, 0, 0, 0, location))
+	want := time.Date(2021, time.March, 15, 2, 0, 0, 0, location)
+	if !next.Equal(want) {
+		t.Errorf("Wrong next time : got %s, want %s", next, want)
+	}
+
+	next = schedule.Next(next)
+	want = time.Date(2021, time.March, 16, 2, 0, 0, 0, location)
+	if !next.Equal(want) {
+		t.Errorf("Wrong next time : got %s, want %s", next, want)
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	specs := []string{
+		"* * * *",
+		"60 * * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every -1m",
+		"TZ=Not/AZone * * * * *",
+	}
+
+	for _, spec := range specs {
+		if _, err := ParseSchedule(spec); errors.Cause(err) != ErrInvalidSchedule {
+			t.Errorf("Wrong error for %s : got %v, want %v", spec, err, ErrInvalidSchedule)
+		}
+	}
+
+	schedule, err := ParseSchedule("@every 90s")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule : %s", err)
+	}
+
+	if schedule != IntervalSchedule(90*time.Second) {
+		t.Errorf("Wrong schedule : got %v, want %v", schedule, 90*time.Second)
+	}
+}
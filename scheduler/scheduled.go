@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// ScheduledTask is a Scheduler Task that runs a process at the times specified by a schedule. A
+//   random delay up to the jitter is added to each run so that processes with the same schedule
+//   on different systems don't all run at the same moment.
+type ScheduledTask struct {
+	name     string
+	process  PeriodicTaskInterface
+	schedule Schedule
+	jitter   time.Duration
+	catchUp  CatchUpPolicy
+	next     time.Time
+	lastRun  time.Time
+	missed   bool
+}
+
+// NewScheduledTask creates a task that runs the process on the schedule.
+func NewScheduledTask(name string, process PeriodicTaskInterface, schedule Schedule,
+	jitter time.Duration) *ScheduledTask {
+
+	result := &ScheduledTask{
+		name:     name,
+		process:  process,
+		schedule: schedule,
+		jitter:   jitter,
+	}
+	result.scheduleNext(time.Now())
+	return result
+}
+
+// NewScheduledTaskFromSpec creates a task that runs the process on the schedule specification.
+//   See ParseSchedule for the supported formats.
+func NewScheduledTaskFromSpec(name string, process PeriodicTaskInterface, spec string,
+	jitter time.Duration) (*ScheduledTask, error) {
+
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewScheduledTask(name, process, schedule, jitter), nil
+}
+
+// SetCatchUp sets the policy for runs that were missed while the process wasn't running. It
+//   only applies when the task is scheduled in a scheduler with storage.
+func (st *ScheduledTask) SetCatchUp(policy CatchUpPolicy) {
+	st.catchUp = policy
+}
+
+// Name returns the name of the task.
+func (st *ScheduledTask) Name() string {
+	return st.name
+}
+
+// LastRun returns the time the task last ran, or zero if it hasn't run.
+func (st *ScheduledTask) LastRun() time.Time {
+	return st.lastRun
+}
+
+// Restore sets the last run time loaded from storage. When a run was missed since then and the
+//   catch up policy is CatchUpOnce the task is scheduled to run immediately.
+func (st *ScheduledTask) Restore(lastRun time.Time) {
+	st.lastRun = lastRun
+	if lastRun.IsZero() {
+		return
+	}
+
+	now := time.Now()
+	missed := st.schedule.Next(lastRun)
+	st.missed = !missed.IsZero() && missed.Before(now)
+	if st.missed && st.catchUp == CatchUpOnce {
+		st.next = now
+	}
+}
+
+// Missed returns true if a run was missed while the process wasn't running, as determined by the
+//   last run time restored from storage.
+func (st *ScheduledTask) Missed() bool {
+	return st.missed
+}
+
+// Next returns the next time the task will run. It is zero when the schedule has no more times.
+func (st *ScheduledTask) Next() time.Time {
+	return st.next
+}
+
+// IsReady returns true when a job should be executed.
+func (st *ScheduledTask) IsReady(ctx context.Context) bool {
+	return !st.next.IsZero() && time.Now().After(st.next)
+}
+
+// Run executes the job.
+func (st *ScheduledTask) Run(ctx context.Context) {
+	// Schedule next time
+	now := time.Now()
+	st.lastRun = now
+	st.scheduleNext(now)
+
+	// Run process
+	st.process.Run(ctx)
+}
+
+// IsComplete returns true when a job should be removed from the scheduler.
+func (st *ScheduledTask) IsComplete(ctx context.Context) bool {
+	return st.next.IsZero()
+}
+
+// Equal returns true if another job matches it. Used to cancel jobs.
+func (st *ScheduledTask) Equal(other Task) bool {
+	otherST, ok := other.(*ScheduledTask)
+	if !ok {
+		return false
+	}
+	return st.name == otherST.name
+}
+
+func (st *ScheduledTask) scheduleNext(now time.Time) {
+	st.next = st.schedule.Next(now)
+	if st.next.IsZero() || st.jitter <= 0 {
+		return
+	}
+
+	st.next = st.next.Add(time.Duration(rand.Int63n(int64(st.jitter))))
+}
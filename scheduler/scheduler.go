@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/pkg/storage"
 )
 
 const (
@@ -23,6 +24,9 @@ type Scheduler struct {
 	lock          sync.Mutex
 	isRunning     bool
 	stopRequested bool
+
+	store       storage.Storage // Optional storage for the state of persistent jobs
+	storagePath string
 }
 
 // Task provides an interface that tells Scheduler when and how to do something.
@@ -40,10 +44,16 @@ type Task interface {
 	Equal(other Task) bool
 }
 
-// ScheduleJob adds a task to the scheduler.
+// ScheduleJob adds a task to the scheduler. When storage is set the saved state of a
+//   PersistentTask is restored.
 func (sch *Scheduler) ScheduleJob(ctx context.Context, job Task) error {
 	sch.lock.Lock()
 	defer sch.lock.Unlock()
+
+	if err := sch.restoreJob(ctx, job); err != nil {
+		return err
+	}
+
 	sch.jobs = append(sch.jobs, job)
 	return nil
 }
@@ -71,6 +81,10 @@ func (sch *Scheduler) Run(ctx context.Context) error {
 		for i, job := range sch.jobs {
 			if job.IsReady(ctx) {
 				job.Run(ctx)
+				if err := sch.saveJob(ctx, job); err != nil {
+					logger.Error(logger.ContextWithLogSubSystem(ctx, SubSystem),
+						"Failed to save job state : %s", err)
+				}
 				if job.IsComplete(ctx) {
 					sch.jobs = append(sch.jobs[:i], sch.jobs[i+1:]...)
 					break // Modified list being iterated
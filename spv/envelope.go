@@ -0,0 +1,374 @@
+package spv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EnvelopeVersion is the current version of the envelope format.
+	EnvelopeVersion = uint8(1)
+)
+
+var (
+	// ErrMissingTx means an envelope doesn't contain its raw tx.
+	ErrMissingTx = errors.New("Missing Tx")
+
+	// ErrWrongTxID means an envelope's tx id doesn't match its tx or merkle proof.
+	ErrWrongTxID = errors.New("Wrong Tx ID")
+
+	// ErrMissingParent means an unconfirmed tx in an envelope spends an output of a tx that isn't
+	// included in its parents.
+	ErrMissingParent = errors.New("Missing Parent")
+
+	// ErrInvalidParent means a parent doesn't contain the output being spent.
+	ErrInvalidParent = errors.New("Invalid Parent")
+
+	// ErrUnsupportedVersion means the envelope has a version that isn't supported.
+	ErrUnsupportedVersion = errors.New("Unsupported Version")
+
+	Endian = binary.LittleEndian
+)
+
+// TxProvider provides the txs and merkle proofs needed to build envelopes.
+type TxProvider interface {
+	// GetTx returns the tx with the specified tx id.
+	GetTx(ctx context.Context, txid bitcoin.Hash32) (*wire.MsgTx, error)
+
+	// GetMerkleProof returns the merkle proof of a confirmed tx, or nil if the tx is unconfirmed.
+	GetMerkleProof(ctx context.Context, txid bitcoin.Hash32) (*merkle_proof.MerkleProof, error)
+}
+
+// Envelope is a tx with the data a counterparty needs to verify it independently. A confirmed tx
+// has a merkle proof that references a block header by hash, so the receiver verifies it against
+// its own header chain. An unconfirmed tx instead contains an envelope for each tx it spends,
+// recursively, until every branch ends in a confirmed tx.
+type Envelope struct {
+	Version     uint8                        `json:"version"`
+	Tx          *wire.MsgTx                  `json:"rawTx"`
+	TxID        bitcoin.Hash32               `json:"txid"`
+	MerkleProof *merkle_proof.MerkleProof    `json:"proof,omitempty"`
+	Parents     map[bitcoin.Hash32]*Envelope `json:"parents,omitempty"`
+}
+
+// NewEnvelope builds an envelope for the tx with the txs and merkle proofs from the provider. The
+// merkle proof of the tx is included if it is confirmed. Otherwise its parents are added
+// recursively until the confirmed txs are reached.
+func NewEnvelope(ctx context.Context, tx *wire.MsgTx, provider TxProvider) (*Envelope, error) {
+	return newEnvelope(ctx, tx, provider, make(map[bitcoin.Hash32]*Envelope))
+}
+
+func newEnvelope(ctx context.Context, tx *wire.MsgTx, provider TxProvider,
+	built map[bitcoin.Hash32]*Envelope) (*Envelope, error) {
+
+	txid := *tx.TxHash()
+	if envelope, exists := built[txid]; exists {
+		return envelope, nil
+	}
+
+	proof, err := provider.GetMerkleProof(ctx, txid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "merkle proof %s", txid)
+	}
+
+	envelope := &Envelope{
+		Version: EnvelopeVersion,
+		Tx:      tx,
+		TxID:    txid,
+	}
+	built[txid] = envelope
+
+	if proof != nil {
+		envelope.MerkleProof = headerReference(proof)
+		return envelope, nil
+	}
+
+	for index, txin := range tx.TxIn {
+		hash := txin.PreviousOutPoint.Hash
+		if _, exists := envelope.Parents[hash]; exists {
+			continue
+		}
+
+		parentTx, err := provider.GetTx(ctx, hash)
+		if err != nil {
+			return nil, errors.Wrapf(err, "input %d parent %s", index, hash)
+		}
+
+		parent, err := newEnvelope(ctx, parentTx, provider, built)
+		if err != nil {
+			return nil, errors.Wrapf(err, "input %d", index)
+		}
+
+		envelope.AddParent(parent)
+	}
+
+	return envelope, nil
+}
+
+// headerReference returns a copy of the merkle proof that targets the block hash instead of
+// embedding the block header or the tx.
+func headerReference(proof *merkle_proof.MerkleProof) *merkle_proof.MerkleProof {
+	result := *proof
+	result.Tx = nil
+	if result.TxID == nil && proof.Tx != nil {
+		result.TxID = proof.Tx.TxHash()
+	}
+	if result.BlockHeader != nil {
+		result.BlockHash = result.BlockHeader.BlockHash()
+		result.BlockHeader = nil
+	}
+	return &result
+}
+
+// AddParent adds an envelope for a tx spent by the envelope's tx.
+func (e *Envelope) AddParent(parent *Envelope) {
+	if e.Parents == nil {
+		e.Parents = make(map[bitcoin.Hash32]*Envelope)
+	}
+	e.Parents[parent.TxID] = parent
+}
+
+// IsConfirmed returns true if the envelope has a merkle proof.
+func (e Envelope) IsConfirmed() bool {
+	return e.MerkleProof != nil
+}
+
+// InputOutput returns the output spent by the input at the specified index.
+func (e Envelope) InputOutput(index int) (*wire.TxOut, error) {
+	if e.Tx == nil {
+		return nil, ErrMissingTx
+	}
+	if index >= len(e.Tx.TxIn) {
+		return nil, errors.New("Input index out of range")
+	}
+
+	return e.spentOutput(e.Tx.TxIn[index].PreviousOutPoint)
+}
+
+func (e Envelope) spentOutput(outpoint wire.OutPoint) (*wire.TxOut, error) {
+	parent, exists := e.Parents[outpoint.Hash]
+	if !exists || parent == nil {
+		return nil, errors.Wrap(ErrMissingParent, outpoint.Hash.String())
+	}
+	if parent.Tx == nil {
+		return nil, errors.Wrapf(ErrMissingTx, "parent %s", outpoint.Hash)
+	}
+
+	if int(outpoint.Index) >= len(parent.Tx.TxOut) {
+		return nil, errors.Wrapf(ErrInvalidParent, "%s output %d out of range", outpoint.Hash,
+			outpoint.Index)
+	}
+
+	return parent.Tx.TxOut[outpoint.Index], nil
+}
+
+// Validate checks the structure of the envelope without access to block headers. Every tx must
+// match its tx id, every merkle proof must match its tx and calculate a merkle root, and every
+// input of an unconfirmed tx must spend an existing output of an included parent.
+func (e Envelope) Validate() error {
+	_, err := e.collectProofs(make(map[bitcoin.Hash32]bool))
+	return err
+}
+
+// Verify validates the envelope and verifies its merkle proofs against the block headers from
+// the header source.
+func (e Envelope) Verify(ctx context.Context, headers merkle_proof.HeaderGetter) error {
+	proofs, err := e.collectProofs(make(map[bitcoin.Hash32]bool))
+	if err != nil {
+		return err
+	}
+
+	results := merkle_proof.NewVerifier(headers, 0).Verify(ctx, proofs)
+	for i, result := range results {
+		if result.Err != nil {
+			return errors.Wrapf(result.Err, "merkle proof %s", proofs[i].TxID)
+		}
+	}
+
+	return nil
+}
+
+// collectProofs validates the envelope and returns the merkle proofs of it and its ancestors.
+// checked contains the tx ids already validated, since a tx can be the parent of several txs.
+func (e Envelope) collectProofs(checked map[bitcoin.Hash32]bool) ([]*merkle_proof.MerkleProof,
+	error) {
+
+	if e.Version != EnvelopeVersion {
+		return nil, errors.Wrapf(ErrUnsupportedVersion, "%d", e.Version)
+	}
+
+	if e.Tx == nil {
+		return nil, errors.Wrap(ErrMissingTx, e.TxID.String())
+	}
+
+	txid := *e.Tx.TxHash()
+	if !txid.Equal(&e.TxID) {
+		return nil, errors.Wrapf(ErrWrongTxID, "%s: tx hash %s", e.TxID, txid)
+	}
+
+	if checked[txid] {
+		return nil, nil
+	}
+	checked[txid] = true
+
+	if e.MerkleProof != nil {
+		if e.MerkleProof.TxID == nil || !e.MerkleProof.TxID.Equal(&txid) {
+			return nil, errors.Wrapf(ErrWrongTxID, "%s: merkle proof", txid)
+		}
+
+		if _, err := e.MerkleProof.CalculateRoot(); err != nil {
+			return nil, errors.Wrapf(err, "%s: merkle proof", txid)
+		}
+
+		// Parents of a confirmed tx aren't needed to verify it.
+		return []*merkle_proof.MerkleProof{e.MerkleProof}, nil
+	}
+
+	var result []*merkle_proof.MerkleProof
+	for index, txin := range e.Tx.TxIn {
+		if _, err := e.spentOutput(txin.PreviousOutPoint); err != nil {
+			return nil, errors.Wrapf(err, "%s: input %d", txid, index)
+		}
+	}
+
+	for _, hash := range e.sortedParents() {
+		parent := e.Parents[hash]
+		if !parent.TxID.Equal(&hash) {
+			return nil, errors.Wrapf(ErrWrongTxID, "%s: parent %s", txid, hash)
+		}
+
+		proofs, err := parent.collectProofs(checked)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parent %s", hash)
+		}
+		result = append(result, proofs...)
+	}
+
+	return result, nil
+}
+
+// sortedParents returns the tx ids of the parents in order so that serialization and validation
+// are deterministic.
+func (e Envelope) sortedParents() []bitcoin.Hash32 {
+	result := make([]bitcoin.Hash32, 0, len(e.Parents))
+	for hash := range e.Parents {
+		result = append(result, hash)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i][:], result[j][:]) < 0
+	})
+
+	return result
+}
+
+func (e Envelope) Serialize(w io.Writer) error {
+	if err := binary.Write(w, Endian, e.Version); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if e.Tx == nil {
+		return ErrMissingTx
+	}
+	if err := e.Tx.Serialize(w); err != nil {
+		return errors.Wrap(err, "tx")
+	}
+
+	if e.MerkleProof != nil {
+		if err := binary.Write(w, Endian, uint8(1)); err != nil {
+			return errors.Wrap(err, "merkle proof flag")
+		}
+		if err := e.MerkleProof.Serialize(w); err != nil {
+			return errors.Wrap(err, "merkle proof")
+		}
+	} else {
+		if err := binary.Write(w, Endian, uint8(0)); err != nil {
+			return errors.Wrap(err, "merkle proof flag")
+		}
+	}
+
+	parents := e.sortedParents()
+	if err := wire.WriteVarInt(w, 0, uint64(len(parents))); err != nil {
+		return errors.Wrap(err, "parent count")
+	}
+
+	for _, hash := range parents {
+		if err := e.Parents[hash].Serialize(w); err != nil {
+			return errors.Wrapf(err, "parent %s", hash)
+		}
+	}
+
+	return nil
+}
+
+func (e *Envelope) Deserialize(r io.Reader) error {
+	if err := binary.Read(r, Endian, &e.Version); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if e.Version != EnvelopeVersion {
+		return errors.Wrapf(ErrUnsupportedVersion, "%d", e.Version)
+	}
+
+	e.Tx = &wire.MsgTx{}
+	if err := e.Tx.Deserialize(r); err != nil {
+		return errors.Wrap(err, "tx")
+	}
+	e.TxID = *e.Tx.TxHash()
+
+	var flag uint8
+	if err := binary.Read(r, Endian, &flag); err != nil {
+		return errors.Wrap(err, "merkle proof flag")
+	}
+
+	switch flag {
+	case 0:
+		e.MerkleProof = nil
+	case 1:
+		e.MerkleProof = &merkle_proof.MerkleProof{}
+		if err := e.MerkleProof.Deserialize(r); err != nil {
+			return errors.Wrap(err, "merkle proof")
+		}
+	default:
+		return fmt.Errorf("Invalid merkle proof flag : %d", flag)
+	}
+
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "parent count")
+	}
+
+	e.Parents = nil
+	for i := uint64(0); i < count; i++ {
+		parent := &Envelope{}
+		if err := parent.Deserialize(r); err != nil {
+			return errors.Wrapf(err, "parent %d", i)
+		}
+
+		e.AddParent(parent)
+	}
+
+	return nil
+}
+
+func (e Envelope) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *Envelope) UnmarshalBinary(data []byte) error {
+	return e.Deserialize(bytes.NewReader(data))
+}
@@ -0,0 +1,213 @@
+package spv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+type mockChain struct {
+	txs     map[bitcoin.Hash32]*wire.MsgTx
+	proofs  map[bitcoin.Hash32]*merkle_proof.MerkleProof
+	headers []*wire.BlockHeader
+}
+
+func (m *mockChain) GetTx(ctx context.Context, txid bitcoin.Hash32) (*wire.MsgTx, error) {
+	tx, exists := m.txs[txid]
+	if !exists {
+		return nil, errors.New("Not Found")
+	}
+	return tx, nil
+}
+
+func (m *mockChain) GetMerkleProof(ctx context.Context,
+	txid bitcoin.Hash32) (*merkle_proof.MerkleProof, error) {
+	return m.proofs[txid], nil
+}
+
+func (m *mockChain) BlockHeader(ctx context.Context,
+	blockHash bitcoin.Hash32) (*wire.BlockHeader, error) {
+
+	for _, header := range m.headers {
+		if header.BlockHash().Equal(&blockHash) {
+			return header, nil
+		}
+	}
+	return nil, merkle_proof.ErrHeaderNotFound
+}
+
+func (m *mockChain) BlockHeaderAtHeight(ctx context.Context,
+	height int) (*wire.BlockHeader, error) {
+
+	if height < 0 || height >= len(m.headers) {
+		return nil, merkle_proof.ErrHeaderNotFound
+	}
+	return m.headers[height], nil
+}
+
+// addBlock confirms the txs in a new block with other random txs.
+func (m *mockChain) addBlock(txs ...*wire.MsgTx) {
+	tree := merkle_proof.NewMerkleTree(false)
+	for i := 0; i < 3; i++ {
+		var hash bitcoin.Hash32
+		rand.Read(hash[:])
+		tree.AddHash(hash)
+	}
+
+	for _, tx := range txs {
+		tree.AddMerkleProof(*tx.TxHash())
+		tree.AddHash(*tx.TxHash())
+	}
+
+	root, proofs := tree.FinalizeMerkleProofs()
+	header := wire.NewBlockHeader(1, &bitcoin.Hash32{}, &root, 1, uint32(len(m.headers)))
+	m.headers = append(m.headers, header)
+
+	for i, tx := range txs {
+		proofs[i].BlockHeader = header
+		m.txs[*tx.TxHash()] = tx
+		m.proofs[*tx.TxHash()] = proofs[i]
+	}
+}
+
+func newTx(values []uint64, outpoints ...*wire.OutPoint) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	for _, outpoint := range outpoints {
+		tx.AddTxIn(wire.NewTxIn(outpoint, nil))
+	}
+	for _, value := range values {
+		tx.AddTxOut(wire.NewTxOut(value, bitcoin.Script{0x51}))
+	}
+	return tx
+}
+
+func TestEnvelope(t *testing.T) {
+	ctx := context.Background()
+	chain := &mockChain{
+		txs:    make(map[bitcoin.Hash32]*wire.MsgTx),
+		proofs: make(map[bitcoin.Hash32]*merkle_proof.MerkleProof),
+	}
+
+	var outpoint wire.OutPoint
+	rand.Read(outpoint.Hash[:])
+	grandparent := newTx([]uint64{5000, 6000}, &outpoint)
+	chain.addBlock(grandparent)
+
+	// Unconfirmed parent spending a confirmed tx.
+	parent := newTx([]uint64{4000}, wire.NewOutPoint(grandparent.TxHash(), 0))
+	chain.txs[*parent.TxHash()] = parent
+
+	tx := newTx([]uint64{9000}, wire.NewOutPoint(parent.TxHash(), 0),
+		wire.NewOutPoint(grandparent.TxHash(), 1))
+
+	envelope, err := NewEnvelope(ctx, tx, chain)
+	if err != nil {
+		t.Fatalf("Failed to create envelope : %s", err)
+	}
+
+	if envelope.IsConfirmed() || len(envelope.Parents) != 2 {
+		t.Fatalf("Wrong envelope parents : %d", len(envelope.Parents))
+	}
+
+	parentEnvelope := envelope.Parents[*parent.TxHash()]
+	if parentEnvelope == nil || len(parentEnvelope.Parents) != 1 {
+		t.Fatalf("Missing parent envelope")
+	}
+
+	grandparentEnvelope := envelope.Parents[*grandparent.TxHash()]
+	if grandparentEnvelope == nil || !grandparentEnvelope.IsConfirmed() {
+		t.Fatalf("Missing confirmed grandparent envelope")
+	}
+
+	if grandparentEnvelope.MerkleProof.BlockHeader != nil ||
+		grandparentEnvelope.MerkleProof.BlockHash == nil {
+		t.Errorf("Merkle proof should reference the block header by hash")
+	}
+
+	output, err := envelope.InputOutput(1)
+	if err != nil {
+		t.Fatalf("Failed to get input output : %s", err)
+	}
+	if output.Value != 6000 {
+		t.Errorf("Wrong input output value : got %d, want %d", output.Value, 6000)
+	}
+
+	if err := envelope.Verify(ctx, chain); err != nil {
+		t.Fatalf("Failed to verify envelope : %s", err)
+	}
+
+	// JSON
+	js, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope : %s", err)
+	}
+	t.Logf("Envelope : %s", js)
+
+	jsonEnvelope := &Envelope{}
+	if err := json.Unmarshal(js, jsonEnvelope); err != nil {
+		t.Fatalf("Failed to unmarshal envelope : %s", err)
+	}
+
+	if err := jsonEnvelope.Verify(ctx, chain); err != nil {
+		t.Fatalf("Failed to verify JSON envelope : %s", err)
+	}
+
+	// Binary
+	b, err := envelope.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize envelope : %s", err)
+	}
+
+	binaryEnvelope := &Envelope{}
+	if err := binaryEnvelope.UnmarshalBinary(b); err != nil {
+		t.Fatalf("Failed to deserialize envelope : %s", err)
+	}
+
+	b2, err := binaryEnvelope.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to serialize envelope : %s", err)
+	}
+
+	if !bytes.Equal(b, b2) {
+		t.Errorf("Binary envelope doesn't match")
+	}
+
+	if err := binaryEnvelope.Verify(ctx, chain); err != nil {
+		t.Fatalf("Failed to verify binary envelope : %s", err)
+	}
+
+	// Unknown header
+	otherChain := &mockChain{}
+	if err := envelope.Verify(ctx, otherChain); errors.Cause(err) != merkle_proof.ErrHeaderNotFound {
+		t.Errorf("Wrong error : got %v, want %v", err, merkle_proof.ErrHeaderNotFound)
+	}
+
+	// Missing parent
+	delete(parentEnvelope.Parents, *grandparent.TxHash())
+	if err := envelope.Validate(); errors.Cause(err) != ErrMissingParent {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrMissingParent)
+	}
+	parentEnvelope.AddParent(grandparentEnvelope)
+
+	// Wrong tx
+	parentEnvelope.Tx = grandparent
+	if err := envelope.Validate(); errors.Cause(err) != ErrWrongTxID {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrWrongTxID)
+	}
+	parentEnvelope.Tx = parent
+
+	// Spending an output that doesn't exist
+	tx.TxIn[1].PreviousOutPoint.Index = 2
+	envelope.TxID = *tx.TxHash()
+	if err := envelope.Validate(); errors.Cause(err) != ErrInvalidParent {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrInvalidParent)
+	}
+}
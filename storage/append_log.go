@@ -0,0 +1,356 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultSegmentSize is the number of records combined into each segment by compaction.
+	DefaultSegmentSize = 1000
+
+	appendLogVersion = uint8(0)
+)
+
+var (
+	ErrInvalidSegment = errors.New("Invalid log segment")
+	ErrMissingRecord  = errors.New("Missing log record")
+)
+
+// AppendLogHandler is called for each record read from an append log. Returning an error stops
+// the read and the error is returned from ReadFrom.
+type AppendLogHandler func(seq uint64, record []byte) error
+
+// AppendLog is an immutable sequence of records kept in storage. Each record is assigned the
+// next sequence number, starting at zero, when it is appended and can't be modified or removed.
+//
+// Each record is written to its own key when appended so that appends are a single write. When
+// enough records are appended to fill a segment they are compacted into one key, so that the
+// number of keys stays small and reading the log doesn't need a read per record.
+//
+// Storage layout under the path:
+//   meta                  segment size and compacted sequence
+//   records/{seq}         records that haven't been compacted yet
+//   segments/{first seq}  compacted records
+type AppendLog struct {
+	store Storage
+	path  string
+
+	segmentSize uint64
+	compacted   uint64 // records before this sequence are in segments
+	next        uint64 // sequence of the next record appended
+
+	sync.Mutex
+}
+
+// NewAppendLog creates an append log under the path. Load must be called before it is used. The
+// segment size is only used for new logs since the size is saved with the log.
+func NewAppendLog(store Storage, path string, segmentSize int) *AppendLog {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
+
+	return &AppendLog{
+		store:       store,
+		path:        strings.TrimSuffix(path, "/"),
+		segmentSize: uint64(segmentSize),
+	}
+}
+
+// Load reads the state of the log from storage. A log that doesn't exist yet is empty.
+func (l *AppendLog) Load(ctx context.Context) error {
+	l.Lock()
+	defer l.Unlock()
+
+	b, err := l.store.Read(ctx, l.metaKey())
+	if err == nil {
+		if err := l.deserializeMeta(bytes.NewReader(b)); err != nil {
+			return errors.Wrap(err, "meta")
+		}
+	} else if errors.Cause(err) != ErrNotFound {
+		return errors.Wrap(err, "read meta")
+	}
+
+	keys, err := l.store.List(ctx, l.path+"/records")
+	if err != nil {
+		return errors.Wrap(err, "list records")
+	}
+
+	l.next = l.compacted
+	for _, key := range keys {
+		seq, err := strconv.ParseUint(key[strings.LastIndex(key, "/")+1:], 10, 64)
+		if err != nil {
+			continue // not a record
+		}
+
+		if seq < l.compacted {
+			// Left over from a compaction that didn't complete.
+			if err := l.store.Remove(ctx, key); err != nil && errors.Cause(err) != ErrNotFound {
+				return errors.Wrapf(err, "remove compacted record %d", seq)
+			}
+			continue
+		}
+
+		if seq >= l.next {
+			l.next = seq + 1
+		}
+	}
+
+	return nil
+}
+
+// Next returns the sequence number that will be assigned to the next record appended, which is
+// also the number of records in the log.
+func (l *AppendLog) Next() uint64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.next
+}
+
+// Append adds a record to the end of the log and returns its sequence number. The records are
+// compacted when a segment is full.
+func (l *AppendLog) Append(ctx context.Context, record []byte) (uint64, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	seq := l.next
+	if err := l.store.Write(ctx, l.recordKey(seq), record, nil); err != nil {
+		return 0, errors.Wrapf(err, "write record %d", seq)
+	}
+	l.next++
+
+	if l.next-l.compacted >= l.segmentSize {
+		if err := l.compact(ctx); err != nil {
+			return seq, errors.Wrap(err, "compact")
+		}
+	}
+
+	return seq, nil
+}
+
+// Compact combines any full segments of records. It is called automatically by Append, so only
+// needs to be called to retry after a compaction failure.
+func (l *AppendLog) Compact(ctx context.Context) error {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.compact(ctx)
+}
+
+func (l *AppendLog) compact(ctx context.Context) error {
+	for l.next-l.compacted >= l.segmentSize {
+		first := l.compacted
+		records := make([][]byte, 0, l.segmentSize)
+		for seq := first; seq < first+l.segmentSize; seq++ {
+			b, err := l.store.Read(ctx, l.recordKey(seq))
+			if err != nil {
+				return errors.Wrapf(err, "read record %d", seq)
+			}
+			records = append(records, b)
+		}
+
+		var buf bytes.Buffer
+		if err := serializeSegment(&buf, records); err != nil {
+			return errors.Wrap(err, "serialize segment")
+		}
+
+		if err := l.store.Write(ctx, l.segmentKey(first), buf.Bytes(), nil); err != nil {
+			return errors.Wrapf(err, "write segment %d", first)
+		}
+
+		// The records are only removed after the meta is saved so that a failure at any point
+		// leaves the records readable.
+		l.compacted = first + l.segmentSize
+		if err := l.saveMeta(ctx); err != nil {
+			l.compacted = first
+			return errors.Wrap(err, "save meta")
+		}
+
+		for seq := first; seq < l.compacted; seq++ {
+			err := l.store.Remove(ctx, l.recordKey(seq))
+			if err != nil && errors.Cause(err) != ErrNotFound {
+				return errors.Wrapf(err, "remove record %d", seq)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadFrom calls the handler for each record starting at the sequence number, in order, until
+// the end of the log. Records appended while reading are included.
+func (l *AppendLog) ReadFrom(ctx context.Context, seq uint64, handler AppendLogHandler) error {
+	for {
+		l.Lock()
+		next := l.next
+		compacted := l.compacted
+		segmentSize := l.segmentSize
+		l.Unlock()
+
+		if seq >= next {
+			return nil
+		}
+
+		if seq < compacted {
+			first := seq - (seq % segmentSize)
+			records, err := l.readSegment(ctx, first)
+			if err != nil {
+				return errors.Wrapf(err, "segment %d", first)
+			}
+
+			for _, record := range records[seq-first:] {
+				if err := handler(seq, record); err != nil {
+					return err
+				}
+				seq++
+			}
+			continue
+		}
+
+		record, err := l.store.Read(ctx, l.recordKey(seq))
+		if err != nil {
+			if errors.Cause(err) != ErrNotFound {
+				return errors.Wrapf(err, "read record %d", seq)
+			}
+
+			// The record might have been compacted since the state was checked.
+			l.Lock()
+			compacted = l.compacted
+			l.Unlock()
+			if seq < compacted {
+				continue
+			}
+
+			return errors.Wrapf(ErrMissingRecord, "%d", seq)
+		}
+
+		if err := handler(seq, record); err != nil {
+			return err
+		}
+		seq++
+	}
+}
+
+func (l *AppendLog) readSegment(ctx context.Context, first uint64) ([][]byte, error) {
+	b, err := l.store.Read(ctx, l.segmentKey(first))
+	if err != nil {
+		return nil, errors.Wrap(err, "read")
+	}
+
+	records, err := deserializeSegment(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "deserialize")
+	}
+
+	if uint64(len(records)) != l.segmentSize {
+		return nil, errors.Wrapf(ErrInvalidSegment, "%d records, should be %d", len(records),
+			l.segmentSize)
+	}
+
+	return records, nil
+}
+
+func (l *AppendLog) metaKey() string {
+	return l.path + "/meta"
+}
+
+func (l *AppendLog) recordKey(seq uint64) string {
+	return fmt.Sprintf("%s/records/%020d", l.path, seq)
+}
+
+func (l *AppendLog) segmentKey(first uint64) string {
+	return fmt.Sprintf("%s/segments/%020d", l.path, first)
+}
+
+func (l *AppendLog) saveMeta(ctx context.Context) error {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, appendLogVersion); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if err := binary.Write(&buf, binary.LittleEndian, l.segmentSize); err != nil {
+		return errors.Wrap(err, "segment size")
+	}
+
+	if err := binary.Write(&buf, binary.LittleEndian, l.compacted); err != nil {
+		return errors.Wrap(err, "compacted")
+	}
+
+	return l.store.Write(ctx, l.metaKey(), buf.Bytes(), nil)
+}
+
+func (l *AppendLog) deserializeMeta(r io.Reader) error {
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if version != 0 {
+		return fmt.Errorf("Unsupported version : %d", version)
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &l.segmentSize); err != nil {
+		return errors.Wrap(err, "segment size")
+	}
+
+	if l.segmentSize == 0 {
+		return errors.Wrap(ErrInvalidSegment, "zero segment size")
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &l.compacted); err != nil {
+		return errors.Wrap(err, "compacted")
+	}
+
+	return nil
+}
+
+func serializeSegment(w io.Writer, records [][]byte) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(records))); err != nil {
+		return errors.Wrap(err, "count")
+	}
+
+	for i, record := range records {
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(record))); err != nil {
+			return errors.Wrapf(err, "size %d", i)
+		}
+
+		if _, err := w.Write(record); err != nil {
+			return errors.Wrapf(err, "record %d", i)
+		}
+	}
+
+	return nil
+}
+
+func deserializeSegment(r io.Reader) ([][]byte, error) {
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, errors.Wrap(err, "count")
+	}
+
+	var records [][]byte
+	for i := uint32(0); i < count; i++ {
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return nil, errors.Wrapf(err, "size %d", i)
+		}
+
+		record := make([]byte, size)
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nil, errors.Wrapf(err, "record %d", i)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAppendLog(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockStorage()
+
+	log := NewAppendLog(store, "events/", 4)
+	if err := log.Load(ctx); err != nil {
+		t.Fatalf("Failed to load : %s", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		seq, err := log.Append(ctx, []byte(fmt.Sprintf("record %d", i)))
+		if err != nil {
+			t.Fatalf("Failed to append %d : %s", i, err)
+		}
+
+		if seq != uint64(i) {
+			t.Fatalf("Wrong sequence : got %d, want %d", seq, i)
+		}
+	}
+
+	// 8 records should be compacted into 2 segments and 2 records should remain.
+	segments, _ := store.List(ctx, "events/segments")
+	if len(segments) != 2 {
+		t.Fatalf("Wrong segment count : got %d, want %d", len(segments), 2)
+	}
+
+	records, _ := store.List(ctx, "events/records")
+	if len(records) != 2 {
+		t.Fatalf("Wrong record count : got %d, want %d", len(records), 2)
+	}
+
+	for _, start := range []uint64{0, 3, 4, 7, 8, 9, 10} {
+		next := start
+		if err := log.ReadFrom(ctx, start, func(seq uint64, record []byte) error {
+			if seq != next {
+				return fmt.Errorf("Wrong sequence : got %d, want %d", seq, next)
+			}
+
+			want := []byte(fmt.Sprintf("record %d", seq))
+			if !bytes.Equal(record, want) {
+				return fmt.Errorf("Wrong record %d : got %s, want %s", seq, record, want)
+			}
+
+			next++
+			return nil
+		}); err != nil {
+			t.Fatalf("Failed to read from %d : %s", start, err)
+		}
+
+		if start < 10 && next != 10 {
+			t.Fatalf("Read from %d stopped at %d", start, next)
+		}
+	}
+
+	// Reload from storage.
+	loaded := NewAppendLog(store, "events", 100)
+	if err := loaded.Load(ctx); err != nil {
+		t.Fatalf("Failed to load : %s", err)
+	}
+
+	if loaded.Next() != 10 {
+		t.Fatalf("Wrong loaded next : got %d, want %d", loaded.Next(), 10)
+	}
+
+	seq, err := loaded.Append(ctx, []byte("record 10"))
+	if err != nil {
+		t.Fatalf("Failed to append : %s", err)
+	}
+	if seq != 10 {
+		t.Fatalf("Wrong sequence : got %d, want %d", seq, 10)
+	}
+
+	// The saved segment size is used.
+	if _, err := loaded.Append(ctx, []byte("record 11")); err != nil {
+		t.Fatalf("Failed to append : %s", err)
+	}
+
+	records, _ = store.List(ctx, "events/records")
+	if len(records) != 0 {
+		t.Fatalf("Wrong record count : got %d, want %d", len(records), 0)
+	}
+
+	// Handler errors stop the read.
+	stop := errors.New("Stop")
+	count := 0
+	err = loaded.ReadFrom(ctx, 0, func(seq uint64, record []byte) error {
+		count++
+		if count == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("Wrong read error : got %v, want %s", err, stop)
+	}
+	if count != 5 {
+		t.Fatalf("Wrong read count : got %d, want %d", count, 5)
+	}
+}
+
+func TestAppendLogIncompleteCompaction(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockStorage()
+
+	log := NewAppendLog(store, "events", 2)
+	for i := 0; i < 3; i++ {
+		if _, err := log.Append(ctx, []byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append %d : %s", i, err)
+		}
+	}
+
+	// Simulate a failure after the meta was saved but before the records were removed.
+	for seq := uint64(0); seq < 2; seq++ {
+		store.Write(ctx, log.recordKey(seq), []byte{byte(seq)}, nil)
+	}
+
+	loaded := NewAppendLog(store, "events", 2)
+	if err := loaded.Load(ctx); err != nil {
+		t.Fatalf("Failed to load : %s", err)
+	}
+
+	if loaded.Next() != 3 {
+		t.Fatalf("Wrong loaded next : got %d, want %d", loaded.Next(), 3)
+	}
+
+	records, _ := store.List(ctx, "events/records")
+	if len(records) != 1 {
+		t.Fatalf("Wrong record count : got %d, want %d", len(records), 1)
+	}
+}
@@ -20,6 +20,15 @@ type Config struct {
 	Root       string
 	MaxRetries int
 	RetryDelay int // Milliseconds between retries
+
+	// Server side encryption used for writes that don't specify it in their Options. Only
+	// supported by S3.
+	Encryption Encryption
+	KMSKeyID   string
+
+	// Checksum stores a SHA-256 checksum beside each object written so Verify can detect
+	// corruption. Only supported by the filesystem.
+	Checksum bool
 }
 
 // NewConfig returns a new Config with AWS style options.
@@ -43,9 +52,15 @@ func (c Config) String() string {
 		root = fmt.Sprintf("Root:%s", c.Root)
 	}
 
-	return fmt.Sprintf("{Bucket:%v %s MaxRetries:%v RetryDelay:%v ms}",
+	encryption := ""
+	if c.Encryption != EncryptionDefault {
+		encryption = fmt.Sprintf(" Encryption:%s", c.Encryption)
+	}
+
+	return fmt.Sprintf("{Bucket:%v %s MaxRetries:%v RetryDelay:%v ms%s}",
 		c.Bucket,
 		root,
 		c.MaxRetries,
-		c.RetryDelay)
+		c.RetryDelay,
+		encryption)
 }
@@ -2,10 +2,21 @@ package storage
 
 import (
 	"context"
+	"hash/fnv"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+)
+
+const (
+	// filesystemLockStripes is the number of locks that writes to different keys are spread over.
+	filesystemLockStripes = 64
+)
+
+var (
+	filesystemLocks [filesystemLockStripes]sync.Mutex
 )
 
 // FilesystemStorage implements the Storage interface for interacting with
@@ -43,21 +54,42 @@ func (f *FilesystemStorage) Write(ctx context.Context,
 		return err
 	}
 
-	file, err := os.Create(filename)
+	lock := filesystemLock(filename)
+	lock.Lock()
+	defer lock.Unlock()
 
-	if err != nil {
+	if !f.Config.Checksum {
+		// Remove the checksum of previous data so it isn't validated against this data.
+		if err := os.Remove(filename + checksumSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return writeFileAtomic(filename, body, options.Mode)
+	}
+
+	// The checksum is written before the data and keeps the checksum of the previous data until
+	// the data is replaced, so the checksum matches the data if the write stops at any point.
+	sum := checksum(body)
+	pending := sum
+	if previous, err := readChecksums(filename); err == nil && len(previous) > 0 {
+		pending += "\n" + previous[0]
+	} else if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	if _, err := file.Write(body); err != nil {
+	if err := writeFileAtomic(filename+checksumSuffix, []byte(pending), options.Mode); err != nil {
 		return err
 	}
 
-	if err := file.Close(); err != nil {
+	if err := writeFileAtomic(filename, body, options.Mode); err != nil {
 		return err
 	}
 
-	return nil
+	if pending == sum {
+		return nil
+	}
+
+	return writeFileAtomic(filename+checksumSuffix, []byte(sum), options.Mode)
 }
 
 // Read reads the data from a file on the local filesystem.
@@ -83,11 +115,23 @@ func (f *FilesystemStorage) Read(ctx context.Context,
 func (f *FilesystemStorage) Remove(ctx context.Context, key string) error {
 	filename := f.buildPath(key)
 
+	lock := filesystemLock(filename)
+	lock.Lock()
+	defer lock.Unlock()
+
 	err := os.RemoveAll(filename)
 	if os.IsNotExist(err) {
 		return ErrNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filename + checksumSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
 }
 
 // All returns all objects in the store, from a given path.
@@ -112,6 +156,10 @@ func (f *FilesystemStorage) Search(ctx context.Context,
 	objects := [][]byte{}
 
 	for _, info := range files {
+		if isInternalFile(info.Name()) {
+			continue
+		}
+
 		var filePath string
 		if len(path) > 0 {
 			filePath = strings.Join([]string{path, info.Name()}, "/")
@@ -144,6 +192,10 @@ func (f *FilesystemStorage) Clear(ctx context.Context, query map[string]string)
 	}
 
 	for _, info := range files {
+		if isInternalFile(info.Name()) {
+			continue
+		}
+
 		var filePath string
 		if len(path) > 0 {
 			filePath = strings.Join([]string{path, info.Name()}, "/")
@@ -172,9 +224,13 @@ func (f *FilesystemStorage) List(ctx context.Context, path string) ([]string, er
 		return nil, err
 	}
 
-	keys := make([]string, len(files), len(files))
+	keys := make([]string, 0, len(files))
+
+	for _, info := range files {
+		if isInternalFile(info.Name()) {
+			continue
+		}
 
-	for i, info := range files {
 		var filePath string
 		if len(path) > 0 {
 			filePath = strings.Join([]string{path, info.Name()}, "/")
@@ -182,7 +238,7 @@ func (f *FilesystemStorage) List(ctx context.Context, path string) ([]string, er
 			filePath = info.Name()
 		}
 
-		keys[i] = filePath
+		keys = append(keys, filePath)
 	}
 
 	return keys, nil
@@ -217,3 +273,50 @@ func (f *FilesystemStorage) ensureExists(dir string, options *Options) error {
 
 	return nil
 }
+
+// filesystemLock returns the lock that serializes changes to the data and checksum files of the
+// filename within the process.
+func filesystemLock(filename string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(filepath.Clean(filename)))
+	return &filesystemLocks[h.Sum32()%filesystemLockStripes]
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory and then renames it to
+// the filename, so the file never contains partially written data. If the process stops during
+// the write then the temporary file is left behind for Verify to find. A zero mode uses the
+// default mode.
+func writeFileAtomic(filename string, body []byte, mode os.FileMode) error {
+	if mode == 0 {
+		mode = NewOptions().Mode
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+tempMarker+"*")
+	if err != nil {
+		return err
+	}
+	tempName := file.Name()
+
+	if _, err := file.Write(body); err != nil {
+		file.Close()
+		os.Remove(tempName)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	if err := os.Chmod(tempName, mode); err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	if err := os.Rename(tempName, filename); err != nil {
+		os.Remove(tempName)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tokenized/pkg/threads"
+
+	"github.com/pkg/errors"
+)
+
+// MigratingStorage moves items from an old storage to a new storage without downtime, for example
+// from the filesystem to S3. Writes and removes go to both storages, reads are from the new storage
+// with a fallback to the old storage, and Backfill copies existing items to the new storage in the
+// background. When the backfill is complete the old storage can be removed from the config.
+//
+// The old storage receives every change, so it is still complete if the migration is abandoned.
+// Search and List use the old storage until the backfill is complete because the new storage
+// doesn't have all of the items before then.
+type MigratingStorage struct {
+	old Storage
+	new Storage
+
+	// Writes hold a read lock so they can run concurrently, while the backfill holds the write
+	// lock while it copies each item so it can't overwrite a newer write with an old value.
+	copyLock sync.RWMutex
+
+	progress     BackfillProgress
+	progressLock sync.Mutex
+}
+
+// BackfillProgress is the progress of copying items from the old storage to the new storage.
+type BackfillProgress struct {
+	// Checked is the number of items in the old storage that have been checked.
+	Checked int
+
+	// Copied is the number of items that were copied because they weren't in the new storage.
+	Copied int
+
+	// Complete is true when all items have been copied.
+	Complete bool
+}
+
+// NewMigratingStorage returns a storage that migrates items from old to new.
+func NewMigratingStorage(old, new Storage) *MigratingStorage {
+	return &MigratingStorage{
+		old: old,
+		new: new,
+	}
+}
+
+// Write writes the item to both storages.
+func (s *MigratingStorage) Write(ctx context.Context, key string, body []byte,
+	options *Options) error {
+
+	s.copyLock.RLock()
+	defer s.copyLock.RUnlock()
+
+	if err := s.new.Write(ctx, key, body, options); err != nil {
+		return errors.Wrap(err, "new")
+	}
+
+	if err := s.old.Write(ctx, key, body, options); err != nil {
+		return errors.Wrap(err, "old")
+	}
+
+	return nil
+}
+
+// Read reads the item from the new storage, or from the old storage if it hasn't been copied yet.
+func (s *MigratingStorage) Read(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.new.Read(ctx, key)
+	if err == nil {
+		return b, nil
+	}
+	if errors.Cause(err) != ErrNotFound {
+		return nil, errors.Wrap(err, "new")
+	}
+
+	return s.old.Read(ctx, key)
+}
+
+// Remove removes the item from both storages. It returns ErrNotFound if neither contained it.
+func (s *MigratingStorage) Remove(ctx context.Context, key string) error {
+	s.copyLock.RLock()
+	defer s.copyLock.RUnlock()
+
+	newErr := s.new.Remove(ctx, key)
+	if newErr != nil && errors.Cause(newErr) != ErrNotFound {
+		return errors.Wrap(newErr, "new")
+	}
+
+	oldErr := s.old.Remove(ctx, key)
+	if oldErr != nil && errors.Cause(oldErr) != ErrNotFound {
+		return errors.Wrap(oldErr, "old")
+	}
+
+	if newErr != nil && oldErr != nil {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Search searches the old storage until the backfill is complete, then the new storage.
+func (s *MigratingStorage) Search(ctx context.Context,
+	query map[string]string) ([][]byte, error) {
+	return s.current().Search(ctx, query)
+}
+
+// Clear clears the matching items from both storages.
+func (s *MigratingStorage) Clear(ctx context.Context, query map[string]string) error {
+	s.copyLock.RLock()
+	defer s.copyLock.RUnlock()
+
+	if err := s.new.Clear(ctx, query); err != nil {
+		return errors.Wrap(err, "new")
+	}
+
+	if err := s.old.Clear(ctx, query); err != nil {
+		return errors.Wrap(err, "old")
+	}
+
+	return nil
+}
+
+// List lists the old storage until the backfill is complete, then the new storage.
+func (s *MigratingStorage) List(ctx context.Context, path string) ([]string, error) {
+	return s.current().List(ctx, path)
+}
+
+// Progress returns the progress of the backfill.
+func (s *MigratingStorage) Progress() BackfillProgress {
+	s.progressLock.Lock()
+	defer s.progressLock.Unlock()
+
+	return s.progress
+}
+
+// Backfill copies all items in the old storage that aren't in the new storage to the new storage.
+// Items already in the new storage are not replaced because they were written since the migration
+// started. It returns threads.Interrupted if the interrupt is closed before it finishes, and can
+// be run again to continue. It can be added to a threads.Supervisor.
+func (s *MigratingStorage) Backfill(ctx context.Context, interrupt <-chan interface{}) error {
+	s.progressLock.Lock()
+	s.progress.Checked = 0
+	s.progress.Copied = 0
+	s.progressLock.Unlock()
+
+	if err := walkItems(ctx, s.old, "", make(map[string]bool),
+		func(key string, b []byte) error {
+			select {
+			case <-interrupt:
+				return threads.Interrupted
+			default:
+			}
+
+			copied, err := s.copyItem(ctx, key)
+			if err != nil {
+				return errors.Wrapf(err, "copy %s", key)
+			}
+
+			s.progressLock.Lock()
+			s.progress.Checked++
+			if copied {
+				s.progress.Copied++
+			}
+			s.progressLock.Unlock()
+			return nil
+		}); err != nil {
+		return err
+	}
+
+	s.progressLock.Lock()
+	s.progress.Complete = true
+	s.progressLock.Unlock()
+	return nil
+}
+
+// copyItem copies the item from the old storage to the new storage if it isn't in the new
+// storage. The item is read again while the copy lock is held because it could have been changed
+// since it was listed.
+func (s *MigratingStorage) copyItem(ctx context.Context, key string) (bool, error) {
+	s.copyLock.Lock()
+	defer s.copyLock.Unlock()
+
+	if _, err := s.new.Read(ctx, key); err == nil {
+		return false, nil
+	} else if errors.Cause(err) != ErrNotFound {
+		return false, errors.Wrap(err, "read new")
+	}
+
+	b, err := s.old.Read(ctx, key)
+	if err != nil {
+		if errors.Cause(err) == ErrNotFound {
+			return false, nil // removed since it was listed
+		}
+		return false, errors.Wrap(err, "read old")
+	}
+
+	if err := s.new.Write(ctx, key, b, nil); err != nil {
+		return false, errors.Wrap(err, "write new")
+	}
+
+	return true, nil
+}
+
+// current returns the storage that contains all items.
+func (s *MigratingStorage) current() Storage {
+	if s.Progress().Complete {
+		return s.new
+	}
+	return s.old
+}
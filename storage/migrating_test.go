@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/tokenized/pkg/threads"
+
+	"github.com/pkg/errors"
+)
+
+func TestMigratingStorage(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "migrating")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := NewFilesystemStorage(Config{Root: dir, Bucket: "test"})
+	new := NewMockStorage()
+
+	for _, key := range []string{"contracts/a", "contracts/b", "contracts/sub/c", "removed"} {
+		if err := old.Write(ctx, key, []byte("old "+key), nil); err != nil {
+			t.Fatalf("Failed to write %s : %s", key, err)
+		}
+	}
+
+	store := NewMigratingStorage(old, new)
+
+	// Reads fall back to the old storage.
+	b, err := store.Read(ctx, "contracts/a")
+	if err != nil {
+		t.Fatalf("Failed to read : %s", err)
+	}
+	if !bytes.Equal(b, []byte("old contracts/a")) {
+		t.Fatalf("Wrong value : got %s, want %s", b, "old contracts/a")
+	}
+
+	// Writes go to both storages.
+	if err := store.Write(ctx, "contracts/b", []byte("updated"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+	for name, s := range map[string]Storage{"old": old, "new": new} {
+		b, err := s.Read(ctx, "contracts/b")
+		if err != nil {
+			t.Fatalf("Failed to read %s : %s", name, err)
+		}
+		if !bytes.Equal(b, []byte("updated")) {
+			t.Fatalf("Wrong %s value : got %s, want %s", name, b, "updated")
+		}
+	}
+
+	if err := store.Remove(ctx, "removed"); err != nil {
+		t.Fatalf("Failed to remove : %s", err)
+	}
+	if _, err := old.Read(ctx, "removed"); errors.Cause(err) != ErrNotFound {
+		t.Fatalf("Wrong read error after remove : got %v, want %s", err, ErrNotFound)
+	}
+
+	// Interrupted before any items are copied.
+	interrupt := make(chan interface{})
+	close(interrupt)
+	if err := store.Backfill(ctx, interrupt); errors.Cause(err) != threads.Interrupted {
+		t.Fatalf("Wrong backfill error : got %v, want %s", err, threads.Interrupted)
+	}
+	if store.Progress().Complete {
+		t.Fatalf("Interrupted backfill should not be complete")
+	}
+
+	if err := store.Backfill(ctx, make(chan interface{})); err != nil {
+		t.Fatalf("Failed to backfill : %s", err)
+	}
+
+	progress := store.Progress()
+	if !progress.Complete || progress.Checked != 3 || progress.Copied != 2 {
+		t.Fatalf("Wrong progress : %+v", progress)
+	}
+
+	// The newer write was not replaced.
+	data := new.Data()
+	if !bytes.Equal(data["contracts/b"], []byte("updated")) {
+		t.Fatalf("Wrong new value : got %s, want %s", data["contracts/b"], "updated")
+	}
+	if !bytes.Equal(data["contracts/sub/c"], []byte("old contracts/sub/c")) {
+		t.Fatalf("Wrong new value : got %s, want %s", data["contracts/sub/c"],
+			"old contracts/sub/c")
+	}
+	if len(data) != 3 {
+		t.Fatalf("Wrong new item count : got %d, want 3", len(data))
+	}
+
+	// List uses the new storage after the backfill is complete.
+	keys, err := store.List(ctx, "contracts")
+	if err != nil {
+		t.Fatalf("Failed to list : %s", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("Wrong keys : %v", keys)
+	}
+}
@@ -2,43 +2,155 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
 	"strings"
+	"sync"
+	"time"
 )
 
-// MockStorage implements the Storage interface for but just holds the data in memory.
+const (
+	// mockStorageStripes is the number of independently locked partitions of the mock storage's
+	// data. Keys are assigned to a stripe by hash so that concurrent access to different keys
+	// rarely contends for the same lock.
+	mockStorageStripes = 32
+)
+
+var (
+	// ErrInjectedFailure is returned by MockStorage when a failure is injected by the failure
+	// rate.
+	ErrInjectedFailure = errors.New("Injected Failure")
+)
+
+// MockFailureFunc is called by MockStorage before each operation. op is the name of the
+// operation, like "Read" or "Write", and key is the key or path it applies to. A non-nil error is
+// returned by the operation instead of performing it.
+type MockFailureFunc func(op, key string) error
+
+// MockStorage implements the Storage interface for but just holds the data in memory. It is safe
+// for concurrent use. Latency and failures can be added to simulate a remote store like S3.
 type MockStorage struct {
-	Data map[string][]byte
+	stripes [mockStorageStripes]*mockStripe
+
+	minLatency  time.Duration
+	maxLatency  time.Duration
+	failureRate float64
+	failureFunc MockFailureFunc
+	random      *rand.Rand
+
+	sync.Mutex
+}
+
+type mockStripe struct {
+	data map[string][]byte
+
+	sync.RWMutex
 }
 
 // MockStorage creates a new mock storage.
 func NewMockStorage() *MockStorage {
-	return &MockStorage{
-		Data: make(map[string][]byte),
+	result := &MockStorage{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for i := range result.stripes {
+		result.stripes[i] = &mockStripe{
+			data: make(map[string][]byte),
+		}
+	}
+
+	return result
+}
+
+// SetLatency adds a random delay between min and max to every operation.
+func (s *MockStorage) SetLatency(min, max time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	if max < min {
+		max = min
 	}
+	s.minLatency = min
+	s.maxLatency = max
+}
+
+// SetFailureRate makes operations fail with ErrInjectedFailure at the rate given, from 0.0 for
+// never to 1.0 for always.
+func (s *MockStorage) SetFailureRate(rate float64) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failureRate = rate
+}
+
+// SetFailureFunc sets a function that can fail specific operations. Set it to nil to remove it.
+func (s *MockStorage) SetFailureFunc(f MockFailureFunc) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failureFunc = f
+}
+
+// Data returns a copy of all of the data in the store.
+func (s *MockStorage) Data() map[string][]byte {
+	result := make(map[string][]byte)
+	for _, stripe := range s.stripes {
+		stripe.RLock()
+		for key, b := range stripe.data {
+			result[key] = copyBytes(b)
+		}
+		stripe.RUnlock()
+	}
+
+	return result
 }
 
 // Write will write the data to the key in the S3 Bucket.
 func (s *MockStorage) Write(ctx context.Context, key string, body []byte, options *Options) error {
-	s.Data[key] = body
+	if err := s.simulate(ctx, "Write", key); err != nil {
+		return err
+	}
+
+	stripe := s.stripe(key)
+	stripe.Lock()
+	stripe.data[key] = copyBytes(body)
+	stripe.Unlock()
 	return nil
 }
 
 // Read reads the data from a file on the local filesystem.
 func (s *MockStorage) Read(ctx context.Context, key string) ([]byte, error) {
-	result, exists := s.Data[key]
+	if err := s.simulate(ctx, "Read", key); err != nil {
+		return nil, err
+	}
+
+	stripe := s.stripe(key)
+	stripe.RLock()
+	defer stripe.RUnlock()
+
+	result, exists := stripe.data[key]
 	if !exists {
 		return nil, ErrNotFound
 	}
-	return result, nil
+	return copyBytes(result), nil
 }
 
 // Remove removes the object stored at key, in the S3 Bucket.
 func (s *MockStorage) Remove(ctx context.Context, key string) error {
-	_, exists := s.Data[key]
+	if err := s.simulate(ctx, "Remove", key); err != nil {
+		return err
+	}
+
+	stripe := s.stripe(key)
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	_, exists := stripe.data[key]
 	if !exists {
 		return ErrNotFound
 	}
-	delete(s.Data, key)
+	delete(stripe.data, key)
 	return nil
 }
 
@@ -46,15 +158,22 @@ func (s *MockStorage) Remove(ctx context.Context, key string) error {
 //
 // The path can be empty.
 func (s *MockStorage) Search(ctx context.Context, query map[string]string) ([][]byte, error) {
-	result := make([][]byte, 0)
 	path := query["path"]
+	if err := s.simulate(ctx, "Search", path); err != nil {
+		return nil, err
+	}
 
-	for key, b := range s.Data {
-		if !strings.HasPrefix(key, path) {
-			continue
-		}
+	result := make([][]byte, 0)
+	for _, stripe := range s.stripes {
+		stripe.RLock()
+		for key, b := range stripe.data {
+			if !strings.HasPrefix(key, path) {
+				continue
+			}
 
-		result = append(result, b)
+			result = append(result, copyBytes(b))
+		}
+		stripe.RUnlock()
 	}
 
 	return result, nil
@@ -62,33 +181,91 @@ func (s *MockStorage) Search(ctx context.Context, query map[string]string) ([][]
 
 func (s *MockStorage) Clear(ctx context.Context, query map[string]string) error {
 	path := query["path"]
-
-	toRemove := make([]string, 0)
-	for key, _ := range s.Data {
-		if !strings.HasPrefix(key, path) {
-			continue
-		}
-
-		toRemove = append(toRemove, key)
+	if err := s.simulate(ctx, "Clear", path); err != nil {
+		return err
 	}
 
-	for _, key := range toRemove {
-		delete(s.Data, key)
+	for _, stripe := range s.stripes {
+		stripe.Lock()
+		for key, _ := range stripe.data {
+			if !strings.HasPrefix(key, path) {
+				continue
+			}
+
+			delete(stripe.data, key)
+		}
+		stripe.Unlock()
 	}
 
 	return nil
 }
 
 func (s *MockStorage) List(ctx context.Context, path string) ([]string, error) {
+	if err := s.simulate(ctx, "List", path); err != nil {
+		return nil, err
+	}
+
 	result := make([]string, 0)
+	for _, stripe := range s.stripes {
+		stripe.RLock()
+		for key, _ := range stripe.data {
+			if !strings.HasPrefix(key, path) {
+				continue
+			}
 
-	for key, _ := range s.Data {
-		if !strings.HasPrefix(key, path) {
-			continue
+			result = append(result, key)
 		}
-
-		result = append(result, key)
+		stripe.RUnlock()
 	}
 
 	return result, nil
 }
+
+// stripe returns the partition of the data that contains the key.
+func (s *MockStorage) stripe(key string) *mockStripe {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.stripes[h.Sum32()%mockStorageStripes]
+}
+
+// simulate applies the latency and failures that are configured for the operation.
+func (s *MockStorage) simulate(ctx context.Context, op, key string) error {
+	s.Lock()
+	latency := s.minLatency
+	if s.maxLatency > s.minLatency {
+		latency += time.Duration(s.random.Int63n(int64(s.maxLatency - s.minLatency)))
+	}
+	fail := s.failureRate > 0.0 && s.random.Float64() < s.failureRate
+	failureFunc := s.failureFunc
+	s.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	if fail {
+		return ErrInjectedFailure
+	}
+
+	if failureFunc != nil {
+		return failureFunc(op, key)
+	}
+
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	result := make([]byte, len(b))
+	copy(result, b)
+	return result
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMockStorageConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockStorage()
+
+	var wait sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func(worker int) {
+			defer wait.Done()
+
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("worker/%d/%d", worker, j)
+				value := []byte(key)
+				if err := store.Write(ctx, key, value, nil); err != nil {
+					errs <- err
+					return
+				}
+
+				read, err := store.Read(ctx, key)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(read, value) {
+					errs <- fmt.Errorf("Wrong value for %s : %s", key, read)
+					return
+				}
+
+				if _, err := store.List(ctx, "worker/"); err != nil {
+					errs <- err
+					return
+				}
+
+				if j%2 == 0 {
+					if err := store.Remove(ctx, key); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(i)
+	}
+
+	wait.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Failed concurrent access : %s", err)
+	}
+
+	keys, err := store.List(ctx, "worker/")
+	if err != nil {
+		t.Fatalf("Failed to list : %s", err)
+	}
+	if len(keys) != 500 {
+		t.Fatalf("Wrong key count : got %d, want %d", len(keys), 500)
+	}
+
+	if err := store.Clear(ctx, map[string]string{"path": "worker/1/"}); err != nil {
+		t.Fatalf("Failed to clear : %s", err)
+	}
+
+	items, err := store.Search(ctx, map[string]string{"path": "worker/"})
+	if err != nil {
+		t.Fatalf("Failed to search : %s", err)
+	}
+	if len(items) != 450 {
+		t.Fatalf("Wrong item count : got %d, want %d", len(items), 450)
+	}
+
+	if len(store.Data()) != 450 {
+		t.Fatalf("Wrong data count : got %d, want %d", len(store.Data()), 450)
+	}
+}
+
+func TestMockStorageCopies(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockStorage()
+
+	value := []byte("value")
+	if err := store.Write(ctx, "key", value, nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+	value[0] = 'x'
+
+	read, err := store.Read(ctx, "key")
+	if err != nil {
+		t.Fatalf("Failed to read : %s", err)
+	}
+	if string(read) != "value" {
+		t.Fatalf("Stored value modified : %s", read)
+	}
+}
+
+func TestMockStorageFailures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockStorage()
+
+	store.SetFailureRate(1.0)
+	if err := store.Write(ctx, "key", []byte("value"), nil); err != ErrInjectedFailure {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrInjectedFailure)
+	}
+	store.SetFailureRate(0.0)
+
+	readErr := errors.New("Read Failed")
+	store.SetFailureFunc(func(op, key string) error {
+		if op == "Read" && key == "bad" {
+			return readErr
+		}
+		return nil
+	})
+
+	if err := store.Write(ctx, "bad", []byte("value"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+	if _, err := store.Read(ctx, "bad"); err != readErr {
+		t.Fatalf("Wrong error : got %v, want %s", err, readErr)
+	}
+	store.SetFailureFunc(nil)
+
+	if _, err := store.Read(ctx, "bad"); err != nil {
+		t.Fatalf("Failed to read : %s", err)
+	}
+
+	store.SetLatency(50*time.Millisecond, 100*time.Millisecond)
+
+	start := time.Now()
+	if _, err := store.Read(ctx, "bad"); err != nil {
+		t.Fatalf("Failed to read : %s", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("Latency not applied : %s", time.Since(start))
+	}
+
+	cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := store.Read(cancelCtx, "bad"); err != context.DeadlineExceeded {
+		t.Fatalf("Wrong error : got %v, want %s", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+)
+
+// Keys for context key/pairs
+type storagekey int
+
+const (
+	operationKey storagekey = 1
+)
+
+// OperationConfig overrides the timeout and retry configuration of a store for an operation, so
+// latency sensitive reads can fail fast while background writes retry more. When an override is
+// provided all of its fields are used, so start from the store's config with
+// Config.OperationConfig. Only S3 retries operations.
+type OperationConfig struct {
+	MaxRetries int
+	RetryDelay int // Milliseconds between retries
+
+	// Timeout is the maximum duration of the operation including retries. Zero means no timeout.
+	Timeout time.Duration
+
+	// Idempotent operations are retried after any failure. Operations that aren't idempotent are
+	// only retried when the store rejected the request without applying it, like when throttling.
+	Idempotent bool
+}
+
+// OperationConfig returns the default operation config of the store.
+func (c Config) OperationConfig() OperationConfig {
+	return OperationConfig{
+		MaxRetries: c.MaxRetries,
+		RetryDelay: c.RetryDelay,
+		Idempotent: true,
+	}
+}
+
+// FailFast returns an operation config that doesn't retry and times out after the duration.
+func FailFast(timeout time.Duration) OperationConfig {
+	return OperationConfig{
+		Timeout:    timeout,
+		Idempotent: true,
+	}
+}
+
+// ContextWithOperationConfig returns a context that overrides the store's timeout and retry
+// configuration for operations called with it.
+func ContextWithOperationConfig(ctx context.Context, config OperationConfig) context.Context {
+	return context.WithValue(ctx, operationKey, config)
+}
+
+// OperationConfigFromContext returns the operation config override of the context, or nil if
+// there isn't one.
+func OperationConfigFromContext(ctx context.Context) *OperationConfig {
+	configValue := ctx.Value(operationKey)
+	if configValue == nil {
+		return nil
+	}
+
+	config, ok := configValue.(OperationConfig)
+	if !ok {
+		return nil
+	}
+	return &config
+}
+
+// operationConfig returns the operation config for an operation. The options override takes
+// precedence over the context override, which takes precedence over the store's config.
+func (c Config) operationConfig(ctx context.Context, options *Options) OperationConfig {
+	if options != nil && options.Operation != nil {
+		return *options.Operation
+	}
+
+	if config := OperationConfigFromContext(ctx); config != nil {
+		return *config
+	}
+
+	return c.OperationConfig()
+}
+
+// retry calls the function until it succeeds or the retries of the operation config are used. It
+// stops when the function returns ErrNotFound, the timeout expires, or the context is canceled.
+func (c Config) retry(ctx context.Context, options *Options,
+	f func(ctx context.Context) error) error {
+
+	config := c.operationConfig(ctx, options)
+
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	var err error
+	for i := 0; i <= config.MaxRetries; i++ {
+		if i != 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Wrap(err, ctx.Err().Error())
+			case <-time.After(time.Duration(config.RetryDelay) * time.Millisecond):
+			}
+		}
+
+		err = f(ctx)
+		if err == nil || errors.Cause(err) == ErrNotFound {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if !config.Idempotent && !isRejected(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// isRejected returns true if the error is a response from the store that shows the request was
+// not applied.
+func isRejected(err error) bool {
+	failure, ok := errors.Cause(err).(awserr.RequestFailure)
+	if !ok {
+		return false
+	}
+
+	switch failure.StatusCode() {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+)
+
+func TestOperationConfigRetry(t *testing.T) {
+	ctx := context.Background()
+	config := NewConfig("bucket", "")
+	config.SetupRetry(3, 1)
+
+	failure := errors.New("failure")
+	attempts := 0
+	f := func(ctx context.Context) error {
+		attempts++
+		return failure
+	}
+
+	if err := config.retry(ctx, nil, f); err != failure {
+		t.Fatalf("Wrong error : got %v, want %v", err, failure)
+	}
+	if attempts != 4 {
+		t.Fatalf("Wrong attempts for store config : got %d, want %d", attempts, 4)
+	}
+
+	// Context override
+	attempts = 0
+	failFastCtx := ContextWithOperationConfig(ctx, FailFast(time.Second))
+	config.retry(failFastCtx, nil, f)
+	if attempts != 1 {
+		t.Fatalf("Wrong attempts for context override : got %d, want %d", attempts, 1)
+	}
+
+	// Options override takes precedence over context.
+	attempts = 0
+	operation := config.OperationConfig()
+	operation.MaxRetries = 5
+	config.retry(failFastCtx, &Options{Operation: &operation}, f)
+	if attempts != 6 {
+		t.Fatalf("Wrong attempts for options override : got %d, want %d", attempts, 6)
+	}
+
+	// Not found isn't retried.
+	attempts = 0
+	err := config.retry(ctx, nil, func(ctx context.Context) error {
+		attempts++
+		return ErrNotFound
+	})
+	if err != ErrNotFound {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrNotFound)
+	}
+	if attempts != 1 {
+		t.Fatalf("Wrong attempts for not found : got %d, want %d", attempts, 1)
+	}
+}
+
+func TestOperationConfigIdempotent(t *testing.T) {
+	config := NewConfig("bucket", "")
+	config.SetupRetry(2, 1)
+
+	operation := config.OperationConfig()
+	operation.Idempotent = false
+	ctx := ContextWithOperationConfig(context.Background(), operation)
+
+	attempts := 0
+	config.retry(ctx, nil, func(ctx context.Context) error {
+		attempts++
+		return errors.New("connection reset")
+	})
+	if attempts != 1 {
+		t.Fatalf("Wrong attempts for ambiguous failure : got %d, want %d", attempts, 1)
+	}
+
+	attempts = 0
+	config.retry(ctx, nil, func(ctx context.Context) error {
+		attempts++
+		return awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil),
+			http.StatusServiceUnavailable, "request")
+	})
+	if attempts != 3 {
+		t.Fatalf("Wrong attempts for rejected request : got %d, want %d", attempts, 3)
+	}
+}
+
+func TestOperationConfigTimeout(t *testing.T) {
+	config := NewConfig("bucket", "")
+	config.SetupRetry(100, 10)
+
+	operation := config.OperationConfig()
+	operation.Timeout = 50 * time.Millisecond
+	ctx := ContextWithOperationConfig(context.Background(), operation)
+
+	start := time.Now()
+	err := config.retry(ctx, nil, func(ctx context.Context) error {
+		return errors.New("failure")
+	})
+	if err == nil {
+		t.Fatalf("Operation should fail")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Operation should time out : %s", elapsed)
+	}
+}
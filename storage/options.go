@@ -1,6 +1,28 @@
 package storage
 
-import "os"
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// Encryption is the server side encryption applied to written objects.
+type Encryption uint8
+
+const (
+	// EncryptionDefault uses the store's default encryption.
+	EncryptionDefault = Encryption(0)
+
+	// EncryptionNone doesn't request server side encryption.
+	EncryptionNone = Encryption(1)
+
+	// EncryptionS3 uses keys managed by the store (SSE-S3).
+	EncryptionS3 = Encryption(2)
+
+	// EncryptionKMS uses a KMS key (SSE-KMS). The key is specified by KMSKeyID, or the account's
+	// default KMS key is used when KMSKeyID is empty.
+	EncryptionKMS = Encryption(3)
+)
 
 // Options for writing data. Not all Storage implementations will support
 // all options.
@@ -10,6 +32,17 @@ type Options struct {
 	TTL     int64
 	Mode    os.FileMode
 	DirMode os.FileMode
+
+	// Server side encryption. Only supported by S3 and ignored by other implementations.
+	Encryption Encryption
+	KMSKeyID   string
+
+	// Tags added to the object. Only supported by S3 and ignored by other implementations.
+	Tags map[string]string
+
+	// Operation overrides the store's timeout and retry configuration for the write. It takes
+	// precedence over an override in the context.
+	Operation *OperationConfig
 }
 
 // NewOptions returns an Options struct with sane defaults set.
@@ -22,3 +55,70 @@ func NewOptions() Options {
 		DirMode: 0755,
 	}
 }
+
+// SetKMSEncryption sets the options to encrypt with the KMS key. An empty key ID uses the
+// account's default KMS key.
+func (o *Options) SetKMSEncryption(keyID string) {
+	o.Encryption = EncryptionKMS
+	o.KMSKeyID = keyID
+}
+
+// AddTag adds a tag to the options.
+func (o *Options) AddTag(key, value string) {
+	if o.Tags == nil {
+		o.Tags = make(map[string]string)
+	}
+	o.Tags[key] = value
+}
+
+// TagSet returns the tags encoded as a URL query string, which is the format used by S3. It
+// returns an empty string when there are no tags.
+func (o Options) TagSet() string {
+	if len(o.Tags) == 0 {
+		return ""
+	}
+
+	values := url.Values{}
+	for key, value := range o.Tags {
+		values.Set(key, value)
+	}
+	return values.Encode()
+}
+
+func (e Encryption) String() string {
+	switch e {
+	case EncryptionDefault:
+		return "default"
+	case EncryptionNone:
+		return "none"
+	case EncryptionS3:
+		return "sse-s3"
+	case EncryptionKMS:
+		return "sse-kms"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText returns the text name of the encryption.
+func (e Encryption) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText parses the text name of the encryption, so it can be set in configs.
+func (e *Encryption) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "", "default":
+		*e = EncryptionDefault
+	case "none":
+		*e = EncryptionNone
+	case "sse-s3", "AES256":
+		*e = EncryptionS3
+	case "sse-kms", "aws:kms":
+		*e = EncryptionKMS
+	default:
+		return fmt.Errorf("Unknown encryption : %s", string(text))
+	}
+
+	return nil
+}
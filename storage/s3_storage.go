@@ -58,79 +58,98 @@ func (s S3Storage) Write(ctx context.Context,
 	poi := s3.PutObjectInput{
 		Bucket: aws.String(s.Config.Bucket),
 		Key:    aws.String(key),
-		Body:   bytes.NewReader(body),
 	}
 
-	var err error
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
-
-		if options != nil {
-			if options.TTL > 0 {
-				expiry := time.Now().Add(time.Duration(options.TTL) * time.Second)
-				poi.Expires = &expiry
-			}
-		}
+	s.applyWriteOptions(&poi, options)
 
-		_, err = svc.PutObject(&poi)
-		if err == nil {
-			return nil
+	if options != nil {
+		if options.TTL > 0 {
+			expiry := time.Now().Add(time.Duration(options.TTL) * time.Second)
+			poi.Expires = &expiry
 		}
-
-		logger.Error(ctx, "S3CallFailed to write to %v : %v", key, err)
 	}
 
-	if err != nil {
+	if err := s.Config.retry(ctx, options, func(ctx context.Context) error {
+		poi.Body = bytes.NewReader(body)
+		_, err := svc.PutObjectWithContext(ctx, &poi)
+		if err != nil {
+			logger.Error(ctx, "S3CallFailed to write to %v : %v", key, err)
+		}
+		return err
+	}); err != nil {
 		logger.Error(ctx, "S3CallAborted write to %v : %v", key, err)
 		return errors.Wrap(err, fmt.Sprintf("Failed to write to %v", key))
 	}
+
 	return nil
 }
 
+// applyWriteOptions sets the encryption and tags for the object. Encryption falls back to the
+// Config when it isn't specified in the options.
+func (s S3Storage) applyWriteOptions(poi *s3.PutObjectInput, options *Options) {
+	encryption := s.Config.Encryption
+	kmsKeyID := s.Config.KMSKeyID
+	if options != nil && options.Encryption != EncryptionDefault {
+		encryption = options.Encryption
+		kmsKeyID = options.KMSKeyID
+	}
+
+	switch encryption {
+	case EncryptionS3:
+		poi.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAes256)
+	case EncryptionKMS:
+		poi.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		if len(kmsKeyID) > 0 {
+			poi.SSEKMSKeyId = aws.String(kmsKeyID)
+		}
+	}
+
+	if options != nil {
+		if tags := options.TagSet(); len(tags) > 0 {
+			poi.Tagging = aws.String(tags)
+		}
+	}
+}
+
 // Read will read the data from the S3 Bucket.
 func (s S3Storage) Read(ctx context.Context, key string) ([]byte, error) {
 	svc := s3.New(s.Session)
 
-	var err error
-	var document *s3.GetObjectOutput
 	var b []byte
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
-
-		document, err = svc.GetObject(&s3.GetObjectInput{
+	if err := s.Config.retry(ctx, nil, func(ctx context.Context) error {
+		document, err := svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
 			Bucket: aws.String(s.Config.Bucket),
 			Key:    aws.String(key),
 		})
-
 		if err != nil {
 			if aerr, ok := err.(awserr.Error); ok {
 				if aerr.Code() == s3.ErrCodeNoSuchKey {
 					// specifically handle the "not found" case
-					return nil, ErrNotFound
+					return ErrNotFound
 				}
 			}
 
 			logger.Error(ctx, "S3CallFailed to read from %v : %v", key, err)
-			continue
+			return err
 		}
+		defer document.Body.Close()
 
 		b, err = ioutil.ReadAll(document.Body)
 		if err != nil {
 			logger.Error(ctx, "S3CallFailed to read from %v : %v", key, err)
-			continue
+			return err
 		}
 
-		break
-	}
+		return nil
+	}); err != nil {
+		if err == ErrNotFound {
+			return nil, ErrNotFound
+		}
 
-	if err != nil {
 		logger.Error(ctx, "S3CallAborted read from %v : %v", key, err)
 		return nil, errors.Wrap(err, fmt.Sprintf("Failed to read from %v", key))
 	}
+
 	return b, nil
 }
 
@@ -143,31 +162,28 @@ func (s S3Storage) Remove(ctx context.Context, key string) error {
 		Key:    aws.String(key),
 	}
 
-	var err error
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
+	if err := s.Config.retry(ctx, nil, func(ctx context.Context) error {
+		_, err := svc.DeleteObjectWithContext(ctx, do)
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok {
+				if aerr.Code() == s3.ErrCodeNoSuchKey {
+					// specifically handle the "not found" case
+					return ErrNotFound
+				}
+			}
 
-		_, err = svc.DeleteObject(do)
-		if err == nil {
-			return nil
+			logger.Error(ctx, "S3CallFailed to delete object at %v : %v", key, err)
 		}
-
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == s3.ErrCodeNoSuchKey {
-				// specifically handle the "not found" case
-				return ErrNotFound
-			}
+		return err
+	}); err != nil {
+		if err == ErrNotFound {
+			return ErrNotFound
 		}
 
-		logger.Error(ctx, "S3CallFailed to delete object at %v : %v", key, err)
-	}
-
-	if err != nil {
 		logger.Error(ctx, "S3CallAborted delete object at %v : %v", key, err)
 		return errors.Wrap(err, fmt.Sprintf("Failed to delete object at %v", key))
 	}
+
 	return nil
 }
 
@@ -176,90 +192,69 @@ func (s S3Storage) Search(ctx context.Context,
 
 	path := query["path"]
 
-	var err error
-	var keys []string
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
+	keys, err := s.List(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 
-		keys, err = s.findKeys(ctx, path)
-		if err == nil {
-			break
-		}
+	svc := s3manager.NewDownloader(s.Session)
 
-		logger.Error(ctx, "S3CallFailed to search %v : %v", path, err)
+	var buffers []*aws.WriteAtBuffer
+	if err := s.Config.retry(ctx, nil, func(ctx context.Context) error {
+		// New buffers for each attempt so data from a failed attempt isn't kept.
+		var objects []s3manager.BatchDownloadObject
+		objects, buffers = s.downloadObjects(keys)
+
+		iter := &s3manager.DownloadObjectsIterator{Objects: objects}
+		err := svc.DownloadWithIterator(ctx, iter)
+		if err != nil {
+			logger.Error(ctx, "S3CallFailed to download with iterator %v : %v", path, err)
+		}
+		return err
+	}); err != nil {
+		logger.Error(ctx, "S3CallAborted download with iterator %v : %v", path, err)
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to download with iterator %v", path))
 	}
 
-	if err != nil {
-		logger.Error(ctx, "S3CallAborted search %v : %v", path, err)
-		return nil, errors.Wrap(err, fmt.Sprintf("Failed to search %v", path))
+	result := make([][]byte, len(buffers))
+	for i, buf := range buffers {
+		result[i] = buf.Bytes()
 	}
 
-	svc := s3manager.NewDownloader(s.Session)
+	return result, nil
+}
 
-	buf := newObjectStore()
+// downloadObjects returns batch download objects for the keys, each with its own buffer. The
+// downloader writes the parts of an object concurrently at their offsets, so each object is
+// assembled in the buffer at the same index.
+func (s S3Storage) downloadObjects(keys []string) ([]s3manager.BatchDownloadObject,
+	[]*aws.WriteAtBuffer) {
 
 	objects := make([]s3manager.BatchDownloadObject, len(keys), len(keys))
+	buffers := make([]*aws.WriteAtBuffer, len(keys), len(keys))
 
 	bucket := &s.Config.Bucket
 
 	for i, k := range keys {
-		o := s3manager.BatchDownloadObject{
+		buffers[i] = &aws.WriteAtBuffer{}
+		objects[i] = s3manager.BatchDownloadObject{
 			Object: &s3.GetObjectInput{
 				Bucket: bucket,
 				Key:    aws.String(k),
 			},
-			Writer: buf,
-		}
-
-		objects[i] = o
-	}
-
-	iter := &s3manager.DownloadObjectsIterator{Objects: objects}
-
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
-
-		err = svc.DownloadWithIterator(ctx, iter)
-		if err == nil {
-			break
+			Writer: buffers[i],
 		}
-
-		logger.Error(ctx, "S3CallFailed to download with iterator %v : %v", path, err)
 	}
 
-	if err != nil {
-		logger.Error(ctx, "S3CallAborted download with iterator %v : %v", path, err)
-		return nil, errors.Wrap(err, fmt.Sprintf("Failed to download with iterator %v", path))
-	}
-
-	return buf.objects(), nil
+	return objects, buffers
 }
 
 func (s S3Storage) Clear(ctx context.Context, query map[string]string) error {
 	path := query["path"]
 
-	var err error
-	var keys []string
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
-
-		keys, err = s.findKeys(ctx, path)
-		if err == nil {
-			break
-		}
-
-		logger.Error(ctx, "S3CallFailed to search %v : %v", path, err)
-	}
-
+	keys, err := s.List(ctx, path)
 	if err != nil {
-		logger.Error(ctx, "S3CallAborted search %v : %v", path, err)
-		return errors.Wrap(err, fmt.Sprintf("Failed to search %v", path))
+		return err
 	}
 
 	svc := s3manager.NewBatchDelete(s.Session)
@@ -279,22 +274,14 @@ func (s S3Storage) Clear(ctx context.Context, query map[string]string) error {
 		objects[i] = o
 	}
 
-	iter := &s3manager.DeleteObjectsIterator{Objects: objects}
-
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
-
-		err = svc.Delete(ctx, iter)
-		if err == nil {
-			return nil
+	if err := s.Config.retry(ctx, nil, func(ctx context.Context) error {
+		iter := &s3manager.DeleteObjectsIterator{Objects: objects}
+		err := svc.Delete(ctx, iter)
+		if err != nil {
+			logger.Error(ctx, "S3CallFailed to delete %v : %v", path, err)
 		}
-
-		logger.Error(ctx, "S3CallFailed to delete %v : %v", path, err)
-	}
-
-	if err != nil {
+		return err
+	}); err != nil {
 		logger.Error(ctx, "S3CallAborted delete %v : %v", path, err)
 		return errors.Wrap(err, fmt.Sprintf("Failed to delete %v", path))
 	}
@@ -303,23 +290,20 @@ func (s S3Storage) Clear(ctx context.Context, query map[string]string) error {
 }
 
 func (s S3Storage) List(ctx context.Context, path string) ([]string, error) {
-	var err error
 	var keys []string
-	for i := 0; i <= s.Config.MaxRetries; i++ {
-		if i != 0 {
-			time.Sleep(time.Duration(s.Config.RetryDelay) * time.Millisecond)
-		}
-
+	if err := s.Config.retry(ctx, nil, func(ctx context.Context) error {
+		var err error
 		keys, err = s.findKeys(ctx, path)
-		if err == nil {
-			return keys, nil
+		if err != nil {
+			logger.Error(ctx, "S3CallFailed to search %v : %v", path, err)
 		}
-
-		logger.Error(ctx, "S3CallFailed to search %v : %v", path, err)
+		return err
+	}); err != nil {
+		logger.Error(ctx, "S3CallAborted search %v : %v", path, err)
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to search %v", path))
 	}
 
-	logger.Error(ctx, "S3CallAborted search %v : %v", path, err)
-	return nil, errors.Wrap(err, fmt.Sprintf("Failed to search %v", path))
+	return keys, nil
 }
 
 func (s S3Storage) findKeys(ctx context.Context, path string) ([]string, error) {
@@ -337,7 +321,7 @@ func (s S3Storage) findKeys(ctx context.Context, path string) ([]string, error)
 			StartAfter: last,
 		}
 
-		out, err := svc.ListObjectsV2(input)
+		out, err := svc.ListObjectsV2WithContext(ctx, input)
 		if err != nil {
 			return nil, err
 		}
@@ -365,31 +349,3 @@ func newAWSSession(config Config) *session.Session {
 	awsConfig := aws.NewConfig()
 	return session.New(awsConfig)
 }
-
-// objectStore implements the WriteAt interface.
-type objectStore struct {
-	data map[int][]byte
-}
-
-func newObjectStore() *objectStore {
-	return &objectStore{
-		data: map[int][]byte{},
-	}
-}
-
-func (o objectStore) WriteAt(p []byte, pos int64) (int, error) {
-	idx := len(o.data)
-	o.data[idx] = p
-
-	return len(p), nil
-}
-
-func (o objectStore) objects() [][]byte {
-	var objs = make([][]byte, len(o.data), len(o.data))
-
-	for i, o := range o.data {
-		objs[i] = o
-	}
-
-	return objs
-}
@@ -4,8 +4,104 @@ import (
 	"context"
 	"fmt"
 	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+func TestS3WriteOptions(t *testing.T) {
+	store := NewS3StorageWithSession(Config{
+		Bucket:     "s3-bucket-name",
+		Encryption: EncryptionKMS,
+		KMSKeyID:   "config-key",
+	}, nil)
+
+	// Config encryption is used when options are nil.
+	poi := s3.PutObjectInput{}
+	store.applyWriteOptions(&poi, nil)
+	if aws.StringValue(poi.ServerSideEncryption) != s3.ServerSideEncryptionAwsKms ||
+		aws.StringValue(poi.SSEKMSKeyId) != "config-key" || poi.Tagging != nil {
+		t.Fatalf("Wrong config options : %+v", poi)
+	}
+
+	// Options override the config.
+	options := NewOptions()
+	options.Encryption = EncryptionS3
+	options.AddTag("project", "tokenized")
+	options.AddTag("class", "a b&c")
+	poi = s3.PutObjectInput{}
+	store.applyWriteOptions(&poi, &options)
+	if aws.StringValue(poi.ServerSideEncryption) != s3.ServerSideEncryptionAes256 ||
+		poi.SSEKMSKeyId != nil {
+		t.Fatalf("Wrong encryption : %+v", poi)
+	}
+	if aws.StringValue(poi.Tagging) != "class=a+b%26c&project=tokenized" {
+		t.Fatalf("Wrong tagging : %s", aws.StringValue(poi.Tagging))
+	}
+
+	options = NewOptions()
+	options.SetKMSEncryption("options-key")
+	poi = s3.PutObjectInput{}
+	store.applyWriteOptions(&poi, &options)
+	if aws.StringValue(poi.ServerSideEncryption) != s3.ServerSideEncryptionAwsKms ||
+		aws.StringValue(poi.SSEKMSKeyId) != "options-key" {
+		t.Fatalf("Wrong kms encryption : %+v", poi)
+	}
+
+	options = NewOptions()
+	options.Encryption = EncryptionNone
+	poi = s3.PutObjectInput{}
+	store.applyWriteOptions(&poi, &options)
+	if poi.ServerSideEncryption != nil || poi.SSEKMSKeyId != nil {
+		t.Fatalf("Encryption should not be set : %+v", poi)
+	}
+
+	var encryption Encryption
+	if err := encryption.UnmarshalText([]byte("aws:kms")); err != nil {
+		t.Fatalf("Failed to parse encryption : %s", err)
+	}
+	if encryption != EncryptionKMS {
+		t.Fatalf("Wrong encryption : got %s, want %s", encryption, EncryptionKMS)
+	}
+}
+
+func TestS3DownloadObjects(t *testing.T) {
+	store := NewS3StorageWithSession(Config{Bucket: "s3-bucket-name"}, nil)
+
+	keys := []string{"key0", "key1"}
+	objects, buffers := store.downloadObjects(keys)
+	if len(objects) != len(keys) || len(buffers) != len(keys) {
+		t.Fatalf("Wrong object count : got %d/%d, want %d", len(objects), len(buffers),
+			len(keys))
+	}
+
+	// The downloader writes parts out of order and reuses its part buffers.
+	for i, object := range objects {
+		if aws.StringValue(object.Object.Key) != keys[i] {
+			t.Fatalf("Wrong key : got %s, want %s", aws.StringValue(object.Object.Key), keys[i])
+		}
+
+		part := []byte(fmt.Sprintf("%s-part1", keys[i]))
+		if _, err := object.Writer.WriteAt(part, 10); err != nil {
+			t.Fatalf("Failed to write : %s", err)
+		}
+		copy(part, []byte(fmt.Sprintf("%s-part0", keys[i])))
+		if _, err := object.Writer.WriteAt(part[:10], 0); err != nil {
+			t.Fatalf("Failed to write : %s", err)
+		}
+		for j := range part {
+			part[j] = 0
+		}
+	}
+
+	for i, buf := range buffers {
+		want := fmt.Sprintf("%s-part0%s-part1", keys[i], keys[i])
+		if string(buf.Bytes()) != want {
+			t.Fatalf("Wrong object : got %q, want %q", buf.Bytes(), want)
+		}
+	}
+}
+
 func TestS3ListLimit(t *testing.T) {
 	t.Skip() // Must be run manually with a valid bucket name
 
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"strings"
+)
+
+// ScopedStorage is a view of a storage where all keys are under a prefix. Keys passed to it are
+// relative to the prefix and keys returned from List are relative to the prefix, so components
+// can be given an isolated part of a bucket without knowing where it is.
+type ScopedStorage struct {
+	store  Storage
+	prefix string // without trailing slash
+}
+
+// Scoped returns a view of the storage where all keys are namespaced under the prefix. Search,
+// Clear, and List are confined to keys under the prefix. Scoping a scoped storage combines the
+// prefixes.
+func Scoped(store Storage, prefix string) Storage {
+	prefix = strings.Trim(prefix, "/")
+	if len(prefix) == 0 {
+		return store
+	}
+
+	if scoped, ok := store.(*ScopedStorage); ok {
+		return &ScopedStorage{
+			store:  scoped.store,
+			prefix: scoped.prefix + "/" + prefix,
+		}
+	}
+
+	return &ScopedStorage{
+		store:  store,
+		prefix: prefix,
+	}
+}
+
+// Prefix returns the prefix that keys are namespaced under in the underlying storage.
+func (s *ScopedStorage) Prefix() string {
+	return s.prefix
+}
+
+func (s *ScopedStorage) Write(ctx context.Context, key string, body []byte,
+	options *Options) error {
+	return s.store.Write(ctx, s.key(key), body, options)
+}
+
+func (s *ScopedStorage) Read(ctx context.Context, key string) ([]byte, error) {
+	return s.store.Read(ctx, s.key(key))
+}
+
+func (s *ScopedStorage) Remove(ctx context.Context, key string) error {
+	return s.store.Remove(ctx, s.key(key))
+}
+
+func (s *ScopedStorage) Search(ctx context.Context, query map[string]string) ([][]byte, error) {
+	return s.store.Search(ctx, s.query(query))
+}
+
+func (s *ScopedStorage) Clear(ctx context.Context, query map[string]string) error {
+	return s.store.Clear(ctx, s.query(query))
+}
+
+func (s *ScopedStorage) List(ctx context.Context, path string) ([]string, error) {
+	keys, err := s.store.List(ctx, s.key(path))
+	if err != nil {
+		return nil, err
+	}
+
+	// Some storages match keys by prefix, so keys with the same starting text that are outside
+	// of the scope have to be removed.
+	scopePrefix := s.prefix + "/"
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, scopePrefix) {
+			continue
+		}
+
+		result = append(result, strings.TrimPrefix(key[len(scopePrefix):], "/"))
+	}
+
+	return result, nil
+}
+
+// key returns the key in the underlying storage.
+func (s *ScopedStorage) key(key string) string {
+	if len(key) == 0 {
+		return s.prefix
+	}
+
+	return s.prefix + "/" + key
+}
+
+// query returns a copy of the query with the path in the underlying storage. An empty path is
+// scoped with a trailing slash so that keys outside the scope with the same starting text are not
+// matched.
+func (s *ScopedStorage) query(query map[string]string) map[string]string {
+	result := make(map[string]string, len(query)+1)
+	for k, v := range query {
+		result[k] = v
+	}
+
+	if path := query["path"]; len(path) > 0 {
+		result["path"] = s.prefix + "/" + path
+	} else {
+		result["path"] = s.prefix + "/"
+	}
+
+	return result
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+)
+
+func TestScopedMock(t *testing.T) {
+	ctx := context.Background()
+	store := NewMockStorage()
+
+	testScoped(t, ctx, store)
+}
+
+func TestScopedFilesystem(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "scoped")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFilesystemStorage(Config{Root: dir, Bucket: "test"})
+
+	testScoped(t, ctx, store)
+}
+
+func testScoped(t *testing.T, ctx context.Context, store Storage) {
+	if err := store.Write(ctx, "unscoped", []byte("outside"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+
+	// Starts with the same text as the scope prefix.
+	if err := store.Write(ctx, "contractsx", []byte("outside"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+
+	scoped := Scoped(store, "contracts/")
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := scoped.Write(ctx, key, []byte(key), nil); err != nil {
+			t.Fatalf("Failed to write scoped %s : %s", key, err)
+		}
+	}
+
+	b, err := store.Read(ctx, "contracts/b")
+	if err != nil {
+		t.Fatalf("Failed to read unscoped key : %s", err)
+	}
+	if !bytes.Equal(b, []byte("b")) {
+		t.Fatalf("Wrong value : got %s, want %s", b, "b")
+	}
+
+	if _, err := scoped.Read(ctx, "unscoped"); err != ErrNotFound {
+		t.Fatalf("Wrong error reading outside scope : got %v, want %s", err, ErrNotFound)
+	}
+
+	keys, err := scoped.List(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to list : %s", err)
+	}
+	sort.Strings(keys)
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "c" {
+		t.Fatalf("Wrong keys : got %v, want %v", keys, []string{"a", "b", "c"})
+	}
+
+	values, err := scoped.Search(ctx, map[string]string{})
+	if err != nil {
+		t.Fatalf("Failed to search : %s", err)
+	}
+	if len(values) != 3 {
+		t.Fatalf("Wrong search count : got %d, want %d", len(values), 3)
+	}
+
+	if err := scoped.Remove(ctx, "a"); err != nil {
+		t.Fatalf("Failed to remove : %s", err)
+	}
+
+	if _, err := store.Read(ctx, "contracts/a"); err != ErrNotFound {
+		t.Fatalf("Wrong error reading removed key : got %v, want %s", err, ErrNotFound)
+	}
+
+	// Nested scopes combine prefixes.
+	nested := Scoped(scoped, "nested")
+	if nested.(*ScopedStorage).Prefix() != "contracts/nested" {
+		t.Fatalf("Wrong nested prefix : got %s, want %s", nested.(*ScopedStorage).Prefix(),
+			"contracts/nested")
+	}
+
+	if err := scoped.Clear(ctx, map[string]string{}); err != nil {
+		t.Fatalf("Failed to clear : %s", err)
+	}
+
+	keys, err = scoped.List(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to list : %s", err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("Keys not cleared : %v", keys)
+	}
+
+	for _, key := range []string{"unscoped", "contractsx"} {
+		if _, err := store.Read(ctx, key); err != nil {
+			t.Fatalf("Failed to read key outside scope %s : %s", key, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxSnapshotItemSize is the largest item that Import will read from a snapshot.
+	maxSnapshotItemSize = 1024 * 1024 * 1024
+)
+
+var (
+	// ErrInvalidSnapshot means a snapshot is not a valid archive or contains an invalid key.
+	ErrInvalidSnapshot = errors.New("Invalid snapshot")
+
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// Export writes a snapshot of all items with keys under the prefix to w. The snapshot is a zstd
+// compressed tar archive with one file per item named by the item's key, so it can be inspected
+// with standard tools. An empty prefix exports the entire store.
+//
+// It works with any backend, so it can be used for backups and to move state between filesystem
+// and S3 deployments with Import.
+func Export(ctx context.Context, store Storage, prefix string, w io.Writer) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return errors.Wrap(err, "create zstd")
+	}
+	tw := tar.NewWriter(zw)
+	now := time.Now()
+
+	if err := walkItems(ctx, store, prefix, make(map[string]bool),
+		func(key string, b []byte) error {
+			header := &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     key,
+				Mode:     0644,
+				Size:     int64(len(b)),
+				ModTime:  now,
+			}
+
+			if err := tw.WriteHeader(header); err != nil {
+				return errors.Wrapf(err, "write header %s", key)
+			}
+
+			if _, err := tw.Write(b); err != nil {
+				return errors.Wrapf(err, "write %s", key)
+			}
+
+			return nil
+		}); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "close tar")
+	}
+
+	if err := zw.Close(); err != nil {
+		return errors.Wrap(err, "close zstd")
+	}
+
+	return nil
+}
+
+// Import writes all items in a snapshot created by Export to the store, replacing items with the
+// same keys. It returns the number of items written. Gzip compressed snapshots written by earlier
+// versions of Export are also accepted.
+func Import(ctx context.Context, store Writer, r io.Reader) (int, error) {
+	zr, err := newSnapshotReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	count := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, errors.Wrap(ErrInvalidSnapshot, err.Error())
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			return count, errors.Wrapf(ErrInvalidSnapshot, "unsupported entry type %d : %s",
+				header.Typeflag, header.Name)
+		}
+
+		if !validSnapshotKey(header.Name) {
+			return count, errors.Wrapf(ErrInvalidSnapshot, "invalid key : %s", header.Name)
+		}
+
+		if header.Size > maxSnapshotItemSize {
+			return count, errors.Wrapf(ErrInvalidSnapshot, "item too large : %s %d", header.Name,
+				header.Size)
+		}
+
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return count, errors.Wrapf(err, "read %s", header.Name)
+		}
+
+		if err := store.Write(ctx, header.Name, b, nil); err != nil {
+			return count, errors.Wrapf(err, "write %s", header.Name)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// newSnapshotReader returns a reader that decompresses the snapshot based on its magic number.
+func newSnapshotReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	if bytes.HasPrefix(magic, zstdMagic) {
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidSnapshot, err.Error())
+		}
+		return zr.IOReadCloser(), nil
+	}
+
+	if bytes.HasPrefix(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidSnapshot, err.Error())
+		}
+		return zr, nil
+	}
+
+	return nil, errors.Wrap(ErrInvalidSnapshot, "unknown compression")
+}
+
+// walkItems calls the function with every item under the path in key order. Backends like S3
+// list all keys under a prefix, but the filesystem only lists one directory level, so keys that
+// can't be read as items are listed as directories.
+func walkItems(ctx context.Context, store Storage, path string, seen map[string]bool,
+	f func(key string, b []byte) error) error {
+
+	keys, err := store.List(ctx, path)
+	if err != nil {
+		return errors.Wrapf(err, "list %s", path)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if seen[key] || key == path {
+			continue
+		}
+		seen[key] = true
+
+		b, err := store.Read(ctx, key)
+		if err == nil {
+			if err := f(key, b); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if errors.Cause(err) == ErrNotFound {
+			continue // removed since it was listed
+		}
+
+		// Check if the key is a directory.
+		children, listErr := store.List(ctx, key)
+		if listErr == nil && len(children) == 0 {
+			continue // empty directory
+		}
+		if listErr != nil || !containsOtherKey(children, key) {
+			return errors.Wrapf(err, "read %s", key)
+		}
+
+		if err := walkItems(ctx, store, key, seen, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsOtherKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k != key {
+			return true
+		}
+	}
+	return false
+}
+
+// validSnapshotKey returns false for keys that could write outside of a store's root.
+func validSnapshotKey(key string) bool {
+	if len(key) == 0 || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+func TestSnapshotFilesystemToMock(t *testing.T) {
+	ctx := context.Background()
+
+	root, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	source := NewFilesystemStorage(NewConfig("bucket", root))
+
+	items := map[string][]byte{
+		"state/a":          []byte("item a"),
+		"state/b/c":        []byte("item c"),
+		"state/b/d/e":      []byte("item e"),
+		"state/empty_item": []byte{},
+	}
+	for key, value := range items {
+		if err := source.Write(ctx, key, value, nil); err != nil {
+			t.Fatalf("Failed to write %s : %s", key, err)
+		}
+	}
+
+	if err := source.Write(ctx, "other/f", []byte("item f"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Export(ctx, source, "state", buf); err != nil {
+		t.Fatalf("Failed to export : %s", err)
+	}
+
+	destination := NewMockStorage()
+	count, err := Import(ctx, destination, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to import : %s", err)
+	}
+
+	if count != len(items) {
+		t.Fatalf("Wrong import count : got %d, want %d", count, len(items))
+	}
+
+	for key, value := range items {
+		b, err := destination.Read(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to read %s : %s", key, err)
+		}
+
+		if !bytes.Equal(b, value) {
+			t.Fatalf("Wrong value for %s : got %q, want %q", key, b, value)
+		}
+	}
+
+	if _, err := destination.Read(ctx, "other/f"); errors.Cause(err) != ErrNotFound {
+		t.Fatalf("Item outside prefix should not be imported : %v", err)
+	}
+}
+
+func TestSnapshotMockToFilesystem(t *testing.T) {
+	ctx := context.Background()
+
+	source := NewMockStorage()
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("txs/%02d", i)
+		if err := source.Write(ctx, key, []byte(key), nil); err != nil {
+			t.Fatalf("Failed to write %s : %s", key, err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Export(ctx, source, "", buf); err != nil {
+		t.Fatalf("Failed to export : %s", err)
+	}
+
+	root, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	destination := NewFilesystemStorage(NewConfig("bucket", root))
+	count, err := Import(ctx, destination, buf)
+	if err != nil {
+		t.Fatalf("Failed to import : %s", err)
+	}
+
+	if count != 10 {
+		t.Fatalf("Wrong import count : got %d, want %d", count, 10)
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("txs/%02d", i)
+		b, err := destination.Read(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to read %s : %s", key, err)
+		}
+
+		if string(b) != key {
+			t.Fatalf("Wrong value for %s : got %q", key, b)
+		}
+	}
+}
+
+func TestSnapshotImportInvalidKey(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"../escape", "a/../../escape", "/absolute"} {
+		buf := &bytes.Buffer{}
+		zw, err := zstd.NewWriter(buf)
+		if err != nil {
+			t.Fatalf("Failed to create zstd writer : %s", err)
+		}
+		tw := tar.NewWriter(zw)
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     4,
+		}); err != nil {
+			t.Fatalf("Failed to write header : %s", err)
+		}
+		tw.Write([]byte("test"))
+		tw.Close()
+		zw.Close()
+
+		store := NewMockStorage()
+		if _, err := Import(ctx, store, buf); errors.Cause(err) != ErrInvalidSnapshot {
+			t.Fatalf("Wrong error for %s : got %v, want %v", name, err, ErrInvalidSnapshot)
+		}
+	}
+
+	_, err := Import(ctx, NewMockStorage(), bytes.NewReader([]byte("not a snapshot")))
+	if errors.Cause(err) != ErrInvalidSnapshot {
+		t.Fatalf("Wrong error for invalid data : got %v, want %v", err, ErrInvalidSnapshot)
+	}
+}
+
+func TestSnapshotImportGzip(t *testing.T) {
+	ctx := context.Background()
+
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "state/a",
+		Mode:     0644,
+		Size:     6,
+	}); err != nil {
+		t.Fatalf("Failed to write header : %s", err)
+	}
+	tw.Write([]byte("item a"))
+	tw.Close()
+	zw.Close()
+
+	store := NewMockStorage()
+	count, err := Import(ctx, store, buf)
+	if err != nil {
+		t.Fatalf("Failed to import : %s", err)
+	}
+
+	if count != 1 {
+		t.Fatalf("Wrong import count : got %d, want %d", count, 1)
+	}
+
+	b, err := store.Read(ctx, "state/a")
+	if err != nil {
+		t.Fatalf("Failed to read : %s", err)
+	}
+
+	if string(b) != "item a" {
+		t.Fatalf("Wrong value : got %q, want %q", b, "item a")
+	}
+}
+
+func TestSnapshotExportZstd(t *testing.T) {
+	ctx := context.Background()
+
+	store := NewMockStorage()
+	if err := store.Write(ctx, "state/a", []byte("item a"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Export(ctx, store, "state", buf); err != nil {
+		t.Fatalf("Failed to export : %s", err)
+	}
+
+	if !bytes.HasPrefix(buf.Bytes(), zstdMagic) {
+		t.Fatalf("Snapshot is not zstd compressed : %x", buf.Bytes()[:4])
+	}
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// checksumSuffix is appended to the filename of an object to get the filename of its checksum.
+	checksumSuffix = ".~sha256"
+
+	// tempMarker is in the filenames of temporary files written before being renamed to the object.
+	tempMarker = ".~tmp"
+
+	// corruptSuffix is appended to the filename of a corrupt object when Verify repairs it.
+	corruptSuffix = ".~corrupt"
+
+	// DefaultTempFileAge is how old a temporary file must be before Verify considers it orphaned.
+	DefaultTempFileAge = time.Hour
+)
+
+// Verifier interface is for checking the consistency of the items in the store.
+type Verifier interface {
+	Verify(context.Context, string, VerifyOptions) (*VerifyReport, error)
+}
+
+// VerifyOptions control what Verify does with the problems it finds.
+type VerifyOptions struct {
+	// Repair fixes the problems found:
+	// - Corrupt objects are renamed with a ".~corrupt" suffix so they aren't read, but are still
+	//   available for recovery. Both files are read again before an object is renamed to confirm
+	//   that the mismatch isn't from a write in progress.
+	// - Missing checksums are calculated from the current object data.
+	// - Orphaned checksums and temporary files are removed.
+	Repair bool
+
+	// TempFileAge is how old a temporary file must be before it is considered orphaned, so writes
+	// in progress aren't reported. Zero uses DefaultTempFileAge.
+	TempFileAge time.Duration
+}
+
+// VerifyReport is the result of Verify. Each list contains the keys of the files with the
+// problem.
+type VerifyReport struct {
+	// Checked is the number of objects scanned.
+	Checked int `json:"checked"`
+
+	// Corrupt objects have data that doesn't match their checksum.
+	Corrupt []string `json:"corrupt,omitempty"`
+
+	// MissingChecksums are objects that don't have a checksum. Only reported when the checksum
+	// option is enabled.
+	MissingChecksums []string `json:"missing_checksums,omitempty"`
+
+	// OrphanedChecksums are checksums without an object.
+	OrphanedChecksums []string `json:"orphaned_checksums,omitempty"`
+
+	// OrphanedTempFiles are temporary files left by writes that didn't complete.
+	OrphanedTempFiles []string `json:"orphaned_temp_files,omitempty"`
+
+	// Repaired is true when the problems were repaired.
+	Repaired bool `json:"repaired"`
+}
+
+// IsClean returns true if no problems were found.
+func (r VerifyReport) IsClean() bool {
+	return len(r.Corrupt) == 0 && len(r.MissingChecksums) == 0 &&
+		len(r.OrphanedChecksums) == 0 && len(r.OrphanedTempFiles) == 0
+}
+
+// Verify scans the objects under the prefix, validates their checksums, and finds temporary files
+// orphaned by incomplete writes. Checksums are only validated when the checksum option is
+// enabled. Otherwise checksum files are ignored since writes remove them. The problems found are
+// returned in the report and repaired if the options specify it.
+func (f *FilesystemStorage) Verify(ctx context.Context, prefix string,
+	options VerifyOptions) (*VerifyReport, error) {
+
+	if options.TempFileAge == 0 {
+		options.TempFileAge = DefaultTempFileAge
+	}
+
+	bucketDir := f.buildPath("")
+	report := &VerifyReport{Repaired: options.Repair}
+
+	err := filepath.Walk(f.buildPath(prefix), func(path string, info os.FileInfo,
+		err error) error {
+
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relative, err := filepath.Rel(bucketDir, path)
+		if err != nil {
+			return errors.Wrap(err, "relative path")
+		}
+		key := filepath.ToSlash(relative)
+		name := info.Name()
+
+		switch {
+		case strings.Contains(name, tempMarker):
+			if time.Since(info.ModTime()) < options.TempFileAge {
+				return nil // write might be in progress
+			}
+
+			report.OrphanedTempFiles = append(report.OrphanedTempFiles, key)
+			if options.Repair {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return errors.Wrapf(err, "remove temp file %s", key)
+				}
+			}
+
+		case strings.HasSuffix(name, checksumSuffix):
+			if !f.Config.Checksum {
+				return nil // checksums not used
+			}
+
+			objectPath := strings.TrimSuffix(path, checksumSuffix)
+			if _, err := os.Stat(objectPath); err == nil {
+				return nil // checked with the object
+			} else if !os.IsNotExist(err) {
+				return errors.Wrapf(err, "stat %s", key)
+			}
+
+			report.OrphanedChecksums = append(report.OrphanedChecksums, key)
+			if options.Repair {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return errors.Wrapf(err, "remove checksum %s", key)
+				}
+			}
+
+		case strings.HasSuffix(name, corruptSuffix):
+			return nil // already repaired
+
+		default:
+			report.Checked++
+			if err := f.verifyObject(path, key, options, report); err != nil {
+				return errors.Wrapf(err, "verify %s", key)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// verifyObject validates the checksum of the object at the path.
+func (f *FilesystemStorage) verifyObject(path, key string, options VerifyOptions,
+	report *VerifyReport) error {
+
+	if !f.Config.Checksum {
+		return nil // checksums not used
+	}
+
+	valid, err := checksumMatches(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if valid {
+		return nil
+	}
+
+	// Confirm the problem while writes to the key are blocked, since the files might have been
+	// read while a write was in progress.
+	lock := filesystemLock(path)
+	lock.Lock()
+	defer lock.Unlock()
+
+	valid, err = checksumMatches(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+			return nil // removed since it was listed
+		}
+
+		report.MissingChecksums = append(report.MissingChecksums, key)
+		if options.Repair {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return errors.Wrap(err, "read")
+			}
+
+			if err := writeFileAtomic(path+checksumSuffix, []byte(checksum(data)),
+				0); err != nil {
+				return errors.Wrap(err, "write checksum")
+			}
+		}
+		return nil
+	}
+	if valid {
+		return nil
+	}
+
+	report.Corrupt = append(report.Corrupt, key)
+	if options.Repair {
+		if err := os.Rename(path, path+corruptSuffix); err != nil {
+			return errors.Wrap(err, "rename corrupt")
+		}
+		if err := os.Remove(path + checksumSuffix); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "remove checksum")
+		}
+	}
+
+	return nil
+}
+
+// checksumMatches returns true if the data of the object at the path matches its checksum. An
+// error for which os.IsNotExist is true is returned when the object or its checksum don't exist.
+func checksumMatches(path string) (bool, error) {
+	expected, err := readChecksums(path)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	sum := checksum(data)
+	for _, s := range expected {
+		if s == sum {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// readChecksums returns the checksums in the checksum file of the object at the path. The first
+// is the checksum of the current data. A second is only present while the data is being replaced
+// and is the checksum of the previous data.
+func readChecksums(path string) ([]string, error) {
+	b, err := ioutil.ReadFile(path + checksumSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			result = append(result, line)
+		}
+	}
+
+	return result, nil
+}
+
+// checksum returns the hex encoded SHA-256 hash of the data.
+func checksum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// isInternalFile returns true if the filename is a checksum, temporary, or corrupt file, rather
+// than an object.
+func isInternalFile(name string) bool {
+	return strings.HasSuffix(name, checksumSuffix) || strings.Contains(name, tempMarker) ||
+		strings.HasSuffix(name, corruptSuffix)
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilesystemVerify(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFilesystemStorage(Config{Root: dir, Bucket: "test", Checksum: true})
+
+	for _, key := range []string{"objects/good", "objects/corrupt", "objects/sub/removed"} {
+		if err := store.Write(ctx, key, []byte("data "+key), nil); err != nil {
+			t.Fatalf("Failed to write %s : %s", key, err)
+		}
+	}
+
+	keys, err := store.List(ctx, "objects")
+	if err != nil {
+		t.Fatalf("Failed to list : %s", err)
+	}
+	want := []string{"objects/corrupt", "objects/good", "objects/sub"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("Wrong keys : got %v, want %v", keys, want)
+	}
+
+	report, err := store.Verify(ctx, "objects", VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to verify : %s", err)
+	}
+	if report.Checked != 3 || !report.IsClean() {
+		t.Fatalf("Wrong report : %+v", report)
+	}
+
+	// Corrupt an object, remove an object without its checksum, add an object without a
+	// checksum, and leave a temp file from an incomplete write.
+	objectsDir := filepath.Join(dir, "test", "objects")
+	if err := ioutil.WriteFile(filepath.Join(objectsDir, "corrupt"), []byte("bad"),
+		0644); err != nil {
+		t.Fatalf("Failed to corrupt file : %s", err)
+	}
+	if err := os.Remove(filepath.Join(objectsDir, "sub", "removed")); err != nil {
+		t.Fatalf("Failed to remove file : %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(objectsDir, "unchecked"), []byte("data"),
+		0644); err != nil {
+		t.Fatalf("Failed to write file : %s", err)
+	}
+	tempPath := filepath.Join(objectsDir, "good"+tempMarker+"123")
+	if err := ioutil.WriteFile(tempPath, []byte("partial"), 0644); err != nil {
+		t.Fatalf("Failed to write temp file : %s", err)
+	}
+
+	// Recent temp files might be writes in progress.
+	report, err = store.Verify(ctx, "objects", VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to verify : %s", err)
+	}
+	if len(report.OrphanedTempFiles) != 0 {
+		t.Fatalf("Recent temp file should not be orphaned : %v", report.OrphanedTempFiles)
+	}
+
+	old := time.Now().Add(-2 * DefaultTempFileAge)
+	if err := os.Chtimes(tempPath, old, old); err != nil {
+		t.Fatalf("Failed to set temp file time : %s", err)
+	}
+
+	report, err = store.Verify(ctx, "objects", VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Failed to verify : %s", err)
+	}
+
+	wantReport := &VerifyReport{
+		Checked:           3,
+		Corrupt:           []string{"objects/corrupt"},
+		MissingChecksums:  []string{"objects/unchecked"},
+		OrphanedChecksums: []string{"objects/sub/removed" + checksumSuffix},
+		OrphanedTempFiles: []string{"objects/good" + tempMarker + "123"},
+		Repaired:          true,
+	}
+	if !reflect.DeepEqual(report, wantReport) {
+		t.Fatalf("Wrong report : got %+v, want %+v", report, wantReport)
+	}
+
+	if _, err := store.Read(ctx, "objects/corrupt"); err != ErrNotFound {
+		t.Fatalf("Corrupt object should be removed : %v", err)
+	}
+
+	report, err = store.Verify(ctx, "objects", VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to verify : %s", err)
+	}
+	if report.Checked != 2 || !report.IsClean() {
+		t.Fatalf("Wrong report after repair : %+v", report)
+	}
+}
+
+func TestFilesystemVerifyStaleChecksums(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir : %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	checked := NewFilesystemStorage(Config{Root: dir, Bucket: "test", Checksum: true})
+	unchecked := NewFilesystemStorage(Config{Root: dir, Bucket: "test"})
+	objectsDir := filepath.Join(dir, "test", "objects")
+
+	// Overwriting without checksums removes the previous checksum.
+	if err := checked.Write(ctx, "objects/a", []byte("first"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+	if err := unchecked.Write(ctx, "objects/a", []byte("second"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(objectsDir, "a"+checksumSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("Checksum should be removed : %v", err)
+	}
+
+	// Checksum files are ignored when checksums are off.
+	if err := ioutil.WriteFile(filepath.Join(objectsDir, "a"+checksumSuffix), []byte("bad"),
+		0644); err != nil {
+		t.Fatalf("Failed to write checksum : %s", err)
+	}
+
+	report, err := unchecked.Verify(ctx, "objects", VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Failed to verify : %s", err)
+	}
+	if report.Checked != 1 || !report.IsClean() {
+		t.Fatalf("Wrong report with checksums off : %+v", report)
+	}
+
+	b, err := unchecked.Read(ctx, "objects/a")
+	if err != nil {
+		t.Fatalf("Object should not be removed : %s", err)
+	}
+	if string(b) != "second" {
+		t.Fatalf("Wrong data : got %q, want %q", b, "second")
+	}
+
+	// A write that stopped after the checksum was written keeps the previous checksum.
+	if err := checked.Write(ctx, "objects/b", []byte("previous"), nil); err != nil {
+		t.Fatalf("Failed to write : %s", err)
+	}
+	pending := checksum([]byte("next")) + "\n" + checksum([]byte("previous"))
+	if err := ioutil.WriteFile(filepath.Join(objectsDir, "b"+checksumSuffix), []byte(pending),
+		0644); err != nil {
+		t.Fatalf("Failed to write checksum : %s", err)
+	}
+
+	if err := os.Remove(filepath.Join(objectsDir, "a"+checksumSuffix)); err != nil {
+		t.Fatalf("Failed to remove checksum : %s", err)
+	}
+
+	report, err = checked.Verify(ctx, "objects", VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to verify : %s", err)
+	}
+	wantReport := &VerifyReport{
+		Checked:          2,
+		MissingChecksums: []string{"objects/a"},
+	}
+	if !reflect.DeepEqual(report, wantReport) {
+		t.Fatalf("Wrong report : got %+v, want %+v", report, wantReport)
+	}
+
+	// Concurrent writes to one key leave a matching checksum.
+	var wait sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			if err := checked.Write(ctx, "objects/b", []byte{byte(i)}, nil); err != nil {
+				t.Errorf("Failed to write : %s", err)
+			}
+		}(i)
+	}
+	wait.Wait()
+
+	report, err = checked.Verify(ctx, "objects/b", VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Failed to verify : %s", err)
+	}
+	if report.Checked != 1 || !report.IsClean() {
+		t.Fatalf("Wrong report after concurrent writes : %+v", report)
+	}
+}
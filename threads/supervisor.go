@@ -0,0 +1,354 @@
+package threads
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// RestartNever never restarts the function.
+	RestartNever = RestartPolicy(0)
+
+	// RestartOnError restarts the function when it returns an error or panics.
+	RestartOnError = RestartPolicy(1)
+
+	// RestartAlways restarts the function whenever it returns, until the supervisor is stopped.
+	RestartAlways = RestartPolicy(2)
+)
+
+var (
+	// ErrPanic is returned by a supervised function that panicked.
+	ErrPanic = errors.New("Panic")
+)
+
+// RestartPolicy specifies when a supervised function is restarted.
+type RestartPolicy uint8
+
+// RestartConfig specifies how a supervised function is restarted.
+type RestartConfig struct {
+	Policy RestartPolicy
+
+	// MaxRestarts is the maximum number of restarts. Zero means no limit.
+	MaxRestarts int
+
+	// Delay is the delay before the first restart.
+	Delay time.Duration
+
+	// MaxDelay enables exponential backoff when it is more than Delay. The delay is doubled after
+	// each consecutive restart until it reaches MaxDelay. It is reset when the function runs for
+	// longer than MaxDelay.
+	MaxDelay time.Duration
+}
+
+// Supervisor runs a group of functions in go routines, restarts them according to their restart
+// policies, captures panics, and stops them together.
+type Supervisor struct {
+	name      string
+	tasks     []*supervisedTask
+	shutdowns []*shutdownFunction
+
+	interrupt chan interface{}
+	failed    chan interface{}
+	wait      sync.WaitGroup
+
+	isStarted  bool
+	wasStopped bool
+	wasFailed  bool
+	shutdown   sync.WaitGroup // complete when the shutdown functions have been called
+
+	sync.Mutex
+}
+
+// ShutdownFunction is called when a supervisor stops.
+type ShutdownFunction func(ctx context.Context) error
+
+type shutdownFunction struct {
+	name     string
+	function ShutdownFunction
+	err      error
+}
+
+type supervisedTask struct {
+	name     string
+	function ThreadInterruptFunction
+	config   RestartConfig
+
+	restarts int
+	err      error
+
+	sync.Mutex
+}
+
+// NewSupervisor creates an empty supervisor.
+func NewSupervisor(name string) *Supervisor {
+	return &Supervisor{
+		name:      name,
+		interrupt: make(chan interface{}),
+		failed:    make(chan interface{}),
+	}
+}
+
+// Add adds a function to be run by the supervisor. The function should return when the interrupt
+// is closed. Functions can't be added after the supervisor is started.
+func (s *Supervisor) Add(name string, function ThreadInterruptFunction, config RestartConfig) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.isStarted {
+		panic(fmt.Sprintf("Supervisor %s : add %s after start", s.name, name))
+	}
+
+	s.tasks = append(s.tasks, &supervisedTask{
+		name:     name,
+		function: function,
+		config:   config,
+	})
+}
+
+// OnShutdown adds a function that is called when the supervisor is stopped, after all of the
+// supervised functions have returned. Shutdown functions are called in the reverse order they are
+// added, so the first one added is called last. For example adding logger.Close first ensures the
+// entries written while everything else shuts down are written before the log is closed.
+func (s *Supervisor) OnShutdown(name string, function ShutdownFunction) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.shutdowns = append(s.shutdowns, &shutdownFunction{
+		name:     name,
+		function: function,
+	})
+}
+
+// Start starts all of the functions.
+func (s *Supervisor) Start(ctx context.Context) {
+	s.Lock()
+	s.isStarted = true
+	tasks := s.tasks
+	s.Unlock()
+
+	for _, task := range tasks {
+		s.wait.Add(1)
+		go func(task *supervisedTask) {
+			s.supervise(ctx, task)
+			s.wait.Done()
+		}(task)
+	}
+}
+
+// Stop interrupts all of the functions, waits for them to return, and then calls the shutdown
+// functions.
+func (s *Supervisor) Stop(ctx context.Context) {
+	s.Lock()
+	first := !s.wasStopped
+	if first {
+		close(s.interrupt)
+		s.wasStopped = true
+		s.shutdown.Add(1)
+	}
+	s.Unlock()
+
+	s.wait.Wait()
+
+	if first {
+		s.callShutdowns(ctx)
+		s.shutdown.Done()
+	}
+
+	s.shutdown.Wait()
+}
+
+// GetFailedChannel returns a channel that is closed when a function fails and will not be
+// restarted, so that the service can shut down.
+func (s *Supervisor) GetFailedChannel() <-chan interface{} {
+	return s.failed
+}
+
+// Run starts all of the functions and waits until the interrupt is closed or a function fails and
+// will not be restarted. It then stops all of the functions and returns their combined errors.
+func (s *Supervisor) Run(ctx context.Context, interrupt <-chan interface{}) error {
+	s.Start(ctx)
+
+	select {
+	case <-interrupt:
+	case <-s.failed:
+	}
+
+	s.Stop(ctx)
+	return s.Error()
+}
+
+// Error returns the combined errors of the functions and shutdown functions that failed.
+// Interrupted errors are ignored.
+func (s *Supervisor) Error() error {
+	s.Lock()
+	tasks := s.tasks
+	shutdowns := s.shutdowns
+	s.Unlock()
+
+	var errs []error
+	for _, task := range tasks {
+		task.Lock()
+		err := task.err
+		task.Unlock()
+
+		if err != nil && errors.Cause(err) != Interrupted {
+			errs = append(errs, errors.Wrap(err, task.name))
+		}
+	}
+
+	s.Lock()
+	for _, shutdown := range shutdowns {
+		if shutdown.err != nil {
+			errs = append(errs, errors.Wrap(shutdown.err, shutdown.name))
+		}
+	}
+	s.Unlock()
+
+	return CombineErrors(errs...)
+}
+
+// Restarts returns the number of times the named function has been restarted.
+func (s *Supervisor) Restarts(name string) int {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, task := range s.tasks {
+		if task.name == name {
+			task.Lock()
+			defer task.Unlock()
+			return task.restarts
+		}
+	}
+
+	return 0
+}
+
+func (s *Supervisor) supervise(ctx context.Context, task *supervisedTask) {
+	delay := task.config.Delay
+
+	for {
+		start := time.Now()
+		logger.Verbose(ctx, "Starting: %s", task.name)
+		err := runProtected(ctx, task.name, task.function, s.interrupt)
+
+		task.Lock()
+		task.err = err
+		task.Unlock()
+
+		select {
+		case <-s.interrupt:
+			if err != nil && errors.Cause(err) != Interrupted {
+				logger.Warn(ctx, "Finished: %s : %s", task.name, err)
+			} else {
+				logger.Verbose(ctx, "Finished: %s", task.name)
+			}
+			return
+		default:
+		}
+
+		if !task.shouldRestart(err) {
+			if err != nil && errors.Cause(err) != Interrupted {
+				logger.Error(ctx, "Failed: %s : %s", task.name, err)
+				s.fail()
+			} else {
+				logger.Verbose(ctx, "Finished: %s", task.name)
+			}
+			return
+		}
+
+		// Reset the backoff when the function ran for a while.
+		if task.config.MaxDelay > task.config.Delay && time.Since(start) > task.config.MaxDelay {
+			delay = task.config.Delay
+		}
+
+		logger.Warn(ctx, "Restarting: %s in %s : %v", task.name, delay, err)
+
+		select {
+		case <-s.interrupt:
+			return
+		case <-time.After(delay):
+		}
+
+		task.Lock()
+		task.restarts++
+		task.Unlock()
+
+		if task.config.MaxDelay > task.config.Delay {
+			delay *= 2
+			if delay == 0 {
+				delay = time.Millisecond
+			}
+			if delay > task.config.MaxDelay {
+				delay = task.config.MaxDelay
+			}
+		}
+	}
+}
+
+// callShutdowns calls the shutdown functions in reverse order. Errors don't prevent the remaining
+// functions from being called.
+func (s *Supervisor) callShutdowns(ctx context.Context) {
+	s.Lock()
+	shutdowns := make([]*shutdownFunction, len(s.shutdowns))
+	copy(shutdowns, s.shutdowns)
+	s.Unlock()
+
+	for i := len(shutdowns) - 1; i >= 0; i-- {
+		shutdown := shutdowns[i]
+		logger.Verbose(ctx, "Shutting down: %s", shutdown.name)
+		err := shutdown.function(ctx)
+
+		s.Lock()
+		shutdown.err = err
+		s.Unlock()
+	}
+}
+
+func (s *Supervisor) fail() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.wasFailed {
+		close(s.failed)
+		s.wasFailed = true
+	}
+}
+
+func (t *supervisedTask) shouldRestart(err error) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.config.MaxRestarts > 0 && t.restarts >= t.config.MaxRestarts {
+		return false
+	}
+
+	switch t.config.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnError:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// runProtected runs the function and converts a panic into an error.
+func runProtected(ctx context.Context, name string, function ThreadInterruptFunction,
+	interrupt <-chan interface{}) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(ctx, "Panic: %s : %v\n%s", name, r, debug.Stack())
+			err = errors.Wrapf(ErrPanic, "%v", r)
+		}
+	}()
+
+	return function(ctx, interrupt)
+}
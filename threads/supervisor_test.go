@@ -0,0 +1,162 @@
+package threads
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestSupervisorRestart(t *testing.T) {
+	ctx := context.Background()
+
+	var errorRuns, panicRuns, alwaysRuns int32
+
+	supervisor := NewSupervisor("test")
+
+	supervisor.Add("error", func(ctx context.Context, interrupt <-chan interface{}) error {
+		if atomic.AddInt32(&errorRuns, 1) < 3 {
+			return errors.New("Test error")
+		}
+		<-interrupt
+		return Interrupted
+	}, RestartConfig{
+		Policy:   RestartOnError,
+		Delay:    time.Millisecond,
+		MaxDelay: 10 * time.Millisecond,
+	})
+
+	supervisor.Add("panic", func(ctx context.Context, interrupt <-chan interface{}) error {
+		if atomic.AddInt32(&panicRuns, 1) == 1 {
+			panic("test panic")
+		}
+		<-interrupt
+		return nil
+	}, RestartConfig{Policy: RestartOnError})
+
+	supervisor.Add("always", func(ctx context.Context, interrupt <-chan interface{}) error {
+		atomic.AddInt32(&alwaysRuns, 1)
+		return nil
+	}, RestartConfig{Policy: RestartAlways, MaxRestarts: 4})
+
+	interrupt := make(chan interface{})
+	complete := make(chan error, 1)
+	go func() {
+		complete <- supervisor.Run(ctx, interrupt)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	close(interrupt)
+
+	select {
+	case err := <-complete:
+		if err != nil {
+			t.Errorf("Supervisor failed : %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Supervisor did not stop")
+	}
+
+	if errorRuns != 3 || supervisor.Restarts("error") != 2 {
+		t.Errorf("Wrong error runs : got %d (%d restarts), want 3", errorRuns,
+			supervisor.Restarts("error"))
+	}
+
+	if panicRuns != 2 {
+		t.Errorf("Wrong panic runs : got %d, want 2", panicRuns)
+	}
+
+	if alwaysRuns != 5 {
+		t.Errorf("Wrong always runs : got %d, want 5", alwaysRuns)
+	}
+}
+
+func TestSupervisorFailure(t *testing.T) {
+	ctx := context.Background()
+
+	supervisor := NewSupervisor("test")
+
+	supervisor.Add("fail", func(ctx context.Context, interrupt <-chan interface{}) error {
+		panic("test panic")
+	}, RestartConfig{Policy: RestartNever})
+
+	stopped := false
+	supervisor.Add("wait", func(ctx context.Context, interrupt <-chan interface{}) error {
+		<-interrupt
+		stopped = true
+		return Interrupted
+	}, RestartConfig{Policy: RestartAlways})
+
+	complete := make(chan error, 1)
+	go func() {
+		complete <- supervisor.Run(ctx, nil)
+	}()
+
+	select {
+	case err := <-complete:
+		if errors.Cause(err) != ErrPanic {
+			t.Errorf("Wrong error : got %v, want %v", err, ErrPanic)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Supervisor did not stop after failure")
+	}
+
+	if !stopped {
+		t.Errorf("Other function not stopped")
+	}
+}
+
+func TestSupervisorShutdown(t *testing.T) {
+	ctx := context.Background()
+
+	supervisor := NewSupervisor("test")
+
+	var order []string
+	var taskReturned int32
+
+	supervisor.OnShutdown("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	supervisor.OnShutdown("second", func(ctx context.Context) error {
+		if atomic.LoadInt32(&taskReturned) == 0 {
+			t.Errorf("Shutdown called before function returned")
+		}
+		order = append(order, "second")
+		return errors.New("Test error")
+	})
+
+	supervisor.Add("wait", func(ctx context.Context, interrupt <-chan interface{}) error {
+		<-interrupt
+		time.Sleep(10 * time.Millisecond)
+		atomic.StoreInt32(&taskReturned, 1)
+		return Interrupted
+	}, RestartConfig{Policy: RestartNever})
+
+	interrupt := make(chan interface{})
+	complete := make(chan error, 1)
+	go func() {
+		complete <- supervisor.Run(ctx, interrupt)
+	}()
+
+	close(interrupt)
+
+	select {
+	case err := <-complete:
+		if err == nil || !strings.Contains(err.Error(), "second") {
+			t.Errorf("Wrong error : got %v, want shutdown error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Supervisor did not stop")
+	}
+
+	// Stopping again doesn't call the shutdown functions again.
+	supervisor.Stop(ctx)
+
+	if len(order) != 2 || order[0] != "second" || order[1] != "first" {
+		t.Errorf("Wrong shutdown order : got %v, want [second first]", order)
+	}
+}
@@ -0,0 +1,202 @@
+package threads
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrQueueFull is returned by TrySubmit when the worker pool's queue is full.
+	ErrQueueFull = errors.New("Queue Full")
+
+	// ErrPoolStopped is returned when a task is submitted to a worker pool that was stopped.
+	ErrPoolStopped = errors.New("Pool Stopped")
+)
+
+// WorkerPoolConfig configures a worker pool.
+type WorkerPoolConfig struct {
+	// Workers is the number of tasks that run concurrently.
+	Workers int
+
+	// QueueSize is the number of tasks that can wait to run before Submit blocks.
+	QueueSize int
+
+	// TaskTimeout is the maximum time for each task. The task's context is cancelled when it
+	// expires. Zero means no timeout.
+	TaskTimeout time.Duration
+}
+
+// WorkerPoolStats contains metrics about the tasks run by a worker pool.
+type WorkerPoolStats struct {
+	Submitted uint64
+	Completed uint64 // Tasks that returned, including failed tasks
+	Failed    uint64 // Tasks that returned an error or panicked
+	Rejected  uint64 // Tasks rejected by TrySubmit because the queue was full
+
+	Queued int // Tasks waiting to run
+	Active int // Tasks running
+
+	TotalDuration time.Duration // Total run time of completed tasks
+	MaxDuration   time.Duration // Longest run time of a completed task
+}
+
+// WorkerPool runs submitted tasks with a bounded number of workers. Tasks wait in a bounded queue
+// and Submit blocks when the queue is full, to provide back pressure.
+type WorkerPool struct {
+	name   string
+	config WorkerPoolConfig
+
+	queue chan TaskFunction
+	wait  sync.WaitGroup
+
+	// stopLock is held for read while submitting and for write while stopping so the queue isn't
+	// closed during a send.
+	isStopped bool
+	stopLock  sync.RWMutex
+
+	stats WorkerPoolStats
+	sync.Mutex
+}
+
+// NewWorkerPool creates a worker pool. It must be started before tasks run.
+func NewWorkerPool(name string, config WorkerPoolConfig) *WorkerPool {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.QueueSize < 0 {
+		config.QueueSize = 0
+	}
+
+	return &WorkerPool{
+		name:   name,
+		config: config,
+		queue:  make(chan TaskFunction, config.QueueSize),
+	}
+}
+
+// Start starts the workers.
+func (p *WorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.config.Workers; i++ {
+		p.wait.Add(1)
+		go func() {
+			p.work(ctx)
+			p.wait.Done()
+		}()
+	}
+}
+
+// Stop stops accepting tasks and waits for the queued and running tasks to complete.
+func (p *WorkerPool) Stop(ctx context.Context) {
+	p.stopLock.Lock()
+	if !p.isStopped {
+		p.isStopped = true
+		close(p.queue)
+	}
+	p.stopLock.Unlock()
+
+	p.wait.Wait()
+}
+
+// Submit adds a task to the queue. It blocks while the queue is full until there is space or the
+// context is done.
+func (p *WorkerPool) Submit(ctx context.Context, task TaskFunction) error {
+	p.stopLock.RLock()
+	defer p.stopLock.RUnlock()
+
+	if p.isStopped {
+		return ErrPoolStopped
+	}
+
+	select {
+	case p.queue <- task:
+		p.Lock()
+		p.stats.Submitted++
+		p.Unlock()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySubmit adds a task to the queue if there is space and returns ErrQueueFull if there isn't.
+func (p *WorkerPool) TrySubmit(task TaskFunction) error {
+	p.stopLock.RLock()
+	defer p.stopLock.RUnlock()
+
+	if p.isStopped {
+		return ErrPoolStopped
+	}
+
+	select {
+	case p.queue <- task:
+		p.Lock()
+		p.stats.Submitted++
+		p.Unlock()
+		return nil
+	default:
+		p.Lock()
+		p.stats.Rejected++
+		p.Unlock()
+		return ErrQueueFull
+	}
+}
+
+// Stats returns the current metrics of the pool.
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	p.Lock()
+	defer p.Unlock()
+
+	result := p.stats
+	result.Queued = len(p.queue)
+	return result
+}
+
+func (p *WorkerPool) work(ctx context.Context) {
+	for task := range p.queue {
+		p.Lock()
+		p.stats.Active++
+		p.Unlock()
+
+		start := time.Now()
+		err := p.run(ctx, task)
+		duration := time.Since(start)
+
+		if err != nil {
+			logger.Warn(ctx, "%s task failed : %s", p.name, err)
+		}
+
+		p.Lock()
+		p.stats.Active--
+		p.stats.Completed++
+		if err != nil {
+			p.stats.Failed++
+		}
+		p.stats.TotalDuration += duration
+		if duration > p.stats.MaxDuration {
+			p.stats.MaxDuration = duration
+		}
+		p.Unlock()
+	}
+}
+
+// run runs the task with its own context.
+func (p *WorkerPool) run(ctx context.Context, task TaskFunction) error {
+	var taskCtx context.Context
+	var cancel context.CancelFunc
+	if p.config.TaskTimeout > 0 {
+		taskCtx, cancel = context.WithTimeout(ctx, p.config.TaskTimeout)
+	} else {
+		taskCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	return runProtected(taskCtx, p.name, func(ctx context.Context,
+		interrupt <-chan interface{}) error {
+		return task(ctx)
+	}, nil)
+}
@@ -0,0 +1,120 @@
+package threads
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestWorkerPool(t *testing.T) {
+	ctx := context.Background()
+
+	pool := NewWorkerPool("test", WorkerPoolConfig{
+		Workers:     3,
+		QueueSize:   2,
+		TaskTimeout: 50 * time.Millisecond,
+	})
+	pool.Start(ctx)
+
+	var active, maxActive, count int32
+	for i := 0; i < 20; i++ {
+		i := i
+		if err := pool.Submit(ctx, func(ctx context.Context) error {
+			current := atomic.AddInt32(&active, 1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if current <= max || atomic.CompareAndSwapInt32(&maxActive, max, current) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			atomic.AddInt32(&count, 1)
+
+			switch i {
+			case 5:
+				return errors.New("Test error")
+			case 10:
+				<-ctx.Done() // wait for timeout
+				return ctx.Err()
+			case 15:
+				panic("test panic")
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("Failed to submit task : %s", err)
+		}
+	}
+
+	pool.Stop(ctx)
+
+	if count != 20 {
+		t.Errorf("Wrong task count : got %d, want %d", count, 20)
+	}
+
+	if maxActive > 3 {
+		t.Errorf("Too many concurrent tasks : got %d, want <= %d", maxActive, 3)
+	}
+
+	stats := pool.Stats()
+	if stats.Submitted != 20 || stats.Completed != 20 || stats.Failed != 3 {
+		t.Errorf("Wrong stats : %+v", stats)
+	}
+
+	if stats.MaxDuration < 50*time.Millisecond {
+		t.Errorf("Wrong max duration : got %s, want >= %s", stats.MaxDuration,
+			50*time.Millisecond)
+	}
+
+	noop := func(ctx context.Context) error { return nil }
+	if err := pool.Submit(ctx, noop); errors.Cause(err) != ErrPoolStopped {
+		t.Errorf("Wrong error : got %v, want %v", err, ErrPoolStopped)
+	}
+}
+
+func TestWorkerPoolBackPressure(t *testing.T) {
+	ctx := context.Background()
+
+	pool := NewWorkerPool("test", WorkerPoolConfig{
+		Workers:   1,
+		QueueSize: 1,
+	})
+	pool.Start(ctx)
+
+	release := make(chan interface{})
+	blocked := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+
+	if err := pool.Submit(ctx, blocked); err != nil { // runs
+		t.Fatalf("Failed to submit task : %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if err := pool.TrySubmit(blocked); err != nil { // queued
+		t.Fatalf("Failed to submit task : %s", err)
+	}
+
+	if err := pool.TrySubmit(blocked); errors.Cause(err) != ErrQueueFull {
+		t.Fatalf("Wrong error : got %v, want %v", err, ErrQueueFull)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(timeoutCtx, blocked); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("Wrong error : got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	pool.Stop(ctx)
+
+	stats := pool.Stats()
+	if stats.Completed != 2 || stats.Rejected != 1 {
+		t.Errorf("Wrong stats : %+v", stats)
+	}
+}
@@ -0,0 +1,112 @@
+package txbuilder
+
+import (
+	"fmt"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+// ChangeAddresser provides change locking scripts to the tx builder so that wallets, like HD
+//   wallets, can pay change to a new address in every tx instead of a fixed change address.
+type ChangeAddresser interface {
+	// NextChangeLockingScript returns the locking script and key ID for the next change output. It
+	//   should return the same values until MarkChangeUsed is called.
+	NextChangeLockingScript() (bitcoin.Script, string, error)
+
+	// MarkChangeUsed is called when a change output paying to the locking script is added to a tx.
+	MarkChangeUsed(lockingScript bitcoin.Script) error
+}
+
+// SetChangeAddresser sets the change addresser used to get a change locking script when a change
+//   output is needed and one hasn't been set with SetChangeAddress.
+func (tx *TxBuilder) SetChangeAddresser(changeAddresser ChangeAddresser) {
+	tx.ChangeAddresser = changeAddresser
+}
+
+// loadChangeScript sets the change script from the change addresser if it isn't already set.
+func (tx *TxBuilder) loadChangeScript() error {
+	if len(tx.ChangeScript) > 0 || tx.ChangeAddresser == nil {
+		return nil
+	}
+
+	lockingScript, keyID, err := tx.ChangeAddresser.NextChangeLockingScript()
+	if err != nil {
+		return errors.Wrap(err, "next change locking script")
+	}
+
+	tx.ChangeScript = lockingScript
+	tx.ChangeKeyID = keyID
+	return nil
+}
+
+// addChangeOutput adds an output paying the value to the change script.
+func (tx *TxBuilder) addChangeOutput(value uint64) error {
+	if len(tx.ChangeScript) == 0 {
+		return errors.Wrap(ErrChangeAddressNeeded, fmt.Sprintf("Remaining: %d", value))
+	}
+
+	if err := tx.AddOutput(tx.ChangeScript, value, true, false); err != nil {
+		return err
+	}
+	tx.Outputs[len(tx.Outputs)-1].KeyID = tx.ChangeKeyID
+
+	if tx.ChangeAddresser != nil {
+		if err := tx.ChangeAddresser.MarkChangeUsed(tx.ChangeScript); err != nil {
+			return errors.Wrap(err, "mark change used")
+		}
+	}
+
+	return nil
+}
+
+// ChangeAddressList is a ChangeAddresser that provides change addresses from a list, skipping
+//   addresses that have been used.
+type ChangeAddressList struct {
+	addresses []AddressKeyID
+	used      []bool
+}
+
+// NewChangeAddressList creates a change addresser that uses the addresses in order.
+func NewChangeAddressList(addresses []AddressKeyID) *ChangeAddressList {
+	return &ChangeAddressList{
+		addresses: addresses,
+		used:      make([]bool, len(addresses)),
+	}
+}
+
+// NextChangeLockingScript returns the locking script and key ID of the first unused address.
+func (l *ChangeAddressList) NextChangeLockingScript() (bitcoin.Script, string, error) {
+	for i, address := range l.addresses {
+		if l.used[i] {
+			continue
+		}
+
+		lockingScript, err := address.Address.LockingScript()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "locking script")
+		}
+
+		return lockingScript, address.KeyID, nil
+	}
+
+	return nil, "", errors.Wrap(ErrChangeAddressNeeded, "all change addresses used")
+}
+
+// MarkChangeUsed marks the address with the locking script as used.
+func (l *ChangeAddressList) MarkChangeUsed(lockingScript bitcoin.Script) error {
+	for i, address := range l.addresses {
+		addressScript, err := address.Address.LockingScript()
+		if err != nil {
+			return errors.Wrap(err, "locking script")
+		}
+
+		if addressScript.Equal(lockingScript) {
+			l.used[i] = true
+			return nil
+		}
+	}
+
+	return errors.New("Change address not found")
+}
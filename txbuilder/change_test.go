@@ -0,0 +1,98 @@
+package txbuilder
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestChangeAddresser(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	address, err := bitcoin.RawAddressFromLockingScript(lockingScript)
+	if err != nil {
+		t.Fatalf("Failed to create address : %s", err)
+	}
+
+	var changeAddresses []AddressKeyID
+	for i := 0; i < 2; i++ {
+		changeKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+		if err != nil {
+			t.Fatalf("Failed to create private key : %s", err)
+		}
+		changeAddress, err := bitcoin.NewRawAddressPKH(
+			bitcoin.Hash160(changeKey.PublicKey().Bytes()))
+		if err != nil {
+			t.Fatalf("Failed to create address : %s", err)
+		}
+		changeAddresses = append(changeAddresses, AddressKeyID{
+			Address: changeAddress,
+			KeyID:   fmt.Sprintf("change %d", i),
+		})
+	}
+	changeAddresser := NewChangeAddressList(changeAddresses)
+
+	var changeScripts []bitcoin.Script
+	for i := 0; i < 2; i++ {
+		tx := NewTxBuilder(0.5, 1.0)
+		tx.SetChangeAddresser(changeAddresser)
+
+		if err := tx.AddPaymentOutput(address, 5000, false); err != nil {
+			t.Fatalf("Failed to add output : %s", err)
+		}
+
+		utxo := bitcoin.UTXO{
+			Index:         uint32(i),
+			Value:         10000,
+			LockingScript: lockingScript,
+		}
+		if err := tx.AddFunding([]bitcoin.UTXO{utxo}); err != nil {
+			t.Fatalf("Failed to add funding : %s", err)
+		}
+
+		if len(tx.MsgTx.TxOut) != 2 {
+			t.Fatalf("Wrong output count : got %d, want 2", len(tx.MsgTx.TxOut))
+		}
+		if !tx.Outputs[1].IsRemainder {
+			t.Fatalf("Change output should be remainder")
+		}
+		if tx.Outputs[1].KeyID != changeAddresses[i].KeyID {
+			t.Fatalf("Wrong change key id : got %s, want %s", tx.Outputs[1].KeyID,
+				changeAddresses[i].KeyID)
+		}
+
+		if err := tx.Sign([]bitcoin.Key{key}); err != nil {
+			t.Fatalf("Failed to sign tx : %s", err)
+		}
+
+		changeScripts = append(changeScripts, tx.MsgTx.TxOut[1].LockingScript)
+	}
+
+	if changeScripts[0].Equal(changeScripts[1]) {
+		t.Fatalf("Change scripts should be different")
+	}
+
+	// All change addresses are used.
+	tx := NewTxBuilder(0.5, 1.0)
+	tx.SetChangeAddresser(changeAddresser)
+	if err := tx.AddPaymentOutput(address, 5000, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+	utxo := bitcoin.UTXO{
+		Index:         2,
+		Value:         10000,
+		LockingScript: lockingScript,
+	}
+	if err := tx.AddFunding([]bitcoin.UTXO{utxo}); errors.Cause(err) != ErrChangeAddressNeeded {
+		t.Fatalf("Wrong error when change addresses are used : %v", err)
+	}
+}
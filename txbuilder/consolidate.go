@@ -0,0 +1,131 @@
+package txbuilder
+
+import (
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// Consolidate returns unsigned txs that spend the UTXOs to the address, each with one output that
+//   receives the input value minus the fee. The number of txs is the minimum needed to keep each tx
+//   under maxTxSize and the inputs are spread evenly between them. A maxTxSize of zero puts all
+//   inputs in one tx.
+// UTXOs that cost more in fees to spend than they are worth, or that have locking scripts that the
+//   tx builder can't sign, are not included. Input key IDs are retained so the txs can be signed.
+func Consolidate(utxos []bitcoin.UTXO, address bitcoin.RawAddress, maxTxSize int,
+	feeRate, dustFeeRate float32) ([]*TxBuilder, error) {
+
+	lockingScript, err := address.LockingScript()
+	if err != nil {
+		return nil, errors.Wrap(err, "address locking script")
+	}
+
+	// Size of everything except inputs, assuming the maximum input count var int size.
+	overhead := BaseTxSize + wire.VarIntSerializeSize(uint64(len(utxos))) +
+		wire.VarIntSerializeSize(1) + OutputSize(lockingScript)
+
+	var spendable []bitcoin.UTXO
+	var sizes []int
+	totalSize := 0
+	for _, utxo := range utxos {
+		size, err := InputSize(utxo.LockingScript)
+		if err != nil {
+			continue // can't sign
+		}
+
+		if uint64(float32(size)*feeRate) >= utxo.Value {
+			continue // not worth spending
+		}
+
+		if maxTxSize > 0 && overhead+size > maxTxSize {
+			return nil, errors.Wrapf(ErrInsufficientValue, "input %d bytes, max tx %d bytes", size,
+				maxTxSize)
+		}
+
+		spendable = append(spendable, utxo)
+		sizes = append(sizes, size)
+		totalSize += size
+	}
+
+	if len(spendable) == 0 {
+		return nil, errors.Wrap(ErrInsufficientValue, "no spendable utxos")
+	}
+
+	// Spread the inputs evenly so the last tx isn't left with only a few small inputs.
+	targetSize := totalSize
+	if maxTxSize > 0 {
+		available := maxTxSize - overhead
+		txCount := (totalSize + available - 1) / available
+		targetSize = (totalSize + txCount - 1) / txCount
+		if targetSize > available {
+			targetSize = available
+		}
+	}
+
+	var result []*TxBuilder
+	var batch []bitcoin.UTXO
+	batchSize := 0
+	for i, utxo := range spendable {
+		if len(batch) > 0 && (batchSize >= targetSize ||
+			(maxTxSize > 0 && overhead+batchSize+sizes[i] > maxTxSize)) {
+			tx, err := consolidationTx(batch, address, feeRate, dustFeeRate)
+			if err != nil {
+				return nil, errors.Wrapf(err, "tx %d", len(result))
+			}
+			if tx != nil {
+				result = append(result, tx)
+			}
+			batch = nil
+			batchSize = 0
+		}
+
+		batch = append(batch, utxo)
+		batchSize += sizes[i]
+	}
+
+	tx, err := consolidationTx(batch, address, feeRate, dustFeeRate)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tx %d", len(result))
+	}
+	if tx != nil {
+		result = append(result, tx)
+	}
+
+	if len(result) == 0 {
+		return nil, errors.Wrap(ErrInsufficientValue, "consolidated value below dust")
+	}
+
+	return result, nil
+}
+
+// consolidationTx returns a tx spending the UTXOs to the address, or nil if the value left after
+//   the fee is below dust.
+func consolidationTx(utxos []bitcoin.UTXO, address bitcoin.RawAddress,
+	feeRate, dustFeeRate float32) (*TxBuilder, error) {
+
+	tx := NewTxBuilder(feeRate, dustFeeRate)
+	for _, utxo := range utxos {
+		if err := tx.AddInputUTXO(utxo); err != nil {
+			return nil, errors.Wrap(err, "add input")
+		}
+	}
+
+	if err := tx.AddMaxOutput(address); err != nil {
+		return nil, errors.Wrap(err, "add output")
+	}
+
+	if err := tx.CalculateFee(); err != nil {
+		if errors.Cause(err) == ErrInsufficientValue {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "calculate fee")
+	}
+
+	if len(tx.MsgTx.TxOut) == 0 ||
+		tx.MsgTx.TxOut[0].Value < DustLimitForOutput(tx.MsgTx.TxOut[0], dustFeeRate) {
+		return nil, nil
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,92 @@
+package txbuilder
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestConsolidate(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	address, err := bitcoin.RawAddressFromLockingScript(lockingScript)
+	if err != nil {
+		t.Fatalf("Failed to create address : %s", err)
+	}
+
+	var utxos []bitcoin.UTXO
+	for i := 0; i < 250; i++ {
+		utxo := bitcoin.UTXO{
+			Index:         uint32(i),
+			Value:         500,
+			LockingScript: lockingScript,
+		}
+		rand.Read(utxo.Hash[:])
+		utxos = append(utxos, utxo)
+	}
+
+	// Uneconomic to spend
+	uneconomic := bitcoin.UTXO{Value: 10, LockingScript: lockingScript}
+	rand.Read(uneconomic.Hash[:])
+	utxos = append(utxos, uneconomic)
+
+	txs, err := Consolidate(utxos, address, 15000, 0.5, 1.0)
+	if err != nil {
+		t.Fatalf("Failed to consolidate : %s", err)
+	}
+
+	// 250 inputs of 149 bytes need 3 txs of less than 15000 bytes.
+	if len(txs) != 3 {
+		t.Fatalf("Wrong tx count : got %d, want 3", len(txs))
+	}
+
+	inputCount := 0
+	for i, tx := range txs {
+		t.Logf("Tx %d : %d inputs, estimated size %d", i, len(tx.MsgTx.TxIn), tx.EstimatedSize())
+
+		if tx.EstimatedSize() > 15000 {
+			t.Errorf("Tx %d too large : %d", i, tx.EstimatedSize())
+		}
+		if len(tx.MsgTx.TxIn) < 80 {
+			t.Errorf("Tx %d inputs not spread evenly : %d", i, len(tx.MsgTx.TxIn))
+		}
+		if len(tx.MsgTx.TxOut) != 1 {
+			t.Fatalf("Tx %d wrong output count : %d", i, len(tx.MsgTx.TxOut))
+		}
+		if tx.Fee() < tx.EstimatedFee() {
+			t.Errorf("Tx %d fee too low : got %d, want %d", i, tx.Fee(), tx.EstimatedFee())
+		}
+
+		if err := tx.Sign([]bitcoin.Key{key}); err != nil {
+			t.Fatalf("Failed to sign tx %d : %s", i, err)
+		}
+		inputCount += len(tx.MsgTx.TxIn)
+	}
+
+	if inputCount != 250 {
+		t.Errorf("Wrong input count : got %d, want 250", inputCount)
+	}
+
+	// No size limit
+	txs, err = Consolidate(utxos, address, 0, 0.5, 1.0)
+	if err != nil {
+		t.Fatalf("Failed to consolidate : %s", err)
+	}
+	if len(txs) != 1 || len(txs[0].MsgTx.TxIn) != 250 {
+		t.Fatalf("Wrong txs with no size limit : %d", len(txs))
+	}
+
+	if _, err := Consolidate(utxos[250:], address, 0, 0.5, 1.0); errors.Cause(err) !=
+		ErrInsufficientValue {
+		t.Fatalf("Wrong error for uneconomic utxos : %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package txbuilder
+
+import (
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// NewChildPaysForParentTx returns a tx that spends the specified outputs of an unconfirmed parent
+//   tx to the change address. Its fee is high enough that the combined fee rate of the parent and
+//   child meets feeRate, so miners will mine the parent with the child. Sign the tx to calculate
+//   the change value.
+// parentFee is the fee paid by the parent tx, which is its input value minus its output value.
+func NewChildPaysForParentTx(feeRate, dustFeeRate float32, parent *wire.MsgTx, parentFee uint64,
+	outputIndexes []uint32, changeAddress bitcoin.RawAddress) (*TxBuilder, error) {
+
+	result := NewTxBuilder(feeRate, dustFeeRate)
+	if err := result.SetChangeAddress(changeAddress, ""); err != nil {
+		return nil, errors.Wrap(err, "change address")
+	}
+
+	if err := result.PayForParent(parent, parentFee, outputIndexes); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// PayForParent adds inputs spending the specified outputs of an unconfirmed parent tx and adds the
+//   fee the parent is missing to ParentFeeDeficit so the fee calculated when signing pays for both
+//   txs. Set the fee rate or fee quote before calling this because they are used to calculate the
+//   parent's required fee.
+// parentFee is the fee paid by the parent tx, which is its input value minus its output value.
+func (tx *TxBuilder) PayForParent(parent *wire.MsgTx, parentFee uint64,
+	outputIndexes []uint32) error {
+
+	if len(outputIndexes) == 0 {
+		return errors.Wrap(ErrMissingInputData, "no parent outputs")
+	}
+
+	parentHash := parent.TxHash()
+	for _, index := range outputIndexes {
+		if int(index) >= len(parent.TxOut) {
+			return errors.Wrapf(ErrMissingInputData, "parent output %d out of range", index)
+		}
+
+		output := parent.TxOut[index]
+		if err := tx.AddInput(wire.OutPoint{Hash: *parentHash, Index: index},
+			output.LockingScript, output.Value); err != nil {
+			return errors.Wrapf(err, "add parent output %d", index)
+		}
+	}
+
+	tx.ParentFeeDeficit += tx.ParentFeeRequired(parent, parentFee)
+	return nil
+}
+
+// ParentFeeRequired returns the fee, in addition to parentFee, that the parent tx needs to meet
+//   the tx's fee rate or fee quote.
+func (tx *TxBuilder) ParentFeeRequired(parent *wire.MsgTx, parentFee uint64) uint64 {
+	var required uint64
+	if tx.FeeQuote == nil {
+		required = uint64(float32(parent.SerializeSize()) * tx.FeeRate)
+	} else {
+		required = tx.FeeQuote.TxFee(parent)
+	}
+
+	if parentFee >= required {
+		return 0
+	}
+	return required - parentFee
+}
@@ -0,0 +1,86 @@
+package txbuilder
+
+import (
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestChildPaysForParent(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	address, err := bitcoin.RawAddressFromLockingScript(lockingScript)
+	if err != nil {
+		t.Fatalf("Failed to create address : %s", err)
+	}
+
+	// Parent pays a fee rate that is too low to be mined.
+	parent := NewTxBuilder(0.05, 1.0)
+	if err := parent.SetChangeAddress(address, ""); err != nil {
+		t.Fatalf("Failed to set change address : %s", err)
+	}
+	var outpoint wire.OutPoint
+	outpoint.Hash[0] = 1
+	if err := parent.AddInput(outpoint, lockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	if err := parent.AddPaymentOutput(address, 9000, true); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+	if err := parent.Sign([]bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign parent : %s", err)
+	}
+	parentFee := parent.Fee()
+	parentSize := parent.MsgTx.SerializeSize()
+	t.Logf("Parent fee %d for %d bytes", parentFee, parentSize)
+
+	child, err := NewChildPaysForParentTx(1.0, 1.0, parent.MsgTx, parentFee, []uint32{0},
+		address)
+	if err != nil {
+		t.Fatalf("Failed to create child : %s", err)
+	}
+
+	wantDeficit := uint64(float32(parentSize)*1.0) - parentFee
+	if child.ParentFeeDeficit != wantDeficit {
+		t.Fatalf("Wrong parent fee deficit : got %d, want %d", child.ParentFeeDeficit,
+			wantDeficit)
+	}
+
+	if err := child.Sign([]bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign child : %s", err)
+	}
+	childFee := child.Fee()
+	childSize := child.MsgTx.SerializeSize()
+	t.Logf("Child fee %d for %d bytes", childFee, childSize)
+
+	if len(child.MsgTx.TxOut) != 1 {
+		t.Fatalf("Wrong child output count : %d", len(child.MsgTx.TxOut))
+	}
+
+	combinedRequired := uint64(float32(parentSize + childSize))
+	if parentFee+childFee < combinedRequired {
+		t.Fatalf("Combined fee too low : got %d, want %d", parentFee+childFee, combinedRequired)
+	}
+	if childFee <= uint64(childSize) {
+		t.Fatalf("Child fee doesn't pay for parent : %d", childFee)
+	}
+
+	// A parent that already pays enough doesn't need more fee.
+	if deficit := child.ParentFeeRequired(parent.MsgTx, uint64(parentSize)); deficit != 0 {
+		t.Fatalf("Wrong fee required : got %d, want 0", deficit)
+	}
+
+	if _, err := NewChildPaysForParentTx(1.0, 1.0, parent.MsgTx, parentFee, []uint32{1},
+		address); errors.Cause(err) != ErrMissingInputData {
+		t.Fatalf("Wrong error for missing output : %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package txbuilder
+
+import (
+	"bytes"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxDataPushSize is the largest push used in data locking scripts. Larger payloads are split
+	//   into multiple pushes. It is the largest push that is encoded with OP_PUSHDATA2, so parsers
+	//   that don't support OP_PUSHDATA4 can read the pushes.
+	MaxDataPushSize = int(bitcoin.OP_PUSH_DATA_2_MAX) - 1
+)
+
+// DataLockingScript returns an OP_FALSE OP_RETURN locking script containing the payloads. Each
+//   payload is split into pushes of no more than chunkSize bytes. A chunkSize of zero uses
+//   MaxDataPushSize.
+func DataLockingScript(chunkSize int, payloads ...[]byte) (bitcoin.Script, error) {
+	if chunkSize <= 0 {
+		chunkSize = MaxDataPushSize
+	}
+
+	size := 2
+	for _, payload := range payloads {
+		size += len(payload) + ((len(payload)/chunkSize)+1)*5
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	buf.WriteByte(bitcoin.OP_FALSE)
+	buf.WriteByte(bitcoin.OP_RETURN)
+
+	for _, payload := range payloads {
+		if len(payload) == 0 {
+			if err := bitcoin.WritePushDataScript(buf, nil); err != nil {
+				return nil, errors.Wrap(err, "write push")
+			}
+			continue
+		}
+
+		for offset := 0; offset < len(payload); offset += chunkSize {
+			end := offset + chunkSize
+			if end > len(payload) {
+				end = len(payload)
+			}
+
+			if err := bitcoin.WritePushDataScript(buf, payload[offset:end]); err != nil {
+				return nil, errors.Wrap(err, "write push")
+			}
+		}
+	}
+
+	return bitcoin.Script(buf.Bytes()), nil
+}
+
+// AddDataOutput adds a zero value OP_FALSE OP_RETURN output containing the payloads, split into
+//   pushes of no more than MaxDataPushSize bytes. It returns ErrDataTooLarge if the tx's data
+//   outputs would exceed MaxDataSize.
+func (tx *TxBuilder) AddDataOutput(payloads ...[]byte) error {
+	lockingScript, err := DataLockingScript(MaxDataPushSize, payloads...)
+	if err != nil {
+		return errors.Wrap(err, "locking script")
+	}
+
+	if tx.MaxDataSize > 0 {
+		dataSize := DataSize(tx.MsgTx) + len(lockingScript)
+		if dataSize > tx.MaxDataSize {
+			return errors.Wrapf(ErrDataTooLarge, "%d bytes, max %d", dataSize, tx.MaxDataSize)
+		}
+	}
+
+	return tx.AddOutput(lockingScript, 0, false, false)
+}
+
+// EstimatedFees returns the estimated fee for the standard bytes and the data bytes of the tx
+//   separately. Data bytes are the locking scripts of data outputs and use the data rates of the
+//   fee quote when one is set. The sum doesn't include ParentFeeDeficit.
+func (tx *TxBuilder) EstimatedFees() (uint64, uint64) {
+	dataSize := DataSize(tx.MsgTx)
+	standardSize := tx.EstimatedSize() - dataSize
+
+	if tx.FeeQuote == nil {
+		return uint64(float32(standardSize) * tx.FeeRate), uint64(float32(dataSize) * tx.FeeRate)
+	}
+
+	return tx.FeeQuote.Fee(standardSize, 0), tx.FeeQuote.Fee(0, dataSize)
+}
@@ -0,0 +1,112 @@
+package txbuilder
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestDataLockingScript(t *testing.T) {
+	large := make([]byte, MaxDataPushSize*2+10)
+	rand.Read(large)
+
+	tests := []struct {
+		name      string
+		chunkSize int
+		payloads  [][]byte
+		pushes    []int
+	}{
+		{"small", 0, [][]byte{[]byte("test")}, []int{4}},
+		{"multiple", 0, [][]byte{[]byte("test"), {}, []byte("data")}, []int{4, 0, 4}},
+		{"chunked", 3, [][]byte{[]byte("abcdefgh")}, []int{3, 3, 2}},
+		{"large", 0, [][]byte{large}, []int{MaxDataPushSize, MaxDataPushSize, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := DataLockingScript(tt.chunkSize, tt.payloads...)
+			if err != nil {
+				t.Fatalf("Failed to create script : %s", err)
+			}
+
+			if !bitcoin.LockingScriptIsUnspendable(script) {
+				t.Fatalf("Script should be unspendable")
+			}
+
+			buf := bytes.NewReader(script[2:])
+			var joined, want []byte
+			for _, payload := range tt.payloads {
+				want = append(want, payload...)
+			}
+			for i, size := range tt.pushes {
+				_, data, err := bitcoin.ParsePushDataScript(buf)
+				if err != nil {
+					t.Fatalf("Failed to parse push %d : %s", i, err)
+				}
+				if len(data) != size {
+					t.Fatalf("Wrong push %d size : got %d, want %d", i, len(data), size)
+				}
+				joined = append(joined, data...)
+			}
+
+			if buf.Len() != 0 {
+				t.Fatalf("Extra script bytes : %d", buf.Len())
+			}
+			if !bytes.Equal(joined, want) {
+				t.Fatalf("Wrong data")
+			}
+		})
+	}
+}
+
+func TestAddDataOutput(t *testing.T) {
+	tx := NewTxBuilder(0.5, 1.0)
+	tx.MaxDataSize = 1000
+
+	if err := tx.AddDataOutput(make([]byte, 500)); err != nil {
+		t.Fatalf("Failed to add data output : %s", err)
+	}
+
+	if tx.MsgTx.TxOut[0].Value != 0 {
+		t.Fatalf("Wrong data output value : %d", tx.MsgTx.TxOut[0].Value)
+	}
+
+	if err := tx.AddDataOutput(make([]byte, 500)); errors.Cause(err) != ErrDataTooLarge {
+		t.Fatalf("Wrong error for too much data : %v", err)
+	}
+
+	if len(tx.MsgTx.TxOut) != 1 {
+		t.Fatalf("Wrong output count : %d", len(tx.MsgTx.TxOut))
+	}
+
+	dataSize := DataSize(tx.MsgTx)
+	standardSize := tx.EstimatedSize() - dataSize
+
+	standardFee, dataFee := tx.EstimatedFees()
+	if standardFee != uint64(float32(standardSize)*0.5) {
+		t.Errorf("Wrong standard fee : got %d, want %d", standardFee, standardSize/2)
+	}
+	if dataFee != uint64(float32(dataSize)*0.5) {
+		t.Errorf("Wrong data fee : got %d, want %d", dataFee, dataSize/2)
+	}
+
+	quote := NewFeeQuote(0.5)
+	quote.DataMiningFee = FeeRate{Satoshis: 250, Bytes: 1000}
+	quote.DataRelayFee = FeeRate{Satoshis: 250, Bytes: 1000}
+	tx.SetFeeQuote(quote)
+
+	standardFee, dataFee = tx.EstimatedFees()
+	if standardFee != uint64(standardSize+1)/2 {
+		t.Errorf("Wrong quote standard fee : got %d, want %d", standardFee, (standardSize+1)/2)
+	}
+	if dataFee != uint64(dataSize+3)/4 {
+		t.Errorf("Wrong quote data fee : got %d, want %d", dataFee, (dataSize+3)/4)
+	}
+	if standardFee+dataFee != tx.EstimatedFee() {
+		t.Errorf("Fees don't add up : %d + %d != %d", standardFee, dataFee, tx.EstimatedFee())
+	}
+}
@@ -0,0 +1,198 @@
+package txbuilder
+
+import (
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrMissingAncestor means that an expanded tx doesn't contain a tx or merkle proof needed to
+	// verify one of its inputs.
+	ErrMissingAncestor = errors.New("Missing Ancestor")
+
+	// ErrInvalidAncestor means that an ancestor's tx and merkle proof don't match or the ancestor
+	// doesn't contain the output being spent.
+	ErrInvalidAncestor = errors.New("Invalid Ancestor")
+)
+
+// AncestorTx is a tx that is spent by an expanded tx, or by one of its unconfirmed ancestors.
+//   Confirmed ancestors have a merkle proof. The tx is needed when an output is spent directly by
+//   the expanded tx, so its locking script and value are known, or when the ancestor is
+//   unconfirmed, so its inputs can be checked. Otherwise only the merkle proof is needed.
+type AncestorTx struct {
+	Tx          *wire.MsgTx               `json:"tx,omitempty"`
+	MerkleProof *merkle_proof.MerkleProof `json:"merkle_proof,omitempty"`
+}
+
+// ExpandedTx is a tx bundled with the ancestors needed to verify it without access to the chain,
+//   as exchanged with merchant APIs and over SPV channels.
+type ExpandedTx struct {
+	Tx        *wire.MsgTx   `json:"tx"`
+	Ancestors []*AncestorTx `json:"ancestors,omitempty"`
+}
+
+// NewExpandedTx creates an expanded tx with no ancestors.
+func NewExpandedTx(tx *wire.MsgTx) *ExpandedTx {
+	return &ExpandedTx{
+		Tx: tx,
+	}
+}
+
+// TxID returns the hash of the ancestor's tx, or the tx id of its merkle proof if the tx isn't
+//   included.
+func (a AncestorTx) TxID() *bitcoin.Hash32 {
+	if a.Tx != nil {
+		return a.Tx.TxHash()
+	}
+	if a.MerkleProof != nil {
+		return a.MerkleProof.TxID
+	}
+	return nil
+}
+
+// IsConfirmed returns true if the ancestor has a merkle proof.
+func (a AncestorTx) IsConfirmed() bool {
+	return a.MerkleProof != nil
+}
+
+// AddAncestor adds a tx and an optional merkle proof as an ancestor. If the ancestor already
+//   exists, the tx or merkle proof is added to it.
+func (etx *ExpandedTx) AddAncestor(tx *wire.MsgTx, merkleProof *merkle_proof.MerkleProof) {
+	var txid *bitcoin.Hash32
+	if tx != nil {
+		txid = tx.TxHash()
+	} else if merkleProof != nil {
+		txid = merkleProof.TxID
+	}
+	if txid == nil {
+		return
+	}
+
+	if ancestor := etx.Ancestor(*txid); ancestor != nil {
+		if ancestor.Tx == nil {
+			ancestor.Tx = tx
+		}
+		if ancestor.MerkleProof == nil {
+			ancestor.MerkleProof = merkleProof
+		}
+		return
+	}
+
+	etx.Ancestors = append(etx.Ancestors, &AncestorTx{
+		Tx:          tx,
+		MerkleProof: merkleProof,
+	})
+}
+
+// Ancestor returns the ancestor with the tx id, or nil if it isn't included.
+func (etx ExpandedTx) Ancestor(txid bitcoin.Hash32) *AncestorTx {
+	for _, ancestor := range etx.Ancestors {
+		ancestorTxID := ancestor.TxID()
+		if ancestorTxID != nil && ancestorTxID.Equal(&txid) {
+			return ancestor
+		}
+	}
+
+	return nil
+}
+
+// InputOutput returns the output spent by the input at the specified index.
+func (etx ExpandedTx) InputOutput(index int) (*wire.TxOut, error) {
+	if index >= len(etx.Tx.TxIn) {
+		return nil, errors.New("Input index out of range")
+	}
+
+	return etx.spentOutput(etx.Tx.TxIn[index].PreviousOutPoint)
+}
+
+func (etx ExpandedTx) spentOutput(outpoint wire.OutPoint) (*wire.TxOut, error) {
+	ancestor := etx.Ancestor(outpoint.Hash)
+	if ancestor == nil || ancestor.Tx == nil {
+		return nil, errors.Wrap(ErrMissingAncestor, outpoint.Hash.String())
+	}
+
+	if int(outpoint.Index) >= len(ancestor.Tx.TxOut) {
+		return nil, errors.Wrapf(ErrInvalidAncestor, "%s output %d out of range", outpoint.Hash,
+			outpoint.Index)
+	}
+
+	return ancestor.Tx.TxOut[outpoint.Index], nil
+}
+
+// Validate checks that every input of the tx spends an output of an included ancestor tx, and
+//   that every unconfirmed ancestor's inputs are covered in the same way, down to ancestors with
+//   merkle proofs. It also checks that merkle proofs match their txs and calculate a merkle root.
+//   Merkle roots must be checked against block headers separately.
+func (etx ExpandedTx) Validate() error {
+	for _, ancestor := range etx.Ancestors {
+		if ancestor.Tx == nil && ancestor.MerkleProof == nil {
+			return errors.Wrap(ErrInvalidAncestor, "empty")
+		}
+
+		if ancestor.MerkleProof == nil {
+			continue
+		}
+
+		txid := ancestor.TxID()
+		if ancestor.MerkleProof.TxID == nil || !ancestor.MerkleProof.TxID.Equal(txid) {
+			return errors.Wrapf(ErrInvalidAncestor, "%s merkle proof tx id", txid)
+		}
+
+		if _, err := ancestor.MerkleProof.CalculateRoot(); err != nil {
+			return errors.Wrapf(ErrInvalidAncestor, "%s merkle proof: %s", txid, err)
+		}
+	}
+
+	for index, txin := range etx.Tx.TxIn {
+		if _, err := etx.spentOutput(txin.PreviousOutPoint); err != nil {
+			return errors.Wrapf(err, "input %d", index)
+		}
+	}
+
+	// Follow unconfirmed ancestors until they are covered by merkle proofs.
+	checked := make(map[bitcoin.Hash32]bool)
+	unconfirmed := []*wire.MsgTx{etx.Tx}
+	for len(unconfirmed) > 0 {
+		tx := unconfirmed[0]
+		unconfirmed = unconfirmed[1:]
+
+		for index, txin := range tx.TxIn {
+			hash := txin.PreviousOutPoint.Hash
+			ancestor := etx.Ancestor(hash)
+			if ancestor == nil {
+				return errors.Wrapf(ErrMissingAncestor, "%s input %d: %s", tx.TxHash(), index,
+					hash)
+			}
+
+			if ancestor.IsConfirmed() || checked[hash] {
+				continue
+			}
+			checked[hash] = true
+
+			if ancestor.Tx == nil {
+				return errors.Wrapf(ErrMissingAncestor, "unconfirmed tx %s", hash)
+			}
+			unconfirmed = append(unconfirmed, ancestor.Tx)
+		}
+	}
+
+	return nil
+}
+
+// NewTxBuilderFromExpandedTx returns a new TxBuilder for the expanded tx, with the input data taken
+//   from the ancestors.
+func NewTxBuilderFromExpandedTx(feeRate, dustFeeRate float32,
+	etx *ExpandedTx) (*TxBuilder, error) {
+
+	var inputs []*wire.MsgTx
+	for _, ancestor := range etx.Ancestors {
+		if ancestor.Tx != nil {
+			inputs = append(inputs, ancestor.Tx)
+		}
+	}
+
+	return NewTxBuilderFromWire(feeRate, dustFeeRate, etx.Tx, inputs)
+}
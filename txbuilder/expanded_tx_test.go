@@ -0,0 +1,91 @@
+package txbuilder
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/merkle_proof"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestExpandedTx(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	// Confirmed grandparent
+	grandparent := wire.NewMsgTx(1)
+	var outpoint wire.OutPoint
+	rand.Read(outpoint.Hash[:])
+	grandparent.AddTxIn(wire.NewTxIn(&outpoint, nil))
+	grandparent.AddTxOut(wire.NewTxOut(10000, lockingScript))
+
+	var sibling bitcoin.Hash32
+	rand.Read(sibling[:])
+	proof := merkle_proof.NewMerkleProof(*grandparent.TxHash())
+	proof.Index = 0
+	proof.Path = []bitcoin.Hash32{sibling}
+
+	// Unconfirmed parent
+	parent := wire.NewMsgTx(1)
+	parent.AddTxIn(wire.NewTxIn(wire.NewOutPoint(grandparent.TxHash(), 0), nil))
+	parent.AddTxOut(wire.NewTxOut(9000, lockingScript))
+	parent.AddTxOut(wire.NewTxOut(500, lockingScript))
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(parent.TxHash(), 1), nil))
+	tx.AddTxOut(wire.NewTxOut(400, lockingScript))
+
+	etx := NewExpandedTx(tx)
+	etx.AddAncestor(parent, nil)
+
+	if err := etx.Validate(); errors.Cause(err) != ErrMissingAncestor {
+		t.Fatalf("Wrong error for missing grandparent : %v", err)
+	}
+
+	// Proof only, without the tx, covers the unconfirmed parent's input.
+	etx.AddAncestor(nil, proof)
+	if err := etx.Validate(); err != nil {
+		t.Fatalf("Failed to validate : %s", err)
+	}
+
+	output, err := etx.InputOutput(0)
+	if err != nil {
+		t.Fatalf("Failed to get input output : %s", err)
+	}
+	if output.Value != 500 {
+		t.Fatalf("Wrong input output value : got %d, want 500", output.Value)
+	}
+
+	// Adding the tx to the existing ancestor.
+	etx.AddAncestor(grandparent, nil)
+	if len(etx.Ancestors) != 2 {
+		t.Fatalf("Wrong ancestor count : got %d, want 2", len(etx.Ancestors))
+	}
+	ancestor := etx.Ancestor(*grandparent.TxHash())
+	if ancestor == nil || ancestor.Tx == nil || !ancestor.IsConfirmed() {
+		t.Fatalf("Grandparent should have tx and merkle proof")
+	}
+
+	txb, err := NewTxBuilderFromExpandedTx(0.5, 1.0, etx)
+	if err != nil {
+		t.Fatalf("Failed to create tx builder : %s", err)
+	}
+	if txb.Inputs[0].Value != 500 || !txb.Inputs[0].LockingScript.Equal(lockingScript) {
+		t.Fatalf("Wrong input supplement")
+	}
+
+	// Spending an output that doesn't exist.
+	tx.TxIn[0].PreviousOutPoint.Index = 2
+	if err := etx.Validate(); errors.Cause(err) != ErrInvalidAncestor {
+		t.Fatalf("Wrong error for missing output : %v", err)
+	}
+}
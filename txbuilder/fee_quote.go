@@ -0,0 +1,117 @@
+package txbuilder
+
+import (
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+)
+
+// FeeRate is a fee of Satoshis for every Bytes of tx size, as specified in merchant API fee
+// quotes.
+type FeeRate struct {
+	Satoshis uint64
+	Bytes    uint64
+}
+
+// NewFeeRate returns a fee rate from a rate in satoshis per byte.
+func NewFeeRate(satoshisPerByte float32) FeeRate {
+	return FeeRate{
+		Satoshis: uint64(satoshisPerByte*1000.0 + 0.5),
+		Bytes:    1000,
+	}
+}
+
+// Fee returns the fee for the size in bytes. It is rounded up so that the rate is always met.
+func (r FeeRate) Fee(size int) uint64 {
+	if r.Bytes == 0 || size <= 0 {
+		return 0
+	}
+
+	return (uint64(size)*r.Satoshis + r.Bytes - 1) / r.Bytes
+}
+
+// Rate returns the rate in satoshis per byte.
+func (r FeeRate) Rate() float32 {
+	if r.Bytes == 0 {
+		return 0.0
+	}
+
+	return float32(r.Satoshis) / float32(r.Bytes)
+}
+
+// max returns the fee rate with the higher rate.
+func (r FeeRate) max(other FeeRate) FeeRate {
+	if other.Satoshis*r.Bytes > r.Satoshis*other.Bytes {
+		return other
+	}
+	return r
+}
+
+// FeeQuote contains the fee rates required by a miner. Data bytes, the bytes of locking scripts
+// that start with OP_RETURN or OP_FALSE OP_RETURN, can have a different rate than the rest of the
+// tx's bytes. The mining fee is required for a tx to be mined and the relay fee is required for
+// the tx to be relayed, so the higher of the two is used.
+type FeeQuote struct {
+	MiningFee     FeeRate
+	RelayFee      FeeRate
+	DataMiningFee FeeRate
+	DataRelayFee  FeeRate
+}
+
+// NewFeeQuote returns a fee quote that uses the same rate, in satoshis per byte, for all bytes.
+func NewFeeQuote(satoshisPerByte float32) *FeeQuote {
+	rate := NewFeeRate(satoshisPerByte)
+	return &FeeQuote{
+		MiningFee:     rate,
+		RelayFee:      rate,
+		DataMiningFee: rate,
+		DataRelayFee:  rate,
+	}
+}
+
+// StandardRate returns the rate, in satoshis per byte, required for non-data bytes.
+func (q FeeQuote) StandardRate() float32 {
+	return q.MiningFee.max(q.RelayFee).Rate()
+}
+
+// Fee returns the fee required for a tx with the specified numbers of standard and data bytes.
+func (q FeeQuote) Fee(standardSize, dataSize int) uint64 {
+	return q.MiningFee.max(q.RelayFee).Fee(standardSize) +
+		q.DataMiningFee.max(q.DataRelayFee).Fee(dataSize)
+}
+
+// TxFee returns the fee required for the tx.
+func (q FeeQuote) TxFee(tx *wire.MsgTx) uint64 {
+	dataSize := DataSize(tx)
+	return q.Fee(tx.SerializeSize()-dataSize, dataSize)
+}
+
+// DataSize returns the number of bytes in the tx that are in data locking scripts.
+func DataSize(tx *wire.MsgTx) int {
+	result := 0
+	for _, output := range tx.TxOut {
+		if bitcoin.LockingScriptIsUnspendable(output.LockingScript) {
+			result += len(output.LockingScript)
+		}
+	}
+	return result
+}
+
+// SetFeeQuote sets the fee quote used to calculate the tx fee. The fee rate is set to the quote's
+// standard rate because it is used to calculate the fees for inputs and change outputs.
+func (tx *TxBuilder) SetFeeQuote(quote *FeeQuote) {
+	tx.FeeQuote = quote
+	if quote != nil {
+		tx.FeeRate = quote.StandardRate()
+	}
+}
+
+// feeForSize returns the fee for a tx of the specified size containing the tx's data scripts,
+// including any fee paid for parent txs.
+func (tx *TxBuilder) feeForSize(size int) uint64 {
+	if tx.FeeQuote == nil {
+		return uint64(float32(size)*tx.FeeRate) + tx.ParentFeeDeficit
+	}
+
+	dataSize := DataSize(tx.MsgTx)
+	return tx.FeeQuote.Fee(size-dataSize, dataSize) + tx.ParentFeeDeficit
+}
@@ -0,0 +1,105 @@
+package txbuilder
+
+import (
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+)
+
+func TestFeeRate(t *testing.T) {
+	tests := []struct {
+		rate FeeRate
+		size int
+		fee  uint64
+	}{
+		{FeeRate{Satoshis: 500, Bytes: 1000}, 1000, 500},
+		{FeeRate{Satoshis: 500, Bytes: 1000}, 1001, 501}, // rounded up
+		{FeeRate{Satoshis: 5, Bytes: 10}, 3, 2},
+		{FeeRate{Satoshis: 500, Bytes: 0}, 1000, 0},
+		{NewFeeRate(0.25), 400, 100},
+	}
+
+	for i, tt := range tests {
+		if fee := tt.rate.Fee(tt.size); fee != tt.fee {
+			t.Errorf("Test %d : wrong fee : got %d, want %d", i, fee, tt.fee)
+		}
+	}
+}
+
+func TestFeeQuoteData(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+
+	address, err := bitcoin.NewRawAddressPKH(bitcoin.Hash160(key.PublicKey().Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to create pkh address : %s", err)
+	}
+
+	lockingScript, err := address.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	quote := &FeeQuote{
+		MiningFee:     FeeRate{Satoshis: 500, Bytes: 1000},
+		RelayFee:      FeeRate{Satoshis: 250, Bytes: 1000},
+		DataMiningFee: FeeRate{Satoshis: 50, Bytes: 1000},
+		DataRelayFee:  FeeRate{Satoshis: 100, Bytes: 1000},
+	}
+
+	dataOutput, err := wire.NewTxOutData(make([]byte, 10000))
+	if err != nil {
+		t.Fatalf("Failed to create data output : %s", err)
+	}
+
+	tx := NewTxBuilder(0.5, 1.0)
+	tx.SetFeeQuote(quote)
+	tx.SetChangeAddress(address, "")
+
+	if tx.FeeRate != 0.5 {
+		t.Fatalf("Wrong fee rate : got %f, want %f", tx.FeeRate, 0.5)
+	}
+
+	if err := tx.AddOutput(dataOutput.LockingScript, 0, false, false); err != nil {
+		t.Fatalf("Failed to add data output : %s", err)
+	}
+
+	utxo := bitcoin.UTXO{
+		Index:         0,
+		Value:         100000,
+		LockingScript: lockingScript,
+	}
+	if err := tx.AddFunding([]bitcoin.UTXO{utxo}); err != nil {
+		t.Fatalf("Failed to add funding : %s", err)
+	}
+
+	if err := tx.Sign([]bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign tx : %s", err)
+	}
+
+	dataSize := DataSize(tx.MsgTx)
+	if dataSize != len(dataOutput.LockingScript) {
+		t.Fatalf("Wrong data size : got %d, want %d", dataSize, len(dataOutput.LockingScript))
+	}
+
+	required := quote.TxFee(tx.MsgTx)
+	fee := tx.Fee()
+	t.Logf("Tx size %d, data size %d, fee %d, required %d", tx.MsgTx.SerializeSize(), dataSize,
+		fee, required)
+
+	if fee < required {
+		t.Fatalf("Fee too low : got %d, want %d", fee, required)
+	}
+
+	// The fee should be far less than paying the standard rate for the data.
+	if fee >= uint64(float32(tx.MsgTx.SerializeSize())*quote.StandardRate()) {
+		t.Fatalf("Fee not reduced for data : %d", fee)
+	}
+
+	if len(tx.MsgTx.TxOut) != 2 {
+		t.Fatalf("Change output not added : %d outputs", len(tx.MsgTx.TxOut))
+	}
+}
@@ -1,7 +1,6 @@
 package txbuilder
 
 import (
-	"fmt"
 	"math"
 
 	"github.com/tokenized/pkg/bitcoin"
@@ -113,6 +112,10 @@ func UnlockingScriptSize(lockingScript bitcoin.Script) (int, error) {
 	} else if lockingScript.IsP2PKH() {
 		// Signature and a public key in a P2PKH unlocking script
 		scriptSize = MaxSignaturesPushDataSize + PublicKeyPushDataSize
+	} else if required, _, err := lockingScript.MultiSigCounts(); err == nil {
+		// OP_FALSE, because of the extra item popped by OP_CHECKMULTISIG, and the required
+		//   signatures
+		scriptSize = 1 + int(required)*MaxSignaturesPushDataSize
 	} else {
 		required, total, err := lockingScript.MultiPKHCounts()
 		if err != nil {
@@ -184,7 +187,51 @@ func (tx *TxBuilder) EstimatedSize() int {
 }
 
 func (tx *TxBuilder) EstimatedFee() uint64 {
-	return uint64(float32(tx.EstimatedSize()) * tx.FeeRate)
+	return tx.feeForSize(tx.EstimatedSize())
+}
+
+// EstimateSize returns the serialized size in bytes of the tx after all inputs are signed, so the
+//   fee can be shown before signing. Signed inputs use their actual size. Unsigned inputs use the
+//   maximum unlocking script size for their locking script template, so the result is only larger
+//   than the final size by the few bytes that shorter signatures save.
+// Unlike EstimatedSize, it returns ErrWrongScriptTemplate for unsigned inputs with unsupported
+//   locking scripts instead of assuming they are P2PKH.
+func (tx *TxBuilder) EstimateSize() (int, error) {
+	if len(tx.Inputs) != len(tx.MsgTx.TxIn) {
+		return 0, ErrMissingInputData
+	}
+
+	result := BaseTxSize + wire.VarIntSerializeSize(uint64(len(tx.MsgTx.TxIn))) +
+		wire.VarIntSerializeSize(uint64(len(tx.MsgTx.TxOut)))
+
+	for index, txin := range tx.MsgTx.TxIn {
+		if len(txin.UnlockingScript) > 0 {
+			result += txin.SerializeSize()
+			continue
+		}
+
+		size, err := InputSize(tx.Inputs[index].LockingScript)
+		if err != nil {
+			return 0, errors.Wrapf(ErrWrongScriptTemplate, "input %d", index)
+		}
+		result += size
+	}
+
+	for _, output := range tx.MsgTx.TxOut {
+		result += output.SerializeSize()
+	}
+
+	return result, nil
+}
+
+// EstimateFee returns the fee required for the size returned by EstimateSize.
+func (tx *TxBuilder) EstimateFee() (uint64, error) {
+	size, err := tx.EstimateSize()
+	if err != nil {
+		return 0, err
+	}
+
+	return tx.feeForSize(size), nil
 }
 
 func (tx *TxBuilder) CalculateFee() error {
@@ -269,20 +316,18 @@ func (tx *TxBuilder) adjustFee(amount int64) (bool, error) {
 	} else {
 		// Decrease fee, transfer to change
 		if changeOutputIndex == 0xffffffff {
+			if err := tx.loadChangeScript(); err != nil {
+				return false, err
+			}
+
 			// Add a change output if it would be more than the dust limit plus the fee to add the
 			// output
 			changeFee, dustLimit := OutputFeeAndDustForLockingScript(tx.ChangeScript,
 				tx.DustFeeRate, tx.FeeRate)
 			if uint64(-amount) > dustLimit+changeFee {
-				if len(tx.ChangeScript) == 0 {
-					return false, errors.Wrap(ErrChangeAddressNeeded, fmt.Sprintf("Remaining: %d",
-						uint64(-amount)))
-				}
-				err := tx.AddOutput(tx.ChangeScript, uint64(-amount)-changeFee, true, false)
-				if err != nil {
+				if err := tx.addChangeOutput(uint64(-amount) - changeFee); err != nil {
 					return false, err
 				}
-				tx.Outputs[len(tx.Outputs)-1].KeyID = tx.ChangeKeyID
 				tx.Outputs[len(tx.Outputs)-1].addedForFee = true
 			} else {
 				// Leave less than dust as additional tx fee
@@ -3,9 +3,14 @@ package txbuilder
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
 )
 
 func Test_MultiPKH_EstimatedSize(t *testing.T) {
@@ -82,3 +87,99 @@ func Test_P2PKH_EstimatedSize(t *testing.T) {
 		t.Fatalf("Estimated size out of range : %d", estSize)
 	}
 }
+
+func Test_EstimateSize(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	tx := NewTxBuilder(0.5, 1.0)
+	if err := tx.SetChangeLockingScript(lockingScript, ""); err != nil {
+		t.Fatalf("Failed to set change : %s", err)
+	}
+	var outpoint wire.OutPoint
+	outpoint.Hash[0] = 1
+	if err := tx.AddInput(outpoint, lockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	if err := tx.AddOutput(lockingScript, 5000, true, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+
+	estimated, err := tx.EstimateSize()
+	if err != nil {
+		t.Fatalf("Failed to estimate size : %s", err)
+	}
+
+	estimatedFee, err := tx.EstimateFee()
+	if err != nil {
+		t.Fatalf("Failed to estimate fee : %s", err)
+	}
+	if estimatedFee != uint64(float32(estimated)*0.5) {
+		t.Fatalf("Wrong estimated fee : got %d, want %d", estimatedFee, estimated/2)
+	}
+
+	if err := tx.SignOnly([]bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	actual := tx.MsgTx.SerializeSize()
+	t.Logf("Estimated size %d, actual size %d", estimated, actual)
+	if estimated < actual || estimated > actual+2 {
+		t.Fatalf("Estimated size out of range : %d", estimated)
+	}
+
+	signedEstimate, err := tx.EstimateSize()
+	if err != nil {
+		t.Fatalf("Failed to estimate size : %s", err)
+	}
+	if signedEstimate != actual {
+		t.Fatalf("Wrong signed size estimate : got %d, want %d", signedEstimate, actual)
+	}
+
+	// 2 of 3 bare multi-sig
+	var publicKeys []string
+	for i := 0; i < 3; i++ {
+		multiKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+		if err != nil {
+			t.Fatalf("Failed to create private key : %s", err)
+		}
+		publicKeys = append(publicKeys, fmt.Sprintf("0x%x", multiKey.PublicKey().Bytes()))
+	}
+	multiSigScript, err := bitcoin.StringToScript("OP_2 " + strings.Join(publicKeys, " ") +
+		" OP_3 OP_CHECKMULTISIG")
+	if err != nil {
+		t.Fatalf("Failed to create multi-sig script : %s", err)
+	}
+
+	outpoint.Hash[0] = 2
+	if err := tx.AddInput(outpoint, multiSigScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+
+	multiSigEstimate, err := tx.EstimateSize()
+	if err != nil {
+		t.Fatalf("Failed to estimate size : %s", err)
+	}
+
+	// outpoint, script size, OP_FALSE, 2 signatures, sequence
+	wantInputSize := InputBaseSize + 1 + 1 + 2*MaxSignaturesPushDataSize + 4
+	if multiSigEstimate != actual+wantInputSize {
+		t.Fatalf("Wrong multi-sig size estimate : got %d, want %d", multiSigEstimate,
+			actual+wantInputSize)
+	}
+
+	outpoint.Hash[0] = 3
+	if err := tx.AddInput(outpoint, bitcoin.Script{bitcoin.OP_TRUE}, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+
+	if _, err := tx.EstimateSize(); errors.Cause(err) != ErrWrongScriptTemplate {
+		t.Fatalf("Wrong error for unsupported script : %v", err)
+	}
+}
@@ -128,6 +128,10 @@ func (tx *TxBuilder) RemoveInput(index int) error {
 		return errors.New("Input index out of range")
 	}
 
+	if err := tx.releaseInput(tx.MsgTx.TxIn[index].PreviousOutPoint); err != nil {
+		return errors.Wrap(err, "release")
+	}
+
 	tx.Inputs = append(tx.Inputs[:index], tx.Inputs[index+1:]...)
 	tx.MsgTx.TxIn = append(tx.MsgTx.TxIn[:index], tx.MsgTx.TxIn[index+1:]...)
 	return nil
@@ -169,6 +173,11 @@ func (tx *TxBuilder) AddFunding(utxos []bitcoin.UTXO) error {
 			break
 		}
 	}
+	if changeDustLimit == 0 {
+		if err := tx.loadChangeScript(); err != nil {
+			return err
+		}
+	}
 	if changeDustLimit == 0 && len(tx.ChangeScript) > 0 {
 		changeOutputFee, changeDustLimit = OutputFeeAndDustForLockingScript(tx.ChangeScript,
 			tx.DustFeeRate, tx.FeeRate)
@@ -180,11 +189,14 @@ func (tx *TxBuilder) AddFunding(utxos []bitcoin.UTXO) error {
 	}
 
 	for _, utxo := range utxos {
-		if err := tx.AddInputUTXO(utxo); err != nil {
+		if err := tx.addFundingInput(utxo); err != nil {
 			if errors.Cause(err) == ErrDuplicateInput {
 				duplicateValue += utxo.Value
 				continue
 			}
+			if errors.Cause(err) == ErrUTXOReserved {
+				continue
+			}
 			return errors.Wrap(err, "adding input")
 		}
 
@@ -213,15 +225,9 @@ func (tx *TxBuilder) AddFunding(utxos []bitcoin.UTXO) error {
 				if change > changeDustLimit+changeOutputFee {
 					// Add new change output
 					change -= changeOutputFee
-					if len(tx.ChangeScript) == 0 {
-						return errors.Wrap(ErrChangeAddressNeeded, fmt.Sprintf("Remaining: %d",
-							change))
-					}
-
-					if err := tx.AddOutput(tx.ChangeScript, change, true, false); err != nil {
+					if err := tx.addChangeOutput(change); err != nil {
 						return errors.Wrap(err, "adding change")
 					}
-					tx.Outputs[len(tx.Outputs)-1].KeyID = tx.ChangeKeyID
 				}
 			}
 
@@ -313,11 +319,14 @@ func (tx *TxBuilder) AddFundingBreakChange(utxos []bitcoin.UTXO, breakValue uint
 	duplicateValue := uint64(0)
 
 	for _, utxo := range utxos {
-		if err := tx.AddInputUTXO(utxo); err != nil {
+		if err := tx.addFundingInput(utxo); err != nil {
 			if errors.Cause(err) == ErrDuplicateInput {
 				duplicateValue += utxo.Value
 				continue
 			}
+			if errors.Cause(err) == ErrUTXOReserved {
+				continue
+			}
 			return errors.Wrap(err, "adding input")
 		}
 
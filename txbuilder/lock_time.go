@@ -0,0 +1,157 @@
+package txbuilder
+
+import (
+	"time"
+
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// LockTimeThreshold is the lock time value below which lock times are block heights. Lock times
+	//   at or above it are unix timestamps.
+	LockTimeThreshold = uint32(500000000)
+
+	// MaxRelativeLockTimeSeconds is the largest relative lock time in seconds that can be encoded
+	//   in an input sequence.
+	MaxRelativeLockTimeSeconds = uint32(wire.SequenceLockTimeMask) <<
+		wire.SequenceLockTimeGranularity
+)
+
+var (
+	// ErrInvalidLockTime means that a lock time or sequence is out of the range for its type.
+	ErrInvalidLockTime = errors.New("Invalid Lock Time")
+)
+
+// SetLockTimeHeight sets the tx's lock time so that it can't be mined until after the block
+//   height. Signatures that are no longer valid are removed.
+func (tx *TxBuilder) SetLockTimeHeight(height uint32) error {
+	if height >= LockTimeThreshold {
+		return errors.Wrapf(ErrInvalidLockTime, "height %d not below %d", height,
+			LockTimeThreshold)
+	}
+
+	tx.setLockTime(height)
+	return nil
+}
+
+// SetLockTimeTime sets the tx's lock time so that it can't be mined until after the time. Lock
+//   times are compared to the median time of the previous blocks. Signatures that are no longer
+//   valid are removed.
+func (tx *TxBuilder) SetLockTimeTime(t time.Time) error {
+	unix := t.Unix()
+	if unix < int64(LockTimeThreshold) || unix > int64(wire.MaxTxInSequenceNum) {
+		return errors.Wrapf(ErrInvalidLockTime, "time %d not in range %d - %d", unix,
+			LockTimeThreshold, wire.MaxTxInSequenceNum)
+	}
+
+	tx.setLockTime(uint32(unix))
+	return nil
+}
+
+// setLockTime sets the lock time and lowers the sequence of final inputs, because the lock time is
+//   ignored when all inputs are final.
+func (tx *TxBuilder) setLockTime(lockTime uint32) {
+	signatures := tx.signatureHashes()
+
+	tx.MsgTx.LockTime = lockTime
+	if lockTime != 0 && tx.allInputsFinal() {
+		for _, txin := range tx.MsgTx.TxIn {
+			txin.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+	}
+
+	tx.removeInvalidSignatures(signatures)
+}
+
+// SetInputSequence sets the sequence of the input at the specified index. Signatures that are no
+//   longer valid are removed.
+func (tx *TxBuilder) SetInputSequence(index int, sequence uint32) error {
+	if index >= len(tx.MsgTx.TxIn) {
+		return errors.New("Input index out of range")
+	}
+
+	signatures := tx.signatureHashes()
+	tx.MsgTx.TxIn[index].Sequence = sequence
+	tx.removeInvalidSignatures(signatures)
+	return nil
+}
+
+// SetInputRelativeLockTime sets the sequence of the input at the specified index to a relative
+//   lock time, from RelativeLockTimeBlocks or RelativeLockTimeSeconds, and sets the tx version to 2
+//   because relative lock times are only enforced, on chains that enforce them, for version 2 txs.
+func (tx *TxBuilder) SetInputRelativeLockTime(index int, sequence uint32) error {
+	if sequence&wire.SequenceLockTimeDisabled != 0 {
+		return errors.Wrapf(ErrInvalidLockTime, "sequence 0x%08x relative lock time disabled",
+			sequence)
+	}
+
+	if tx.MsgTx.Version < 2 {
+		signatures := tx.signatureHashes()
+		tx.MsgTx.Version = 2
+		tx.removeInvalidSignatures(signatures)
+	}
+
+	return tx.SetInputSequence(index, sequence)
+}
+
+// RelativeLockTimeBlocks returns an input sequence that locks the input until the output being
+//   spent has the specified number of confirmations.
+func RelativeLockTimeBlocks(blocks uint16) uint32 {
+	return uint32(blocks)
+}
+
+// RelativeLockTimeSeconds returns an input sequence that locks the input until the specified time
+//   after the output being spent was mined. Seconds are rounded up to a multiple of 512.
+func RelativeLockTimeSeconds(seconds uint32) (uint32, error) {
+	if seconds > MaxRelativeLockTimeSeconds {
+		return 0, errors.Wrapf(ErrInvalidLockTime, "%d seconds more than max %d", seconds,
+			MaxRelativeLockTimeSeconds)
+	}
+
+	granularity := uint32(1) << wire.SequenceLockTimeGranularity
+	units := (seconds + granularity - 1) >> wire.SequenceLockTimeGranularity
+	return wire.SequenceLockTimeIsSeconds | units, nil
+}
+
+// IsFinal returns true if the tx can be mined in a block at the height with the time.
+func (tx *TxBuilder) IsFinal(height uint32, blockTime time.Time) bool {
+	return IsFinalTx(tx.MsgTx, height, blockTime)
+}
+
+// IsFinalTx returns true if the tx can be mined in a block at the height with the time. It doesn't
+//   check relative lock times.
+func IsFinalTx(tx *wire.MsgTx, height uint32, blockTime time.Time) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	var limit int64
+	if tx.LockTime < LockTimeThreshold {
+		limit = int64(height)
+	} else {
+		limit = blockTime.Unix()
+	}
+
+	if int64(tx.LockTime) < limit {
+		return true
+	}
+
+	for _, txin := range tx.TxIn {
+		if txin.Sequence != wire.MaxTxInSequenceNum {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (tx *TxBuilder) allInputsFinal() bool {
+	for _, txin := range tx.MsgTx.TxIn {
+		if txin.Sequence != wire.MaxTxInSequenceNum {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package txbuilder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestLockTime(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	tx := NewTxBuilder(0.5, 1.0)
+	var outpoint wire.OutPoint
+	outpoint.Hash[0] = 1
+	if err := tx.AddInput(outpoint, lockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	if err := tx.AddOutput(lockingScript, 9000, false, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+	if err := tx.SignOnly([]bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	if !tx.IsFinal(0, time.Unix(0, 0)) {
+		t.Fatalf("Tx without lock time should be final")
+	}
+
+	if err := tx.SetLockTimeHeight(LockTimeThreshold); errors.Cause(err) != ErrInvalidLockTime {
+		t.Fatalf("Wrong error for height above threshold : %v", err)
+	}
+
+	if err := tx.SetLockTimeHeight(1000); err != nil {
+		t.Fatalf("Failed to set lock time : %s", err)
+	}
+	if tx.MsgTx.TxIn[0].Sequence != wire.MaxTxInSequenceNum-1 {
+		t.Fatalf("Sequence should be lowered : 0x%08x", tx.MsgTx.TxIn[0].Sequence)
+	}
+	if tx.InputIsSigned(0) {
+		t.Fatalf("Signature should be removed")
+	}
+
+	if tx.IsFinal(1000, time.Now()) {
+		t.Fatalf("Tx should not be final at lock time height")
+	}
+	if !tx.IsFinal(1001, time.Now()) {
+		t.Fatalf("Tx should be final after lock time height")
+	}
+
+	lockTime := time.Unix(1600000000, 0)
+	if err := tx.SetLockTimeTime(time.Unix(1000, 0)); errors.Cause(err) != ErrInvalidLockTime {
+		t.Fatalf("Wrong error for time below threshold : %v", err)
+	}
+	if err := tx.SetLockTimeTime(lockTime); err != nil {
+		t.Fatalf("Failed to set lock time : %s", err)
+	}
+	if tx.IsFinal(2000000, lockTime) {
+		t.Fatalf("Tx should not be final at lock time")
+	}
+	if !tx.IsFinal(0, lockTime.Add(time.Second)) {
+		t.Fatalf("Tx should be final after lock time")
+	}
+
+	// Final sequence disables the lock time.
+	if err := tx.SetInputSequence(0, wire.MaxTxInSequenceNum); err != nil {
+		t.Fatalf("Failed to set sequence : %s", err)
+	}
+	if !tx.IsFinal(0, lockTime) {
+		t.Fatalf("Tx with final inputs should be final")
+	}
+}
+
+func TestRelativeLockTime(t *testing.T) {
+	if sequence := RelativeLockTimeBlocks(144); sequence != 144 {
+		t.Fatalf("Wrong block sequence : got %d, want 144", sequence)
+	}
+
+	sequence, err := RelativeLockTimeSeconds(1000)
+	if err != nil {
+		t.Fatalf("Failed to encode seconds : %s", err)
+	}
+	if sequence != wire.SequenceLockTimeIsSeconds|2 {
+		t.Fatalf("Wrong seconds sequence : got 0x%08x, want 0x%08x", sequence,
+			wire.SequenceLockTimeIsSeconds|2)
+	}
+
+	if _, err := RelativeLockTimeSeconds(MaxRelativeLockTimeSeconds + 1); errors.Cause(err) !=
+		ErrInvalidLockTime {
+		t.Fatalf("Wrong error for too many seconds : %v", err)
+	}
+
+	tx := NewTxBuilder(0.5, 1.0)
+	var outpoint wire.OutPoint
+	tx.MsgTx.AddTxIn(wire.NewTxIn(&outpoint, nil))
+	tx.Inputs = append(tx.Inputs, &InputSupplement{})
+
+	if err := tx.SetInputRelativeLockTime(0, sequence); err != nil {
+		t.Fatalf("Failed to set relative lock time : %s", err)
+	}
+	if tx.MsgTx.Version != 2 {
+		t.Fatalf("Wrong version : got %d, want 2", tx.MsgTx.Version)
+	}
+	if tx.MsgTx.TxIn[0].Sequence != sequence {
+		t.Fatalf("Wrong sequence : got 0x%08x, want 0x%08x", tx.MsgTx.TxIn[0].Sequence, sequence)
+	}
+
+	if err := tx.SetInputRelativeLockTime(0, wire.MaxTxInSequenceNum); errors.Cause(err) !=
+		ErrInvalidLockTime {
+		t.Fatalf("Wrong error for disabled relative lock time : %v", err)
+	}
+}
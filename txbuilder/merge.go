@@ -0,0 +1,204 @@
+package txbuilder
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrIncompatibleTx means that partial txs can't be merged because they have different versions
+	//   or lock times.
+	ErrIncompatibleTx = errors.New("Incompatible Tx")
+)
+
+// Merge adds the inputs and outputs of another partially built tx to this tx so that multiple
+//   parties can fund and receive from the same tx. The other tx's inputs and outputs are appended
+//   after this tx's.
+//
+// Signatures that are no longer valid after the merge, because their sig hash type covers inputs
+//   or outputs that changed, are removed. Use UnsignedInputs to find the inputs that need to be
+//   signed again.
+func (tx *TxBuilder) Merge(other *TxBuilder) error {
+	if tx.MsgTx.Version != other.MsgTx.Version {
+		return errors.Wrapf(ErrIncompatibleTx, "version %d != %d", tx.MsgTx.Version,
+			other.MsgTx.Version)
+	}
+	if tx.MsgTx.LockTime != other.MsgTx.LockTime {
+		return errors.Wrapf(ErrIncompatibleTx, "lock time %d != %d", tx.MsgTx.LockTime,
+			other.MsgTx.LockTime)
+	}
+	if len(other.Inputs) != len(other.MsgTx.TxIn) {
+		return errors.Wrap(ErrMissingInputData, "other tx")
+	}
+
+	for _, otherInput := range other.MsgTx.TxIn {
+		for _, input := range tx.MsgTx.TxIn {
+			if input.PreviousOutPoint.Hash.Equal(&otherInput.PreviousOutPoint.Hash) &&
+				input.PreviousOutPoint.Index == otherInput.PreviousOutPoint.Index {
+				return errors.Wrapf(ErrDuplicateInput, "%s:%d", input.PreviousOutPoint.Hash,
+					input.PreviousOutPoint.Index)
+			}
+		}
+	}
+
+	signatures := tx.signatureHashes()
+	for txin, hash := range other.signatureHashes() {
+		signatures[txin] = hash
+	}
+
+	for i, txin := range other.MsgTx.TxIn {
+		input := *other.Inputs[i]
+		tx.Inputs = append(tx.Inputs, &input)
+		tx.MsgTx.AddTxIn(txin)
+	}
+
+	for i, txout := range other.MsgTx.TxOut {
+		output := *other.Outputs[i]
+		tx.Outputs = append(tx.Outputs, &output)
+		tx.MsgTx.AddTxOut(txout)
+	}
+
+	tx.removeInvalidSignatures(signatures)
+	return nil
+}
+
+// SortBIP69 sorts the inputs and outputs of the tx as specified by BIP-0069 so that the order
+//   doesn't reveal which inputs and outputs belong to which party. Signatures that are no longer
+//   valid after the sort are removed.
+func (tx *TxBuilder) SortBIP69() {
+	signatures := tx.signatureHashes()
+
+	sort.Sort(bip69Inputs{tx})
+	sort.Sort(bip69Outputs{tx})
+
+	tx.removeInvalidSignatures(signatures)
+}
+
+// UnsignedInputs returns the indexes of the inputs that don't have unlocking scripts.
+func (tx *TxBuilder) UnsignedInputs() []int {
+	var result []int
+	for i, input := range tx.MsgTx.TxIn {
+		if len(input.UnlockingScript) == 0 {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// signatureHashes returns the hashes signed by each signed input.
+func (tx *TxBuilder) signatureHashes() map[*wire.TxIn]*bitcoin.Hash32 {
+	result := make(map[*wire.TxIn]*bitcoin.Hash32)
+	hashCache := &SigHashCache{}
+	for index, txin := range tx.MsgTx.TxIn {
+		if len(txin.UnlockingScript) == 0 {
+			continue
+		}
+
+		result[txin] = tx.signatureHash(index, hashCache)
+	}
+
+	return result
+}
+
+// signatureHash returns the hash signed by the input, or nil if it can't be determined.
+func (tx *TxBuilder) signatureHash(index int, hashCache *SigHashCache) *bitcoin.Hash32 {
+	if index >= len(tx.Inputs) {
+		return nil
+	}
+
+	// The signature is the first push of P2PKH and P2PK unlocking scripts. The last byte of the
+	//   signature is the sig hash type.
+	_, signature, err := bitcoin.ParsePushDataScript(bytes.NewReader(
+		tx.MsgTx.TxIn[index].UnlockingScript))
+	if err != nil || len(signature) == 0 {
+		return nil
+	}
+	hashType := SigHashType(signature[len(signature)-1])
+
+	hash, err := SignatureHash(tx.MsgTx, index, tx.Inputs[index].LockingScript,
+		tx.Inputs[index].Value, hashType, hashCache)
+	if err != nil {
+		return nil
+	}
+
+	return hash
+}
+
+// removeInvalidSignatures removes the unlocking scripts of inputs whose signature hashes have
+//   changed. Unlocking scripts whose signature hashes can't be determined are also removed.
+func (tx *TxBuilder) removeInvalidSignatures(previous map[*wire.TxIn]*bitcoin.Hash32) {
+	hashCache := &SigHashCache{}
+	for index, txin := range tx.MsgTx.TxIn {
+		previousHash, exists := previous[txin]
+		if !exists {
+			continue
+		}
+
+		if previousHash != nil {
+			hash := tx.signatureHash(index, hashCache)
+			if hash != nil && hash.Equal(previousHash) {
+				continue // signature is still valid
+			}
+		}
+
+		txin.UnlockingScript = nil
+	}
+}
+
+// bip69Inputs sorts inputs by previous tx hash, in the reversed byte order it is displayed in,
+//   and then by previous output index.
+type bip69Inputs struct {
+	tx *TxBuilder
+}
+
+func (s bip69Inputs) Len() int {
+	return len(s.tx.MsgTx.TxIn)
+}
+
+func (s bip69Inputs) Swap(i, j int) {
+	s.tx.MsgTx.TxIn[i], s.tx.MsgTx.TxIn[j] = s.tx.MsgTx.TxIn[j], s.tx.MsgTx.TxIn[i]
+	s.tx.Inputs[i], s.tx.Inputs[j] = s.tx.Inputs[j], s.tx.Inputs[i]
+}
+
+func (s bip69Inputs) Less(i, j int) bool {
+	left := s.tx.MsgTx.TxIn[i].PreviousOutPoint
+	right := s.tx.MsgTx.TxIn[j].PreviousOutPoint
+
+	for b := bitcoin.Hash32Size - 1; b >= 0; b-- {
+		if left.Hash[b] != right.Hash[b] {
+			return left.Hash[b] < right.Hash[b]
+		}
+	}
+
+	return left.Index < right.Index
+}
+
+// bip69Outputs sorts outputs by value and then by locking script.
+type bip69Outputs struct {
+	tx *TxBuilder
+}
+
+func (s bip69Outputs) Len() int {
+	return len(s.tx.MsgTx.TxOut)
+}
+
+func (s bip69Outputs) Swap(i, j int) {
+	s.tx.MsgTx.TxOut[i], s.tx.MsgTx.TxOut[j] = s.tx.MsgTx.TxOut[j], s.tx.MsgTx.TxOut[i]
+	s.tx.Outputs[i], s.tx.Outputs[j] = s.tx.Outputs[j], s.tx.Outputs[i]
+}
+
+func (s bip69Outputs) Less(i, j int) bool {
+	left := s.tx.MsgTx.TxOut[i]
+	right := s.tx.MsgTx.TxOut[j]
+
+	if left.Value != right.Value {
+		return left.Value < right.Value
+	}
+
+	return bytes.Compare(left.LockingScript, right.LockingScript) < 0
+}
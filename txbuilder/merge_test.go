@@ -0,0 +1,166 @@
+package txbuilder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestMerge(t *testing.T) {
+	payerKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	payerLockingScript, err := payerKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	payeeKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	payeeLockingScript, err := payeeKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	// Payer signs all inputs and outputs.
+	payer := NewTxBuilder(1.0, 1.0)
+	payerOutPoint := wire.OutPoint{Index: 1}
+	payerOutPoint.Hash[31] = 0x10
+	if err := payer.AddInput(payerOutPoint, payerLockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	payer.MsgTx.AddTxOut(wire.NewTxOut(9000, payeeLockingScript))
+	payer.Outputs = append(payer.Outputs, &OutputSupplement{})
+	if err := payer.SignP2PKHInput(0, payerKey, &SigHashCache{}); err != nil {
+		t.Fatalf("Failed to sign input : %s", err)
+	}
+
+	// Payee only signs its own input and output, so its signature survives the merge.
+	payee := NewTxBuilder(1.0, 1.0)
+	payeeOutPoint := wire.OutPoint{Index: 0}
+	payeeOutPoint.Hash[31] = 0x01
+	if err := payee.AddInput(payeeOutPoint, payeeLockingScript, 5000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	payee.MsgTx.AddTxOut(wire.NewTxOut(4500, payeeLockingScript))
+	payee.Outputs = append(payee.Outputs, &OutputSupplement{})
+	payee.MsgTx.TxIn[0].UnlockingScript, err = P2PKHUnlockingScript(payeeKey, payee.MsgTx, 0,
+		payeeLockingScript, 5000, SigHashSingle+SigHashAnyOneCanPay+SigHashForkID, &SigHashCache{})
+	if err != nil {
+		t.Fatalf("Failed to sign input : %s", err)
+	}
+	payeeSignature := payee.MsgTx.TxIn[0].UnlockingScript
+
+	if err := payer.Merge(payee); err != nil {
+		t.Fatalf("Failed to merge : %s", err)
+	}
+
+	if len(payer.MsgTx.TxIn) != 2 || len(payer.Inputs) != 2 {
+		t.Fatalf("Wrong input count : %d", len(payer.MsgTx.TxIn))
+	}
+	if len(payer.MsgTx.TxOut) != 2 || len(payer.Outputs) != 2 {
+		t.Fatalf("Wrong output count : %d", len(payer.MsgTx.TxOut))
+	}
+
+	unsigned := payer.UnsignedInputs()
+	if len(unsigned) != 1 || unsigned[0] != 0 {
+		t.Fatalf("Wrong unsigned inputs : got %v, want [0]", unsigned)
+	}
+	if !bytes.Equal(payer.MsgTx.TxIn[1].UnlockingScript, payeeSignature) {
+		t.Fatalf("Payee signature should not be removed")
+	}
+
+	if err := payer.Merge(payee); errors.Cause(err) != ErrDuplicateInput {
+		t.Fatalf("Wrong merge error : got %v, want %s", err, ErrDuplicateInput)
+	}
+
+	other := NewTxBuilder(1.0, 1.0)
+	other.MsgTx.LockTime = 100
+	if err := payer.Merge(other); errors.Cause(err) != ErrIncompatibleTx {
+		t.Fatalf("Wrong merge error : got %v, want %s", err, ErrIncompatibleTx)
+	}
+
+	// Sorting moves the payee input and output both to index 0, so the SigHashSingle signature is
+	//   still valid.
+	payer.SortBIP69()
+
+	if !payer.MsgTx.TxIn[0].PreviousOutPoint.Hash.Equal(&payeeOutPoint.Hash) {
+		t.Fatalf("Wrong first input : %s", payer.MsgTx.TxIn[0].PreviousOutPoint.Hash)
+	}
+	if !bytes.Equal(payer.MsgTx.TxIn[0].UnlockingScript, payeeSignature) {
+		t.Fatalf("Payee signature should not be removed by sort")
+	}
+	if payer.Inputs[0].Value != 5000 {
+		t.Fatalf("Input supplement not sorted : %d", payer.Inputs[0].Value)
+	}
+	if payer.MsgTx.TxOut[0].Value != 4500 {
+		t.Fatalf("Wrong first output : %d", payer.MsgTx.TxOut[0].Value)
+	}
+
+	if err := payer.SignP2PKHInput(1, payerKey, &SigHashCache{}); err != nil {
+		t.Fatalf("Failed to sign input : %s", err)
+	}
+
+	unsigned = payer.UnsignedInputs()
+	if len(unsigned) != 0 {
+		t.Fatalf("Wrong unsigned inputs : got %v, want []", unsigned)
+	}
+}
+
+func TestSortBIP69(t *testing.T) {
+	tx := NewTxBuilder(1.0, 1.0)
+
+	hashes := []string{
+		"0e53ec5dfb2cb8a71fec32dc9a634a35b7e24799295ddd5278217822e0b31f57",
+		"26aa6e6d8b9e49bb0630aac301db6757c02e3619feb4ee0eea81eb1672947024",
+		"0e53ec5dfb2cb8a71fec32dc9a634a35b7e24799295ddd5278217822e0b31f57",
+	}
+	indexes := []uint32{1, 0, 0}
+	for i, h := range hashes {
+		hash, err := bitcoin.NewHash32FromStr(h)
+		if err != nil {
+			t.Fatalf("Failed to parse hash : %s", err)
+		}
+		tx.MsgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, indexes[i]), nil))
+		tx.Inputs = append(tx.Inputs, &InputSupplement{Value: uint64(i)})
+	}
+
+	tx.MsgTx.AddTxOut(wire.NewTxOut(2000, []byte{0x02}))
+	tx.MsgTx.AddTxOut(wire.NewTxOut(1000, []byte{0x01}))
+	tx.MsgTx.AddTxOut(wire.NewTxOut(2000, []byte{0x01}))
+	for range tx.MsgTx.TxOut {
+		tx.Outputs = append(tx.Outputs, &OutputSupplement{})
+	}
+
+	tx.SortBIP69()
+
+	wantValues := []uint64{2, 0, 1}
+	for i, want := range wantValues {
+		if tx.Inputs[i].Value != want {
+			t.Errorf("Wrong input %d : got %d, want %d", i, tx.Inputs[i].Value, want)
+		}
+	}
+
+	wantOutputs := []struct {
+		value  uint64
+		script []byte
+	}{
+		{1000, []byte{0x01}},
+		{2000, []byte{0x01}},
+		{2000, []byte{0x02}},
+	}
+	for i, want := range wantOutputs {
+		if tx.MsgTx.TxOut[i].Value != want.value ||
+			!bytes.Equal(tx.MsgTx.TxOut[i].LockingScript, want.script) {
+			t.Errorf("Wrong output %d : got %d %x", i, tx.MsgTx.TxOut[i].Value,
+				tx.MsgTx.TxOut[i].LockingScript)
+		}
+	}
+}
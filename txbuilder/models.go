@@ -12,6 +12,9 @@ type InputSupplement struct {
 
 	// Optional identifier for external use to track the key needed to sign the input.
 	KeyID string `json:"key_id,omitempty"`
+
+	// Optional sig hash type used to sign the input. Zero means SigHashAll+SigHashForkID.
+	SigHashType SigHashType `json:"sig_hash_type,omitempty"`
 }
 
 // AddressKeyID is an address and a key ID.
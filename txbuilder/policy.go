@@ -0,0 +1,149 @@
+package txbuilder
+
+import (
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTxTooLarge means the tx is larger than the policy's maximum tx size.
+	ErrTxTooLarge = errors.New("Tx Too Large")
+
+	// ErrNonFinal means the tx's lock time prevents it from being mined in the next block.
+	ErrNonFinal = errors.New("Non Final")
+
+	// ErrFeeTooLow means the tx pays less than the policy's minimum fee rate and doesn't qualify as
+	//   a consolidation tx.
+	ErrFeeTooLow = errors.New("Fee Too Low")
+)
+
+// Policy contains the node policies that a tx is checked against before it is broadcast. Zero
+//   values disable a check.
+type Policy struct {
+	// MaxTxSize is the largest tx size in bytes that nodes will accept.
+	MaxTxSize int
+
+	// MaxDataSize is the largest total size in bytes of the locking scripts of OP_RETURN outputs.
+	MaxDataSize int
+
+	// DustFeeRate is the fee rate used to calculate the dust limits of spendable outputs.
+	DustFeeRate float32
+
+	// MinFeeRate is the lowest fee rate in sat/byte that nodes will accept for txs that aren't
+	//   consolidation txs.
+	MinFeeRate float32
+
+	// MinConsolidationFactor is the ratio of inputs to outputs, and of input locking script bytes
+	//   to output locking script bytes, at which nodes accept a tx that pays less than MinFeeRate
+	//   as a consolidation tx. Zero means low fee txs are never accepted.
+	MinConsolidationFactor int
+}
+
+// DefaultPolicy returns the default policies of BSV nodes.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxTxSize:              10000000,
+		DustFeeRate:            1.0,
+		MinFeeRate:             0.5,
+		MinConsolidationFactor: 20,
+	}
+}
+
+// Validate checks the tx against the policy and returns all of the violations, so txs that nodes
+//   will reject are found before they are broadcast. height and blockTime are those of the next
+//   block and are used to check the lock time. Unsigned inputs are assumed to have unlocking
+//   scripts of the maximum size for their templates.
+// The cause of each violation is ErrTxTooLarge, ErrDataTooLarge, ErrBelowDustValue, ErrNonFinal,
+//   ErrFeeTooLow, or ErrMissingInputData.
+func (tx *TxBuilder) Validate(policy Policy, height uint32, blockTime time.Time) []error {
+	var result []error
+
+	size := tx.validationSize()
+	if policy.MaxTxSize > 0 && size > policy.MaxTxSize {
+		result = append(result, errors.Wrapf(ErrTxTooLarge, "%d bytes, max %d", size,
+			policy.MaxTxSize))
+	}
+
+	if policy.MaxDataSize > 0 {
+		if dataSize := DataSize(tx.MsgTx); dataSize > policy.MaxDataSize {
+			result = append(result, errors.Wrapf(ErrDataTooLarge, "%d bytes, max %d", dataSize,
+				policy.MaxDataSize))
+		}
+	}
+
+	if policy.DustFeeRate > 0 {
+		for index, output := range tx.MsgTx.TxOut {
+			if bitcoin.LockingScriptIsUnspendable(output.LockingScript) {
+				continue
+			}
+
+			dust := DustLimitForOutput(output, policy.DustFeeRate)
+			if output.Value < dust {
+				result = append(result, errors.Wrapf(ErrBelowDustValue,
+					"output %d: value %d, dust limit %d", index, output.Value, dust))
+			}
+		}
+	}
+
+	if !IsFinalTx(tx.MsgTx, height, blockTime) {
+		result = append(result, errors.Wrapf(ErrNonFinal, "lock time %d", tx.MsgTx.LockTime))
+	}
+
+	if len(tx.Inputs) != len(tx.MsgTx.TxIn) {
+		result = append(result, errors.Wrapf(ErrMissingInputData, "%d inputs, %d supplements",
+			len(tx.MsgTx.TxIn), len(tx.Inputs)))
+		return result // fee can't be calculated
+	}
+
+	if policy.MinFeeRate > 0 {
+		fee := tx.Fee()
+		minFee := uint64(float32(size) * policy.MinFeeRate)
+		if fee < minFee && !tx.IsConsolidation(policy.MinConsolidationFactor) {
+			result = append(result, errors.Wrapf(ErrFeeTooLow, "fee %d, min %d", fee, minFee))
+		}
+	}
+
+	return result
+}
+
+// IsConsolidation returns true if the tx meets the consolidation factor, so nodes will accept it
+//   without the minimum fee. Nodes also require the inputs to have a minimum number of
+//   confirmations, which isn't checked.
+func (tx *TxBuilder) IsConsolidation(factor int) bool {
+	if factor <= 0 || len(tx.MsgTx.TxOut) == 0 || len(tx.Inputs) != len(tx.MsgTx.TxIn) {
+		return false
+	}
+
+	if len(tx.MsgTx.TxIn) < factor*len(tx.MsgTx.TxOut) {
+		return false
+	}
+
+	inputScriptSize := 0
+	for _, input := range tx.Inputs {
+		inputScriptSize += len(input.LockingScript)
+	}
+
+	outputScriptSize := 0
+	for _, output := range tx.MsgTx.TxOut {
+		outputScriptSize += len(output.LockingScript)
+	}
+
+	return inputScriptSize >= factor*outputScriptSize
+}
+
+// validationSize returns the actual size of the tx if it is fully signed, otherwise the estimated
+//   size after it is signed.
+func (tx *TxBuilder) validationSize() int {
+	if tx.AllInputsAreSigned() {
+		return tx.MsgTx.SerializeSize()
+	}
+
+	size, err := tx.EstimateSize()
+	if err != nil {
+		return tx.EstimatedSize()
+	}
+	return size
+}
@@ -0,0 +1,127 @@
+package txbuilder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	policy := DefaultPolicy()
+	dust := DustLimitForLockingScript(lockingScript, policy.DustFeeRate)
+
+	tx := NewTxBuilder(0.5, 1.0)
+	var outpoint wire.OutPoint
+	outpoint.Hash[0] = 1
+	if err := tx.AddInput(outpoint, lockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	if err := tx.AddOutput(lockingScript, 9000, false, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+	if err := tx.AddDataOutput([]byte("test data")); err != nil {
+		t.Fatalf("Failed to add data output : %s", err)
+	}
+	if err := tx.SignOnly([]bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	if violations := tx.Validate(policy, 1000, time.Now()); len(violations) != 0 {
+		t.Fatalf("Valid tx should have no violations : %v", violations)
+	}
+
+	// Break every policy.
+	tx.MsgTx.TxOut[0].Value = dust - 1
+	tx.MsgTx.TxOut = append(tx.MsgTx.TxOut, wire.NewTxOut(10000, lockingScript))
+	tx.MsgTx.LockTime = 2000
+	tx.MsgTx.TxIn[0].Sequence = 0
+
+	policy.MaxTxSize = 100
+	policy.MaxDataSize = 5
+
+	violations := tx.Validate(policy, 1000, time.Now())
+	wantCauses := []error{ErrTxTooLarge, ErrDataTooLarge, ErrBelowDustValue, ErrNonFinal,
+		ErrFeeTooLow}
+	if len(violations) != len(wantCauses) {
+		t.Fatalf("Wrong violation count : got %d, want %d : %v", len(violations),
+			len(wantCauses), violations)
+	}
+
+	for i, violation := range violations {
+		t.Logf("Violation : %s", violation)
+		if errors.Cause(violation) != wantCauses[i] {
+			t.Errorf("Wrong violation %d : got %s, want %s", i, violation, wantCauses[i])
+		}
+	}
+
+	tx.Inputs = nil
+	violations = tx.Validate(policy, 1000, time.Now())
+	if errors.Cause(violations[len(violations)-1]) != ErrMissingInputData {
+		t.Fatalf("Wrong last violation : got %s, want %s", violations[len(violations)-1],
+			ErrMissingInputData)
+	}
+}
+
+func TestValidatePolicyConsolidation(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	policy := DefaultPolicy()
+
+	tx := NewTxBuilder(0.5, 1.0)
+	for i := 0; i < policy.MinConsolidationFactor-1; i++ {
+		var outpoint wire.OutPoint
+		outpoint.Hash[0] = byte(i + 1)
+		if err := tx.AddInput(outpoint, lockingScript, 1000); err != nil {
+			t.Fatalf("Failed to add input : %s", err)
+		}
+	}
+
+	// Pay no fee.
+	value := uint64(1000 * (policy.MinConsolidationFactor - 1))
+	if err := tx.AddOutput(lockingScript, value, false, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+
+	if tx.IsConsolidation(policy.MinConsolidationFactor) {
+		t.Fatalf("Tx with too few inputs should not be a consolidation")
+	}
+
+	violations := tx.Validate(policy, 1000, time.Now())
+	if len(violations) != 1 || errors.Cause(violations[0]) != ErrFeeTooLow {
+		t.Fatalf("Low fee tx should violate fee policy : %v", violations)
+	}
+
+	var outpoint wire.OutPoint
+	outpoint.Hash[0] = byte(policy.MinConsolidationFactor)
+	if err := tx.AddInput(outpoint, lockingScript, 0); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+
+	if !tx.IsConsolidation(policy.MinConsolidationFactor) {
+		t.Fatalf("Tx should be a consolidation")
+	}
+
+	if violations := tx.Validate(policy, 1000, time.Now()); len(violations) != 0 {
+		t.Fatalf("Consolidation tx should have no violations : %v", violations)
+	}
+}
@@ -0,0 +1,196 @@
+package txbuilder
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUTXOReserved means that a UTXO is reserved by another tx or already spent.
+	ErrUTXOReserved = errors.New("UTXO Reserved")
+)
+
+// UTXOReserver prevents the same UTXO from being selected by more than one tx builder at the same
+//   time, so that concurrent builders in one wallet don't create double spends.
+type UTXOReserver interface {
+	// ReserveUTXO reserves the UTXO for a tx. It returns ErrUTXOReserved if the UTXO is already
+	//   reserved or spent.
+	ReserveUTXO(utxo bitcoin.UTXO) error
+
+	// ReleaseUTXO releases a reservation so the UTXO can be selected again.
+	ReleaseUTXO(outpoint wire.OutPoint) error
+
+	// SpentUTXO is called when a tx spending the reserved UTXO is complete so it is never selected
+	//   again.
+	SpentUTXO(outpoint wire.OutPoint) error
+}
+
+// SetUTXOReserver sets the reserver that UTXOs are reserved with when they are selected by
+//   AddFunding and AddFundingBreakChange. UTXOs that are already reserved are skipped.
+func (tx *TxBuilder) SetUTXOReserver(reserver UTXOReserver) {
+	tx.UTXOReserver = reserver
+}
+
+// ReleaseInputs releases the reservations of the UTXOs selected by this builder. It should be
+//   called when the tx is abandoned, for example when funding fails.
+func (tx *TxBuilder) ReleaseInputs() error {
+	if tx.UTXOReserver == nil {
+		return nil
+	}
+
+	for outpoint := range tx.reserved {
+		if err := tx.UTXOReserver.ReleaseUTXO(outpoint); err != nil {
+			return errors.Wrapf(err, "release %s", outpoint)
+		}
+		delete(tx.reserved, outpoint)
+	}
+
+	return nil
+}
+
+// MarkInputsSpent tells the reserver that the UTXOs selected by this builder are spent. It should
+//   be called when the tx is complete and sent.
+func (tx *TxBuilder) MarkInputsSpent() error {
+	if tx.UTXOReserver == nil {
+		return nil
+	}
+
+	for outpoint := range tx.reserved {
+		if err := tx.UTXOReserver.SpentUTXO(outpoint); err != nil {
+			return errors.Wrapf(err, "spent %s", outpoint)
+		}
+		delete(tx.reserved, outpoint)
+	}
+
+	return nil
+}
+
+// addFundingInput reserves the UTXO, if there is a reserver, and adds it as an input.
+func (tx *TxBuilder) addFundingInput(utxo bitcoin.UTXO) error {
+	if tx.UTXOReserver == nil {
+		return tx.AddInputUTXO(utxo)
+	}
+
+	outpoint := wire.OutPoint{Hash: utxo.Hash, Index: utxo.Index}
+	if _, exists := tx.reserved[outpoint]; exists {
+		return errors.Wrapf(ErrDuplicateInput, "%d %s", utxo.Index, utxo.Hash)
+	}
+
+	if err := tx.UTXOReserver.ReserveUTXO(utxo); err != nil {
+		return errors.Wrap(err, "reserve")
+	}
+
+	if err := tx.AddInputUTXO(utxo); err != nil {
+		tx.UTXOReserver.ReleaseUTXO(outpoint)
+		return err
+	}
+
+	if tx.reserved == nil {
+		tx.reserved = make(map[wire.OutPoint]bool)
+	}
+	tx.reserved[outpoint] = true
+	return nil
+}
+
+// releaseInput releases the reservation of an input that is removed from the tx.
+func (tx *TxBuilder) releaseInput(outpoint wire.OutPoint) error {
+	if tx.UTXOReserver == nil {
+		return nil
+	}
+
+	if _, exists := tx.reserved[outpoint]; !exists {
+		return nil
+	}
+
+	delete(tx.reserved, outpoint)
+	return tx.UTXOReserver.ReleaseUTXO(outpoint)
+}
+
+// UTXOReservations is an in memory UTXOReserver that can be shared by the tx builders in a
+//   process. Reservations that are not released or spent expire after the timeout so UTXOs aren't
+//   lost when a builder is abandoned.
+type UTXOReservations struct {
+	timeout  time.Duration
+	reserved map[wire.OutPoint]time.Time
+	spent    map[wire.OutPoint]bool
+
+	lock sync.Mutex
+}
+
+// NewUTXOReservations creates an in memory UTXO reserver. A timeout of zero means reservations
+//   don't expire.
+func NewUTXOReservations(timeout time.Duration) *UTXOReservations {
+	return &UTXOReservations{
+		timeout:  timeout,
+		reserved: make(map[wire.OutPoint]time.Time),
+		spent:    make(map[wire.OutPoint]bool),
+	}
+}
+
+// ReserveUTXO reserves the UTXO. It returns ErrUTXOReserved if the UTXO is already reserved or
+//   spent.
+func (r *UTXOReservations) ReserveUTXO(utxo bitcoin.UTXO) error {
+	outpoint := wire.OutPoint{Hash: utxo.Hash, Index: utxo.Index}
+	now := time.Now()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.spent[outpoint] {
+		return errors.Wrapf(ErrUTXOReserved, "spent %s", outpoint)
+	}
+
+	if reservedAt, exists := r.reserved[outpoint]; exists &&
+		(r.timeout == 0 || now.Sub(reservedAt) < r.timeout) {
+		return errors.Wrapf(ErrUTXOReserved, "%s", outpoint)
+	}
+
+	r.reserved[outpoint] = now
+	return nil
+}
+
+// ReleaseUTXO releases a reservation so the UTXO can be selected again.
+func (r *UTXOReservations) ReleaseUTXO(outpoint wire.OutPoint) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.reserved, outpoint)
+	return nil
+}
+
+// SpentUTXO marks the UTXO as spent so it is never reserved again.
+func (r *UTXOReservations) SpentUTXO(outpoint wire.OutPoint) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.reserved, outpoint)
+	r.spent[outpoint] = true
+	return nil
+}
+
+// IsReserved returns true if the UTXO is currently reserved or spent.
+func (r *UTXOReservations) IsReserved(outpoint wire.OutPoint) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.spent[outpoint] {
+		return true
+	}
+
+	reservedAt, exists := r.reserved[outpoint]
+	return exists && (r.timeout == 0 || time.Since(reservedAt) < r.timeout)
+}
+
+// RemoveSpent forgets a spent UTXO, for example when the tx spending it is dropped by a reorg or
+//   never accepted.
+func (r *UTXOReservations) RemoveSpent(outpoint wire.OutPoint) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.spent, outpoint)
+}
@@ -0,0 +1,133 @@
+package txbuilder
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestUTXOReserverConcurrent(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	address, err := bitcoin.RawAddressFromLockingScript(lockingScript)
+	if err != nil {
+		t.Fatalf("Failed to create address : %s", err)
+	}
+
+	var utxos []bitcoin.UTXO
+	for i := 0; i < 40; i++ {
+		utxos = append(utxos, bitcoin.UTXO{
+			Hash:          bitcoin.Hash32{byte(i)},
+			Index:         uint32(i),
+			Value:         10000,
+			LockingScript: lockingScript,
+		})
+	}
+
+	reserver := NewUTXOReservations(0)
+
+	builderCount := 10
+	txs := make([]*TxBuilder, builderCount)
+	errs := make([]error, builderCount)
+	var wait sync.WaitGroup
+	for i := 0; i < builderCount; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+
+			tx := NewTxBuilder(0.5, 1.0)
+			tx.SetUTXOReserver(reserver)
+			tx.SetChangeAddress(address, "")
+
+			if err := tx.AddPaymentOutput(address, 25000, false); err != nil {
+				errs[i] = err
+				return
+			}
+
+			errs[i] = tx.AddFunding(utxos)
+			txs[i] = tx
+		}(i)
+	}
+	wait.Wait()
+
+	used := make(map[wire.OutPoint]int)
+	for i, tx := range txs {
+		if errs[i] != nil {
+			t.Fatalf("Failed to fund tx %d : %s", i, errs[i])
+		}
+
+		for _, txin := range tx.MsgTx.TxIn {
+			if previous, exists := used[txin.PreviousOutPoint]; exists {
+				t.Fatalf("UTXO %s used by tx %d and %d", txin.PreviousOutPoint, previous, i)
+			}
+			used[txin.PreviousOutPoint] = i
+		}
+	}
+
+	// Release one tx and spend another.
+	released := txs[0].MsgTx.TxIn[0].PreviousOutPoint
+	if err := txs[0].ReleaseInputs(); err != nil {
+		t.Fatalf("Failed to release inputs : %s", err)
+	}
+	if reserver.IsReserved(released) {
+		t.Fatalf("Released UTXO still reserved")
+	}
+
+	spent := txs[1].MsgTx.TxIn[0].PreviousOutPoint
+	if err := txs[1].MarkInputsSpent(); err != nil {
+		t.Fatalf("Failed to mark inputs spent : %s", err)
+	}
+	if err := txs[1].ReleaseInputs(); err != nil {
+		t.Fatalf("Failed to release spent inputs : %s", err)
+	}
+	if !reserver.IsReserved(spent) {
+		t.Fatalf("Spent UTXO not reserved")
+	}
+
+	for _, utxo := range utxos {
+		if utxo.Hash.Equal(&spent.Hash) && utxo.Index == spent.Index {
+			if err := reserver.ReserveUTXO(utxo); errors.Cause(err) != ErrUTXOReserved {
+				t.Fatalf("Wrong error reserving spent UTXO : %v", err)
+			}
+		}
+	}
+
+	// Removing an input releases its reservation.
+	removed := txs[2].MsgTx.TxIn[0].PreviousOutPoint
+	if err := txs[2].RemoveInput(0); err != nil {
+		t.Fatalf("Failed to remove input : %s", err)
+	}
+	if reserver.IsReserved(removed) {
+		t.Fatalf("Removed input still reserved")
+	}
+}
+
+func TestUTXOReservationsTimeout(t *testing.T) {
+	reserver := NewUTXOReservations(10 * time.Millisecond)
+	utxo := bitcoin.UTXO{Hash: bitcoin.Hash32{1}, Index: 1}
+
+	if err := reserver.ReserveUTXO(utxo); err != nil {
+		t.Fatalf("Failed to reserve : %s", err)
+	}
+
+	if err := reserver.ReserveUTXO(utxo); errors.Cause(err) != ErrUTXOReserved {
+		t.Fatalf("Wrong error reserving twice : %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if err := reserver.ReserveUTXO(utxo); err != nil {
+		t.Fatalf("Failed to reserve after timeout : %s", err)
+	}
+}
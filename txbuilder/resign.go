@@ -0,0 +1,117 @@
+package txbuilder
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+// InvalidatedInputs returns the indexes of the inputs that have unlocking scripts with signatures
+//   that are no longer valid for the tx. A signature is invalidated when an input or output that
+//   its sig hash type covers is added, removed, or modified. For example a signature with
+//   SigHashAll is invalidated by any change to the outputs, but a signature with
+//   SigHashSingle+SigHashAnyOneCanPay is only invalidated by changes to its own input and the
+//   output at the same index.
+// Unlocking scripts that can't be verified, because they aren't P2PKH or P2PK, are not included.
+func (tx *TxBuilder) InvalidatedInputs() []int {
+	var result []int
+	hashCache := &SigHashCache{}
+	for index, txin := range tx.MsgTx.TxIn {
+		if len(txin.UnlockingScript) == 0 {
+			continue
+		}
+
+		valid, err := tx.signatureIsValid(index, hashCache)
+		if err != nil {
+			continue // unknown unlocking script
+		}
+
+		if !valid {
+			result = append(result, index)
+		}
+	}
+
+	return result
+}
+
+// ResignInvalidated signs the inputs whose signatures were invalidated by changes to the tx since
+//   they were signed, and returns their indexes. Inputs with valid signatures are not signed
+//   again, so iterative build flows only need signatures for the inputs affected by each change.
+// Like SignOnly, it does not adjust the fee or make any other modifications to the tx.
+func (tx *TxBuilder) ResignInvalidated(signer bitcoin.Signer) ([]int, error) {
+	invalidated := tx.InvalidatedInputs()
+
+	shc := SigHashCache{}
+	for _, index := range invalidated {
+		if err := tx.signInput(index, signer, &shc); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("sign input %d", index))
+		}
+	}
+
+	return invalidated, nil
+}
+
+// signatureIsValid returns true if the signature in the unlocking script of the input is valid
+//   for the current tx. It returns an error if the unlocking script isn't a P2PKH or P2PK
+//   unlocking script for the input's locking script.
+func (tx *TxBuilder) signatureIsValid(index int, hashCache *SigHashCache) (bool, error) {
+	if index >= len(tx.Inputs) {
+		return false, ErrMissingInputData
+	}
+	input := tx.Inputs[index]
+
+	address, err := bitcoin.RawAddressFromLockingScript(input.LockingScript)
+	if err != nil {
+		return false, errors.Wrap(err, "locking script")
+	}
+
+	// <Signature> <PublicKey> for P2PKH and <Signature> for P2PK
+	buf := bytes.NewReader(tx.MsgTx.TxIn[index].UnlockingScript)
+	_, sigBytes, err := bitcoin.ParsePushDataScript(buf)
+	if err != nil {
+		return false, errors.Wrap(err, "parse signature")
+	}
+	if len(sigBytes) == 0 {
+		return false, errors.Wrap(ErrWrongScriptTemplate, "empty signature")
+	}
+
+	var publicKey bitcoin.PublicKey
+	switch address.Type() {
+	case bitcoin.ScriptTypePKH:
+		_, publicKeyBytes, err := bitcoin.ParsePushDataScript(buf)
+		if err != nil {
+			return false, errors.Wrap(err, "parse public key")
+		}
+
+		publicKey, err = bitcoin.PublicKeyFromBytes(publicKeyBytes)
+		if err != nil {
+			return false, errors.Wrap(err, "public key")
+		}
+
+	case bitcoin.ScriptTypePK:
+		publicKey, err = address.GetPublicKey()
+		if err != nil {
+			return false, errors.Wrap(err, "address public key")
+		}
+
+	default:
+		return false, errors.Wrap(ErrWrongScriptTemplate, "Not a P2PKH or P2PK locking script")
+	}
+
+	hashType := SigHashType(sigBytes[len(sigBytes)-1])
+	sig, err := bitcoin.SignatureFromBytes(sigBytes[:len(sigBytes)-1])
+	if err != nil {
+		return false, nil // malformed signatures are not valid
+	}
+
+	hash, err := SignatureHash(tx.MsgTx, index, input.LockingScript, input.Value, hashType,
+		hashCache)
+	if err != nil {
+		return false, nil // the sig hash type no longer applies, like single without an output
+	}
+
+	return sig.Verify(*hash, publicKey), nil
+}
@@ -0,0 +1,104 @@
+package txbuilder
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+)
+
+func TestResignInvalidated(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+	signer := bitcoin.NewKeysSigner([]bitcoin.Key{key})
+
+	tx := NewTxBuilder(1.0, 1.0)
+	for i := 0; i < 2; i++ {
+		outpoint := wire.OutPoint{Index: uint32(i)}
+		outpoint.Hash[0] = 0x01
+		if err := tx.AddInput(outpoint, lockingScript, 10000); err != nil {
+			t.Fatalf("Failed to add input : %s", err)
+		}
+		if err := tx.AddOutput(lockingScript, 9000, false, false); err != nil {
+			t.Fatalf("Failed to add output : %s", err)
+		}
+	}
+
+	// Input 0 signs everything. Input 1 only signs itself and output 1.
+	if err := tx.SetInputSigHashType(1, SigHashSingle+SigHashAnyOneCanPay+SigHashForkID); err != nil {
+		t.Fatalf("Failed to set sig hash type : %s", err)
+	}
+
+	if err := tx.SignOnlyWithSigner(signer); err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	if invalidated := tx.InvalidatedInputs(); len(invalidated) != 0 {
+		t.Fatalf("Wrong invalidated inputs : got %v, want none", invalidated)
+	}
+
+	singleScript := tx.MsgTx.TxIn[1].UnlockingScript
+
+	// Adding an output only invalidates the signature that covers all outputs.
+	if err := tx.AddOutput(lockingScript, 1000, false, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+
+	if invalidated := tx.InvalidatedInputs(); !reflect.DeepEqual(invalidated, []int{0}) {
+		t.Fatalf("Wrong invalidated inputs : got %v, want %v", invalidated, []int{0})
+	}
+
+	resigned, err := tx.ResignInvalidated(signer)
+	if err != nil {
+		t.Fatalf("Failed to resign : %s", err)
+	}
+	if !reflect.DeepEqual(resigned, []int{0}) {
+		t.Fatalf("Wrong resigned inputs : got %v, want %v", resigned, []int{0})
+	}
+
+	if !bytes.Equal(tx.MsgTx.TxIn[1].UnlockingScript, singleScript) {
+		t.Fatalf("Valid signature should not be replaced")
+	}
+
+	// Adding an input doesn't invalidate the anyone can pay signature.
+	outpoint := wire.OutPoint{Index: 2}
+	outpoint.Hash[0] = 0x01
+	if err := tx.AddInput(outpoint, lockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+
+	if invalidated := tx.InvalidatedInputs(); !reflect.DeepEqual(invalidated, []int{0}) {
+		t.Fatalf("Wrong invalidated inputs : got %v, want %v", invalidated, []int{0})
+	}
+
+	// Modifying the output at the same index invalidates the single signature.
+	tx.MsgTx.TxOut[1].Value = 8000
+
+	if invalidated := tx.InvalidatedInputs(); !reflect.DeepEqual(invalidated, []int{0, 1}) {
+		t.Fatalf("Wrong invalidated inputs : got %v, want %v", invalidated, []int{0, 1})
+	}
+
+	resigned, err = tx.ResignInvalidated(signer)
+	if err != nil {
+		t.Fatalf("Failed to resign : %s", err)
+	}
+	if !reflect.DeepEqual(resigned, []int{0, 1}) {
+		t.Fatalf("Wrong resigned inputs : got %v, want %v", resigned, []int{0, 1})
+	}
+
+	if tx.InputIsSigned(2) {
+		t.Fatalf("Unsigned input should not be signed")
+	}
+
+	if invalidated := tx.InvalidatedInputs(); len(invalidated) != 0 {
+		t.Fatalf("Wrong invalidated inputs : got %v, want none", invalidated)
+	}
+}
@@ -27,8 +27,30 @@ const (
 	// sigHashMask defines the number of bits of the hash type which is used to identify which
 	//   outputs are signed.
 	sigHashMask = 0x1f
+
+	// DefaultSigHashType is the sig hash type used to sign inputs that don't specify one.
+	DefaultSigHashType = SigHashAll + SigHashForkID
 )
 
+// Validate returns an error if the sig hash type isn't one of ALL, NONE, or SINGLE, optionally
+//   combined with ANYONECANPAY, and always combined with FORKID.
+func (t SigHashType) Validate() error {
+	if t&SigHashForkID == 0 {
+		return errors.Wrapf(ErrUnsupportedSigHashType, "0x%02x missing fork id", uint32(t))
+	}
+
+	if t&^(sigHashMask|SigHashAnyOneCanPay|SigHashForkID) != 0 {
+		return errors.Wrapf(ErrUnsupportedSigHashType, "0x%02x unknown flags", uint32(t))
+	}
+
+	switch t & sigHashMask {
+	case SigHashAll, SigHashNone, SigHashSingle:
+		return nil
+	default:
+		return errors.Wrapf(ErrUnsupportedSigHashType, "0x%02x unknown base type", uint32(t))
+	}
+}
+
 // SigHashCache allows caching of previously calculated hashes used to calculate the signature hash
 //   for signing tx inputs.
 // This allows validation to re-use previous hashing computation, reducing the complexity of
@@ -8,6 +8,8 @@ import (
 
 	"github.com/tokenized/pkg/bitcoin"
 	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
 )
 
 func TestSigHash(t *testing.T) {
@@ -242,3 +244,115 @@ func TestSigHashBytes(t *testing.T) {
 
 	t.Logf("Sig Hash Preimage : %x", b)
 }
+
+func TestSigHashTypeValidate(t *testing.T) {
+	tests := []struct {
+		hashType SigHashType
+		valid    bool
+	}{
+		{SigHashAll + SigHashForkID, true},
+		{SigHashNone + SigHashForkID, true},
+		{SigHashSingle + SigHashForkID, true},
+		{SigHashAll + SigHashAnyOneCanPay + SigHashForkID, true},
+		{SigHashNone + SigHashAnyOneCanPay + SigHashForkID, true},
+		{SigHashSingle + SigHashAnyOneCanPay + SigHashForkID, true},
+		{SigHashAll, false},
+		{SigHashAll + SigHashAnyOneCanPay, false},
+		{SigHashForkID, false},
+		{0x04 + SigHashForkID, false},
+		{SigHashAll + SigHashForkID + 0x20, false},
+		{SigHashAll + SigHashForkID + 0x100, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.hashType.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("Hash type 0x%02x should be valid : %s", uint32(tt.hashType), err)
+		}
+		if !tt.valid && errors.Cause(err) != ErrUnsupportedSigHashType {
+			t.Errorf("Hash type 0x%02x should be unsupported : %v", uint32(tt.hashType), err)
+		}
+	}
+}
+
+func TestInputSigHashType(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	tx := NewTxBuilder(1.0, 1.0)
+	for i := 0; i < 2; i++ {
+		var outpoint wire.OutPoint
+		rand.Read(outpoint.Hash[:])
+		if err := tx.AddInput(outpoint, lockingScript, 10000); err != nil {
+			t.Fatalf("Failed to add input : %s", err)
+		}
+	}
+	tx.MsgTx.AddTxOut(wire.NewTxOut(15000, lockingScript))
+	tx.Outputs = append(tx.Outputs, &OutputSupplement{})
+
+	if err := tx.SetInputSigHashType(0, SigHashAll); errors.Cause(err) !=
+		ErrUnsupportedSigHashType {
+		t.Fatalf("Wrong error for missing fork id : %v", err)
+	}
+
+	// Crowdfund style input that doesn't sign the other inputs.
+	crowdfund := SigHashAll + SigHashAnyOneCanPay + SigHashForkID
+	if err := tx.SetInputSigHashType(0, crowdfund); err != nil {
+		t.Fatalf("Failed to set sig hash type : %s", err)
+	}
+
+	// There is no output 1 for SIGHASH_SINGLE to sign.
+	if err := tx.SetInputSigHashType(1, SigHashSingle+SigHashForkID); err != nil {
+		t.Fatalf("Failed to set sig hash type : %s", err)
+	}
+	if err := tx.SignOnly([]bitcoin.Key{key}); errors.Cause(err) != ErrUnsupportedSigHashType {
+		t.Fatalf("Wrong error for sig hash single without output : %v", err)
+	}
+
+	if err := tx.SetInputSigHashType(1, SigHashNone+SigHashForkID); err != nil {
+		t.Fatalf("Failed to set sig hash type : %s", err)
+	}
+	if err := tx.SignOnly([]bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	wantTypes := []SigHashType{crowdfund, SigHashNone + SigHashForkID}
+	for index, want := range wantTypes {
+		_, signature, err := bitcoin.ParsePushDataScript(bytes.NewReader(
+			tx.MsgTx.TxIn[index].UnlockingScript))
+		if err != nil {
+			t.Fatalf("Failed to parse signature : %s", err)
+		}
+
+		if got := SigHashType(signature[len(signature)-1]); got != want {
+			t.Errorf("Wrong sig hash type for input %d : got 0x%02x, want 0x%02x", index,
+				uint32(got), uint32(want))
+		}
+	}
+
+	// Adding an input doesn't invalidate the ANYONECANPAY signature.
+	previous, err := SignatureHash(tx.MsgTx, 0, lockingScript, 10000, crowdfund, &SigHashCache{})
+	if err != nil {
+		t.Fatalf("Failed to create sig hash : %s", err)
+	}
+
+	var outpoint wire.OutPoint
+	rand.Read(outpoint.Hash[:])
+	if err := tx.AddInput(outpoint, lockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+
+	hash, err := SignatureHash(tx.MsgTx, 0, lockingScript, 10000, crowdfund, &SigHashCache{})
+	if err != nil {
+		t.Fatalf("Failed to create sig hash : %s", err)
+	}
+	if !previous.Equal(hash) {
+		t.Fatalf("Sig hash should not change")
+	}
+}
@@ -29,6 +29,47 @@ func (tx *TxBuilder) AllInputsAreSigned() bool {
 	return true
 }
 
+// SetInputSigHashType sets the sig hash type used to sign the input at the specified index. The
+//   hash type must contain SigHashForkID. Any existing signature for the input is removed.
+func (tx *TxBuilder) SetInputSigHashType(index int, hashType SigHashType) error {
+	if index >= len(tx.Inputs) {
+		return errors.New("Input index out of range")
+	}
+
+	if err := hashType.Validate(); err != nil {
+		return err
+	}
+
+	tx.Inputs[index].SigHashType = hashType
+	tx.MsgTx.TxIn[index].UnlockingScript = nil
+	return nil
+}
+
+// InputSigHashType returns the sig hash type that will be used to sign the input at the
+//   specified index.
+func (tx *TxBuilder) InputSigHashType(index int) (SigHashType, error) {
+	if index >= len(tx.Inputs) {
+		return 0, errors.New("Input index out of range")
+	}
+
+	hashType := tx.Inputs[index].SigHashType
+	if hashType == 0 {
+		return DefaultSigHashType, nil
+	}
+
+	if err := hashType.Validate(); err != nil {
+		return 0, err
+	}
+
+	// SIGHASH_SINGLE signs the output at the same index as the input, so it must exist.
+	if hashType&sigHashMask == SigHashSingle && index >= len(tx.MsgTx.TxOut) {
+		return 0, errors.Wrapf(ErrUnsupportedSigHashType, "no output %d for sig hash single",
+			index)
+	}
+
+	return hashType, nil
+}
+
 // SignP2PKHInput sets the signature script on the specified PKH input.
 // This should only be used when you aren't signing for all inputs and the fee is overestimated, so
 //   it needs no adjustement.
@@ -54,8 +95,13 @@ func (tx *TxBuilder) SignP2PKHInput(index int, key bitcoin.Key, hashCache *SigHa
 		return errors.Wrap(ErrWrongPrivateKey, fmt.Sprintf("Required : %x", hash.Bytes()))
 	}
 
+	hashType, err := tx.InputSigHashType(index)
+	if err != nil {
+		return err
+	}
+
 	tx.MsgTx.TxIn[index].UnlockingScript, err = P2PKHUnlockingScript(key, tx.MsgTx, index,
-		tx.Inputs[index].LockingScript, tx.Inputs[index].Value, SigHashAll+SigHashForkID, hashCache)
+		tx.Inputs[index].LockingScript, tx.Inputs[index].Value, hashType, hashCache)
 
 	return err
 }
@@ -64,8 +110,14 @@ func (tx *TxBuilder) SignP2PKHInput(index int, key bitcoin.Key, hashCache *SigHa
 //   keys is a slice of all keys required to sign all inputs. They do not have to be in any order.
 // TODO Upgrade to sign more than just P2PKH inputs.
 func (tx *TxBuilder) Sign(keys []bitcoin.Key) error {
+	return tx.SignWithSigner(bitcoin.NewKeysSigner(keys))
+}
+
+// SignWithSigner is the same as Sign, but gets signatures from the signer so the private keys
+//   don't have to be available. The input key IDs are passed to the signer.
+func (tx *TxBuilder) SignWithSigner(signer bitcoin.Signer) error {
 	// Update fee to estimated amount
-	estimatedFee := int64(float32(tx.EstimatedSize()) * tx.FeeRate)
+	estimatedFee := int64(tx.EstimatedFee())
 	inputValue := tx.InputValue()
 	outputValue := tx.OutputValue(true)
 	shc := SigHashCache{}
@@ -94,7 +146,7 @@ func (tx *TxBuilder) Sign(keys []bitcoin.Key) error {
 
 		// Sign all inputs
 		for index, _ := range tx.Inputs {
-			if err := tx.signInput(index, keys, shc); err != nil {
+			if err := tx.signInput(index, signer, &shc); err != nil {
 				return errors.Wrap(err, fmt.Sprintf("sign input %d", index))
 			}
 		}
@@ -104,7 +156,7 @@ func (tx *TxBuilder) Sign(keys []bitcoin.Key) error {
 		}
 
 		// Check fee and adjust if too low
-		targetFee := int64(float32(tx.MsgTx.SerializeSize()) * tx.FeeRate)
+		targetFee := int64(tx.feeForSize(tx.MsgTx.SerializeSize()))
 		inputValue = tx.InputValue()
 		outputValue = tx.OutputValue(false)
 		changeValue := tx.changeSum()
@@ -136,6 +188,11 @@ func (tx *TxBuilder) Sign(keys []bitcoin.Key) error {
 // SignOnly signs any unsigned inputs in the tx.
 // It does not adjust the fee or make any other modifications to the tx like Sign.
 func (tx *TxBuilder) SignOnly(keys []bitcoin.Key) error {
+	return tx.SignOnlyWithSigner(bitcoin.NewKeysSigner(keys))
+}
+
+// SignOnlyWithSigner is the same as SignOnly, but gets signatures from the signer.
+func (tx *TxBuilder) SignOnlyWithSigner(signer bitcoin.Signer) error {
 	shc := SigHashCache{}
 
 	for index, _ := range tx.Inputs {
@@ -143,7 +200,7 @@ func (tx *TxBuilder) SignOnly(keys []bitcoin.Key) error {
 			continue // already signed
 		}
 
-		if err := tx.signInput(index, keys, shc); err != nil {
+		if err := tx.signInput(index, signer, &shc); err != nil {
 			return errors.Wrap(err, fmt.Sprintf("sign input %d", index))
 		}
 	}
@@ -152,72 +209,76 @@ func (tx *TxBuilder) SignOnly(keys []bitcoin.Key) error {
 }
 
 // signInput signs an input of the tx.
-func (tx *TxBuilder) signInput(index int, keys []bitcoin.Key, shc SigHashCache) error {
-	address, err := bitcoin.RawAddressFromLockingScript(tx.Inputs[index].LockingScript)
+func (tx *TxBuilder) signInput(index int, signer bitcoin.Signer, shc *SigHashCache) error {
+	input := tx.Inputs[index]
+	address, err := bitcoin.RawAddressFromLockingScript(input.LockingScript)
 	if err != nil {
 		return errors.Wrap(err, "locking script")
 	}
 
-	switch address.Type() {
-	case bitcoin.ScriptTypePKH:
-		hash, err := address.Hash()
-		if err != nil {
-			return errors.Wrap(err, "address hash")
-		}
-		signed := false
-		for _, key := range keys {
-			pkh := bitcoin.Hash160(key.PublicKey().Bytes())
-			if !bytes.Equal(pkh, hash.Bytes()) {
-				continue
-			}
-
-			tx.MsgTx.TxIn[index].UnlockingScript, err = P2PKHUnlockingScript(key, tx.MsgTx, index,
-				tx.Inputs[index].LockingScript, tx.Inputs[index].Value, SigHashAll+SigHashForkID,
-				&shc)
+	hashType, err := tx.InputSigHashType(index)
+	if err != nil {
+		return errors.Wrap(err, "sig hash type")
+	}
 
-			if err != nil {
-				return errors.Wrap(err, "unlock script")
-			}
-			signed = true
-			break
-		}
+	scriptType := address.Type()
+	if scriptType != bitcoin.ScriptTypePKH && scriptType != bitcoin.ScriptTypePK {
+		return errors.Wrap(ErrWrongScriptTemplate, "Not a P2PKH or P2PK locking script")
+	}
 
-		if !signed {
+	publicKey, err := signer.PublicKey(input.KeyID, input.LockingScript)
+	if err != nil {
+		if errors.Cause(err) == bitcoin.ErrKeyNotFound {
 			return ErrMissingPrivateKey
 		}
+		return errors.Wrap(err, "public key")
+	}
 
-	case bitcoin.ScriptTypePK:
-		pubKey, err := address.GetPublicKey()
+	// Don't trust the signer to return the right key.
+	if scriptType == bitcoin.ScriptTypePKH {
+		hash, err := address.Hash()
 		if err != nil {
-			return err
+			return errors.Wrap(err, "address hash")
 		}
-		pkb := pubKey.Bytes()
-		signed := false
-		for _, key := range keys {
-			pk := key.PublicKey().Bytes()
-			if !bytes.Equal(pk, pkb) {
-				continue
-			}
-
-			tx.MsgTx.TxIn[index].UnlockingScript, err = P2PKUnlockingScript(key, tx.MsgTx, index,
-				tx.Inputs[index].LockingScript, tx.Inputs[index].Value, SigHashAll+SigHashForkID,
-				&shc)
-
-			if err != nil {
-				return errors.Wrap(err, "unlock script")
-			}
-			signed = true
-			break
+		if !bytes.Equal(bitcoin.Hash160(publicKey.Bytes()), hash.Bytes()) {
+			return errors.Wrap(ErrWrongPrivateKey, fmt.Sprintf("Required : %x", hash.Bytes()))
 		}
+	} else {
+		addressPublicKey, err := address.GetPublicKey()
+		if err != nil {
+			return errors.Wrap(err, "address public key")
+		}
+		if !addressPublicKey.Equal(publicKey) {
+			return errors.Wrap(ErrWrongPrivateKey, fmt.Sprintf("Required : %s", addressPublicKey))
+		}
+	}
 
-		if !signed {
+	hash, err := SignatureHash(tx.MsgTx, index, input.LockingScript, input.Value, hashType, shc)
+	if err != nil {
+		return errors.Wrap(err, "sig hash")
+	}
+
+	sig, err := signer.Sign(input.KeyID, publicKey, *hash)
+	if err != nil {
+		if errors.Cause(err) == bitcoin.ErrKeyNotFound {
 			return ErrMissingPrivateKey
 		}
+		return errors.Wrap(err, "sign")
+	}
 
-	default:
-		return errors.Wrap(ErrWrongScriptTemplate, "Not a P2PKH or P2PK locking script")
+	// <Signature> <PublicKey> for P2PKH and <Signature> for P2PK
+	sigBytes := append(sig.Bytes(), byte(hashType))
+	buf := bytes.NewBuffer(make([]byte, 0, len(sigBytes)+PublicKeyPushDataSize+1))
+	if err := bitcoin.WritePushDataScript(buf, sigBytes); err != nil {
+		return errors.Wrap(err, "write signature")
+	}
+	if scriptType == bitcoin.ScriptTypePKH {
+		if err := bitcoin.WritePushDataScript(buf, publicKey.Bytes()); err != nil {
+			return errors.Wrap(err, "write public key")
+		}
 	}
 
+	tx.MsgTx.TxIn[index].UnlockingScript = buf.Bytes()
 	return nil
 }
 
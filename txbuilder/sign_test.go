@@ -0,0 +1,118 @@
+package txbuilder
+
+import (
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// pathSigner is a signer that finds keys by key id, like an HSM service that derives keys from
+// derivation paths.
+type pathSigner struct {
+	keys   map[string]bitcoin.Key
+	signed []string
+}
+
+func (s *pathSigner) PublicKey(keyID string, lockingScript bitcoin.Script) (bitcoin.PublicKey,
+	error) {
+
+	key, exists := s.keys[keyID]
+	if !exists {
+		return bitcoin.PublicKey{}, bitcoin.ErrKeyNotFound
+	}
+	return key.PublicKey(), nil
+}
+
+func (s *pathSigner) Sign(keyID string, publicKey bitcoin.PublicKey,
+	hash bitcoin.Hash32) (bitcoin.Signature, error) {
+
+	key, exists := s.keys[keyID]
+	if !exists {
+		return bitcoin.Signature{}, bitcoin.ErrKeyNotFound
+	}
+	s.signed = append(s.signed, keyID)
+	return key.Sign(hash)
+}
+
+func TestSignWithSigner(t *testing.T) {
+	signer := &pathSigner{
+		keys: make(map[string]bitcoin.Key),
+	}
+
+	tx := NewTxBuilder(0.5, 1.0)
+	var lockingScripts []bitcoin.Script
+	for i, path := range []string{"m/0/0", "m/0/1"} {
+		key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+		if err != nil {
+			t.Fatalf("Failed to create private key : %s", err)
+		}
+		signer.keys[path] = key
+
+		lockingScript, err := key.LockingScript()
+		if err != nil {
+			t.Fatalf("Failed to create locking script : %s", err)
+		}
+		lockingScripts = append(lockingScripts, lockingScript)
+
+		utxo := bitcoin.UTXO{
+			Index:         uint32(i),
+			Value:         5000,
+			LockingScript: lockingScript,
+			KeyID:         path,
+		}
+		if err := tx.AddInputUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add input : %s", err)
+		}
+	}
+
+	if err := tx.SetChangeLockingScript(lockingScripts[0], "m/0/0"); err != nil {
+		t.Fatalf("Failed to set change : %s", err)
+	}
+	if err := tx.AddOutput(lockingScripts[1], 3000, false, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+
+	if err := tx.SignWithSigner(signer); err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	if !tx.AllInputsAreSigned() {
+		t.Fatalf("All inputs should be signed")
+	}
+	if len(signer.signed) < 2 || signer.signed[0] != "m/0/0" || signer.signed[1] != "m/0/1" {
+		t.Fatalf("Wrong key ids signed : %v", signer.signed)
+	}
+
+	// The signatures match what signing with the keys directly produces.
+	keys := []bitcoin.Key{signer.keys["m/0/0"], signer.keys["m/0/1"]}
+	for index := range tx.MsgTx.TxIn {
+		previous := tx.signatureHash(index, &SigHashCache{})
+		tx.MsgTx.TxIn[index].UnlockingScript = nil
+		if err := tx.SignOnly(keys); err != nil {
+			t.Fatalf("Failed to sign with keys : %s", err)
+		}
+		if hash := tx.signatureHash(index, &SigHashCache{}); !hash.Equal(previous) {
+			t.Fatalf("Wrong signature hash for input %d", index)
+		}
+	}
+
+	// Missing key
+	var outpoint wire.OutPoint
+	outpoint.Hash[0] = 1
+	if err := tx.AddInput(outpoint, lockingScripts[0], 1000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	tx.Inputs[2].KeyID = "m/0/2"
+	if err := tx.SignOnlyWithSigner(signer); errors.Cause(err) != ErrMissingPrivateKey {
+		t.Fatalf("Wrong error for missing key : %v", err)
+	}
+
+	// Signer returns the wrong key for the locking script.
+	tx.Inputs[2].KeyID = "m/0/1"
+	if err := tx.SignOnlyWithSigner(signer); errors.Cause(err) != ErrWrongPrivateKey {
+		t.Fatalf("Wrong error for wrong key : %v", err)
+	}
+}
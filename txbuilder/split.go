@@ -0,0 +1,207 @@
+package txbuilder
+
+import (
+	"fmt"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+// Split returns the tx as a chain of txs that are each under maxTxSize, so payments with more
+//   inputs or outputs than fit in one tx can still be sent. Each tx except the last pays its
+//   remaining value to an intermediate change output that is spent by the first input of the next
+//   tx. The txs are in dependency order, so they must be signed with SignSplit and broadcast in
+//   the order returned.
+// Inputs are added to the first txs and non-remainder outputs follow them in their original
+//   order. Remainder outputs are put in the last tx and pay the fees of the extra txs. If the tx
+//   already fits, or maxTxSize is zero, the tx itself is returned. The tx is not modified, other
+//   than loading a change script from the change addresser.
+func (tx *TxBuilder) Split(maxTxSize int) ([]*TxBuilder, error) {
+	if maxTxSize <= 0 || tx.validationSize() <= maxTxSize {
+		return []*TxBuilder{tx}, nil
+	}
+
+	if len(tx.Inputs) != len(tx.MsgTx.TxIn) {
+		return nil, ErrMissingInputData
+	}
+
+	if err := tx.loadChangeScript(); err != nil {
+		return nil, errors.Wrap(err, "change script")
+	}
+	if len(tx.ChangeScript) == 0 {
+		return nil, errors.Wrap(ErrChangeAddressNeeded, "intermediate change")
+	}
+
+	var payments, remainders []int
+	remaindersSize := 0
+	for index, output := range tx.Outputs {
+		if output.IsRemainder {
+			remainders = append(remainders, index)
+			remaindersSize += tx.MsgTx.TxOut[index].SerializeSize()
+		} else {
+			payments = append(payments, index)
+		}
+	}
+
+	// Space kept for the intermediate change output, or the remainder outputs of the last tx,
+	// plus space for var int size increases.
+	changeSize := OutputSize(tx.ChangeScript)
+	reserved := changeSize
+	if remaindersSize > reserved {
+		reserved = remaindersSize
+	}
+	reserved += 2 * int(wire.MaxVarIntPayload)
+
+	_, changeDust := OutputFeeAndDustForLockingScript(tx.ChangeScript, tx.DustFeeRate, tx.FeeRate)
+
+	var result []*TxBuilder
+	var previous *TxBuilder
+	nextInput, nextPayment := 0, 0
+	for {
+		part := tx.newSplitPart()
+		if previous != nil {
+			change := len(previous.MsgTx.TxOut) - 1
+			outpoint := wire.OutPoint{Hash: *previous.MsgTx.TxHash(), Index: uint32(change)}
+			if err := part.AddInput(outpoint, tx.ChangeScript,
+				previous.MsgTx.TxOut[change].Value); err != nil {
+				return nil, errors.Wrapf(err, "tx %d: change input", len(result))
+			}
+			part.Inputs[0].KeyID = tx.ChangeKeyID
+		}
+
+		for nextInput < len(tx.Inputs) {
+			input := tx.Inputs[nextInput]
+			size, err := InputSize(input.LockingScript)
+			if err != nil {
+				size = MaximumP2PKHInputSize
+			}
+			if part.EstimatedSize()+size+reserved > maxTxSize {
+				break
+			}
+
+			part.addSplitInput(tx.MsgTx.TxIn[nextInput], input)
+			nextInput++
+		}
+
+		for nextPayment < len(payments) {
+			index := payments[nextPayment]
+			txout := tx.MsgTx.TxOut[index]
+			size := part.EstimatedSize() + txout.SerializeSize()
+			if size+reserved > maxTxSize {
+				break
+			}
+
+			// Keep enough value for the fee and the change or remainder outputs.
+			required := part.OutputValue(true) + txout.Value +
+				part.feeForSize(size+reserved) + changeDust
+			if part.InputValue() < required {
+				break
+			}
+
+			part.MsgTx.AddTxOut(wire.NewTxOut(txout.Value, txout.LockingScript))
+			supplement := *tx.Outputs[index]
+			part.Outputs = append(part.Outputs, &supplement)
+			nextPayment++
+		}
+
+		if nextInput == len(tx.Inputs) && nextPayment == len(payments) {
+			for _, index := range remainders {
+				txout := tx.MsgTx.TxOut[index]
+				part.MsgTx.AddTxOut(wire.NewTxOut(txout.Value, txout.LockingScript))
+				supplement := *tx.Outputs[index]
+				part.Outputs = append(part.Outputs, &supplement)
+			}
+
+			if err := part.CalculateFee(); err != nil {
+				return nil, errors.Wrapf(err, "tx %d: calculate fee", len(result))
+			}
+
+			return append(result, part), nil
+		}
+
+		if len(part.MsgTx.TxIn) == 0 ||
+			(previous != nil && len(part.MsgTx.TxIn) == 1 && len(part.MsgTx.TxOut) == 0) {
+			// No progress was made.
+			if nextInput < len(tx.Inputs) {
+				return nil, errors.Wrapf(ErrTxTooLarge, "input %d doesn't fit", nextInput)
+			}
+			return nil, errors.Wrapf(ErrInsufficientValue, "output %d", payments[nextPayment])
+		}
+
+		fee := part.feeForSize(part.EstimatedSize() + changeSize)
+		if part.InputValue() < part.OutputValue(true)+fee+changeDust {
+			return nil, errors.Wrapf(ErrInsufficientValue, "tx %d: intermediate change",
+				len(result))
+		}
+
+		change := part.InputValue() - part.OutputValue(true) - fee
+		part.MsgTx.AddTxOut(wire.NewTxOut(change, tx.ChangeScript))
+		part.Outputs = append(part.Outputs, &OutputSupplement{
+			IsRemainder: true,
+			KeyID:       tx.ChangeKeyID,
+		})
+
+		result = append(result, part)
+		previous = part
+	}
+}
+
+// SignSplit signs the txs returned by Split in order. After each tx is signed, the inputs of the
+//   following txs that spend it are updated with its signed tx hash and final output values, since
+//   signing changes the tx hash and can adjust the fee.
+func SignSplit(txs []*TxBuilder, keys []bitcoin.Key) error {
+	return SignSplitWithSigner(txs, bitcoin.NewKeysSigner(keys))
+}
+
+// SignSplitWithSigner is the same as SignSplit, but gets signatures from the signer.
+func SignSplitWithSigner(txs []*TxBuilder, signer bitcoin.Signer) error {
+	// The hashes must be calculated first because updating the inputs of a tx changes its hash.
+	unsignedHashes := make([]bitcoin.Hash32, len(txs))
+	for i, tx := range txs {
+		unsignedHashes[i] = *tx.MsgTx.TxHash()
+	}
+
+	for i, tx := range txs {
+		if err := tx.SignWithSigner(signer); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("sign tx %d", i))
+		}
+
+		signedHash := *tx.MsgTx.TxHash()
+		for _, child := range txs[i+1:] {
+			for index, txin := range child.MsgTx.TxIn {
+				if !txin.PreviousOutPoint.Hash.Equal(&unsignedHashes[i]) {
+					continue
+				}
+
+				txin.PreviousOutPoint.Hash = signedHash
+				child.Inputs[index].Value = tx.MsgTx.TxOut[txin.PreviousOutPoint.Index].Value
+			}
+		}
+	}
+
+	return nil
+}
+
+// newSplitPart returns an empty tx with the same settings as the tx.
+func (tx *TxBuilder) newSplitPart() *TxBuilder {
+	result := NewTxBuilder(tx.FeeRate, tx.DustFeeRate)
+	result.MsgTx.Version = tx.MsgTx.Version
+	result.MsgTx.LockTime = tx.MsgTx.LockTime
+	result.FeeQuote = tx.FeeQuote
+	result.ChangeScript = tx.ChangeScript
+	result.ChangeKeyID = tx.ChangeKeyID
+	return result
+}
+
+// addSplitInput adds an unsigned copy of the input to the tx.
+func (tx *TxBuilder) addSplitInput(txin *wire.TxIn, input *InputSupplement) {
+	outpoint := txin.PreviousOutPoint
+	newTxIn := wire.NewTxIn(&outpoint, nil)
+	newTxIn.Sequence = txin.Sequence
+	tx.MsgTx.AddTxIn(newTxIn)
+
+	supplement := *input
+	tx.Inputs = append(tx.Inputs, &supplement)
+}
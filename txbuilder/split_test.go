@@ -0,0 +1,123 @@
+package txbuilder
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+func TestSplit(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	tx := NewTxBuilder(0.5, 1.0)
+	if err := tx.SetChangeLockingScript(lockingScript, "change"); err != nil {
+		t.Fatalf("Failed to set change : %s", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		utxo := bitcoin.UTXO{
+			Index:         uint32(i),
+			Value:         2000,
+			LockingScript: lockingScript,
+		}
+		rand.Read(utxo.Hash[:])
+		if err := tx.AddInputUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add input : %s", err)
+		}
+	}
+
+	paymentValue := uint64(0)
+	for i := 0; i < 500; i++ {
+		if err := tx.AddOutput(lockingScript, 600, false, false); err != nil {
+			t.Fatalf("Failed to add output : %s", err)
+		}
+		paymentValue += 600
+	}
+
+	if err := tx.CalculateFee(); err != nil {
+		t.Fatalf("Failed to calculate fee : %s", err)
+	}
+
+	if _, err := tx.Split(100); err == nil {
+		t.Fatalf("Split should fail when an input doesn't fit")
+	}
+
+	txs, err := tx.Split(15000)
+	if err != nil {
+		t.Fatalf("Failed to split : %s", err)
+	}
+
+	// 200 inputs of 148 bytes and 500 outputs of 34 bytes need 4 txs of less than 15000 bytes.
+	if len(txs) != 4 {
+		t.Fatalf("Wrong tx count : got %d, want 4", len(txs))
+	}
+
+	if err := SignSplit(txs, []bitcoin.Key{key}); err != nil {
+		t.Fatalf("Failed to sign split : %s", err)
+	}
+
+	inputCount := 0
+	outputValue := uint64(0)
+	for i, part := range txs {
+		t.Logf("Tx %d : %d inputs, %d outputs, size %d, fee %d", i, len(part.MsgTx.TxIn),
+			len(part.MsgTx.TxOut), part.MsgTx.SerializeSize(), part.Fee())
+
+		if part.MsgTx.SerializeSize() > 15000 {
+			t.Errorf("Tx %d too large : %d", i, part.MsgTx.SerializeSize())
+		}
+		if part.Fee() < part.EstimatedFee() {
+			t.Errorf("Tx %d fee too low : got %d, want %d", i, part.Fee(), part.EstimatedFee())
+		}
+
+		if i > 0 {
+			// The first input spends the change of the previous tx.
+			previous := txs[i-1].MsgTx
+			outpoint := part.MsgTx.TxIn[0].PreviousOutPoint
+			if !outpoint.Hash.Equal(previous.TxHash()) ||
+				int(outpoint.Index) != len(previous.TxOut)-1 {
+				t.Fatalf("Tx %d doesn't spend previous change : %s", i, outpoint.String())
+			}
+			if part.Inputs[0].Value != previous.TxOut[outpoint.Index].Value {
+				t.Fatalf("Tx %d wrong change value : got %d, want %d", i, part.Inputs[0].Value,
+					previous.TxOut[outpoint.Index].Value)
+			}
+			inputCount += len(part.MsgTx.TxIn) - 1
+		} else {
+			inputCount += len(part.MsgTx.TxIn)
+		}
+
+		for index, output := range part.MsgTx.TxOut {
+			if !part.Outputs[index].IsRemainder {
+				outputValue += output.Value
+			}
+		}
+
+		if len(part.InvalidatedInputs()) != 0 {
+			t.Errorf("Tx %d has invalid signatures", i)
+		}
+	}
+
+	if inputCount != 200 {
+		t.Errorf("Wrong input count : got %d, want 200", inputCount)
+	}
+	if outputValue != paymentValue {
+		t.Errorf("Wrong payment value : got %d, want %d", outputValue, paymentValue)
+	}
+
+	// Fits in one tx
+	txs, err = tx.Split(0)
+	if err != nil {
+		t.Fatalf("Failed to split : %s", err)
+	}
+	if len(txs) != 1 || txs[0] != tx {
+		t.Fatalf("Tx without size limit should not be split")
+	}
+}
@@ -0,0 +1,314 @@
+package txbuilder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// StateVersion is the current version of the serialized builder state format.
+	StateVersion = uint8(0)
+
+	outputFlagRemainder   = uint8(0x01)
+	outputFlagDust        = uint8(0x02)
+	outputFlagAddedForFee = uint8(0x04)
+
+	// maxStateStringSize is the maximum size of scripts and strings in serialized builder state.
+	maxStateStringSize = 1 << 24
+)
+
+var (
+	// ErrUnsupportedStateVersion means that serialized builder state has a version that isn't
+	// supported.
+	ErrUnsupportedStateVersion = errors.New("Unsupported State Version")
+
+	// ErrInvalidState means that serialized builder state doesn't match its tx.
+	ErrInvalidState = errors.New("Invalid State")
+)
+
+// SerializeState writes the full state of the builder so it can be resumed later, possibly in
+// another process. This includes the tx, with any unlocking scripts already added, the data for
+// each input and output, and the fee settings. The ChangeAddresser is not included and must be
+// set again after the state is deserialized.
+func (tx *TxBuilder) SerializeState(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, StateVersion); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if err := tx.MsgTx.Serialize(w); err != nil {
+		return errors.Wrap(err, "tx")
+	}
+
+	if len(tx.Inputs) != len(tx.MsgTx.TxIn) {
+		return errors.Wrapf(ErrInvalidState, "%d inputs, %d supplements", len(tx.MsgTx.TxIn),
+			len(tx.Inputs))
+	}
+	for i, input := range tx.Inputs {
+		if err := input.serialize(w); err != nil {
+			return errors.Wrapf(err, "input %d", i)
+		}
+	}
+
+	if len(tx.Outputs) != len(tx.MsgTx.TxOut) {
+		return errors.Wrapf(ErrInvalidState, "%d outputs, %d supplements", len(tx.MsgTx.TxOut),
+			len(tx.Outputs))
+	}
+	for i, output := range tx.Outputs {
+		if err := output.serialize(w); err != nil {
+			return errors.Wrapf(err, "output %d", i)
+		}
+	}
+
+	if err := wire.WriteVarBytes(w, 0, tx.ChangeScript); err != nil {
+		return errors.Wrap(err, "change script")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, tx.FeeRate); err != nil {
+		return errors.Wrap(err, "fee rate")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, tx.SendMax); err != nil {
+		return errors.Wrap(err, "send max")
+	}
+
+	if tx.FeeQuote != nil {
+		if err := binary.Write(w, binary.LittleEndian, uint8(1)); err != nil {
+			return errors.Wrap(err, "fee quote flag")
+		}
+		if err := binary.Write(w, binary.LittleEndian, *tx.FeeQuote); err != nil {
+			return errors.Wrap(err, "fee quote")
+		}
+	} else {
+		if err := binary.Write(w, binary.LittleEndian, uint8(0)); err != nil {
+			return errors.Wrap(err, "fee quote flag")
+		}
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, tx.DustFeeRate); err != nil {
+		return errors.Wrap(err, "dust fee rate")
+	}
+
+	if err := wire.WriteVarString(w, 0, tx.ChangeKeyID); err != nil {
+		return errors.Wrap(err, "change key id")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, tx.ParentFeeDeficit); err != nil {
+		return errors.Wrap(err, "parent fee deficit")
+	}
+
+	if err := wire.WriteVarInt(w, 0, uint64(tx.MaxDataSize)); err != nil {
+		return errors.Wrap(err, "max data size")
+	}
+
+	return nil
+}
+
+// DeserializeState reads builder state written by SerializeState. The ChangeAddresser is not
+// restored.
+func (tx *TxBuilder) DeserializeState(r io.Reader) error {
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if version != StateVersion {
+		return errors.Wrapf(ErrUnsupportedStateVersion, "%d", version)
+	}
+
+	msgTx := &wire.MsgTx{}
+	if err := msgTx.Deserialize(r); err != nil {
+		return errors.Wrap(err, "tx")
+	}
+
+	inputs := make([]*InputSupplement, len(msgTx.TxIn))
+	for i := range inputs {
+		inputs[i] = &InputSupplement{}
+		if err := inputs[i].deserialize(r); err != nil {
+			return errors.Wrapf(err, "input %d", i)
+		}
+	}
+
+	outputs := make([]*OutputSupplement, len(msgTx.TxOut))
+	for i := range outputs {
+		outputs[i] = &OutputSupplement{}
+		if err := outputs[i].deserialize(r); err != nil {
+			return errors.Wrapf(err, "output %d", i)
+		}
+	}
+
+	changeScript, err := wire.ReadVarBytes(r, 0, maxStateStringSize, "change script")
+	if err != nil {
+		return errors.Wrap(err, "change script")
+	}
+
+	var feeRate float32
+	if err := binary.Read(r, binary.LittleEndian, &feeRate); err != nil {
+		return errors.Wrap(err, "fee rate")
+	}
+
+	var sendMax bool
+	if err := binary.Read(r, binary.LittleEndian, &sendMax); err != nil {
+		return errors.Wrap(err, "send max")
+	}
+
+	var flag uint8
+	if err := binary.Read(r, binary.LittleEndian, &flag); err != nil {
+		return errors.Wrap(err, "fee quote flag")
+	}
+
+	var feeQuote *FeeQuote
+	switch flag {
+	case 0:
+	case 1:
+		feeQuote = &FeeQuote{}
+		if err := binary.Read(r, binary.LittleEndian, feeQuote); err != nil {
+			return errors.Wrap(err, "fee quote")
+		}
+	default:
+		return errors.Wrapf(ErrInvalidState, "fee quote flag %d", flag)
+	}
+
+	var dustFeeRate float32
+	if err := binary.Read(r, binary.LittleEndian, &dustFeeRate); err != nil {
+		return errors.Wrap(err, "dust fee rate")
+	}
+
+	changeKeyID, err := wire.ReadVarString(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "change key id")
+	}
+
+	var parentFeeDeficit uint64
+	if err := binary.Read(r, binary.LittleEndian, &parentFeeDeficit); err != nil {
+		return errors.Wrap(err, "parent fee deficit")
+	}
+
+	maxDataSize, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "max data size")
+	}
+
+	tx.MsgTx = msgTx
+	tx.Inputs = inputs
+	tx.Outputs = outputs
+	if len(changeScript) > 0 {
+		tx.ChangeScript = bitcoin.Script(changeScript)
+	} else {
+		tx.ChangeScript = nil
+	}
+	tx.FeeRate = feeRate
+	tx.SendMax = sendMax
+	tx.FeeQuote = feeQuote
+	tx.DustFeeRate = dustFeeRate
+	tx.ChangeKeyID = changeKeyID
+	tx.ParentFeeDeficit = parentFeeDeficit
+	tx.MaxDataSize = int(maxDataSize)
+	return nil
+}
+
+// MarshalBinary returns the serialized builder state.
+func (tx *TxBuilder) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tx.SerializeState(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary sets the builder state from data written by MarshalBinary.
+func (tx *TxBuilder) UnmarshalBinary(data []byte) error {
+	return tx.DeserializeState(bytes.NewReader(data))
+}
+
+func (input InputSupplement) serialize(w io.Writer) error {
+	if err := wire.WriteVarBytes(w, 0, input.LockingScript); err != nil {
+		return errors.Wrap(err, "locking script")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, input.Value); err != nil {
+		return errors.Wrap(err, "value")
+	}
+
+	if err := wire.WriteVarString(w, 0, input.KeyID); err != nil {
+		return errors.Wrap(err, "key id")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(input.SigHashType)); err != nil {
+		return errors.Wrap(err, "sig hash type")
+	}
+
+	return nil
+}
+
+func (input *InputSupplement) deserialize(r io.Reader) error {
+	lockingScript, err := wire.ReadVarBytes(r, 0, maxStateStringSize, "locking script")
+	if err != nil {
+		return errors.Wrap(err, "locking script")
+	}
+	input.LockingScript = bitcoin.Script(lockingScript)
+
+	if err := binary.Read(r, binary.LittleEndian, &input.Value); err != nil {
+		return errors.Wrap(err, "value")
+	}
+
+	keyID, err := wire.ReadVarString(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "key id")
+	}
+	input.KeyID = keyID
+
+	var sigHashType uint32
+	if err := binary.Read(r, binary.LittleEndian, &sigHashType); err != nil {
+		return errors.Wrap(err, "sig hash type")
+	}
+	input.SigHashType = SigHashType(sigHashType)
+
+	return nil
+}
+
+func (output OutputSupplement) serialize(w io.Writer) error {
+	var flags uint8
+	if output.IsRemainder {
+		flags |= outputFlagRemainder
+	}
+	if output.IsDust {
+		flags |= outputFlagDust
+	}
+	if output.addedForFee {
+		flags |= outputFlagAddedForFee
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, flags); err != nil {
+		return errors.Wrap(err, "flags")
+	}
+
+	if err := wire.WriteVarString(w, 0, output.KeyID); err != nil {
+		return errors.Wrap(err, "key id")
+	}
+
+	return nil
+}
+
+func (output *OutputSupplement) deserialize(r io.Reader) error {
+	var flags uint8
+	if err := binary.Read(r, binary.LittleEndian, &flags); err != nil {
+		return errors.Wrap(err, "flags")
+	}
+	output.IsRemainder = flags&outputFlagRemainder != 0
+	output.IsDust = flags&outputFlagDust != 0
+	output.addedForFee = flags&outputFlagAddedForFee != 0
+
+	keyID, err := wire.ReadVarString(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "key id")
+	}
+	output.KeyID = keyID
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package txbuilder
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestStateResume(t *testing.T) {
+	var keys []bitcoin.Key
+	tx := NewTxBuilder(0.5, 1.0)
+	tx.SetFeeQuote(NewFeeQuote(0.5))
+	tx.MaxDataSize = 1000
+	for i := 0; i < 2; i++ {
+		key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to create private key : %s", err)
+		}
+		keys = append(keys, key)
+
+		lockingScript, err := key.LockingScript()
+		if err != nil {
+			t.Fatalf("Failed to create locking script : %s", err)
+		}
+
+		utxo := bitcoin.UTXO{
+			Index:         uint32(i),
+			Value:         10000,
+			LockingScript: lockingScript,
+			KeyID:         fmt.Sprintf("m/0/%d", i),
+		}
+		utxo.Hash[0] = byte(i + 1)
+		if err := tx.AddInputUTXO(utxo); err != nil {
+			t.Fatalf("Failed to add input : %s", err)
+		}
+	}
+
+	changeKey, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to create change key : %s", err)
+	}
+	changeScript, err := changeKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create change script : %s", err)
+	}
+	if err := tx.SetChangeLockingScript(changeScript, "change"); err != nil {
+		t.Fatalf("Failed to set change script : %s", err)
+	}
+
+	if err := tx.AddOutput(changeScript, 5000, false, false); err != nil {
+		t.Fatalf("Failed to add output : %s", err)
+	}
+
+	if err := tx.CalculateFee(); err != nil {
+		t.Fatalf("Failed to calculate fee : %s", err)
+	}
+
+	// Sign only the first input to simulate a partially signed tx.
+	if err := tx.SignP2PKHInput(0, keys[0], &SigHashCache{}); err != nil {
+		t.Fatalf("Failed to sign first input : %s", err)
+	}
+
+	b, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to marshal state : %s", err)
+	}
+
+	resumed := &TxBuilder{}
+	if err := resumed.UnmarshalBinary(b); err != nil {
+		t.Fatalf("Failed to unmarshal state : %s", err)
+	}
+
+	if !resumed.MsgTx.TxHash().Equal(tx.MsgTx.TxHash()) {
+		t.Fatalf("Wrong tx : got %s, want %s", resumed.MsgTx.TxHash(), tx.MsgTx.TxHash())
+	}
+
+	if !resumed.InputIsSigned(0) || resumed.InputIsSigned(1) {
+		t.Fatalf("Signing progress not restored")
+	}
+
+	for i, input := range tx.Inputs {
+		if !input.LockingScript.Equal(resumed.Inputs[i].LockingScript) ||
+			input.Value != resumed.Inputs[i].Value || input.KeyID != resumed.Inputs[i].KeyID {
+			t.Fatalf("Wrong input %d : got %+v, want %+v", i, resumed.Inputs[i], input)
+		}
+	}
+
+	for i, output := range tx.Outputs {
+		if *output != *resumed.Outputs[i] {
+			t.Fatalf("Wrong output %d : got %+v, want %+v", i, resumed.Outputs[i], output)
+		}
+	}
+
+	if !resumed.ChangeScript.Equal(tx.ChangeScript) || resumed.ChangeKeyID != tx.ChangeKeyID {
+		t.Fatalf("Wrong change : got %s, want %s", resumed.ChangeScript, tx.ChangeScript)
+	}
+
+	if resumed.FeeRate != tx.FeeRate || resumed.DustFeeRate != tx.DustFeeRate ||
+		resumed.MaxDataSize != tx.MaxDataSize || *resumed.FeeQuote != *tx.FeeQuote {
+		t.Fatalf("Wrong fee settings")
+	}
+
+	if resumed.Fee() != tx.Fee() {
+		t.Fatalf("Wrong fee : got %d, want %d", resumed.Fee(), tx.Fee())
+	}
+
+	// Finish signing in the resumed builder.
+	if err := resumed.SignOnly(keys[1:]); err != nil {
+		t.Fatalf("Failed to sign second input : %s", err)
+	}
+
+	if !resumed.AllInputsAreSigned() {
+		t.Fatalf("Not all inputs are signed")
+	}
+}
+
+func TestStateVersion(t *testing.T) {
+	tx := NewTxBuilder(0.5, 1.0)
+	var buf bytes.Buffer
+	if err := tx.SerializeState(&buf); err != nil {
+		t.Fatalf("Failed to serialize state : %s", err)
+	}
+
+	b := buf.Bytes()
+	b[0] = StateVersion + 1
+
+	resumed := &TxBuilder{}
+	if err := resumed.UnmarshalBinary(b); errors.Cause(err) != ErrUnsupportedStateVersion {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrUnsupportedStateVersion)
+	}
+
+	tx.MsgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil))
+	if err := tx.SerializeState(&buf); errors.Cause(err) != ErrInvalidState {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrInvalidState)
+	}
+}
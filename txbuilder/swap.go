@@ -0,0 +1,103 @@
+package txbuilder
+
+import (
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SwapOfferSigHashType is used by the first party to sign a swap when the counterparty still
+	//   needs to add inputs to fund it. It signs all outputs, so the counterparty can't change what
+	//   the first party receives, but not the other inputs, so the counterparty can add them.
+	SwapOfferSigHashType = SigHashAll + SigHashAnyOneCanPay + SigHashForkID
+)
+
+// NewSwapTx returns a tx that exchanges assets between two parties, for example tokens from one
+//   party for bitcoin from the other. Each party's partial tx contains the inputs they contribute
+//   and the outputs they require. The offer's inputs and outputs are first, followed by the
+//   counterparty's. Neither partial tx is modified.
+//
+// Each party then signs only their own inputs with SignInputs and passes the partially signed tx
+//   to the other party. The party that signs first uses SwapOfferSigHashType if the counterparty
+//   may still add funding inputs, otherwise both use SigHashAll+SigHashForkID.
+func NewSwapTx(offer, counter *TxBuilder) (*TxBuilder, error) {
+	result := &TxBuilder{
+		MsgTx:           offer.MsgTx.Copy(),
+		Inputs:          copyInputSupplements(offer.Inputs),
+		Outputs:         copyOutputSupplements(offer.Outputs),
+		ChangeScript:    offer.ChangeScript,
+		ChangeKeyID:     offer.ChangeKeyID,
+		ChangeAddresser: offer.ChangeAddresser,
+		FeeRate:         offer.FeeRate,
+		FeeQuote:        offer.FeeQuote,
+		DustFeeRate:     offer.DustFeeRate,
+	}
+
+	other := &TxBuilder{
+		MsgTx:   counter.MsgTx.Copy(),
+		Inputs:  copyInputSupplements(counter.Inputs),
+		Outputs: copyOutputSupplements(counter.Outputs),
+	}
+
+	if err := result.Merge(other); err != nil {
+		return nil, errors.Wrap(err, "merge")
+	}
+
+	return result, nil
+}
+
+// SignInputs signs the unsigned inputs that can be unlocked by the keys with the specified sig hash
+//   type and returns their indexes. Inputs belonging to other parties are left unsigned. It
+//   doesn't adjust the fee, so the fee must already be correct.
+func (tx *TxBuilder) SignInputs(keys []bitcoin.Key, hashType SigHashType) ([]int, error) {
+	if err := hashType.Validate(); err != nil {
+		return nil, err
+	}
+
+	signer := bitcoin.NewKeysSigner(keys)
+	var result []int
+	shc := SigHashCache{}
+	for index, input := range tx.Inputs {
+		if len(tx.MsgTx.TxIn[index].UnlockingScript) > 0 {
+			continue // already signed
+		}
+
+		if _, err := signer.PublicKey(input.KeyID, input.LockingScript); err != nil {
+			continue // another party's input
+		}
+
+		previousHashType := input.SigHashType
+		input.SigHashType = hashType
+		if err := tx.signInput(index, signer, &shc); err != nil {
+			input.SigHashType = previousHashType
+			return nil, errors.Wrapf(err, "sign input %d", index)
+		}
+
+		result = append(result, index)
+	}
+
+	if len(result) == 0 {
+		return nil, errors.Wrap(ErrMissingPrivateKey, "no inputs for keys")
+	}
+
+	return result, nil
+}
+
+func copyInputSupplements(inputs []*InputSupplement) []*InputSupplement {
+	result := make([]*InputSupplement, len(inputs))
+	for i, input := range inputs {
+		c := *input
+		result[i] = &c
+	}
+	return result
+}
+
+func copyOutputSupplements(outputs []*OutputSupplement) []*OutputSupplement {
+	result := make([]*OutputSupplement, len(outputs))
+	for i, output := range outputs {
+		c := *output
+		result[i] = &c
+	}
+	return result
+}
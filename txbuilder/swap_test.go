@@ -0,0 +1,160 @@
+package txbuilder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/wire"
+
+	"github.com/pkg/errors"
+)
+
+func TestSwap(t *testing.T) {
+	tokenKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	tokenLockingScript, err := tokenKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	bitcoinKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	bitcoinLockingScript, err := bitcoinKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	// Party A sends tokens and receives bitcoin.
+	offer := NewTxBuilder(0.5, 1.0)
+	var tokenOutPoint wire.OutPoint
+	tokenOutPoint.Hash[0] = 1
+	if err := offer.AddInput(tokenOutPoint, tokenLockingScript, 1000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	if err := offer.AddDataOutput([]byte("token transfer")); err != nil {
+		t.Fatalf("Failed to add data output : %s", err)
+	}
+	if err := offer.AddOutput(bitcoinLockingScript, 0, false, true); err != nil {
+		t.Fatalf("Failed to add token receiver output : %s", err)
+	}
+	if err := offer.AddOutput(tokenLockingScript, 6000, false, false); err != nil {
+		t.Fatalf("Failed to add payment output : %s", err)
+	}
+
+	// Party B sends bitcoin.
+	counter := NewTxBuilder(0.5, 1.0)
+	var bitcoinOutPoint wire.OutPoint
+	bitcoinOutPoint.Hash[0] = 2
+	if err := counter.AddInput(bitcoinOutPoint, bitcoinLockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	if err := counter.AddOutput(bitcoinLockingScript, 4000, false, false); err != nil {
+		t.Fatalf("Failed to add change output : %s", err)
+	}
+
+	swap, err := NewSwapTx(offer, counter)
+	if err != nil {
+		t.Fatalf("Failed to create swap : %s", err)
+	}
+
+	if len(swap.MsgTx.TxIn) != 2 || len(swap.MsgTx.TxOut) != 4 {
+		t.Fatalf("Wrong swap tx : %d inputs, %d outputs", len(swap.MsgTx.TxIn),
+			len(swap.MsgTx.TxOut))
+	}
+	if len(offer.MsgTx.TxIn) != 1 || len(counter.MsgTx.TxOut) != 1 {
+		t.Fatalf("Partial txs should not be modified")
+	}
+
+	signed, err := swap.SignInputs([]bitcoin.Key{tokenKey}, SigHashAll+SigHashForkID)
+	if err != nil {
+		t.Fatalf("Failed to sign token inputs : %s", err)
+	}
+	if len(signed) != 1 || signed[0] != 0 {
+		t.Fatalf("Wrong signed inputs : got %v, want [0]", signed)
+	}
+	if unsigned := swap.UnsignedInputs(); len(unsigned) != 1 || unsigned[0] != 1 {
+		t.Fatalf("Wrong unsigned inputs : got %v, want [1]", unsigned)
+	}
+
+	_, err = swap.SignInputs([]bitcoin.Key{tokenKey}, SigHashAll+SigHashForkID)
+	if errors.Cause(err) != ErrMissingPrivateKey {
+		t.Fatalf("Wrong error for no inputs to sign : %v", err)
+	}
+
+	if _, err := swap.SignInputs([]bitcoin.Key{bitcoinKey}, SigHashAll+SigHashForkID); err != nil {
+		t.Fatalf("Failed to sign bitcoin inputs : %s", err)
+	}
+	if !swap.AllInputsAreSigned() {
+		t.Fatalf("All inputs should be signed")
+	}
+	if swap.Fee() != 1000+10000-(6000+4000+swap.MsgTx.TxOut[1].Value) {
+		t.Fatalf("Wrong fee : %d", swap.Fee())
+	}
+}
+
+func TestSwapOffer(t *testing.T) {
+	tokenKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	tokenLockingScript, err := tokenKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	bitcoinKey, err := bitcoin.GenerateKey(bitcoin.TestNet)
+	if err != nil {
+		t.Fatalf("Failed to create private key : %s", err)
+	}
+	bitcoinLockingScript, err := bitcoinKey.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	// The offer contains all outputs and is signed before the counterparty adds funding.
+	offer := NewTxBuilder(0.5, 1.0)
+	var tokenOutPoint wire.OutPoint
+	tokenOutPoint.Hash[0] = 1
+	if err := offer.AddInput(tokenOutPoint, tokenLockingScript, 1000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+	if err := offer.AddOutput(tokenLockingScript, 6000, false, false); err != nil {
+		t.Fatalf("Failed to add payment output : %s", err)
+	}
+	if err := offer.AddOutput(bitcoinLockingScript, 4000, false, false); err != nil {
+		t.Fatalf("Failed to add change output : %s", err)
+	}
+
+	if _, err := offer.SignInputs([]bitcoin.Key{tokenKey}, SwapOfferSigHashType); err != nil {
+		t.Fatalf("Failed to sign offer : %s", err)
+	}
+	offerSignature := offer.MsgTx.TxIn[0].UnlockingScript
+
+	funding := NewTxBuilder(0.5, 1.0)
+	var bitcoinOutPoint wire.OutPoint
+	bitcoinOutPoint.Hash[0] = 2
+	if err := funding.AddInput(bitcoinOutPoint, bitcoinLockingScript, 10000); err != nil {
+		t.Fatalf("Failed to add input : %s", err)
+	}
+
+	swap, err := NewSwapTx(offer, funding)
+	if err != nil {
+		t.Fatalf("Failed to create swap : %s", err)
+	}
+
+	if !bytes.Equal(swap.MsgTx.TxIn[0].UnlockingScript, offerSignature) {
+		t.Fatalf("Offer signature should still be valid")
+	}
+
+	if _, err := swap.SignInputs([]bitcoin.Key{bitcoinKey}, SigHashAll+SigHashForkID); err != nil {
+		t.Fatalf("Failed to sign funding : %s", err)
+	}
+	if !swap.AllInputsAreSigned() {
+		t.Fatalf("All inputs should be signed")
+	}
+}
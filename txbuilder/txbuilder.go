@@ -41,6 +41,13 @@ var (
 
 	// ErrMissingInputData means that data required to include an input in a tx was not provided.
 	ErrMissingInputData = errors.New("Missing Input Data")
+
+	// ErrUnsupportedSigHashType means that the sig hash type is not a valid combination of flags or
+	// can't be used for the input it is specified for.
+	ErrUnsupportedSigHashType = errors.New("Unsupported Sig Hash Type")
+
+	// ErrDataTooLarge means that adding data to the tx would exceed the maximum data size.
+	ErrDataTooLarge = errors.New("Data Too Large")
 )
 
 type TxBuilder struct {
@@ -51,12 +58,34 @@ type TxBuilder struct {
 	FeeRate      float32             // The target fee rate in sat/byte
 	SendMax      bool                // When set, AddFunding will add all UTXOs given
 
+	// Optional fee quote that takes precedence over FeeRate when calculating the tx fee. Set it
+	// with SetFeeQuote.
+	FeeQuote *FeeQuote
+
 	// The fee rate used by miners to calculate dust. It is currently maintained as a different rate
 	// than min accept and min propagate. Currently 1.0
 	DustFeeRate float32
 
 	// Optional identifier for external use to track the key needed to spend change
 	ChangeKeyID string
+
+	// Optional source of change locking scripts used when ChangeScript isn't set. Set it with
+	// SetChangeAddresser.
+	ChangeAddresser ChangeAddresser
+
+	// Fee paid in addition to this tx's fee so that unconfirmed parent txs that paid too little fee
+	// are mined with it. Set it with PayForParent.
+	ParentFeeDeficit uint64
+
+	// Optional maximum number of bytes in data outputs added with AddDataOutput. Zero means no
+	// limit.
+	MaxDataSize int
+
+	// Optional reserver that prevents UTXOs selected by AddFunding from being selected by other
+	// builders at the same time. Set it with SetUTXOReserver.
+	UTXOReserver UTXOReserver
+
+	reserved map[wire.OutPoint]bool // UTXOs reserved by this builder
 }
 
 // NewTxBuilder returns a new TxBuilder with the specified change address.
@@ -0,0 +1,566 @@
+package wire
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/storage"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultAddrManagerPath is the storage path of the address manager.
+	DefaultAddrManagerPath = "wire/addr_manager"
+
+	// AddrBucketCount is the number of buckets addresses are spread across.
+	AddrBucketCount = 64
+
+	// AddrBucketSize is the maximum number of addresses in a bucket.
+	AddrBucketSize = 64
+
+	// addrSourceBuckets is the number of buckets that the addresses announced by one network group
+	//   can be put in. This limits how much of the address manager one source can fill.
+	addrSourceBuckets = 8
+
+	// addrHorizon is how long an address can go without being seen before it is ignored.
+	addrHorizon = 30 * 24 * time.Hour
+
+	// addrMaxFailures is the number of failed attempts, without a success, after which an address
+	//   is removed.
+	addrMaxFailures = 10
+
+	// addrMaxPerMsg is the maximum number of addresses used from one addr message so one peer
+	//   can't fill the address manager at once.
+	addrMaxPerMsg = 100
+
+	// peerRetryDelay is the minimum time between connection attempts to the same address.
+	peerRetryDelay = time.Minute
+
+	addrManagerVersion = uint8(0)
+)
+
+// KnownAddress is a peer address tracked by the address manager.
+type KnownAddress struct {
+	Address     string
+	Services    ServiceFlag
+	Source      string // network group of the peer that announced the address
+	LastSeen    time.Time
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Failures    uint32 // failed attempts since the last success
+}
+
+// AddrManager tracks known peer addresses, scores them by recency and connection success, and
+//   supplies candidates to dial. Addresses are put in buckets based on the network group of the
+//   address and of the peer that announced it, so that one source can't fill the address manager
+//   with addresses it controls. Dial candidates are chosen from distinct network groups to reduce
+//   the risk of all connections going to one operator.
+type AddrManager struct {
+	store storage.Storage
+	path  string
+
+	key       [32]byte // random key so bucket placement can't be predicted
+	addresses map[string]*KnownAddress
+	buckets   [AddrBucketCount]map[string]*KnownAddress
+
+	sync.Mutex
+}
+
+// NewAddrManager creates an empty address manager that persists to the store.
+func NewAddrManager(store storage.Storage) *AddrManager {
+	result := &AddrManager{
+		store: store,
+		path:  DefaultAddrManagerPath,
+	}
+	rand.Read(result.key[:])
+	result.reset()
+	return result
+}
+
+func (m *AddrManager) reset() {
+	m.addresses = make(map[string]*KnownAddress)
+	for i := range m.buckets {
+		m.buckets[i] = make(map[string]*KnownAddress)
+	}
+}
+
+// Load reads the addresses from storage. Missing data is not an error.
+func (m *AddrManager) Load(ctx context.Context) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if err := storage.Load(ctx, m.store, m.path, m); err != nil {
+		if errors.Cause(err) == storage.ErrNotFound {
+			return nil
+		}
+		return errors.Wrap(err, "load addresses")
+	}
+
+	return nil
+}
+
+// Save writes the addresses to storage.
+func (m *AddrManager) Save(ctx context.Context) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if err := storage.Save(ctx, m.store, m.path, m); err != nil {
+		return errors.Wrap(err, "save addresses")
+	}
+
+	return nil
+}
+
+// Count returns the number of known addresses.
+func (m *AddrManager) Count() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return len(m.addresses)
+}
+
+// Address returns a copy of the known address or nil if it isn't known.
+func (m *AddrManager) Address(address string) *KnownAddress {
+	m.Lock()
+	defer m.Unlock()
+
+	ka, exists := m.addresses[address]
+	if !exists {
+		return nil
+	}
+	c := *ka
+	return &c
+}
+
+// AddAddress adds an address announced by the source address. An empty source means the address
+//   came from a local source like a seed or configuration. It returns true if the address was
+//   added or updated.
+func (m *AddrManager) AddAddress(address string, services ServiceFlag, seen time.Time,
+	source string) bool {
+
+	m.Lock()
+	defer m.Unlock()
+
+	return m.addAddress(address, services, seen, NetworkGroup(source), time.Now())
+}
+
+// AddNetAddresses adds addresses received from the peer at the source address. It returns the
+//   number of addresses added or updated.
+func (m *AddrManager) AddNetAddresses(addresses []*NetAddress, source string) int {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	sourceGroup := NetworkGroup(source)
+	count := 0
+	for _, na := range addresses {
+		address := net.JoinHostPort(na.IP.String(), strconv.Itoa(int(na.Port)))
+		if m.addAddress(address, na.Services, na.Timestamp, sourceGroup, now) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// HandleAddr is a MessageHandler that adds addresses from addr messages. Register it with a peer
+//   or peer manager. Only the first addrMaxPerMsg addresses of each message are used.
+//
+// addrv2 messages (BIP155) aren't handled because BSV nodes don't send them, so Tor v3 and I2P
+//   addresses can't be learned from peers.
+func (m *AddrManager) HandleAddr(ctx context.Context, peer *Peer, msg Message) error {
+	addr, ok := msg.(*MsgAddr)
+	if !ok {
+		return nil
+	}
+
+	list := addr.AddrList
+	if len(list) > addrMaxPerMsg {
+		list = list[:addrMaxPerMsg]
+	}
+
+	m.AddNetAddresses(list, peer.Address())
+	return nil
+}
+
+func (m *AddrManager) addAddress(address string, services ServiceFlag, seen time.Time,
+	sourceGroup string, now time.Time) bool {
+
+	if seen.After(now) {
+		seen = now // don't trust times in the future
+	}
+	if now.Sub(seen) > addrHorizon {
+		return false
+	}
+
+	if ka, exists := m.addresses[address]; exists {
+		if seen.After(ka.LastSeen) {
+			ka.LastSeen = seen
+		}
+		ka.Services |= services
+		return true
+	}
+
+	group := NetworkGroup(address)
+	if len(group) == 0 {
+		return false
+	}
+
+	ka := &KnownAddress{
+		Address:  address,
+		Services: services,
+		Source:   sourceGroup,
+		LastSeen: seen,
+	}
+
+	bucket := m.buckets[m.bucketIndex(group, sourceGroup)]
+	if len(bucket) >= AddrBucketSize {
+		// Evict the worst address in the bucket if the new address is better.
+		var worst *KnownAddress
+		worstScore := 0.0
+		for _, other := range bucket {
+			score := other.Score(now)
+			if worst == nil || score < worstScore {
+				worst = other
+				worstScore = score
+			}
+		}
+
+		if ka.Score(now) <= worstScore {
+			return false
+		}
+		m.remove(worst)
+	}
+
+	m.addresses[address] = ka
+	bucket[address] = ka
+	return true
+}
+
+// bucketIndex returns the bucket for an address in the group that was announced by the source
+//   group. Each source group can only place addresses in addrSourceBuckets buckets.
+func (m *AddrManager) bucketIndex(group, sourceGroup string) int {
+	h := fnv.New64a()
+	h.Write(m.key[:])
+	h.Write([]byte(group))
+	h.Write([]byte(sourceGroup))
+	slot := h.Sum64() % addrSourceBuckets
+
+	h.Reset()
+	h.Write(m.key[:])
+	h.Write([]byte(sourceGroup))
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], slot)
+	h.Write(b[:])
+	return int(h.Sum64() % AddrBucketCount)
+}
+
+func (m *AddrManager) remove(ka *KnownAddress) {
+	delete(m.addresses, ka.Address)
+	delete(m.buckets[m.bucketIndex(NetworkGroup(ka.Address), ka.Source)], ka.Address)
+}
+
+// MarkAttempt records that a connection to the address is being attempted.
+func (m *AddrManager) MarkAttempt(address string, t time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	if ka, exists := m.addresses[address]; exists {
+		ka.LastAttempt = t
+	}
+}
+
+// MarkSuccess records a successful connection to the address.
+func (m *AddrManager) MarkSuccess(address string, t time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	if ka, exists := m.addresses[address]; exists {
+		ka.LastSuccess = t
+		ka.LastSeen = t
+		ka.Failures = 0
+	}
+}
+
+// MarkFailure records a failed connection to the address. Addresses that fail too many times
+//   without ever succeeding are removed.
+func (m *AddrManager) MarkFailure(address string) {
+	m.Lock()
+	defer m.Unlock()
+
+	ka, exists := m.addresses[address]
+	if !exists {
+		return
+	}
+
+	ka.Failures++
+	if ka.Failures >= addrMaxFailures && ka.LastSuccess.IsZero() {
+		m.remove(ka)
+	}
+}
+
+// DialCandidates returns up to count addresses to connect to, best first. Addresses in exclude,
+//   usually the currently connected peers, are skipped along with any other address in the same
+//   network group. At most one address is returned from each network group.
+func (m *AddrManager) DialCandidates(count int, exclude []string) []string {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now()
+	excludeAddresses := make(map[string]bool)
+	usedGroups := make(map[string]bool)
+	for _, address := range exclude {
+		excludeAddresses[address] = true
+		usedGroups[NetworkGroup(address)] = true
+	}
+
+	type candidate struct {
+		address string
+		group   string
+		score   float64
+	}
+
+	var candidates []candidate
+	for _, ka := range m.addresses {
+		if excludeAddresses[ka.Address] {
+			continue
+		}
+		if now.Sub(ka.LastAttempt) < peerRetryDelay*time.Duration(ka.Failures+1) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			address: ka.Address,
+			group:   NetworkGroup(ka.Address),
+			score:   ka.Score(now),
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].address < candidates[j].address
+	})
+
+	var result []string
+	for _, c := range candidates {
+		if len(result) == count {
+			break
+		}
+		if usedGroups[c.group] {
+			continue
+		}
+
+		usedGroups[c.group] = true
+		result = append(result, c.address)
+	}
+
+	return result
+}
+
+// Score returns the quality of the address as a connection candidate. Recently seen addresses
+//   and addresses that have been connected to successfully score higher, and each failed attempt
+//   reduces the score.
+func (ka KnownAddress) Score(now time.Time) float64 {
+	result := 1.0 / (1.0 + now.Sub(ka.LastSeen).Hours()/24.0)
+
+	if !ka.LastSuccess.IsZero() {
+		result += 2.0 / (1.0 + now.Sub(ka.LastSuccess).Hours()/24.0)
+	}
+
+	return result * math.Pow(0.66, float64(ka.Failures))
+}
+
+// NetworkGroup returns the network group of an address, in host:port or host form. IPv4
+//   addresses are grouped by /16 and IPv6 addresses by /32, so that addresses likely to be run by
+//   the same operator are in the same group. Local and private addresses are all in one group.
+//   Host names are their own group. It returns an empty string for an empty address.
+func NetworkGroup(address string) string {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	if len(host) == 0 {
+		return ""
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "host:" + host
+	}
+
+	if ip.IsLoopback() || ip.IsUnspecified() || isPrivateIP(ip) {
+		return "local"
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("ipv4:%d.%d", ip4[0], ip4[1])
+	}
+
+	return fmt.Sprintf("ipv6:%x", []byte(ip[:4]))
+}
+
+func isPrivateIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4[0] == 10 ||
+			(ip4[0] == 172 && ip4[1]&0xf0 == 16) ||
+			(ip4[0] == 192 && ip4[1] == 168) ||
+			(ip4[0] == 169 && ip4[1] == 254)
+	}
+
+	return ip[0]&0xfe == 0xfc || (ip[0] == 0xfe && ip[1]&0xc0 == 0x80)
+}
+
+// Serialize writes the address manager in binary form.
+func (m *AddrManager) Serialize(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, addrManagerVersion); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if _, err := w.Write(m.key[:]); err != nil {
+		return errors.Wrap(err, "key")
+	}
+
+	addresses := make([]string, 0, len(m.addresses))
+	for address := range m.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	if err := WriteVarInt(w, 0, uint64(len(addresses))); err != nil {
+		return errors.Wrap(err, "count")
+	}
+
+	for _, address := range addresses {
+		if err := m.addresses[address].Serialize(w); err != nil {
+			return errors.Wrapf(err, "address %s", address)
+		}
+	}
+
+	return nil
+}
+
+// Deserialize reads the address manager from binary form.
+func (m *AddrManager) Deserialize(r io.Reader) error {
+	var version uint8
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	if version != addrManagerVersion {
+		return fmt.Errorf("Unsupported address manager version : %d", version)
+	}
+
+	if _, err := io.ReadFull(r, m.key[:]); err != nil {
+		return errors.Wrap(err, "key")
+	}
+
+	count, err := ReadVarInt(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "count")
+	}
+
+	if count > AddrBucketCount*AddrBucketSize {
+		return fmt.Errorf("Too many addresses : %d > %d", count, AddrBucketCount*AddrBucketSize)
+	}
+
+	m.reset()
+	for i := uint64(0); i < count; i++ {
+		ka := &KnownAddress{}
+		if err := ka.Deserialize(r); err != nil {
+			return errors.Wrapf(err, "address %d", i)
+		}
+
+		m.addresses[ka.Address] = ka
+		m.buckets[m.bucketIndex(NetworkGroup(ka.Address), ka.Source)][ka.Address] = ka
+	}
+
+	return nil
+}
+
+// Serialize writes the known address in binary form.
+func (ka KnownAddress) Serialize(w io.Writer) error {
+	if err := WriteVarString(w, 0, ka.Address); err != nil {
+		return errors.Wrap(err, "address")
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint64(ka.Services)); err != nil {
+		return errors.Wrap(err, "services")
+	}
+
+	if err := WriteVarString(w, 0, ka.Source); err != nil {
+		return errors.Wrap(err, "source")
+	}
+
+	for _, t := range []time.Time{ka.LastSeen, ka.LastAttempt, ka.LastSuccess} {
+		if err := binary.Write(w, binary.LittleEndian, timeToUnix(t)); err != nil {
+			return errors.Wrap(err, "time")
+		}
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, ka.Failures); err != nil {
+		return errors.Wrap(err, "failures")
+	}
+
+	return nil
+}
+
+// Deserialize reads the known address from binary form.
+func (ka *KnownAddress) Deserialize(r io.Reader) error {
+	address, err := ReadVarString(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "address")
+	}
+	ka.Address = address
+
+	var services uint64
+	if err := binary.Read(r, binary.LittleEndian, &services); err != nil {
+		return errors.Wrap(err, "services")
+	}
+	ka.Services = ServiceFlag(services)
+
+	source, err := ReadVarString(r, 0)
+	if err != nil {
+		return errors.Wrap(err, "source")
+	}
+	ka.Source = source
+
+	for _, t := range []*time.Time{&ka.LastSeen, &ka.LastAttempt, &ka.LastSuccess} {
+		var value int64
+		if err := binary.Read(r, binary.LittleEndian, &value); err != nil {
+			return errors.Wrap(err, "time")
+		}
+		*t = unixToTime(value)
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &ka.Failures); err != nil {
+		return errors.Wrap(err, "failures")
+	}
+
+	return nil
+}
+
+func timeToUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func unixToTime(value int64) time.Time {
+	if value == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, value)
+}
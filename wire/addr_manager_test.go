@@ -0,0 +1,241 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/storage"
+)
+
+func TestNetworkGroup(t *testing.T) {
+	tests := []struct {
+		address string
+		group   string
+	}{
+		{"1.2.3.4:8333", "ipv4:1.2"},
+		{"1.2.200.1:8333", "ipv4:1.2"},
+		{"1.3.3.4", "ipv4:1.3"},
+		{"127.0.0.1:8333", "local"},
+		{"192.168.1.10:8333", "local"},
+		{"10.0.0.1:8333", "local"},
+		{"[2001:db8:1:2::1]:8333", "ipv6:20010db8"},
+		{"[::1]:8333", "local"},
+		{"seed.example.com:8333", "host:seed.example.com"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := NetworkGroup(tt.address); got != tt.group {
+				t.Fatalf("Wrong group : got %s, want %s", got, tt.group)
+			}
+		})
+	}
+}
+
+func TestAddrManagerDialCandidates(t *testing.T) {
+	ctx := context.Background()
+	m := NewAddrManager(storage.NewMockStorage())
+	now := time.Now()
+
+	var addrs []*NetAddress
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			addrs = append(addrs, &NetAddress{
+				Timestamp: now,
+				Services:  SFNodeNetwork,
+				IP:        net.IPv4(byte(20+i), 1, byte(j), 1),
+				Port:      8333,
+			})
+		}
+	}
+
+	if count := m.AddNetAddresses(addrs, "5.5.5.5:8333"); count != len(addrs) {
+		t.Fatalf("Wrong added count : got %d, want %d", count, len(addrs))
+	}
+
+	// Old addresses are ignored.
+	if m.AddAddress("30.1.1.1:8333", SFNodeNetwork, now.Add(-2*addrHorizon), "") {
+		t.Fatalf("Old address should not be added")
+	}
+
+	m.MarkSuccess("21.1.2.1:8333", now)
+
+	candidates := m.DialCandidates(10, nil)
+	if len(candidates) != 3 {
+		t.Fatalf("Wrong candidate count : got %d, want %d : %v", len(candidates), 3, candidates)
+	}
+	if candidates[0] != "21.1.2.1:8333" {
+		t.Fatalf("Wrong best candidate : got %s, want %s", candidates[0], "21.1.2.1:8333")
+	}
+
+	groups := make(map[string]bool)
+	for _, address := range candidates {
+		group := NetworkGroup(address)
+		if groups[group] {
+			t.Fatalf("Duplicate network group in candidates : %v", candidates)
+		}
+		groups[group] = true
+	}
+
+	// Groups of connected peers are excluded.
+	candidates = m.DialCandidates(10, []string{"20.1.0.1:8333"})
+	if len(candidates) != 2 {
+		t.Fatalf("Wrong candidate count : got %d, want %d : %v", len(candidates), 2, candidates)
+	}
+	for _, address := range candidates {
+		if NetworkGroup(address) == "ipv4:20.1" {
+			t.Fatalf("Candidate in excluded group : %s", address)
+		}
+	}
+
+	// Recently attempted addresses are skipped.
+	m.MarkAttempt("21.1.2.1:8333", now)
+	candidates = m.DialCandidates(10, nil)
+	for _, address := range candidates {
+		if address == "21.1.2.1:8333" {
+			t.Fatalf("Recently attempted address returned")
+		}
+	}
+
+	// Addresses that never succeed are removed.
+	for i := 0; i < addrMaxFailures; i++ {
+		m.MarkFailure("22.1.0.1:8333")
+	}
+	if m.Address("22.1.0.1:8333") != nil {
+		t.Fatalf("Failing address should be removed")
+	}
+
+	if err := m.Save(ctx); err != nil {
+		t.Fatalf("Failed to save : %s", err)
+	}
+
+	loaded := NewAddrManager(m.store)
+	if err := loaded.Load(ctx); err != nil {
+		t.Fatalf("Failed to load : %s", err)
+	}
+
+	if loaded.Count() != m.Count() {
+		t.Fatalf("Wrong loaded count : got %d, want %d", loaded.Count(), m.Count())
+	}
+	if loaded.key != m.key {
+		t.Fatalf("Wrong loaded key")
+	}
+	for address, ka := range m.addresses {
+		lka := loaded.Address(address)
+		if lka == nil {
+			t.Fatalf("Missing loaded address : %s", address)
+		}
+		ka.LastSeen = time.Unix(0, ka.LastSeen.UnixNano())
+		ka.LastAttempt = unixToTime(timeToUnix(ka.LastAttempt))
+		ka.LastSuccess = unixToTime(timeToUnix(ka.LastSuccess))
+		if !reflect.DeepEqual(lka, ka) {
+			t.Fatalf("Wrong loaded address : \ngot  %+v\nwant %+v", lka, ka)
+		}
+	}
+}
+
+func TestAddrManagerBucketing(t *testing.T) {
+	m := NewAddrManager(storage.NewMockStorage())
+	now := time.Now()
+
+	// One source announcing many addresses can only fill a limited number of buckets.
+	var addrs []*NetAddress
+	for i := 0; i < 100; i++ {
+		for j := 0; j < 50; j++ {
+			addrs = append(addrs, &NetAddress{
+				Timestamp: now,
+				IP:        net.IPv4(byte(i+1), byte(j), 1, 1),
+				Port:      8333,
+			})
+		}
+	}
+
+	m.AddNetAddresses(addrs, "5.5.5.5:8333")
+
+	max := addrSourceBuckets * AddrBucketSize
+	if m.Count() > max {
+		t.Fatalf("Too many addresses from one source : got %d, max %d", m.Count(), max)
+	}
+
+	// Addresses from other sources are still accepted, except where they land in a bucket that
+	// is already full.
+	before := m.Count()
+	for i := 0; i < 20; i++ {
+		address := fmt.Sprintf("200.%d.1.1:8333", i)
+		m.AddAddress(address, SFNodeNetwork, now, fmt.Sprintf("%d.9.9.9:8333", 100+i))
+	}
+	if m.Count() <= before {
+		t.Fatalf("Failed to add addresses from new sources")
+	}
+}
+
+func TestAddrManagerLimits(t *testing.T) {
+	ctx := context.Background()
+	m := NewAddrManager(storage.NewMockStorage())
+	now := time.Now()
+
+	// Only the first addresses of an addr message are used.
+	msg := NewMsgAddr()
+	for i := 0; i < 2*addrMaxPerMsg; i++ {
+		msg.AddAddress(NewNetAddressTimestamp(now, SFNodeNetwork,
+			net.IPv4(byte(20+i%50), byte(i), 1, 1), 8333))
+	}
+	peer := NewPeer("5.5.5.5:8333", DefaultPeerConfig(bitcoin.MainNet))
+	if err := m.HandleAddr(ctx, peer, msg); err != nil {
+		t.Fatalf("Failed to handle addr : %s", err)
+	}
+	if m.Count() > addrMaxPerMsg {
+		t.Fatalf("Too many addresses from one message : got %d, max %d", m.Count(),
+			addrMaxPerMsg)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(addrManagerVersion)
+	buf.Write(m.key[:])
+	WriteVarInt(&buf, 0, 1<<40)
+	if err := NewAddrManager(m.store).Deserialize(&buf); err == nil {
+		t.Fatalf("Deserialized address manager with huge count")
+	}
+}
+
+func TestPeerManagerAddrManager(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+	pm := NewPeerManager(DefaultPeerConfig(bitcoin.MainNet), store, 2)
+
+	for _, address := range []string{"20.1.1.1:8333", "20.1.2.1:8333", "21.1.1.1:8333"} {
+		if !pm.AddAddress(address, time.Now()) {
+			t.Fatalf("Failed to add address %s", address)
+		}
+	}
+
+	// Dial candidates come from distinct network groups.
+	addresses := pm.nextAddresses()
+	if len(addresses) != 2 || NetworkGroup(addresses[0]) == NetworkGroup(addresses[1]) {
+		t.Fatalf("Wrong next addresses : %v", addresses)
+	}
+
+	pm.removePeer(addresses[0], false)
+	if ka := pm.AddrManager().Address(addresses[0]); ka == nil || ka.Failures != 1 {
+		t.Fatalf("Failure not recorded : %+v", ka)
+	}
+
+	if err := pm.Save(ctx); err != nil {
+		t.Fatalf("Failed to save : %s", err)
+	}
+
+	loaded := NewPeerManager(DefaultPeerConfig(bitcoin.MainNet), store, 2)
+	if err := loaded.Load(ctx); err != nil {
+		t.Fatalf("Failed to load : %s", err)
+	}
+	if loaded.AddrManager().Count() != 3 {
+		t.Fatalf("Wrong loaded count : got %d, want %d", loaded.AddrManager().Count(), 3)
+	}
+}
@@ -0,0 +1,143 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrMerkleRootMismatch = errors.New("Merkle root mismatch")
+	ErrBlockIncomplete    = errors.New("Block incomplete")
+)
+
+// BlockStream parses a block from a reader one tx at a time so that blocks of any size can be
+//   processed without holding the whole block in memory. Only the current tx and the hashes
+//   needed to calculate the merkle root are retained.
+//
+//   stream, err := NewBlockStream(r, ProtocolVersion)
+//   for {
+//     tx, err := stream.NextTx()
+//     if err == io.EOF {
+//       break
+//     }
+//     ...
+//   }
+//   if !stream.IsMerkleRootValid() { ... }
+type BlockStream struct {
+	Header  BlockHeader
+	TxCount uint64
+
+	r          io.Reader
+	pver       uint32
+	index      uint64
+	merkleTree *MerkleTree
+}
+
+// BlockTxHandler is called for each tx in a streamed block. The index is the offset of the tx
+//   in the block.
+type BlockTxHandler func(index uint64, tx *MsgTx) error
+
+// NewBlockStream reads the block header and tx count from the reader and returns a stream that
+//   reads the txs.
+func NewBlockStream(r io.Reader, pver uint32) (*BlockStream, error) {
+	result := &BlockStream{
+		r:          r,
+		pver:       pver,
+		merkleTree: NewMerkleTree(true),
+	}
+
+	if err := readBlockHeader(r, pver, &result.Header); err != nil {
+		return nil, errors.Wrap(err, "header")
+	}
+
+	txCount, err := ReadVarInt(r, pver)
+	if err != nil {
+		return nil, errors.Wrap(err, "tx count")
+	}
+
+	// Prevent more transactions than could possibly fit into a block.
+	if txCount > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", txCount, maxTxPerBlock)
+		return nil, messageTypeError("BlockStream", MessageErrorInvalidCount, str)
+	}
+	result.TxCount = txCount
+
+	return result, nil
+}
+
+// NextTx reads the next tx from the block. It returns io.EOF when all txs have been read.
+func (bs *BlockStream) NextTx() (*MsgTx, error) {
+	if bs.index == bs.TxCount {
+		return nil, io.EOF
+	}
+
+	tx := &MsgTx{}
+	if err := tx.BtcDecode(bs.r, bs.pver); err != nil {
+		return nil, errors.Wrapf(err, "tx %d", bs.index)
+	}
+
+	bs.merkleTree.AddHash(*tx.TxHash())
+	bs.index++
+	return tx, nil
+}
+
+// Remaining returns the number of txs that have not been read yet.
+func (bs *BlockStream) Remaining() uint64 {
+	return bs.TxCount - bs.index
+}
+
+// MerkleRoot returns the merkle root hash calculated from the txs. All txs must be read first.
+func (bs *BlockStream) MerkleRoot() (bitcoin.Hash32, error) {
+	if bs.index != bs.TxCount {
+		return bitcoin.Hash32{}, errors.Wrapf(ErrBlockIncomplete, "%d txs remaining",
+			bs.Remaining())
+	}
+
+	return bs.merkleTree.RootHash(), nil
+}
+
+// IsMerkleRootValid returns true if all txs have been read and the calculated merkle root hash
+//   matches the header.
+func (bs *BlockStream) IsMerkleRootValid() bool {
+	merkleRoot, err := bs.MerkleRoot()
+	if err != nil {
+		return false
+	}
+
+	return bs.Header.MerkleRoot.Equal(&merkleRoot)
+}
+
+// StreamBlock reads a block from the reader and calls the handler for each tx. It returns the
+//   block header after verifying the merkle root hash.
+func StreamBlock(r io.Reader, pver uint32, handler BlockTxHandler) (*BlockHeader, error) {
+	stream, err := NewBlockStream(r, pver)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		index := stream.index
+		tx, err := stream.NextTx()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := handler(index, tx); err != nil {
+			return nil, errors.Wrapf(err, "handle tx %d", index)
+		}
+	}
+
+	if !stream.IsMerkleRootValid() {
+		return nil, ErrMerkleRootMismatch
+	}
+
+	return &stream.Header, nil
+}
@@ -0,0 +1,57 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBlockStream(t *testing.T) {
+	block := &MsgBlock{Header: blockOne.Header}
+	for _, tx := range blockOne.Transactions {
+		block.AddTransaction(tx)
+	}
+
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		t.Fatalf("Failed to serialize block : %s", err)
+	}
+
+	var txs []*MsgTx
+	header, err := StreamBlock(bytes.NewReader(buf.Bytes()), ProtocolVersion,
+		func(index uint64, tx *MsgTx) error {
+			if index != uint64(len(txs)) {
+				t.Fatalf("Wrong tx index : got %d, want %d", index, len(txs))
+			}
+			txs = append(txs, tx)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Failed to stream block : %s", err)
+	}
+
+	if !reflect.DeepEqual(*header, block.Header) {
+		t.Fatalf("Wrong header : \ngot  %+v\nwant %+v", header, block.Header)
+	}
+
+	if !reflect.DeepEqual(txs, block.Transactions) {
+		t.Fatalf("Wrong txs")
+	}
+
+	// Corrupt merkle root
+	b := buf.Bytes()
+	b[36] ^= 0xff
+	if _, err := StreamBlock(bytes.NewReader(b), ProtocolVersion,
+		func(index uint64, tx *MsgTx) error { return nil }); err != ErrMerkleRootMismatch {
+		t.Fatalf("Stream with invalid merkle root should fail : %v", err)
+	}
+
+	stream, err := NewBlockStream(bytes.NewReader(b), ProtocolVersion)
+	if err != nil {
+		t.Fatalf("Failed to create block stream : %s", err)
+	}
+
+	if _, err := stream.MerkleRoot(); err == nil {
+		t.Fatalf("Merkle root should fail before txs are read")
+	}
+}
@@ -0,0 +1,309 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+const (
+	ln2Squared = math.Ln2 * math.Ln2
+
+	// bloomHashSeedMultiplier is multiplied by the hash function index and added to the tweak to
+	//   seed each hash function as specified by BIP0037.
+	bloomHashSeedMultiplier = 0xfba4c795
+)
+
+// BloomFilter is a BIP0037 bloom filter used by lightweight clients to request only relevant txs
+//   from peers. It is safe for concurrent use.
+type BloomFilter struct {
+	filter *MsgFilterLoad
+	lock   sync.Mutex
+}
+
+// NewBloomFilter creates a filter sized to hold the specified number of elements with the
+//   specified false positive rate. The size and number of hash functions are limited to the
+//   maximums allowed by the protocol.
+func NewBloomFilter(elements, tweak uint32, fpRate float64,
+	flags BloomUpdateType) *BloomFilter {
+
+	// Clamp the false positive rate to a valid range.
+	fpRate = math.Max(1e-9, math.Min(1.0, fpRate))
+
+	// Calculate the size of the filter in bytes as specified by BIP0037.
+	dataLen := uint32(-1 * float64(elements) * math.Log(fpRate) / ln2Squared / 8)
+	if dataLen > uint32(MaxFilterLoadFilterSize) {
+		dataLen = uint32(MaxFilterLoadFilterSize)
+	}
+	if dataLen == 0 {
+		dataLen = 1
+	}
+
+	// Calculate the number of hash functions as specified by BIP0037.
+	hashFuncs := uint32(float64(dataLen*8) / float64(elements) * math.Ln2)
+	if hashFuncs > MaxFilterLoadHashFuncs {
+		hashFuncs = MaxFilterLoadHashFuncs
+	}
+	if hashFuncs == 0 {
+		hashFuncs = 1
+	}
+
+	return &BloomFilter{
+		filter: NewMsgFilterLoad(make([]byte, dataLen), hashFuncs, tweak, flags),
+	}
+}
+
+// LoadBloomFilter creates a filter from a filterload message received from a peer.
+func LoadBloomFilter(msg *MsgFilterLoad) *BloomFilter {
+	return &BloomFilter{filter: msg}
+}
+
+// IsLoaded returns true if the filter has been loaded.
+func (bf *BloomFilter) IsLoaded() bool {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	return bf.filter != nil
+}
+
+// Reload replaces the filter with a new filterload message. A nil message unloads the filter.
+func (bf *BloomFilter) Reload(msg *MsgFilterLoad) {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	bf.filter = msg
+}
+
+// Unload removes the filter, as requested by a filterclear message.
+func (bf *BloomFilter) Unload() {
+	bf.Reload(nil)
+}
+
+// MsgFilterLoad returns a filterload message that can be sent to a peer to load the filter.
+func (bf *BloomFilter) MsgFilterLoad() *MsgFilterLoad {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	if bf.filter == nil {
+		return nil
+	}
+
+	msg := *bf.filter
+	msg.Filter = make([]byte, len(bf.filter.Filter))
+	copy(msg.Filter, bf.filter.Filter)
+	return &msg
+}
+
+// Add inserts data into the filter, as requested by a filteradd message.
+func (bf *BloomFilter) Add(data []byte) {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	bf.add(data)
+}
+
+// AddHash inserts a hash into the filter.
+func (bf *BloomFilter) AddHash(hash bitcoin.Hash32) {
+	bf.Add(hash[:])
+}
+
+// AddOutPoint inserts an outpoint into the filter.
+func (bf *BloomFilter) AddOutPoint(outpoint *OutPoint) {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	bf.addOutPoint(outpoint)
+}
+
+// Contains returns true if the data might be in the filter. False positives are possible, but
+//   false negatives are not.
+func (bf *BloomFilter) Contains(data []byte) bool {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	return bf.contains(data)
+}
+
+// ContainsOutPoint returns true if the outpoint might be in the filter.
+func (bf *BloomFilter) ContainsOutPoint(outpoint *OutPoint) bool {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	return bf.containsOutPoint(outpoint)
+}
+
+// MatchTxAndUpdate returns true if the tx matches the filter. The tx matches if its hash, any data
+//   pushed by its locking or unlocking scripts, or any of the outpoints it spends are in the
+//   filter. When an output matches, its outpoint is added to the filter as specified by the
+//   filter's update flags so that txs spending it will also match.
+func (bf *BloomFilter) MatchTxAndUpdate(tx *MsgTx) bool {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+
+	if bf.filter == nil {
+		return false
+	}
+
+	txid := tx.TxHash()
+	matched := bf.contains(txid[:])
+
+	for index, txout := range tx.TxOut {
+		if !bf.containsScriptData(txout.LockingScript) {
+			continue
+		}
+		matched = true
+
+		switch bf.filter.Flags {
+		case BloomUpdateAll:
+			bf.addOutPoint(NewOutPoint(txid, uint32(index)))
+		case BloomUpdateP2PubkeyOnly:
+			if _, err := bitcoin.PublicKeyFromLockingScript(txout.LockingScript); err == nil {
+				bf.addOutPoint(NewOutPoint(txid, uint32(index)))
+			}
+		}
+	}
+
+	if matched {
+		return true
+	}
+
+	for _, txin := range tx.TxIn {
+		if bf.containsOutPoint(&txin.PreviousOutPoint) {
+			return true
+		}
+
+		if bf.containsScriptData(txin.UnlockingScript) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (bf *BloomFilter) add(data []byte) {
+	if bf.filter == nil {
+		return
+	}
+
+	bitCount := uint32(len(bf.filter.Filter)) * 8
+	for i := uint32(0); i < bf.filter.HashFuncs; i++ {
+		index := bf.hash(i, data) % bitCount
+		bf.filter.Filter[index>>3] |= 1 << (index & 7)
+	}
+}
+
+func (bf *BloomFilter) contains(data []byte) bool {
+	if bf.filter == nil {
+		return false
+	}
+
+	bitCount := uint32(len(bf.filter.Filter)) * 8
+	if bitCount == 0 {
+		return false
+	}
+
+	for i := uint32(0); i < bf.filter.HashFuncs; i++ {
+		index := bf.hash(i, data) % bitCount
+		if bf.filter.Filter[index>>3]&(1<<(index&7)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (bf *BloomFilter) addOutPoint(outpoint *OutPoint) {
+	bf.add(outPointBytes(outpoint))
+}
+
+func (bf *BloomFilter) containsOutPoint(outpoint *OutPoint) bool {
+	return bf.contains(outPointBytes(outpoint))
+}
+
+// containsScriptData returns true if any data pushed by the script is in the filter.
+func (bf *BloomFilter) containsScriptData(script []byte) bool {
+	buf := bytes.NewReader(script)
+	for buf.Len() > 0 {
+		item, err := bitcoin.ParseScript(buf)
+		if err != nil {
+			return false
+		}
+
+		if item.Type == bitcoin.ScriptItemTypePushData && len(item.Data) > 0 &&
+			bf.contains(item.Data) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hash returns the value of the specified hash function for the data.
+func (bf *BloomFilter) hash(hashIndex uint32, data []byte) uint32 {
+	return murmurHash3(hashIndex*bloomHashSeedMultiplier+bf.filter.Tweak, data)
+}
+
+// outPointBytes returns the serialized outpoint as it is inserted into a filter.
+func outPointBytes(outpoint *OutPoint) []byte {
+	b := make([]byte, bitcoin.Hash32Size+4)
+	copy(b, outpoint.Hash[:])
+	binary.LittleEndian.PutUint32(b[bitcoin.Hash32Size:], outpoint.Index)
+	return b
+}
+
+// murmurHash3 implements the 32 bit version of the MurmurHash3 algorithm used by BIP0037.
+func murmurHash3(seed uint32, data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+		r1 = 15
+		r2 = 13
+		m  = 5
+		n  = 0xe6546b64
+	)
+
+	hash := seed
+	length := len(data)
+	blocks := length / 4
+	for i := 0; i < blocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = (k << r1) | (k >> (32 - r1))
+		k *= c2
+
+		hash ^= k
+		hash = (hash << r2) | (hash >> (32 - r2))
+		hash = hash*m + n
+	}
+
+	// Process the remaining bytes.
+	tail := data[blocks*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = (k << r1) | (k >> (32 - r1))
+		k *= c2
+		hash ^= k
+	}
+
+	// Finalize.
+	hash ^= uint32(length)
+	hash ^= hash >> 16
+	hash *= 0x85ebca6b
+	hash ^= hash >> 13
+	hash *= 0xc2b2ae35
+	hash ^= hash >> 16
+
+	return hash
+}
@@ -0,0 +1,110 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMurmurHash3(t *testing.T) {
+	tests := []struct {
+		seed uint32
+		data []byte
+		want uint32
+	}{
+		{0x00000000, []byte{}, 0x00000000},
+		{0xfba4c795, []byte{}, 0x6a396f08},
+		{0xffffffff, []byte{}, 0x81f16f39},
+		{0x00000000, []byte{0x00}, 0x514e28b7},
+		{0xfba4c795, []byte{0x00}, 0xea3f0b17},
+		{0x00000000, []byte{0xff}, 0xfd6cf10d},
+		{0x00000000, []byte{0x00, 0x11}, 0x16c6b7ab},
+		{0x00000000, []byte{0x00, 0x11, 0x22}, 0x8eb51c3d},
+		{0x00000000, []byte{0x00, 0x11, 0x22, 0x33}, 0xb4471bf8},
+		{0x00000000, []byte{0x00, 0x11, 0x22, 0x33, 0x44}, 0xe2301fa8},
+		{0x00000000, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, 0xfc2e4a15},
+		{0x00000000, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66}, 0xb074502c},
+		{0x00000000, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77}, 0x8034d2a0},
+		{0x00000000, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}, 0xb4698def},
+	}
+
+	for i, tt := range tests {
+		got := murmurHash3(tt.seed, tt.data)
+		if got != tt.want {
+			t.Errorf("Test %d : got 0x%08x, want 0x%08x", i, got, tt.want)
+		}
+	}
+}
+
+func TestBloomFilterInsert(t *testing.T) {
+	filter := NewBloomFilter(3, 0, 0.01, BloomUpdateAll)
+
+	items := []string{
+		"99108ad8ed9bb6274d3980bab5a85c048f0950c8",
+		"b5a2c786d9ef4658287ced5914b37a1b4aa32eee",
+		"b9300670b4c5366e95b2699e8b18bc75e5f729c5",
+	}
+
+	for _, item := range items {
+		b, _ := hex.DecodeString(item)
+		filter.Add(b)
+		if !filter.Contains(b) {
+			t.Fatalf("Filter doesn't contain inserted item %s", item)
+		}
+	}
+
+	missing, _ := hex.DecodeString("19108ad8ed9bb6274d3980bab5a85c048f0950c8")
+	if filter.Contains(missing) {
+		t.Fatalf("Filter contains item that wasn't inserted")
+	}
+
+	var buf bytes.Buffer
+	if err := filter.MsgFilterLoad().BtcEncode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("Failed to encode filter : %s", err)
+	}
+
+	want, _ := hex.DecodeString("03614e9b050000000000000001")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("Wrong filter encoding : got %x, want %x", buf.Bytes(), want)
+	}
+}
+
+func TestBloomFilterMatchTxAndUpdate(t *testing.T) {
+	filter := NewBloomFilter(10, 0, 0.0001, BloomUpdateAll)
+
+	pkh := bytes.Repeat([]byte{0x12}, 20)
+	filter.Add(pkh)
+
+	lockingScript := append([]byte{0x76, 0xa9, 0x14}, pkh...)
+	lockingScript = append(lockingScript, 0x88, 0xac)
+
+	tx := NewMsgTx(1)
+	tx.AddTxIn(NewTxIn(NewOutPoint(&multiTx.TxIn[0].PreviousOutPoint.Hash, 0), nil))
+	tx.AddTxOut(NewTxOut(1000, lockingScript))
+
+	if !filter.MatchTxAndUpdate(tx) {
+		t.Fatalf("Tx doesn't match filter")
+	}
+
+	// The matching output should have been added to the filter so the spending tx matches.
+	spend := NewMsgTx(1)
+	spend.AddTxIn(NewTxIn(NewOutPoint(tx.TxHash(), 0), nil))
+	spend.AddTxOut(NewTxOut(900, []byte{0x6a}))
+
+	if !filter.MatchTxAndUpdate(spend) {
+		t.Fatalf("Spending tx doesn't match filter")
+	}
+
+	other := NewMsgTx(1)
+	other.AddTxIn(NewTxIn(NewOutPoint(tx.TxHash(), 1), nil))
+	other.AddTxOut(NewTxOut(900, []byte{0x6a}))
+
+	if filter.MatchTxAndUpdate(other) {
+		t.Fatalf("Unrelated tx matches filter")
+	}
+
+	filter.Unload()
+	if filter.MatchTxAndUpdate(tx) {
+		t.Fatalf("Unloaded filter matches tx")
+	}
+}
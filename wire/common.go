@@ -5,6 +5,7 @@
 package wire
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
@@ -18,6 +19,15 @@ import (
 const (
 	// MaxVarIntPayload is the maximum payload size for a variable length integer.
 	MaxVarIntPayload = uint64(9)
+
+	// maxPreallocBytes is the largest buffer that is allocated based on a length read from a
+	// message before the data is received. Larger buffers grow as the data is read so a malicious
+	// peer can't cause large allocations by sending a large length.
+	maxPreallocBytes = uint64(1024 * 1024)
+
+	// maxPreallocCount is the largest number of items that is allocated based on a count read from
+	// a message before the items are received.
+	maxPreallocCount = uint64(10000)
 )
 
 var (
@@ -479,11 +489,10 @@ func ReadVarString(r io.Reader, pver uint32) (string, error) {
 	if count > MaxMessagePayload {
 		str := fmt.Sprintf("variable length string is too long "+"[count %d, max %d]", count,
 			MaxMessagePayload)
-		return "", messageError("ReadVarString", str)
+		return "", messageTypeError("ReadVarString", MessageErrorTooLarge, str)
 	}
 
-	buf := make([]byte, count)
-	_, err = io.ReadFull(r, buf)
+	buf, err := readBytes(r, count)
 	if err != nil {
 		return "", err
 	}
@@ -523,15 +532,44 @@ func ReadVarBytes(r io.Reader, pver uint32, maxAllowed uint64,
 	if count > uint64(maxAllowed) {
 		str := fmt.Sprintf("%s is larger than the max allowed size "+
 			"[count %d, max %d]", fieldName, count, maxAllowed)
-		return nil, messageError("ReadVarBytes", str)
+		return nil, messageTypeError("ReadVarBytes", MessageErrorTooLarge, str)
+	}
+
+	return readBytes(r, count)
+}
+
+// readBytes reads count bytes from r. Buffers larger than maxPreallocBytes grow as the data is
+// read instead of being allocated up front. Like io.ReadFull, it returns io.EOF if no bytes were
+// read and io.ErrUnexpectedEOF if only some of the bytes were read.
+func readBytes(r io.Reader, count uint64) ([]byte, error) {
+	if count <= maxPreallocBytes {
+		b := make([]byte, count)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b, nil
 	}
 
-	b := make([]byte, count)
-	_, err = io.ReadFull(r, b)
+	var buf bytes.Buffer
+	buf.Grow(int(maxPreallocBytes))
+	n, err := io.CopyN(&buf, r, int64(count))
 	if err != nil {
+		if err == io.EOF && n > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
-	return b, nil
+
+	return buf.Bytes(), nil
+}
+
+// preallocCount returns the number of items to allocate for a count read from a message. The
+// rest of the items should be appended as they are read.
+func preallocCount(count uint64) uint64 {
+	if count > maxPreallocCount {
+		return maxPreallocCount
+	}
+	return count
 }
 
 // WriteVarBytes serializes a variable length byte array to w as a varInt
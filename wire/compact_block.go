@@ -0,0 +1,272 @@
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/bits"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrShortIDCollision means two txs in a compact block have the same short id so the block
+	// can't be reconstructed and the full block must be requested.
+	ErrShortIDCollision = errors.New("Short id collision")
+
+	ErrWrongBlockHash   = errors.New("Wrong block hash")
+	ErrWrongTxCount     = errors.New("Wrong tx count")
+	ErrMissingTxs       = errors.New("Missing txs")
+	ErrInvalidPrefilled = errors.New("Invalid prefilled tx index")
+)
+
+// ShortIDKeys returns the SipHash keys used to calculate short ids for a compact block. They are
+// the first two little endian 64 bit integers of the SHA256 of the block header and the nonce.
+func ShortIDKeys(header *BlockHeader, nonce uint64) (uint64, uint64) {
+	var buf bytes.Buffer
+	writeBlockHeader(&buf, 0, header)
+	writeElement(&buf, nonce)
+
+	hash := sha256.Sum256(buf.Bytes())
+	return endian.Uint64(hash[0:8]), endian.Uint64(hash[8:16])
+}
+
+// ShortID returns the 6 byte short id of the txid for a compact block.
+func ShortID(k0, k1 uint64, txid bitcoin.Hash32) uint64 {
+	return sipHash24(k0, k1, txid[:]) & 0x0000ffffffffffff
+}
+
+// NewMsgCmpctBlock creates a compact block from a block. The coinbase tx and the txs at the
+// specified indexes are included in full. All other txs are represented by short ids.
+func NewMsgCmpctBlock(block *MsgBlock, nonce uint64, prefill []uint64) *MsgCmpctBlock {
+	result := &MsgCmpctBlock{
+		Header: block.Header,
+		Nonce:  nonce,
+	}
+
+	prefilled := make(map[uint64]bool)
+	prefilled[0] = true
+	for _, index := range prefill {
+		prefilled[index] = true
+	}
+
+	k0, k1 := ShortIDKeys(&block.Header, nonce)
+	for i, tx := range block.Transactions {
+		index := uint64(i)
+		if prefilled[index] {
+			result.PrefilledTxs = append(result.PrefilledTxs, &PrefilledTx{
+				Index: index,
+				Tx:    tx,
+			})
+			continue
+		}
+
+		result.ShortIDs = append(result.ShortIDs, ShortID(k0, k1, *tx.TxHash()))
+	}
+
+	return result
+}
+
+// PartialBlock reconstructs a block from a compact block and txs that are already known, like
+// those in the mempool.
+//
+//   partial, err := NewPartialBlock(cmpctBlock, mempoolTxs)
+//   if request := partial.GetBlockTxn(); request != nil {
+//     // send request and wait for blocktxn response
+//     err := partial.AddBlockTxn(blockTxn)
+//   }
+//   block, err := partial.Block()
+type PartialBlock struct {
+	Header BlockHeader
+
+	txs     []*MsgTx
+	missing []uint64
+}
+
+// NewPartialBlock creates a partial block from a compact block, filling in the txs that are
+// found in known. Txs that are in known more than once are only counted once. ErrShortIDCollision
+// is returned when the compact block contains duplicate short ids, in which case the full block
+// must be requested.
+func NewPartialBlock(msg *MsgCmpctBlock, known []*MsgTx) (*PartialBlock, error) {
+	txCount := msg.TxCount()
+	result := &PartialBlock{
+		Header: msg.Header,
+		txs:    make([]*MsgTx, txCount),
+	}
+
+	for _, prefilled := range msg.PrefilledTxs {
+		if prefilled.Index >= txCount || result.txs[prefilled.Index] != nil {
+			return nil, errors.Wrapf(ErrInvalidPrefilled, "%d", prefilled.Index)
+		}
+		result.txs[prefilled.Index] = prefilled.Tx
+	}
+
+	// Map short ids to the indexes of the txs that are not prefilled.
+	shortIDIndexes := make(map[uint64]uint64, len(msg.ShortIDs))
+	shortIDOffset := 0
+	for index := uint64(0); index < txCount; index++ {
+		if result.txs[index] != nil {
+			continue
+		}
+
+		shortID := msg.ShortIDs[shortIDOffset]
+		shortIDOffset++
+		if _, exists := shortIDIndexes[shortID]; exists {
+			return nil, ErrShortIDCollision
+		}
+		shortIDIndexes[shortID] = index
+	}
+
+	k0, k1 := ShortIDKeys(&msg.Header, msg.Nonce)
+	collisions := make(map[uint64]bool)
+	seen := make(map[bitcoin.Hash32]bool, len(known))
+	for _, tx := range known {
+		txid := *tx.TxHash()
+		if seen[txid] {
+			continue // the same tx is not a collision
+		}
+		seen[txid] = true
+
+		shortID := ShortID(k0, k1, txid)
+		index, exists := shortIDIndexes[shortID]
+		if !exists {
+			continue
+		}
+
+		if result.txs[index] != nil {
+			// Two known txs match the same short id, so neither can be trusted.
+			collisions[index] = true
+			continue
+		}
+		result.txs[index] = tx
+	}
+
+	for index := range collisions {
+		result.txs[index] = nil
+	}
+
+	for index, tx := range result.txs {
+		if tx == nil {
+			result.missing = append(result.missing, uint64(index))
+		}
+	}
+
+	return result, nil
+}
+
+// MissingIndexes returns the indexes of the txs that still need to be retrieved.
+func (pb *PartialBlock) MissingIndexes() []uint64 {
+	return pb.missing
+}
+
+// IsComplete returns true when all txs are known.
+func (pb *PartialBlock) IsComplete() bool {
+	return len(pb.missing) == 0
+}
+
+// GetBlockTxn returns a message requesting the missing txs, or nil if no txs are missing.
+func (pb *PartialBlock) GetBlockTxn() *MsgGetBlockTxn {
+	if len(pb.missing) == 0 {
+		return nil
+	}
+
+	indexes := make([]uint64, len(pb.missing))
+	copy(indexes, pb.missing)
+	return NewMsgGetBlockTxn(*pb.Header.BlockHash(), indexes)
+}
+
+// AddBlockTxn fills in the missing txs from a blocktxn response to the request from
+// GetBlockTxn.
+func (pb *PartialBlock) AddBlockTxn(msg *MsgBlockTxn) error {
+	if !msg.BlockHash.Equal(pb.Header.BlockHash()) {
+		return ErrWrongBlockHash
+	}
+
+	if len(msg.Txs) != len(pb.missing) {
+		return errors.Wrapf(ErrWrongTxCount, "got %d, want %d", len(msg.Txs), len(pb.missing))
+	}
+
+	for i, index := range pb.missing {
+		pb.txs[index] = msg.Txs[i]
+	}
+	pb.missing = nil
+
+	return nil
+}
+
+// Block returns the reconstructed block after verifying the merkle root hash.
+// ErrMerkleRootMismatch is returned if a known tx had a short id that matched a different tx in
+// the block, in which case the full block must be requested.
+func (pb *PartialBlock) Block() (*MsgBlock, error) {
+	if len(pb.missing) != 0 {
+		return nil, errors.Wrapf(ErrMissingTxs, "%d", len(pb.missing))
+	}
+
+	merkleTree := NewMerkleTree(true)
+	for _, tx := range pb.txs {
+		merkleTree.AddHash(*tx.TxHash())
+	}
+
+	merkleRoot := merkleTree.RootHash()
+	if !merkleRoot.Equal(&pb.Header.MerkleRoot) {
+		return nil, ErrMerkleRootMismatch
+	}
+
+	return &MsgBlock{
+		Header:       pb.Header,
+		Transactions: pb.txs,
+	}, nil
+}
+
+// sipHash24 calculates the SipHash-2-4 of the data with the keys.
+func sipHash24(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	length := len(data)
+	for len(data) >= 8 {
+		m := endian.Uint64(data[:8])
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+		data = data[8:]
+	}
+
+	var last [8]byte
+	copy(last[:], data)
+	last[7] = byte(length)
+	m := endian.Uint64(last[:])
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+
+	return v0 ^ v1 ^ v2 ^ v3
+}
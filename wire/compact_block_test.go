@@ -0,0 +1,120 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSipHash24(t *testing.T) {
+	// Test vector from the SipHash reference implementation.
+	data := make([]byte, 15)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	got := sipHash24(0x0706050403020100, 0x0f0e0d0c0b0a0908, data)
+	if got != 0xa129ca6149be45e5 {
+		t.Fatalf("Wrong siphash : got %x, want %x", got, uint64(0xa129ca6149be45e5))
+	}
+}
+
+func TestCompactBlock(t *testing.T) {
+	block := &MsgBlock{Header: blockOne.Header}
+	block.AddTransaction(blockOne.Transactions[0])
+	for i := 0; i < 4; i++ {
+		tx := multiTx.Copy()
+		tx.LockTime = uint32(i)
+		block.AddTransaction(tx)
+	}
+
+	merkleTree := NewMerkleTree(true)
+	for _, tx := range block.Transactions {
+		merkleTree.AddHash(*tx.TxHash())
+	}
+	block.Header.MerkleRoot = merkleTree.RootHash()
+
+	cmpct := NewMsgCmpctBlock(block, 12345, []uint64{3})
+	if len(cmpct.PrefilledTxs) != 2 || len(cmpct.ShortIDs) != 3 {
+		t.Fatalf("Wrong compact block : %d prefilled, %d short ids", len(cmpct.PrefilledTxs),
+			len(cmpct.ShortIDs))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, cmpct, ProtocolVersion, MainNet); err != nil {
+		t.Fatalf("Failed to write compact block : %s", err)
+	}
+
+	msg, _, err := ReadMessage(&buf, ProtocolVersion, MainNet)
+	if err != nil {
+		t.Fatalf("Failed to read compact block : %s", err)
+	}
+
+	readCmpct, ok := msg.(*MsgCmpctBlock)
+	if !ok {
+		t.Fatalf("Wrong message type : %s", msg.Command())
+	}
+
+	if !reflect.DeepEqual(readCmpct, cmpct) {
+		t.Fatalf("Wrong compact block : \ngot  %+v\nwant %+v", readCmpct, cmpct)
+	}
+
+	// Only tx 1 is known so txs 2 and 4 are missing. A tx that is known more than once is not a
+	// collision.
+	duplicate := block.Transactions[1].Copy()
+	partial, err := NewPartialBlock(readCmpct, []*MsgTx{block.Transactions[1], duplicate})
+	if err != nil {
+		t.Fatalf("Failed to create partial block : %s", err)
+	}
+
+	if !reflect.DeepEqual(partial.MissingIndexes(), []uint64{2, 4}) {
+		t.Fatalf("Wrong missing indexes : %v", partial.MissingIndexes())
+	}
+
+	request := partial.GetBlockTxn()
+	if err := WriteMessage(&buf, request, ProtocolVersion, MainNet); err != nil {
+		t.Fatalf("Failed to write getblocktxn : %s", err)
+	}
+
+	msg, _, err = ReadMessage(&buf, ProtocolVersion, MainNet)
+	if err != nil {
+		t.Fatalf("Failed to read getblocktxn : %s", err)
+	}
+
+	readRequest := msg.(*MsgGetBlockTxn)
+	if !reflect.DeepEqual(readRequest, request) {
+		t.Fatalf("Wrong getblocktxn : \ngot  %+v\nwant %+v", readRequest, request)
+	}
+
+	var txs []*MsgTx
+	for _, index := range readRequest.Indexes {
+		txs = append(txs, block.Transactions[index])
+	}
+
+	if err := WriteMessage(&buf, NewMsgBlockTxn(readRequest.BlockHash, txs), ProtocolVersion,
+		MainNet); err != nil {
+		t.Fatalf("Failed to write blocktxn : %s", err)
+	}
+
+	msg, _, err = ReadMessage(&buf, ProtocolVersion, MainNet)
+	if err != nil {
+		t.Fatalf("Failed to read blocktxn : %s", err)
+	}
+
+	if _, err := partial.Block(); err == nil {
+		t.Fatalf("Block should fail with missing txs")
+	}
+
+	if err := partial.AddBlockTxn(msg.(*MsgBlockTxn)); err != nil {
+		t.Fatalf("Failed to add blocktxn : %s", err)
+	}
+
+	reconstructed, err := partial.Block()
+	if err != nil {
+		t.Fatalf("Failed to reconstruct block : %s", err)
+	}
+
+	if !reflect.DeepEqual(reconstructed, block) {
+		t.Fatalf("Wrong reconstructed block")
+	}
+}
@@ -0,0 +1,270 @@
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DSNTVersion is the version of double spend notification outputs created by this package.
+	DSNTVersion = uint8(1)
+
+	// dsntVersionMask is the bits of the version byte that contain the version number.
+	dsntVersionMask = uint8(0x1f)
+
+	// dsntFlagIPv6 is the bit of the version byte that is set when callback addresses are IPv6.
+	dsntFlagIPv6 = uint8(0x80)
+
+	// maxDSNTCount is the maximum number of callback addresses or inputs in a double spend
+	// notification output.
+	maxDSNTCount = 1000
+)
+
+var (
+	// DSNTProtocolID is the protocol identifier pushed after OP_RETURN in double spend notification
+	// outputs.
+	DSNTProtocolID = []byte{0x64, 0x73, 0x6e, 0x74} // "dsnt"
+
+	// ErrNotDSNT means a locking script is not a double spend notification output.
+	ErrNotDSNT = errors.New("Not Double Spend Notification")
+)
+
+// DSNTOutput is a double spend notification output. It is an OP_FALSE OP_RETURN output added to a
+// tx to ask nodes that see a double spend of the tx's inputs to send a double spend proof to the
+// callback addresses.
+//
+// The payload, pushed after the protocol ID, is a version byte with the IPv6 flag in the top bit,
+// a var int count of callback IP addresses followed by the addresses, and a var int count of
+// input indexes followed by the indexes as var ints. No input indexes means all inputs.
+type DSNTOutput struct {
+	Version   uint8
+	Callbacks []net.IP
+	Inputs    []uint32
+}
+
+// NewDSNTOutput creates a double spend notification output for the callback addresses. All of
+// the callback addresses must be either IPv4 or IPv6.
+func NewDSNTOutput(callbacks []net.IP, inputs []uint32) *DSNTOutput {
+	return &DSNTOutput{
+		Version:   DSNTVersion,
+		Callbacks: callbacks,
+		Inputs:    inputs,
+	}
+}
+
+// IsIPv6 returns true if the callback addresses are IPv6.
+func (o DSNTOutput) IsIPv6() bool {
+	for _, ip := range o.Callbacks {
+		if ip.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CoversInput returns true if double spends of the input index should be reported.
+func (o DSNTOutput) CoversInput(index uint32) bool {
+	if len(o.Inputs) == 0 {
+		return true
+	}
+
+	for _, input := range o.Inputs {
+		if input == index {
+			return true
+		}
+	}
+	return false
+}
+
+// LockingScript returns the OP_FALSE OP_RETURN locking script containing the notification.
+func (o DSNTOutput) LockingScript() (bitcoin.Script, error) {
+	isIPv6 := o.IsIPv6()
+
+	version := o.Version & dsntVersionMask
+	if isIPv6 {
+		version |= dsntFlagIPv6
+	}
+
+	payload := &bytes.Buffer{}
+	payload.WriteByte(version)
+
+	if err := WriteVarInt(payload, 0, uint64(len(o.Callbacks))); err != nil {
+		return nil, errors.Wrap(err, "callback count")
+	}
+
+	for i, ip := range o.Callbacks {
+		if isIPv6 {
+			ip = ip.To16()
+		} else {
+			ip = ip.To4()
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid callback address %d", i)
+		}
+		payload.Write(ip)
+	}
+
+	if err := WriteVarInt(payload, 0, uint64(len(o.Inputs))); err != nil {
+		return nil, errors.Wrap(err, "input count")
+	}
+
+	for _, input := range o.Inputs {
+		if err := WriteVarInt(payload, 0, uint64(input)); err != nil {
+			return nil, errors.Wrap(err, "input")
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(bitcoin.OP_FALSE)
+	buf.WriteByte(bitcoin.OP_RETURN)
+	if err := bitcoin.WritePushDataScript(buf, DSNTProtocolID); err != nil {
+		return nil, errors.Wrap(err, "protocol id")
+	}
+	if err := bitcoin.WritePushDataScript(buf, payload.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "payload")
+	}
+
+	return bitcoin.Script(buf.Bytes()), nil
+}
+
+// ParseDSNTOutput parses a double spend notification from a locking script. It returns
+// ErrNotDSNT if the locking script isn't a double spend notification.
+func ParseDSNTOutput(lockingScript bitcoin.Script) (*DSNTOutput, error) {
+	buf := bytes.NewReader(lockingScript)
+
+	if b, err := buf.ReadByte(); err != nil || b != bitcoin.OP_FALSE {
+		return nil, ErrNotDSNT
+	}
+	if b, err := buf.ReadByte(); err != nil || b != bitcoin.OP_RETURN {
+		return nil, ErrNotDSNT
+	}
+
+	item, err := bitcoin.ParseScript(buf)
+	if err != nil || item.Type != bitcoin.ScriptItemTypePushData ||
+		!bytes.Equal(item.Data, DSNTProtocolID) {
+		return nil, ErrNotDSNT
+	}
+
+	item, err = bitcoin.ParseScript(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "payload")
+	}
+	if item.Type != bitcoin.ScriptItemTypePushData {
+		return nil, errors.New("Payload not push data")
+	}
+
+	payload := bytes.NewReader(item.Data)
+	version, err := payload.ReadByte()
+	if err != nil {
+		return nil, errors.Wrap(err, "version")
+	}
+
+	result := &DSNTOutput{
+		Version: version & dsntVersionMask,
+	}
+
+	if result.Version != DSNTVersion {
+		return nil, fmt.Errorf("Unsupported dsnt version : %d", result.Version)
+	}
+
+	ipSize := net.IPv4len
+	if version&dsntFlagIPv6 != 0 {
+		ipSize = net.IPv6len
+	}
+
+	count, err := ReadVarInt(payload, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "callback count")
+	}
+	if count > maxDSNTCount {
+		return nil, fmt.Errorf("Too many callbacks : %d", count)
+	}
+
+	for i := uint64(0); i < count; i++ {
+		ip := make(net.IP, ipSize)
+		if _, err := io.ReadFull(payload, ip); err != nil {
+			return nil, errors.Wrapf(err, "callback %d", i)
+		}
+		result.Callbacks = append(result.Callbacks, ip)
+	}
+
+	count, err = ReadVarInt(payload, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "input count")
+	}
+	if count > maxDSNTCount {
+		return nil, fmt.Errorf("Too many inputs : %d", count)
+	}
+
+	for i := uint64(0); i < count; i++ {
+		input, err := ReadVarInt(payload, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "input %d", i)
+		}
+		result.Inputs = append(result.Inputs, uint32(input))
+	}
+
+	return result, nil
+}
+
+// FindDSNTOutput returns the index and contents of the first double spend notification output in
+// the tx. It returns ErrNotDSNT if there isn't one.
+func FindDSNTOutput(tx *MsgTx) (int, *DSNTOutput, error) {
+	for index, output := range tx.TxOut {
+		dsnt, err := ParseDSNTOutput(output.LockingScript)
+		if err == nil {
+			return index, dsnt, nil
+		}
+		if errors.Cause(err) != ErrNotDSNT {
+			return index, nil, errors.Wrapf(err, "output %d", index)
+		}
+	}
+
+	return -1, nil, ErrNotDSNT
+}
+
+// DSNTQueryURL returns the URL used to ask a callback service whether it wants a double spend
+// proof for the tx.
+func DSNTQueryURL(callback net.IP, txid bitcoin.Hash32) string {
+	u := url.URL{
+		Scheme:   "http",
+		Host:     callback.String(),
+		Path:     "/dsnt/1/query",
+		RawQuery: url.Values{"txid": []string{txid.String()}}.Encode(),
+	}
+	if callback.To4() == nil {
+		u.Host = "[" + callback.String() + "]"
+	}
+	return u.String()
+}
+
+// DSNTSubmitURL returns the URL used to post a double spend proof to a callback service. txid and
+// index identify the input of the tx with the notification output, and conflictingTxID and
+// conflictingIndex identify the input of the tx that double spends it.
+func DSNTSubmitURL(callback net.IP, txid bitcoin.Hash32, index uint32,
+	conflictingTxID bitcoin.Hash32, conflictingIndex uint32) string {
+
+	values := url.Values{}
+	values.Set("txid", txid.String())
+	values.Set("n", fmt.Sprintf("%d", index))
+	values.Set("ctxid", conflictingTxID.String())
+	values.Set("cn", fmt.Sprintf("%d", conflictingIndex))
+
+	u := url.URL{
+		Scheme:   "http",
+		Host:     callback.String(),
+		Path:     "/dsnt/1/submit",
+		RawQuery: values.Encode(),
+	}
+	if callback.To4() == nil {
+		u.Host = "[" + callback.String() + "]"
+	}
+	return u.String()
+}
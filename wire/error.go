@@ -45,11 +45,44 @@ func messageTypeError(f string, t int, desc string) *MessageError {
 	return &MessageError{Func: f, Type: t, Description: desc}
 }
 
+// Is returns true if the target is a message error with the same type and no function or
+// description, like ErrMessageTooLarge. This allows errors.Is to check the type of a message
+// error.
+func (e *MessageError) Is(target error) bool {
+	t, ok := target.(*MessageError)
+	if !ok {
+		return false
+	}
+
+	if len(t.Func) == 0 && len(t.Description) == 0 {
+		return e.Type == t.Type
+	}
+
+	return e == t
+}
+
 const (
 	MessageErrorUndefined        = 0
 	MessageErrorConnectionClosed = 1
 	MessageErrorWrongNetwork     = 2
 	MessageErrorUnknownCommand   = 3
+	MessageErrorTooLarge         = 4
+	MessageErrorInvalidCount     = 5
+	MessageErrorInvalidChecksum  = 6
+)
+
+var (
+	// ErrMessageTooLarge matches, with errors.Is, message errors for data that is larger than
+	// allowed.
+	ErrMessageTooLarge = &MessageError{Type: MessageErrorTooLarge}
+
+	// ErrInvalidCount matches, with errors.Is, message errors for item counts that are larger
+	// than allowed.
+	ErrInvalidCount = &MessageError{Type: MessageErrorInvalidCount}
+
+	// ErrInvalidChecksum matches, with errors.Is, message errors for payloads that don't match
+	// the checksum in the message header.
+	ErrInvalidChecksum = &MessageError{Type: MessageErrorInvalidChecksum}
 )
 
 func messageErrorTypeName(t int) string {
@@ -62,6 +95,12 @@ func messageErrorTypeName(t int) string {
 		return "Wrong Network"
 	case MessageErrorUnknownCommand:
 		return "Unknown Command"
+	case MessageErrorTooLarge:
+		return "Too Large"
+	case MessageErrorInvalidCount:
+		return "Invalid Count"
+	case MessageErrorInvalidChecksum:
+		return "Invalid Checksum"
 	default:
 		return ""
 	}
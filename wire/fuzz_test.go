@@ -0,0 +1,141 @@
+package wire
+
+import (
+	"bytes"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// fuzzCommands are the commands of the messages that are decoded from random data.
+var fuzzCommands = []string{
+	CmdVersion, CmdVerAck, CmdGetAddr, CmdAddr, CmdGetBlocks, CmdBlock, CmdInv, CmdGetData,
+	CmdNotFound, CmdTx, CmdPing, CmdPong, CmdGetHeaders, CmdHeaders, CmdAlert, CmdMemPool,
+	CmdFilterAdd, CmdFilterClear, CmdFilterLoad, CmdMerkleBlock, CmdReject, CmdSendHeaders,
+	CmdFeeFilter, CmdProtoconf, CmdSendCmpct, CmdCmpctBlock, CmdGetBlockTxn, CmdBlockTxn,
+	CmdExtended,
+}
+
+// TestDecodeFuzz decodes random and mutated data as each message type to ensure malformed
+// input returns errors instead of panicking.
+func TestDecodeFuzz(t *testing.T) {
+	random := rand.New(rand.NewSource(581))
+
+	// Valid encodings to mutate.
+	var seeds [][]byte
+	for _, msg := range []Message{&blockOne, multiTx, NewMsgPing(1), NewMsgProtoconf()} {
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+			t.Fatalf("Failed to encode seed : %s", err)
+		}
+		seeds = append(seeds, buf.Bytes())
+	}
+
+	for _, command := range fuzzCommands {
+		for i := 0; i < 500; i++ {
+			var data []byte
+			if i%2 == 0 {
+				data = make([]byte, random.Intn(300))
+				random.Read(data)
+			} else {
+				seed := seeds[random.Intn(len(seeds))]
+				data = make([]byte, len(seed))
+				copy(data, seed)
+				for j := random.Intn(5); j >= 0; j-- {
+					data[random.Intn(len(data))] = byte(random.Intn(256))
+				}
+				data = data[:random.Intn(len(data)+1)]
+			}
+
+			msg, err := makeEmptyMessage(command)
+			if err != nil {
+				t.Fatalf("Failed to make message %s : %s", command, err)
+			}
+
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("Decode %s panicked on %x : %v", command, data, r)
+					}
+				}()
+
+				msg.BtcDecode(bytes.NewBuffer(data), ProtocolVersion)
+			}()
+		}
+	}
+}
+
+// TestDecodeLargeCounts verifies that large counts and lengths in small messages don't cause
+// large allocations.
+func TestDecodeLargeCounts(t *testing.T) {
+	hugeCount := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00}  // 2^40 - 1
+	largeCount := []byte{0xff, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00} // 2^32
+
+	tests := []struct {
+		name string
+		msg  Message
+		data []byte
+	}{
+		{"tx inputs", &MsgTx{}, append([]byte{1, 0, 0, 0}, largeCount...)},
+		{"block txs", &MsgBlock{}, append(make([]byte, 80), largeCount...)},
+		{"merkle block hashes", &MsgMerkleBlock{},
+			append(make([]byte, 84), largeCount...)},
+		{"compact block short ids", &MsgCmpctBlock{}, append(make([]byte, 88), largeCount...)},
+		{"protoconf stream policies", &MsgProtoconf{},
+			append([]byte{2, 0, 0, 0x10, 0}, hugeCount...)},
+		{"extended payload", &MsgExtended{}, append(make([]byte, 12), hugeCount[1:]...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			if err := tt.msg.BtcDecode(bytes.NewBuffer(tt.data), ProtocolVersion); err == nil {
+				t.Fatalf("Decode should fail")
+			}
+
+			runtime.ReadMemStats(&after)
+			allocated := after.TotalAlloc - before.TotalAlloc
+			if allocated > 10*1024*1024 {
+				t.Fatalf("Decode allocated too much memory : %d bytes", allocated)
+			}
+		})
+	}
+}
+
+func TestTypedMessageErrors(t *testing.T) {
+	// More invs than allowed.
+	var buf bytes.Buffer
+	WriteVarInt(&buf, ProtocolVersion, MaxInvPerMsg+1)
+	err := (&MsgInv{}).BtcDecode(&buf, ProtocolVersion)
+	if !errors.Is(err, ErrInvalidCount) {
+		t.Fatalf("Wrong error for too many invs : %v", err)
+	}
+	if errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("Invalid count should not match too large")
+	}
+
+	// Bytes larger than allowed.
+	buf.Reset()
+	WriteVarInt(&buf, ProtocolVersion, 100)
+	if _, err := ReadVarBytes(&buf, ProtocolVersion, 10, "test"); !errors.Is(err,
+		ErrMessageTooLarge) {
+		t.Fatalf("Wrong error for too many bytes : %v", err)
+	}
+
+	// Bad checksum.
+	buf.Reset()
+	if err := WriteMessage(&buf, NewMsgPing(1), ProtocolVersion, MainNet); err != nil {
+		t.Fatalf("Failed to write message : %s", err)
+	}
+	b := buf.Bytes()
+	b[len(b)-1] ^= 0xff
+	if _, _, err := ReadMessage(bytes.NewReader(b), ProtocolVersion, MainNet); !errors.Is(err,
+		ErrInvalidChecksum) {
+		t.Fatalf("Wrong error for bad checksum : %v", err)
+	}
+}
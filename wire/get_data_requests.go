@@ -0,0 +1,164 @@
+package wire
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrItemNotFound means the remote node responded to a getdata request with a notfound message.
+	ErrItemNotFound = errors.New("Item not found")
+
+	// ErrRequestTimeout means the remote node didn't respond to a getdata request in time.
+	ErrRequestTimeout = errors.New("Request timeout")
+)
+
+// GetDataResult is the response to one item requested with SendGetData.
+type GetDataResult struct {
+	Inv InvVect
+
+	// Message is the MsgTx, MsgBlock, or MsgMerkleBlock received for the item. It is nil when Err
+	//   is set.
+	Message Message
+
+	// Err is ErrItemNotFound, ErrRequestTimeout, or ErrPeerStopped when the item wasn't received.
+	Err error
+}
+
+// getDataRequest is an item requested with SendGetData that hasn't been resolved.
+type getDataRequest struct {
+	inv     InvVect
+	results chan *GetDataResult
+}
+
+// SendGetData sends getdata messages for the inventory vectors and returns a channel that receives
+//   one result for each item, in the order the responses arrive, so callers don't have to match
+//   responses to requests themselves. Items are resolved when the corresponding tx, block, or
+//   merkleblock message is received, when a notfound message contains them, when the timeout
+//   passes, or when the peer stops. A timeout of zero waits until a response is received or the
+//   peer stops.
+// Responses are still passed to the registered handlers.
+func (p *Peer) SendGetData(invs []*InvVect, timeout time.Duration) (<-chan *GetDataResult, error) {
+	results := make(chan *GetDataResult, len(invs))
+	requests := make([]*getDataRequest, len(invs))
+
+	p.Lock()
+	if p.isDone {
+		p.Unlock()
+		return nil, ErrPeerStopped
+	}
+	if p.pendingGetData == nil {
+		p.pendingGetData = make(map[InvVect][]*getDataRequest)
+	}
+	for i, inv := range invs {
+		requests[i] = &getDataRequest{
+			inv:     *inv,
+			results: results,
+		}
+		p.pendingGetData[*inv] = append(p.pendingGetData[*inv], requests[i])
+	}
+	p.Unlock()
+
+	for _, inv := range SplitInvVects(invs, p.Limits().MaxInvElements) {
+		msg := NewMsgGetDataSizeHint(uint(len(inv.InvList)))
+		msg.InvList = inv.InvList
+		if err := p.Send(msg); err != nil {
+			p.removeGetDataRequests(requests)
+			return nil, err
+		}
+	}
+
+	if timeout > 0 {
+		time.AfterFunc(timeout, func() {
+			for _, request := range p.removeGetDataRequests(requests) {
+				request.results <- &GetDataResult{Inv: request.inv, Err: ErrRequestTimeout}
+			}
+		})
+	}
+
+	return results, nil
+}
+
+// PendingGetDataCount returns the number of items requested with SendGetData that haven't been
+//   resolved.
+func (p *Peer) PendingGetDataCount() int {
+	p.Lock()
+	defer p.Unlock()
+
+	result := 0
+	for _, requests := range p.pendingGetData {
+		result += len(requests)
+	}
+	return result
+}
+
+// resolveGetData resolves the requests that the message responds to.
+func (p *Peer) resolveGetData(msg Message) {
+	switch m := msg.(type) {
+	case *MsgTx:
+		p.resolveGetDataItem(InvVect{Type: InvTypeTx, Hash: *m.TxHash()}, m, nil)
+	case *MsgBlock:
+		p.resolveGetDataItem(InvVect{Type: InvTypeBlock, Hash: *m.BlockHash()}, m, nil)
+	case *MsgMerkleBlock:
+		p.resolveGetDataItem(InvVect{Type: InvTypeFilteredBlock, Hash: *m.Header.BlockHash()}, m,
+			nil)
+	case *MsgNotFound:
+		for _, inv := range m.InvList {
+			p.resolveGetDataItem(*inv, nil, ErrItemNotFound)
+		}
+	}
+}
+
+// resolveGetDataItem sends the result to the requests for the item.
+func (p *Peer) resolveGetDataItem(inv InvVect, msg Message, err error) {
+	p.Lock()
+	requests := p.pendingGetData[inv]
+	delete(p.pendingGetData, inv)
+	p.Unlock()
+
+	for _, request := range requests {
+		request.results <- &GetDataResult{Inv: inv, Message: msg, Err: err}
+	}
+}
+
+// failGetData resolves all pending requests with the error.
+func (p *Peer) failGetData(err error) {
+	p.Lock()
+	pending := p.pendingGetData
+	p.pendingGetData = nil
+	p.Unlock()
+
+	for inv, requests := range pending {
+		for _, request := range requests {
+			request.results <- &GetDataResult{Inv: inv, Err: err}
+		}
+	}
+}
+
+// removeGetDataRequests removes the requests that are still pending and returns them.
+func (p *Peer) removeGetDataRequests(requests []*getDataRequest) []*getDataRequest {
+	p.Lock()
+	defer p.Unlock()
+
+	var result []*getDataRequest
+	for _, request := range requests {
+		pending := p.pendingGetData[request.inv]
+		for i, other := range pending {
+			if other != request {
+				continue
+			}
+
+			result = append(result, request)
+			pending = append(pending[:i], pending[i+1:]...)
+			if len(pending) == 0 {
+				delete(p.pendingGetData, request.inv)
+			} else {
+				p.pendingGetData[request.inv] = pending
+			}
+			break
+		}
+	}
+
+	return result
+}
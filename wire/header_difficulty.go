@@ -0,0 +1,265 @@
+package wire
+
+import (
+	"math/big"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/pkg/errors"
+)
+
+const (
+	// targetBlockSpacing is the number of seconds expected between blocks.
+	targetBlockSpacing = 600
+
+	// daaWindow is the number of blocks the difficulty adjustment algorithm averages work over.
+	daaWindow = 144
+
+	// mainNetForkHeight and testNetForkHeight are the last heights shared with the BTC chain.
+	// Checkpoints above them are for the BTC chain.
+	mainNetForkHeight = 478558
+	testNetForkHeight = 1155875
+)
+
+var (
+	ErrInvalidDifficulty  = errors.New("Invalid difficulty")
+	ErrCheckpointMismatch = errors.New("Checkpoint mismatch")
+)
+
+// Checkpoint is the hash of a header in the best chain.
+type Checkpoint struct {
+	Height int
+	Hash   bitcoin.Hash32
+}
+
+// HeaderSyncConfig contains the consensus rules used to validate headers.
+type HeaderSyncConfig struct {
+	// PowLimitBits is the lowest difficulty allowed for a header.
+	PowLimitBits uint32
+
+	// Checkpoints are headers that must be in the best chain. Branches that fork below the
+	//   highest checkpoint in the best chain are rejected.
+	Checkpoints []Checkpoint
+
+	// DifficultyAdjustmentHeight is the first height with bits that must match the difficulty
+	//   adjustment algorithm, which targets the average work of the previous 144 headers. Headers
+	//   below it are only checked against PowLimitBits and Checkpoints. Negative doesn't check the
+	//   difficulty adjustment.
+	DifficultyAdjustmentHeight int
+
+	// AllowMinDifficulty allows headers with PowLimitBits when they are more than 20 minutes after
+	//   the previous header, as on testnet.
+	AllowMinDifficulty bool
+
+	// NoRetargeting requires headers to have the same bits as the previous header, as on regtest.
+	NoRetargeting bool
+}
+
+// DefaultHeaderSyncConfig returns the header validation rules of the network.
+//
+// The difficulty adjustments before the current algorithm aren't verified, so mainnet and testnet
+//   headers between the last checkpoint and DifficultyAdjustmentHeight are only checked against
+//   the proof of work limit.
+func DefaultHeaderSyncConfig(net bitcoin.Network) HeaderSyncConfig {
+	switch net {
+	case bitcoin.MainNet:
+		return HeaderSyncConfig{
+			PowLimitBits: bitcoin.MainNetParams.PowLimitBits,
+			Checkpoints: convertCheckpoints(bitcoin.MainNetParams.Checkpoints,
+				mainNetForkHeight),
+			DifficultyAdjustmentHeight: 504032,
+		}
+
+	case bitcoin.TestNet:
+		return HeaderSyncConfig{
+			PowLimitBits: bitcoin.TestNetParams.PowLimitBits,
+			Checkpoints: convertCheckpoints(bitcoin.TestNetParams.Checkpoints,
+				testNetForkHeight),
+			DifficultyAdjustmentHeight: 1188698,
+			AllowMinDifficulty:         true,
+		}
+
+	case bitcoin.RegTestNet:
+		return HeaderSyncConfig{
+			PowLimitBits:               bitcoin.RegTestNetParams.PowLimitBits,
+			DifficultyAdjustmentHeight: 0,
+			NoRetargeting:              true,
+		}
+	}
+
+	// The difficulty rules of other networks aren't known.
+	return HeaderSyncConfig{
+		PowLimitBits:               bitcoin.MaxBits,
+		DifficultyAdjustmentHeight: -1,
+	}
+}
+
+func convertCheckpoints(checkpoints []chaincfg.Checkpoint, maxHeight int) []Checkpoint {
+	var result []Checkpoint
+	for _, checkpoint := range checkpoints {
+		if int(checkpoint.Height) > maxHeight {
+			continue
+		}
+
+		result = append(result, Checkpoint{
+			Height: int(checkpoint.Height),
+			Hash:   bitcoin.Hash32(*checkpoint.Hash),
+		})
+	}
+	return result
+}
+
+// CheckHeader returns an error if the header at the height doesn't meet the proof of work limit,
+//   doesn't match the checkpoint at the height, or doesn't have the bits required by the previous
+//   headers. ancestor returns the headers below the height in the header's branch. The header's own
+//   proof of work isn't checked.
+func (c HeaderSyncConfig) CheckHeader(header *BlockHeader, height int,
+	ancestor func(height int) *BlockHeader) error {
+
+	if err := c.checkPowLimit(header); err != nil {
+		return err
+	}
+
+	if hash, exists := c.checkpoint(height); exists {
+		if headerHash := header.BlockHash(); !hash.Equal(headerHash) {
+			return errors.Wrapf(ErrCheckpointMismatch, "height %d : got %s, want %s", height,
+				headerHash, hash)
+		}
+	}
+
+	return c.checkDifficulty(header, height, ancestor)
+}
+
+// CheckFork returns ErrCheckpointMismatch if a branch that forks from the best chain after the
+//   header at forkHeight would replace a checkpoint in the best chain, which ends at tipHeight.
+func (c HeaderSyncConfig) CheckFork(forkHeight, tipHeight int) error {
+	if checkpointHeight := c.lastCheckpointHeight(tipHeight); forkHeight < checkpointHeight {
+		return errors.Wrapf(ErrCheckpointMismatch, "fork at %d below checkpoint %d", forkHeight,
+			checkpointHeight)
+	}
+	return nil
+}
+
+// checkpoint returns the checkpoint hash at the height.
+func (c HeaderSyncConfig) checkpoint(height int) (bitcoin.Hash32, bool) {
+	for _, checkpoint := range c.Checkpoints {
+		if checkpoint.Height == height {
+			return checkpoint.Hash, true
+		}
+	}
+	return bitcoin.Hash32{}, false
+}
+
+// lastCheckpointHeight returns the height of the highest checkpoint at or below the height, or -1
+//   if there isn't one.
+func (c HeaderSyncConfig) lastCheckpointHeight(height int) int {
+	result := -1
+	for _, checkpoint := range c.Checkpoints {
+		if checkpoint.Height <= height && checkpoint.Height > result {
+			result = checkpoint.Height
+		}
+	}
+	return result
+}
+
+// checkPowLimit returns an error if the header's bits are below the lowest allowed difficulty.
+func (c HeaderSyncConfig) checkPowLimit(header *BlockHeader) error {
+	if bitcoin.ConvertToDifficulty(header.Bits).Cmp(
+		bitcoin.ConvertToDifficulty(c.PowLimitBits)) > 0 {
+		return errors.Wrapf(ErrInvalidDifficulty, "bits 0x%08x below limit 0x%08x", header.Bits,
+			c.PowLimitBits)
+	}
+	return nil
+}
+
+// headerAncestor returns the header at a height below the header being validated.
+type headerAncestor func(height int) *BlockHeader
+
+// checkDifficulty returns an error if the bits of the header at the height don't match the
+//   difficulty required by the previous headers.
+func (c HeaderSyncConfig) checkDifficulty(header *BlockHeader, height int,
+	ancestor headerAncestor) error {
+
+	if c.DifficultyAdjustmentHeight < 0 || height < c.DifficultyAdjustmentHeight || height == 0 {
+		return nil
+	}
+
+	previous := ancestor(height - 1)
+	if c.NoRetargeting {
+		if header.Bits != previous.Bits {
+			return errors.Wrapf(ErrInvalidDifficulty, "bits 0x%08x, previous 0x%08x",
+				header.Bits, previous.Bits)
+		}
+		return nil
+	}
+
+	if c.AllowMinDifficulty && header.Bits == c.PowLimitBits &&
+		int64(header.Timestamp) > int64(previous.Timestamp)+2*targetBlockSpacing {
+		return nil
+	}
+
+	if height < daaWindow+3 {
+		return nil // not enough previous headers
+	}
+
+	required := c.requiredBits(height, ancestor)
+	if header.Bits != required {
+		return errors.Wrapf(ErrInvalidDifficulty, "bits 0x%08x, required 0x%08x", header.Bits,
+			required)
+	}
+
+	return nil
+}
+
+// requiredBits returns the bits required at the height by the difficulty adjustment algorithm.
+//   The work of the previous 144 headers is converted to a target for 10 minute block spacing.
+//   The first and last headers are the medians by timestamp of 3 headers to limit the effect of
+//   incorrect timestamps.
+func (c HeaderSyncConfig) requiredBits(height int, ancestor headerAncestor) uint32 {
+	lastHeight := suitableHeight(height-1, ancestor)
+	firstHeight := suitableHeight(height-1-daaWindow, ancestor)
+
+	work := &big.Int{}
+	for h := firstHeight + 1; h <= lastHeight; h++ {
+		work.Add(work, bitcoin.ConvertToWork(bitcoin.ConvertToDifficulty(ancestor(h).Bits)))
+	}
+
+	timespan := int64(ancestor(lastHeight).Timestamp) - int64(ancestor(firstHeight).Timestamp)
+	if timespan < daaWindow/2*targetBlockSpacing {
+		timespan = daaWindow / 2 * targetBlockSpacing
+	} else if timespan > 2*daaWindow*targetBlockSpacing {
+		timespan = 2 * daaWindow * targetBlockSpacing
+	}
+
+	work.Mul(work, big.NewInt(targetBlockSpacing))
+	work.Div(work, big.NewInt(timespan))
+
+	// target = (2^256 - work) / work
+	target := new(big.Int).Lsh(big.NewInt(1), 256)
+	target.Sub(target, work)
+	target.Div(target, work)
+
+	if target.Cmp(bitcoin.ConvertToDifficulty(c.PowLimitBits)) > 0 {
+		return c.PowLimitBits
+	}
+
+	return bitcoin.ConvertToBits(target, c.PowLimitBits)
+}
+
+// suitableHeight returns the height of the header with the median timestamp of the header at the
+//   height and the 2 headers before it. The swaps match the reference implementation so equal
+//   timestamps select the same header.
+func suitableHeight(height int, ancestor headerAncestor) int {
+	heights := [3]int{height - 2, height - 1, height}
+	swapIfLater := func(i, j int) {
+		if ancestor(heights[i]).Timestamp > ancestor(heights[j]).Timestamp {
+			heights[i], heights[j] = heights[j], heights[i]
+		}
+	}
+
+	swapIfLater(0, 2)
+	swapIfLater(0, 1)
+	swapIfLater(1, 2)
+	return heights[1]
+}
@@ -0,0 +1,449 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/storage"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultHeadersPath is the storage path under which headers are saved.
+	DefaultHeadersPath = "wire/headers"
+
+	// headersPerFile is the number of headers saved in each storage item.
+	headersPerFile = 1000
+)
+
+var (
+	ErrHeadersNotConnected = errors.New("Headers not connected")
+	ErrHeadersNotLinked    = errors.New("Headers not linked")
+	ErrInvalidProofOfWork  = errors.New("Invalid proof of work")
+	ErrHeightNotFound      = errors.New("Height not found")
+	ErrWrongGenesis        = errors.New("Wrong genesis header")
+)
+
+// ChainTipUpdate describes a change to the best chain.
+type ChainTipUpdate struct {
+	Height int            // Height of the new tip
+	Hash   bitcoin.Hash32 // Hash of the new tip
+
+	// ForkHeight is the height of the last header that the previous and new best chains have in
+	//   common. It is the previous tip height when no headers were disconnected.
+	ForkHeight int
+
+	// Disconnected contains the hashes of headers removed from the best chain by a reorg, tip
+	//   first.
+	Disconnected []bitcoin.Hash32
+}
+
+// IsReorg returns true if headers were removed from the best chain.
+func (u ChainTipUpdate) IsReorg() bool {
+	return len(u.Disconnected) > 0
+}
+
+// ChainTipListener is called after the best chain changes.
+type ChainTipListener func(ctx context.Context, update *ChainTipUpdate)
+
+// HeaderSync maintains the best chain of block headers by exchanging getheaders and headers
+//   messages with peers. Headers must link to the chain, meet the target specified by their bits,
+//   and have the bits required by the config's difficulty rules and checkpoints. The chain with
+//   the most work is selected as the best chain.
+type HeaderSync struct {
+	config HeaderSyncConfig
+	store  storage.Storage
+	path   string
+
+	headers []*BlockHeader // best chain, indexed by height
+	hashes  []bitcoin.Hash32
+	heights map[bitcoin.Hash32]int
+
+	savedCount  int // number of headers in storage
+	dirtyHeight int // lowest height modified since the last save
+
+	listeners []ChainTipListener
+
+	sync.Mutex
+}
+
+// NewHeaderSync creates a header sync with a chain containing only the genesis header.
+func NewHeaderSync(config HeaderSyncConfig, store storage.Storage,
+	genesis *BlockHeader) *HeaderSync {
+
+	result := &HeaderSync{
+		config: config,
+		store:  store,
+		path:   DefaultHeadersPath,
+	}
+	result.reset(genesis)
+	return result
+}
+
+func (hs *HeaderSync) reset(genesis *BlockHeader) {
+	header := *genesis
+	hash := *header.BlockHash()
+	hs.headers = []*BlockHeader{&header}
+	hs.hashes = []bitcoin.Hash32{hash}
+	hs.heights = map[bitcoin.Hash32]int{hash: 0}
+	hs.savedCount = 0
+	hs.dirtyHeight = 0
+}
+
+// RegisterListener adds a function that is called when the best chain changes.
+func (hs *HeaderSync) RegisterListener(listener ChainTipListener) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	hs.listeners = append(hs.listeners, listener)
+}
+
+// Height returns the height of the best chain tip.
+func (hs *HeaderSync) Height() int {
+	hs.Lock()
+	defer hs.Unlock()
+
+	return len(hs.headers) - 1
+}
+
+// Tip returns the height and hash of the best chain tip.
+func (hs *HeaderSync) Tip() (int, bitcoin.Hash32) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	height := len(hs.headers) - 1
+	return height, hs.hashes[height]
+}
+
+// Hash returns the hash of the header at the height in the best chain.
+func (hs *HeaderSync) Hash(height int) (*bitcoin.Hash32, error) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	if height < 0 || height >= len(hs.hashes) {
+		return nil, errors.Wrapf(ErrHeightNotFound, "%d", height)
+	}
+
+	hash := hs.hashes[height]
+	return &hash, nil
+}
+
+// Header returns the header at the height in the best chain.
+func (hs *HeaderSync) Header(height int) (*BlockHeader, error) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	if height < 0 || height >= len(hs.headers) {
+		return nil, errors.Wrapf(ErrHeightNotFound, "%d", height)
+	}
+
+	header := *hs.headers[height]
+	return &header, nil
+}
+
+// HashHeight returns the height of the header with the hash if it is in the best chain.
+func (hs *HeaderSync) HashHeight(hash bitcoin.Hash32) (int, bool) {
+	hs.Lock()
+	defer hs.Unlock()
+
+	height, exists := hs.heights[hash]
+	return height, exists
+}
+
+// RegisterPeer registers handlers to process headers received from the peer. New blocks are
+//   only announced with headers messages when PeerConfig.SendHeaders is set.
+func (hs *HeaderSync) RegisterPeer(peer *Peer) {
+	peer.RegisterHandler(CmdHeaders, hs.handleHeaders)
+}
+
+// RequestHeaders sends a getheaders message with a locator for the best chain. The peer must be
+//   connected and registered with RegisterPeer.
+func (hs *HeaderSync) RequestHeaders(peer *Peer) error {
+	msg := NewMsgGetHeaders()
+	msg.ProtocolVersion = peer.ProtocolVersion()
+	for _, hash := range hs.Locator() {
+		h := hash
+		if err := msg.AddBlockLocatorHash(&h); err != nil {
+			return errors.Wrap(err, "add locator")
+		}
+	}
+
+	return peer.Send(msg)
+}
+
+// Locator returns block locator hashes for the best chain. They start at the tip and become
+//   exponentially further apart, ending with the genesis header.
+func (hs *HeaderSync) Locator() []bitcoin.Hash32 {
+	hs.Lock()
+	defer hs.Unlock()
+
+	var result []bitcoin.Hash32
+	step := 1
+	for height := len(hs.hashes) - 1; height > 0; height -= step {
+		result = append(result, hs.hashes[height])
+		if len(result) >= 10 {
+			step *= 2
+		}
+	}
+
+	return append(result, hs.hashes[0])
+}
+
+func (hs *HeaderSync) handleHeaders(ctx context.Context, peer *Peer, msg Message) error {
+	headers, ok := msg.(*MsgHeaders)
+	if !ok {
+		return nil
+	}
+
+	if _, err := hs.ProcessHeaders(ctx, headers.Headers); err != nil {
+		if errors.Cause(err) == ErrHeadersNotConnected {
+			// Probably an announcement of a new block while we are behind.
+			return hs.RequestHeaders(peer)
+		}
+		return errors.Wrap(err, "process headers")
+	}
+
+	if len(headers.Headers) == MaxBlockHeadersPerMsg {
+		return hs.RequestHeaders(peer) // request the next batch
+	}
+
+	return nil
+}
+
+// ProcessHeaders validates the headers and adds them to the best chain if they extend it or
+//   form a branch with more work. The headers must be in order and the first header must link
+//   to a header in the best chain. It returns nil if the best chain didn't change.
+func (hs *HeaderSync) ProcessHeaders(ctx context.Context,
+	headers []*BlockHeader) (*ChainTipUpdate, error) {
+
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	// Validate the headers before locking.
+	hashes := make([]bitcoin.Hash32, len(headers))
+	for i, header := range headers {
+		hashes[i] = *header.BlockHash()
+		if i > 0 && !header.PrevBlock.Equal(&hashes[i-1]) {
+			return nil, errors.Wrapf(ErrHeadersNotLinked, "header %d", i)
+		}
+		if err := hs.config.checkPowLimit(header); err != nil {
+			return nil, errors.Wrapf(err, "header %s", hashes[i])
+		}
+		if !header.WorkIsValid() {
+			return nil, errors.Wrapf(ErrInvalidProofOfWork, "header %s", hashes[i])
+		}
+	}
+
+	hs.Lock()
+	update, err := hs.connectHeaders(headers, hashes)
+	listeners := make([]ChainTipListener, len(hs.listeners))
+	copy(listeners, hs.listeners)
+	hs.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if update != nil {
+		for _, listener := range listeners {
+			listener(ctx, update)
+		}
+	}
+
+	return update, nil
+}
+
+func (hs *HeaderSync) connectHeaders(headers []*BlockHeader,
+	hashes []bitcoin.Hash32) (*ChainTipUpdate, error) {
+
+	forkHeight, exists := hs.heights[headers[0].PrevBlock]
+	if !exists {
+		return nil, errors.Wrapf(ErrHeadersNotConnected, "previous %s", headers[0].PrevBlock)
+	}
+
+	// Skip headers that are already in the best chain.
+	offset := 0
+	for offset < len(headers) && forkHeight+1 < len(hs.hashes) &&
+		hs.hashes[forkHeight+1].Equal(&hashes[offset]) {
+		forkHeight++
+		offset++
+	}
+	if offset == len(headers) {
+		return nil, nil
+	}
+	headers = headers[offset:]
+	hashes = hashes[offset:]
+
+	tipHeight := len(hs.headers) - 1
+	if err := hs.validateBranch(forkHeight, tipHeight, headers); err != nil {
+		return nil, err
+	}
+
+	if forkHeight < tipHeight &&
+		headersWork(headers).Cmp(headersWork(hs.headers[forkHeight+1:])) <= 0 {
+		return nil, nil // branch doesn't have more work than the best chain
+	}
+
+	update := &ChainTipUpdate{
+		ForkHeight: forkHeight,
+	}
+
+	for height := tipHeight; height > forkHeight; height-- {
+		update.Disconnected = append(update.Disconnected, hs.hashes[height])
+		delete(hs.heights, hs.hashes[height])
+	}
+	hs.headers = hs.headers[:forkHeight+1]
+	hs.hashes = hs.hashes[:forkHeight+1]
+
+	for i, header := range headers {
+		h := *header
+		hs.headers = append(hs.headers, &h)
+		hs.hashes = append(hs.hashes, hashes[i])
+		hs.heights[hashes[i]] = len(hs.hashes) - 1
+	}
+
+	if forkHeight+1 < hs.dirtyHeight {
+		hs.dirtyHeight = forkHeight + 1
+	}
+
+	update.Height = len(hs.hashes) - 1
+	update.Hash = hs.hashes[update.Height]
+	return update, nil
+}
+
+// validateBranch checks the headers that follow the header at the fork height against the
+//   checkpoints and difficulty rules.
+func (hs *HeaderSync) validateBranch(forkHeight, tipHeight int, headers []*BlockHeader) error {
+
+	if err := hs.config.CheckFork(forkHeight, tipHeight); err != nil {
+		return err
+	}
+
+	ancestor := func(height int) *BlockHeader {
+		if height <= forkHeight {
+			return hs.headers[height]
+		}
+		return headers[height-forkHeight-1]
+	}
+
+	for i, header := range headers {
+		height := forkHeight + 1 + i
+
+		if err := hs.config.CheckHeader(header, height, ancestor); err != nil {
+			return errors.Wrapf(err, "height %d", height)
+		}
+	}
+
+	return nil
+}
+
+// headersWork returns the total work required to produce the headers.
+func headersWork(headers []*BlockHeader) *big.Int {
+	result := &big.Int{}
+	for _, header := range headers {
+		result.Add(result, bitcoin.ConvertToWork(bitcoin.ConvertToDifficulty(header.Bits)))
+	}
+	return result
+}
+
+// Load reads the headers from storage. The first header in storage must match the genesis header.
+func (hs *HeaderSync) Load(ctx context.Context) error {
+	hs.Lock()
+	defer hs.Unlock()
+
+	genesis := hs.headers[0]
+	genesisHash := hs.hashes[0]
+	hs.reset(genesis)
+
+	for file := 0; ; file++ {
+		b, err := hs.store.Read(ctx, hs.filePath(file))
+		if err != nil {
+			if errors.Cause(err) == storage.ErrNotFound {
+				break
+			}
+			return errors.Wrapf(err, "read %d", file)
+		}
+
+		r := bytes.NewReader(b)
+		for r.Len() > 0 {
+			header := &BlockHeader{}
+			if err := header.Deserialize(r); err != nil {
+				return errors.Wrapf(err, "header %d", file*headersPerFile+hs.savedCount)
+			}
+			hash := *header.BlockHash()
+
+			if file == 0 && hs.savedCount == 0 {
+				if !hash.Equal(&genesisHash) {
+					return errors.Wrapf(ErrWrongGenesis, "%s", hash)
+				}
+				hs.savedCount++
+				continue
+			}
+
+			if !header.PrevBlock.Equal(&hs.hashes[len(hs.hashes)-1]) {
+				return errors.Wrapf(ErrHeadersNotLinked, "height %d", len(hs.hashes))
+			}
+
+			hs.headers = append(hs.headers, header)
+			hs.hashes = append(hs.hashes, hash)
+			hs.heights[hash] = len(hs.hashes) - 1
+			hs.savedCount++
+		}
+
+		if hs.savedCount < (file+1)*headersPerFile {
+			break // partial file is the last
+		}
+	}
+
+	hs.dirtyHeight = hs.savedCount
+	return nil
+}
+
+// Save writes the headers that were modified since the last save to storage.
+func (hs *HeaderSync) Save(ctx context.Context) error {
+	hs.Lock()
+	defer hs.Unlock()
+
+	count := len(hs.headers)
+	lastFile := (count - 1) / headersPerFile
+	for file := hs.dirtyHeight / headersPerFile; file <= lastFile; file++ {
+		end := (file + 1) * headersPerFile
+		if end > count {
+			end = count
+		}
+
+		buf := bytes.NewBuffer(make([]byte, 0, (end-file*headersPerFile)*blockHeaderLen))
+		for _, header := range hs.headers[file*headersPerFile : end] {
+			if err := header.Serialize(buf); err != nil {
+				return errors.Wrapf(err, "serialize %d", file)
+			}
+		}
+
+		if err := hs.store.Write(ctx, hs.filePath(file), buf.Bytes(), nil); err != nil {
+			return errors.Wrapf(err, "write %d", file)
+		}
+	}
+
+	// Remove files that only contained headers that were disconnected.
+	savedFiles := (hs.savedCount + headersPerFile - 1) / headersPerFile
+	for file := lastFile + 1; file < savedFiles; file++ {
+		if err := hs.store.Remove(ctx, hs.filePath(file)); err != nil &&
+			errors.Cause(err) != storage.ErrNotFound {
+			return errors.Wrapf(err, "remove %d", file)
+		}
+	}
+
+	hs.savedCount = count
+	hs.dirtyHeight = count
+	return nil
+}
+
+func (hs *HeaderSync) filePath(file int) string {
+	return fmt.Sprintf("%s/%08d", hs.path, file)
+}
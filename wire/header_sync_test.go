@@ -0,0 +1,343 @@
+package wire
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/storage"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	testEasyBits = 0x207fffff // about half of hashes are valid
+	testHardBits = 0x2000ffff // about 1 in 256 hashes are valid
+)
+
+// testHeaderSyncConfig allows test bits and doesn't check difficulty adjustments.
+var testHeaderSyncConfig = HeaderSyncConfig{
+	PowLimitBits:               testEasyBits,
+	DifficultyAdjustmentHeight: -1,
+}
+
+// mineHeaders creates a chain of valid headers following the previous header.
+func mineHeaders(previous *BlockHeader, count int, bits uint32, seed uint32) []*BlockHeader {
+
+	var result []*BlockHeader
+	for i := 0; i < count; i++ {
+		previous = mineHeader(previous, bits, previous.Timestamp+1, seed)
+		result = append(result, previous)
+	}
+
+	return result
+}
+
+// mineHeader creates a valid header following the previous header.
+func mineHeader(previous *BlockHeader, bits, timestamp, seed uint32) *BlockHeader {
+	header := &BlockHeader{
+		Version:   1,
+		PrevBlock: *previous.BlockHash(),
+		Timestamp: timestamp,
+		Bits:      bits,
+		Nonce:     seed << 24,
+	}
+	for !header.WorkIsValid() {
+		header.Nonce++
+	}
+
+	return header
+}
+
+func TestHeaderSyncReorg(t *testing.T) {
+	ctx := context.Background()
+	genesis := &BlockHeader{Version: 1, Bits: testEasyBits}
+	hs := NewHeaderSync(testHeaderSyncConfig, storage.NewMockStorage(), genesis)
+
+	var updates []*ChainTipUpdate
+	hs.RegisterListener(func(ctx context.Context, update *ChainTipUpdate) {
+		updates = append(updates, update)
+	})
+
+	main := mineHeaders(genesis, 5, testEasyBits, 1)
+	update, err := hs.ProcessHeaders(ctx, main)
+	if err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+	if update == nil || update.Height != 5 || update.IsReorg() {
+		t.Fatalf("Wrong update : %+v", update)
+	}
+
+	// Reprocessing the same headers doesn't change anything.
+	update, err = hs.ProcessHeaders(ctx, main[2:])
+	if err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+	if update != nil {
+		t.Fatalf("Duplicate headers changed chain : %+v", update)
+	}
+
+	// A branch with less work is ignored.
+	weak := mineHeaders(main[1], 2, testEasyBits, 2)
+	update, err = hs.ProcessHeaders(ctx, weak)
+	if err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+	if update != nil {
+		t.Fatalf("Weak branch changed chain : %+v", update)
+	}
+
+	// A branch with more work causes a reorg.
+	strong := mineHeaders(main[1], 2, testHardBits, 3)
+	update, err = hs.ProcessHeaders(ctx, strong)
+	if err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+	if update == nil || !update.IsReorg() {
+		t.Fatalf("Strong branch didn't reorg : %+v", update)
+	}
+	if update.ForkHeight != 2 || update.Height != 4 || len(update.Disconnected) != 3 {
+		t.Fatalf("Wrong reorg update : %+v", update)
+	}
+	if !update.Disconnected[0].Equal(main[4].BlockHash()) {
+		t.Fatalf("Wrong first disconnected : got %s, want %s", update.Disconnected[0],
+			main[4].BlockHash())
+	}
+
+	height, hash := hs.Tip()
+	if height != 4 || !hash.Equal(strong[1].BlockHash()) {
+		t.Fatalf("Wrong tip : %d %s", height, hash)
+	}
+
+	if _, exists := hs.HashHeight(*main[4].BlockHash()); exists {
+		t.Fatalf("Disconnected header still in chain")
+	}
+
+	if len(updates) != 2 {
+		t.Fatalf("Wrong listener update count : got %d, want %d", len(updates), 2)
+	}
+}
+
+func TestHeaderSyncInvalid(t *testing.T) {
+	ctx := context.Background()
+	genesis := &BlockHeader{Version: 1, Bits: testEasyBits}
+	hs := NewHeaderSync(testHeaderSyncConfig, storage.NewMockStorage(), genesis)
+
+	headers := mineHeaders(genesis, 3, testEasyBits, 1)
+
+	if _, err := hs.ProcessHeaders(ctx, headers[1:]); err == nil {
+		t.Fatalf("Unconnected headers accepted")
+	}
+
+	unlinked := []*BlockHeader{headers[0], headers[2]}
+	if _, err := hs.ProcessHeaders(ctx, unlinked); err == nil {
+		t.Fatalf("Unlinked headers accepted")
+	}
+
+	invalid := *headers[0]
+	invalid.Bits = bitcoin.MaxBits
+	if _, err := hs.ProcessHeaders(ctx, []*BlockHeader{&invalid}); err == nil {
+		t.Fatalf("Invalid proof of work accepted")
+	}
+
+	if hs.Height() != 0 {
+		t.Fatalf("Wrong height : got %d, want %d", hs.Height(), 0)
+	}
+}
+
+func TestHeaderSyncPowLimit(t *testing.T) {
+	ctx := context.Background()
+	genesis := &BlockHeader{Version: 1, Bits: testHardBits}
+	config := HeaderSyncConfig{
+		PowLimitBits:               testHardBits,
+		DifficultyAdjustmentHeight: -1,
+	}
+	hs := NewHeaderSync(config, storage.NewMockStorage(), genesis)
+
+	// Headers with valid proof of work for a difficulty below the limit are rejected.
+	forged := mineHeaders(genesis, 10, testEasyBits, 1)
+	if _, err := hs.ProcessHeaders(ctx, forged); errors.Cause(err) != ErrInvalidDifficulty {
+		t.Fatalf("Wrong error for forged headers : got %v, want %v", err, ErrInvalidDifficulty)
+	}
+
+	if _, err := hs.ProcessHeaders(ctx, mineHeaders(genesis, 2, testHardBits, 2)); err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+
+	if hs.Height() != 2 {
+		t.Fatalf("Wrong height : got %d, want %d", hs.Height(), 2)
+	}
+}
+
+func TestHeaderSyncNoRetargeting(t *testing.T) {
+	ctx := context.Background()
+	genesis := &BlockHeader{Version: 1, Bits: testHardBits}
+	config := HeaderSyncConfig{
+		PowLimitBits:  testEasyBits,
+		NoRetargeting: true,
+	}
+	hs := NewHeaderSync(config, storage.NewMockStorage(), genesis)
+
+	main := mineHeaders(genesis, 3, testHardBits, 1)
+	if _, err := hs.ProcessHeaders(ctx, main); err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+
+	// A longer branch with lower difficulty headers is rejected rather than becoming the best
+	// chain.
+	forged := mineHeaders(main[0], 10, testEasyBits, 2)
+	if _, err := hs.ProcessHeaders(ctx, forged); errors.Cause(err) != ErrInvalidDifficulty {
+		t.Fatalf("Wrong error for forged headers : got %v, want %v", err, ErrInvalidDifficulty)
+	}
+
+	height, hash := hs.Tip()
+	if height != 3 || !hash.Equal(main[2].BlockHash()) {
+		t.Fatalf("Wrong tip : %d %s", height, hash)
+	}
+}
+
+func TestHeaderSyncDifficultyAdjustment(t *testing.T) {
+	ctx := context.Background()
+	genesis := &BlockHeader{Version: 1, Bits: testHardBits, Timestamp: 1600000000}
+	config := HeaderSyncConfig{
+		PowLimitBits:               testEasyBits,
+		DifficultyAdjustmentHeight: 150,
+	}
+	hs := NewHeaderSync(config, storage.NewMockStorage(), genesis)
+
+	chain := []*BlockHeader{genesis}
+	for i := 0; i < 149; i++ {
+		previous := chain[len(chain)-1]
+		chain = append(chain, mineHeader(previous, testHardBits,
+			previous.Timestamp+targetBlockSpacing, 1))
+	}
+	if _, err := hs.ProcessHeaders(ctx, chain[1:]); err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+
+	required := config.requiredBits(150, func(height int) *BlockHeader {
+		return chain[height]
+	})
+	if required == testEasyBits {
+		t.Fatalf("Required bits should be more difficult than the limit")
+	}
+
+	tip := chain[149]
+	forged := mineHeader(tip, testEasyBits, tip.Timestamp+targetBlockSpacing, 2)
+	if _, err := hs.ProcessHeaders(ctx, []*BlockHeader{forged}); errors.Cause(err) !=
+		ErrInvalidDifficulty {
+		t.Fatalf("Wrong error for forged header : got %v, want %v", err, ErrInvalidDifficulty)
+	}
+
+	valid := mineHeader(tip, required, tip.Timestamp+targetBlockSpacing, 3)
+	update, err := hs.ProcessHeaders(ctx, []*BlockHeader{valid})
+	if err != nil {
+		t.Fatalf("Failed to process header : %s", err)
+	}
+	if update == nil || update.Height != 150 {
+		t.Fatalf("Wrong update : %+v", update)
+	}
+}
+
+func TestHeaderSyncCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	genesis := &BlockHeader{Version: 1, Bits: testEasyBits}
+	main := mineHeaders(genesis, 5, testEasyBits, 1)
+
+	config := testHeaderSyncConfig
+	config.Checkpoints = []Checkpoint{{Height: 3, Hash: *main[2].BlockHash()}}
+	hs := NewHeaderSync(config, storage.NewMockStorage(), genesis)
+
+	wrong := mineHeaders(main[1], 1, testEasyBits, 2)
+	if _, err := hs.ProcessHeaders(ctx, append(main[:2:2], wrong...)); errors.Cause(err) !=
+		ErrCheckpointMismatch {
+		t.Fatalf("Wrong error for checkpoint header : got %v, want %v", err,
+			ErrCheckpointMismatch)
+	}
+
+	if _, err := hs.ProcessHeaders(ctx, main); err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+
+	// A branch with more work that forks below the checkpoint is rejected.
+	strong := mineHeaders(main[0], 5, testHardBits, 3)
+	if _, err := hs.ProcessHeaders(ctx, strong); errors.Cause(err) != ErrCheckpointMismatch {
+		t.Fatalf("Wrong error for branch below checkpoint : got %v, want %v", err,
+			ErrCheckpointMismatch)
+	}
+
+	height, hash := hs.Tip()
+	if height != 5 || !hash.Equal(main[4].BlockHash()) {
+		t.Fatalf("Wrong tip : %d %s", height, hash)
+	}
+}
+
+func TestHeaderSyncSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMockStorage()
+	genesis := &BlockHeader{Version: 1, Bits: testEasyBits}
+	hs := NewHeaderSync(testHeaderSyncConfig, store, genesis)
+
+	headers := mineHeaders(genesis, 2500, testEasyBits, 1)
+	for i := 0; i < len(headers); i += MaxBlockHeadersPerMsg {
+		end := i + MaxBlockHeadersPerMsg
+		if end > len(headers) {
+			end = len(headers)
+		}
+		if _, err := hs.ProcessHeaders(ctx, headers[i:end]); err != nil {
+			t.Fatalf("Failed to process headers : %s", err)
+		}
+	}
+
+	if err := hs.Save(ctx); err != nil {
+		t.Fatalf("Failed to save headers : %s", err)
+	}
+
+	loaded := NewHeaderSync(testHeaderSyncConfig, store, genesis)
+	if err := loaded.Load(ctx); err != nil {
+		t.Fatalf("Failed to load headers : %s", err)
+	}
+
+	height, hash := loaded.Tip()
+	if height != 2500 || !hash.Equal(headers[2499].BlockHash()) {
+		t.Fatalf("Wrong loaded tip : %d %s", height, hash)
+	}
+
+	// Reorg to a shorter chain so the last file is removed.
+	branch := mineHeaders(headers[1989], 8, testHardBits, 2)
+	update, err := loaded.ProcessHeaders(ctx, branch)
+	if err != nil {
+		t.Fatalf("Failed to process headers : %s", err)
+	}
+	if update == nil || update.Height != 1998 {
+		t.Fatalf("Wrong reorg update : %+v", update)
+	}
+
+	if err := loaded.Save(ctx); err != nil {
+		t.Fatalf("Failed to save headers : %s", err)
+	}
+
+	if _, err := store.Read(ctx, DefaultHeadersPath+"/00000002"); err == nil {
+		t.Fatalf("Disconnected headers file not removed")
+	}
+
+	reloaded := NewHeaderSync(testHeaderSyncConfig, store, genesis)
+	if err := reloaded.Load(ctx); err != nil {
+		t.Fatalf("Failed to load headers : %s", err)
+	}
+
+	height, hash = reloaded.Tip()
+	if height != 1998 || !hash.Equal(branch[7].BlockHash()) {
+		t.Fatalf("Wrong reloaded tip : %d %s", height, hash)
+	}
+
+	locator := reloaded.Locator()
+	if !locator[0].Equal(&hash) || !locator[len(locator)-1].Equal(genesis.BlockHash()) {
+		t.Fatalf("Wrong locator ends")
+	}
+
+	other := NewHeaderSync(testHeaderSyncConfig, store, &BlockHeader{Version: 2, Bits: testEasyBits})
+	if err := other.Load(ctx); err == nil {
+		t.Fatalf("Loaded headers with wrong genesis")
+	}
+}
@@ -0,0 +1,378 @@
+package wire
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/logger"
+
+	"github.com/pkg/errors"
+)
+
+// InvPeer is a peer that announces inventory and that getdata requests are sent to. Peer
+//   implements it.
+type InvPeer interface {
+	Send(msg Message) error
+}
+
+// InvCollectorConfig configures an inventory collector.
+type InvCollectorConfig struct {
+	// MaxInFlightPerPeer is the maximum number of requested items that a peer hasn't delivered
+	//   yet.
+	MaxInFlightPerPeer int
+
+	// RequestTimeout is how long a peer has to deliver a requested item before it is requested
+	//   from another peer that announced it.
+	RequestTimeout time.Duration
+
+	// KnownRetention is how long completed items are remembered so that later announcements of
+	//   them are ignored.
+	KnownRetention time.Duration
+}
+
+// DefaultInvCollectorConfig returns an inventory collector config with reasonable values.
+func DefaultInvCollectorConfig() InvCollectorConfig {
+	return InvCollectorConfig{
+		MaxInFlightPerPeer: 1000,
+		RequestTimeout:     time.Minute,
+		KnownRetention:     10 * time.Minute,
+	}
+}
+
+// InvCollector aggregates inventory announced by multiple peers, removes duplicates, and
+//   schedules getdata requests across the peers that announced each item. Each peer has a limit
+//   on requested items that haven't been delivered and requests that time out are reassigned to
+//   other peers that announced the item.
+type InvCollector struct {
+	config InvCollectorConfig
+
+	items   map[InvVect]*invItem
+	pending []*invItem // items waiting to be requested, in order of announcement
+	known   map[InvVect]time.Time
+	peers   map[InvPeer]*invPeerState
+
+	sync.Mutex
+}
+
+type invItem struct {
+	inv     InvVect
+	sources []InvPeer // peers that announced the item and haven't failed to deliver it
+
+	requestedFrom InvPeer
+	requestedAt   time.Time
+	isPending     bool
+}
+
+type invPeerState struct {
+	inFlight int
+}
+
+// NewInvCollector creates an inventory collector.
+func NewInvCollector(config InvCollectorConfig) *InvCollector {
+	return &InvCollector{
+		config: config,
+		items:  make(map[InvVect]*invItem),
+		known:  make(map[InvVect]time.Time),
+		peers:  make(map[InvPeer]*invPeerState),
+	}
+}
+
+// Announce records inventory announced by a peer. It returns the number of items that were not
+//   already known.
+func (c *InvCollector) Announce(peer InvPeer, invs []*InvVect) int {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, exists := c.peers[peer]; !exists {
+		c.peers[peer] = &invPeerState{}
+	}
+
+	count := 0
+	for _, inv := range invs {
+		if _, isKnown := c.known[*inv]; isKnown {
+			continue
+		}
+
+		if item, exists := c.items[*inv]; exists {
+			if !containsInvPeer(item.sources, peer) {
+				item.sources = append(item.sources, peer)
+			}
+			continue
+		}
+
+		item := &invItem{
+			inv:       *inv,
+			sources:   []InvPeer{peer},
+			isPending: true,
+		}
+		c.items[*inv] = item
+		c.pending = append(c.pending, item)
+		count++
+	}
+
+	return count
+}
+
+// Received marks an item as delivered so it is no longer requested and later announcements of
+//   it are ignored.
+func (c *InvCollector) Received(inv InvVect) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.known[inv] = time.Now()
+
+	item, exists := c.items[inv]
+	if !exists {
+		return
+	}
+
+	c.releaseRequest(item)
+	delete(c.items, inv)
+	if item.isPending {
+		c.removePending(item)
+	}
+}
+
+// NotFound records that a peer doesn't have the items so they are requested from other peers.
+func (c *InvCollector) NotFound(peer InvPeer, invs []*InvVect) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, inv := range invs {
+		item, exists := c.items[*inv]
+		if !exists {
+			continue
+		}
+
+		c.removeSource(item, peer)
+	}
+}
+
+// RemovePeer forgets a peer, for example when it disconnects. Items requested from it are
+//   requested from other peers.
+func (c *InvCollector) RemovePeer(peer InvPeer) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.removePeer(peer)
+}
+
+// InFlight returns the number of items requested from the peer that haven't been delivered.
+func (c *InvCollector) InFlight(peer InvPeer) int {
+	c.Lock()
+	defer c.Unlock()
+
+	state, exists := c.peers[peer]
+	if !exists {
+		return 0
+	}
+	return state.inFlight
+}
+
+// PendingCount returns the number of items that are waiting to be requested or delivered.
+func (c *InvCollector) PendingCount() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.items)
+}
+
+// Schedule reassigns timed out requests and sends getdata requests for pending items to peers
+//   that announced them and have capacity. It returns the number of items requested. Peers that
+//   fail to send are removed.
+func (c *InvCollector) Schedule(ctx context.Context) int {
+	now := time.Now()
+	requests := c.assign(now)
+
+	count := 0
+	for peer, invs := range requests {
+		msg := NewMsgGetDataSizeHint(uint(len(invs)))
+		msg.InvList = invs
+
+		if err := peer.Send(msg); err != nil {
+			logger.WarnWithFields(ctx, []logger.Field{
+				logger.Int("count", len(invs)),
+			}, "Failed to send getdata : %s", err)
+			c.RemovePeer(peer)
+			continue
+		}
+
+		count += len(invs)
+	}
+
+	return count
+}
+
+// Run schedules requests at the interval until the interrupt is closed.
+func (c *InvCollector) Run(ctx context.Context, interrupt <-chan interface{},
+	interval time.Duration) error {
+
+	if interval <= 0 {
+		return errors.New("Interval must be positive")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+			c.Schedule(ctx)
+		}
+	}
+}
+
+// HandleInv is a MessageHandler for inv messages. Only the items that the peer's relay policy
+//   allows to be requested are collected.
+func (c *InvCollector) HandleInv(ctx context.Context, peer *Peer, msg Message) error {
+	inv, ok := msg.(*MsgInv)
+	if !ok {
+		return errors.New("Not inv message")
+	}
+
+	c.Announce(peer, peer.RequestableInvs(inv.InvList))
+	return nil
+}
+
+// HandleNotFound is a MessageHandler for notfound messages.
+func (c *InvCollector) HandleNotFound(ctx context.Context, peer *Peer, msg Message) error {
+	notFound, ok := msg.(*MsgNotFound)
+	if !ok {
+		return errors.New("Not notfound message")
+	}
+
+	c.NotFound(peer, notFound.InvList)
+	return nil
+}
+
+// assign updates the state for the requests that should be sent and returns them by peer.
+func (c *InvCollector) assign(now time.Time) map[InvPeer][]*InvVect {
+	c.Lock()
+	defer c.Unlock()
+
+	// Reassign timed out requests.
+	for _, item := range c.items {
+		if item.requestedFrom == nil || now.Sub(item.requestedAt) < c.config.RequestTimeout {
+			continue
+		}
+
+		c.removeSource(item, item.requestedFrom)
+	}
+
+	for inv, receivedAt := range c.known {
+		if now.Sub(receivedAt) > c.config.KnownRetention {
+			delete(c.known, inv)
+		}
+	}
+
+	result := make(map[InvPeer][]*InvVect)
+	var stillPending []*invItem
+	for _, item := range c.pending {
+		peer := c.selectPeer(item)
+		if peer == nil {
+			stillPending = append(stillPending, item)
+			continue
+		}
+
+		item.isPending = false
+		item.requestedFrom = peer
+		item.requestedAt = now
+		c.peers[peer].inFlight++
+
+		inv := item.inv
+		result[peer] = append(result[peer], &inv)
+	}
+	c.pending = stillPending
+
+	return result
+}
+
+// selectPeer returns the source of the item with the fewest items in flight, or nil if none
+//   have capacity.
+func (c *InvCollector) selectPeer(item *invItem) InvPeer {
+	var result InvPeer
+	lowest := c.config.MaxInFlightPerPeer
+	for _, peer := range item.sources {
+		state, exists := c.peers[peer]
+		if !exists || state.inFlight >= lowest {
+			continue
+		}
+
+		result = peer
+		lowest = state.inFlight
+	}
+
+	return result
+}
+
+// removePeer removes the peer from all items. The lock must be held.
+func (c *InvCollector) removePeer(peer InvPeer) {
+	delete(c.peers, peer)
+
+	for _, item := range c.items {
+		c.removeSource(item, peer)
+	}
+}
+
+// removeSource removes the peer as a source of the item. If the item was requested from the
+//   peer then it is requested again from another source. Items without sources are dropped. The
+//   lock must be held.
+func (c *InvCollector) removeSource(item *invItem, peer InvPeer) {
+	for i, source := range item.sources {
+		if source == peer {
+			item.sources = append(item.sources[:i], item.sources[i+1:]...)
+			break
+		}
+	}
+
+	if item.requestedFrom == peer {
+		c.releaseRequest(item)
+		if len(item.sources) > 0 {
+			item.isPending = true
+			c.pending = append(c.pending, item)
+		}
+	}
+
+	if len(item.sources) == 0 {
+		delete(c.items, item.inv)
+		if item.isPending {
+			c.removePending(item)
+		}
+	}
+}
+
+// releaseRequest frees the in flight slot of the peer the item was requested from. The lock must
+//   be held.
+func (c *InvCollector) releaseRequest(item *invItem) {
+	if item.requestedFrom == nil {
+		return
+	}
+
+	if state, exists := c.peers[item.requestedFrom]; exists && state.inFlight > 0 {
+		state.inFlight--
+	}
+	item.requestedFrom = nil
+}
+
+// removePending removes the item from the pending list. The lock must be held.
+func (c *InvCollector) removePending(item *invItem) {
+	for i, pending := range c.pending {
+		if pending == item {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			break
+		}
+	}
+	item.isPending = false
+}
+
+func containsInvPeer(peers []InvPeer, peer InvPeer) bool {
+	for _, p := range peers {
+		if p == peer {
+			return true
+		}
+	}
+	return false
+}
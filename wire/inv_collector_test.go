@@ -0,0 +1,228 @@
+package wire
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+type mockInvPeer struct {
+	name     string
+	fail     bool
+	requests []*MsgGetData
+	lock     sync.Mutex
+}
+
+func (p *mockInvPeer) Send(msg Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.fail {
+		return errors.New("Send failed")
+	}
+
+	getData, ok := msg.(*MsgGetData)
+	if !ok {
+		return errors.New("Not getdata")
+	}
+
+	p.requests = append(p.requests, getData)
+	return nil
+}
+
+func (p *mockInvPeer) requested() map[InvVect]bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	result := make(map[InvVect]bool)
+	for _, msg := range p.requests {
+		for _, inv := range msg.InvList {
+			result[*inv] = true
+		}
+	}
+	p.requests = nil
+	return result
+}
+
+func testInvs(count int) []*InvVect {
+	result := make([]*InvVect, count)
+	for i := range result {
+		hash := bitcoin.Hash32{byte(i), byte(i >> 8), 1}
+		result[i] = NewInvVect(InvTypeTx, &hash)
+	}
+	return result
+}
+
+func TestInvCollectorDeduplicate(t *testing.T) {
+	ctx := context.Background()
+	collector := NewInvCollector(DefaultInvCollectorConfig())
+
+	peer1 := &mockInvPeer{name: "peer1"}
+	peer2 := &mockInvPeer{name: "peer2"}
+
+	invs := testInvs(10)
+	if count := collector.Announce(peer1, invs); count != 10 {
+		t.Fatalf("Wrong new count : got %d, want %d", count, 10)
+	}
+	if count := collector.Announce(peer2, invs[5:]); count != 0 {
+		t.Fatalf("Wrong new count for duplicates : got %d, want %d", count, 0)
+	}
+
+	if count := collector.Schedule(ctx); count != 10 {
+		t.Fatalf("Wrong request count : got %d, want %d", count, 10)
+	}
+
+	// Each item is requested from exactly one peer.
+	requested1 := peer1.requested()
+	requested2 := peer2.requested()
+	if len(requested1)+len(requested2) != 10 {
+		t.Fatalf("Wrong total requests : %d + %d", len(requested1), len(requested2))
+	}
+	for inv := range requested1 {
+		if requested2[inv] {
+			t.Fatalf("Item requested from both peers : %s", inv.Hash)
+		}
+	}
+
+	// Requests are balanced between the peers that announced the items.
+	if len(requested2) == 0 {
+		t.Fatalf("No requests sent to second peer")
+	}
+
+	for _, inv := range invs {
+		collector.Received(*inv)
+	}
+
+	if collector.PendingCount() != 0 {
+		t.Fatalf("Wrong pending count : got %d, want %d", collector.PendingCount(), 0)
+	}
+	if collector.InFlight(peer1) != 0 || collector.InFlight(peer2) != 0 {
+		t.Fatalf("Wrong in flight : %d, %d", collector.InFlight(peer1), collector.InFlight(peer2))
+	}
+
+	// Received items are ignored when announced again.
+	if count := collector.Announce(peer2, invs); count != 0 {
+		t.Fatalf("Wrong new count for received items : got %d, want %d", count, 0)
+	}
+	if count := collector.Schedule(ctx); count != 0 {
+		t.Fatalf("Wrong request count for received items : got %d, want %d", count, 0)
+	}
+}
+
+func TestInvCollectorInFlightLimit(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultInvCollectorConfig()
+	config.MaxInFlightPerPeer = 4
+	collector := NewInvCollector(config)
+
+	peer := &mockInvPeer{name: "peer"}
+	invs := testInvs(10)
+	collector.Announce(peer, invs)
+
+	if count := collector.Schedule(ctx); count != 4 {
+		t.Fatalf("Wrong request count : got %d, want %d", count, 4)
+	}
+	if collector.InFlight(peer) != 4 {
+		t.Fatalf("Wrong in flight : got %d, want %d", collector.InFlight(peer), 4)
+	}
+
+	// Nothing more is requested until items are delivered.
+	if count := collector.Schedule(ctx); count != 0 {
+		t.Fatalf("Wrong request count at limit : got %d, want %d", count, 0)
+	}
+
+	for inv := range peer.requested() {
+		collector.Received(inv)
+	}
+
+	if count := collector.Schedule(ctx); count != 4 {
+		t.Fatalf("Wrong request count after delivery : got %d, want %d", count, 4)
+	}
+}
+
+func TestInvCollectorTimeout(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultInvCollectorConfig()
+	config.RequestTimeout = 10 * time.Millisecond
+	collector := NewInvCollector(config)
+
+	slow := &mockInvPeer{name: "slow"}
+	fast := &mockInvPeer{name: "fast"}
+
+	invs := testInvs(3)
+	collector.Announce(slow, invs)
+
+	if count := collector.Schedule(ctx); count != 3 {
+		t.Fatalf("Wrong request count : got %d, want %d", count, 3)
+	}
+	if len(slow.requested()) != 3 {
+		t.Fatalf("Items not requested from slow peer")
+	}
+
+	collector.Announce(fast, invs)
+	time.Sleep(20 * time.Millisecond)
+
+	// Timed out requests are reassigned to the other peer.
+	if count := collector.Schedule(ctx); count != 3 {
+		t.Fatalf("Wrong reassigned count : got %d, want %d", count, 3)
+	}
+	if len(fast.requested()) != 3 {
+		t.Fatalf("Items not reassigned to fast peer")
+	}
+	if collector.InFlight(slow) != 0 {
+		t.Fatalf("Wrong slow in flight : got %d, want %d", collector.InFlight(slow), 0)
+	}
+
+	// Items are dropped when no peers that announced them remain.
+	time.Sleep(20 * time.Millisecond)
+	if count := collector.Schedule(ctx); count != 0 {
+		t.Fatalf("Wrong request count after all timed out : got %d, want %d", count, 0)
+	}
+	if collector.PendingCount() != 0 {
+		t.Fatalf("Wrong pending count : got %d, want %d", collector.PendingCount(), 0)
+	}
+}
+
+func TestInvCollectorPeerFailures(t *testing.T) {
+	ctx := context.Background()
+	collector := NewInvCollector(DefaultInvCollectorConfig())
+
+	failing := &mockInvPeer{name: "failing", fail: true}
+	working := &mockInvPeer{name: "working"}
+	gone := &mockInvPeer{name: "gone"}
+
+	invs := testInvs(2)
+	collector.Announce(failing, invs)
+	collector.Schedule(ctx)
+
+	// The failing peer was removed, so the items are requested from the next peer.
+	collector.Announce(working, invs)
+	if count := collector.Schedule(ctx); count != 2 {
+		t.Fatalf("Wrong request count : got %d, want %d", count, 2)
+	}
+	working.requested()
+
+	// Not found items are requested from another peer.
+	collector.Announce(gone, invs)
+	collector.NotFound(working, invs[:1])
+	if count := collector.Schedule(ctx); count != 1 {
+		t.Fatalf("Wrong not found request count : got %d, want %d", count, 1)
+	}
+	if !gone.requested()[*invs[0]] {
+		t.Fatalf("Not found item not requested from other peer")
+	}
+
+	// Items requested from a removed peer are requested from another peer.
+	collector.RemovePeer(working)
+	if count := collector.Schedule(ctx); count != 1 {
+		t.Fatalf("Wrong removed peer request count : got %d, want %d", count, 1)
+	}
+	if !gone.requested()[*invs[1]] {
+		t.Fatalf("Removed peer item not requested from other peer")
+	}
+}
@@ -0,0 +1,240 @@
+package wire
+
+import (
+	"crypto/sha256"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidMerkleBlock = errors.New("Invalid merkle block")
+)
+
+// NewMerkleBlock creates a merkleblock message containing the partial merkle tree for the txs in
+//   the block that match the filter. It also returns the indexes of the matching txs, which
+//   should be sent to the peer after the merkleblock.
+func NewMerkleBlock(block *MsgBlock, filter *BloomFilter) (*MsgMerkleBlock, []int) {
+	txCount := len(block.Transactions)
+	txids := make([]bitcoin.Hash32, txCount)
+	matches := make([]bool, txCount)
+	var matchedIndexes []int
+	for i, tx := range block.Transactions {
+		txids[i] = *tx.TxHash()
+		if filter.MatchTxAndUpdate(tx) {
+			matches[i] = true
+			matchedIndexes = append(matchedIndexes, i)
+		}
+	}
+
+	return newMerkleBlock(&block.Header, txids, matches), matchedIndexes
+}
+
+func newMerkleBlock(header *BlockHeader, txids []bitcoin.Hash32,
+	matches []bool) *MsgMerkleBlock {
+
+	builder := &partialMerkleTree{
+		txCount: uint32(len(txids)),
+		txids:   txids,
+		matches: matches,
+	}
+
+	height := builder.height()
+	builder.traverseAndBuild(height, 0)
+
+	result := NewMsgMerkleBlock(header)
+	result.Transactions = builder.txCount
+	for i := range builder.hashes {
+		result.AddTxHash(&builder.hashes[i])
+	}
+
+	result.Flags = make([]byte, (len(builder.bits)+7)/8)
+	for i, bit := range builder.bits {
+		if bit {
+			result.Flags[i/8] |= 1 << (uint(i) % 8)
+		}
+	}
+
+	return result
+}
+
+// ExtractMatches verifies the partial merkle tree against the merkle root in the header and
+//   returns the hashes of the matching txs.
+func (msg *MsgMerkleBlock) ExtractMatches() ([]bitcoin.Hash32, error) {
+	if msg.Transactions == 0 {
+		return nil, errors.Wrap(ErrInvalidMerkleBlock, "no txs")
+	}
+
+	if uint32(len(msg.Hashes)) > msg.Transactions {
+		return nil, errors.Wrapf(ErrInvalidMerkleBlock, "more hashes than txs : %d > %d",
+			len(msg.Hashes), msg.Transactions)
+	}
+
+	if len(msg.Flags)*8 < len(msg.Hashes) {
+		return nil, errors.Wrapf(ErrInvalidMerkleBlock, "not enough flag bits : %d < %d",
+			len(msg.Flags)*8, len(msg.Hashes))
+	}
+
+	extractor := &partialMerkleTree{
+		txCount: msg.Transactions,
+		bits:    make([]bool, len(msg.Flags)*8),
+		hashes:  make([]bitcoin.Hash32, len(msg.Hashes)),
+	}
+	for i := range extractor.bits {
+		extractor.bits[i] = msg.Flags[i/8]&(1<<(uint(i)%8)) != 0
+	}
+	for i, hash := range msg.Hashes {
+		extractor.hashes[i] = *hash
+	}
+
+	root, err := extractor.traverseAndExtract(extractor.height(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if extractor.hashesUsed != len(extractor.hashes) {
+		return nil, errors.Wrapf(ErrInvalidMerkleBlock, "not all hashes used : %d/%d",
+			extractor.hashesUsed, len(extractor.hashes))
+	}
+
+	if (extractor.bitsUsed+7)/8 != len(msg.Flags) {
+		return nil, errors.Wrapf(ErrInvalidMerkleBlock, "not all flag bytes used : %d/%d",
+			(extractor.bitsUsed+7)/8, len(msg.Flags))
+	}
+
+	if !root.Equal(&msg.Header.MerkleRoot) {
+		return nil, ErrMerkleRootMismatch
+	}
+
+	return extractor.matched, nil
+}
+
+// partialMerkleTree builds and extracts the partial merkle trees of merkleblock messages as
+//   specified by BIP0037.
+type partialMerkleTree struct {
+	txCount uint32
+	txids   []bitcoin.Hash32
+	matches []bool
+
+	bits       []bool
+	hashes     []bitcoin.Hash32
+	bitsUsed   int
+	hashesUsed int
+	matched    []bitcoin.Hash32
+}
+
+// width returns the number of nodes at the specified height of the tree.
+func (t *partialMerkleTree) width(height uint) uint32 {
+	return uint32((uint64(t.txCount) + (1 << height) - 1) >> height)
+}
+
+// height returns the height of the root of the tree.
+func (t *partialMerkleTree) height() uint {
+	height := uint(0)
+	for t.width(height) > 1 {
+		height++
+	}
+	return height
+}
+
+// calcHash returns the hash of the node at the specified height and position.
+func (t *partialMerkleTree) calcHash(height uint, pos uint32) bitcoin.Hash32 {
+	if height == 0 {
+		return t.txids[pos]
+	}
+
+	left := t.calcHash(height-1, pos*2)
+	right := left
+	if pos*2+1 < t.width(height-1) {
+		right = t.calcHash(height-1, pos*2+1)
+	}
+
+	return hashMerkleNodes(left, right)
+}
+
+// traverseAndBuild adds the flag bits and hashes for the node at the specified height and
+//   position.
+func (t *partialMerkleTree) traverseAndBuild(height uint, pos uint32) {
+	// Determine if this node is the parent of a matched tx.
+	isParent := false
+	end := (uint64(pos) + 1) << height
+	if end > uint64(t.txCount) {
+		end = uint64(t.txCount)
+	}
+	for i := uint64(pos) << height; i < end; i++ {
+		if t.matches[i] {
+			isParent = true
+			break
+		}
+	}
+
+	t.bits = append(t.bits, isParent)
+
+	if height == 0 || !isParent {
+		t.hashes = append(t.hashes, t.calcHash(height, pos))
+		return
+	}
+
+	t.traverseAndBuild(height-1, pos*2)
+	if pos*2+1 < t.width(height-1) {
+		t.traverseAndBuild(height-1, pos*2+1)
+	}
+}
+
+// traverseAndExtract consumes the flag bits and hashes for the node at the specified height and
+//   position and returns its hash.
+func (t *partialMerkleTree) traverseAndExtract(height uint,
+	pos uint32) (bitcoin.Hash32, error) {
+
+	if t.bitsUsed >= len(t.bits) {
+		return bitcoin.Hash32{}, errors.Wrap(ErrInvalidMerkleBlock, "not enough flag bits")
+	}
+
+	isParent := t.bits[t.bitsUsed]
+	t.bitsUsed++
+
+	if height == 0 || !isParent {
+		if t.hashesUsed >= len(t.hashes) {
+			return bitcoin.Hash32{}, errors.Wrap(ErrInvalidMerkleBlock, "not enough hashes")
+		}
+
+		hash := t.hashes[t.hashesUsed]
+		t.hashesUsed++
+		if height == 0 && isParent {
+			t.matched = append(t.matched, hash)
+		}
+		return hash, nil
+	}
+
+	left, err := t.traverseAndExtract(height-1, pos*2)
+	if err != nil {
+		return bitcoin.Hash32{}, err
+	}
+
+	right := left
+	if pos*2+1 < t.width(height-1) {
+		right, err = t.traverseAndExtract(height-1, pos*2+1)
+		if err != nil {
+			return bitcoin.Hash32{}, err
+		}
+
+		// Identical left and right branches would allow duplicate txs to be hidden in a tree
+		//   (CVE-2012-2459).
+		if right.Equal(&left) {
+			return bitcoin.Hash32{}, errors.Wrap(ErrInvalidMerkleBlock,
+				"duplicate merkle branch")
+		}
+	}
+
+	return hashMerkleNodes(left, right), nil
+}
+
+// hashMerkleNodes returns the double SHA256 of the concatenated hashes.
+func hashMerkleNodes(left, right bitcoin.Hash32) bitcoin.Hash32 {
+	var b [bitcoin.Hash32Size * 2]byte
+	copy(b[:], left[:])
+	copy(b[bitcoin.Hash32Size:], right[:])
+	hash := sha256.Sum256(b[:])
+	return bitcoin.Hash32(sha256.Sum256(hash[:]))
+}
@@ -0,0 +1,92 @@
+package wire
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+func TestMerkleBlockExtractMatches(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for _, txCount := range []int{1, 2, 3, 7, 8, 100, 1001} {
+		tree := NewMerkleTree(false)
+		txids := make([]bitcoin.Hash32, txCount)
+		matches := make([]bool, txCount)
+		var want []bitcoin.Hash32
+		for i := range txids {
+			random.Read(txids[i][:])
+			tree.AddHash(txids[i])
+			if random.Intn(10) == 0 {
+				matches[i] = true
+				want = append(want, txids[i])
+			}
+		}
+
+		header := &BlockHeader{MerkleRoot: tree.RootHash()}
+		msg := newMerkleBlock(header, txids, matches)
+
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+			t.Fatalf("Failed to encode merkle block : %s", err)
+		}
+
+		read := &MsgMerkleBlock{}
+		if err := read.BtcDecode(&buf, ProtocolVersion); err != nil {
+			t.Fatalf("Failed to decode merkle block : %s", err)
+		}
+
+		got, err := read.ExtractMatches()
+		if err != nil {
+			t.Fatalf("Failed to extract matches (%d txs) : %s", txCount, err)
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Wrong match count (%d txs) : got %d, want %d", txCount, len(got),
+				len(want))
+		}
+		for i := range want {
+			if !got[i].Equal(&want[i]) {
+				t.Fatalf("Wrong match %d : got %s, want %s", i, got[i], want[i])
+			}
+		}
+
+		// A modified hash must not validate against the header.
+		read.Hashes[0][0] ^= 0x01
+		if _, err := read.ExtractMatches(); err == nil {
+			t.Fatalf("Modified merkle block validated")
+		}
+	}
+}
+
+func TestNewMerkleBlock(t *testing.T) {
+	block := &MsgBlock{}
+	tree := NewMerkleTree(false)
+	for i := 0; i < 5; i++ {
+		tx := NewMsgTx(1)
+		tx.AddTxIn(NewTxIn(NewOutPoint(&bitcoin.Hash32{}, uint32(i)), nil))
+		tx.AddTxOut(NewTxOut(uint64(i), []byte{0x6a}))
+		block.AddTransaction(tx)
+		tree.AddHash(*tx.TxHash())
+	}
+	block.Header.MerkleRoot = tree.RootHash()
+
+	filter := NewBloomFilter(1, 0, 0.0001, BloomUpdateNone)
+	filter.AddHash(*block.Transactions[3].TxHash())
+
+	msg, indexes := NewMerkleBlock(block, filter)
+	if len(indexes) != 1 || indexes[0] != 3 {
+		t.Fatalf("Wrong matched indexes : %v", indexes)
+	}
+
+	matches, err := msg.ExtractMatches()
+	if err != nil {
+		t.Fatalf("Failed to extract matches : %s", err)
+	}
+
+	if len(matches) != 1 || !matches[0].Equal(block.Transactions[3].TxHash()) {
+		t.Fatalf("Wrong matches : %v", matches)
+	}
+}
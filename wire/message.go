@@ -21,6 +21,10 @@ import (
 // checksum 4 bytes.
 const MessageHeaderSize = 24
 
+// ExtendedHeaderSize is the number of bytes that follow the message header of an extended
+// message. Extended command 12 bytes + extended payload length 8 bytes.
+const ExtendedHeaderSize = 20
+
 // CommandSize is the fixed size of all commands in the common bitcoin message
 // header.  Shorter commands must be zero padded.
 const CommandSize = 12
@@ -54,6 +58,13 @@ const (
 	CmdReject      = "reject"
 	CmdSendHeaders = "sendheaders"
 	CmdFeeFilter   = "feefilter"
+	CmdSendCmpct   = "sendcmpct"
+	CmdCmpctBlock  = "cmpctblock"
+	CmdGetBlockTxn = "getblocktxn"
+	CmdBlockTxn    = "blocktxn"
+	CmdGetUTXOs    = "getutxos"
+	CmdUTXOs       = "utxos"
+	CmdDSProof     = "dsproof-beta"
 	CmdProtoconf   = "protoconf"
 	CmdExtended    = "extmsg" // added in protocol version 70016
 )
@@ -155,6 +166,30 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdFeeFilter:
 		msg = &MsgFeeFilter{}
 
+	case CmdProtoconf:
+		msg = &MsgProtoconf{}
+
+	case CmdSendCmpct:
+		msg = &MsgSendCmpct{}
+
+	case CmdCmpctBlock:
+		msg = &MsgCmpctBlock{}
+
+	case CmdGetBlockTxn:
+		msg = &MsgGetBlockTxn{}
+
+	case CmdBlockTxn:
+		msg = &MsgBlockTxn{}
+
+	case CmdGetUTXOs:
+		msg = &MsgGetUTXOs{}
+
+	case CmdUTXOs:
+		msg = &MsgUTXOs{}
+
+	case CmdDSProof:
+		msg = &MsgDSProof{}
+
 	case CmdExtended:
 		msg = &MsgExtended{}
 
@@ -222,6 +257,14 @@ func discardInput(r io.Reader, n uint32) {
 // information and returns the number of bytes written.    This function is the
 // same as WriteMessage except it also returns the number of bytes written.
 func WriteMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (uint64, error) {
+	n, err := writeMessageN(w, msg, pver, btcnet)
+	if metrics := GetMessageMetrics(); metrics != nil {
+		metrics.recordSend(msg.Command(), n, err)
+	}
+	return n, err
+}
+
+func writeMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (uint64, error) {
 	totalBytes := uint64(0)
 
 	// Enforce max command size.
@@ -230,10 +273,24 @@ func WriteMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (ui
 	if len(cmd) > CommandSize {
 		str := fmt.Sprintf("command [%s] is too long [max %v]",
 			cmd, CommandSize)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, messageTypeError("WriteMessage", MessageErrorTooLarge, str)
 	}
 	copy(command[:], []byte(cmd))
 
+	// Extended messages that are already encoded are written with an extended header.
+	if extendedMsg, ok := msg.(*MsgExtended); ok {
+		return writeExtendedMessage(w, extendedMsg.ExtCommand, extendedMsg.Length,
+			bytes.NewReader(extendedMsg.Payload), pver, btcnet)
+	}
+
+	// Stream messages that are too large for a standard header, when their size is known, so
+	// they don't have to be buffered.
+	if sizer, ok := msg.(serializeSizer); ok {
+		if size := uint64(sizer.SerializeSize()); size >= math.MaxUint32 {
+			return writeExtendedMessageStream(w, msg, size, pver, btcnet)
+		}
+	}
+
 	// Encode the message payload.
 	var bw bytes.Buffer
 	err := msg.BtcEncode(&bw, pver)
@@ -248,7 +305,7 @@ func WriteMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (ui
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload is %d bytes",
 			lenp, MaxMessagePayload)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, messageTypeError("WriteMessage", MessageErrorTooLarge, str)
 	}
 
 	// Enforce maximum message payload based on the message type.
@@ -257,30 +314,19 @@ func WriteMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (ui
 		str := fmt.Sprintf("message payload is too large - encoded "+
 			"%d bytes, but maximum message payload size for "+
 			"messages of type [%s] is %d.", lenp, cmd, mpl)
-		return totalBytes, messageError("WriteMessage", str)
+		return totalBytes, messageTypeError("WriteMessage", MessageErrorTooLarge, str)
+	}
+
+	if lenp >= math.MaxUint32 {
+		return writeExtendedMessage(w, cmd, uint64(lenp), bytes.NewReader(payload), pver,
+			btcnet)
 	}
 
 	// Create header for the message.
 	hdr := messageHeader{}
 	hdr.magic = btcnet
-	if lenp >= math.MaxUint32 {
-		// Convert to extended message
-		hdr.command = CmdExtended
-		hdr.length = math.MaxUint32
-		copy(command[:], []byte(CmdExtended))
-
-		extendedMsg := NewMsgExtended(cmd, payload)
-		var extendedBuffer bytes.Buffer
-		err := extendedMsg.BtcEncode(&extendedBuffer, pver)
-		if err != nil {
-			return totalBytes, err
-		}
-		payload = extendedBuffer.Bytes()
-
-	} else {
-		hdr.command = cmd
-		hdr.length = uint32(lenp)
-	}
+	hdr.command = cmd
+	hdr.length = uint32(lenp)
 	copy(hdr.checksum[:], bitcoin.DoubleSha256(payload)[0:4])
 
 	// Encode the header for the message.  This is done to a buffer
@@ -317,19 +363,44 @@ func WriteMessage(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) erro
 // bytes read in addition to the parsed Message and raw bytes which comprise the
 // message.  This function is the same as ReadMessage except it also returns the
 // number of bytes read.
+//
+// The payload of an extended message is decoded directly from r, without being
+// buffered, so the raw bytes returned are nil.
 func ReadMessageN(r io.Reader, pver uint32, btcnet BitcoinNet) (uint64, Message, []byte, error) {
+	n, command, msg, payload, err := readMessageN(r, pver, btcnet)
+	if metrics := GetMessageMetrics(); metrics != nil {
+		metrics.recordReceive(command, n, err)
+	}
+	return n, msg, payload, err
+}
+
+// readMessageN reads the next message and also returns the command from its header so it can be
+// recorded in the message metrics.
+func readMessageN(r io.Reader, pver uint32,
+	btcnet BitcoinNet) (uint64, string, Message, []byte, error) {
+
 	totalBytes := uint64(0)
 	n, hdr, err := readMessageHeader(r)
 	totalBytes += uint64(n)
 	if err != nil {
-		return totalBytes, nil, nil, errors.Wrap(err, "read header")
+		return totalBytes, "", nil, nil, errors.Wrap(err, "read header")
 	}
 
 	// Check for messages from the wrong bitcoin network.
 	if hdr.magic != btcnet {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("[%v]", hdr.magic)
-		return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorWrongNetwork, str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorWrongNetwork, str)
+	}
+
+	if hdr.command == CmdExtended && hdr.length == math.MaxUint32 {
+		n, msg, err := readExtendedMessage(r, pver)
+		command := CmdExtended
+		if msg != nil {
+			command = msg.Command()
+		}
+		return totalBytes + n, command, msg, nil, err
 	}
 
 	// Enforce maximum message payload.
@@ -337,23 +408,24 @@ func ReadMessageN(r io.Reader, pver uint32, btcnet BitcoinNet) (uint64, Message,
 		str := fmt.Sprintf("message payload is too large - header "+
 			"indicates %d bytes, but max message payload is %d "+
 			"bytes.", hdr.length, MaxMessagePayload)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorTooLarge, str)
 	}
 
 	// Check for malformed commands.
 	command := hdr.command
 	if !utf8.ValidString(command) {
 		discardInput(r, hdr.length)
-		return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorUnknownCommand,
-			command)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorUnknownCommand, command)
 	}
 
 	// Create struct of appropriate message type based on the command.
 	msg, err := makeEmptyMessage(command)
 	if err != nil {
 		discardInput(r, hdr.length)
-		return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorUnknownCommand,
-			command)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorUnknownCommand, command)
 	}
 
 	// Check for maximum length based on the message type as a malicious client
@@ -365,17 +437,17 @@ func ReadMessageN(r io.Reader, pver uint32, btcnet BitcoinNet) (uint64, Message,
 		str := fmt.Sprintf("payload exceeds max length - header "+
 			"indicates %v bytes, but max payload size for "+
 			"messages of type [%v] is %v.", hdr.length, command, mpl)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorTooLarge, str)
 	}
 
 	// Read payload.
-	payload := make([]byte, hdr.length)
-	n, err = io.ReadFull(r, payload)
-	totalBytes += uint64(n)
+	payload, err := readBytes(r, uint64(hdr.length))
+	totalBytes += uint64(len(payload))
 	if err != nil {
 		// If read failed assume closed connection since net package doesn't give consistent errors.
-		return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorConnectionClosed,
-			err.Error())
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorConnectionClosed, err.Error())
 	}
 
 	// Test checksum.
@@ -383,7 +455,8 @@ func ReadMessageN(r io.Reader, pver uint32, btcnet BitcoinNet) (uint64, Message,
 	if !bytes.Equal(checksum, hdr.checksum[:]) {
 		str := fmt.Sprintf("payload checksum failed - header "+
 			"indicates %v, but actual checksum is %v.", hdr.checksum, checksum)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorInvalidChecksum, str)
 	}
 
 	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
@@ -391,10 +464,10 @@ func ReadMessageN(r io.Reader, pver uint32, btcnet BitcoinNet) (uint64, Message,
 	pr := bytes.NewBuffer(payload)
 	err = msg.BtcDecode(pr, pver)
 	if err != nil {
-		return totalBytes, nil, nil, err
+		return totalBytes, hdr.command, nil, nil, err
 	}
 
-	return totalBytes, msg, payload, nil
+	return totalBytes, command, msg, payload, nil
 }
 
 // ReadMessage reads, validates, and parses the next bitcoin Message from r for
@@ -0,0 +1,194 @@
+package wire
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// UnknownCommand is the command that messages with unknown or invalid commands are counted
+	//   under, so that a remote node can't grow the metrics with random commands.
+	UnknownCommand = "unknown"
+)
+
+var (
+	globalMessageMetrics atomic.Value
+)
+
+// MessageCounters contains the counts for one message command.
+type MessageCounters struct {
+	ReceivedCount uint64 // Messages received and decoded successfully
+	ReceivedBytes uint64 // Bytes received, including headers and failed messages
+	SentCount     uint64 // Messages sent successfully
+	SentBytes     uint64 // Bytes sent, including headers and failed messages
+	DecodeErrors  uint64 // Received messages that failed to decode or validate
+	SendErrors    uint64 // Messages that failed to encode or write
+}
+
+// Add adds the counts from another set of counters.
+func (c *MessageCounters) Add(other MessageCounters) {
+	c.ReceivedCount += other.ReceivedCount
+	c.ReceivedBytes += other.ReceivedBytes
+	c.SentCount += other.SentCount
+	c.SentBytes += other.SentBytes
+	c.DecodeErrors += other.DecodeErrors
+	c.SendErrors += other.SendErrors
+}
+
+// MessageMetrics collects counts of messages read and written by ReadMessageN, ReadMessageParse,
+//   and WriteMessageN, and the functions that call them. Metrics are only collected after they
+//   are enabled with SetMessageMetrics.
+//
+//   metrics := NewMessageMetrics()
+//   SetMessageMetrics(metrics)
+//   ...
+//   snapshot := metrics.Snapshot()
+type MessageMetrics struct {
+	commands map[string]*MessageCounters
+	start    time.Time
+
+	sync.Mutex
+}
+
+// MessageMetricsSnapshot is a copy of message metrics at a point in time.
+type MessageMetricsSnapshot struct {
+	Time     time.Time
+	Commands map[string]MessageCounters
+}
+
+// NewMessageMetrics creates empty message metrics.
+func NewMessageMetrics() *MessageMetrics {
+	return &MessageMetrics{
+		commands: make(map[string]*MessageCounters),
+		start:    time.Now(),
+	}
+}
+
+// SetMessageMetrics sets the metrics that messages are recorded in. Nil stops recording.
+func SetMessageMetrics(metrics *MessageMetrics) {
+	globalMessageMetrics.Store(&metrics)
+}
+
+// GetMessageMetrics returns the metrics that messages are recorded in, or nil if metrics are not
+//   enabled.
+func GetMessageMetrics() *MessageMetrics {
+	metrics, ok := globalMessageMetrics.Load().(**MessageMetrics)
+	if !ok {
+		return nil
+	}
+	return *metrics
+}
+
+// Snapshot returns a copy of the current counters.
+func (m *MessageMetrics) Snapshot() MessageMetricsSnapshot {
+	m.Lock()
+	defer m.Unlock()
+
+	result := MessageMetricsSnapshot{
+		Time:     time.Now(),
+		Commands: make(map[string]MessageCounters, len(m.commands)),
+	}
+	for command, counters := range m.commands {
+		result.Commands[command] = *counters
+	}
+
+	return result
+}
+
+// Reset clears all counters.
+func (m *MessageMetrics) Reset() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.commands = make(map[string]*MessageCounters)
+	m.start = time.Now()
+}
+
+// Since returns the time the metrics were created or last reset.
+func (m *MessageMetrics) Since() time.Time {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.start
+}
+
+func (m *MessageMetrics) counters(command string) *MessageCounters {
+	if result, exists := m.commands[command]; exists {
+		return result
+	}
+
+	if _, err := makeEmptyMessage(command); err != nil {
+		command = UnknownCommand
+		if result, exists := m.commands[command]; exists {
+			return result
+		}
+	}
+
+	result := &MessageCounters{}
+	m.commands[command] = result
+	return result
+}
+
+func (m *MessageMetrics) recordReceive(command string, bytes uint64, err error) {
+	if len(command) == 0 && bytes == 0 {
+		return // nothing was read
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	counters := m.counters(command)
+	counters.ReceivedBytes += bytes
+	if err == nil {
+		counters.ReceivedCount++
+	} else if len(command) > 0 && !isConnectionClosed(err) {
+		counters.DecodeErrors++
+	}
+}
+
+func (m *MessageMetrics) recordSend(command string, bytes uint64, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	counters := m.counters(command)
+	counters.SentBytes += bytes
+	if err == nil {
+		counters.SentCount++
+	} else {
+		counters.SendErrors++
+	}
+}
+
+// isConnectionClosed returns true if the error is from the connection closing rather than from an
+//   invalid message.
+func isConnectionClosed(err error) bool {
+	messageErr, ok := errors.Cause(err).(*MessageError)
+	return ok && messageErr.Type == MessageErrorConnectionClosed
+}
+
+// Total returns the sum of the counters for all commands.
+func (s MessageMetricsSnapshot) Total() MessageCounters {
+	var result MessageCounters
+	for _, counters := range s.Commands {
+		result.Add(counters)
+	}
+	return result
+}
+
+// Rates returns the bytes per second received and sent between a previous snapshot and this
+//   snapshot.
+func (s MessageMetricsSnapshot) Rates(previous MessageMetricsSnapshot) (float64, float64) {
+	seconds := s.Time.Sub(previous.Time).Seconds()
+	if seconds <= 0 {
+		return 0, 0
+	}
+
+	total := s.Total()
+	previousTotal := previous.Total()
+	received := float64(total.ReceivedBytes-previousTotal.ReceivedBytes) / seconds
+	sent := float64(total.SentBytes-previousTotal.SentBytes) / seconds
+	return received, sent
+}
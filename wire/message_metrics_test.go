@@ -0,0 +1,79 @@
+package wire
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMessageMetrics(t *testing.T) {
+	metrics := NewMessageMetrics()
+	SetMessageMetrics(metrics)
+	defer SetMessageMetrics(nil)
+
+	previous := metrics.Snapshot()
+
+	var buf bytes.Buffer
+	sent, err := WriteMessageN(&buf, NewMsgPing(123), ProtocolVersion, MainNet)
+	if err != nil {
+		t.Fatalf("Failed to write message : %s", err)
+	}
+
+	received, _, _, err := ReadMessageN(&buf, ProtocolVersion, MainNet)
+	if err != nil {
+		t.Fatalf("Failed to read message : %s", err)
+	}
+
+	// Corrupt the checksum so the message fails validation.
+	if _, err := WriteMessageN(&buf, NewMsgPing(456), ProtocolVersion, MainNet); err != nil {
+		t.Fatalf("Failed to write message : %s", err)
+	}
+	buf.Bytes()[MessageHeaderSize-1] ^= 0xff
+	if _, _, _, err := ReadMessageN(&buf, ProtocolVersion, MainNet); err == nil {
+		t.Fatalf("Read corrupted message")
+	}
+
+	// Unknown commands are grouped together.
+	unknown := &fakeMessage{command: "random", payload: []byte{1, 2, 3}}
+	if _, err := WriteMessageN(&buf, unknown, ProtocolVersion, MainNet); err != nil {
+		t.Fatalf("Failed to write message : %s", err)
+	}
+	if _, _, _, err := ReadMessageN(&buf, ProtocolVersion, MainNet); err == nil {
+		t.Fatalf("Read unknown message")
+	}
+
+	snapshot := metrics.Snapshot()
+	ping := snapshot.Commands[CmdPing]
+	if ping.SentCount != 2 || ping.SentBytes != 2*sent {
+		t.Fatalf("Wrong ping sent counts : %+v", ping)
+	}
+	if ping.ReceivedCount != 1 || ping.ReceivedBytes != 2*received || ping.DecodeErrors != 1 {
+		t.Fatalf("Wrong ping received counts : %+v", ping)
+	}
+
+	if _, exists := snapshot.Commands["random"]; exists {
+		t.Fatalf("Unknown command counted separately")
+	}
+	if snapshot.Commands[UnknownCommand].DecodeErrors != 1 {
+		t.Fatalf("Wrong unknown counts : %+v", snapshot.Commands[UnknownCommand])
+	}
+
+	total := snapshot.Total()
+	if total.DecodeErrors != 2 || total.SentCount != 3 {
+		t.Fatalf("Wrong total counts : %+v", total)
+	}
+
+	snapshot.Time = previous.Time.Add(time.Second)
+	receivedRate, sentRate := snapshot.Rates(previous)
+	if receivedRate != float64(total.ReceivedBytes) || sentRate != float64(total.SentBytes) {
+		t.Fatalf("Wrong rates : received %f, sent %f", receivedRate, sentRate)
+	}
+
+	SetMessageMetrics(nil)
+	if _, err := WriteMessageN(&buf, NewMsgPing(789), ProtocolVersion, MainNet); err != nil {
+		t.Fatalf("Failed to write message : %s", err)
+	}
+	if metrics.Snapshot().Commands[CmdPing].SentCount != 2 {
+		t.Fatalf("Message recorded after metrics disabled")
+	}
+}
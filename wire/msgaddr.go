@@ -32,7 +32,7 @@ func (msg *MsgAddr) AddAddress(na *NetAddress) error {
 	if len(msg.AddrList)+1 > MaxAddrPerMsg {
 		str := fmt.Sprintf("too many addresses in message [max %v]",
 			MaxAddrPerMsg)
-		return messageError("MsgAddr.AddAddress", str)
+		return messageTypeError("MsgAddr.AddAddress", MessageErrorInvalidCount, str)
 	}
 
 	msg.AddrList = append(msg.AddrList, na)
@@ -67,7 +67,7 @@ func (msg *MsgAddr) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxAddrPerMsg {
 		str := fmt.Sprintf("too many addresses for message "+
 			"[count %v, max %v]", count, MaxAddrPerMsg)
-		return messageError("MsgAddr.BtcDecode", str)
+		return messageTypeError("MsgAddr.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	addrList := make([]NetAddress, count)
@@ -92,13 +92,13 @@ func (msg *MsgAddr) BtcEncode(w io.Writer, pver uint32) error {
 	if pver < MultipleAddressVersion && count > 1 {
 		str := fmt.Sprintf("too many addresses for message of "+
 			"protocol version %v [count %v, max 1]", pver, count)
-		return messageError("MsgAddr.BtcEncode", str)
+		return messageTypeError("MsgAddr.BtcEncode", MessageErrorInvalidCount, str)
 
 	}
 	if count > MaxAddrPerMsg {
 		str := fmt.Sprintf("too many addresses for message "+
 			"[count %v, max %v]", count, MaxAddrPerMsg)
-		return messageError("MsgAddr.BtcEncode", str)
+		return messageTypeError("MsgAddr.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
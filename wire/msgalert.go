@@ -161,7 +161,7 @@ func (alert *Alert) Serialize(w io.Writer, pver uint32) error {
 	if count > maxCountSetCancel {
 		str := fmt.Sprintf("too many cancel alert IDs for alert "+
 			"[count %v, max %v]", count, maxCountSetCancel)
-		return messageError("Alert.Serialize", str)
+		return messageTypeError("Alert.Serialize", MessageErrorInvalidCount, str)
 	}
 	err = WriteVarInt(w, pver, count)
 	if err != nil {
@@ -183,7 +183,7 @@ func (alert *Alert) Serialize(w io.Writer, pver uint32) error {
 	if count > maxCountSetSubVer {
 		str := fmt.Sprintf("too many sub versions for alert "+
 			"[count %v, max %v]", count, maxCountSetSubVer)
-		return messageError("Alert.Serialize", str)
+		return messageTypeError("Alert.Serialize", MessageErrorInvalidCount, str)
 	}
 	err = WriteVarInt(w, pver, uint64(count))
 	if err != nil {
@@ -230,7 +230,7 @@ func (alert *Alert) Deserialize(r io.Reader, pver uint32) error {
 	if count > maxCountSetCancel {
 		str := fmt.Sprintf("too many cancel alert IDs for alert "+
 			"[count %v, max %v]", count, maxCountSetCancel)
-		return messageError("Alert.Deserialize", str)
+		return messageTypeError("Alert.Deserialize", MessageErrorInvalidCount, str)
 	}
 	alert.SetCancel = make([]int32, count)
 	for i := 0; i < int(count); i++ {
@@ -254,7 +254,7 @@ func (alert *Alert) Deserialize(r io.Reader, pver uint32) error {
 	if count > maxCountSetSubVer {
 		str := fmt.Sprintf("too many sub versions for alert "+
 			"[count %v, max %v]", count, maxCountSetSubVer)
-		return messageError("Alert.Deserialize", str)
+		return messageTypeError("Alert.Deserialize", MessageErrorInvalidCount, str)
 	}
 	alert.SetSubVer = make([]string, count)
 	for i := 0; i < int(count); i++ {
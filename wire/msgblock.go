@@ -59,6 +59,18 @@ func (msg *MsgBlock) IsMerkleRootValid() bool {
 	return msg.Header.MerkleRoot.Equal(merkleRoot)
 }
 
+// IsMerkleRootValidWithTxHashes returns true if the merkle root of the tx hashes, like those
+// returned by DeserializeWithTxHashes, matches the header.
+func (msg *MsgBlock) IsMerkleRootValidWithTxHashes(txHashes []*bitcoin.Hash32) bool {
+	if len(txHashes) == 0 {
+		return msg.Header.MerkleRoot.Equal(&bitcoin.Hash32{})
+	}
+	if len(txHashes) == 1 {
+		return msg.Header.MerkleRoot.Equal(txHashes[0])
+	}
+	return msg.Header.MerkleRoot.Equal(calculateMerkleLevel(txHashes))
+}
+
 func (msg *MsgBlock) GetTxCount() uint64 {
 	return uint64(len(msg.Transactions))
 }
@@ -93,15 +105,30 @@ func (msg *MsgBlock) ClearTransactions() {
 // See Deserialize for decoding blocks stored to disk, such as in a database, as
 // opposed to decoding blocks from the wire.
 func (msg *MsgBlock) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := msg.decode(r, pver, false)
+	return err
+}
+
+// DeserializeWithTxHashes decodes a block from r in the same manner Deserialize does and returns
+// the hashes of its txs in block order. The hashes of large blocks' txs are calculated
+// concurrently while the rest of the block is decoded. They aren't updated if the txs are
+// modified.
+func (msg *MsgBlock) DeserializeWithTxHashes(r io.Reader) ([]*bitcoin.Hash32, error) {
+	return msg.decode(r, 0, true)
+}
+
+func (msg *MsgBlock) decode(r io.Reader, pver uint32,
+	withHashes bool) ([]*bitcoin.Hash32, error) {
+
 	err := readBlockHeader(r, pver, &msg.Header)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	msg.txOffset = 0
 	txCount, err := ReadVarInt(r, pver)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Prevent more transactions than could possibly fit into a block.
@@ -110,20 +137,32 @@ func (msg *MsgBlock) BtcDecode(r io.Reader, pver uint32) error {
 	if txCount > maxTxPerBlock {
 		str := fmt.Sprintf("too many transactions to fit into a block "+
 			"[count %d, max %d]", txCount, maxTxPerBlock)
-		return messageError("MsgBlock.BtcDecode", str)
+		return nil, messageTypeError("MsgBlock.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	var hasher *txHasher
+	var hashes []*bitcoin.Hash32
+	if withHashes {
+		hasher = newTxHasher(txCount)
+		defer hasher.finish()
+		hashes = make([]*bitcoin.Hash32, 0, preallocCount(txCount))
 	}
 
-	msg.Transactions = make([]*MsgTx, 0, txCount)
+	msg.Transactions = make([]*MsgTx, 0, preallocCount(txCount))
 	for i := uint64(0); i < txCount; i++ {
 		tx := MsgTx{}
 		err := tx.BtcDecode(r, pver)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		msg.Transactions = append(msg.Transactions, &tx)
+		if withHashes {
+			hashes = append(hashes, hasher.add(&tx))
+		}
 	}
 
-	return nil
+	hasher.finish()
+	return hashes, nil
 }
 
 // Deserialize decodes a block from r into the receiver using a format that is
@@ -169,15 +208,15 @@ func (msg *MsgBlock) DeserializeTxLoc(r *bytes.Buffer) ([]TxLoc, error) {
 	if txCount > maxTxPerBlock {
 		str := fmt.Sprintf("too many transactions to fit into a block "+
 			"[count %d, max %d]", txCount, maxTxPerBlock)
-		return nil, messageError("MsgBlock.DeserializeTxLoc", str)
+		return nil, messageTypeError("MsgBlock.DeserializeTxLoc", MessageErrorInvalidCount, str)
 	}
 
 	// Deserialize each transaction while keeping track of its location
 	// within the byte stream.
-	msg.Transactions = make([]*MsgTx, 0, txCount)
-	txLocs := make([]TxLoc, txCount)
+	msg.Transactions = make([]*MsgTx, 0, preallocCount(txCount))
+	txLocs := make([]TxLoc, 0, preallocCount(txCount))
 	for i := uint64(0); i < txCount; i++ {
-		txLocs[i].TxStart = fullLen - r.Len()
+		txLocs = append(txLocs, TxLoc{TxStart: fullLen - r.Len()})
 		tx := MsgTx{}
 		err := tx.Deserialize(r)
 		if err != nil {
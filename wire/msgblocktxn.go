@@ -0,0 +1,102 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+// MsgBlockTxn implements the Message interface and represents a bitcoin blocktxn message. It
+// contains the txs requested by a getblocktxn message, in the same order. See BIP0152.
+//
+// This message was not added until protocol versions starting with CompactBlocksVersion.
+type MsgBlockTxn struct {
+	BlockHash bitcoin.Hash32
+	Txs       []*MsgTx
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgBlockTxn) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("blocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgBlockTxn.BtcDecode", str)
+	}
+
+	if err := readElement(r, &msg.BlockHash); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageTypeError("MsgBlockTxn.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	msg.Txs = make([]*MsgTx, 0, minUint64(count, defaultTransactionAlloc))
+	for i := uint64(0); i < count; i++ {
+		tx := &MsgTx{}
+		if err := tx.BtcDecode(r, pver); err != nil {
+			return errors.Wrapf(err, "tx %d", i)
+		}
+		msg.Txs = append(msg.Txs, tx)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgBlockTxn) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("blocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgBlockTxn.BtcEncode", str)
+	}
+
+	if err := writeElement(w, &msg.BlockHash); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.Txs))); err != nil {
+		return err
+	}
+
+	for _, tx := range msg.Txs {
+		if err := tx.BtcEncode(w, pver); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgBlockTxn) Command() string {
+	return CmdBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgBlockTxn) MaxPayloadLength(pver uint32) uint64 {
+	return MaxBlockPayload
+}
+
+// NewMsgBlockTxn returns a new bitcoin blocktxn message that conforms to
+// the Message interface.  See MsgBlockTxn for details.
+func NewMsgBlockTxn(blockHash bitcoin.Hash32, txs []*MsgTx) *MsgBlockTxn {
+	return &MsgBlockTxn{
+		BlockHash: blockHash,
+		Txs:       txs,
+	}
+}
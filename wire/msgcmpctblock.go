@@ -0,0 +1,203 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// ShortIDSize is the number of bytes in a compact block short id.
+const ShortIDSize = 6
+
+// PrefilledTx is a tx included in full in a compact block.
+type PrefilledTx struct {
+	Index uint64 // Index of the tx in the block.
+	Tx    *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a bitcoin cmpctblock message.
+// It contains a block header, the short ids of the txs in the block, and the txs that the
+// sender expects the receiver to be missing. See BIP0152.
+//
+// Prefilled tx indexes are absolute in this structure and are differentially encoded on the
+// wire.
+//
+// This message was not added until protocol versions starting with CompactBlocksVersion.
+type MsgCmpctBlock struct {
+	Header       BlockHeader
+	Nonce        uint64
+	ShortIDs     []uint64
+	PrefilledTxs []*PrefilledTx
+}
+
+// TxCount returns the total number of txs in the block.
+func (msg *MsgCmpctBlock) TxCount() uint64 {
+	return uint64(len(msg.ShortIDs)) + uint64(len(msg.PrefilledTxs))
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	if err := readBlockHeader(r, pver, &msg.Header); err != nil {
+		return err
+	}
+
+	if err := readElement(r, &msg.Nonce); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many short ids to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageTypeError("MsgCmpctBlock.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	msg.ShortIDs = make([]uint64, 0, minUint64(count, defaultTransactionAlloc))
+	for i := uint64(0); i < count; i++ {
+		shortID, err := readShortID(r)
+		if err != nil {
+			return err
+		}
+		msg.ShortIDs = append(msg.ShortIDs, shortID)
+	}
+
+	count, err = ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	if count > maxTxPerBlock-uint64(len(msg.ShortIDs)) {
+		str := fmt.Sprintf("too many prefilled txs to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageTypeError("MsgCmpctBlock.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	msg.PrefilledTxs = make([]*PrefilledTx, 0, minUint64(count, defaultTransactionAlloc))
+	nextIndex := uint64(0)
+	for i := uint64(0); i < count; i++ {
+		offset, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+
+		if offset > math.MaxUint64-nextIndex {
+			return messageError("MsgCmpctBlock.BtcDecode", "prefilled tx index overflow")
+		}
+		index := nextIndex + offset
+
+		tx := &MsgTx{}
+		if err := tx.BtcDecode(r, pver); err != nil {
+			return errors.Wrapf(err, "prefilled tx %d", i)
+		}
+
+		msg.PrefilledTxs = append(msg.PrefilledTxs, &PrefilledTx{
+			Index: index,
+			Tx:    tx,
+		})
+		nextIndex = index + 1
+	}
+
+	if len(msg.PrefilledTxs) > 0 &&
+		msg.PrefilledTxs[len(msg.PrefilledTxs)-1].Index >= msg.TxCount() {
+		return messageError("MsgCmpctBlock.BtcDecode", "prefilled tx index out of range")
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	if err := writeBlockHeader(w, pver, &msg.Header); err != nil {
+		return err
+	}
+
+	if err := writeElement(w, msg.Nonce); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.ShortIDs))); err != nil {
+		return err
+	}
+
+	for _, shortID := range msg.ShortIDs {
+		if err := writeShortID(w, shortID); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.PrefilledTxs))); err != nil {
+		return err
+	}
+
+	nextIndex := uint64(0)
+	for _, prefilled := range msg.PrefilledTxs {
+		if prefilled.Index < nextIndex {
+			return messageError("MsgCmpctBlock.BtcEncode", "prefilled txs not in order")
+		}
+
+		if err := WriteVarInt(w, pver, prefilled.Index-nextIndex); err != nil {
+			return err
+		}
+
+		if err := prefilled.Tx.BtcEncode(w, pver); err != nil {
+			return err
+		}
+		nextIndex = prefilled.Index + 1
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint64 {
+	return MaxBlockPayload
+}
+
+func readShortID(r io.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:ShortIDSize]); err != nil {
+		return 0, err
+	}
+	return endian.Uint64(b[:]), nil
+}
+
+func writeShortID(w io.Writer, shortID uint64) error {
+	var b [8]byte
+	endian.PutUint64(b[:], shortID)
+	_, err := w.Write(b[:ShortIDSize])
+	return err
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,399 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxDSProofPushDataCount is the maximum number of push data items in a double spend proof
+	// spender.
+	MaxDSProofPushDataCount = 16
+
+	// MaxDSProofPushDataSize is the maximum size of a push data item in a double spend proof
+	// spender.
+	MaxDSProofPushDataSize = 520
+
+	dsSigHashNone         = 0x02
+	dsSigHashSingle       = 0x03
+	dsSigHashForkID       = 0x40
+	dsSigHashAnyOneCanPay = 0x80
+	dsSigHashMask         = 0x1f
+)
+
+var (
+	// ErrInvalidDSProof means a double spend proof is malformed or its spenders don't conflict.
+	ErrInvalidDSProof = errors.New("Invalid Double Spend Proof")
+
+	// ErrDSProofSignature means a spender's signature in a double spend proof is not valid for
+	// the output being spent.
+	ErrDSProofSignature = errors.New("Double Spend Proof Signature Invalid")
+
+	// ErrDSProofUnsupportedScript means a double spend proof spends a locking script that it can't
+	// be verified against.
+	ErrDSProofUnsupportedScript = errors.New("Double Spend Proof Unsupported Script")
+)
+
+// DSSpender is one of the two conflicting spends of an output in a double spend proof. It
+// contains the parts of the signature hash preimage that are specific to the spending tx, so the
+// signature can be verified without the full tx.
+type DSSpender struct {
+	TxVersion       uint32
+	Sequence        uint32
+	LockTime        uint32
+	HashPrevOutputs bitcoin.Hash32
+	HashSequence    bitcoin.Hash32
+	HashOutputs     bitcoin.Hash32
+
+	// PushData is the data pushed by the unlocking script. For P2PKH it is the signature followed
+	// by the public key.
+	PushData [][]byte
+}
+
+// MsgDSProof implements the Message interface and represents a double spend proof message. It
+// proves that two different txs spend the same output by including the signed data from each.
+type MsgDSProof struct {
+	OutPoint OutPoint
+	Spender1 DSSpender
+	Spender2 DSSpender
+}
+
+// NewDSSpender creates the spender for the input of the tx from its unlocking script. The first
+// push of the unlocking script must be a signature.
+func NewDSSpender(tx *MsgTx, index int) (*DSSpender, error) {
+	if index < 0 || index >= len(tx.TxIn) {
+		return nil, fmt.Errorf("Input index out of range : %d", index)
+	}
+	input := tx.TxIn[index]
+
+	pushData, err := unlockingScriptPushData(input.UnlockingScript)
+	if err != nil {
+		return nil, errors.Wrap(err, "unlocking script")
+	}
+
+	if len(pushData) == 0 || len(pushData[0]) == 0 {
+		return nil, errors.Wrap(ErrInvalidDSProof, "missing signature")
+	}
+	hashType := uint32(pushData[0][len(pushData[0])-1])
+
+	result := &DSSpender{
+		TxVersion: uint32(tx.Version),
+		Sequence:  input.Sequence,
+		LockTime:  tx.LockTime,
+		PushData:  pushData,
+	}
+
+	if hashType&dsSigHashAnyOneCanPay == 0 {
+		var buf bytes.Buffer
+		for _, in := range tx.TxIn {
+			in.PreviousOutPoint.Serialize(&buf)
+		}
+		copy(result.HashPrevOutputs[:], bitcoin.DoubleSha256(buf.Bytes()))
+	}
+
+	if hashType&dsSigHashAnyOneCanPay == 0 && hashType&dsSigHashMask != dsSigHashSingle &&
+		hashType&dsSigHashMask != dsSigHashNone {
+		var buf bytes.Buffer
+		for _, in := range tx.TxIn {
+			binary.Write(&buf, binary.LittleEndian, in.Sequence)
+		}
+		copy(result.HashSequence[:], bitcoin.DoubleSha256(buf.Bytes()))
+	}
+
+	if hashType&dsSigHashMask != dsSigHashSingle && hashType&dsSigHashMask != dsSigHashNone {
+		var buf bytes.Buffer
+		for _, out := range tx.TxOut {
+			out.Serialize(&buf, 0, 0)
+		}
+		copy(result.HashOutputs[:], bitcoin.DoubleSha256(buf.Bytes()))
+	} else if hashType&dsSigHashMask == dsSigHashSingle && index < len(tx.TxOut) {
+		var buf bytes.Buffer
+		tx.TxOut[index].Serialize(&buf, 0, 0)
+		copy(result.HashOutputs[:], bitcoin.DoubleSha256(buf.Bytes()))
+	}
+
+	return result, nil
+}
+
+// NewMsgDSProof creates a double spend proof from two txs that spend the same output. index1 and
+// index2 are the indexes of the inputs that spend it.
+func NewMsgDSProof(tx1 *MsgTx, index1 int, tx2 *MsgTx, index2 int) (*MsgDSProof, error) {
+	spender1, err := NewDSSpender(tx1, index1)
+	if err != nil {
+		return nil, errors.Wrap(err, "spender 1")
+	}
+
+	spender2, err := NewDSSpender(tx2, index2)
+	if err != nil {
+		return nil, errors.Wrap(err, "spender 2")
+	}
+
+	outpoint := tx1.TxIn[index1].PreviousOutPoint
+	if !outpoint.Hash.Equal(&tx2.TxIn[index2].PreviousOutPoint.Hash) ||
+		outpoint.Index != tx2.TxIn[index2].PreviousOutPoint.Index {
+		return nil, errors.Wrap(ErrInvalidDSProof, "inputs spend different outputs")
+	}
+
+	if dsSpenderLess(spender2, spender1) {
+		spender1, spender2 = spender2, spender1
+	}
+
+	result := &MsgDSProof{
+		OutPoint: outpoint,
+		Spender1: *spender1,
+		Spender2: *spender2,
+	}
+
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Hash returns the identifier of the proof, which is the double SHA256 of its serialized form.
+func (msg *MsgDSProof) Hash() bitcoin.Hash32 {
+	var buf bytes.Buffer
+	msg.BtcEncode(&buf, ProtocolVersion)
+
+	var result bitcoin.Hash32
+	copy(result[:], bitcoin.DoubleSha256(buf.Bytes()))
+	return result
+}
+
+// Validate checks that the proof is well formed. The spenders must be different, contain a
+// signature, and be in canonical order.
+func (msg *MsgDSProof) Validate() error {
+	for i, spender := range []*DSSpender{&msg.Spender1, &msg.Spender2} {
+		if len(spender.PushData) == 0 || len(spender.PushData[0]) == 0 {
+			return errors.Wrapf(ErrInvalidDSProof, "spender %d missing signature", i+1)
+		}
+	}
+
+	if dsSpenderEqual(&msg.Spender1, &msg.Spender2) {
+		return errors.Wrap(ErrInvalidDSProof, "spenders have the same inputs and outputs")
+	}
+
+	if dsSpenderLess(&msg.Spender2, &msg.Spender1) {
+		return errors.Wrap(ErrInvalidDSProof, "spenders not in canonical order")
+	}
+
+	return nil
+}
+
+// Verify checks that both spenders contain valid signatures for the output being spent, which
+// proves that two different txs spend it. Only P2PKH locking scripts are supported.
+func (msg *MsgDSProof) Verify(lockingScript bitcoin.Script, value uint64) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+
+	for i, spender := range []*DSSpender{&msg.Spender1, &msg.Spender2} {
+		if err := spender.Verify(msg.OutPoint, lockingScript, value); err != nil {
+			return errors.Wrapf(err, "spender %d", i+1)
+		}
+	}
+
+	return nil
+}
+
+// SignatureHash returns the hash signed by the spender's signature.
+func (s DSSpender) SignatureHash(outpoint OutPoint, lockingScript bitcoin.Script,
+	value uint64, hashType uint32) bitcoin.Hash32 {
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, s.TxVersion)
+	buf.Write(s.HashPrevOutputs[:])
+	buf.Write(s.HashSequence[:])
+	outpoint.Serialize(&buf)
+	WriteVarBytes(&buf, 0, lockingScript)
+	binary.Write(&buf, binary.LittleEndian, value)
+	binary.Write(&buf, binary.LittleEndian, s.Sequence)
+	buf.Write(s.HashOutputs[:])
+	binary.Write(&buf, binary.LittleEndian, s.LockTime)
+	binary.Write(&buf, binary.LittleEndian, hashType|dsSigHashForkID)
+
+	var result bitcoin.Hash32
+	copy(result[:], bitcoin.DoubleSha256(buf.Bytes()))
+	return result
+}
+
+// Verify checks that the spender's signature is valid for the P2PKH output being spent.
+func (s DSSpender) Verify(outpoint OutPoint, lockingScript bitcoin.Script, value uint64) error {
+	if !lockingScript.IsP2PKH() {
+		return ErrDSProofUnsupportedScript
+	}
+
+	if len(s.PushData) != 2 || len(s.PushData[0]) == 0 {
+		return errors.Wrap(ErrInvalidDSProof, "wrong push data count")
+	}
+
+	publicKey, err := bitcoin.PublicKeyFromBytes(s.PushData[1])
+	if err != nil {
+		return errors.Wrap(err, "public key")
+	}
+
+	pkhs, err := bitcoin.PKHsFromLockingScript(lockingScript)
+	if err != nil {
+		return errors.Wrap(err, "locking script")
+	}
+
+	if len(pkhs) != 1 || !bytes.Equal(pkhs[0][:], bitcoin.Hash160(publicKey.Bytes())) {
+		return errors.Wrap(ErrDSProofSignature, "wrong public key")
+	}
+
+	sigBytes := s.PushData[0]
+	hashType := uint32(sigBytes[len(sigBytes)-1])
+	if hashType&dsSigHashForkID == 0 {
+		return errors.Wrap(ErrDSProofSignature, "missing fork id")
+	}
+
+	signature, err := bitcoin.SignatureFromBytes(sigBytes[:len(sigBytes)-1])
+	if err != nil {
+		return errors.Wrap(err, "signature")
+	}
+
+	hash := s.SignatureHash(outpoint, lockingScript, value, hashType)
+	if !signature.Verify(hash, publicKey) {
+		return ErrDSProofSignature
+	}
+
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgDSProof) BtcDecode(r io.Reader, pver uint32) error {
+	if err := readOutPoint(r, pver, 0, &msg.OutPoint); err != nil {
+		return err
+	}
+
+	if err := msg.Spender1.read(r, pver); err != nil {
+		return err
+	}
+
+	return msg.Spender2.read(r, pver)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgDSProof) BtcEncode(w io.Writer, pver uint32) error {
+	if err := msg.OutPoint.Serialize(w); err != nil {
+		return err
+	}
+
+	if err := msg.Spender1.write(w, pver); err != nil {
+		return err
+	}
+
+	return msg.Spender2.write(w, pver)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgDSProof) Command() string {
+	return CmdDSProof
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgDSProof) MaxPayloadLength(pver uint32) uint64 {
+	spender := uint64(12+32*3) + MaxVarIntPayload +
+		MaxDSProofPushDataCount*(MaxVarIntPayload+MaxDSProofPushDataSize)
+	return 36 + 2*spender
+}
+
+func (s *DSSpender) read(r io.Reader, pver uint32) error {
+	if err := readElements(r, &s.TxVersion, &s.Sequence, &s.LockTime, &s.HashPrevOutputs,
+		&s.HashSequence, &s.HashOutputs); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	if count > MaxDSProofPushDataCount {
+		str := fmt.Sprintf("too many push data items [count %d, max %d]", count,
+			MaxDSProofPushDataCount)
+		return messageTypeError("MsgDSProof.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	s.PushData = make([][]byte, count)
+	for i := range s.PushData {
+		data, err := ReadVarBytes(r, pver, MaxDSProofPushDataSize, "dsproof push data")
+		if err != nil {
+			return err
+		}
+		s.PushData[i] = data
+	}
+
+	return nil
+}
+
+func (s *DSSpender) write(w io.Writer, pver uint32) error {
+	count := len(s.PushData)
+	if count > MaxDSProofPushDataCount {
+		str := fmt.Sprintf("too many push data items [count %d, max %d]", count,
+			MaxDSProofPushDataCount)
+		return messageTypeError("MsgDSProof.BtcEncode", MessageErrorInvalidCount, str)
+	}
+
+	if err := writeElements(w, s.TxVersion, s.Sequence, s.LockTime, &s.HashPrevOutputs,
+		&s.HashSequence, &s.HashOutputs); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+
+	for _, data := range s.PushData {
+		if err := WriteVarBytes(w, pver, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dsSpenderLess returns true if l is before r in canonical order, which is by hash of outputs
+// then hash of previous outputs.
+func dsSpenderLess(l, r *DSSpender) bool {
+	if c := bytes.Compare(l.HashOutputs[:], r.HashOutputs[:]); c != 0 {
+		return c < 0
+	}
+	return bytes.Compare(l.HashPrevOutputs[:], r.HashPrevOutputs[:]) < 0
+}
+
+func dsSpenderEqual(l, r *DSSpender) bool {
+	return l.HashOutputs.Equal(&r.HashOutputs) && l.HashPrevOutputs.Equal(&r.HashPrevOutputs)
+}
+
+// unlockingScriptPushData returns the data pushed by an unlocking script, which must contain only
+// push data.
+func unlockingScriptPushData(script bitcoin.Script) ([][]byte, error) {
+	var result [][]byte
+	buf := bytes.NewReader(script)
+	for buf.Len() > 0 {
+		item, err := bitcoin.ParseScript(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		if item.Type != bitcoin.ScriptItemTypePushData {
+			return nil, errors.Wrap(ErrInvalidDSProof, "unlocking script not push only")
+		}
+
+		result = append(result, item.Data)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,208 @@
+package wire
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+// dsTestSpend returns a tx that spends the outpoint with a signed P2PKH unlocking script.
+func dsTestSpend(t *testing.T, key bitcoin.Key, outpoint OutPoint, lockingScript bitcoin.Script,
+	value uint64, payTo bitcoin.Script) *MsgTx {
+
+	tx := NewMsgTx(1)
+	tx.AddTxIn(NewTxIn(&outpoint, nil))
+	tx.AddTxOut(NewTxOut(value-200, payTo))
+
+	// Build the spender with a placeholder signature to get the hashes to sign.
+	placeholder := &bytes.Buffer{}
+	bitcoin.WritePushDataScript(placeholder, []byte{0x41})
+	tx.TxIn[0].UnlockingScript = placeholder.Bytes()
+
+	spender, err := NewDSSpender(tx, 0)
+	if err != nil {
+		t.Fatalf("Failed to create spender : %s", err)
+	}
+
+	sigHash := spender.SignatureHash(outpoint, lockingScript, value, 0x41)
+	signature, err := key.Sign(sigHash)
+	if err != nil {
+		t.Fatalf("Failed to sign : %s", err)
+	}
+
+	unlockingScript := &bytes.Buffer{}
+	bitcoin.WritePushDataScript(unlockingScript, append(signature.Bytes(), 0x41))
+	bitcoin.WritePushDataScript(unlockingScript, key.PublicKey().Bytes())
+	tx.TxIn[0].UnlockingScript = unlockingScript.Bytes()
+
+	return tx
+}
+
+func TestDSProof(t *testing.T) {
+	key, err := bitcoin.GenerateKey(bitcoin.MainNet)
+	if err != nil {
+		t.Fatalf("Failed to generate key : %s", err)
+	}
+
+	lockingScript, err := key.LockingScript()
+	if err != nil {
+		t.Fatalf("Failed to create locking script : %s", err)
+	}
+
+	var outpoint OutPoint
+	outpoint.Hash[0] = 1
+	outpoint.Index = 2
+	value := uint64(10000)
+
+	var payTo []bitcoin.Script
+	for i := 0; i < 2; i++ {
+		other, err := bitcoin.GenerateKey(bitcoin.MainNet)
+		if err != nil {
+			t.Fatalf("Failed to generate key : %s", err)
+		}
+		script, err := other.LockingScript()
+		if err != nil {
+			t.Fatalf("Failed to create locking script : %s", err)
+		}
+		payTo = append(payTo, script)
+	}
+
+	tx1 := dsTestSpend(t, key, outpoint, lockingScript, value, payTo[0])
+	tx2 := dsTestSpend(t, key, outpoint, lockingScript, value, payTo[1])
+
+	proof, err := NewMsgDSProof(tx1, 0, tx2, 0)
+	if err != nil {
+		t.Fatalf("Failed to create proof : %s", err)
+	}
+
+	if err := proof.Verify(lockingScript, value); err != nil {
+		t.Fatalf("Failed to verify proof : %s", err)
+	}
+
+	// Wrong value changes the signature hash.
+	if err := proof.Verify(lockingScript, value+1); errors.Cause(err) != ErrDSProofSignature {
+		t.Fatalf("Wrong error for wrong value : got %v, want %s", err, ErrDSProofSignature)
+	}
+
+	// Wrong locking script doesn't match the public key.
+	if err := proof.Verify(payTo[0], value); errors.Cause(err) != ErrDSProofSignature {
+		t.Fatalf("Wrong error for wrong script : got %v, want %s", err, ErrDSProofSignature)
+	}
+
+	// Same tx twice is not a double spend.
+	if _, err := NewMsgDSProof(tx1, 0, tx1, 0); errors.Cause(err) != ErrInvalidDSProof {
+		t.Fatalf("Wrong error for same tx : got %v, want %s", err, ErrInvalidDSProof)
+	}
+
+	// Spenders out of order.
+	swapped := *proof
+	swapped.Spender1, swapped.Spender2 = proof.Spender2, proof.Spender1
+	if err := swapped.Validate(); errors.Cause(err) != ErrInvalidDSProof {
+		t.Fatalf("Wrong error for swapped spenders : got %v, want %s", err, ErrInvalidDSProof)
+	}
+
+	var buf bytes.Buffer
+	if err := proof.BtcEncode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("Failed to encode proof : %s", err)
+	}
+
+	if uint64(buf.Len()) > proof.MaxPayloadLength(ProtocolVersion) {
+		t.Fatalf("Proof larger than max payload : %d", buf.Len())
+	}
+
+	read := &MsgDSProof{}
+	if err := read.BtcDecode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("Failed to decode proof : %s", err)
+	}
+
+	if !reflect.DeepEqual(read, proof) {
+		t.Fatalf("Wrong decoded proof : \ngot  %+v\nwant %+v", read, proof)
+	}
+
+	if read.Hash() != proof.Hash() {
+		t.Fatalf("Wrong decoded proof hash")
+	}
+
+	msg, err := makeEmptyMessage(CmdDSProof)
+	if err != nil {
+		t.Fatalf("Failed to make message : %s", err)
+	}
+	if _, ok := msg.(*MsgDSProof); !ok {
+		t.Fatalf("Wrong message type : %T", msg)
+	}
+}
+
+func TestDSNTOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		callbacks []net.IP
+		inputs    []uint32
+	}{
+		{
+			name:      "ipv4 all inputs",
+			callbacks: []net.IP{net.ParseIP("1.2.3.4").To4()},
+		},
+		{
+			name:      "ipv4 some inputs",
+			callbacks: []net.IP{net.ParseIP("1.2.3.4").To4(), net.ParseIP("5.6.7.8").To4()},
+			inputs:    []uint32{0, 3, 300},
+		},
+		{
+			name:      "ipv6",
+			callbacks: []net.IP{net.ParseIP("2001:db8::1")},
+			inputs:    []uint32{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsnt := NewDSNTOutput(tt.callbacks, tt.inputs)
+			script, err := dsnt.LockingScript()
+			if err != nil {
+				t.Fatalf("Failed to create locking script : %s", err)
+			}
+
+			tx := NewMsgTx(1)
+			tx.AddTxOut(NewTxOut(1000, script[:2]))
+			tx.AddTxOut(NewTxOut(0, script))
+
+			index, read, err := FindDSNTOutput(tx)
+			if err != nil {
+				t.Fatalf("Failed to find dsnt output : %s", err)
+			}
+			if index != 1 {
+				t.Fatalf("Wrong output index : got %d, want %d", index, 1)
+			}
+
+			if !reflect.DeepEqual(read, dsnt) {
+				t.Fatalf("Wrong dsnt : \ngot  %+v\nwant %+v", read, dsnt)
+			}
+
+			for _, input := range tt.inputs {
+				if !read.CoversInput(input) {
+					t.Fatalf("Input %d should be covered", input)
+				}
+			}
+			if len(tt.inputs) > 0 && read.CoversInput(2) {
+				t.Fatalf("Input 2 should not be covered")
+			}
+		})
+	}
+
+	_, err := ParseDSNTOutput(bitcoin.Script{bitcoin.OP_FALSE, bitcoin.OP_RETURN})
+	if errors.Cause(err) != ErrNotDSNT {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrNotDSNT)
+	}
+
+	var txid bitcoin.Hash32
+	url := DSNTSubmitURL(net.ParseIP("1.2.3.4"), txid, 1, txid, 2)
+	if url != "http://1.2.3.4/dsnt/1/submit?cn=2&ctxid="+txid.String()+"&n=1&txid="+
+		txid.String() {
+		t.Fatalf("Wrong submit url : %s", url)
+	}
+}
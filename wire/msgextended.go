@@ -3,8 +3,11 @@ package wire
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
@@ -12,6 +15,10 @@ import (
 // MsgExtended is a message that allows messages larger than 2^32 bytes to be sent in the P2P
 // protocol. It was added in protocol version 70016.
 // https://github.com/bitcoin-sv-specs/protocol/blob/master/p2p/large_messages.md
+//
+// ReadMessage and WriteMessage convert large messages to and from the extended format
+// automatically, streaming the payload, so this type is only needed to send a payload that is
+// already encoded.
 type MsgExtended struct {
 	ExtCommand string
 	Length     uint64
@@ -33,11 +40,12 @@ func (msg *MsgExtended) BtcDecode(r io.Reader, pver uint32) error {
 		return messageTypeError("ReadMessage", MessageErrorConnectionClosed, err.Error())
 	}
 
-	msg.Payload = make([]byte, msg.Length)
-	if _, err := io.ReadFull(r, msg.Payload); err != nil {
+	payload, err := readBytes(r, msg.Length)
+	if err != nil {
 		// If read failed assume closed connection since net package doesn't give consistent errors.
 		return messageTypeError("ReadMessage", MessageErrorConnectionClosed, err.Error())
 	}
+	msg.Payload = payload
 
 	return nil
 }
@@ -84,3 +92,165 @@ func NewMsgExtended(command string, payload []byte) *MsgExtended {
 		Payload:    payload,
 	}
 }
+
+// serializeSizer is implemented by messages that can calculate their encoded size without
+// encoding, so that large messages can be streamed.
+type serializeSizer interface {
+	SerializeSize() int
+}
+
+// writeExtendedHeader writes a message header and extended header for an extended message.
+func writeExtendedHeader(w io.Writer, command string, length uint64, btcnet BitcoinNet) error {
+	if len(command) > CommandSize {
+		return messageTypeError("WriteMessage", MessageErrorTooLarge,
+			fmt.Sprintf("command [%s] is too long [max %v]", command, CommandSize))
+	}
+
+	var extCommand, extendedCommand [CommandSize]byte
+	copy(extCommand[:], []byte(CmdExtended))
+	copy(extendedCommand[:], []byte(command))
+
+	// The checksum is not used for extended messages so it is zero.
+	var checksum [4]byte
+
+	hw := bytes.NewBuffer(make([]byte, 0, MessageHeaderSize+ExtendedHeaderSize))
+	writeElements(hw, btcnet, extCommand, uint32(math.MaxUint32), checksum, extendedCommand,
+		length)
+
+	_, err := w.Write(hw.Bytes())
+	return err
+}
+
+// writeExtendedMessage writes an extended message with a payload that is already encoded.
+func writeExtendedMessage(w io.Writer, command string, length uint64, payload io.Reader,
+	pver uint32, btcnet BitcoinNet) (uint64, error) {
+
+	if pver < ExtendedMessageVersion {
+		return 0, messageError("WriteMessage", fmt.Sprintf("extended messages not supported "+
+			"by protocol version %d", pver))
+	}
+
+	if err := writeExtendedHeader(w, command, length, btcnet); err != nil {
+		return 0, err
+	}
+	totalBytes := uint64(MessageHeaderSize + ExtendedHeaderSize)
+
+	n, err := io.CopyN(w, payload, int64(length))
+	totalBytes += uint64(n)
+	return totalBytes, err
+}
+
+// writeExtendedMessageStream writes an extended message, encoding the payload directly to the
+// writer.
+func writeExtendedMessageStream(w io.Writer, msg Message, size uint64, pver uint32,
+	btcnet BitcoinNet) (uint64, error) {
+
+	if pver < ExtendedMessageVersion {
+		return 0, messageError("WriteMessage", fmt.Sprintf("extended messages not supported "+
+			"by protocol version %d", pver))
+	}
+
+	if size > msg.MaxPayloadLength(pver) {
+		return 0, messageTypeError("WriteMessage", MessageErrorTooLarge,
+			fmt.Sprintf("message payload is too large - encoded %d bytes, but maximum message "+
+				"payload size for messages of type [%s] is %d.", size, msg.Command(),
+				msg.MaxPayloadLength(pver)))
+	}
+
+	if err := writeExtendedHeader(w, msg.Command(), size, btcnet); err != nil {
+		return 0, err
+	}
+
+	counter := &countWriter{w: w}
+	err := msg.BtcEncode(counter, pver)
+	totalBytes := uint64(MessageHeaderSize+ExtendedHeaderSize) + counter.count
+	if err != nil {
+		return totalBytes, err
+	}
+
+	if counter.count != size {
+		return totalBytes, messageError("WriteMessage", fmt.Sprintf("encoded %d bytes, but "+
+			"serialize size is %d", counter.count, size))
+	}
+
+	return totalBytes, nil
+}
+
+// readExtendedMessage reads the extended header following a message header with the extended
+// command and decodes the payload directly from the reader.
+func readExtendedMessage(r io.Reader, pver uint32) (uint64, Message, error) {
+	var headerBytes [ExtendedHeaderSize]byte
+	n, err := io.ReadFull(r, headerBytes[:])
+	totalBytes := uint64(n)
+	if err != nil {
+		// If read failed assume closed connection since net package doesn't give consistent errors.
+		return totalBytes, nil, messageTypeError("ReadMessage", MessageErrorConnectionClosed,
+			err.Error())
+	}
+
+	var extendedCommand [CommandSize]byte
+	var length uint64
+	readElements(bytes.NewReader(headerBytes[:]), &extendedCommand, &length)
+	command := string(bytes.TrimRight(extendedCommand[:], string(rune(0))))
+
+	if length > MaxMessagePayload {
+		return totalBytes, nil, messageTypeError("ReadMessage", MessageErrorTooLarge,
+			fmt.Sprintf("message payload is too large - header indicates %d bytes, but max "+
+				"message payload is %d bytes.", length, MaxMessagePayload))
+	}
+
+	if !utf8.ValidString(command) || command == CmdExtended {
+		totalBytes += discardInputN(r, length)
+		return totalBytes, nil, messageTypeError("ReadMessage", MessageErrorUnknownCommand,
+			command)
+	}
+
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		totalBytes += discardInputN(r, length)
+		return totalBytes, nil, messageTypeError("ReadMessage", MessageErrorUnknownCommand,
+			command)
+	}
+
+	if mpl := msg.MaxPayloadLength(pver); length > mpl {
+		totalBytes += discardInputN(r, length)
+		return totalBytes, nil, messageTypeError("ReadMessage", MessageErrorTooLarge,
+			fmt.Sprintf("payload exceeds max length - header indicates %v bytes, but max "+
+				"payload size for messages of type [%v] is %v.", length, command, mpl))
+	}
+
+	limited := &io.LimitedReader{R: r, N: int64(length)}
+	err = msg.BtcDecode(limited, pver)
+	totalBytes += length - uint64(limited.N)
+	if err != nil {
+		totalBytes += discardInputN(limited, uint64(limited.N))
+		return totalBytes, nil, err
+	}
+
+	if limited.N != 0 {
+		remaining := uint64(limited.N)
+		totalBytes += discardInputN(limited, remaining)
+		return totalBytes, nil, messageError("ReadMessage", fmt.Sprintf("payload has %d bytes "+
+			"remaining after decoding [%s]", remaining, command))
+	}
+
+	return totalBytes, msg, nil
+}
+
+// discardInputN reads and discards n bytes from r and returns the number of bytes read.
+func discardInputN(r io.Reader, n uint64) uint64 {
+	read, _ := io.CopyN(ioutil.Discard, r, int64(n))
+	return uint64(read)
+}
+
+// countWriter counts the bytes written to the underlying writer.
+type countWriter struct {
+	w     io.Writer
+	count uint64
+}
+
+func (cw *countWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.count += uint64(n)
+	return n, err
+}
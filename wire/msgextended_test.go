@@ -0,0 +1,82 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+func TestExtendedMessage(t *testing.T) {
+	net := BitcoinNet(bitcoin.MainNet)
+
+	tx := multiTx.Copy()
+	var txBuf bytes.Buffer
+	if err := tx.Serialize(&txBuf); err != nil {
+		t.Fatalf("Failed to serialize tx : %s", err)
+	}
+
+	var buf bytes.Buffer
+	written, err := WriteMessageN(&buf, NewMsgExtended(CmdTx, txBuf.Bytes()), ProtocolVersion,
+		net)
+	if err != nil {
+		t.Fatalf("Failed to write extended message : %s", err)
+	}
+
+	wantSize := uint64(MessageHeaderSize + ExtendedHeaderSize + txBuf.Len())
+	if written != wantSize {
+		t.Fatalf("Wrong written size : got %d, want %d", written, wantSize)
+	}
+
+	// Unknown extended command is skipped.
+	if err := WriteMessage(&buf, NewMsgExtended("unknown", []byte{1, 2, 3}), ProtocolVersion,
+		net); err != nil {
+		t.Fatalf("Failed to write unknown extended message : %s", err)
+	}
+
+	if err := WriteMessage(&buf, NewMsgPing(123), ProtocolVersion, net); err != nil {
+		t.Fatalf("Failed to write ping : %s", err)
+	}
+
+	read, msg, payload, err := ReadMessageN(&buf, ProtocolVersion, net)
+	if err != nil {
+		t.Fatalf("Failed to read extended message : %s", err)
+	}
+
+	if read != wantSize {
+		t.Fatalf("Wrong read size : got %d, want %d", read, wantSize)
+	}
+
+	if payload != nil {
+		t.Fatalf("Extended message payload should not be buffered")
+	}
+
+	readTx, ok := msg.(*MsgTx)
+	if !ok {
+		t.Fatalf("Wrong message type : %s", msg.Command())
+	}
+
+	if !reflect.DeepEqual(readTx, tx) {
+		t.Fatalf("Wrong tx : \ngot  %s\nwant %s", readTx.TxHash(), tx.TxHash())
+	}
+
+	_, _, err = ReadMessage(&buf, ProtocolVersion, net)
+	if msgErr, ok := err.(*MessageError); !ok || msgErr.Type != MessageErrorUnknownCommand {
+		t.Fatalf("Unknown extended command should return unknown command error : %v", err)
+	}
+
+	msg, _, err = ReadMessage(&buf, ProtocolVersion, net)
+	if err != nil {
+		t.Fatalf("Failed to read ping : %s", err)
+	}
+
+	if _, ok := msg.(*MsgPing); !ok {
+		t.Fatalf("Wrong message type after extended message : %s", msg.Command())
+	}
+
+	if err := WriteMessage(&buf, NewMsgExtended(CmdTx, txBuf.Bytes()), FeeFilterVersion,
+		net); err == nil {
+		t.Fatalf("Extended message should fail for old protocol version")
+	}
+}
@@ -53,7 +53,7 @@ func (msg *MsgFilterAdd) BtcEncode(w io.Writer, pver uint32) error {
 	if size > MaxFilterAddDataSize {
 		str := fmt.Sprintf("filteradd size too large for message "+
 			"[size %v, max %v]", size, MaxFilterAddDataSize)
-		return messageError("MsgFilterAdd.BtcEncode", str)
+		return messageTypeError("MsgFilterAdd.BtcEncode", MessageErrorTooLarge, str)
 	}
 
 	return WriteVarBytes(w, pver, msg.Data)
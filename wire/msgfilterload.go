@@ -73,7 +73,7 @@ func (msg *MsgFilterLoad) BtcDecode(r io.Reader, pver uint32) error {
 	if msg.HashFuncs > MaxFilterLoadHashFuncs {
 		str := fmt.Sprintf("too many filter hash functions for message "+
 			"[count %v, max %v]", msg.HashFuncs, MaxFilterLoadHashFuncs)
-		return messageError("MsgFilterLoad.BtcDecode", str)
+		return messageTypeError("MsgFilterLoad.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	return nil
@@ -92,13 +92,13 @@ func (msg *MsgFilterLoad) BtcEncode(w io.Writer, pver uint32) error {
 	if size > MaxFilterLoadFilterSize {
 		str := fmt.Sprintf("filterload filter size too large for message "+
 			"[size %v, max %v]", size, MaxFilterLoadFilterSize)
-		return messageError("MsgFilterLoad.BtcEncode", str)
+		return messageTypeError("MsgFilterLoad.BtcEncode", MessageErrorTooLarge, str)
 	}
 
 	if msg.HashFuncs > MaxFilterLoadHashFuncs {
 		str := fmt.Sprintf("too many filter hash functions for message "+
 			"[count %v, max %v]", msg.HashFuncs, MaxFilterLoadHashFuncs)
-		return messageError("MsgFilterLoad.BtcEncode", str)
+		return messageTypeError("MsgFilterLoad.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := WriteVarBytes(w, pver, msg.Filter)
@@ -41,7 +41,7 @@ func (msg *MsgGetBlocks) AddBlockLocatorHash(hash *bitcoin.Hash32) error {
 	if len(msg.BlockLocatorHashes)+1 > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message [max %v]",
 			MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetBlocks.AddBlockLocatorHash", str)
+		return messageTypeError("MsgGetBlocks.AddBlockLocatorHash", MessageErrorInvalidCount, str)
 	}
 
 	msg.BlockLocatorHashes = append(msg.BlockLocatorHashes, hash)
@@ -64,7 +64,7 @@ func (msg *MsgGetBlocks) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetBlocks.BtcDecode", str)
+		return messageTypeError("MsgGetBlocks.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
@@ -90,7 +90,7 @@ func (msg *MsgGetBlocks) BtcEncode(w io.Writer, pver uint32) error {
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetBlocks.BtcEncode", str)
+		return messageTypeError("MsgGetBlocks.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := writeElement(w, msg.ProtocolVersion)
@@ -0,0 +1,118 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+// MsgGetBlockTxn implements the Message interface and represents a bitcoin getblocktxn message.
+// It is used to request the txs that could not be found while reconstructing a block from a
+// cmpctblock message. See BIP0152.
+//
+// Indexes are absolute in this structure and are differentially encoded on the wire.
+//
+// This message was not added until protocol versions starting with CompactBlocksVersion.
+type MsgGetBlockTxn struct {
+	BlockHash bitcoin.Hash32
+	Indexes   []uint64
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+
+	if err := readElement(r, &msg.BlockHash); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many indexes to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageTypeError("MsgGetBlockTxn.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	msg.Indexes = make([]uint64, 0, minUint64(count, defaultTransactionAlloc))
+	nextIndex := uint64(0)
+	for i := uint64(0); i < count; i++ {
+		offset, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+
+		if offset > math.MaxUint64-nextIndex {
+			return messageError("MsgGetBlockTxn.BtcDecode", "index overflow")
+		}
+		index := nextIndex + offset
+
+		msg.Indexes = append(msg.Indexes, index)
+		nextIndex = index + 1
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcEncode", str)
+	}
+
+	if err := writeElement(w, &msg.BlockHash); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.Indexes))); err != nil {
+		return err
+	}
+
+	nextIndex := uint64(0)
+	for _, index := range msg.Indexes {
+		if index < nextIndex {
+			return messageError("MsgGetBlockTxn.BtcEncode", "indexes not in order")
+		}
+
+		if err := WriteVarInt(w, pver, index-nextIndex); err != nil {
+			return err
+		}
+		nextIndex = index + 1
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetBlockTxn) Command() string {
+	return CmdGetBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) MaxPayloadLength(pver uint32) uint64 {
+	return MaxBlockPayload
+}
+
+// NewMsgGetBlockTxn returns a new bitcoin getblocktxn message that conforms to
+// the Message interface.  See MsgGetBlockTxn for details.
+func NewMsgGetBlockTxn(blockHash bitcoin.Hash32, indexes []uint64) *MsgGetBlockTxn {
+	return &MsgGetBlockTxn{
+		BlockHash: blockHash,
+		Indexes:   indexes,
+	}
+}
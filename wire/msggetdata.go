@@ -28,7 +28,7 @@ func (msg *MsgGetData) AddInvVect(iv *InvVect) error {
 	if len(msg.InvList)+1 > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [max %v]",
 			MaxInvPerMsg)
-		return messageError("MsgGetData.AddInvVect", str)
+		return messageTypeError("MsgGetData.AddInvVect", MessageErrorInvalidCount, str)
 	}
 
 	msg.InvList = append(msg.InvList, iv)
@@ -46,7 +46,7 @@ func (msg *MsgGetData) BtcDecode(r io.Reader, pver uint32) error {
 	// Limit to max inventory vectors per message.
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgGetData.BtcDecode", str)
+		return messageTypeError("MsgGetData.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Create a contiguous slice of inventory vectors to deserialize into in
@@ -72,7 +72,7 @@ func (msg *MsgGetData) BtcEncode(w io.Writer, pver uint32) error {
 	count := len(msg.InvList)
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgGetData.BtcEncode", str)
+		return messageTypeError("MsgGetData.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
@@ -38,7 +38,7 @@ func (msg *MsgGetHeaders) AddBlockLocatorHash(hash *bitcoin.Hash32) error {
 	if len(msg.BlockLocatorHashes)+1 > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message [max %v]",
 			MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.AddBlockLocatorHash", str)
+		return messageTypeError("MsgGetHeaders.AddBlockLocatorHash", MessageErrorInvalidCount, str)
 	}
 
 	msg.BlockLocatorHashes = append(msg.BlockLocatorHashes, hash)
@@ -61,7 +61,7 @@ func (msg *MsgGetHeaders) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.BtcDecode", str)
+		return messageTypeError("MsgGetHeaders.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
@@ -88,7 +88,7 @@ func (msg *MsgGetHeaders) BtcEncode(w io.Writer, pver uint32) error {
 	if count > MaxBlockLocatorsPerMsg {
 		str := fmt.Sprintf("too many block locator hashes for message "+
 			"[count %v, max %v]", count, MaxBlockLocatorsPerMsg)
-		return messageError("MsgGetHeaders.BtcEncode", str)
+		return messageTypeError("MsgGetHeaders.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := writeElement(w, msg.ProtocolVersion)
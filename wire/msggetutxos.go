@@ -0,0 +1,110 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxGetUTXOsOutPoints is the maximum number of outpoints that can be requested in a single
+// getutxos message. See BIP0064.
+const MaxGetUTXOsOutPoints = 100
+
+// MsgGetUTXOs implements the Message interface and represents a bitcoin getutxos message. It is
+// used to request the unspent outputs for outpoints from a peer that advertises SFNodeGetUTXO.
+// The peer responds with a utxos message. See BIP0064.
+type MsgGetUTXOs struct {
+	// CheckMempool requests that outputs created and spent by txs in the mempool be considered.
+	CheckMempool bool
+	OutPoints    []*OutPoint
+}
+
+// AddOutPoint adds an outpoint to the message.
+func (msg *MsgGetUTXOs) AddOutPoint(outpoint *OutPoint) error {
+	if len(msg.OutPoints)+1 > MaxGetUTXOsOutPoints {
+		str := fmt.Sprintf("too many outpoints in message [max %d]", MaxGetUTXOsOutPoints)
+		return messageError("MsgGetUTXOs.AddOutPoint", str)
+	}
+
+	msg.OutPoints = append(msg.OutPoints, outpoint)
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetUTXOs) BtcDecode(r io.Reader, pver uint32) error {
+	if err := readElement(r, &msg.CheckMempool); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	if count > MaxGetUTXOsOutPoints {
+		str := fmt.Sprintf("too many outpoints in message [count %d, max %d]", count,
+			MaxGetUTXOsOutPoints)
+		return messageTypeError("MsgGetUTXOs.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	outpoints := make([]OutPoint, count)
+	msg.OutPoints = make([]*OutPoint, 0, count)
+	for i := range outpoints {
+		outpoint := &outpoints[i]
+		if err := readOutPoint(r, pver, 0, outpoint); err != nil {
+			return err
+		}
+		msg.OutPoints = append(msg.OutPoints, outpoint)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetUTXOs) BtcEncode(w io.Writer, pver uint32) error {
+	count := len(msg.OutPoints)
+	if count > MaxGetUTXOsOutPoints {
+		str := fmt.Sprintf("too many outpoints in message [count %d, max %d]", count,
+			MaxGetUTXOsOutPoints)
+		return messageTypeError("MsgGetUTXOs.BtcEncode", MessageErrorInvalidCount, str)
+	}
+
+	if err := writeElement(w, msg.CheckMempool); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+
+	for _, outpoint := range msg.OutPoints {
+		if err := outpoint.Serialize(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetUTXOs) Command() string {
+	return CmdGetUTXOs
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetUTXOs) MaxPayloadLength(pver uint32) uint64 {
+	// Mempool flag + num outpoints (varInt) + max allowed outpoints.
+	return 1 + MaxVarIntPayload + (MaxGetUTXOsOutPoints * (32 + 4))
+}
+
+// NewMsgGetUTXOs returns a new bitcoin getutxos message that conforms to the Message interface.
+// See MsgGetUTXOs for details.
+func NewMsgGetUTXOs(checkMempool bool, outpoints []*OutPoint) *MsgGetUTXOs {
+	return &MsgGetUTXOs{
+		CheckMempool: checkMempool,
+		OutPoints:    outpoints,
+	}
+}
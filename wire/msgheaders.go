@@ -27,7 +27,7 @@ func (msg *MsgHeaders) AddBlockHeader(bh *BlockHeader) error {
 	if len(msg.Headers)+1 > MaxBlockHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers in message [max %v]",
 			MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.AddBlockHeader", str)
+		return messageTypeError("MsgHeaders.AddBlockHeader", MessageErrorInvalidCount, str)
 	}
 
 	msg.Headers = append(msg.Headers, bh)
@@ -46,7 +46,7 @@ func (msg *MsgHeaders) BtcDecode(r io.Reader, pver uint32) error {
 	if count > MaxBlockHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers for message "+
 			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.BtcDecode", str)
+		return messageTypeError("MsgHeaders.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Create a contiguous slice of headers to deserialize into in order to
@@ -85,7 +85,7 @@ func (msg *MsgHeaders) BtcEncode(w io.Writer, pver uint32) error {
 	if count > MaxBlockHeadersPerMsg {
 		str := fmt.Sprintf("too many block headers for message "+
 			"[count %v, max %v]", count, MaxBlockHeadersPerMsg)
-		return messageError("MsgHeaders.BtcEncode", str)
+		return messageTypeError("MsgHeaders.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
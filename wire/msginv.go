@@ -36,7 +36,7 @@ func (msg *MsgInv) AddInvVect(iv *InvVect) error {
 	if len(msg.InvList)+1 > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [max %v]",
 			MaxInvPerMsg)
-		return messageError("MsgInv.AddInvVect", str)
+		return messageTypeError("MsgInv.AddInvVect", MessageErrorInvalidCount, str)
 	}
 
 	msg.InvList = append(msg.InvList, iv)
@@ -54,7 +54,7 @@ func (msg *MsgInv) BtcDecode(r io.Reader, pver uint32) error {
 	// Limit to max inventory vectors per message.
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgInv.BtcDecode", str)
+		return messageTypeError("MsgInv.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Create a contiguous slice of inventory vectors to deserialize into in
@@ -80,7 +80,7 @@ func (msg *MsgInv) BtcEncode(w io.Writer, pver uint32) error {
 	count := len(msg.InvList)
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgInv.BtcEncode", str)
+		return messageTypeError("MsgInv.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
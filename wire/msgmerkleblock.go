@@ -33,7 +33,7 @@ func (msg *MsgMerkleBlock) AddTxHash(hash *bitcoin.Hash32) error {
 	if len(msg.Hashes)+1 > maxTxPerBlock {
 		str := fmt.Sprintf("too many tx hashes for message [max %v]",
 			maxTxPerBlock)
-		return messageError("MsgMerkleBlock.AddTxHash", str)
+		return messageTypeError("MsgMerkleBlock.AddTxHash", MessageErrorInvalidCount, str)
 	}
 
 	msg.Hashes = append(msg.Hashes, hash)
@@ -67,15 +67,19 @@ func (msg *MsgMerkleBlock) BtcDecode(r io.Reader, pver uint32) error {
 	if count > maxTxPerBlock {
 		str := fmt.Sprintf("too many transaction hashes for message "+
 			"[count %v, max %v]", count, maxTxPerBlock)
-		return messageError("MsgMerkleBlock.BtcDecode", str)
+		return messageTypeError("MsgMerkleBlock.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Create a contiguous slice of hashes to deserialize into in order to
 	// reduce the number of allocations.
-	hashes := make([]bitcoin.Hash32, count)
-	msg.Hashes = make([]*bitcoin.Hash32, 0, count)
+	hashes := make([]bitcoin.Hash32, preallocCount(count))
+	msg.Hashes = make([]*bitcoin.Hash32, 0, preallocCount(count))
 	for i := uint64(0); i < count; i++ {
-		hash := &hashes[i]
+		if len(hashes) == 0 {
+			hashes = make([]bitcoin.Hash32, preallocCount(count-i))
+		}
+		hash := &hashes[0]
+		hashes = hashes[1:]
 		err := readElement(r, hash)
 		if err != nil {
 			return err
@@ -102,13 +106,13 @@ func (msg *MsgMerkleBlock) BtcEncode(w io.Writer, pver uint32) error {
 	if numHashes > maxTxPerBlock {
 		str := fmt.Sprintf("too many transaction hashes for message "+
 			"[count %v, max %v]", numHashes, maxTxPerBlock)
-		return messageError("MsgMerkleBlock.BtcDecode", str)
+		return messageTypeError("MsgMerkleBlock.BtcDecode", MessageErrorInvalidCount, str)
 	}
 	numFlagBytes := len(msg.Flags)
 	if numFlagBytes > maxFlagsPerMerkleBlock {
 		str := fmt.Sprintf("too many flag bytes for message [count %v, "+
 			"max %v]", numFlagBytes, maxFlagsPerMerkleBlock)
-		return messageError("MsgMerkleBlock.BtcDecode", str)
+		return messageTypeError("MsgMerkleBlock.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	err := writeBlockHeader(w, pver, &msg.Header)
@@ -25,7 +25,7 @@ func (msg *MsgNotFound) AddInvVect(iv *InvVect) error {
 	if len(msg.InvList)+1 > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [max %v]",
 			MaxInvPerMsg)
-		return messageError("MsgNotFound.AddInvVect", str)
+		return messageTypeError("MsgNotFound.AddInvVect", MessageErrorInvalidCount, str)
 	}
 
 	msg.InvList = append(msg.InvList, iv)
@@ -43,7 +43,7 @@ func (msg *MsgNotFound) BtcDecode(r io.Reader, pver uint32) error {
 	// Limit to max inventory vectors per message.
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgNotFound.BtcDecode", str)
+		return messageTypeError("MsgNotFound.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Create a contiguous slice of inventory vectors to deserialize into in
@@ -69,7 +69,7 @@ func (msg *MsgNotFound) BtcEncode(w io.Writer, pver uint32) error {
 	count := len(msg.InvList)
 	if count > MaxInvPerMsg {
 		str := fmt.Sprintf("too many invvect in message [%v]", count)
-		return messageError("MsgNotFound.BtcEncode", str)
+		return messageTypeError("MsgNotFound.BtcEncode", MessageErrorInvalidCount, str)
 	}
 
 	err := WriteVarInt(w, pver, uint64(count))
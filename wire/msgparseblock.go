@@ -88,7 +88,7 @@ func (mpb *MsgParseBlock) BtcDecode(r io.Reader, pver uint32) error {
 	if mpb.TxCount > maxTxPerBlock {
 		str := fmt.Sprintf("too many transactions to fit into a block "+
 			"[count %d, max %d]", mpb.TxCount, maxTxPerBlock)
-		return messageError("MsgBlock.BtcDecode", str)
+		return messageTypeError("MsgBlock.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Write all data read from this point on to the raw data for the block transactions so it can
@@ -98,7 +98,7 @@ func (mpb *MsgParseBlock) BtcDecode(r io.Reader, pver uint32) error {
 
 	// Read the raw data for each tx and calculate it's hash so the merkle root hash can be
 	//   calculated.
-	txSizes := make([]uint64, 0, mpb.TxCount)
+	txSizes := make([]uint64, 0, preallocCount(mpb.TxCount))
 	for i := uint64(0); i < mpb.TxCount; i++ {
 		txSize, err := readTxSize(r, pver) // Reads full tx data and returns the size
 		if err != nil {
@@ -111,7 +111,7 @@ func (mpb *MsgParseBlock) BtcDecode(r io.Reader, pver uint32) error {
 
 	// Calculate merkle root hash
 	offset := uint64(0)
-	txids := make([]*bitcoin.Hash32, 0, mpb.TxCount)
+	txids := make([]*bitcoin.Hash32, 0, len(txSizes))
 	for _, txSize := range txSizes {
 		hash := sha256.Sum256(mpb.data[offset : offset+txSize])
 		txid := bitcoin.Hash32(sha256.Sum256(hash[:]))
@@ -157,16 +157,28 @@ func (mpb *MsgParseBlock) MaxPayloadLength(pver uint32) uint64 {
 
 // *************************************************************************************************
 
+// ReadMessageParse is the same as ReadMessageN except that it returns a MsgParseBlock for
+// CmdBlock.
 func ReadMessageParse(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message, []byte, error) {
+	n, command, msg, payload, err := readMessageParse(r, pver, btcnet)
+	if metrics := GetMessageMetrics(); metrics != nil {
+		metrics.recordReceive(command, uint64(n), err)
+	}
+	return n, msg, payload, err
+}
+
+func readMessageParse(r io.Reader, pver uint32,
+	btcnet BitcoinNet) (int, string, Message, []byte, error) {
+
 	totalBytes := 0
 	n, hdr, err := readMessageHeader(r)
 	totalBytes += n
 	if err != nil {
 		if err == io.EOF {
-			return totalBytes, nil, nil, messageTypeError("ReadMessage",
+			return totalBytes, "", nil, nil, messageTypeError("ReadMessage",
 				MessageErrorConnectionClosed, err.Error())
 		}
-		return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorUndefined,
+		return totalBytes, "", nil, nil, messageTypeError("ReadMessage", MessageErrorUndefined,
 			err.Error())
 	}
 
@@ -174,7 +186,8 @@ func ReadMessageParse(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message
 	if hdr.magic != btcnet {
 		discardInput(r, hdr.length)
 		str := fmt.Sprintf("[%v]", hdr.magic)
-		return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorWrongNetwork, str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorWrongNetwork, str)
 	}
 
 	// Enforce maximum message payload.
@@ -182,7 +195,8 @@ func ReadMessageParse(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message
 		str := fmt.Sprintf("message payload is too large - header "+
 			"indicates %d bytes, but max message payload is %d "+
 			"bytes.", hdr.length, MaxMessagePayload)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorTooLarge, str)
 
 	}
 
@@ -190,8 +204,8 @@ func ReadMessageParse(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message
 	command := hdr.command
 	if !utf8.ValidString(command) {
 		discardInput(r, hdr.length)
-		return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorUnknownCommand,
-			command)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorUnknownCommand, command)
 	}
 
 	var msg Message
@@ -202,8 +216,8 @@ func ReadMessageParse(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message
 		msg, err = makeEmptyMessage(command)
 		if err != nil {
 			discardInput(r, hdr.length)
-			return totalBytes, nil, nil, messageTypeError("ReadMessage", MessageErrorUnknownCommand,
-				command)
+			return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+				MessageErrorUnknownCommand, command)
 		}
 	}
 
@@ -216,32 +230,33 @@ func ReadMessageParse(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message
 		str := fmt.Sprintf("payload exceeds max length - header "+
 			"indicates %v bytes, but max payload size for "+
 			"messages of type [%v] is %v.", hdr.length, command, mpl)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorTooLarge, str)
 	}
 
 	// Read payload.
-	payload := make([]byte, hdr.length)
-	n, err = io.ReadFull(r, payload)
-	totalBytes += n
+	payload, err := readBytes(r, uint64(hdr.length))
 	if err != nil {
-		return totalBytes, nil, nil, err
+		return totalBytes, hdr.command, nil, nil, err
 	}
+	totalBytes += len(payload)
 
 	// Test checksum.
 	checksum := bitcoin.DoubleSha256(payload)[0:4]
 	if !bytes.Equal(checksum, hdr.checksum[:]) {
 		str := fmt.Sprintf("payload checksum failed - header "+
 			"indicates %v, but actual checksum is %v.", hdr.checksum, checksum)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+		return totalBytes, hdr.command, nil, nil, messageTypeError("ReadMessage",
+			MessageErrorInvalidChecksum, str)
 	}
 
 	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
 	// MsgVersion BtcDecode function requires it.
 	if err := msg.BtcDecode(bytes.NewBuffer(payload), pver); err != nil {
-		return totalBytes, nil, nil, err
+		return totalBytes, hdr.command, nil, nil, err
 	}
 
-	return totalBytes, msg, payload, nil
+	return totalBytes, command, msg, payload, nil
 }
 
 // readTxId reads the data for a full tx and returns the double SHA256 hash of it. It must take an
@@ -289,7 +304,7 @@ func readTxSize(r io.Reader, pver uint32) (uint64, error) {
 		str := fmt.Sprintf("too many input transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxInPerMessage)
-		return 0, messageError("readTxSize", str)
+		return 0, messageTypeError("readTxSize", MessageErrorInvalidCount, str)
 	}
 
 	// Inputs
@@ -315,7 +330,7 @@ func readTxSize(r io.Reader, pver uint32) (uint64, error) {
 		str := fmt.Sprintf("too many output transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxOutPerMessage)
-		return 0, messageError("readTxSize", str)
+		return 0, messageTypeError("readTxSize", MessageErrorInvalidCount, str)
 	}
 
 	// Outputs
@@ -423,7 +438,7 @@ func ReadTxBytes(b []byte, pver uint32) (uint64, *MsgTx, error) {
 	offset += countSize
 
 	// Inputs
-	result.TxIn = make([]*TxIn, 0, count)
+	result.TxIn = make([]*TxIn, 0, preallocCount(count))
 	for i := uint64(0); i < count; i++ {
 		inputSize, input, err := ReadInputBytes(b[offset:], pver)
 		if err != nil {
@@ -442,7 +457,7 @@ func ReadTxBytes(b []byte, pver uint32) (uint64, *MsgTx, error) {
 	offset += countSize
 
 	// Outputs
-	result.TxOut = make([]*TxOut, 0, count)
+	result.TxOut = make([]*TxOut, 0, preallocCount(count))
 	for i := uint64(0); i < count; i++ {
 		outputSize, output, err := ReadOutputBytes(b[offset:], pver)
 		if err != nil {
@@ -9,8 +9,20 @@ import (
 
 const (
 	ProtoconfNumberOfFields = 2 // used as version
+
+	// LegacyMaxReceivePayloadLength is the max payload length assumed for peers that don't send
+	// a protoconf message.
+	LegacyMaxReceivePayloadLength = uint32(1024 * 1024)
+
+	// MaxReceivePayloadLength is the largest max payload length that can be advertised in a
+	// protoconf message such that the inv messages a peer sends can still be decoded.
+	MaxReceivePayloadLength = uint32(MaxVarIntPayload + MaxInvPerMsg*maxInvVectPayload)
 )
 
+// MsgProtoconf implements the Message interface and represents a bitcoin protoconf message. It
+// is sent after the verack to tell the peer the largest message payload that it will accept.
+//
+// This message was not added until protocol versions starting with ProtoconfVersion.
 type MsgProtoconf struct {
 	NumberOfFields          uint64
 	MaxReceivePayloadLength uint32
@@ -77,12 +89,79 @@ func (msg *MsgProtoconf) MaxPayloadLength(pver uint32) uint64 {
 	return MaxMessagePayload
 }
 
-// NewMsgProtoconf returns a new bitcoin headers message that conforms to the
-// Message interface.  See MsgHeaders for details.
+// Limits returns the protocol limits specified by the protoconf message.
+func (msg *MsgProtoconf) Limits() ProtocolLimits {
+	return NewProtocolLimits(msg.MaxReceivePayloadLength)
+}
+
+// NewMsgProtoconf returns a new bitcoin protoconf message that conforms to the
+// Message interface.  See MsgProtoconf for details.
 func NewMsgProtoconf() *MsgProtoconf {
 	return &MsgProtoconf{
 		NumberOfFields:          uint64(ProtoconfNumberOfFields),
-		MaxReceivePayloadLength: 1048576,
+		MaxReceivePayloadLength: LegacyMaxReceivePayloadLength,
 		StreamPolicies:          "Default",
 	}
 }
+
+// ProtocolLimits are the limits a peer places on the messages it receives.
+type ProtocolLimits struct {
+	MaxReceivePayloadLength uint32
+	MaxInvElements          int
+}
+
+// NewProtocolLimits returns the protocol limits for a max receive payload length. Lengths below
+// the legacy length are not valid, so the legacy length is used instead.
+func NewProtocolLimits(maxReceivePayloadLength uint32) ProtocolLimits {
+	if maxReceivePayloadLength < LegacyMaxReceivePayloadLength {
+		maxReceivePayloadLength = LegacyMaxReceivePayloadLength
+	}
+
+	return ProtocolLimits{
+		MaxReceivePayloadLength: maxReceivePayloadLength,
+		MaxInvElements:          MaxInvElements(maxReceivePayloadLength),
+	}
+}
+
+// LegacyProtocolLimits returns the protocol limits for a peer that hasn't sent a protoconf
+// message.
+func LegacyProtocolLimits() ProtocolLimits {
+	return NewProtocolLimits(LegacyMaxReceivePayloadLength)
+}
+
+// MaxInvElements returns the number of inventory vectors that fit in an inv message with the
+// max payload length. It is never more than MaxInvPerMsg.
+func MaxInvElements(maxPayloadLength uint32) int {
+	if uint64(maxPayloadLength) <= MaxVarIntPayload {
+		return 0
+	}
+
+	result := (uint64(maxPayloadLength) - MaxVarIntPayload) / maxInvVectPayload
+	if result > MaxInvPerMsg {
+		return MaxInvPerMsg
+	}
+	return int(result)
+}
+
+// SplitInvVects splits inventory vectors into inv messages that don't contain more than max
+// vectors each.
+func SplitInvVects(invs []*InvVect, max int) []*MsgInv {
+	if max <= 0 || max > MaxInvPerMsg {
+		max = MaxInvPerMsg
+	}
+
+	var result []*MsgInv
+	for len(invs) > 0 {
+		count := len(invs)
+		if count > max {
+			count = max
+		}
+
+		msg := NewMsgInvSizeHint(uint(count))
+		msg.InvList = append(msg.InvList, invs[:count]...)
+		result = append(result, msg)
+		invs = invs[count:]
+	}
+
+	return result
+}
@@ -0,0 +1,75 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestProtoconf(t *testing.T) {
+	msg := NewMsgProtoconf()
+	msg.MaxReceivePayloadLength = 2 * 1024 * 1024
+
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, msg, ProtocolVersion, MainNet); err != nil {
+		t.Fatalf("Failed to write protoconf : %s", err)
+	}
+
+	read, _, err := ReadMessage(&buf, ProtocolVersion, MainNet)
+	if err != nil {
+		t.Fatalf("Failed to read protoconf : %s", err)
+	}
+
+	if !reflect.DeepEqual(read, msg) {
+		t.Fatalf("Wrong protoconf : \ngot  %+v\nwant %+v", read, msg)
+	}
+
+	limits := msg.Limits()
+	if limits.MaxInvElements != MaxInvPerMsg {
+		t.Fatalf("Wrong max inv elements : got %d, want %d", limits.MaxInvElements,
+			MaxInvPerMsg)
+	}
+
+	legacy := LegacyProtocolLimits()
+	if legacy.MaxInvElements != 29126 {
+		t.Fatalf("Wrong legacy max inv elements : got %d, want %d", legacy.MaxInvElements, 29126)
+	}
+
+	// Below legacy isn't valid.
+	if NewProtocolLimits(1000) != legacy {
+		t.Fatalf("Limits below legacy should use legacy")
+	}
+}
+
+func TestSplitInvVects(t *testing.T) {
+	var invs []*InvVect
+	for i := 0; i < 70000; i++ {
+		invs = append(invs, &InvVect{Type: InvTypeTx})
+	}
+
+	msgs := SplitInvVects(invs, LegacyProtocolLimits().MaxInvElements)
+	if len(msgs) != 3 {
+		t.Fatalf("Wrong message count : got %d, want %d", len(msgs), 3)
+	}
+
+	total := 0
+	for _, msg := range msgs {
+		if len(msg.InvList) > LegacyProtocolLimits().MaxInvElements {
+			t.Fatalf("Too many invs in message : %d", len(msg.InvList))
+		}
+		total += len(msg.InvList)
+
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+			t.Fatalf("Failed to encode inv : %s", err)
+		}
+
+		if uint32(buf.Len()) > LegacyMaxReceivePayloadLength {
+			t.Fatalf("Inv payload too large : %d", buf.Len())
+		}
+	}
+
+	if total != len(invs) {
+		t.Fatalf("Wrong inv count : got %d, want %d", total, len(invs))
+	}
+}
@@ -6,7 +6,6 @@ package wire
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -341,6 +340,38 @@ func NewTxOut(value uint64, lockingScript bitcoin.Script) *TxOut {
 	}
 }
 
+// NewTxOutPayToAddress returns a new bitcoin transaction output that pays the value to the
+// address.
+func NewTxOutPayToAddress(value uint64, ra bitcoin.RawAddress) (*TxOut, error) {
+	lockingScript, err := ra.LockingScript()
+	if err != nil {
+		return nil, errors.Wrap(err, "locking script")
+	}
+
+	return NewTxOut(value, lockingScript), nil
+}
+
+// NewTxOutData returns a new zero value bitcoin transaction output containing the data pushes in
+// an unspendable OP_FALSE OP_RETURN locking script.
+func NewTxOutData(pushes ...[]byte) (*TxOut, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(bitcoin.OP_FALSE)
+	buf.WriteByte(bitcoin.OP_RETURN)
+	for i, push := range pushes {
+		if err := bitcoin.WritePushDataScript(buf, push); err != nil {
+			return nil, errors.Wrapf(err, "push %d", i)
+		}
+	}
+
+	return NewTxOut(0, buf.Bytes()), nil
+}
+
+// NewTxInFromUTXO returns a new bitcoin transaction input, without an unlocking script, that
+// spends the UTXO.
+func NewTxInFromUTXO(utxo bitcoin.UTXO) *TxIn {
+	return NewTxIn(NewOutPoint(&utxo.Hash, utxo.Index), nil)
+}
+
 // MsgTx implements the Message interface and represents a bitcoin tx message.
 // It is used to deliver transaction information in response to a getdata
 // message (MsgGetData) for a given transaction.
@@ -370,9 +401,7 @@ func (msg *MsgTx) TxHash() *bitcoin.Hash32 {
 	// Ignore the error returns since the only way the encode could fail
 	// is being out of memory or due to nil pointers, both of which would
 	// cause a run-time panic.
-	hasher := sha256.New()
-	_ = msg.Serialize(hasher)
-	result := bitcoin.Hash32(sha256.Sum256(hasher.Sum(nil)))
+	result := msg.txHash()
 	return &result
 }
 
@@ -518,7 +547,7 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32) error {
 		str := fmt.Sprintf("too many input transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxInPerMessage)
-		return messageError("MsgTx.BtcDecode", str)
+		return messageTypeError("MsgTx.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// returnScriptBuffers is a closure that returns any script buffers that
@@ -543,13 +572,20 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32) error {
 
 	// Deserialize the inputs.
 	var totalScriptSize uint64
-	txIns := make([]TxIn, count)
-	msg.TxIn = make([]*TxIn, count)
+	// The count isn't trusted until the inputs are read, so the contiguous
+	// slice is allocated in chunks.
+	txIns := make([]TxIn, preallocCount(count))
+	msg.TxIn = make([]*TxIn, 0, preallocCount(count))
 	for i := uint64(0); i < count; i++ {
+		if len(txIns) == 0 {
+			txIns = make([]TxIn, preallocCount(count-i))
+		}
+
 		// The pointer is set now in case a script buffer is borrowed
 		// and needs to be returned to the pool on error.
-		ti := &txIns[i]
-		msg.TxIn[i] = ti
+		ti := &txIns[0]
+		txIns = txIns[1:]
+		msg.TxIn = append(msg.TxIn, ti)
 		err = readTxIn(r, pver, msg.Version, ti)
 		if err != nil {
 			returnScriptBuffers()
@@ -572,17 +608,22 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32) error {
 		str := fmt.Sprintf("too many output transactions to fit into "+
 			"max message size [count %d, max %d]", count,
 			maxTxOutPerMessage)
-		return messageError("MsgTx.BtcDecode", str)
+		return messageTypeError("MsgTx.BtcDecode", MessageErrorInvalidCount, str)
 	}
 
 	// Deserialize the outputs.
-	txOuts := make([]TxOut, count)
-	msg.TxOut = make([]*TxOut, count)
+	txOuts := make([]TxOut, preallocCount(count))
+	msg.TxOut = make([]*TxOut, 0, preallocCount(count))
 	for i := uint64(0); i < count; i++ {
+		if len(txOuts) == 0 {
+			txOuts = make([]TxOut, preallocCount(count-i))
+		}
+
 		// The pointer is set now in case a script buffer is borrowed
 		// and needs to be returned to the pool on error.
-		to := &txOuts[i]
-		msg.TxOut[i] = to
+		to := &txOuts[0]
+		txOuts = txOuts[1:]
+		msg.TxOut = append(msg.TxOut, to)
 		err = readTxOut(r, pver, msg.Version, to)
 		if err != nil {
 			returnScriptBuffers()
@@ -671,6 +712,18 @@ func (msg *MsgTx) Deserialize(r io.Reader) error {
 // See Serialize for encoding transactions to be stored to disk, such as in a
 // database, as opposed to encoding transactions for the wire.
 func (msg *MsgTx) BtcEncode(w io.Writer, pver uint32) error {
+	// Small txs are encoded into a pooled buffer and written at once.
+	size := msg.SerializeSize()
+	if size <= maxPooledTxBufferSize {
+		_, err := msg.writePooled(w, size)
+		return err
+	}
+
+	return msg.btcEncodeStream(w, pver)
+}
+
+// btcEncodeStream encodes the tx directly to the writer, one field at a time.
+func (msg *MsgTx) btcEncodeStream(w io.Writer, pver uint32) error {
 	err := binary.Write(w, endian, uint32(msg.Version))
 	if err != nil {
 		return err
@@ -768,12 +821,7 @@ func (msg *MsgTx) UnmarshalText(text []byte) error {
 
 // MarshalBinary implements encoding.BinaryMarshaler for binary encoding packages.
 func (msg MsgTx) MarshalBinary() ([]byte, error) {
-	var buf bytes.Buffer
-	if err := msg.Serialize(&buf); err != nil {
-		return nil, errors.Wrap(err, "serialize tx")
-	}
-
-	return buf.Bytes(), nil
+	return msg.appendTo(make([]byte, 0, msg.SerializeSize())), nil
 }
 
 // UnmarshalBinary implements encoding.BinaryUnmarshaler for binary encoding packages.
@@ -877,7 +925,12 @@ func readScript(r io.Reader, pver uint32, maxAllowed uint64, fieldName string) (
 	if count > maxAllowed {
 		str := fmt.Sprintf("%s is larger than the max allowed size "+
 			"[count %d, max %d]", fieldName, count, maxAllowed)
-		return nil, messageError("readScript", str)
+		return nil, messageTypeError("readScript", MessageErrorTooLarge, str)
+	}
+
+	// Large scripts are read without allocating the full size up front.
+	if count > freeListMaxScriptSize {
+		return readBytes(r, count)
 	}
 
 	b := scriptPool.Borrow(count)
@@ -0,0 +1,152 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+// UTXOMempoolHeight is the height reported in a utxos message for outputs of txs that are only
+// in the mempool.
+const UTXOMempoolHeight = 0x7fffffff
+
+// UTXOResult is an unspent output returned in a utxos message.
+type UTXOResult struct {
+	TxVersion uint32
+	Height    uint32 // UTXOMempoolHeight if the tx is not in a block
+	Output    TxOut
+}
+
+// InMempool returns true if the output's tx is not in a block yet.
+func (r UTXOResult) InMempool() bool {
+	return r.Height == UTXOMempoolHeight
+}
+
+// MsgUTXOs implements the Message interface and represents a bitcoin utxos message. It is the
+// response to a getutxos message. See BIP0064.
+//
+// Bitmap contains a bit for each requested outpoint, in request order and least significant bit
+// first, that is set when the output is unspent. Outputs contains an item for each set bit.
+type MsgUTXOs struct {
+	ChainHeight  uint32
+	ChainTipHash bitcoin.Hash32
+	Bitmap       []byte
+	Outputs      []*UTXOResult
+}
+
+// IsUnspent returns true if the bit for the requested outpoint index is set.
+func (msg *MsgUTXOs) IsUnspent(index int) bool {
+	if index < 0 || index/8 >= len(msg.Bitmap) {
+		return false
+	}
+	return msg.Bitmap[index/8]&(1<<uint(index%8)) != 0
+}
+
+// SetUnspent adds the result for the requested outpoint index. Results must be added in request
+// order.
+func (msg *MsgUTXOs) SetUnspent(index int, result *UTXOResult) {
+	for len(msg.Bitmap) <= index/8 {
+		msg.Bitmap = append(msg.Bitmap, 0)
+	}
+	msg.Bitmap[index/8] |= 1 << uint(index%8)
+	msg.Outputs = append(msg.Outputs, result)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgUTXOs) BtcDecode(r io.Reader, pver uint32) error {
+	if err := readElements(r, &msg.ChainHeight, &msg.ChainTipHash); err != nil {
+		return err
+	}
+
+	bitmap, err := ReadVarBytes(r, pver, (MaxGetUTXOsOutPoints+7)/8, "utxos bitmap")
+	if err != nil {
+		return err
+	}
+	msg.Bitmap = bitmap
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	if count > MaxGetUTXOsOutPoints {
+		str := fmt.Sprintf("too many outputs in message [count %d, max %d]", count,
+			MaxGetUTXOsOutPoints)
+		return messageTypeError("MsgUTXOs.BtcDecode", MessageErrorInvalidCount, str)
+	}
+
+	results := make([]UTXOResult, count)
+	msg.Outputs = make([]*UTXOResult, 0, count)
+	for i := range results {
+		result := &results[i]
+		if err := readElements(r, &result.TxVersion, &result.Height); err != nil {
+			return err
+		}
+
+		if err := readTxOut(r, pver, 0, &result.Output); err != nil {
+			return err
+		}
+
+		msg.Outputs = append(msg.Outputs, result)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgUTXOs) BtcEncode(w io.Writer, pver uint32) error {
+	count := len(msg.Outputs)
+	if count > MaxGetUTXOsOutPoints {
+		str := fmt.Sprintf("too many outputs in message [count %d, max %d]", count,
+			MaxGetUTXOsOutPoints)
+		return messageTypeError("MsgUTXOs.BtcEncode", MessageErrorInvalidCount, str)
+	}
+
+	if err := writeElements(w, msg.ChainHeight, &msg.ChainTipHash); err != nil {
+		return err
+	}
+
+	if err := WriteVarBytes(w, pver, msg.Bitmap); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+
+	for _, result := range msg.Outputs {
+		if err := writeElements(w, result.TxVersion, result.Height); err != nil {
+			return err
+		}
+
+		if err := writeTxOut(w, pver, 0, &result.Output); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgUTXOs) Command() string {
+	return CmdUTXOs
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgUTXOs) MaxPayloadLength(pver uint32) uint64 {
+	return MaxBlockPayload
+}
+
+// NewMsgUTXOs returns a new bitcoin utxos message that conforms to the Message interface. See
+// MsgUTXOs for details.
+func NewMsgUTXOs(chainHeight uint32, chainTipHash bitcoin.Hash32) *MsgUTXOs {
+	return &MsgUTXOs{
+		ChainHeight:  chainHeight,
+		ChainTipHash: chainTipHash,
+	}
+}
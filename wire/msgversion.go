@@ -242,7 +242,7 @@ func validateUserAgent(userAgent string) error {
 	if len(userAgent) > MaxUserAgentLen {
 		str := fmt.Sprintf("user agent too long [len %v, max %v]",
 			len(userAgent), MaxUserAgentLen)
-		return messageError("MsgVersion", str)
+		return messageTypeError("MsgVersion", MessageErrorTooLarge, str)
 	}
 	return nil
 }
@@ -266,3 +266,49 @@ func (msg *MsgVersion) AddUserAgent(name string, version string,
 	msg.UserAgent = newUserAgent
 	return nil
 }
+
+// VersionConfig contains the values advertised to remote nodes in version messages.
+type VersionConfig struct {
+	// ProtocolVersion can be higher than the ProtocolVersion constant to advertise newer protocol
+	// versions. Messages are encoded with the lower of the two nodes' versions.
+	ProtocolVersion uint32
+
+	// UserAgentName, UserAgentVersion, and UserAgentComments are added to DefaultUserAgent. An
+	// empty name only uses DefaultUserAgent.
+	UserAgentName     string
+	UserAgentVersion  string
+	UserAgentComments []string
+
+	Services       ServiceFlag
+	StartHeight    int32
+	DisableRelayTx bool
+}
+
+// DefaultVersionConfig returns a version config for the latest protocol version supported by this
+// package without any services.
+func DefaultVersionConfig() VersionConfig {
+	return VersionConfig{
+		ProtocolVersion: ProtocolVersion,
+	}
+}
+
+// NewMsgVersionFromConfig returns a new bitcoin version message with the values from the config.
+func NewMsgVersionFromConfig(me *NetAddress, you *NetAddress, nonce uint64,
+	config VersionConfig) (*MsgVersion, error) {
+
+	msg := NewMsgVersion(me, you, nonce, config.StartHeight)
+	if config.ProtocolVersion != 0 {
+		msg.ProtocolVersion = int32(config.ProtocolVersion)
+	}
+	msg.Services = config.Services
+	msg.DisableRelayTx = config.DisableRelayTx
+
+	if len(config.UserAgentName) > 0 {
+		if err := msg.AddUserAgent(config.UserAgentName, config.UserAgentVersion,
+			config.UserAgentComments...); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
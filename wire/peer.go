@@ -0,0 +1,833 @@
+package wire
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/pkg/threads"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrPeerNotConnected  = errors.New("Peer not connected")
+	ErrPeerStopped       = errors.New("Peer stopped")
+	ErrHandshakeTimeout  = errors.New("Handshake timeout")
+	ErrPingTimeout       = errors.New("Ping timeout")
+	ErrSelfConnection    = errors.New("Connected to self")
+	ErrSendQueueFull     = errors.New("Send queue full")
+	ErrVersionNotAllowed = errors.New("Protocol version not allowed")
+	ErrNotSupported      = errors.New("Not supported by peer")
+)
+
+// PeerConfig contains the parameters used to connect to peers.
+type PeerConfig struct {
+	Network bitcoin.Network
+
+	// NetworkMagic overrides the message start bytes derived from Network, for networks that
+	// aren't registered. Zero uses Network.
+	NetworkMagic BitcoinNet
+
+	// ProtocolVersion is the version advertised to remote nodes. It can be higher than the
+	// ProtocolVersion constant. Messages are encoded with the negotiated version, which is the
+	// lower of this and the remote node's version.
+	ProtocolVersion uint32
+
+	UserAgentName     string
+	UserAgentVersion  string
+	UserAgentComments []string
+	Services          ServiceFlag
+	StartHeight       int32
+	DisableRelayTx    bool
+
+	// MinProtocolVersion is the lowest protocol version a remote peer can use.
+	MinProtocolVersion uint32
+
+	// MaxReceivePayloadLength is advertised to peers in a protoconf message. It can't be more than
+	// MaxReceivePayloadLength.
+	MaxReceivePayloadLength uint32
+
+	// SendHeaders requests that the remote node announce new blocks with headers messages rather
+	// than invs.
+	SendHeaders bool
+
+	// MinFeeRate is sent to the remote node in a feefilter message so that it doesn't relay txs
+	// with a lower fee rate. It is in satoshis per kilobyte. Zero doesn't send a feefilter.
+	MinFeeRate int64
+
+	DialTimeout      time.Duration
+	HandshakeTimeout time.Duration
+	WriteTimeout     time.Duration
+	PingInterval     time.Duration
+	PingTimeout      time.Duration
+
+	// SendQueueSize is the number of messages that can be waiting to be sent.
+	SendQueueSize int
+
+	// RelayPolicy decides which txs are announced to the peer and which items announced by the
+	// peer are requested. Nil announces and requests everything.
+	RelayPolicy RelayPolicy
+}
+
+// DefaultPeerConfig returns a peer config with reasonable defaults for the network.
+func DefaultPeerConfig(net bitcoin.Network) PeerConfig {
+	return PeerConfig{
+		Network:                 net,
+		ProtocolVersion:         ProtocolVersion,
+		UserAgentName:           "tokenized",
+		UserAgentVersion:        "0.1.0",
+		MinProtocolVersion:      70015,
+		MaxReceivePayloadLength: MaxReceivePayloadLength,
+		SendHeaders:             true,
+		DialTimeout:             10 * time.Second,
+		HandshakeTimeout:        30 * time.Second,
+		WriteTimeout:            30 * time.Second,
+		PingInterval:            2 * time.Minute,
+		PingTimeout:             30 * time.Second,
+		SendQueueSize:           100,
+	}
+}
+
+// VersionConfig returns the values advertised in the version message.
+func (c PeerConfig) VersionConfig() VersionConfig {
+	return VersionConfig{
+		ProtocolVersion:   c.ProtocolVersion,
+		UserAgentName:     c.UserAgentName,
+		UserAgentVersion:  c.UserAgentVersion,
+		UserAgentComments: c.UserAgentComments,
+		Services:          c.Services,
+		StartHeight:       c.StartHeight,
+		DisableRelayTx:    c.DisableRelayTx,
+	}
+}
+
+// Magic returns the message start bytes of the network.
+func (c PeerConfig) Magic() BitcoinNet {
+	if c.NetworkMagic != 0 {
+		return c.NetworkMagic
+	}
+	return BitcoinNet(c.Network)
+}
+
+// MessageHandler is called for each message received from a peer with a registered command.
+// Returning an error disconnects the peer.
+type MessageHandler func(ctx context.Context, peer *Peer, msg Message) error
+
+// Peer is a connection to a bitcoin node using the P2P protocol. It performs the version
+//   handshake, responds to pings, keeps the connection alive with its own pings, and routes
+//   received messages to registered handlers.
+//
+//   peer := NewPeer("127.0.0.1:8333", DefaultPeerConfig(bitcoin.MainNet))
+//   peer.RegisterHandler(CmdInv, handleInv)
+//   if err := peer.Connect(ctx); err != nil { ... }
+//   err := peer.Run(ctx, interrupt) // returns when interrupt is closed or peer disconnects
+type Peer struct {
+	address  string
+	config   PeerConfig
+	inbound  bool
+	handlers map[string][]MessageHandler
+
+	conn            net.Conn
+	nonce           uint64
+	protocolVersion uint32 // negotiated version, accessed atomically
+	remoteVersion   *MsgVersion
+	remoteLimits    ProtocolLimits
+	connectedAt     time.Time
+
+	remoteSendHeaders bool  // remote node wants block announcements as headers
+	remoteMinFeeRate  int64 // remote node doesn't want txs below this fee rate
+
+	outgoing chan Message
+	done     chan interface{}
+	isDone   bool
+
+	pingNonce    uint64
+	pingSent     time.Time
+	pingDuration time.Duration
+	lastReceive  time.Time
+
+	pendingGetData map[InvVect][]*getDataRequest // items requested with SendGetData
+
+	sync.Mutex
+}
+
+// NewPeer creates an outgoing peer that will connect to the address.
+func NewPeer(address string, config PeerConfig) *Peer {
+	return &Peer{
+		address:  address,
+		config:   config,
+		handlers: make(map[string][]MessageHandler),
+		done:     make(chan interface{}),
+
+		protocolVersion: config.ProtocolVersion,
+		remoteLimits:    LegacyProtocolLimits(),
+	}
+}
+
+// NewInboundPeer creates a peer from a connection that was accepted from a remote node.
+func NewInboundPeer(conn net.Conn, config PeerConfig) *Peer {
+	return &Peer{
+		address:  conn.RemoteAddr().String(),
+		config:   config,
+		inbound:  true,
+		handlers: make(map[string][]MessageHandler),
+		conn:     conn,
+		done:     make(chan interface{}),
+
+		protocolVersion: config.ProtocolVersion,
+		remoteLimits:    LegacyProtocolLimits(),
+	}
+}
+
+// Address returns the address of the remote node.
+func (p *Peer) Address() string {
+	return p.address
+}
+
+// IsInbound returns true if the connection was initiated by the remote node.
+func (p *Peer) IsInbound() bool {
+	return p.inbound
+}
+
+// RemoteVersion returns the version message received from the remote node during the handshake.
+func (p *Peer) RemoteVersion() *MsgVersion {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.remoteVersion
+}
+
+// ProtocolVersion returns the protocol version used to encode messages. It is the configured
+//   version until the handshake, then the lower of the configured and remote versions.
+func (p *Peer) ProtocolVersion() uint32 {
+	return atomic.LoadUint32(&p.protocolVersion)
+}
+
+// Limits returns the limits the remote node places on the messages it receives. They are the
+//   legacy limits until a protoconf message is received.
+func (p *Peer) Limits() ProtocolLimits {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.remoteLimits
+}
+
+// ConnectedAt returns the time the handshake completed.
+func (p *Peer) ConnectedAt() time.Time {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.connectedAt
+}
+
+// PingDuration returns the round trip time of the last ping.
+func (p *Peer) PingDuration() time.Duration {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.pingDuration
+}
+
+// RegisterHandler adds a handler that is called for each received message with the command.
+// Handlers must be registered before Run is called.
+func (p *Peer) RegisterHandler(command string, handler MessageHandler) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.handlers[command] = append(p.handlers[command], handler)
+}
+
+// Connect dials the remote node, if this is not an inbound peer, and performs the version
+//   handshake.
+func (p *Peer) Connect(ctx context.Context) error {
+	p.Lock()
+	conn := p.conn
+	p.Unlock()
+
+	if conn == nil {
+		dialer := net.Dialer{Timeout: p.config.DialTimeout}
+		c, err := dialer.DialContext(ctx, "tcp", p.address)
+		if err != nil {
+			return errors.Wrap(err, "dial")
+		}
+		conn = c
+
+		p.Lock()
+		p.conn = conn
+		p.Unlock()
+	}
+
+	if err := p.handshake(ctx); err != nil {
+		conn.Close()
+		return errors.Wrap(err, "handshake")
+	}
+
+	if err := p.sendProtoconf(); err != nil {
+		conn.Close()
+		return errors.Wrap(err, "send protoconf")
+	}
+
+	if err := p.sendPreferences(); err != nil {
+		conn.Close()
+		return errors.Wrap(err, "send preferences")
+	}
+
+	p.Lock()
+	p.outgoing = make(chan Message, p.config.SendQueueSize)
+	p.connectedAt = time.Now()
+	p.lastReceive = p.connectedAt
+	p.Unlock()
+
+	return nil
+}
+
+// handshake exchanges version and verack messages with the remote node.
+func (p *Peer) handshake(ctx context.Context) error {
+	if p.config.HandshakeTimeout != 0 {
+		p.conn.SetDeadline(time.Now().Add(p.config.HandshakeTimeout))
+		defer p.conn.SetDeadline(time.Time{})
+	}
+
+	nonce, err := randomNonce()
+	if err != nil {
+		return errors.Wrap(err, "nonce")
+	}
+	p.nonce = nonce
+
+	if !p.inbound {
+		if err := p.sendVersion(); err != nil {
+			return errors.Wrap(err, "send version")
+		}
+	}
+
+	versionReceived := false
+	verackReceived := false
+	for !versionReceived || !verackReceived {
+		msg, err := p.readMessage()
+		if err != nil {
+			if isTimeout(err) {
+				return ErrHandshakeTimeout
+			}
+			return err
+		}
+		if msg == nil {
+			continue // unsupported message
+		}
+
+		switch m := msg.(type) {
+		case *MsgVersion:
+			if versionReceived {
+				return errors.New("Duplicate version message")
+			}
+			if m.Nonce == p.nonce {
+				return ErrSelfConnection
+			}
+			if uint32(m.ProtocolVersion) < p.config.MinProtocolVersion {
+				return errors.Wrapf(ErrVersionNotAllowed, "%d", m.ProtocolVersion)
+			}
+
+			p.Lock()
+			p.remoteVersion = m
+			p.Unlock()
+			versionReceived = true
+
+			if uint32(m.ProtocolVersion) < p.config.ProtocolVersion {
+				atomic.StoreUint32(&p.protocolVersion, uint32(m.ProtocolVersion))
+			}
+
+			if p.inbound {
+				if err := p.sendVersion(); err != nil {
+					return errors.Wrap(err, "send version")
+				}
+			}
+
+			if err := p.writeMessage(NewMsgVerAck()); err != nil {
+				return errors.Wrap(err, "send verack")
+			}
+
+		case *MsgVerAck:
+			if !p.inbound && !versionReceived {
+				return errors.New("Verack before version")
+			}
+			verackReceived = true
+
+		case *MsgProtoconf:
+			p.setProtoconf(m)
+
+		case *MsgSendHeaders, *MsgFeeFilter:
+			p.setPreference(m)
+
+		default:
+			logger.VerboseWithFields(ctx, []logger.Field{
+				logger.String("peer", p.address),
+				logger.String("command", msg.Command()),
+			}, "Ignoring message during handshake")
+		}
+	}
+
+	return nil
+}
+
+// sendProtoconf tells the remote node the largest message payload that will be accepted, if
+//   both nodes support protoconf.
+func (p *Peer) sendProtoconf() error {
+	if p.ProtocolVersion() < ProtoconfVersion {
+		return nil
+	}
+
+	maxReceivePayloadLength := p.config.MaxReceivePayloadLength
+	if maxReceivePayloadLength == 0 || maxReceivePayloadLength > MaxReceivePayloadLength {
+		maxReceivePayloadLength = MaxReceivePayloadLength
+	}
+
+	msg := NewMsgProtoconf()
+	msg.MaxReceivePayloadLength = maxReceivePayloadLength
+	return p.writeMessage(msg)
+}
+
+func (p *Peer) setProtoconf(msg *MsgProtoconf) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.remoteLimits = msg.Limits()
+}
+
+// sendPreferences tells the remote node how to announce blocks and which txs to relay, if it
+//   supports the messages.
+func (p *Peer) sendPreferences() error {
+	version := p.ProtocolVersion()
+
+	if p.config.SendHeaders && version >= SendHeadersVersion {
+		if err := p.writeMessage(NewMsgSendHeaders()); err != nil {
+			return errors.Wrap(err, "sendheaders")
+		}
+	}
+
+	if p.config.MinFeeRate > 0 && version >= FeeFilterVersion {
+		if err := p.writeMessage(NewMsgFeeFilter(p.config.MinFeeRate)); err != nil {
+			return errors.Wrap(err, "feefilter")
+		}
+	}
+
+	return nil
+}
+
+func (p *Peer) setPreference(msg Message) {
+	p.Lock()
+	defer p.Unlock()
+
+	switch m := msg.(type) {
+	case *MsgSendHeaders:
+		p.remoteSendHeaders = true
+	case *MsgFeeFilter:
+		p.remoteMinFeeRate = m.MinFee
+	}
+}
+
+// RemoteSendHeaders returns true if the remote node requested that new blocks be announced with
+//   headers messages rather than invs.
+func (p *Peer) RemoteSendHeaders() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.remoteSendHeaders
+}
+
+// RemoteMinFeeRate returns the fee rate, in satoshis per kilobyte, below which the remote node
+//   doesn't want txs relayed.
+func (p *Peer) RemoteMinFeeRate() int64 {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.remoteMinFeeRate
+}
+
+// ShouldRelayTx returns true if a tx with the fee rate, in satoshis per kilobyte, meets the
+//   remote node's fee filter.
+func (p *Peer) ShouldRelayTx(feeRate int64) bool {
+	return feeRate >= p.RemoteMinFeeRate()
+}
+
+func (p *Peer) sendVersion() error {
+	me := NewNetAddressIPPort(net.IPv4zero, 0, p.config.Services)
+
+	you := NewNetAddressIPPort(net.IPv4zero, 0, 0)
+	if host, port, err := net.SplitHostPort(p.address); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			you.IP = ip
+		}
+		if portValue, err := strconv.ParseUint(port, 10, 16); err == nil {
+			you.Port = uint16(portValue)
+		}
+	}
+
+	msg, err := NewMsgVersionFromConfig(me, you, p.nonce, p.config.VersionConfig())
+	if err != nil {
+		return errors.Wrap(err, "version")
+	}
+
+	return p.writeMessage(msg)
+}
+
+// Run processes messages with the peer until the interrupt is closed, Stop is called, or the
+//   connection fails. Connect must be called first. The signature matches
+//   threads.ThreadInterruptFunction so a peer can be run with threads.NewThread.
+func (p *Peer) Run(ctx context.Context, interrupt <-chan interface{}) error {
+	p.Lock()
+	conn := p.conn
+	outgoing := p.outgoing
+	p.Unlock()
+
+	if conn == nil || outgoing == nil {
+		return ErrPeerNotConnected
+	}
+
+	ctx = logger.ContextWithLogFields(ctx, logger.String("peer", p.address))
+
+	var wait sync.WaitGroup
+	errs := make(chan error, 3)
+
+	wait.Add(3)
+	go func() {
+		errs <- p.runReceive(ctx)
+		wait.Done()
+	}()
+	go func() {
+		errs <- p.runSend(ctx, outgoing)
+		wait.Done()
+	}()
+	go func() {
+		errs <- p.runPing(ctx)
+		wait.Done()
+	}()
+
+	var err error
+	select {
+	case <-interrupt:
+		err = threads.Interrupted
+	case <-p.done:
+		err = ErrPeerStopped
+	case err = <-errs:
+	}
+
+	p.close()
+	conn.Close()
+	wait.Wait()
+	p.failGetData(ErrPeerStopped)
+
+	if err == threads.Interrupted || err == ErrPeerStopped {
+		return nil
+	}
+	return err
+}
+
+// Stop disconnects the peer and causes Run to return.
+func (p *Peer) Stop(ctx context.Context) {
+	p.close()
+}
+
+func (p *Peer) close() {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.isDone {
+		close(p.done)
+		p.isDone = true
+	}
+}
+
+// Send queues a message to be sent to the peer.
+func (p *Peer) Send(msg Message) error {
+	p.Lock()
+	outgoing := p.outgoing
+	isDone := p.isDone
+	p.Unlock()
+
+	if outgoing == nil {
+		return ErrPeerNotConnected
+	}
+	if isDone {
+		return ErrPeerStopped
+	}
+
+	select {
+	case outgoing <- msg:
+		return nil
+	case <-p.done:
+		return ErrPeerStopped
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// SendInv queues inv messages for the inventory vectors, split so that no message is larger
+//   than the remote node's limits.
+func (p *Peer) SendInv(invs []*InvVect) error {
+	for _, msg := range SplitInvVects(invs, p.Limits().MaxInvElements) {
+		if err := p.Send(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AnnounceTxs sends inv messages for the txs that the relay policy allows to be announced to the
+//   peer. Nothing is announced if the remote node asked not to have txs relayed in its version
+//   message. It returns the number of txs announced.
+func (p *Peer) AnnounceTxs(txs []*MsgTx) (int, error) {
+	if remoteVersion := p.RemoteVersion(); remoteVersion != nil && remoteVersion.DisableRelayTx {
+		return 0, nil
+	}
+
+	policy := p.config.relayPolicy()
+	var invs []*InvVect
+	for _, tx := range txs {
+		if policy.ShouldAnnounce(p, tx) {
+			invs = append(invs, NewInvVect(InvTypeTx, tx.TxHash()))
+		}
+	}
+
+	if len(invs) == 0 {
+		return 0, nil
+	}
+
+	if err := p.SendInv(invs); err != nil {
+		return 0, err
+	}
+
+	return len(invs), nil
+}
+
+// RequestableInvs returns the inventory vectors announced by the peer that the relay policy
+//   allows to be requested.
+func (p *Peer) RequestableInvs(invs []*InvVect) []*InvVect {
+	policy := p.config.relayPolicy()
+	result := make([]*InvVect, 0, len(invs))
+	for _, inv := range invs {
+		if policy.ShouldRequest(p, inv) {
+			result = append(result, inv)
+		}
+	}
+	return result
+}
+
+// RequestMemPool sends a mempool message so the remote node announces the txs in its mempool. The
+//   announcements are received as inv messages. It returns ErrNotSupported if the negotiated
+//   protocol version doesn't support the mempool message.
+func (p *Peer) RequestMemPool() error {
+	if p.ProtocolVersion() < BIP0035Version {
+		return errors.Wrapf(ErrNotSupported, "mempool requires protocol version %d",
+			BIP0035Version)
+	}
+
+	return p.Send(NewMsgMemPool())
+}
+
+// LoadBloomFilter sends a filterload message so the remote node only relays txs that match the
+//   filter.
+func (p *Peer) LoadBloomFilter(filter *BloomFilter) error {
+	msg := filter.MsgFilterLoad()
+	if msg == nil {
+		return p.ClearBloomFilter()
+	}
+
+	return p.Send(msg)
+}
+
+// AddToBloomFilter sends a filteradd message to add data to the filter loaded on the remote node.
+func (p *Peer) AddToBloomFilter(data []byte) error {
+	return p.Send(NewMsgFilterAdd(data))
+}
+
+// ClearBloomFilter sends a filterclear message to remove the filter loaded on the remote node.
+func (p *Peer) ClearBloomFilter() error {
+	return p.Send(NewMsgFilterClear())
+}
+
+// RequestMerkleBlocks sends getdata messages requesting merkleblocks for the block hashes. The
+//   remote node responds with a merkleblock message followed by the matching txs for each block.
+func (p *Peer) RequestMerkleBlocks(hashes []bitcoin.Hash32) error {
+	invs := make([]*InvVect, len(hashes))
+	for i := range hashes {
+		invs[i] = NewInvVect(InvTypeFilteredBlock, &hashes[i])
+	}
+
+	for _, inv := range SplitInvVects(invs, p.Limits().MaxInvElements) {
+		msg := NewMsgGetDataSizeHint(uint(len(inv.InvList)))
+		msg.InvList = inv.InvList
+		if err := p.Send(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Peer) runReceive(ctx context.Context) error {
+	for {
+		msg, err := p.readMessage()
+		if err != nil {
+			select {
+			case <-p.done:
+				return nil // connection closed by stop
+			default:
+				return errors.Wrap(err, "read")
+			}
+		}
+
+		p.Lock()
+		p.lastReceive = time.Now()
+		p.Unlock()
+
+		if msg == nil {
+			continue // unsupported message
+		}
+
+		switch m := msg.(type) {
+		case *MsgPing:
+			if err := p.Send(NewMsgPong(m.Nonce)); err != nil {
+				return errors.Wrap(err, "send pong")
+			}
+		case *MsgProtoconf:
+			p.setProtoconf(m)
+		case *MsgSendHeaders, *MsgFeeFilter:
+			p.setPreference(m)
+		case *MsgTx, *MsgBlock, *MsgMerkleBlock, *MsgNotFound:
+			p.resolveGetData(m)
+		case *MsgPong:
+			p.Lock()
+			if p.pingNonce != 0 && m.Nonce == p.pingNonce {
+				p.pingDuration = time.Since(p.pingSent)
+				p.pingNonce = 0
+			}
+			p.Unlock()
+		}
+
+		p.Lock()
+		handlers := p.handlers[msg.Command()]
+		p.Unlock()
+
+		for _, handler := range handlers {
+			if err := handler(ctx, p, msg); err != nil {
+				return errors.Wrapf(err, "handle %s", msg.Command())
+			}
+		}
+	}
+}
+
+func (p *Peer) runSend(ctx context.Context, outgoing <-chan Message) error {
+	for {
+		select {
+		case <-p.done:
+			return nil
+		case msg := <-outgoing:
+			if err := p.writeMessage(msg); err != nil {
+				return errors.Wrapf(err, "write %s", msg.Command())
+			}
+		}
+	}
+}
+
+func (p *Peer) runPing(ctx context.Context) error {
+	if p.config.PingInterval == 0 {
+		<-p.done
+		return nil
+	}
+
+	ticker := time.NewTicker(p.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return nil
+		case <-ticker.C:
+			p.Lock()
+			outstanding := p.pingNonce != 0
+			sent := p.pingSent
+			p.Unlock()
+
+			if outstanding {
+				if p.config.PingTimeout != 0 && time.Since(sent) > p.config.PingTimeout {
+					return ErrPingTimeout
+				}
+				continue
+			}
+
+			nonce, err := randomNonce()
+			if err != nil {
+				return errors.Wrap(err, "nonce")
+			}
+
+			p.Lock()
+			p.pingNonce = nonce
+			p.pingSent = time.Now()
+			p.Unlock()
+
+			if err := p.Send(NewMsgPing(nonce)); err != nil {
+				return errors.Wrap(err, "send ping")
+			}
+		}
+	}
+}
+
+// readMessage reads the next message from the connection. A nil message with no error is
+//   returned for messages with commands that are not supported.
+func (p *Peer) readMessage() (Message, error) {
+	_, msg, _, err := ReadMessageN(p.conn, p.ProtocolVersion(), p.config.Magic())
+	if err != nil {
+		if msgErr, ok := errors.Cause(err).(*MessageError); ok &&
+			msgErr.Type == MessageErrorUnknownCommand {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (p *Peer) writeMessage(msg Message) error {
+	if p.config.WriteTimeout != 0 {
+		p.conn.SetWriteDeadline(time.Now().Add(p.config.WriteTimeout))
+	}
+
+	return WriteMessage(p.conn, msg, p.ProtocolVersion(), p.config.Magic())
+}
+
+// String returns the address and direction of the peer.
+func (p *Peer) String() string {
+	if p.inbound {
+		return fmt.Sprintf("%s (inbound)", p.address)
+	}
+	return fmt.Sprintf("%s (outbound)", p.address)
+}
+
+func randomNonce() (uint64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func isTimeout(err error) bool {
+	if netErr, ok := errors.Cause(err).(net.Error); ok {
+		return netErr.Timeout()
+	}
+	if msgErr, ok := errors.Cause(err).(*MessageError); ok {
+		return msgErr.Type == MessageErrorConnectionClosed &&
+			strings.Contains(msgErr.Description, "timeout")
+	}
+	return false
+}
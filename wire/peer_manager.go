@@ -0,0 +1,240 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tokenized/pkg/logger"
+	"github.com/tokenized/pkg/storage"
+	"github.com/tokenized/pkg/threads"
+)
+
+// PeerManager maintains connections to multiple peers. Known peer addresses are kept in an
+//   AddrManager, which is persisted to storage, and peers that disconnect are replaced with dial
+//   candidates from it.
+type PeerManager struct {
+	config   PeerConfig
+	maxPeers int
+
+	addresses *AddrManager
+	peers     map[string]*Peer
+	handlers  map[string][]MessageHandler
+
+	sync.Mutex
+}
+
+// NewPeerManager creates a peer manager that maintains up to maxPeers connections.
+func NewPeerManager(config PeerConfig, store storage.Storage, maxPeers int) *PeerManager {
+	return &PeerManager{
+		config:    config,
+		maxPeers:  maxPeers,
+		addresses: NewAddrManager(store),
+		peers:     make(map[string]*Peer),
+		handlers:  make(map[string][]MessageHandler),
+	}
+}
+
+// Load reads the known addresses from storage. Missing data is not an error.
+func (m *PeerManager) Load(ctx context.Context) error {
+	return m.addresses.Load(ctx)
+}
+
+// Save writes the known addresses to storage.
+func (m *PeerManager) Save(ctx context.Context) error {
+	return m.addresses.Save(ctx)
+}
+
+// AddAddress adds an address from a local source like a seed or configuration. It returns false
+//   if the address wasn't added because it is stale or its bucket is full of better addresses.
+func (m *PeerManager) AddAddress(address string, seen time.Time) bool {
+	return m.addresses.AddAddress(address, 0, seen, "")
+}
+
+// AddrManager returns the address manager that holds the known peer addresses.
+func (m *PeerManager) AddrManager() *AddrManager {
+	return m.addresses
+}
+
+// RegisterHandler adds a handler that is called for messages with the command received from any
+//   peer. Handlers must be registered before Run is called.
+func (m *PeerManager) RegisterHandler(command string, handler MessageHandler) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.handlers[command] = append(m.handlers[command], handler)
+}
+
+// Peers returns the currently connected peers.
+func (m *PeerManager) Peers() []*Peer {
+	m.Lock()
+	defer m.Unlock()
+
+	result := make([]*Peer, 0, len(m.peers))
+	for _, peer := range m.peers {
+		if peer == nil {
+			continue // still connecting
+		}
+		result = append(result, peer)
+	}
+	return result
+}
+
+// Broadcast sends a message to all connected peers. It returns the number of peers the message
+//   was queued for.
+func (m *PeerManager) Broadcast(msg Message) int {
+	count := 0
+	for _, peer := range m.Peers() {
+		if err := peer.Send(msg); err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// AnnounceTx announces the tx to the connected peers that the relay policy allows. It returns the
+//   number of peers it was announced to.
+func (m *PeerManager) AnnounceTx(tx *MsgTx) int {
+	count := 0
+	for _, peer := range m.Peers() {
+		if announced, err := peer.AnnounceTxs([]*MsgTx{tx}); err == nil && announced > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// RequestMemPool sends a mempool message to the connected peers that support it so they
+//   announce the txs in their mempools. It returns the number of peers it was sent to.
+func (m *PeerManager) RequestMemPool() int {
+	count := 0
+	for _, peer := range m.Peers() {
+		if err := peer.RequestMemPool(); err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Run connects to peers from the address book until the interrupt is closed. Disconnected peers
+//   are replaced with new connections. The address book is saved when it stops.
+func (m *PeerManager) Run(ctx context.Context, interrupt <-chan interface{}) error {
+	var wait sync.WaitGroup
+	peerInterrupt := make(chan interface{})
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		m.connectPeers(ctx, peerInterrupt, &wait)
+
+		select {
+		case <-interrupt:
+			close(peerInterrupt)
+			wait.Wait()
+			return m.Save(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// connectPeers starts connections to addresses until there are maxPeers connections.
+func (m *PeerManager) connectPeers(ctx context.Context, interrupt <-chan interface{},
+	wait *sync.WaitGroup) {
+
+	for _, address := range m.nextAddresses() {
+		wait.Add(1)
+		go func(address string) {
+			m.runPeer(ctx, address, interrupt)
+			wait.Done()
+		}(address)
+	}
+}
+
+// nextAddresses returns the addresses to connect to and marks them as attempted. They are the
+//   address manager's best dial candidates from network groups without a connection.
+func (m *PeerManager) nextAddresses() []string {
+	m.Lock()
+	defer m.Unlock()
+
+	needed := m.maxPeers - len(m.peers)
+	if needed <= 0 {
+		return nil
+	}
+
+	exclude := make([]string, 0, len(m.peers))
+	for address := range m.peers {
+		exclude = append(exclude, address)
+	}
+
+	now := time.Now()
+	result := m.addresses.DialCandidates(needed, exclude)
+	for _, address := range result {
+		m.addresses.MarkAttempt(address, now)
+		m.peers[address] = nil // reserve slot while connecting
+	}
+
+	return result
+}
+
+func (m *PeerManager) runPeer(ctx context.Context, address string,
+	interrupt <-chan interface{}) {
+
+	ctx = logger.ContextWithLogFields(ctx, logger.String("peer", address))
+
+	peer := NewPeer(address, m.config)
+
+	m.Lock()
+	for command, handlers := range m.handlers {
+		for _, handler := range handlers {
+			peer.RegisterHandler(command, handler)
+		}
+	}
+	m.Unlock()
+
+	peer.RegisterHandler(CmdAddr, m.addresses.HandleAddr)
+
+	if err := peer.Connect(ctx); err != nil {
+		logger.Warn(ctx, "Failed to connect to peer : %s", err)
+		m.removePeer(address, false)
+		return
+	}
+
+	m.Lock()
+	m.peers[address] = peer
+	m.Unlock()
+
+	logger.Info(ctx, "Connected to peer")
+
+	// Request more addresses for the address book.
+	peer.Send(NewMsgGetAddr())
+
+	thread := threads.NewThread(fmt.Sprintf("Peer %s", address), peer.Run)
+	complete := thread.GetCompleteChannel()
+	thread.Start(ctx)
+
+	select {
+	case <-interrupt:
+		thread.Stop(ctx)
+		<-complete
+	case <-complete:
+	}
+
+	logger.Info(ctx, "Disconnected from peer")
+
+	m.removePeer(address, true)
+}
+
+// removePeer removes the peer from the active set and updates the address manager.
+func (m *PeerManager) removePeer(address string, connected bool) {
+	m.Lock()
+	delete(m.peers, address)
+	m.Unlock()
+
+	if connected {
+		m.addresses.MarkSuccess(address, time.Now())
+	} else {
+		m.addresses.MarkFailure(address)
+	}
+}
@@ -0,0 +1,433 @@
+package wire
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+func TestPeerHandshake(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultPeerConfig(bitcoin.MainNet)
+	config.HandshakeTimeout = 5 * time.Second
+	config.MinFeeRate = 500
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen : %s", err)
+	}
+	defer listener.Close()
+
+	inboundErr := make(chan error, 1)
+	var inbound *Peer
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			inboundErr <- err
+			return
+		}
+
+		inbound = NewInboundPeer(conn, config)
+		inboundErr <- inbound.Connect(ctx)
+	}()
+
+	outbound := NewPeer(listener.Addr().String(), config)
+
+	pongs := make(chan uint64, 1)
+	outbound.RegisterHandler(CmdPong, func(ctx context.Context, peer *Peer, msg Message) error {
+		pongs <- msg.(*MsgPong).Nonce
+		return nil
+	})
+
+	if err := outbound.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect outbound peer : %s", err)
+	}
+
+	if err := <-inboundErr; err != nil {
+		t.Fatalf("Failed to connect inbound peer : %s", err)
+	}
+
+	if outbound.RemoteVersion() == nil || inbound.RemoteVersion() == nil {
+		t.Fatalf("Missing remote version")
+	}
+
+	if outbound.RemoteVersion().UserAgent != inbound.RemoteVersion().UserAgent {
+		t.Fatalf("Wrong user agent : got %s, want %s", outbound.RemoteVersion().UserAgent,
+			inbound.RemoteVersion().UserAgent)
+	}
+
+	interrupt := make(chan interface{})
+	outboundComplete := make(chan error, 1)
+	inboundComplete := make(chan error, 1)
+	go func() {
+		outboundComplete <- outbound.Run(ctx, interrupt)
+	}()
+	go func() {
+		inboundComplete <- inbound.Run(ctx, interrupt)
+	}()
+
+	if err := outbound.Send(NewMsgPing(1234)); err != nil {
+		t.Fatalf("Failed to send ping : %s", err)
+	}
+
+	select {
+	case nonce := <-pongs:
+		if nonce != 1234 {
+			t.Fatalf("Wrong pong nonce : got %d, want %d", nonce, 1234)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Pong not received")
+	}
+
+	// The protoconf from the inbound peer is received before the pong.
+	if outbound.Limits().MaxReceivePayloadLength != MaxReceivePayloadLength {
+		t.Fatalf("Wrong remote max receive payload length : got %d, want %d",
+			outbound.Limits().MaxReceivePayloadLength, MaxReceivePayloadLength)
+	}
+
+	// The sendheaders and feefilter from the inbound peer are also received before the pong.
+	if !outbound.RemoteSendHeaders() {
+		t.Fatalf("Remote send headers not received")
+	}
+
+	if outbound.RemoteMinFeeRate() != 500 {
+		t.Fatalf("Wrong remote min fee rate : got %d, want %d", outbound.RemoteMinFeeRate(), 500)
+	}
+
+	if outbound.ShouldRelayTx(499) || !outbound.ShouldRelayTx(500) {
+		t.Fatalf("Wrong fee filter relay result")
+	}
+
+	close(interrupt)
+
+	if err := <-outboundComplete; err != nil {
+		t.Fatalf("Outbound peer failed : %s", err)
+	}
+
+	// The inbound peer might see the connection closed before the interrupt.
+	<-inboundComplete
+}
+
+func TestPeerVersionNegotiation(t *testing.T) {
+	ctx := context.Background()
+
+	inboundConfig := DefaultPeerConfig(bitcoin.MainNet)
+	inboundConfig.HandshakeTimeout = 5 * time.Second
+	inboundConfig.NetworkMagic = BitcoinNet(0x0a0b0c0d)
+
+	outboundConfig := inboundConfig
+	outboundConfig.ProtocolVersion = ProtocolVersion + 10
+	outboundConfig.UserAgentComments = []string{"test"}
+	outboundConfig.Services = SFNodeNetwork
+	outboundConfig.DisableRelayTx = true
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen : %s", err)
+	}
+	defer listener.Close()
+
+	inboundErr := make(chan error, 1)
+	var inbound *Peer
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			inboundErr <- err
+			return
+		}
+
+		inbound = NewInboundPeer(conn, inboundConfig)
+		inboundErr <- inbound.Connect(ctx)
+	}()
+
+	outbound := NewPeer(listener.Addr().String(), outboundConfig)
+	if outbound.ProtocolVersion() != ProtocolVersion+10 {
+		t.Fatalf("Wrong initial protocol version : got %d, want %d", outbound.ProtocolVersion(),
+			ProtocolVersion+10)
+	}
+
+	if err := outbound.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect outbound peer : %s", err)
+	}
+	defer outbound.Stop(ctx)
+
+	if err := <-inboundErr; err != nil {
+		t.Fatalf("Failed to connect inbound peer : %s", err)
+	}
+	defer inbound.Stop(ctx)
+
+	if outbound.ProtocolVersion() != ProtocolVersion {
+		t.Fatalf("Wrong outbound protocol version : got %d, want %d", outbound.ProtocolVersion(),
+			ProtocolVersion)
+	}
+	if inbound.ProtocolVersion() != ProtocolVersion {
+		t.Fatalf("Wrong inbound protocol version : got %d, want %d", inbound.ProtocolVersion(),
+			ProtocolVersion)
+	}
+
+	remote := inbound.RemoteVersion()
+	if uint32(remote.ProtocolVersion) != ProtocolVersion+10 {
+		t.Fatalf("Wrong remote protocol version : got %d, want %d", remote.ProtocolVersion,
+			ProtocolVersion+10)
+	}
+	if !remote.HasService(SFNodeNetwork) {
+		t.Fatalf("Missing remote service")
+	}
+	if !remote.DisableRelayTx {
+		t.Fatalf("Remote relay tx should be disabled")
+	}
+
+	wantUserAgent := DefaultUserAgent + "tokenized:0.1.0(test)/"
+	if remote.UserAgent != wantUserAgent {
+		t.Fatalf("Wrong remote user agent : got %s, want %s", remote.UserAgent, wantUserAgent)
+	}
+}
+
+type testRelayPolicy struct {
+	announce bitcoin.Hash32
+	request  bitcoin.Hash32
+}
+
+func (p *testRelayPolicy) ShouldAnnounce(peer *Peer, tx *MsgTx) bool {
+	return tx.TxHash().Equal(&p.announce)
+}
+
+func (p *testRelayPolicy) ShouldRequest(peer *Peer, inv *InvVect) bool {
+	return inv.Hash.Equal(&p.request)
+}
+
+func TestPeerRelayPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	var txs []*MsgTx
+	for i := 0; i < 3; i++ {
+		tx := NewMsgTx(1)
+		tx.AddTxIn(NewTxIn(NewOutPoint(&bitcoin.Hash32{byte(i)}, 0), nil))
+		tx.AddTxOut(NewTxOut(1000, bitcoin.Script{bitcoin.OP_TRUE}))
+		txs = append(txs, tx)
+	}
+
+	policy := &testRelayPolicy{
+		announce: *txs[0].TxHash(),
+		request:  *txs[1].TxHash(),
+	}
+
+	inboundConfig := DefaultPeerConfig(bitcoin.MainNet)
+	inboundConfig.HandshakeTimeout = 5 * time.Second
+
+	outboundConfig := inboundConfig
+	outboundConfig.RelayPolicy = policy
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen : %s", err)
+	}
+	defer listener.Close()
+
+	inboundInvs := make(chan []*InvVect, 1)
+	inboundErr := make(chan error, 1)
+	var inbound *Peer
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			inboundErr <- err
+			return
+		}
+
+		inbound = NewInboundPeer(conn, inboundConfig)
+		inbound.RegisterHandler(CmdInv, func(ctx context.Context, peer *Peer, msg Message) error {
+			inboundInvs <- msg.(*MsgInv).InvList
+			return nil
+		})
+
+		// Respond to mempool requests by announcing all txs.
+		inbound.RegisterHandler(CmdMemPool, func(ctx context.Context, peer *Peer,
+			msg Message) error {
+			_, err := peer.AnnounceTxs(txs)
+			return err
+		})
+
+		inboundErr <- inbound.Connect(ctx)
+	}()
+
+	outbound := NewPeer(listener.Addr().String(), outboundConfig)
+
+	requestable := make(chan []*InvVect, 1)
+	outbound.RegisterHandler(CmdInv, func(ctx context.Context, peer *Peer, msg Message) error {
+		requestable <- peer.RequestableInvs(msg.(*MsgInv).InvList)
+		return nil
+	})
+
+	if err := outbound.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect outbound peer : %s", err)
+	}
+
+	if err := <-inboundErr; err != nil {
+		t.Fatalf("Failed to connect inbound peer : %s", err)
+	}
+
+	interrupt := make(chan interface{})
+	defer close(interrupt)
+	go outbound.Run(ctx, interrupt)
+	go inbound.Run(ctx, interrupt)
+
+	announced, err := outbound.AnnounceTxs(txs)
+	if err != nil {
+		t.Fatalf("Failed to announce txs : %s", err)
+	}
+	if announced != 1 {
+		t.Fatalf("Wrong announced count : got %d, want %d", announced, 1)
+	}
+
+	select {
+	case invs := <-inboundInvs:
+		if len(invs) != 1 || !invs[0].Hash.Equal(txs[0].TxHash()) {
+			t.Fatalf("Wrong announced invs : %v", invs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Inv not received")
+	}
+
+	if err := outbound.RequestMemPool(); err != nil {
+		t.Fatalf("Failed to request mempool : %s", err)
+	}
+
+	select {
+	case invs := <-requestable:
+		if len(invs) != 1 || !invs[0].Hash.Equal(txs[1].TxHash()) {
+			t.Fatalf("Wrong requestable invs : %v", invs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Mempool inv not received")
+	}
+}
+
+func TestPeerSendGetData(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultPeerConfig(bitcoin.MainNet)
+	config.HandshakeTimeout = 5 * time.Second
+
+	var txs []*MsgTx
+	for i := 0; i < 3; i++ {
+		tx := NewMsgTx(1)
+		tx.AddTxIn(NewTxIn(NewOutPoint(&bitcoin.Hash32{byte(i)}, 0), nil))
+		tx.AddTxOut(NewTxOut(1000, bitcoin.Script{bitcoin.OP_TRUE}))
+		txs = append(txs, tx)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen : %s", err)
+	}
+	defer listener.Close()
+
+	inboundErr := make(chan error, 1)
+	var inbound *Peer
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			inboundErr <- err
+			return
+		}
+
+		// Responds with the first tx, notfound for the second, and nothing for the third.
+		inbound = NewInboundPeer(conn, config)
+		inbound.RegisterHandler(CmdGetData, func(ctx context.Context, peer *Peer,
+			msg Message) error {
+
+			notFound := NewMsgNotFound()
+			for _, inv := range msg.(*MsgGetData).InvList {
+				if inv.Hash.Equal(txs[0].TxHash()) {
+					if err := peer.Send(txs[0]); err != nil {
+						return err
+					}
+				} else if inv.Hash.Equal(txs[1].TxHash()) {
+					notFound.AddInvVect(inv)
+				}
+			}
+
+			if len(notFound.InvList) > 0 {
+				return peer.Send(notFound)
+			}
+			return nil
+		})
+
+		inboundErr <- inbound.Connect(ctx)
+	}()
+
+	outbound := NewPeer(listener.Addr().String(), config)
+
+	if err := outbound.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect outbound peer : %s", err)
+	}
+
+	if err := <-inboundErr; err != nil {
+		t.Fatalf("Failed to connect inbound peer : %s", err)
+	}
+
+	interrupt := make(chan interface{})
+	outboundComplete := make(chan error, 1)
+	go func() {
+		outboundComplete <- outbound.Run(ctx, interrupt)
+	}()
+	go inbound.Run(ctx, interrupt)
+
+	invs := make([]*InvVect, len(txs))
+	for i, tx := range txs {
+		invs[i] = NewInvVect(InvTypeTx, tx.TxHash())
+	}
+
+	results, err := outbound.SendGetData(invs, 500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to send getdata : %s", err)
+	}
+
+	received := make(map[bitcoin.Hash32]*GetDataResult)
+	for i := 0; i < len(txs); i++ {
+		select {
+		case result := <-results:
+			received[result.Inv.Hash] = result
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Getdata result %d not received", i)
+		}
+	}
+
+	if result := received[*txs[0].TxHash()]; result == nil || result.Err != nil ||
+		!result.Message.(*MsgTx).TxHash().Equal(txs[0].TxHash()) {
+		t.Fatalf("Wrong tx result : %+v", result)
+	}
+	if result := received[*txs[1].TxHash()]; result == nil || result.Err != ErrItemNotFound {
+		t.Fatalf("Wrong not found result : %+v", result)
+	}
+	if result := received[*txs[2].TxHash()]; result == nil || result.Err != ErrRequestTimeout {
+		t.Fatalf("Wrong timeout result : %+v", result)
+	}
+
+	if count := outbound.PendingGetDataCount(); count != 0 {
+		t.Fatalf("Wrong pending count : got %d, want 0", count)
+	}
+
+	// Pending requests are resolved when the peer stops.
+	results, err = outbound.SendGetData(invs[2:], 0)
+	if err != nil {
+		t.Fatalf("Failed to send getdata : %s", err)
+	}
+
+	close(interrupt)
+
+	select {
+	case result := <-results:
+		if result.Err != ErrPeerStopped {
+			t.Fatalf("Wrong stopped result : got %v, want %s", result.Err, ErrPeerStopped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Getdata not resolved when peer stopped")
+	}
+
+	if err := <-outboundComplete; err != nil {
+		t.Fatalf("Outbound peer failed : %s", err)
+	}
+}
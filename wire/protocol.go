@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/tokenized/pkg/bitcoin"
 )
 
 const (
@@ -50,6 +52,18 @@ const (
 	// FeeFilterVersion is the protocol version which added a new
 	// feefilter message.
 	FeeFilterVersion uint32 = 70013
+
+	// CompactBlocksVersion is the protocol version which added the
+	// compact block messages from BIP0152.
+	CompactBlocksVersion uint32 = 70014
+
+	// ProtoconfVersion is the protocol version which added the protoconf
+	// message.
+	ProtoconfVersion uint32 = 70016
+
+	// ExtendedMessageVersion is the protocol version which added the
+	// extmsg header for messages larger than 4GB.
+	ExtendedMessageVersion uint32 = 70016
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -149,5 +163,15 @@ func (n BitcoinNet) String() string {
 		return s
 	}
 
+	if params, ok := bitcoin.LookupNetwork(bitcoin.Network(n)); ok {
+		return params.Name
+	}
+
 	return fmt.Sprintf("Unknown BitcoinNet (%d)", uint32(n))
 }
+
+// DefaultPort returns the default P2P port of a network registered with bitcoin.RegisterNetwork,
+// or zero if the network isn't registered.
+func (n BitcoinNet) DefaultPort() uint16 {
+	return bitcoin.Network(n).DefaultPort()
+}
@@ -0,0 +1,35 @@
+package wire
+
+// RelayPolicy decides which txs are announced to peers and which announced items are requested
+//   from them, so services can implement custom relay behavior, for example only relaying txs
+//   that contain Tokenized actions. It is set in PeerConfig and used by Peer.AnnounceTxs,
+//   Peer.RequestableInvs, and the PeerManager.
+type RelayPolicy interface {
+	// ShouldAnnounce returns true if the tx should be announced to the peer.
+	ShouldAnnounce(peer *Peer, tx *MsgTx) bool
+
+	// ShouldRequest returns true if the item announced by the peer should be requested from it.
+	ShouldRequest(peer *Peer, inv *InvVect) bool
+}
+
+// RelayAll is a RelayPolicy that announces all txs and requests all announced items. It is used
+//   when PeerConfig doesn't specify a relay policy.
+type RelayAll struct{}
+
+// ShouldAnnounce returns true.
+func (RelayAll) ShouldAnnounce(peer *Peer, tx *MsgTx) bool {
+	return true
+}
+
+// ShouldRequest returns true.
+func (RelayAll) ShouldRequest(peer *Peer, inv *InvVect) bool {
+	return true
+}
+
+// relayPolicy returns the relay policy of the config.
+func (c PeerConfig) relayPolicy() RelayPolicy {
+	if c.RelayPolicy == nil {
+		return RelayAll{}
+	}
+	return c.RelayPolicy
+}
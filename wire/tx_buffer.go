@@ -0,0 +1,130 @@
+package wire
+
+import (
+	"crypto/sha256"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+const (
+	// maxPooledTxBufferSize is the largest serialized tx that is encoded into a pooled buffer.
+	// Larger txs are written directly so they don't have to be held in memory.
+	maxPooledTxBufferSize = 1024 * 1024
+
+	defaultTxBufferSize = 1024
+)
+
+// txBufferPool holds buffers used to serialize txs so that computing hashes and writing txs
+// doesn't allocate.
+var txBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, defaultTxBufferSize)
+		return &b
+	},
+}
+
+// borrowTxBuffer returns an empty buffer from the pool with at least size capacity.
+func borrowTxBuffer(size int) *[]byte {
+	b := txBufferPool.Get().(*[]byte)
+	if cap(*b) < size {
+		*b = make([]byte, 0, size)
+	}
+	*b = (*b)[:0]
+	return b
+}
+
+// returnTxBuffer puts the buffer back in the pool, unless it is too large to keep.
+func returnTxBuffer(b *[]byte) {
+	if cap(*b) > maxPooledTxBufferSize {
+		return
+	}
+	txBufferPool.Put(b)
+}
+
+// WriteTo writes the serialized tx to w with a single write from a pooled buffer. It implements
+// io.WriterTo.
+func (msg *MsgTx) WriteTo(w io.Writer) (int64, error) {
+	size := msg.SerializeSize()
+	if size > maxPooledTxBufferSize {
+		counter := &countWriter{w: w}
+		err := msg.btcEncodeStream(counter, 0)
+		return int64(counter.count), err
+	}
+
+	return msg.writePooled(w, size)
+}
+
+// writePooled serializes the tx into a pooled buffer and writes it to w. size must be the
+// serialized size of the tx.
+func (msg *MsgTx) writePooled(w io.Writer, size int) (int64, error) {
+	b := borrowTxBuffer(size)
+	*b = msg.appendTo(*b)
+	n, err := w.Write(*b)
+	returnTxBuffer(b)
+	return int64(n), err
+}
+
+// txHash calculates the double SHA256 of the serialized tx using a pooled buffer.
+func (msg *MsgTx) txHash() bitcoin.Hash32 {
+	size := msg.SerializeSize()
+	if size > maxPooledTxBufferSize {
+		hasher := sha256.New()
+		_ = msg.btcEncodeStream(hasher, 0)
+		return bitcoin.Hash32(sha256.Sum256(hasher.Sum(nil)))
+	}
+
+	b := borrowTxBuffer(size)
+	*b = msg.appendTo(*b)
+	hash := sha256.Sum256(*b)
+	returnTxBuffer(b)
+	return bitcoin.Hash32(sha256.Sum256(hash[:]))
+}
+
+// appendTo appends the serialized tx to b.
+func (msg *MsgTx) appendTo(b []byte) []byte {
+	b = appendUint32(b, uint32(msg.Version))
+
+	b = appendVarInt(b, uint64(len(msg.TxIn)))
+	for _, ti := range msg.TxIn {
+		b = append(b, ti.PreviousOutPoint.Hash[:]...)
+		b = appendUint32(b, ti.PreviousOutPoint.Index)
+		b = appendVarInt(b, uint64(len(ti.UnlockingScript)))
+		b = append(b, ti.UnlockingScript...)
+		b = appendUint32(b, ti.Sequence)
+	}
+
+	b = appendVarInt(b, uint64(len(msg.TxOut)))
+	for _, to := range msg.TxOut {
+		b = appendUint64(b, to.Value)
+		b = appendVarInt(b, uint64(len(to.LockingScript)))
+		b = append(b, to.LockingScript...)
+	}
+
+	return appendUint32(b, msg.LockTime)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24), byte(v>>32), byte(v>>40),
+		byte(v>>48), byte(v>>56))
+}
+
+// appendVarInt appends the variable length integer encoding of v to b.
+func appendVarInt(b []byte, v uint64) []byte {
+	switch {
+	case v < 0xfd:
+		return append(b, byte(v))
+	case v <= math.MaxUint16:
+		return append(b, 0xfd, byte(v), byte(v>>8))
+	case v <= math.MaxUint32:
+		return appendUint32(append(b, 0xfe), uint32(v))
+	default:
+		return appendUint64(append(b, 0xff), v)
+	}
+}
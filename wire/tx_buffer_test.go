@@ -0,0 +1,109 @@
+package wire
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestTxAppendMatchesStream(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 50; i++ {
+		tx := NewMsgTx(int32(random.Uint32()))
+		for j := random.Intn(300); j > 0; j-- {
+			script := make([]byte, random.Intn(300))
+			random.Read(script)
+			tx.AddTxIn(&TxIn{
+				PreviousOutPoint: OutPoint{Index: random.Uint32()},
+				UnlockingScript:  script,
+				Sequence:         random.Uint32(),
+			})
+		}
+		for j := random.Intn(300); j > 0; j-- {
+			script := make([]byte, random.Intn(3000))
+			random.Read(script)
+			tx.AddTxOut(NewTxOut(random.Uint64(), script))
+		}
+		tx.LockTime = random.Uint32()
+
+		var stream bytes.Buffer
+		if err := tx.btcEncodeStream(&stream, 0); err != nil {
+			t.Fatalf("Failed to encode tx : %s", err)
+		}
+
+		appended := tx.appendTo(nil)
+		if !bytes.Equal(stream.Bytes(), appended) {
+			t.Fatalf("Appended tx doesn't match stream encoding")
+		}
+
+		if tx.SerializeSize() != len(appended) {
+			t.Fatalf("Wrong serialize size : got %d, want %d", tx.SerializeSize(),
+				len(appended))
+		}
+
+		var written bytes.Buffer
+		n, err := tx.WriteTo(&written)
+		if err != nil {
+			t.Fatalf("Failed to write tx : %s", err)
+		}
+		if n != int64(len(appended)) || !bytes.Equal(written.Bytes(), appended) {
+			t.Fatalf("Written tx doesn't match stream encoding")
+		}
+
+		cached := NewCachedTx(tx)
+		if !cached.TxHash().Equal(tx.TxHash()) {
+			t.Fatalf("Wrong cached hash : got %s, want %s", cached.TxHash(), tx.TxHash())
+		}
+		if cached.SerializeSize() != len(appended) {
+			t.Fatalf("Wrong cached size : got %d, want %d", cached.SerializeSize(),
+				len(appended))
+		}
+
+		fromBytes, err := NewCachedTxFromBytes(appended)
+		if err != nil {
+			t.Fatalf("Failed to deserialize cached tx : %s", err)
+		}
+		if !fromBytes.TxHash().Equal(tx.TxHash()) {
+			t.Fatalf("Wrong cached hash from bytes : got %s, want %s", fromBytes.TxHash(),
+				tx.TxHash())
+		}
+
+		// The cached tx must not share memory with the slice it was created from.
+		original := make([]byte, len(appended))
+		copy(original, appended)
+		for j := range appended {
+			appended[j] ^= 0xff
+		}
+		if !bytes.Equal(fromBytes.Bytes(), original) {
+			t.Fatalf("Cached bytes changed when source slice was modified")
+		}
+	}
+}
+
+func TestAppendVarInt(t *testing.T) {
+	values := []uint64{0, 0xfc, 0xfd, 0xffff, 0x10000, 0xffffffff, 0x100000000,
+		0xffffffffffffffff}
+
+	for _, value := range values {
+		var buf bytes.Buffer
+		if err := WriteVarInt(&buf, 0, value); err != nil {
+			t.Fatalf("Failed to write var int : %s", err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), appendVarInt(nil, value)) {
+			t.Fatalf("Wrong var int encoding for %d", value)
+		}
+	}
+}
+
+func TestTxHashAllocations(t *testing.T) {
+	multiTx.TxHash() // prime the buffer pool
+
+	allocs := testing.AllocsPerRun(100, func() {
+		multiTx.TxHash()
+	})
+	if allocs > 1 {
+		t.Fatalf("Too many allocations for tx hash : %f", allocs)
+	}
+}
@@ -0,0 +1,89 @@
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+// CachedTx wraps a tx and caches its serialized bytes, size, and hash so they are only calculated
+//   once. The tx must not be modified after it is wrapped.
+type CachedTx struct {
+	tx *MsgTx
+
+	once  sync.Once
+	bytes []byte
+	hash  bitcoin.Hash32
+}
+
+// NewCachedTx wraps a tx so its serialization is only calculated once.
+func NewCachedTx(tx *MsgTx) *CachedTx {
+	return &CachedTx{tx: tx}
+}
+
+// NewCachedTxFromBytes deserializes a tx and retains a copy of the raw bytes so they don't have to
+//   be recalculated. The caller can reuse b after it returns.
+func NewCachedTxFromBytes(b []byte) (*CachedTx, error) {
+	tx := &MsgTx{}
+	r := bytes.NewReader(b)
+	if err := tx.Deserialize(r); err != nil {
+		return nil, errors.Wrap(err, "deserialize tx")
+	}
+
+	result := &CachedTx{tx: tx}
+	result.once.Do(func() {
+		size := len(b) - r.Len()
+		c := make([]byte, size)
+		copy(c, b[:size])
+		result.setBytes(c)
+	})
+	return result, nil
+}
+
+// Tx returns the wrapped tx.
+func (c *CachedTx) Tx() *MsgTx {
+	return c.tx
+}
+
+// TxHash returns the cached hash of the tx.
+func (c *CachedTx) TxHash() *bitcoin.Hash32 {
+	c.calculate()
+	hash := c.hash
+	return &hash
+}
+
+// SerializeSize returns the cached size of the serialized tx.
+func (c *CachedTx) SerializeSize() int {
+	c.calculate()
+	return len(c.bytes)
+}
+
+// Bytes returns the cached serialized tx. The returned slice must not be modified.
+func (c *CachedTx) Bytes() []byte {
+	c.calculate()
+	return c.bytes
+}
+
+// WriteTo writes the cached serialized tx to w. It implements io.WriterTo.
+func (c *CachedTx) WriteTo(w io.Writer) (int64, error) {
+	c.calculate()
+	n, err := w.Write(c.bytes)
+	return int64(n), err
+}
+
+func (c *CachedTx) calculate() {
+	c.once.Do(func() {
+		c.setBytes(c.tx.appendTo(make([]byte, 0, c.tx.SerializeSize())))
+	})
+}
+
+func (c *CachedTx) setBytes(b []byte) {
+	c.bytes = b
+	hash := sha256.Sum256(b)
+	c.hash = bitcoin.Hash32(sha256.Sum256(hash[:]))
+}
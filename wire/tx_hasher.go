@@ -0,0 +1,83 @@
+package wire
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+const (
+	// minConcurrentHashTxCount is the fewest txs in a block for which tx hashes are calculated
+	// concurrently while the block is deserialized. Hashes of txs in smaller blocks are
+	// calculated as they are decoded.
+	minConcurrentHashTxCount = 64
+
+	// txHasherQueueSize is the number of txs per worker that can wait to be hashed before
+	// deserialization waits for the workers.
+	txHasherQueueSize = 64
+)
+
+// txHasher calculates tx hashes with a pool of workers, one per CPU.
+type txHasher struct {
+	jobs     chan txHashJob
+	finished bool
+	wait     sync.WaitGroup
+}
+
+type txHashJob struct {
+	tx   *MsgTx
+	hash *bitcoin.Hash32
+}
+
+// newTxHasher starts a tx hasher for a block with txCount txs. It returns nil when the block is
+// too small or there is only one CPU, in which case hashes are calculated as txs are added.
+func newTxHasher(txCount uint64) *txHasher {
+	workers := runtime.GOMAXPROCS(0)
+	if txCount < minConcurrentHashTxCount || workers < 2 {
+		return nil
+	}
+
+	result := &txHasher{
+		jobs: make(chan txHashJob, workers*txHasherQueueSize),
+	}
+
+	result.wait.Add(workers)
+	for i := 0; i < workers; i++ {
+		go result.run()
+	}
+
+	return result
+}
+
+// add queues a tx to be hashed and returns the hash that is set before finish returns. The tx
+// must not be modified until finish returns.
+func (h *txHasher) add(tx *MsgTx) *bitcoin.Hash32 {
+	hash := &bitcoin.Hash32{}
+	if h == nil {
+		*hash = tx.txHash()
+		return hash
+	}
+
+	h.jobs <- txHashJob{tx: tx, hash: hash}
+	return hash
+}
+
+// finish waits for all queued txs to be hashed and stops the workers. It can be called more than
+// once.
+func (h *txHasher) finish() {
+	if h == nil || h.finished {
+		return
+	}
+	h.finished = true
+	close(h.jobs)
+	h.wait.Wait()
+}
+
+func (h *txHasher) run() {
+	defer h.wait.Done()
+
+	for job := range h.jobs {
+		*job.hash = job.tx.txHash()
+	}
+}
@@ -0,0 +1,110 @@
+package wire
+
+import (
+	"bytes"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+)
+
+func randomBlock(txCount int) *MsgBlock {
+	block := NewMsgBlock(&BlockHeader{Version: 1})
+	for i := 0; i < txCount; i++ {
+		tx := NewMsgTx(1)
+
+		var hash bitcoin.Hash32
+		rand.Read(hash[:])
+		tx.AddTxIn(NewTxIn(NewOutPoint(&hash, uint32(i)), make([]byte, 107)))
+
+		script := make([]byte, 25)
+		rand.Read(script)
+		tx.AddTxOut(NewTxOut(uint64(i), script))
+		tx.AddTxOut(NewTxOut(uint64(i), script))
+
+		block.AddTransaction(tx)
+	}
+
+	merkleRoot, _ := block.CalculateMerkleHash()
+	block.Header.MerkleRoot = *merkleRoot
+	return block
+}
+
+func TestBlockConcurrentTxHashes(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for _, txCount := range []int{1, 10, 500} {
+		block := randomBlock(txCount)
+
+		var buf bytes.Buffer
+		if err := block.Serialize(&buf); err != nil {
+			t.Fatalf("Failed to serialize block : %s", err)
+		}
+		b := buf.Bytes()
+
+		read := &MsgBlock{}
+		hashes, err := read.DeserializeWithTxHashes(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("Failed to deserialize block : %s", err)
+		}
+
+		if len(hashes) != txCount {
+			t.Fatalf("Wrong hash count : got %d, want %d", len(hashes), txCount)
+		}
+
+		for i, tx := range read.Transactions {
+			if !hashes[i].Equal(tx.TxHash()) {
+				t.Fatalf("Wrong hash for tx %d : got %s, want %s", i, hashes[i], tx.TxHash())
+			}
+		}
+
+		if !read.IsMerkleRootValidWithTxHashes(hashes) {
+			t.Fatalf("Invalid merkle root from hashes")
+		}
+
+		if !read.IsMerkleRootValid() {
+			t.Fatalf("Invalid merkle root")
+		}
+
+		// Modifying a decoded tx changes its hash.
+		tx := read.Transactions[0]
+		tx.LockTime = 100
+		if tx.TxHash().Equal(hashes[0]) {
+			t.Fatalf("Hash not recalculated after modification")
+		}
+
+		if read.IsMerkleRootValid() {
+			t.Fatalf("Merkle root valid after modification")
+		}
+
+		copied := *read.Transactions[txCount-1]
+		copied.TxIn[0].UnlockingScript = []byte{bitcoin.OP_TRUE}
+		if copied.TxHash().Equal(hashes[txCount-1]) {
+			t.Fatalf("Hash not recalculated after modifying copy")
+		}
+	}
+}
+
+func BenchmarkBlockDeserializeMerkleRoot(b *testing.B) {
+	block := randomBlock(10000)
+
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		b.Fatalf("Failed to serialize block : %s", err)
+	}
+	data := buf.Bytes()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		read := &MsgBlock{}
+		hashes, err := read.DeserializeWithTxHashes(bytes.NewReader(data))
+		if err != nil {
+			b.Fatalf("Failed to deserialize block : %s", err)
+		}
+
+		if !read.IsMerkleRootValidWithTxHashes(hashes) {
+			b.Fatalf("Invalid merkle root")
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrScriptTooLarge = errors.New("Script too large")
+	ErrDustOutput     = errors.New("Dust output")
+)
+
+// TxPolicy contains the limits that txs are checked against before they are serialized for
+//   broadcast. Zero values disable a limit.
+type TxPolicy struct {
+	// MaxLockingScriptSize is the largest locking script allowed in an output.
+	MaxLockingScriptSize int
+
+	// MaxUnlockingScriptSize is the largest unlocking script allowed in an input.
+	MaxUnlockingScriptSize int
+
+	// DustLimit is the lowest value allowed in a spendable output. Unspendable outputs, like
+	//   OP_FALSE OP_RETURN data outputs, can have a zero value.
+	DustLimit uint64
+}
+
+// DefaultTxPolicy returns a policy that doesn't limit script sizes and rejects spendable outputs
+//   with no value.
+func DefaultTxPolicy() TxPolicy {
+	return TxPolicy{
+		DustLimit: 1,
+	}
+}
+
+// CheckTxOut returns an error if the output doesn't meet the policy.
+func (p TxPolicy) CheckTxOut(txout *TxOut) error {
+	if p.MaxLockingScriptSize > 0 && len(txout.LockingScript) > p.MaxLockingScriptSize {
+		return errors.Wrapf(ErrScriptTooLarge, "locking script %d bytes, max %d",
+			len(txout.LockingScript), p.MaxLockingScriptSize)
+	}
+
+	if txout.Value < p.DustLimit && !bitcoin.LockingScriptIsUnspendable(txout.LockingScript) {
+		return errors.Wrapf(ErrDustOutput, "value %d, dust limit %d", txout.Value, p.DustLimit)
+	}
+
+	return nil
+}
+
+// CheckTxIn returns an error if the input doesn't meet the policy.
+func (p TxPolicy) CheckTxIn(txin *TxIn) error {
+	if p.MaxUnlockingScriptSize > 0 && len(txin.UnlockingScript) > p.MaxUnlockingScriptSize {
+		return errors.Wrapf(ErrScriptTooLarge, "unlocking script %d bytes, max %d",
+			len(txin.UnlockingScript), p.MaxUnlockingScriptSize)
+	}
+
+	return nil
+}
+
+// CheckTx returns an error naming the index of the first input or output that doesn't meet the
+//   policy.
+func (p TxPolicy) CheckTx(tx *MsgTx) error {
+	for index, txin := range tx.TxIn {
+		if err := p.CheckTxIn(txin); err != nil {
+			return errors.Wrapf(err, "input %d", index)
+		}
+	}
+
+	for index, txout := range tx.TxOut {
+		if err := p.CheckTxOut(txout); err != nil {
+			return errors.Wrapf(err, "output %d", index)
+		}
+	}
+
+	return nil
+}
+
+// SerializeWithPolicy checks the tx against the policy and then serializes it to w.
+func (msg *MsgTx) SerializeWithPolicy(w io.Writer, policy TxPolicy) error {
+	if err := policy.CheckTx(msg); err != nil {
+		return errors.Wrap(err, "policy")
+	}
+
+	return msg.Serialize(w)
+}
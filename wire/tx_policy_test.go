@@ -0,0 +1,80 @@
+package wire
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestTxPolicy(t *testing.T) {
+	pkh := bytes.Repeat([]byte{0x12}, bitcoin.Hash20Size)
+	ra, err := bitcoin.NewRawAddressPKH(pkh)
+	if err != nil {
+		t.Fatalf("Failed to create address : %s", err)
+	}
+
+	payment, err := NewTxOutPayToAddress(1000, ra)
+	if err != nil {
+		t.Fatalf("Failed to create output : %s", err)
+	}
+
+	want := append([]byte{bitcoin.OP_DUP, bitcoin.OP_HASH160, bitcoin.Hash20Size}, pkh...)
+	want = append(want, bitcoin.OP_EQUALVERIFY, bitcoin.OP_CHECKSIG)
+	if !bytes.Equal(payment.LockingScript, want) {
+		t.Fatalf("Wrong locking script : got %x, want %x", payment.LockingScript, want)
+	}
+
+	data, err := NewTxOutData([]byte("test"), []byte{1, 2, 3})
+	if err != nil {
+		t.Fatalf("Failed to create data output : %s", err)
+	}
+	if !bitcoin.LockingScriptIsUnspendable(data.LockingScript) {
+		t.Fatalf("Data output is spendable")
+	}
+
+	tx := NewMsgTx(1)
+	tx.AddTxIn(NewTxInFromUTXO(bitcoin.UTXO{Index: 1}))
+	tx.AddTxOut(payment)
+	tx.AddTxOut(data)
+
+	policy := DefaultTxPolicy()
+	var buf bytes.Buffer
+	if err := tx.SerializeWithPolicy(&buf, policy); err != nil {
+		t.Fatalf("Failed to serialize tx : %s", err)
+	}
+
+	tx.AddTxOut(NewTxOut(0, want))
+	buf.Reset()
+	err = tx.SerializeWithPolicy(&buf, policy)
+	if errors.Cause(err) != ErrDustOutput {
+		t.Fatalf("Wrong dust error : got %v, want %v", err, ErrDustOutput)
+	}
+	if !strings.Contains(err.Error(), "output 2") {
+		t.Fatalf("Error doesn't name output : %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Tx serialized after policy failure")
+	}
+
+	tx.TxOut[2].Value = 1
+	policy.MaxLockingScriptSize = len(want) - 1
+	err = policy.CheckTx(tx)
+	if errors.Cause(err) != ErrScriptTooLarge {
+		t.Fatalf("Wrong script size error : got %v, want %v", err, ErrScriptTooLarge)
+	}
+	if !strings.Contains(err.Error(), "output 0") {
+		t.Fatalf("Error doesn't name output : %s", err)
+	}
+
+	policy.MaxLockingScriptSize = 0
+	policy.MaxUnlockingScriptSize = 1
+	tx.TxIn[0].UnlockingScript = []byte{1, 2}
+	err = policy.CheckTx(tx)
+	if errors.Cause(err) != ErrScriptTooLarge || !strings.Contains(err.Error(), "input 0") {
+		t.Fatalf("Wrong unlocking script error : %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package wire
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUTXOsNotSupported = errors.New("Peer doesn't support getutxos")
+	ErrUTXOsMismatch     = errors.New("UTXOs response doesn't match request")
+)
+
+// UTXOFetcher requests the unspent state of outpoints from peers with getutxos messages and
+//   matches the utxos responses to the requests. Responses don't identify the request, so they
+//   are matched in the order the requests were sent to each peer.
+//
+//   fetcher := NewUTXOFetcher()
+//   peer.RegisterHandler(CmdUTXOs, fetcher.HandleUTXOs)
+//   ...
+//   utxos, msg, err := fetcher.FetchUTXOs(ctx, peer, outpoints, true)
+type UTXOFetcher struct {
+	pending map[*Peer][]chan *MsgUTXOs
+
+	sync.Mutex
+}
+
+// NewUTXOFetcher creates a new UTXO fetcher. Its HandleUTXOs function must be registered as the
+//   handler for CmdUTXOs on any peers used with it.
+func NewUTXOFetcher() *UTXOFetcher {
+	return &UTXOFetcher{
+		pending: make(map[*Peer][]chan *MsgUTXOs),
+	}
+}
+
+// FetchUTXOs sends a getutxos message to the peer and waits for the response. The returned slice
+//   contains an item for each outpoint, in the same order, that is nil if the output is spent or
+//   unknown to the peer. The response message is also returned since it contains the chain tip
+//   the state is from and the heights of the outputs.
+func (f *UTXOFetcher) FetchUTXOs(ctx context.Context, peer *Peer, outpoints []*OutPoint,
+	checkMempool bool) ([]*bitcoin.UTXO, *MsgUTXOs, error) {
+
+	if len(outpoints) > MaxGetUTXOsOutPoints {
+		return nil, nil, errors.Wrapf(ErrUTXOsMismatch, "too many outpoints : %d > %d",
+			len(outpoints), MaxGetUTXOsOutPoints)
+	}
+
+	version := peer.RemoteVersion()
+	if version == nil || !version.HasService(SFNodeGetUTXO) {
+		return nil, nil, ErrUTXOsNotSupported
+	}
+
+	// Buffered so that a response to a cancelled request doesn't block the handler.
+	response := make(chan *MsgUTXOs, 1)
+
+	f.Lock()
+	f.pending[peer] = append(f.pending[peer], response)
+	f.Unlock()
+
+	if err := peer.Send(NewMsgGetUTXOs(checkMempool, outpoints)); err != nil {
+		f.remove(peer, response)
+		return nil, nil, errors.Wrap(err, "send")
+	}
+
+	select {
+	case msg := <-response:
+		utxos, err := msg.UTXOs(outpoints)
+		if err != nil {
+			return nil, nil, err
+		}
+		return utxos, msg, nil
+
+	case <-peer.done:
+		f.remove(peer, response)
+		return nil, nil, ErrPeerStopped
+
+	case <-ctx.Done():
+		// The request stays pending so that a late response is matched to it instead of the next
+		// request.
+		return nil, nil, ctx.Err()
+	}
+}
+
+// HandleUTXOs is a MessageHandler for CmdUTXOs that passes responses to pending requests.
+func (f *UTXOFetcher) HandleUTXOs(ctx context.Context, peer *Peer, msg Message) error {
+	utxosMsg, ok := msg.(*MsgUTXOs)
+	if !ok {
+		return errors.New("Message not utxos")
+	}
+
+	f.Lock()
+	pending := f.pending[peer]
+	if len(pending) == 0 {
+		f.Unlock()
+		return nil // unrequested
+	}
+
+	response := pending[0]
+	if len(pending) == 1 {
+		delete(f.pending, peer)
+	} else {
+		f.pending[peer] = pending[1:]
+	}
+	f.Unlock()
+
+	response <- utxosMsg
+	return nil
+}
+
+func (f *UTXOFetcher) remove(peer *Peer, response chan *MsgUTXOs) {
+	f.Lock()
+	defer f.Unlock()
+
+	pending := f.pending[peer]
+	for i, c := range pending {
+		if c == response {
+			pending = append(pending[:i], pending[i+1:]...)
+			break
+		}
+	}
+
+	if len(pending) == 0 {
+		delete(f.pending, peer)
+	} else {
+		f.pending[peer] = pending
+	}
+}
+
+// UTXOs converts the response into UTXOs for the outpoints that were requested. The returned
+//   slice contains an item for each outpoint, in the same order, that is nil if the output isn't
+//   unspent.
+func (msg *MsgUTXOs) UTXOs(outpoints []*OutPoint) ([]*bitcoin.UTXO, error) {
+	if len(msg.Bitmap) != (len(outpoints)+7)/8 {
+		return nil, errors.Wrapf(ErrUTXOsMismatch, "bitmap size %d for %d outpoints",
+			len(msg.Bitmap), len(outpoints))
+	}
+
+	result := make([]*bitcoin.UTXO, len(outpoints))
+	next := 0
+	for i, outpoint := range outpoints {
+		if !msg.IsUnspent(i) {
+			continue
+		}
+
+		if next >= len(msg.Outputs) {
+			return nil, errors.Wrapf(ErrUTXOsMismatch, "missing output %d", next)
+		}
+		output := msg.Outputs[next]
+		next++
+
+		result[i] = &bitcoin.UTXO{
+			Hash:          outpoint.Hash,
+			Index:         outpoint.Index,
+			Value:         output.Output.Value,
+			LockingScript: output.Output.LockingScript,
+		}
+	}
+
+	if next != len(msg.Outputs) {
+		return nil, errors.Wrapf(ErrUTXOsMismatch, "%d outputs for %d unspent", len(msg.Outputs),
+			next)
+	}
+
+	return result, nil
+}
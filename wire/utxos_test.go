@@ -0,0 +1,274 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tokenized/pkg/bitcoin"
+
+	"github.com/pkg/errors"
+)
+
+func TestGetUTXOsSerialize(t *testing.T) {
+	var hash bitcoin.Hash32
+	hash[0] = 1
+
+	msg := NewMsgGetUTXOs(true, nil)
+	for i := uint32(0); i < 3; i++ {
+		if err := msg.AddOutPoint(NewOutPoint(&hash, i)); err != nil {
+			t.Fatalf("Failed to add outpoint : %s", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("Failed to encode getutxos : %s", err)
+	}
+
+	// Mempool flag + count + 3 outpoints
+	if buf.Len() != 1+1+3*36 {
+		t.Fatalf("Wrong encoded size : got %d, want %d", buf.Len(), 1+1+3*36)
+	}
+
+	read := &MsgGetUTXOs{}
+	if err := read.BtcDecode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("Failed to decode getutxos : %s", err)
+	}
+
+	if !reflect.DeepEqual(msg, read) {
+		t.Fatalf("Wrong decoded getutxos : got %+v, want %+v", read, msg)
+	}
+
+	for i := len(msg.OutPoints); i < MaxGetUTXOsOutPoints; i++ {
+		if err := msg.AddOutPoint(NewOutPoint(&hash, uint32(i))); err != nil {
+			t.Fatalf("Failed to add outpoint : %s", err)
+		}
+	}
+
+	if err := msg.AddOutPoint(NewOutPoint(&hash, 1000)); err == nil {
+		t.Fatalf("Added more than max outpoints")
+	}
+}
+
+func TestUTXOsSerialize(t *testing.T) {
+	var tipHash bitcoin.Hash32
+	tipHash[31] = 2
+
+	msg := NewMsgUTXOs(650000, tipHash)
+	msg.SetUnspent(1, &UTXOResult{
+		TxVersion: 1,
+		Height:    649000,
+		Output:    TxOut{Value: 1000, LockingScript: []byte{0x76, 0xa9}},
+	})
+	msg.SetUnspent(9, &UTXOResult{
+		TxVersion: 2,
+		Height:    UTXOMempoolHeight,
+		Output:    TxOut{Value: 2000, LockingScript: []byte{0x6a}},
+	})
+
+	if !bytes.Equal(msg.Bitmap, []byte{0x02, 0x02}) {
+		t.Fatalf("Wrong bitmap : got %x, want %x", msg.Bitmap, []byte{0x02, 0x02})
+	}
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("Failed to encode utxos : %s", err)
+	}
+
+	read := &MsgUTXOs{}
+	if err := read.BtcDecode(&buf, ProtocolVersion); err != nil {
+		t.Fatalf("Failed to decode utxos : %s", err)
+	}
+
+	if !reflect.DeepEqual(msg, read) {
+		t.Fatalf("Wrong decoded utxos : got %+v, want %+v", read, msg)
+	}
+
+	if read.Outputs[0].InMempool() || !read.Outputs[1].InMempool() {
+		t.Fatalf("Wrong mempool status")
+	}
+
+	var hash bitcoin.Hash32
+	hash[0] = 3
+	outpoints := make([]*OutPoint, 10)
+	for i := range outpoints {
+		outpoints[i] = NewOutPoint(&hash, uint32(i))
+	}
+
+	utxos, err := read.UTXOs(outpoints)
+	if err != nil {
+		t.Fatalf("Failed to convert utxos : %s", err)
+	}
+
+	for i, utxo := range utxos {
+		if i != 1 && i != 9 {
+			if utxo != nil {
+				t.Fatalf("UTXO %d should be nil", i)
+			}
+			continue
+		}
+
+		if utxo == nil {
+			t.Fatalf("UTXO %d missing", i)
+		}
+
+		if !utxo.Hash.Equal(&hash) || utxo.Index != uint32(i) {
+			t.Fatalf("Wrong UTXO %d outpoint : %s", i, utxo.ID())
+		}
+	}
+
+	if utxos[9].Value != 2000 {
+		t.Fatalf("Wrong UTXO value : got %d, want %d", utxos[9].Value, 2000)
+	}
+
+	if _, err := read.UTXOs(outpoints[:8]); errors.Cause(err) != ErrUTXOsMismatch {
+		t.Fatalf("Wrong mismatch error : got %v, want %s", err, ErrUTXOsMismatch)
+	}
+}
+
+func TestFetchUTXOs(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultPeerConfig(bitcoin.MainNet)
+	config.HandshakeTimeout = 5 * time.Second
+	config.Services = SFNodeGetUTXO
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen : %s", err)
+	}
+	defer listener.Close()
+
+	var tipHash bitcoin.Hash32
+	tipHash[0] = 5
+
+	inboundErr := make(chan error, 1)
+	var inbound *Peer
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			inboundErr <- err
+			return
+		}
+
+		inbound = NewInboundPeer(conn, config)
+
+		// Respond that every even outpoint is unspent.
+		inbound.RegisterHandler(CmdGetUTXOs,
+			func(ctx context.Context, peer *Peer, msg Message) error {
+				request := msg.(*MsgGetUTXOs)
+				response := NewMsgUTXOs(100, tipHash)
+				response.Bitmap = make([]byte, (len(request.OutPoints)+7)/8)
+				for i, outpoint := range request.OutPoints {
+					if outpoint.Index%2 == 0 {
+						response.SetUnspent(i, &UTXOResult{
+							TxVersion: 1,
+							Height:    90,
+							Output:    TxOut{Value: uint64(outpoint.Index) * 10},
+						})
+					}
+				}
+				return peer.Send(response)
+			})
+
+		inboundErr <- inbound.Connect(ctx)
+	}()
+
+	fetcher := NewUTXOFetcher()
+	outbound := NewPeer(listener.Addr().String(), config)
+	outbound.RegisterHandler(CmdUTXOs, fetcher.HandleUTXOs)
+
+	if err := outbound.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect outbound peer : %s", err)
+	}
+
+	if err := <-inboundErr; err != nil {
+		t.Fatalf("Failed to connect inbound peer : %s", err)
+	}
+
+	interrupt := make(chan interface{})
+	outboundComplete := make(chan error, 1)
+	inboundComplete := make(chan error, 1)
+	go func() {
+		outboundComplete <- outbound.Run(ctx, interrupt)
+	}()
+	go func() {
+		inboundComplete <- inbound.Run(ctx, interrupt)
+	}()
+
+	var hash bitcoin.Hash32
+	hash[0] = 6
+	outpoints := make([]*OutPoint, 5)
+	for i := range outpoints {
+		outpoints[i] = NewOutPoint(&hash, uint32(i))
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Send two requests at once to verify responses are matched in order.
+	results := make(chan []*bitcoin.UTXO, 2)
+	fetchErrs := make(chan error, 2)
+	for _, request := range [][]*OutPoint{outpoints, outpoints[1:2]} {
+		go func(request []*OutPoint) {
+			utxos, msg, err := fetcher.FetchUTXOs(fetchCtx, outbound, request, false)
+			if err != nil {
+				fetchErrs <- err
+				return
+			}
+			if msg.ChainHeight != 100 || !msg.ChainTipHash.Equal(&tipHash) {
+				fetchErrs <- errors.New("Wrong chain tip")
+				return
+			}
+			results <- utxos
+		}(request)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-fetchErrs:
+			t.Fatalf("Failed to fetch utxos : %s", err)
+		case utxos := <-results:
+			if len(utxos) == 1 {
+				if utxos[0] != nil {
+					t.Fatalf("Odd outpoint should be spent")
+				}
+				continue
+			}
+
+			if len(utxos) != len(outpoints) {
+				t.Fatalf("Wrong utxo count : got %d, want %d", len(utxos), len(outpoints))
+			}
+
+			for j, utxo := range utxos {
+				if (j%2 == 0) != (utxo != nil) {
+					t.Fatalf("Wrong unspent status for outpoint %d", j)
+				}
+				if utxo != nil && utxo.Value != uint64(j)*10 {
+					t.Fatalf("Wrong value for outpoint %d : got %d, want %d", j, utxo.Value,
+						j*10)
+				}
+			}
+		}
+	}
+
+	close(interrupt)
+
+	if err := <-outboundComplete; err != nil {
+		t.Fatalf("Outbound peer failed : %s", err)
+	}
+	<-inboundComplete
+}
+
+func TestFetchUTXOsNotSupported(t *testing.T) {
+	peer := NewPeer("127.0.0.1:8333", DefaultPeerConfig(bitcoin.MainNet))
+	peer.remoteVersion = &MsgVersion{Services: SFNodeNetwork}
+
+	_, _, err := NewUTXOFetcher().FetchUTXOs(context.Background(), peer, nil, false)
+	if errors.Cause(err) != ErrUTXOsNotSupported {
+		t.Fatalf("Wrong error : got %v, want %s", err, ErrUTXOsNotSupported)
+	}
+}